@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/openai/openai-go"
+	"github.com/workflowai/workflowai/go/examples/workflowai"
+)
+
+// Asks a question about an image and streams the answer. The image is either
+// a URL, sent as is, or a local file, sent inline as base64:
+//
+//	go run ./chat-completion-vision -image photo.jpg "What is in this photo?"
+func main() {
+	image := flag.String("image", "https://upload.wikimedia.org/wikipedia/commons/3/3a/Cat03.jpg", "URL or path of the image")
+	model := flag.String("model", "gpt-4o-mini-latest", "model, which must support image inputs")
+	detail := flag.String("detail", "auto", `image detail, "auto", "low" or "high"`)
+	flag.Parse()
+	question := "Describe this image."
+	if flag.NArg() > 0 {
+		question = flag.Arg(0)
+	}
+
+	client := workflowai.NewClient()
+	ctx := context.Background()
+
+	// Not all models accept images: check the catalog before sending one
+	supports, err := workflowai.FindModelCapabilities(ctx, client.Client, *model)
+	if err != nil {
+		panic(err)
+	}
+	if !supports.Input.Image {
+		fmt.Fprintf(os.Stderr, "%s does not support image inputs\n", *model)
+		os.Exit(1)
+	}
+
+	var imagePart openai.ChatCompletionContentPartUnionParam
+	if isURL(*image) {
+		imagePart = workflowai.ImageURLPart(*image, *detail)
+	} else {
+		imagePart, err = workflowai.ImageFilePart(*image, *detail)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	fmt.Printf("> %s\n", question)
+	stream := client.Chat.Completions.NewStreaming(ctx, openai.ChatCompletionNewParams{
+		Model: *model,
+		Messages: []openai.ChatCompletionMessageParamUnion{
+			openai.UserMessage([]openai.ChatCompletionContentPartUnionParam{
+				openai.TextContentPart(question),
+				imagePart,
+			}),
+		},
+	})
+	defer stream.Close()
+
+	for stream.Next() {
+		chunk := stream.Current()
+		if len(chunk.Choices) > 0 {
+			fmt.Print(chunk.Choices[0].Delta.Content)
+		}
+	}
+	if err := stream.Err(); err != nil {
+		panic(err)
+	}
+	fmt.Println()
+}
+
+func isURL(s string) bool {
+	for _, prefix := range []string{"http://", "https://", "data:"} {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,60 @@
+// Command workflowai-benchmark runs a dataset across several models or agent
+// versions and prints a comparison of quality, latency and cost.
+//
+// Usage:
+//
+//	workflowai-benchmark -dataset cases.jsonl -prompt "Summarize {{text}}" \
+//		-models my-agent/gpt-4o,my-agent/claude-3-5-sonnet-latest \
+//		-judge gpt-4o -rubric "The summary is accurate and concise"
+//
+// The prompt is rendered by WorkflowAI with the input of each case. When no
+// judge is given, outputs are compared to the expected outputs with
+// -match (exact or subset).
+//
+// The client is configured from WORKFLOWAI_API_URL and WORKFLOWAI_API_KEY.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/workflowai/workflowai/go/examples/workflowai"
+	"github.com/workflowai/workflowai/go/examples/workflowai/workflowaieval"
+)
+
+func main() {
+	var benchmark workflowaieval.BenchmarkFlags
+	benchmark.Register(flag.CommandLine)
+	flag.Parse()
+
+	if benchmark.Dataset == "" || benchmark.Models == "" || (benchmark.Prompt == "" && benchmark.Instructions == "") {
+		fmt.Fprintln(os.Stderr, "-dataset, -models and -prompt or -instructions are required")
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	client := workflowai.NewClient()
+	comparison, err := benchmark.Comparison(&client.Chat.Completions, "")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	report, err := workflowaieval.Compare(ctx, comparison)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, workflowai.ScrubError(err))
+		os.Exit(1)
+	}
+	ds := comparison.Dataset
+	fmt.Printf("Dataset %s (%s), %d cases\n\n", ds.Name, ds.ID, len(ds.Cases))
+	if err := report.WriteMarkdown(os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+	"github.com/openai/openai-go/packages/ssestream"
+
+	"github.com/workflowai/workflowai/go/examples/workflowai"
+)
+
+type config struct {
+	Model       string
+	Prompt      string
+	Input       string
+	Concurrency int
+	RPS         float64
+	Duration    time.Duration
+	Requests    int
+	StreamRatio float64
+}
+
+func (c config) validate() error {
+	switch {
+	case c.Model == "":
+		return errors.New("-model is required")
+	case c.Concurrency < 1:
+		return errors.New("-concurrency must be at least 1")
+	case c.RPS < 0:
+		return errors.New("-rps must be positive")
+	case c.Duration <= 0:
+		// A zero timeout would end the test before the first request
+		return errors.New("-duration must be positive")
+	case c.StreamRatio < 0 || c.StreamRatio > 1:
+		return errors.New("-stream-ratio must be between 0 and 1")
+	case c.Input != "" && !json.Valid([]byte(c.Input)):
+		return errors.New("-input must be valid JSON")
+	}
+	return nil
+}
+
+// streamer is implemented by *openai.ChatCompletionService.
+type streamer interface {
+	workflowai.ChatCompleter
+	NewStreaming(ctx context.Context, body openai.ChatCompletionNewParams, opts ...option.RequestOption) *ssestream.Stream[openai.ChatCompletionChunk]
+}
+
+// sample is the measurement of a single request.
+type sample struct {
+	Stream  bool
+	Latency time.Duration
+	// TTFT is the time to the first content chunk of a streamed request
+	TTFT             time.Duration
+	CompletionTokens int64
+	Err              error
+}
+
+// costRecorder sums the cost reported by WorkflowAI for every run.
+type costRecorder struct {
+	mu    sync.Mutex
+	total float64
+}
+
+func (c *costRecorder) RecordRun(_ context.Context, record workflowai.RunRecord) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.total += record.CostUSD
+	return nil
+}
+
+func (c *costRecorder) Total() float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.total
+}
+
+func run(ctx context.Context, completions streamer, cfg config, costs *costRecorder) *Report {
+	ctx, cancel := context.WithTimeout(ctx, cfg.Duration)
+	defer cancel()
+
+	tickets := make(chan bool)
+	go func() {
+		defer close(tickets)
+		var tick <-chan time.Time
+		if cfg.RPS > 0 {
+			ticker := time.NewTicker(time.Duration(float64(time.Second) / cfg.RPS))
+			defer ticker.Stop()
+			tick = ticker.C
+		}
+		for i := 0; cfg.Requests == 0 || i < cfg.Requests; i++ {
+			if tick != nil {
+				select {
+				case <-tick:
+				case <-ctx.Done():
+					return
+				}
+			}
+			select {
+			case tickets <- rand.Float64() < cfg.StreamRatio:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var (
+		mu      sync.Mutex
+		samples []sample
+		wg      sync.WaitGroup
+	)
+	start := time.Now()
+	for range cfg.Concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for stream := range tickets {
+				s := send(ctx, completions, cfg, stream)
+				if errors.Is(s.Err, context.DeadlineExceeded) && ctx.Err() != nil {
+					// Interrupted by the end of the test
+					continue
+				}
+				mu.Lock()
+				samples = append(samples, s)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	return newReport(samples, time.Since(start), costs.Total())
+}
+
+func (c config) params(stream bool) openai.ChatCompletionNewParams {
+	params := openai.ChatCompletionNewParams{
+		Model:    c.Model,
+		Messages: []openai.ChatCompletionMessageParamUnion{openai.UserMessage(c.Prompt)},
+	}
+	if stream {
+		params.StreamOptions = openai.ChatCompletionStreamOptionsParam{IncludeUsage: openai.Bool(true)}
+	}
+	return params
+}
+
+func send(ctx context.Context, completions streamer, cfg config, stream bool) sample {
+	var opts []option.RequestOption
+	if cfg.Input != "" {
+		opts = append(opts, option.WithJSONSet("input", json.RawMessage(cfg.Input)))
+	}
+	s := sample{Stream: stream}
+	start := time.Now()
+
+	if !stream {
+		completion, err := completions.New(ctx, cfg.params(false), opts...)
+		s.Latency = time.Since(start)
+		if err != nil {
+			s.Err = err
+			return s
+		}
+		s.CompletionTokens = completion.Usage.CompletionTokens
+		return s
+	}
+
+	st := completions.NewStreaming(ctx, cfg.params(true), opts...)
+	defer st.Close()
+	for st.Next() {
+		chunk := st.Current()
+		if s.TTFT == 0 && len(chunk.Choices) > 0 && chunk.Choices[0].Delta.Content != "" {
+			s.TTFT = time.Since(start)
+		}
+		if chunk.Usage.CompletionTokens > 0 {
+			s.CompletionTokens = chunk.Usage.CompletionTokens
+		}
+	}
+	s.Latency = time.Since(start)
+	s.Err = st.Err()
+	return s
+}
+
+// Report aggregates the samples of a load test.
+type Report struct {
+	Requests int
+	Errors   int
+	Elapsed  time.Duration
+	CostUSD  float64
+	// Latency and TTFT are sorted
+	Latency []time.Duration
+	TTFT    []time.Duration
+	// TokensPerSecond is the output rate of streamed requests after the
+	// first token, or of whole requests when nothing was streamed
+	TokensPerSecond  float64
+	CompletionTokens int64
+	ErrorsByMessage  map[string]int
+}
+
+func newReport(samples []sample, elapsed time.Duration, cost float64) *Report {
+	r := &Report{
+		Requests:        len(samples),
+		Elapsed:         elapsed,
+		CostUSD:         cost,
+		ErrorsByMessage: map[string]int{},
+	}
+	var streamTokens, syncTokens int64
+	var streamTime, syncTime time.Duration
+	for _, s := range samples {
+		if s.Err != nil {
+			r.Errors++
+			r.ErrorsByMessage[workflowai.Scrub(s.Err.Error())]++
+			continue
+		}
+		r.Latency = append(r.Latency, s.Latency)
+		r.CompletionTokens += s.CompletionTokens
+		if s.Stream && s.TTFT > 0 {
+			r.TTFT = append(r.TTFT, s.TTFT)
+			streamTokens += s.CompletionTokens
+			streamTime += s.Latency - s.TTFT
+		} else {
+			syncTokens += s.CompletionTokens
+			syncTime += s.Latency
+		}
+	}
+	sort.Slice(r.Latency, func(i, j int) bool { return r.Latency[i] < r.Latency[j] })
+	sort.Slice(r.TTFT, func(i, j int) bool { return r.TTFT[i] < r.TTFT[j] })
+	switch {
+	case streamTime > 0:
+		r.TokensPerSecond = float64(streamTokens) / streamTime.Seconds()
+	case syncTime > 0:
+		r.TokensPerSecond = float64(syncTokens) / syncTime.Seconds()
+	}
+	return r
+}
+
+// ErrorRate returns the fraction of requests that failed.
+func (r *Report) ErrorRate() float64 {
+	if r.Requests == 0 {
+		return 0
+	}
+	return float64(r.Errors) / float64(r.Requests)
+}
+
+// percentile returns the p-th percentile (0 < p <= 100) of sorted durations.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(float64(len(sorted))*p/100+0.5) - 1
+	return sorted[min(max(i, 0), len(sorted)-1)]
+}
+
+// Print writes a human readable summary of the report.
+func (r *Report) Print(w io.Writer) {
+	fmt.Fprintf(w, "requests:     %d in %s (%.2f req/s)\n", r.Requests, r.Elapsed.Round(time.Millisecond), float64(r.Requests)/r.Elapsed.Seconds())
+	fmt.Fprintf(w, "errors:       %d (%.1f%%)\n", r.Errors, r.ErrorRate()*100)
+	printDurations(w, "latency:", r.Latency)
+	printDurations(w, "ttft:", r.TTFT)
+	fmt.Fprintf(w, "tokens/s:     %.1f per request, %.1f overall\n", r.TokensPerSecond, float64(r.CompletionTokens)/r.Elapsed.Seconds())
+	fmt.Fprintf(w, "cost:         $%.4f total", r.CostUSD)
+	if n := r.Requests - r.Errors; n > 0 {
+		fmt.Fprintf(w, ", $%.6f per request", r.CostUSD/float64(n))
+	}
+	fmt.Fprintln(w)
+
+	messages := make([]string, 0, len(r.ErrorsByMessage))
+	for m := range r.ErrorsByMessage {
+		messages = append(messages, m)
+	}
+	sort.Slice(messages, func(i, j int) bool { return r.ErrorsByMessage[messages[i]] > r.ErrorsByMessage[messages[j]] })
+	for _, m := range messages {
+		fmt.Fprintf(w, "  %5d × %s\n", r.ErrorsByMessage[m], m)
+	}
+}
+
+func printDurations(w io.Writer, label string, sorted []time.Duration) {
+	if len(sorted) == 0 {
+		fmt.Fprintf(w, "%-13s n/a\n", label)
+		return
+	}
+	fmt.Fprintf(w, "%-13s p50 %s, p90 %s, p99 %s, max %s\n", label,
+		percentile(sorted, 50).Round(time.Millisecond),
+		percentile(sorted, 90).Round(time.Millisecond),
+		percentile(sorted, 99).Round(time.Millisecond),
+		sorted[len(sorted)-1].Round(time.Millisecond),
+	)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+
+	"github.com/workflowai/workflowai/go/examples/workflowai"
+)
+
+func TestRun(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if bytes.Contains(body, []byte(`"stream":true`)) {
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprint(w, `data: {"id":"a/1","object":"chat.completion.chunk","model":"m","choices":[{"index":0,"delta":{"content":"Hi"}}]}`+"\n\n")
+			fmt.Fprint(w, `data: {"id":"a/1","object":"chat.completion.chunk","model":"m","choices":[{"index":0,"delta":{},"finish_reason":"stop","cost_usd":0.5}],"usage":{"prompt_tokens":1,"completion_tokens":4,"total_tokens":5}}`+"\n\n")
+			fmt.Fprint(w, "data: [DONE]\n\n")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id":"a/2","object":"chat.completion","model":"m","choices":[{"index":0,"finish_reason":"stop","cost_usd":0.5,"message":{"role":"assistant","content":"Hi"}}],"usage":{"prompt_tokens":1,"completion_tokens":4,"total_tokens":5}}`)
+	}))
+	defer server.Close()
+
+	costs := &costRecorder{}
+	client := openai.NewClient(
+		option.WithBaseURL(server.URL+"/v1/"),
+		option.WithAPIKey("key"),
+		option.WithMiddleware(workflowai.RunLogMiddleware(costs)),
+	)
+	cfg := config{Model: "a/m", Prompt: "Hello", Concurrency: 3, Duration: 10 * time.Second, Requests: 10, StreamRatio: 0.5}
+	report := run(context.Background(), &client.Chat.Completions, cfg, costs)
+
+	if report.Requests != 10 || report.Errors != 0 {
+		t.Fatalf("expected 10 successful requests, got %d with %d errors", report.Requests, report.Errors)
+	}
+	if report.CostUSD != 5 {
+		t.Errorf("expected a total cost of 5, got %v", report.CostUSD)
+	}
+	if report.CompletionTokens != 40 {
+		t.Errorf("expected 40 completion tokens, got %d", report.CompletionTokens)
+	}
+
+	var out strings.Builder
+	report.Print(&out)
+	if !strings.Contains(out.String(), "requests:     10") {
+		t.Errorf("unexpected report:\n%s", out.String())
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	sorted := []time.Duration{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	if got := percentile(sorted, 50); got != 5 {
+		t.Errorf("p50: expected 5, got %d", got)
+	}
+	if got := percentile(sorted, 99); got != 10 {
+		t.Errorf("p99: expected 10, got %d", got)
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	valid := config{Model: "a/m", Concurrency: 1, Duration: time.Second}
+	if err := valid.validate(); err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range []time.Duration{0, -time.Second} {
+		cfg := valid
+		cfg.Duration = d
+		if err := cfg.validate(); err == nil {
+			t.Errorf("expected a duration of %v to be rejected", d)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+// Command workflowai-loadtest sends chat completions to a deployment at a
+// given concurrency and rate, and reports latency, time to first token,
+// throughput, error rate and cost.
+//
+// Usage:
+//
+//	workflowai-loadtest -model my-agent/#1/production -concurrency 20 -rps 10 -duration 1m
+//
+// The client is configured from WORKFLOWAI_API_URL and WORKFLOWAI_API_KEY.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/openai/openai-go/option"
+
+	"github.com/workflowai/workflowai/go/examples/workflowai"
+)
+
+func main() {
+	var cfg config
+	flag.StringVar(&cfg.Model, "model", "", "model or deployment to target, e.g. my-agent/#1/production (required)")
+	flag.StringVar(&cfg.Prompt, "prompt", "Write a haiku about load testing.", "user message sent with every request")
+	flag.StringVar(&cfg.Input, "input", "", "JSON input of the agent, sent as the input extra field")
+	flag.IntVar(&cfg.Concurrency, "concurrency", 4, "number of concurrent requests")
+	flag.Float64Var(&cfg.RPS, "rps", 0, "maximum requests per second, 0 for no limit")
+	flag.DurationVar(&cfg.Duration, "duration", 30*time.Second, "duration of the test")
+	flag.IntVar(&cfg.Requests, "requests", 0, "stop after this many requests, 0 for no limit")
+	flag.Float64Var(&cfg.StreamRatio, "stream-ratio", 0.5, "fraction of requests that are streamed, between 0 and 1")
+	flag.Parse()
+
+	if err := cfg.validate(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	costs := &costRecorder{}
+	client := workflowai.NewClient(
+		option.WithMiddleware(workflowai.RunLogMiddleware(costs)),
+		// Retries would hide errors and skew latencies
+		option.WithMaxRetries(0),
+	)
+
+	report := run(ctx, &client.Chat.Completions, cfg, costs)
+	report.Print(os.Stdout)
+	if report.Requests == 0 || report.Errors == report.Requests {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+
+	"github.com/workflowai/workflowai/go/examples/workflowai"
+	"github.com/workflowai/workflowai/go/examples/workflowai/mdrender"
+)
+
+const chatHelp = `Commands:
+  /model <model>          switch the model or deployment, keeping the history
+  /temperature <t|reset>  set the temperature, or reset it to the default
+  /system <prompt>        set the system prompt, empty to remove it
+  /reset                  clear the history
+  /history                print the history
+  /save <file>            export the transcript, as JSON for .json files, Markdown otherwise
+  /help                   print this help
+  /exit                   quit`
+
+// transcriptEntry is a message of the chat transcript.
+type transcriptEntry struct {
+	Role    string    `json:"role"`
+	Content string    `json:"content"`
+	Model   string    `json:"model,omitempty"`
+	CostUSD float64   `json:"cost_usd,omitempty"`
+	RunID   string    `json:"run_id,omitempty"`
+	Time    time.Time `json:"time"`
+}
+
+// chatSession is the state of a chat REPL.
+type chatSession struct {
+	cli         *cli
+	client      workflowai.Client
+	agent       string
+	model       string
+	system      string
+	temperature *float64
+	// markdown renders the replies as markdown in the terminal
+	markdown   bool
+	transcript []transcriptEntry
+	// last is set by the hooks at the end of each reply
+	last workflowai.CompleteEvent
+}
+
+func (c *cli) cmdChat(ctx context.Context, args []string) error {
+	fs := c.newFlagSet("chat", "[-agent my-agent] [-model gpt-4o] [-system prompt]")
+	agent := fs.String("agent", "", "agent ID, the runs are recorded under this agent")
+	model := fs.String("model", "gpt-4o-mini-latest", `model, or deployment of the agent, e.g. "#1/production"`)
+	system := fs.String("system", "", "system prompt")
+	temperature := fs.String("temperature", "", "temperature, the default of the model when empty")
+	raw := fs.Bool("raw", false, "print the replies as they are received, also when NO_COLOR is set or stdout is not a terminal")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	s := &chatSession{cli: c, agent: *agent, model: *model, system: *system}
+	s.markdown = !*raw && os.Getenv("NO_COLOR") == "" && isTerminal(c.stdout)
+	if *temperature != "" {
+		if err := s.setTemperature(*temperature); err != nil {
+			return usage(fs, "%v", err)
+		}
+	}
+	var hooks workflowai.Hooks
+	hooks.OnComplete(func(_ context.Context, e workflowai.CompleteEvent) {
+		s.last = e
+	})
+	s.client = c.client(option.WithMiddleware(hooks.Middleware()))
+
+	fmt.Fprintf(c.stdout, "Chatting with %s, /help for the commands.\n", s.modelName())
+	scanner := bufio.NewScanner(c.stdin)
+	for {
+		fmt.Fprint(c.stdout, "> ")
+		if !scanner.Scan() {
+			fmt.Fprintln(c.stdout)
+			return scanner.Err()
+		}
+		quit, err := s.handle(ctx, strings.TrimSpace(scanner.Text()))
+		if errors.Is(err, context.Canceled) {
+			return nil
+		}
+		if err != nil {
+			// Errors end the turn, not the session
+			fmt.Fprintf(c.stderr, "error: %v\n", workflowai.ScrubError(err))
+		}
+		if quit {
+			return nil
+		}
+	}
+}
+
+func (s *chatSession) modelName() string {
+	if s.agent != "" {
+		return s.agent + "/" + s.model
+	}
+	return s.model
+}
+
+// handle handles a line of the user, a slash command or a message. It
+// returns true to quit.
+func (s *chatSession) handle(ctx context.Context, line string) (bool, error) {
+	if line == "" {
+		return false, nil
+	}
+	if !strings.HasPrefix(line, "/") {
+		return false, s.send(ctx, line)
+	}
+	name, arg, _ := strings.Cut(line[1:], " ")
+	arg = strings.TrimSpace(arg)
+	out := s.cli.stdout
+	switch name {
+	case "exit", "quit":
+		return true, nil
+	case "help":
+		fmt.Fprintln(out, chatHelp)
+	case "model":
+		if arg == "" {
+			fmt.Fprintln(out, s.modelName())
+			return false, nil
+		}
+		s.model = arg
+		fmt.Fprintf(out, "Switched to %s\n", s.modelName())
+	case "temperature":
+		if arg == "" {
+			if s.temperature == nil {
+				fmt.Fprintln(out, "default")
+			} else {
+				fmt.Fprintln(out, *s.temperature)
+			}
+			return false, nil
+		}
+		if err := s.setTemperature(arg); err != nil {
+			return false, err
+		}
+	case "system":
+		s.system = arg
+	case "reset":
+		s.transcript = nil
+	case "history":
+		for _, entry := range s.transcript {
+			fmt.Fprintf(out, "%s: %s\n", entry.Role, entry.Content)
+		}
+	case "save":
+		if arg == "" {
+			return false, errors.New("usage: /save <file>")
+		}
+		if err := s.save(arg); err != nil {
+			return false, err
+		}
+		fmt.Fprintf(out, "Saved %d messages to %s\n", len(s.transcript), arg)
+	default:
+		return false, fmt.Errorf("unknown command /%s, /help for the commands", name)
+	}
+	return false, nil
+}
+
+func (s *chatSession) setTemperature(arg string) error {
+	if arg == "reset" || arg == "default" {
+		s.temperature = nil
+		return nil
+	}
+	t, err := strconv.ParseFloat(arg, 64)
+	if err != nil || t < 0 || t > 2 {
+		return fmt.Errorf("invalid temperature %q, expected a number between 0 and 2", arg)
+	}
+	s.temperature = &t
+	return nil
+}
+
+// send streams the reply to message. The message is only added to the
+// history when the reply succeeds.
+func (s *chatSession) send(ctx context.Context, message string) error {
+	params := openai.ChatCompletionNewParams{Model: s.modelName()}
+	if s.system != "" {
+		params.Messages = append(params.Messages, openai.SystemMessage(s.system))
+	}
+	for _, entry := range s.transcript {
+		if entry.Role == "user" {
+			params.Messages = append(params.Messages, openai.UserMessage(entry.Content))
+		} else {
+			params.Messages = append(params.Messages, openai.AssistantMessage(entry.Content))
+		}
+	}
+	params.Messages = append(params.Messages, openai.UserMessage(message))
+	if s.temperature != nil {
+		params.Temperature = openai.Float(*s.temperature)
+	}
+
+	s.last = workflowai.CompleteEvent{}
+	stream := s.client.Chat.Completions.NewStreaming(ctx, params)
+	defer stream.Close()
+	var reply strings.Builder
+	var renderer *mdrender.Renderer
+	if s.markdown {
+		renderer = mdrender.NewTerminal(s.cli.stdout)
+	}
+	for stream.Next() {
+		chunk := stream.Current()
+		if len(chunk.Choices) == 0 {
+			continue
+		}
+		content := chunk.Choices[0].Delta.Content
+		reply.WriteString(content)
+		if renderer != nil {
+			renderer.WriteString(content)
+		} else {
+			fmt.Fprint(s.cli.stdout, content)
+		}
+	}
+	if renderer != nil {
+		renderer.Flush()
+	} else {
+		fmt.Fprintln(s.cli.stdout)
+	}
+	if err := stream.Err(); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	s.transcript = append(s.transcript,
+		transcriptEntry{Role: "user", Content: message, Time: now},
+		transcriptEntry{Role: "assistant", Content: reply.String(), Model: s.modelName(), CostUSD: s.last.CostUSD, RunID: s.last.RunID, Time: now},
+	)
+	return nil
+}
+
+func (s *chatSession) save(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(map[string]any{"system": s.system, "messages": s.transcript}); err != nil {
+			return err
+		}
+		return f.Close()
+	}
+	w := bufio.NewWriter(f)
+	if s.system != "" {
+		fmt.Fprintf(w, "**System**\n\n%s\n\n", s.system)
+	}
+	for _, entry := range s.transcript {
+		if entry.Role == "user" {
+			fmt.Fprintf(w, "**User**\n\n%s\n\n", entry.Content)
+		} else {
+			fmt.Fprintf(w, "**Assistant** (%s, $%.6f)\n\n%s\n\n", entry.Model, entry.CostUSD, entry.Content)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestChat(t *testing.T) {
+	var requests []map[string]any
+	server := newTestServer(t, &requests)
+	transcript := filepath.Join(t.TempDir(), "transcript.json")
+	c, stdout, stderr := testCLI(server, strings.Join([]string{
+		"Hi",
+		"/model #1/production",
+		"/temperature 0.2",
+		"/system Be brief",
+		"/temperature 3",
+		"How are you?",
+		"/save " + transcript,
+		"/exit",
+	}, "\n"))
+
+	code := c.main(context.Background(), []string{"chat", "-agent", "my-agent", "-model", "gpt-4o"})
+	if code != 0 {
+		t.Fatalf("exit code %d: %s", code, stderr)
+	}
+	if !strings.Contains(stdout.String(), "Hello world\n") || !strings.Contains(stdout.String(), "Switched to my-agent/#1/production") {
+		t.Errorf("unexpected output %q", stdout)
+	}
+	if !strings.Contains(stderr.String(), "invalid temperature") {
+		t.Errorf("expected an invalid temperature error, got %q", stderr)
+	}
+
+	if len(requests) != 2 {
+		t.Fatalf("expected 2 requests, got %d", len(requests))
+	}
+	second := requests[1]
+	if second["model"] != "my-agent/#1/production" || second["temperature"] != 0.2 {
+		t.Errorf("unexpected request %v", second)
+	}
+	// The system prompt and the history are sent with the new message
+	if messages := second["messages"].([]any); len(messages) != 4 || messages[0].(map[string]any)["content"] != "Be brief" {
+		t.Errorf("unexpected messages %v", messages)
+	}
+
+	data, err := os.ReadFile(transcript)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var saved struct {
+		System   string            `json:"system"`
+		Messages []transcriptEntry `json:"messages"`
+	}
+	if err := json.Unmarshal(data, &saved); err != nil {
+		t.Fatal(err)
+	}
+	if saved.System != "Be brief" || len(saved.Messages) != 4 || saved.Messages[3].CostUSD != 0.25 || saved.Messages[3].RunID != "run-1" {
+		t.Errorf("unexpected transcript %+v", saved)
+	}
+}
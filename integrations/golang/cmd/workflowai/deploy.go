@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/workflowai/workflowai/go/examples/workflowai"
+	"github.com/workflowai/workflowai/go/examples/workflowai/workflowaieval"
+)
+
+func (c *cli) cmdDeploy(ctx context.Context, args []string) error {
+	fs := c.newFlagSet("deploy", "-agent my-agent -version 12.1 -env production [-yes]")
+	agent := fs.String("agent", "", "agent ID (required)")
+	versionID := fs.String("version", "", `version to deploy: a semver such as "12.1", a major such as "12" for its latest minor, or a version ID (required)`)
+	env := fs.String("env", "", "environment: dev, staging or production (required)")
+	yes := fs.Bool("yes", false, "deploy without asking for confirmation")
+	force := fs.Bool("force", false, "deploy even if the schemas are incompatible with the deployed version")
+	deployedBy := fs.String("deployed-by", defaultDeployer(), "who deploys, recorded in the notes of the version")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *agent == "" || *versionID == "" || *env == "" {
+		return usage(fs, "-agent, -version and -env are required")
+	}
+	switch *env {
+	case workflowai.EnvironmentDev, workflowai.EnvironmentStaging, workflowai.EnvironmentProduction:
+	default:
+		return usage(fs, "unknown environment %q, expected dev, staging or production", *env)
+	}
+
+	client := c.client()
+	majors, err := client.Versions.List(ctx, *agent, 0)
+	if err != nil {
+		return fmt.Errorf("listing the versions: %w", err)
+	}
+	if major, err := strconv.Atoi(*versionID); err == nil {
+		if *versionID, err = latestMinor(majors, major); err != nil {
+			return err
+		}
+	}
+	version, err := client.Versions.Get(ctx, *agent, *versionID)
+	if err != nil {
+		return fmt.Errorf("fetching version %s: %w", *versionID, err)
+	}
+
+	out := c.stdout
+	fmt.Fprintf(out, "Deploying %s version %s (%s, schema #%d) to %s\n", *agent, version.Name(), version.Model, version.SchemaID, *env)
+	if deployedMinor, _ := workflowai.DeployedVersion(majors, *env, version.SchemaID); deployedMinor == nil {
+		fmt.Fprintf(out, "\nNo version is deployed to %s.\n", *env)
+	} else {
+		deployed, err := client.Versions.Get(ctx, *agent, deployedMinor.ID)
+		if err != nil {
+			return fmt.Errorf("fetching the deployed version: %w", err)
+		}
+		if deployed.ID == version.ID {
+			fmt.Fprintf(out, "\nVersion %s is already deployed to %s.\n", version.Name(), *env)
+			return nil
+		}
+		fmt.Fprintf(out, "\nCurrently deployed: version %s (%s, schema #%d)\n", deployed.Name(), deployed.Model, deployed.SchemaID)
+		oldProperties, _ := json.Marshal(deployed.Properties)
+		newProperties, _ := json.Marshal(version.Properties)
+		fmt.Fprintf(out, "\n%s", workflowaieval.Diff(oldProperties, newProperties))
+
+		if deployed.SchemaID != version.SchemaID {
+			fmt.Fprintf(out, "\nThe schema changes: callers of %s/#%d/%s keep using version %s, new callers must use %s/#%d/%s.\n",
+				*agent, deployed.SchemaID, *env, deployed.Name(), *agent, version.SchemaID, *env)
+			changes := breakingChanges("input", deployed.InputSchema, version.InputSchema, false)
+			changes = append(changes, breakingChanges("output", deployed.OutputSchema, version.OutputSchema, true)...)
+			if len(changes) > 0 {
+				fmt.Fprintf(out, "\nIncompatible schema changes:\n  %s\n", strings.Join(changes, "\n  "))
+				if !*force {
+					return errors.New("the schemas are incompatible with the deployed version, use -force to deploy anyway")
+				}
+			}
+		}
+	}
+
+	if !*yes {
+		fmt.Fprintf(out, "\nDeploy version %s to %s? [y/N] ", version.Name(), *env)
+		answer, _ := bufio.NewReader(c.stdin).ReadString('\n')
+		if a := strings.ToLower(strings.TrimSpace(answer)); a != "y" && a != "yes" {
+			return errors.New("deployment cancelled")
+		}
+	}
+
+	deployment, err := client.Versions.Deploy(ctx, *agent, version.ID, *env)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Deployed version %s to %s, use the model %s/#%d/%s\n", version.Name(), *env, *agent, deployment.SchemaID, *env)
+
+	if *deployedBy != "" {
+		note := fmt.Sprintf("Deployed to %s by %s on %s", *env, *deployedBy, deployment.DeployedAt.UTC().Format(time.RFC3339))
+		if err := client.Versions.UpdateNotes(ctx, *agent, version.ID, deployNotes(version.Notes, *env, note)); err != nil {
+			// The deployment succeeded, only its record failed
+			fmt.Fprintf(c.stderr, "warning: recording the deployment in the notes: %v\n", workflowai.ScrubError(err))
+		}
+	}
+	return nil
+}
+
+// latestMinor returns the semver of the latest minor of a major version.
+func latestMinor(majors []workflowai.MajorVersion, major int) (string, error) {
+	for _, m := range majors {
+		if m.Major != major || len(m.Minors) == 0 {
+			continue
+		}
+		latest := m.Minors[0].Minor
+		for _, minor := range m.Minors {
+			latest = max(latest, minor.Minor)
+		}
+		return fmt.Sprintf("%d.%d", major, latest), nil
+	}
+	return "", fmt.Errorf("no version %d", major)
+}
+
+// deployNotes replaces the deploy note of env in notes with note, keeping
+// the other lines, so that redeploying a version does not grow its notes.
+func deployNotes(notes string, env string, note string) string {
+	var lines []string
+	for _, line := range strings.Split(notes, "\n") {
+		if line != "" && !strings.HasPrefix(line, "Deployed to "+env+" by ") {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(append(lines, note), "\n")
+}
+
+func defaultDeployer() string {
+	user := os.Getenv("USER")
+	if host, err := os.Hostname(); err == nil && user != "" {
+		return user + "@" + host
+	}
+	return user
+}
+
+// jsonSchema holds the parts of a JSON schema compared by breakingChanges.
+type jsonSchema struct {
+	Type       any                   `json:"type"`
+	Properties map[string]jsonSchema `json:"properties"`
+	Required   []string              `json:"required"`
+	Items      *jsonSchema           `json:"items"`
+}
+
+// breakingChanges lists the changes from oldSchema to newSchema that break
+// existing callers. Input schemas break when a field becomes required or
+// changes type. Output schemas break when a required field becomes optional
+// or is removed, or changes type.
+func breakingChanges(name string, oldSchema json.RawMessage, newSchema json.RawMessage, output bool) []string {
+	var from, to jsonSchema
+	if json.Unmarshal(oldSchema, &from) != nil || json.Unmarshal(newSchema, &to) != nil {
+		return nil
+	}
+	var changes []string
+	compareSchemas(name, from, to, output, &changes)
+	return changes
+}
+
+func compareSchemas(path string, from jsonSchema, to jsonSchema, output bool, changes *[]string) {
+	if from.Type != nil && to.Type != nil && fmt.Sprint(from.Type) != fmt.Sprint(to.Type) {
+		*changes = append(*changes, fmt.Sprintf("%s: type changed from %v to %v", path, from.Type, to.Type))
+		return
+	}
+	if from.Items != nil && to.Items != nil {
+		compareSchemas(path+"[]", *from.Items, *to.Items, output, changes)
+	}
+
+	wasRequired := map[string]bool{}
+	for _, field := range from.Required {
+		wasRequired[field] = true
+	}
+	isRequired := map[string]bool{}
+	for _, field := range to.Required {
+		isRequired[field] = true
+	}
+	fields := make([]string, 0, len(from.Properties)+len(to.Properties))
+	for field := range from.Properties {
+		fields = append(fields, field)
+	}
+	for field := range to.Properties {
+		if _, ok := from.Properties[field]; !ok {
+			fields = append(fields, field)
+		}
+	}
+	sort.Strings(fields)
+	for _, field := range fields {
+		fieldPath := path + "." + field
+		oldField, hadField := from.Properties[field]
+		newField, hasField := to.Properties[field]
+		switch {
+		case output && wasRequired[field] && !hasField:
+			*changes = append(*changes, fieldPath+": required field removed")
+		case output && wasRequired[field] && !isRequired[field]:
+			*changes = append(*changes, fieldPath+": no longer required")
+		case !output && isRequired[field] && !wasRequired[field]:
+			*changes = append(*changes, fieldPath+": new required field")
+		}
+		if hadField && hasField {
+			compareSchemas(fieldPath, oldField, newField, output, changes)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const testVersions = `{"items":[
+	{"major":11,"schema_id":1,"created_at":"2025-01-01T00:00:00Z","minors":[
+		{"id":"v11","minor":1,"model":"gpt-4o","deployments":[{"environment":"production","deployed_at":"2025-01-02T00:00:00Z"}]}
+	]},
+	{"major":12,"schema_id":%d,"created_at":"2025-02-01T00:00:00Z","minors":[
+		{"id":"v12-0","minor":0,"model":"gpt-4o"},
+		{"id":"v12-1","minor":1,"model":"gpt-4.1"}
+	]}
+]}`
+
+// newDeployServer serves the versions of my-agent, version 12 having the
+// output schema outputSchema under the schema schemaID. requests receives
+// the deploy and notes requests.
+func newDeployServer(t *testing.T, schemaID int, outputSchema string, requests *[]string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		body, _ := io.ReadAll(r.Body)
+		switch r.Method + " " + r.URL.Path {
+		case "GET /v1/_/agents/my-agent/versions":
+			fmt.Fprintf(w, testVersions, schemaID)
+		case "GET /v1/_/agents/my-agent/versions/v11":
+			fmt.Fprint(w, `{"id":"v11","schema_id":1,"semver":[11,1],"model":"gpt-4o","properties":{"model":"gpt-4o","temperature":0},
+				"input_schema":{"type":"object","properties":{"text":{"type":"string"}},"required":["text"]},
+				"output_schema":{"type":"object","properties":{"summary":{"type":"string"}},"required":["summary"]}}`)
+		case "GET /v1/_/agents/my-agent/versions/12.1":
+			fmt.Fprintf(w, `{"id":"v12-1","schema_id":%d,"semver":[12,1],"model":"gpt-4.1","notes":"faster","properties":{"model":"gpt-4.1","temperature":0},
+				"input_schema":{"type":"object","properties":{"text":{"type":"string"}},"required":["text"]},
+				"output_schema":%s}`, schemaID, outputSchema)
+		case "POST /v1/_/agents/my-agent/versions/v12-1/deploy", "PATCH /v1/_/agents/my-agent/versions/v12-1/notes":
+			*requests = append(*requests, r.Method+" "+string(body))
+			fmt.Fprintf(w, `{"task_schema_id":%d,"version_id":"v12-1","environment":"production","deployed_at":"2025-03-01T00:00:00Z"}`, schemaID)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestDeploy(t *testing.T) {
+	var requests []string
+	server := newDeployServer(t, 1, `{"type":"object","properties":{"summary":{"type":"string"}},"required":["summary"]}`, &requests)
+	c, stdout, stderr := testCLI(server, "y\n")
+	code := c.main(context.Background(), []string{"deploy", "-agent", "my-agent", "-version", "12", "-env", "production", "-deployed-by", "alice"})
+	if code != 0 {
+		t.Fatalf("exit code %d: %s", code, stderr)
+	}
+	for _, want := range []string{
+		"Currently deployed: version 11.1 (gpt-4o, schema #1)",
+		`-   "model": "gpt-4o",`,
+		`+   "model": "gpt-4.1",`,
+		"Deploy version 12.1 to production? [y/N]",
+		"use the model my-agent/#1/production",
+	} {
+		if !strings.Contains(stdout.String(), want) {
+			t.Errorf("output does not contain %q:\n%s", want, stdout)
+		}
+	}
+	if len(requests) != 2 || requests[0] != `POST {"environment":"production"}` {
+		t.Fatalf("unexpected requests %q", requests)
+	}
+	var notes map[string]string
+	json.Unmarshal([]byte(strings.TrimPrefix(requests[1], "PATCH ")), &notes)
+	if notes["notes"] != "faster\nDeployed to production by alice on 2025-03-01T00:00:00Z" {
+		t.Errorf("unexpected notes %q", notes["notes"])
+	}
+}
+
+func TestDeploy_NotConfirmed(t *testing.T) {
+	var requests []string
+	server := newDeployServer(t, 1, `{}`, &requests)
+	c, _, stderr := testCLI(server, "n\n")
+	if code := c.main(context.Background(), []string{"deploy", "-agent", "my-agent", "-version", "12.1", "-env", "production"}); code != 1 {
+		t.Errorf("unexpected exit code %d", code)
+	}
+	if !strings.Contains(stderr.String(), "deployment cancelled") || len(requests) != 0 {
+		t.Errorf("unexpected stderr %q, requests %q", stderr, requests)
+	}
+}
+
+func TestDeploy_IncompatibleSchema(t *testing.T) {
+	var requests []string
+	server := newDeployServer(t, 2, `{"type":"object","properties":{"summary":{"type":"array"}},"required":["summary"]}`, &requests)
+	c, stdout, stderr := testCLI(server, "")
+	if code := c.main(context.Background(), []string{"deploy", "-agent", "my-agent", "-version", "12.1", "-env", "production", "-yes"}); code != 1 {
+		t.Errorf("unexpected exit code %d", code)
+	}
+	if !strings.Contains(stdout.String(), "output.summary: type changed from string to array") || len(requests) != 0 {
+		t.Errorf("unexpected output %q, requests %q", stdout, requests)
+	}
+	if !strings.Contains(stderr.String(), "use -force") {
+		t.Errorf("unexpected stderr %q", stderr)
+	}
+
+	if code := c.main(context.Background(), []string{"deploy", "-agent", "my-agent", "-version", "12.1", "-env", "production", "-yes", "-force", "-deployed-by", "ci"}); code != 0 {
+		t.Errorf("unexpected exit code %d: %s", code, stderr)
+	}
+	if len(requests) != 2 {
+		t.Errorf("unexpected requests %q", requests)
+	}
+}
+
+func TestDeployNotes(t *testing.T) {
+	notes := "faster\nDeployed to staging by ci on 2025-02-01T00:00:00Z\nDeployed to production by bob on 2025-02-02T00:00:00Z"
+	got := deployNotes(notes, "production", "Deployed to production by alice on 2025-03-01T00:00:00Z")
+	if got != "faster\nDeployed to staging by ci on 2025-02-01T00:00:00Z\nDeployed to production by alice on 2025-03-01T00:00:00Z" {
+		t.Errorf("unexpected notes %q", got)
+	}
+	if got := deployNotes("", "dev", "Deployed to dev by ci on 2025-03-01T00:00:00Z"); got != "Deployed to dev by ci on 2025-03-01T00:00:00Z" {
+		t.Errorf("unexpected notes %q", got)
+	}
+}
+
+func TestBreakingChanges(t *testing.T) {
+	old := json.RawMessage(`{"type":"object","properties":{"a":{"type":"string"},"b":{"type":"object","properties":{"c":{"type":"integer"}},"required":["c"]}},"required":["a","b"]}`)
+	input := breakingChanges("input", old, json.RawMessage(`{"type":"object","properties":{"a":{"type":"string"},"b":{"type":"object"},"d":{"type":"string"}},"required":["a","b","d"]}`), false)
+	if strings.Join(input, "|") != "input.d: new required field" {
+		t.Errorf("unexpected input changes %q", input)
+	}
+	output := breakingChanges("output", old, json.RawMessage(`{"type":"object","properties":{"b":{"type":"object","properties":{"c":{"type":"number"}}}},"required":["b"]}`), true)
+	if strings.Join(output, "|") != "output.a: required field removed|output.b.c: no longer required|output.b.c: type changed from integer to number" {
+		t.Errorf("unexpected output changes %q", output)
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/workflowai/workflowai/go/examples/workflowai"
+	"github.com/workflowai/workflowai/go/examples/workflowai/workflowaieval"
+)
+
+// evalReport is the JSON export of "eval", also read back as a baseline.
+type evalReport struct {
+	DatasetID   string          `json:"dataset_id"`
+	DatasetName string          `json:"dataset_name"`
+	Cases       int             `json:"cases"`
+	Candidates  []evalCandidate `json:"candidates"`
+}
+
+type evalCandidate struct {
+	Name        string                      `json:"name"`
+	Score       float64                     `json:"score"`
+	PassRate    float64                     `json:"pass_rate"`
+	Errors      int                         `json:"errors"`
+	P50Latency  time.Duration               `json:"p50_latency"`
+	P90Latency  time.Duration               `json:"p90_latency"`
+	CostPerItem float64                     `json:"cost_per_item_usd"`
+	Results     []workflowaieval.CaseResult `json:"results,omitempty"`
+}
+
+func newEvalReport(ds *workflowai.Dataset, report *workflowaieval.ComparisonReport) evalReport {
+	r := evalReport{DatasetID: ds.ID, DatasetName: ds.Name, Cases: len(ds.Cases)}
+	for _, c := range report.Candidates {
+		errs := 0
+		for _, result := range c.Results {
+			if result.Error != "" {
+				errs++
+			}
+		}
+		r.Candidates = append(r.Candidates, evalCandidate{
+			Name:        c.Name,
+			Score:       c.AverageScore(),
+			PassRate:    c.PassRate(),
+			Errors:      errs,
+			P50Latency:  c.LatencyPercentile(50),
+			P90Latency:  c.LatencyPercentile(90),
+			CostPerItem: c.CostPerItem(),
+			Results:     c.Results,
+		})
+	}
+	return r
+}
+
+// regressions returns a description of each candidate scoring below
+// minScore or more than maxRegression below its score in baseline, and of
+// each case passing in baseline that no longer passes.
+func (r evalReport) regressions(baseline *evalReport, minScore float64, maxRegression float64) []string {
+	var out []string
+	for _, c := range r.Candidates {
+		if c.Score < minScore {
+			out = append(out, fmt.Sprintf("%s: score %.3f is below the minimum %.3f", c.Name, c.Score, minScore))
+		}
+		if baseline == nil {
+			continue
+		}
+		for _, b := range baseline.Candidates {
+			if b.Name != c.Name {
+				continue
+			}
+			if b.Score-c.Score > maxRegression {
+				out = append(out, fmt.Sprintf("%s: score %.3f regressed from %.3f by more than %.3f", c.Name, c.Score, b.Score, maxRegression))
+			}
+			passed := map[string]bool{}
+			for _, result := range b.Results {
+				passed[result.CaseID] = result.Passed()
+			}
+			for _, result := range c.Results {
+				if passed[result.CaseID] && !result.Passed() {
+					out = append(out, fmt.Sprintf("%s: case %s passed in the baseline and now fails", c.Name, result.CaseID))
+				}
+			}
+		}
+	}
+	return out
+}
+
+func (c *cli) cmdEval(ctx context.Context, args []string) error {
+	fs := c.newFlagSet("eval", "-dataset cases.jsonl -agent my-agent -models gpt-4o,claude-3-7-sonnet-latest [-baseline report.json]")
+	var benchmark workflowaieval.BenchmarkFlags
+	benchmark.Register(fs)
+	agent := fs.String("agent", "", "agent ID, prefixed to each model")
+	output := fs.String("output", "", "file the report is exported to, as JSON for .json files, Markdown otherwise")
+	baselinePath := fs.String("baseline", "", "JSON report of a previous evaluation the scores are compared to")
+	maxRegression := fs.Float64("max-regression", 0, "maximum decrease of the score of a model from the baseline")
+	minScore := fs.Float64("min-score", 0, "minimum score of every model")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if benchmark.Dataset == "" || benchmark.Models == "" {
+		return usage(fs, "-dataset and -models are required")
+	}
+	if *agent == "" && benchmark.Prompt == "" && benchmark.Instructions == "" {
+		return usage(fs, "-agent, -prompt or -instructions is required")
+	}
+
+	var baseline *evalReport
+	if *baselinePath != "" {
+		data, err := os.ReadFile(*baselinePath)
+		if err != nil {
+			return err
+		}
+		baseline = &evalReport{}
+		if err := json.Unmarshal(data, baseline); err != nil {
+			return fmt.Errorf("reading the baseline %s: %w", *baselinePath, err)
+		}
+	}
+	client := c.client()
+	config, err := benchmark.Comparison(&client.Chat.Completions, *agent)
+	if err != nil {
+		return err
+	}
+	ds := config.Dataset
+	comparison, err := workflowaieval.Compare(ctx, config)
+	if err != nil {
+		return err
+	}
+	report := newEvalReport(ds, comparison)
+
+	fmt.Fprintf(c.stdout, "Dataset %s (%s), %d cases\n\n", ds.Name, ds.ID, len(ds.Cases))
+	if err := comparison.WriteMarkdown(c.stdout); err != nil {
+		return err
+	}
+	if *output != "" {
+		if err := writeEvalReport(*output, report, comparison); err != nil {
+			return err
+		}
+	}
+	if regressions := report.regressions(baseline, *minScore, *maxRegression); len(regressions) > 0 {
+		return errors.New("quality regressed:\n  " + strings.Join(regressions, "\n  "))
+	}
+	return nil
+}
+
+func writeEvalReport(path string, report evalReport, comparison *workflowaieval.ComparisonReport) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			return err
+		}
+		return f.Close()
+	}
+	if err := comparison.WriteMarkdown(f); err != nil {
+		return err
+	}
+	// The detailed report of each candidate, with the diff of failing cases
+	for _, candidate := range comparison.Candidates {
+		io.WriteString(f, "\n")
+		r := *candidate.Report
+		r.DatasetName = fmt.Sprintf("%s with %s", report.DatasetName, candidate.Name)
+		if err := r.WriteMarkdown(f); err != nil {
+			return err
+		}
+	}
+	return f.Close()
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEval(t *testing.T) {
+	var requests []map[string]any
+	server := newTestServer(t, &requests)
+	dir := t.TempDir()
+	dataset := filepath.Join(dir, "cases.jsonl")
+	os.WriteFile(dataset, []byte(`{"id":"1","input":{"review":"Great!"},"expected_output":{"sentiment":"positive"}}
+{"id":"2","input":{"review":"Awful."},"expected_output":{"sentiment":"negative"}}
+`), 0o644)
+	report := filepath.Join(dir, "report.json")
+
+	c, stdout, stderr := testCLI(server, "")
+	code := c.main(context.Background(), []string{"eval", "-dataset", dataset, "-agent", "my-agent", "-models", "gpt-4o,claude-3-7-sonnet-latest", "-output", report})
+	if code != 0 {
+		t.Fatalf("exit code %d: %s", code, stderr)
+	}
+	if !strings.Contains(stdout.String(), "| my-agent/gpt-4o | 0.500 | 50.0% | 0 |") {
+		t.Errorf("unexpected output %q", stdout)
+	}
+	if len(requests) != 4 || requests[0]["input"] == nil {
+		t.Errorf("unexpected requests %v", requests)
+	}
+	var exported evalReport
+	data, _ := os.ReadFile(report)
+	if err := json.Unmarshal(data, &exported); err != nil {
+		t.Fatal(err)
+	}
+	if exported.Cases != 2 || len(exported.Candidates) != 2 || exported.Candidates[0].Score != 0.5 || exported.Candidates[0].CostPerItem != 0.5 {
+		t.Errorf("unexpected report %+v", exported)
+	}
+
+	// The same scores as the baseline pass
+	code = c.main(context.Background(), []string{"eval", "-dataset", dataset, "-agent", "my-agent", "-models", "gpt-4o", "-baseline", report})
+	if code != 0 {
+		t.Fatalf("exit code %d: %s", code, stderr)
+	}
+
+	exported.Candidates[0].Score = 1
+	data, _ = json.Marshal(exported)
+	os.WriteFile(report, data, 0o644)
+	stderr.Reset()
+	code = c.main(context.Background(), []string{"eval", "-dataset", dataset, "-agent", "my-agent", "-models", "gpt-4o", "-baseline", report})
+	if code != 1 || !strings.Contains(stderr.String(), "my-agent/gpt-4o: score 0.500 regressed from 1.000") {
+		t.Errorf("exit code %d: %s", code, stderr)
+	}
+
+	// A case passing in the baseline fails the evaluation, even at the same
+	// score
+	exported.Candidates[0].Score = 0.5
+	for i := range exported.Candidates[0].Results {
+		exported.Candidates[0].Results[i].Match.Pass = true
+	}
+	data, _ = json.Marshal(exported)
+	os.WriteFile(report, data, 0o644)
+	stderr.Reset()
+	code = c.main(context.Background(), []string{"eval", "-dataset", dataset, "-agent", "my-agent", "-models", "gpt-4o", "-baseline", report})
+	if code != 1 || !strings.Contains(stderr.String(), "passed in the baseline and now fails") {
+		t.Errorf("exit code %d: %s", code, stderr)
+	}
+
+	stderr.Reset()
+	code = c.main(context.Background(), []string{"eval", "-dataset", dataset, "-prompt", "Classify {{review}}", "-models", "gpt-4o", "-min-score", "0.8"})
+	if code != 1 || !strings.Contains(stderr.String(), "gpt-4o: score 0.500 is below the minimum 0.800") {
+		t.Errorf("exit code %d: %s", code, stderr)
+	}
+}
+
+func TestEval_Markdown(t *testing.T) {
+	server := newTestServer(t, nil)
+	dir := t.TempDir()
+	dataset := filepath.Join(dir, "cases.jsonl")
+	os.WriteFile(dataset, []byte(`{"id":"1","input":{},"expected_output":{"sentiment":"negative"}}`+"\n"), 0o644)
+	report := filepath.Join(dir, "report.md")
+
+	c, _, stderr := testCLI(server, "")
+	if code := c.main(context.Background(), []string{"eval", "-dataset", dataset, "-agent", "my-agent", "-models", "gpt-4o", "-output", report}); code != 0 {
+		t.Fatalf("exit code %d: %s", code, stderr)
+	}
+	data, _ := os.ReadFile(report)
+	if !strings.Contains(string(data), "# Evaluation of cases with my-agent/gpt-4o") || !strings.Contains(string(data), `-   "sentiment": "negative"`) {
+		t.Errorf("unexpected report:\n%s", data)
+	}
+}
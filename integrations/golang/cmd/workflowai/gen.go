@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/workflowai/workflowai/go/examples/workflowai/codegen"
+)
+
+func (c *cli) cmdGen(ctx context.Context, args []string) error {
+	fs := c.newFlagSet("gen", "-agent my-agent -schema 3 [-out types_gen.go]")
+	agent := fs.String("agent", "", "agent ID (required)")
+	schemaID := fs.Int("schema", 0, "schema ID of the agent (required)")
+	out := fs.String("out", "", "generated file, stdout when empty")
+	pkg := fs.String("package", "", "package of the generated file, $GOPACKAGE or the name of the directory of -out by default")
+	name := fs.String("name", "", `prefix of the generated identifiers, e.g. "MyAgent" for "my-agent" by default`)
+	inputSchema := fs.String("input-schema", "", "file of the input JSON schema, fetched from the latest version of the schema by default")
+	outputSchema := fs.String("output-schema", "", "file of the output JSON schema, fetched from the latest version of the schema by default")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *agent == "" || *schemaID <= 0 {
+		return usage(fs, "-agent and -schema are required")
+	}
+	if *pkg == "" {
+		// Set by go generate
+		*pkg = os.Getenv("GOPACKAGE")
+	}
+	if *pkg == "" && *out != "" {
+		abs, err := filepath.Abs(*out)
+		if err != nil {
+			return err
+		}
+		*pkg = packageName(filepath.Base(filepath.Dir(abs)))
+	}
+	if *pkg == "" {
+		return usage(fs, "-package is required when writing to stdout")
+	}
+
+	cfg := codegen.Config{Package: *pkg, AgentID: *agent, SchemaID: *schemaID, Name: *name}
+	if *inputSchema != "" || *outputSchema != "" {
+		var err error
+		if cfg.InputSchema, err = readSchemaFile(*inputSchema); err != nil {
+			return err
+		}
+		if cfg.OutputSchema, err = readSchemaFile(*outputSchema); err != nil {
+			return err
+		}
+	} else {
+		client := c.client()
+		majors, err := client.Versions.List(ctx, *agent, *schemaID)
+		if err != nil {
+			return fmt.Errorf("listing the versions of schema #%d: %w", *schemaID, err)
+		}
+		// All the versions of a schema share its input and output schemas
+		var versionID string
+		for _, major := range majors {
+			if major.SchemaID == *schemaID && len(major.Minors) > 0 {
+				versionID = major.Minors[len(major.Minors)-1].ID
+			}
+		}
+		if versionID == "" {
+			return fmt.Errorf("schema #%d of %s has no saved version", *schemaID, *agent)
+		}
+		version, err := client.Versions.Get(ctx, *agent, versionID)
+		if err != nil {
+			return err
+		}
+		cfg.InputSchema, cfg.OutputSchema = version.InputSchema, version.OutputSchema
+	}
+
+	src, err := codegen.Generate(cfg)
+	if err != nil {
+		return err
+	}
+	if *out == "" {
+		_, err := c.stdout.Write(src)
+		return err
+	}
+	if existing, err := os.ReadFile(*out); err == nil && bytes.Equal(existing, src) {
+		// Leave the modification time of unchanged files untouched
+		return nil
+	}
+	return os.WriteFile(*out, src, 0o644)
+}
+
+func readSchemaFile(path string) (json.RawMessage, error) {
+	if path == "" {
+		return json.RawMessage(`{"type":"object","properties":{}}`), nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if !json.Valid(data) {
+		return nil, fmt.Errorf("%s: invalid JSON", path)
+	}
+	return data, nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGen(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/v1/_/agents/my-agent/versions":
+			if r.URL.Query().Get("schema_id") != "3" {
+				t.Errorf("unexpected query %s", r.URL.RawQuery)
+			}
+			fmt.Fprint(w, `{"items":[{"major":1,"schema_id":3,"minors":[{"id":"v1-0","minor":0},{"id":"v1-1","minor":1}]}]}`)
+		case "/v1/_/agents/my-agent/versions/v1-1":
+			fmt.Fprint(w, `{"id":"v1-1","schema_id":3,
+				"input_schema":{"type":"object","properties":{"text":{"type":"string"}},"required":["text"]},
+				"output_schema":{"type":"object","properties":{"summary":{"type":"string"}},"required":["summary"]}}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	out := filepath.Join(t.TempDir(), "summaries", "types_gen.go")
+	os.Mkdir(filepath.Dir(out), 0o755)
+	c, _, stderr := testCLI(server, "")
+	if code := c.main(context.Background(), []string{"gen", "-agent", "my-agent", "-schema", "3", "-out", out}); code != 0 {
+		t.Fatalf("exit code %d: %s", code, stderr)
+	}
+	src, _ := os.ReadFile(out)
+	for _, want := range []string{"package summaries\n", "Text string `json:\"text\"`", "Summary string `json:\"summary\"`"} {
+		if !strings.Contains(string(src), want) {
+			t.Errorf("generated code does not contain %q:\n%s", want, src)
+		}
+	}
+}
+
+func TestGen_SchemaFiles(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "input.json")
+	os.WriteFile(input, []byte(`{"type":"object","properties":{"question":{"type":"string"}}}`), 0o644)
+
+	c, stdout, stderr := testCLI(newTestServer(t, nil), "")
+	code := c.main(context.Background(), []string{"gen", "-agent", "qa", "-schema", "1", "-package", "qa", "-name", "QA", "-input-schema", input})
+	if code != 0 {
+		t.Fatalf("exit code %d: %s", code, stderr)
+	}
+	if !strings.Contains(stdout.String(), "type QAInput struct {\n\tQuestion string `json:\"question,omitempty\"`\n}") {
+		t.Errorf("unexpected output:\n%s", stdout)
+	}
+}
@@ -0,0 +1,359 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"go/format"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"golang.org/x/mod/modfile"
+)
+
+// scaffold is the data of the agent templates.
+type scaffold struct {
+	AgentID string
+	Package string
+	// ImportPath is the import path of the agent package
+	ImportPath string
+	Model      string
+}
+
+// scaffoldFiles are the files created by "init agent", relative to the
+// package directory. {{.Package}} is expanded in the paths.
+var scaffoldFiles = []struct {
+	path     string
+	template *template.Template
+}{
+	{"agent.go", template.Must(template.New("agent.go").Parse(agentTemplate))},
+	{"agent_test.go", template.Must(template.New("agent_test.go").Parse(agentTestTemplate))},
+	{"cmd/{{.Package}}/main.go", template.Must(template.New("main.go").Parse(agentMainTemplate))},
+}
+
+func (s scaffold) path(dir string, file string) string {
+	return filepath.Join(dir, filepath.FromSlash(strings.ReplaceAll(file, "{{.Package}}", s.Package)))
+}
+
+func (c *cli) cmdInit(ctx context.Context, args []string) error {
+	fs := c.newFlagSet("init", "agent [-dir path] [-model gpt-4o] <name>")
+	dir := fs.String("dir", "", "directory of the package, ./<name> by default")
+	model := fs.String("model", "gpt-4o-mini-latest", "default model of the agent")
+	modulePath := fs.String("module", "", "module path of the current directory, read from the enclosing go.mod file by default")
+	force := fs.Bool("force", false, "overwrite existing files")
+	if len(args) == 0 || args[0] != "agent" {
+		return usage(fs, "expected a thing to create: agent")
+	}
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return usage(fs, "expected the name of the agent")
+	}
+	name := fs.Arg(0)
+	s := scaffold{AgentID: name, Package: packageName(name), Model: *model}
+	if s.Package == "" {
+		return usage(fs, "invalid agent name %q", name)
+	}
+	if *dir == "" {
+		*dir = name
+	}
+
+	importPath, err := packageImportPath(*dir, *modulePath)
+	if err != nil {
+		return err
+	}
+	s.ImportPath = importPath
+
+	for _, file := range scaffoldFiles {
+		path := s.path(*dir, file.path)
+		if _, err := os.Stat(path); err == nil && !*force {
+			return fmt.Errorf("%s already exists, use -force to overwrite it", path)
+		}
+	}
+	for _, file := range scaffoldFiles {
+		var buf bytes.Buffer
+		if err := file.template.Execute(&buf, s); err != nil {
+			return err
+		}
+		src, err := format.Source(buf.Bytes())
+		if err != nil {
+			return fmt.Errorf("formatting %s: %w", file.path, err)
+		}
+		path := s.path(*dir, file.path)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, src, 0o644); err != nil {
+			return err
+		}
+		fmt.Fprintf(c.stdout, "created %s\n", path)
+	}
+	fmt.Fprintf(c.stdout, "\nTest the agent with go test %s and run it with:\n\n  echo '{\"text\": \"...\", \"question\": \"...\"}' | go run %s\n",
+		localPackage(*dir), localPackage(filepath.Dir(s.path(*dir, "cmd/{{.Package}}/main.go"))))
+	return nil
+}
+
+// localPackage returns the go command pattern of the package in dir.
+func localPackage(dir string) string {
+	dir = filepath.ToSlash(filepath.Clean(dir))
+	if filepath.IsAbs(dir) || strings.HasPrefix(dir, "../") {
+		return dir
+	}
+	return "./" + dir
+}
+
+// packageName derives a Go package name from an agent ID, e.g.
+// "sentiment-analyzer" gives "sentimentanalyzer".
+func packageName(agentID string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(agentID) {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || (unicode.IsDigit(r) && b.Len() > 0)) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// packageImportPath returns the import path of dir, relative to modulePath
+// when set, or to the go.mod file of an enclosing directory.
+func packageImportPath(dir string, modulePath string) (string, error) {
+	if modulePath != "" {
+		return path.Join(modulePath, filepath.ToSlash(filepath.Clean(dir))), nil
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for root := filepath.Dir(abs); ; root = filepath.Dir(root) {
+		data, err := os.ReadFile(filepath.Join(root, "go.mod"))
+		if err == nil {
+			modulePath := modfile.ModulePath(data)
+			if modulePath == "" {
+				return "", fmt.Errorf("%s: no module path", filepath.Join(root, "go.mod"))
+			}
+			rel, err := filepath.Rel(root, abs)
+			if err != nil {
+				return "", err
+			}
+			return path.Join(modulePath, filepath.ToSlash(rel)), nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return "", err
+		}
+		if filepath.Dir(root) == root {
+			return "", errors.New("no go.mod file found, run go mod init first or set -module")
+		}
+	}
+}
+
+const agentTemplate = `// Package {{.Package}} runs the {{.AgentID}} agent on WorkflowAI.
+//
+// Edit Input, Output, the instructions and the prompt to fit the task: the
+// input fields are rendered in the messages with {{"{{"}}field{{"}}"}} and the output is
+// returned as structured JSON matching outputSchema.
+package {{.Package}}
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+	"github.com/openai/openai-go/shared"
+
+	"github.com/workflowai/workflowai/go/examples/workflowai"
+)
+
+const (
+	// AgentID is the ID under which the runs are recorded
+	AgentID      = "{{.AgentID}}"
+	DefaultModel = "{{.Model}}"
+)
+
+const instructions = "You are a helpful assistant. Answer the question about the text."
+
+const prompt = "Text: {{"{{"}}text{{"}}"}}\nQuestion: {{"{{"}}question{{"}}"}}"
+
+// Input is the input of the agent.
+type Input struct {
+	Text     string ` + "`json:\"text\"`" + `
+	Question string ` + "`json:\"question\"`" + `
+}
+
+// Output is the structured output of the agent.
+type Output struct {
+	Answer     string  ` + "`json:\"answer\"`" + `
+	Confidence float64 ` + "`json:\"confidence\"`" + `
+}
+
+// outputSchema is the JSON schema of Output, kept in sync by hand.
+const outputSchema = ` + "`" + `{
+	"type": "object",
+	"properties": {
+		"answer": {"type": "string"},
+		"confidence": {"type": "number", "minimum": 0, "maximum": 1}
+	},
+	"required": ["answer", "confidence"],
+	"additionalProperties": false
+}` + "`" + `
+
+// Agent runs the agent, e.g. with ` + "`&client.Chat.Completions`" + ` of a
+// workflowai.Client.
+type Agent struct {
+	completions workflowai.ChatCompleter
+	// Model is the model, or the deployment, e.g. "#1/production"
+	Model string
+}
+
+func New(completions workflowai.ChatCompleter) *Agent {
+	return &Agent{completions: completions, Model: DefaultModel}
+}
+
+// Run runs the agent and returns its validated output.
+func (a *Agent) Run(ctx context.Context, input Input, opts ...option.RequestOption) (*Output, error) {
+	var schema map[string]any
+	if err := json.Unmarshal([]byte(outputSchema), &schema); err != nil {
+		return nil, err
+	}
+	params := openai.ChatCompletionNewParams{
+		Model: AgentID + "/" + a.Model,
+		Messages: []openai.ChatCompletionMessageParamUnion{
+			openai.SystemMessage(instructions),
+			openai.UserMessage(prompt),
+		},
+		ResponseFormat: openai.ChatCompletionNewParamsResponseFormatUnion{
+			OfJSONSchema: &shared.ResponseFormatJSONSchemaParam{
+				JSONSchema: shared.ResponseFormatJSONSchemaJSONSchemaParam{Name: "output", Schema: schema},
+			},
+		},
+	}
+	params.SetExtraFields(map[string]any{"input": input})
+
+	completion, err := a.completions.New(ctx, params, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if len(completion.Choices) == 0 {
+		return nil, errors.New("{{.Package}}: the completion has no choices")
+	}
+	content := completion.Choices[0].Message.Content
+	if err := workflowai.ValidateJSONSchema(json.RawMessage(outputSchema), json.RawMessage(content)); err != nil {
+		return nil, fmt.Errorf("{{.Package}}: invalid output: %w", err)
+	}
+	var output Output
+	if err := json.Unmarshal([]byte(content), &output); err != nil {
+		return nil, fmt.Errorf("{{.Package}}: decoding the output: %w", err)
+	}
+	return &output, nil
+}
+`
+
+const agentTestTemplate = `package {{.Package}}
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+func TestAgent(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    Output
+		wantErr bool
+	}{
+		{name: "valid output", content: ` + "`" + `{"answer":"Paris","confidence":0.9}` + "`" + `, want: Output{Answer: "Paris", Confidence: 0.9}},
+		{name: "missing field", content: ` + "`" + `{"answer":"Paris"}` + "`" + `, wantErr: true},
+		{name: "not JSON", content: "Paris", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var request map[string]any
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				json.Unmarshal(body, &request)
+				content, _ := json.Marshal(tt.content)
+				w.Header().Set("Content-Type", "application/json")
+				fmt.Fprintf(w, ` + "`" + `{"id":"%s/run-1","object":"chat.completion","choices":[{"index":0,"finish_reason":"stop","message":{"role":"assistant","content":%s}}]}` + "`" + `, AgentID, content)
+			}))
+			defer server.Close()
+			client := openai.NewClient(option.WithBaseURL(server.URL), option.WithAPIKey("test"))
+
+			output, err := New(&client.Chat.Completions).Run(context.Background(), Input{Text: "France's capital is Paris.", Question: "What is the capital?"})
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", output)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if *output != tt.want {
+				t.Errorf("got %+v, want %+v", *output, tt.want)
+			}
+			if request["model"] != AgentID+"/"+DefaultModel || request["input"].(map[string]any)["question"] != "What is the capital?" {
+				t.Errorf("unexpected request %v", request)
+			}
+		})
+	}
+}
+`
+
+const agentMainTemplate = `// Command {{.Package}} runs the {{.AgentID}} agent with the JSON input read
+// from stdin and prints its output.
+//
+// The client is configured from WORKFLOWAI_API_URL and WORKFLOWAI_API_KEY.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/workflowai/workflowai/go/examples/workflowai"
+
+	"{{.ImportPath}}"
+)
+
+func main() {
+	model := flag.String("model", {{.Package}}.DefaultModel, "model, or deployment of the agent")
+	flag.Parse()
+
+	var input {{.Package}}.Input
+	if err := json.NewDecoder(os.Stdin).Decode(&input); err != nil {
+		fmt.Fprintln(os.Stderr, "reading the input:", err)
+		os.Exit(2)
+	}
+
+	client := workflowai.NewClient()
+	agent := {{.Package}}.New(&client.Chat.Completions)
+	agent.Model = *model
+	output, err := agent.Run(context.Background(), input)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, workflowai.ScrubError(err))
+		os.Exit(1)
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.Encode(output)
+}
+`
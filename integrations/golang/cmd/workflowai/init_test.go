@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestInitAgent(t *testing.T) {
+	root := t.TempDir()
+	os.WriteFile(filepath.Join(root, "go.mod"), []byte("module example.com/agents\n\ngo 1.22\n"), 0o644)
+	dir := filepath.Join(root, "agents", "sentiment-analyzer")
+
+	c, stdout, stderr := testCLI(newTestServer(t, nil), "")
+	if code := c.main(context.Background(), []string{"init", "agent", "-dir", dir, "-model", "gpt-4o", "sentiment-analyzer"}); code != 0 {
+		t.Fatalf("exit code %d: %s", code, stderr)
+	}
+	if !strings.Contains(stdout.String(), "created "+filepath.Join(dir, "agent.go")) {
+		t.Errorf("unexpected output %q", stdout)
+	}
+
+	fset := token.NewFileSet()
+	agent, err := parser.ParseFile(fset, filepath.Join(dir, "agent.go"), nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if agent.Name.Name != "sentimentanalyzer" {
+		t.Errorf("unexpected package %s", agent.Name.Name)
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, "agent.go"))
+	if !strings.Contains(string(data), `AgentID      = "sentiment-analyzer"`) || !strings.Contains(string(data), `DefaultModel = "gpt-4o"`) {
+		t.Errorf("unexpected agent.go:\n%s", data)
+	}
+	if _, err := parser.ParseFile(fset, filepath.Join(dir, "agent_test.go"), nil, 0); err != nil {
+		t.Fatal(err)
+	}
+	main, err := parser.ParseFile(fset, filepath.Join(dir, "cmd", "sentimentanalyzer", "main.go"), nil, parser.ImportsOnly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var imported bool
+	for _, spec := range main.Imports {
+		imported = imported || spec.Path.Value == `"example.com/agents/agents/sentiment-analyzer"`
+	}
+	if !imported {
+		t.Error("the command doesn't import the agent package")
+	}
+
+	// Existing files are only overwritten with -force
+	if code := c.main(context.Background(), []string{"init", "agent", "-dir", dir, "sentiment-analyzer"}); code != 1 {
+		t.Errorf("unexpected exit code %d", code)
+	}
+	if code := c.main(context.Background(), []string{"init", "agent", "-dir", dir, "-force", "sentiment-analyzer"}); code != 0 {
+		t.Errorf("unexpected exit code %d: %s", code, stderr)
+	}
+}
+
+func TestPackageName(t *testing.T) {
+	for name, want := range map[string]string{
+		"sentiment-analyzer": "sentimentanalyzer",
+		"Summarize_2":        "summarize2",
+		"3d-model":           "dmodel",
+		"---":                "",
+	} {
+		if got := packageName(name); got != want {
+			t.Errorf("packageName(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
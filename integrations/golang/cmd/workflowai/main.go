@@ -0,0 +1,125 @@
+// Command workflowai runs agents and chat completions from the terminal.
+//
+// Usage:
+//
+//	workflowai <command> [flags]
+//
+// Run "workflowai <command> -h" for the flags of a command.
+//
+// The client is configured from WORKFLOWAI_API_URL and WORKFLOWAI_API_KEY.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+
+	"github.com/openai/openai-go/option"
+
+	"github.com/workflowai/workflowai/go/examples/workflowai"
+)
+
+type command struct {
+	name    string
+	summary string
+	run     func(c *cli, ctx context.Context, args []string) error
+}
+
+var commands = []command{
+	{"run", "run an agent or a chat completion", (*cli).cmdRun},
+	{"chat", "chat interactively with a model", (*cli).cmdChat},
+	{"models", "list the models matching capabilities, price and context window", (*cli).cmdModels},
+	{"runs", "watch the new runs of an agent", (*cli).cmdRuns},
+	{"deploy", "deploy a version of an agent to an environment", (*cli).cmdDeploy},
+	{"eval", "compare models over a dataset and fail on quality regressions", (*cli).cmdEval},
+	{"init", "create an agent package with its test and command", (*cli).cmdInit},
+	{"gen", "generate Go types and a client from the schemas of an agent", (*cli).cmdGen},
+	{"tools", "generate agent tools calling the operations of an OpenAPI spec", (*cli).cmdTools},
+}
+
+// cli holds the IO of the commands, replaced in tests.
+type cli struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+	// opts are applied after the default client options
+	opts []option.RequestOption
+}
+
+func (c *cli) client(opts ...option.RequestOption) workflowai.Client {
+	return workflowai.NewClient(append(c.opts, opts...)...)
+}
+
+// usageError is returned for invalid arguments, after the usage was printed.
+type usageError struct {
+	msg string
+}
+
+func (e *usageError) Error() string {
+	return e.msg
+}
+
+// newFlagSet returns the flag set of a command, whose errors are returned
+// instead of exiting.
+func (c *cli) newFlagSet(name string, usage string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(c.stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(c.stderr, "Usage: workflowai %s %s\n\n", name, usage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// usage prints the usage of fs and returns a usage error.
+func usage(fs *flag.FlagSet, format string, args ...any) error {
+	msg := fmt.Sprintf(format, args...)
+	fmt.Fprintln(fs.Output(), msg)
+	fs.Usage()
+	return &usageError{msg: msg}
+}
+
+func (c *cli) main(ctx context.Context, args []string) int {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "--help" || args[0] == "help" {
+		c.printUsage()
+		return 2
+	}
+	for _, cmd := range commands {
+		if cmd.name != args[0] {
+			continue
+		}
+		err := cmd.run(c, ctx, args[1:])
+		var usageErr *usageError
+		switch {
+		case err == nil:
+			return 0
+		case errors.Is(err, flag.ErrHelp), errors.As(err, &usageErr):
+			return 2
+		default:
+			fmt.Fprintln(c.stderr, workflowai.ScrubError(err))
+			return 1
+		}
+	}
+	fmt.Fprintf(c.stderr, "unknown command %q\n\n", args[0])
+	c.printUsage()
+	return 2
+}
+
+func (c *cli) printUsage() {
+	fmt.Fprintln(c.stderr, "Usage: workflowai <command> [flags]\n\nCommands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(c.stderr, "  %-8s %s\n", cmd.name, cmd.summary)
+	}
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	c := &cli{stdin: os.Stdin, stdout: os.Stdout, stderr: os.Stderr}
+	code := c.main(ctx, os.Args[1:])
+	stop()
+	os.Exit(code)
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/workflowai/workflowai/go/examples/workflowai"
+)
+
+// capabilities are the values of "models -supports".
+var capabilities = map[string]func(workflowai.ModelInfo) bool{
+	"tool-calling":        func(m workflowai.ModelInfo) bool { return m.Supports.Tools },
+	"parallel-tool-calls": func(m workflowai.ModelInfo) bool { return m.Supports.ParallelToolCalls },
+	"image":               func(m workflowai.ModelInfo) bool { return m.Supports.Input.Image },
+	"audio":               func(m workflowai.ModelInfo) bool { return m.Supports.Input.Audio },
+	"pdf":                 func(m workflowai.ModelInfo) bool { return m.Supports.Input.PDF },
+	"image-output":        func(m workflowai.ModelInfo) bool { return m.Supports.Output.Image },
+	"audio-output":        func(m workflowai.ModelInfo) bool { return m.Supports.Output.Audio },
+	"temperature":         func(m workflowai.ModelInfo) bool { return m.Supports.Temperature },
+	"top-p":               func(m workflowai.ModelInfo) bool { return m.Supports.TopP },
+	"reasoning":           func(m workflowai.ModelInfo) bool { return m.Reasoning != nil },
+}
+
+func capabilityNames() string {
+	names := make([]string, 0, len(capabilities))
+	for name := range capabilities {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// modelFilter selects the models of the catalog.
+type modelFilter struct {
+	supports    []string
+	maxPriceIn  float64
+	maxPriceOut float64
+	minContext  int64
+}
+
+func (f modelFilter) match(m workflowai.ModelInfo) bool {
+	for _, name := range f.supports {
+		if !capabilities[name](m) {
+			return false
+		}
+	}
+	if f.maxPriceIn > 0 && perMillion(m.Pricing.InputTokenUSD) > f.maxPriceIn {
+		return false
+	}
+	if f.maxPriceOut > 0 && perMillion(m.Pricing.OutputTokenUSD) > f.maxPriceOut {
+		return false
+	}
+	return m.ContextWindow.MaxTokens >= f.minContext
+}
+
+func perMillion(usdPerToken float64) float64 {
+	return usdPerToken * 1e6
+}
+
+func (c *cli) cmdModels(ctx context.Context, args []string) error {
+	fs := c.newFlagSet("models", "[-supports tool-calling,image] [-max-price-in 2.0] [-min-context 128k]")
+	supports := fs.String("supports", "", "comma separated capabilities the models must support: "+capabilityNames())
+	maxPriceIn := fs.Float64("max-price-in", 0, "maximum price of the input tokens, in USD per million tokens")
+	maxPriceOut := fs.Float64("max-price-out", 0, "maximum price of the output tokens, in USD per million tokens")
+	minContext := fs.String("min-context", "", "minimum context window, e.g. 128k or 1m")
+	sortBy := fs.String("sort", "", "sort by price-in, price-out, context or release, the catalog order when empty")
+	jsonOutput := fs.Bool("json", false, "print the models as JSON")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	filter := modelFilter{maxPriceIn: *maxPriceIn, maxPriceOut: *maxPriceOut}
+	if *supports != "" {
+		for _, name := range strings.Split(*supports, ",") {
+			name = strings.TrimSpace(name)
+			if capabilities[name] == nil {
+				return usage(fs, "unknown capability %q, expected one of %s", name, capabilityNames())
+			}
+			filter.supports = append(filter.supports, name)
+		}
+	}
+	if *minContext != "" {
+		n, err := parseTokenCount(*minContext)
+		if err != nil {
+			return usage(fs, "invalid -min-context: %v", err)
+		}
+		filter.minContext = n
+	}
+	less, err := modelOrder(*sortBy)
+	if err != nil {
+		return usage(fs, "%v", err)
+	}
+
+	client := c.client()
+	models, err := workflowai.ListModels(ctx, client.Client)
+	if err != nil {
+		return err
+	}
+	var selected []workflowai.ModelInfo
+	for _, m := range models {
+		if filter.match(m) {
+			selected = append(selected, m)
+		}
+	}
+	if less != nil {
+		sort.SliceStable(selected, func(i, j int) bool { return less(selected[i], selected[j]) })
+	}
+
+	if *jsonOutput {
+		enc := json.NewEncoder(c.stdout)
+		enc.SetIndent("", "  ")
+		if selected == nil {
+			selected = []workflowai.ModelInfo{}
+		}
+		return enc.Encode(selected)
+	}
+	w := tabwriter.NewWriter(c.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "MODEL\tINPUT $/M\tOUTPUT $/M\tCONTEXT\tSUPPORTS")
+	for _, m := range selected {
+		fmt.Fprintf(w, "%s\t%.2f\t%.2f\t%s\t%s\n", m.ID, perMillion(m.Pricing.InputTokenUSD), perMillion(m.Pricing.OutputTokenUSD),
+			formatTokenCount(m.ContextWindow.MaxTokens), strings.Join(modelCapabilities(m), ","))
+	}
+	return w.Flush()
+}
+
+func modelOrder(name string) (func(a, b workflowai.ModelInfo) bool, error) {
+	switch name {
+	case "":
+		return nil, nil
+	case "price-in":
+		return func(a, b workflowai.ModelInfo) bool { return a.Pricing.InputTokenUSD < b.Pricing.InputTokenUSD }, nil
+	case "price-out":
+		return func(a, b workflowai.ModelInfo) bool { return a.Pricing.OutputTokenUSD < b.Pricing.OutputTokenUSD }, nil
+	case "context":
+		return func(a, b workflowai.ModelInfo) bool { return a.ContextWindow.MaxTokens > b.ContextWindow.MaxTokens }, nil
+	case "release":
+		return func(a, b workflowai.ModelInfo) bool { return a.Released().After(b.Released()) }, nil
+	}
+	return nil, fmt.Errorf("unknown sort %q", name)
+}
+
+func modelCapabilities(m workflowai.ModelInfo) []string {
+	var names []string
+	for _, name := range []string{"tool-calling", "image", "audio", "pdf", "reasoning"} {
+		if capabilities[name](m) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// parseTokenCount parses a number of tokens with an optional k or m suffix.
+func parseTokenCount(s string) (int64, error) {
+	multiplier := 1.0
+	switch strings.ToLower(s[len(s)-1:]) {
+	case "k":
+		multiplier, s = 1e3, s[:len(s)-1]
+	case "m":
+		multiplier, s = 1e6, s[:len(s)-1]
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("expected a number of tokens, e.g. 128k, got %q", s)
+	}
+	return int64(n * multiplier), nil
+}
+
+func formatTokenCount(n int64) string {
+	switch {
+	case n >= 1e6 && n%1e5 == 0:
+		return strconv.FormatFloat(float64(n)/1e6, 'f', -1, 64) + "m"
+	case n >= 1e3:
+		return strconv.FormatInt(n/1e3, 10) + "k"
+	}
+	return strconv.FormatInt(n, 10)
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+const testModels = `{"object":"list","data":[
+	{"id":"gpt-4o","object":"model","created":1,"owned_by":"WorkflowAI","release_date":"2024-05-13",
+		"supports":{"input":{"text":true,"image":true},"tools":true},
+		"pricing":{"input_token_usd":2.5e-6,"output_token_usd":1e-5},
+		"context_window":{"max_tokens":128000,"max_output_tokens":16384}},
+	{"id":"gpt-4o-mini","object":"model","created":1,"owned_by":"WorkflowAI","release_date":"2024-07-18",
+		"supports":{"input":{"text":true,"image":true},"tools":true},
+		"pricing":{"input_token_usd":1.5e-7,"output_token_usd":6e-7},
+		"context_window":{"max_tokens":128000,"max_output_tokens":16384}},
+	{"id":"gemini-1.5-pro","object":"model","created":1,"owned_by":"WorkflowAI","release_date":"2024-05-14",
+		"supports":{"input":{"text":true,"pdf":true},"tools":false},
+		"pricing":{"input_token_usd":1.25e-6,"output_token_usd":5e-6},
+		"context_window":{"max_tokens":2000000,"max_output_tokens":8192}}
+]}`
+
+func TestModels(t *testing.T) {
+	server := newTestServer(t, nil)
+	c, stdout, stderr := testCLI(server, "")
+	code := c.main(context.Background(), []string{"models", "-supports", "tool-calling", "-max-price-in", "2.0", "-min-context", "128k"})
+	if code != 0 {
+		t.Fatalf("exit code %d: %s", code, stderr)
+	}
+	expected := "MODEL        INPUT $/M  OUTPUT $/M  CONTEXT  SUPPORTS\n" +
+		"gpt-4o-mini  0.15       0.60        128k     tool-calling,image\n"
+	if stdout.String() != expected {
+		t.Errorf("unexpected table:\n%s", stdout)
+	}
+
+	c, stdout, _ = testCLI(server, "")
+	if code := c.main(context.Background(), []string{"models", "-min-context", "1m", "-sort", "release", "-json"}); code != 0 {
+		t.Fatalf("exit code %d", code)
+	}
+	var models []struct{ ID string }
+	if err := json.Unmarshal(stdout.Bytes(), &models); err != nil {
+		t.Fatal(err)
+	}
+	if len(models) != 1 || models[0].ID != "gemini-1.5-pro" {
+		t.Errorf("unexpected models %v", models)
+	}
+
+	c, _, stderr = testCLI(server, "")
+	if code := c.main(context.Background(), []string{"models", "-supports", "telepathy"}); code != 2 || !strings.Contains(stderr.String(), "unknown capability") {
+		t.Errorf("unexpected exit code %d: %s", code, stderr)
+	}
+}
+
+func TestTokenCount(t *testing.T) {
+	for s, expected := range map[string]int64{"128k": 128000, "1m": 1000000, "1.5M": 1500000, "4096": 4096} {
+		if n, err := parseTokenCount(s); err != nil || n != expected {
+			t.Errorf("parseTokenCount(%q) = %d, %v", s, n, err)
+		}
+	}
+	if _, err := parseTokenCount("lots"); err == nil {
+		t.Error("expected an error")
+	}
+	for n, expected := range map[int64]string{128000: "128k", 2000000: "2m", 1048576: "1048k", 512: "512"} {
+		if s := formatTokenCount(n); s != expected {
+			t.Errorf("formatTokenCount(%d) = %q", n, s)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+	"github.com/openai/openai-go/shared"
+
+	"github.com/workflowai/workflowai/go/examples/workflowai"
+)
+
+// runResult is printed by "run -json".
+type runResult struct {
+	Output  any     `json:"output"`
+	AgentID string  `json:"agent_id,omitempty"`
+	RunID   string  `json:"run_id,omitempty"`
+	CostUSD float64 `json:"cost_usd"`
+	URL     string  `json:"url,omitempty"`
+}
+
+func (c *cli) cmdRun(ctx context.Context, args []string) error {
+	fs := c.newFlagSet("run", "[-agent my-agent] [-input input.json] [-prompt text] [-stream]")
+	agent := fs.String("agent", "", "agent ID, the run is recorded under this agent")
+	model := fs.String("model", "gpt-4o-mini-latest", `model, or deployment of the agent, e.g. "#1/production"`)
+	inputPath := fs.String("input", "", `JSON file with the input of the agent, "-" for stdin`)
+	prompt := fs.String("prompt", "", "templated user message, rendered with the input")
+	instructions := fs.String("instructions", "", "templated system message, rendered with the input")
+	schemaPath := fs.String("schema", "", "JSON schema file of a structured output")
+	stream := fs.Bool("stream", false, "stream the output as it is generated")
+	jsonOutput := fs.Bool("json", false, "print the output, cost and run URL as a JSON object")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *inputPath == "" && *prompt == "" && *instructions == "" {
+		return usage(fs, "-input, -prompt or -instructions is required")
+	}
+
+	params := openai.ChatCompletionNewParams{Model: *model}
+	if *agent != "" {
+		params.Model = *agent + "/" + *model
+	}
+	if *instructions != "" {
+		params.Messages = append(params.Messages, openai.SystemMessage(*instructions))
+	}
+	if *prompt != "" {
+		params.Messages = append(params.Messages, openai.UserMessage(*prompt))
+	}
+	if *inputPath != "" {
+		input, err := c.readJSON(*inputPath)
+		if err != nil {
+			return fmt.Errorf("reading the input: %w", err)
+		}
+		params.SetExtraFields(map[string]any{"input": input})
+	}
+	if *schemaPath != "" {
+		schema, err := c.readJSON(*schemaPath)
+		if err != nil {
+			return fmt.Errorf("reading the schema: %w", err)
+		}
+		params.ResponseFormat = openai.ChatCompletionNewParamsResponseFormatUnion{
+			OfJSONSchema: &shared.ResponseFormatJSONSchemaParam{
+				JSONSchema: shared.ResponseFormatJSONSchemaJSONSchemaParam{Name: "output", Schema: schema},
+			},
+		}
+	}
+
+	// The cost and run ID of streamed and non streamed completions are read
+	// by the hooks
+	var hooks workflowai.Hooks
+	var result runResult
+	hooks.OnComplete(func(_ context.Context, e workflowai.CompleteEvent) {
+		result.AgentID, result.RunID, result.CostUSD = e.AgentID, e.RunID, e.CostUSD
+	})
+	client := c.client(option.WithMiddleware(hooks.Middleware()))
+
+	var content string
+	if *stream {
+		s := client.Chat.Completions.NewStreaming(ctx, params)
+		defer s.Close()
+		for s.Next() {
+			chunk := s.Current()
+			if len(chunk.Choices) == 0 {
+				continue
+			}
+			delta := chunk.Choices[0].Delta.Content
+			content += delta
+			if !*jsonOutput {
+				fmt.Fprint(c.stdout, delta)
+			}
+		}
+		if err := s.Err(); err != nil {
+			return err
+		}
+		if !*jsonOutput {
+			fmt.Fprintln(c.stdout)
+		}
+	} else {
+		completion, err := client.Chat.Completions.New(ctx, params)
+		if err != nil {
+			return err
+		}
+		if len(completion.Choices) > 0 {
+			content = completion.Choices[0].Message.Content
+		}
+		if !*jsonOutput {
+			fmt.Fprintln(c.stdout, formatOutput(content))
+		}
+	}
+
+	if result.AgentID != "" && result.RunID != "" {
+		run, err := client.Runs.Get(ctx, result.AgentID, result.RunID)
+		if err != nil {
+			fmt.Fprintf(c.stderr, "warning: fetching the run: %v\n", workflowai.ScrubError(err))
+		} else {
+			result.URL = run.URL
+		}
+	}
+
+	if *jsonOutput {
+		result.Output = content
+		var structured any
+		if json.Unmarshal([]byte(content), &structured) == nil {
+			result.Output = structured
+		}
+		enc := json.NewEncoder(c.stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(result)
+	}
+	fmt.Fprintf(c.stderr, "cost: $%.6f", result.CostUSD)
+	if result.URL != "" {
+		fmt.Fprintf(c.stderr, "  run: %s", result.URL)
+	}
+	fmt.Fprintln(c.stderr)
+	return nil
+}
+
+// readJSON decodes a JSON file, or stdin for "-".
+func (c *cli) readJSON(path string) (map[string]any, error) {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(c.stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var v map[string]any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return v, nil
+}
+
+// formatOutput indents structured outputs.
+func formatOutput(content string) string {
+	var buf bytes.Buffer
+	if json.Indent(&buf, []byte(content), "", "  ") == nil {
+		return buf.String()
+	}
+	return content
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/openai/openai-go/option"
+)
+
+// newTestServer serves chat completions returning a JSON output, the run they
+// create and the models catalog. requests receives the decoded completion
+// requests.
+func newTestServer(t *testing.T, requests *[]map[string]any) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/chat/completions":
+			body, _ := io.ReadAll(r.Body)
+			var request map[string]any
+			json.Unmarshal(body, &request)
+			if requests != nil {
+				*requests = append(*requests, request)
+			}
+			if request["stream"] == true {
+				w.Header().Set("Content-Type", "text/event-stream")
+				fmt.Fprint(w, `data: {"id":"my-agent/run-1","object":"chat.completion.chunk","model":"m","choices":[{"index":0,"delta":{"content":"Hello"}}]}`+"\n\n")
+				fmt.Fprint(w, `data: {"id":"my-agent/run-1","object":"chat.completion.chunk","model":"m","choices":[{"index":0,"delta":{"content":" world"},"finish_reason":"stop","cost_usd":0.25}]}`+"\n\n")
+				fmt.Fprint(w, "data: [DONE]\n\n")
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `{"id":"my-agent/run-1","object":"chat.completion","model":"m","choices":[{"index":0,"finish_reason":"stop","cost_usd":0.5,"message":{"role":"assistant","content":"{\"sentiment\":\"positive\"}"}}]}`)
+		case "/v1/models":
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, testModels)
+		case "/v1/_/agents/my-agent/runs/run-1":
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `{"id":"run-1","task_id":"my-agent","status":"success","url":"https://workflowai.com/runs/run-1"}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func testCLI(server *httptest.Server, stdin string) (*cli, *bytes.Buffer, *bytes.Buffer) {
+	var stdout, stderr bytes.Buffer
+	return &cli{
+		stdin:  strings.NewReader(stdin),
+		stdout: &stdout,
+		stderr: &stderr,
+		opts:   []option.RequestOption{option.WithBaseURL(server.URL + "/v1"), option.WithAPIKey("test"), option.WithMaxRetries(0)},
+	}, &stdout, &stderr
+}
+
+func TestRun(t *testing.T) {
+	var requests []map[string]any
+	server := newTestServer(t, &requests)
+	schema := filepath.Join(t.TempDir(), "schema.json")
+	os.WriteFile(schema, []byte(`{"type":"object","properties":{"sentiment":{"type":"string"}}}`), 0o644)
+
+	c, stdout, stderr := testCLI(server, `{"review":"Great!"}`)
+	code := c.main(context.Background(), []string{"run", "-agent", "my-agent", "-model", "gpt-4o", "-input", "-", "-prompt", "Classify {{review}}", "-schema", schema})
+	if code != 0 {
+		t.Fatalf("exit code %d: %s", code, stderr)
+	}
+	if stdout.String() != "{\n  \"sentiment\": \"positive\"\n}\n" {
+		t.Errorf("unexpected output %q", stdout)
+	}
+	if stderr.String() != "cost: $0.500000  run: https://workflowai.com/runs/run-1\n" {
+		t.Errorf("unexpected stderr %q", stderr)
+	}
+	request := requests[0]
+	if request["model"] != "my-agent/gpt-4o" || request["input"].(map[string]any)["review"] != "Great!" {
+		t.Errorf("unexpected request %v", request)
+	}
+	if format := request["response_format"].(map[string]any); format["type"] != "json_schema" {
+		t.Errorf("unexpected response format %v", format)
+	}
+}
+
+func TestRun_StreamJSON(t *testing.T) {
+	server := newTestServer(t, nil)
+	c, stdout, stderr := testCLI(server, "")
+	code := c.main(context.Background(), []string{"run", "-prompt", "Hi", "-stream", "-json"})
+	if code != 0 {
+		t.Fatalf("exit code %d: %s", code, stderr)
+	}
+	var result runResult
+	if err := json.Unmarshal(stdout.Bytes(), &result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Output != "Hello world" || result.RunID != "run-1" || result.CostUSD != 0.25 || result.URL == "" {
+		t.Errorf("unexpected result %+v", result)
+	}
+}
+
+func TestRun_Usage(t *testing.T) {
+	server := newTestServer(t, nil)
+	c, _, stderr := testCLI(server, "")
+	if code := c.main(context.Background(), []string{"run"}); code != 2 {
+		t.Errorf("unexpected exit code %d", code)
+	}
+	if !strings.Contains(stderr.String(), "-input, -prompt or -instructions is required") {
+		t.Errorf("unexpected stderr %q", stderr)
+	}
+	if code := c.main(context.Background(), []string{"unknown"}); code != 2 {
+		t.Errorf("unexpected exit code %d", code)
+	}
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/workflowai/workflowai/go/examples/workflowai"
+)
+
+// runStatuses maps the accepted values of -status to the status of the runs.
+var runStatuses = map[string]string{
+	"success":   "success",
+	"succeeded": "success",
+	"failure":   "failure",
+	"failed":    "failure",
+}
+
+const (
+	colorReset  = "\x1b[0m"
+	colorRed    = "\x1b[31m"
+	colorGreen  = "\x1b[32m"
+	colorYellow = "\x1b[33m"
+	colorDim    = "\x1b[2m"
+)
+
+func (c *cli) cmdRuns(ctx context.Context, args []string) error {
+	fs := c.newFlagSet("runs", "tail -agent my-agent [-status failed] [-model gpt-4o] [-interval 2s]")
+	agent := fs.String("agent", "", "agent ID (required)")
+	status := fs.String("status", "", "only show the runs with this status: success or failed")
+	model := fs.String("model", "", "only show the runs of this model")
+	interval := fs.Duration("interval", 2*time.Second, "interval between the polls of new runs")
+	since := fs.Duration("since", 0, "also show the runs created in this duration before now")
+	jsonOutput := fs.Bool("json", false, "print the runs as JSON lines")
+	noColor := fs.Bool("no-color", false, "disable colors, also disabled by NO_COLOR or when stdout is not a terminal")
+	if len(args) == 0 || args[0] != "tail" {
+		return usage(fs, "expected a runs command: tail")
+	}
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	if *agent == "" {
+		return usage(fs, "-agent is required")
+	}
+	var queries []workflowai.FieldQuery
+	if *status != "" {
+		s, ok := runStatuses[*status]
+		if !ok {
+			return usage(fs, "unknown status %q, expected success or failed", *status)
+		}
+		queries = append(queries, workflowai.FieldQuery{FieldName: "status", Operator: workflowai.SearchOperatorIs, Values: []any{s}})
+	}
+	if *model != "" {
+		queries = append(queries, workflowai.FieldQuery{FieldName: "model", Operator: workflowai.SearchOperatorIs, Values: []any{*model}})
+	}
+
+	t := &runTail{
+		client:  c.client(),
+		agentID: *agent,
+		queries: queries,
+		after:   time.Now().Add(-*since),
+		seen:    map[string]time.Time{},
+	}
+	enc := json.NewEncoder(c.stdout)
+	color := !*noColor && os.Getenv("NO_COLOR") == "" && isTerminal(c.stdout)
+	if !*jsonOutput {
+		fmt.Fprintf(c.stderr, "Watching the runs of %s, press Ctrl+C to stop.\n", *agent)
+	}
+
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+	for {
+		runs, err := t.poll(ctx)
+		if errors.Is(err, context.Canceled) {
+			return nil
+		}
+		if err != nil {
+			// A failed poll is retried at the next tick
+			fmt.Fprintf(c.stderr, "error: %v\n", workflowai.ScrubError(err))
+		}
+		for _, run := range runs {
+			if *jsonOutput {
+				enc.Encode(run)
+			} else {
+				writeRunLine(c.stdout, run, color)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// runTail polls the runs created since the previous poll.
+type runTail struct {
+	client  workflowai.Client
+	agentID string
+	queries []workflowai.FieldQuery
+	// after is the creation time of the most recent run seen
+	after time.Time
+	// seen holds the IDs of the runs created at after or later, which the
+	// next search may return again
+	seen map[string]time.Time
+	// pageSize is the number of runs of each search, tailPageSize by default
+	pageSize int
+}
+
+const tailPageSize = 100
+
+// poll returns the new runs, oldest first.
+func (t *runTail) poll(ctx context.Context) ([]workflowai.RunItem, error) {
+	queries := append([]workflowai.FieldQuery{{
+		FieldName: "time",
+		Operator:  workflowai.SearchOperatorIsAfter,
+		Values:    []any{t.after.UTC().Format(time.RFC3339Nano)},
+		Type:      "date",
+	}}, t.queries...)
+	pageSize := t.pageSize
+	if pageSize <= 0 {
+		pageSize = tailPageSize
+	}
+	// The runs are returned most recent first: the pages are read until the
+	// runs seen by the previous poll, so that bursts of runs are not cut
+	var runs []workflowai.RunItem
+	for offset := 0; ; offset += pageSize {
+		page, err := t.client.Runs.Search(ctx, t.agentID, workflowai.RunSearchParams{FieldQueries: queries, Limit: pageSize, Offset: offset})
+		if err != nil {
+			return nil, err
+		}
+		reached := false
+		for _, run := range page.Items {
+			if _, ok := t.seen[run.ID]; ok || run.CreatedAt.Before(t.after) {
+				reached = true
+				continue
+			}
+			runs = append(runs, run)
+		}
+		if reached || len(page.Items) < pageSize {
+			break
+		}
+	}
+	sort.SliceStable(runs, func(i, j int) bool { return runs[i].CreatedAt.Before(runs[j].CreatedAt) })
+	for _, run := range runs {
+		t.seen[run.ID] = run.CreatedAt
+		if run.CreatedAt.After(t.after) {
+			t.after = run.CreatedAt
+		}
+	}
+	for id, createdAt := range t.seen {
+		if createdAt.Before(t.after) {
+			delete(t.seen, id)
+		}
+	}
+	return runs, nil
+}
+
+func writeRunLine(w io.Writer, run workflowai.RunItem, color bool) {
+	paint := func(code string, s string) string {
+		if !color {
+			return s
+		}
+		return code + s + colorReset
+	}
+	statusColor := colorYellow
+	switch run.Status {
+	case "success":
+		statusColor = colorGreen
+	case "failure":
+		statusColor = colorRed
+	}
+	cost, duration := "-", "-"
+	if run.CostUSD != nil {
+		cost = fmt.Sprintf("$%.6f", *run.CostUSD)
+	}
+	if run.DurationSeconds != nil {
+		duration = (time.Duration(*run.DurationSeconds * float64(time.Second))).Round(time.Millisecond).String()
+	}
+	fields := []string{
+		paint(colorDim, run.CreatedAt.Local().Format("15:04:05")),
+		paint(statusColor, fmt.Sprintf("%-7s", run.Status)),
+		run.Version.Properties.Model,
+		cost,
+		duration,
+		run.URL,
+	}
+	if run.Error != nil {
+		fields = append(fields, paint(colorRed, workflowai.Scrub(run.Error.Message)))
+	}
+	fmt.Fprintln(w, strings.Join(fields, "  "))
+}
+
+// isTerminal returns true if w is a character device.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
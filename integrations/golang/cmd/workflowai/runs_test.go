@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/workflowai/workflowai/go/examples/workflowai"
+)
+
+func TestRunsTail(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	now := time.Now().UTC()
+	var searches []workflowai.RunSearchParams
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/_/agents/my-agent/runs/search" {
+			http.NotFound(w, r)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		var params workflowai.RunSearchParams
+		json.Unmarshal(body, &params)
+		searches = append(searches, params)
+		run := func(id string, at time.Time, status string) string {
+			return fmt.Sprintf(`{"id":%q,"task_id":"my-agent","status":%q,"cost_usd":0.002,"duration_seconds":1.5,"created_at":%q,"url":"https://workflowai.com/runs/%s","version":{"properties":{"model":"gpt-4o"}},"error":{"code":"timeout","message":"provider timed out"}}`,
+				id, status, at.Format(time.RFC3339Nano), id)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		switch len(searches) {
+		case 1:
+			// Most recent first
+			fmt.Fprintf(w, `{"items":[%s,%s]}`, run("run-2", now.Add(time.Second), "failure"), run("run-1", now, "failure"))
+		case 2:
+			// run-2 is returned again, at the time of the cursor
+			fmt.Fprintf(w, `{"items":[%s,%s]}`, run("run-3", now.Add(2*time.Second), "failure"), run("run-2", now.Add(time.Second), "failure"))
+		default:
+			cancel()
+			fmt.Fprint(w, `{"items":[]}`)
+		}
+	}))
+	defer server.Close()
+
+	c, stdout, stderr := testCLI(server, "")
+	code := c.main(ctx, []string{"runs", "tail", "-agent", "my-agent", "-status", "failed", "-since", "1m", "-interval", "10ms"})
+	if code != 0 {
+		t.Fatalf("exit code %d: %s", code, stderr)
+	}
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	if len(lines) != 3 || !strings.Contains(lines[0], "run-1") || !strings.Contains(lines[2], "run-3") {
+		t.Fatalf("unexpected output %q", stdout)
+	}
+	if !strings.Contains(lines[0], "failure  gpt-4o  $0.002000  1.5s  https://workflowai.com/runs/run-1  provider timed out") {
+		t.Errorf("unexpected line %q", lines[0])
+	}
+	if strings.Contains(stdout.String(), "\x1b[") {
+		t.Error("colors are not disabled when stdout is not a terminal")
+	}
+
+	status := searches[0].FieldQueries[1]
+	if status.FieldName != "status" || status.Values[0] != "failure" {
+		t.Errorf("unexpected status query %+v", status)
+	}
+	if after := searches[1].FieldQueries[0]; after.Operator != workflowai.SearchOperatorIsAfter || after.Values[0] != now.Add(time.Second).Format(time.RFC3339Nano) {
+		t.Errorf("unexpected time query %+v", after)
+	}
+}
+
+func TestRunTail_Pages(t *testing.T) {
+	now := time.Now().UTC()
+	// run-5 to run-1, most recent first
+	var runs []string
+	for i := 5; i >= 1; i-- {
+		runs = append(runs, fmt.Sprintf(`{"id":"run-%d","created_at":%q}`, i, now.Add(time.Duration(i)*time.Second).Format(time.RFC3339Nano)))
+	}
+	var offsets []int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var params workflowai.RunSearchParams
+		json.NewDecoder(r.Body).Decode(&params)
+		offsets = append(offsets, params.Offset)
+		end := min(params.Offset+params.Limit, len(runs))
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"items":[%s]}`, strings.Join(runs[min(params.Offset, end):end], ","))
+	}))
+	defer server.Close()
+
+	c, _, _ := testCLI(server, "")
+	tail := &runTail{client: c.client(), agentID: "my-agent", after: now, seen: map[string]time.Time{}, pageSize: 2}
+	// run-1 was returned by the previous poll
+	tail.seen["run-1"] = now.Add(time.Second)
+	got, err := tail.poll(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, run := range got {
+		ids = append(ids, run.ID)
+	}
+	if strings.Join(ids, ",") != "run-2,run-3,run-4,run-5" {
+		t.Errorf("expected the new runs of all the pages, got %v", ids)
+	}
+	if len(offsets) != 3 || offsets[2] != 4 {
+		t.Errorf("expected the pages to be read until run-1, got offsets %v", offsets)
+	}
+}
+
+func TestRunsTail_Usage(t *testing.T) {
+	c, _, stderr := testCLI(newTestServer(t, nil), "")
+	if code := c.main(context.Background(), []string{"runs", "tail", "-agent", "my-agent", "-status", "pending"}); code != 2 {
+		t.Errorf("unexpected exit code %d", code)
+	}
+	if !strings.Contains(stderr.String(), `unknown status "pending"`) {
+		t.Errorf("unexpected stderr %q", stderr)
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/workflowai/workflowai/go/examples/workflowai/openapitools"
+)
+
+func (c *cli) cmdTools(ctx context.Context, args []string) error {
+	fs := c.newFlagSet("tools", "-spec openapi.yaml [-out tools_gen.go]")
+	specPath := fs.String("spec", "", "OpenAPI 3 spec, in JSON or YAML (required)")
+	out := fs.String("out", "", "generated file, stdout when empty")
+	pkg := fs.String("package", "", "package of the generated file, $GOPACKAGE or the name of the directory of -out by default")
+	only := fs.String("operations", "", "comma separated names of the operations to generate, all by default")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *specPath == "" {
+		return usage(fs, "-spec is required")
+	}
+	if *pkg == "" {
+		// Set by go generate
+		*pkg = os.Getenv("GOPACKAGE")
+	}
+	if *pkg == "" && *out != "" {
+		abs, err := filepath.Abs(*out)
+		if err != nil {
+			return err
+		}
+		*pkg = packageName(filepath.Base(filepath.Dir(abs)))
+	}
+	if *pkg == "" {
+		return usage(fs, "-package is required when writing to stdout")
+	}
+
+	spec, err := openapitools.LoadFile(*specPath)
+	if err != nil {
+		return err
+	}
+	ops, err := spec.Operations()
+	if err != nil {
+		return err
+	}
+	if *only != "" {
+		names := strings.Split(*only, ",")
+		ops = slices.DeleteFunc(ops, func(op openapitools.Operation) bool { return !slices.Contains(names, op.Name) })
+		if len(ops) != len(names) {
+			return fmt.Errorf("%s: some of the operations %s do not exist", *specPath, *only)
+		}
+	}
+
+	src, err := openapitools.Generate(*pkg, ops)
+	if err != nil {
+		return err
+	}
+	if *out == "" {
+		_, err := c.stdout.Write(src)
+		return err
+	}
+	if existing, err := os.ReadFile(*out); err == nil && bytes.Equal(existing, src) {
+		// Leave the modification time of unchanged files untouched
+		return nil
+	}
+	return os.WriteFile(*out, src, 0o644)
+}
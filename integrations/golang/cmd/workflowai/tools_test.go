@@ -0,0 +1,31 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTools(t *testing.T) {
+	spec := filepath.Join(t.TempDir(), "openapi.json")
+	os.WriteFile(spec, []byte(`{"paths": {
+		"/pets": {"get": {"operationId": "listPets"}, "post": {"operationId": "createPet"}},
+		"/pets/{id}": {"get": {"operationId": "getPet", "parameters": [{"name": "id", "in": "path"}]}}
+	}}`), 0o644)
+
+	c, stdout, stderr := testCLI(newTestServer(t, nil), "")
+	if code := c.main(context.Background(), []string{"tools", "-spec", spec, "-package", "pets", "-operations", "listPets,getPet"}); code != 0 {
+		t.Fatalf("exit code %d: %s", code, stderr)
+	}
+	src := stdout.String()
+	if !strings.Contains(src, "func GetPetTool(") || !strings.Contains(src, "func ListPetsTool(") || strings.Contains(src, "CreatePetTool") {
+		t.Errorf("unexpected output:\n%s", src)
+	}
+
+	c, _, _ = testCLI(newTestServer(t, nil), "")
+	if code := c.main(context.Background(), []string{"tools", "-spec", spec, "-package", "pets", "-operations", "deletePet"}); code == 0 {
+		t.Error("expected an unknown operation error")
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"strings"
+	"sync"
+)
+
+// entry is a line of the checkpoint: the result of a row.
+type entry struct {
+	Row int `json:"row"`
+	// Hash is the hash of the row and of the configuration enriching it, so
+	// that the rows are enriched again when either changed since the
+	// checkpoint
+	Hash    string            `json:"hash"`
+	Values  map[string]string `json:"values,omitempty"`
+	Error   string            `json:"error,omitempty"`
+	CostUSD float64           `json:"cost_usd"`
+}
+
+// checkpoint is an append-only JSONL log of the enriched rows, fsynced after
+// each row so that an interrupted run resumes where it stopped.
+type checkpoint struct {
+	mu      sync.Mutex
+	f       *os.File
+	w       *bufio.Writer
+	entries map[int]entry
+	spent   float64
+}
+
+func openCheckpoint(path string) (*checkpoint, error) {
+	c := &checkpoint{entries: map[int]entry{}}
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		var e entry
+		// The last line is truncated when the process was killed while
+		// writing it
+		if json.Unmarshal([]byte(line), &e) != nil {
+			continue
+		}
+		c.entries[e.Row] = e
+		c.spent += e.CostUSD
+	}
+	if c.f, err = os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644); err != nil {
+		return nil, err
+	}
+	c.w = bufio.NewWriter(c.f)
+	return c, nil
+}
+
+// done returns the result of a row, and true when it was enriched. Results of
+// rows changed since the checkpoint are ignored.
+func (c *checkpoint) done(row int, hash string) (entry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[row]
+	if !ok || e.Hash != hash {
+		return entry{}, false
+	}
+	return e, e.Error == ""
+}
+
+func (c *checkpoint) add(e entry) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[e.Row] = e
+	c.spent += e.CostUSD
+	line, _ := json.Marshal(e)
+	c.w.Write(line)
+	c.w.WriteByte('\n')
+	if err := c.w.Flush(); err != nil {
+		return err
+	}
+	return c.f.Sync()
+}
+
+// costUSD returns the cost of all the runs, including the ones of previous
+// runs of the program.
+func (c *checkpoint) costUSD() float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.spent
+}
+
+func (c *checkpoint) Close() error {
+	return c.f.Close()
+}
+
+// rowHash returns the hash of a row and of config, the model, instructions,
+// prompt and output fields enriching it.
+func rowHash(config []string, record []string) string {
+	h := sha256.Sum256([]byte(strings.Join(config, "\x1f") + "\x1e" + strings.Join(record, "\x1f")))
+	return hex.EncodeToString(h[:8])
+}
@@ -0,0 +1,314 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/shared"
+
+	"github.com/workflowai/workflowai/go/examples/workflowai"
+)
+
+// Enriches each row of a CSV with an agent, adding columns to the output CSV:
+//
+//	go run ./csv-enrich -in companies.csv -out enriched.csv \
+//		-prompt "Company: {{name}}, website: {{website}}" \
+//		-fields "industry,employees,summary" -budget 5
+//
+// The columns of a row are the input of the agent, referenced as variables in
+// the prompt. The results are appended to a checkpoint as they arrive, so an
+// interrupted run, or one that stopped at the budget, resumes where it stopped
+// when started again. Rows are enriched again when they, the model, the
+// instructions, the prompt or the fields changed since the checkpoint. The
+// output CSV is written from the checkpoint, the rows that were not enriched
+// have an error column.
+func main() {
+	in := flag.String("in", "", "input CSV, with a header (required)")
+	out := flag.String("out", "", "output CSV (required)")
+	checkpointPath := flag.String("checkpoint", "", "checkpoint file, <out>.checkpoint.jsonl by default")
+	prompt := flag.String("prompt", "", "prompt, with {{column}} variables (required)")
+	fieldList := flag.String("fields", "", "comma separated columns added by the agent (required)")
+	instructions := flag.String("instructions", "Fill in the fields from the row. Use an empty string when a value cannot be determined, do not guess.", "system prompt")
+	model := flag.String("model", "csv-enrichment/gpt-4o-mini-latest", "agent and model")
+	concurrency := flag.Int("concurrency", 8, "rows enriched in parallel")
+	budget := flag.Float64("budget", 0, "stop starting rows once the cost reaches this amount in USD, 0 for no limit")
+	flag.Parse()
+	if *in == "" || *out == "" || *prompt == "" || *fieldList == "" {
+		fmt.Fprintln(os.Stderr, "usage: csv-enrich -in <csv> -out <csv> -prompt <prompt> -fields <a,b,c> [-budget USD]")
+		os.Exit(2)
+	}
+	if *checkpointPath == "" {
+		*checkpointPath = *out + ".checkpoint.jsonl"
+	}
+	fields := strings.Split(*fieldList, ",")
+	for i := range fields {
+		fields[i] = strings.TrimSpace(fields[i])
+	}
+
+	config := append([]string{*model, *instructions, *prompt}, fields...)
+
+	cp, err := openCheckpoint(*checkpointPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer cp.Close()
+	e := &enricher{
+		client:      workflowai.NewClient(),
+		checkpoint:  cp,
+		params:      enrichParams(*model, *instructions, *prompt, fields),
+		fields:      fields,
+		config:      config,
+		file:        filepath.Base(*in),
+		concurrency: *concurrency,
+		budget:      *budget,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if err := e.run(ctx, *in); err != nil {
+		log.Print(workflowai.ScrubError(err))
+	}
+	// The output is written even when interrupted, with the rows enriched so
+	// far
+	enriched, total, err := writeOutput(*in, *out, fields, config, cp)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("%d/%d rows enriched, total cost $%.4f, written to %s\n", enriched, total, cp.costUSD(), *out)
+	if enriched < total {
+		fmt.Printf("run the same command again to enrich the remaining rows\n")
+		os.Exit(1)
+	}
+}
+
+// enrichParams returns the parameters of the runs, with a strict structured
+// output of a string per field.
+func enrichParams(model string, instructions string, prompt string, fields []string) openai.ChatCompletionNewParams {
+	properties := map[string]any{}
+	for _, field := range fields {
+		properties[field] = map[string]any{"type": "string"}
+	}
+	return openai.ChatCompletionNewParams{
+		Model: model,
+		Messages: []openai.ChatCompletionMessageParamUnion{
+			openai.SystemMessage(instructions),
+			openai.UserMessage(prompt),
+		},
+		ResponseFormat: openai.ChatCompletionNewParamsResponseFormatUnion{OfJSONSchema: &openai.ResponseFormatJSONSchemaParam{
+			JSONSchema: openai.ResponseFormatJSONSchemaJSONSchemaParam{
+				Name: "enrichment",
+				Schema: map[string]any{
+					"type":                 "object",
+					"properties":           properties,
+					"required":             fields,
+					"additionalProperties": false,
+				},
+				Strict: openai.Bool(true),
+			},
+		}},
+		Temperature: openai.Float(0),
+	}
+}
+
+type enricher struct {
+	client     workflowai.Client
+	checkpoint *checkpoint
+	params     openai.ChatCompletionNewParams
+	fields     []string
+	// config is hashed with each row, see rowHash
+	config      []string
+	file        string
+	concurrency int
+	budget      float64
+
+	mu       sync.Mutex
+	runs     int
+	inFlight int
+}
+
+// overBudget returns true when starting a row could exceed the budget,
+// counting the rows in flight at the average cost of a row.
+func (e *enricher) overBudget() bool {
+	if e.budget <= 0 {
+		return false
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	spent := e.checkpoint.costUSD()
+	average := 0.0
+	if e.runs > 0 {
+		average = spent / float64(e.runs)
+	}
+	return spent+float64(e.inFlight+1)*average >= e.budget
+}
+
+// run enriches the rows missing from the checkpoint, streaming the input so
+// that large files are not loaded in memory.
+func (e *enricher) run(ctx context.Context, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	r := csv.NewReader(f)
+	header, err := r.Read()
+	if err != nil {
+		return fmt.Errorf("reading the header: %w", err)
+	}
+	for _, entry := range e.checkpoint.entries {
+		if entry.Error == "" {
+			e.runs++
+		}
+	}
+
+	sem := make(chan struct{}, max(e.concurrency, 1))
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for row := 1; ; row++ {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		hash := rowHash(e.config, record)
+		if _, ok := e.checkpoint.done(row, hash); ok {
+			continue
+		}
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if e.overBudget() {
+			<-sem
+			return fmt.Errorf("stopped at row %d: the budget of $%.2f is reached", row, e.budget)
+		}
+		e.mu.Lock()
+		e.inFlight++
+		e.mu.Unlock()
+
+		input := map[string]any{}
+		for i, column := range header {
+			if i < len(record) {
+				input[column] = record[i]
+			}
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			result := e.enrich(ctx, row, input)
+			result.Hash = hash
+			if result.Error != "" {
+				log.Printf("row %d: %s", row, result.Error)
+			}
+			if ctx.Err() == nil {
+				if err := e.checkpoint.add(result); err != nil {
+					log.Printf("writing the checkpoint: %v", err)
+				}
+			}
+			e.mu.Lock()
+			e.inFlight--
+			if result.Error == "" {
+				e.runs++
+			}
+			e.mu.Unlock()
+		}()
+	}
+}
+
+// enrich runs the agent on a row.
+func (e *enricher) enrich(ctx context.Context, row int, input map[string]any) entry {
+	result := entry{Row: row}
+	params := e.params
+	// The runs of a row can be found by file and row in WorkflowAI
+	params.Metadata = shared.Metadata{"csv_file": e.file, "csv_row": strconv.Itoa(row)}
+	params.SetExtraFields(map[string]any{"input": input})
+	completion, err := e.client.Chat.Completions.New(ctx, params)
+	if err != nil {
+		result.Error = workflowai.ScrubError(err).Error()
+		return result
+	}
+	result.CostUSD = workflowai.CompletionCost(completion)
+	if len(completion.Choices) == 0 {
+		result.Error = "no output"
+		return result
+	}
+	if err := json.Unmarshal([]byte(completion.Choices[0].Message.Content), &result.Values); err != nil {
+		result.Error = fmt.Sprintf("invalid output: %v", err)
+	}
+	return result
+}
+
+// writeOutput writes the input rows with the enriched columns and an error
+// column, and returns the number of enriched rows.
+func writeOutput(inPath string, outPath string, fields []string, config []string, cp *checkpoint) (enriched int, total int, err error) {
+	in, err := os.Open(inPath)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer in.Close()
+	// Written next to the output then renamed, so that a failure does not
+	// leave a truncated output
+	tmp, err := os.CreateTemp(filepath.Dir(outPath), filepath.Base(outPath)+".*")
+	if err != nil {
+		return 0, 0, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	r := csv.NewReader(in)
+	w := csv.NewWriter(tmp)
+	header, err := r.Read()
+	if err != nil {
+		return 0, 0, err
+	}
+	w.Write(append(append(header, fields...), "enrichment_error"))
+	for row := 1; ; row++ {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return 0, 0, err
+		}
+		total++
+		e, ok := cp.done(row, rowHash(config, record))
+		for _, field := range fields {
+			record = append(record, e.Values[field])
+		}
+		switch {
+		case ok:
+			enriched++
+			record = append(record, "")
+		case e.Error != "":
+			record = append(record, e.Error)
+		default:
+			record = append(record, "not enriched")
+		}
+		w.Write(record)
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return 0, 0, err
+	}
+	if err := tmp.Close(); err != nil {
+		return 0, 0, err
+	}
+	return enriched, total, os.Rename(tmp.Name(), outPath)
+}
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/shared"
+
+	"github.com/workflowai/workflowai/go/examples/workflowai"
+)
+
+// Interaction and response types of the Discord API
+const (
+	interactionPing    = 1
+	interactionCommand = 2
+
+	responsePong                  = 1
+	responseMessage               = 4
+	responseDeferredMessage       = 5
+	flagEphemeral                 = 64
+	editInterval                  = time.Second
+	maxToolRounds                 = 5
+	thinking                      = "_Thinking…_"
+	defaultInstructionsForServers = "You are a helpful assistant in a Discord server. Answer concisely, using Discord markdown. Use the tools to answer questions about the server."
+)
+
+type interaction struct {
+	Type      int    `json:"type"`
+	Token     string `json:"token"`
+	GuildID   string `json:"guild_id"`
+	ChannelID string `json:"channel_id"`
+	Member    *struct {
+		User discordUser `json:"user"`
+	} `json:"member"`
+	User *discordUser `json:"user"`
+	Data struct {
+		Name    string `json:"name"`
+		Options []struct {
+			Name  string `json:"name"`
+			Value string `json:"value"`
+		} `json:"options"`
+	} `json:"data"`
+}
+
+type discordUser struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+}
+
+func (i *interaction) user() discordUser {
+	if i.Member != nil {
+		return i.Member.User
+	}
+	if i.User != nil {
+		return *i.User
+	}
+	return discordUser{}
+}
+
+func (i *interaction) option(name string) string {
+	for _, o := range i.Data.Options {
+		if o.Name == name {
+			return o.Value
+		}
+	}
+	return ""
+}
+
+type bot struct {
+	discord      *discordAPI
+	completions  workflowai.ChatService
+	manager      *workflowai.ConversationManager
+	publicKey    ed25519.PublicKey
+	defaultModel string
+
+	mu sync.Mutex
+	// models holds the agent selected with /agent in each channel
+	models map[string]string
+	// channels serializes the replies of each channel, so that concurrent
+	// messages do not interleave in the history
+	channels map[string]*sync.Mutex
+}
+
+func (b *bot) handleInteractions(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 1<<20))
+	if err != nil {
+		http.Error(w, "invalid body", http.StatusBadRequest)
+		return
+	}
+	if err := verifyInteraction(r.Header, body, b.publicKey); err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	var in interaction
+	if err := json.Unmarshal(body, &in); err != nil {
+		http.Error(w, "invalid interaction", http.StatusBadRequest)
+		return
+	}
+
+	respond := func(response map[string]any) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}
+	ephemeral := func(content string) {
+		respond(map[string]any{"type": responseMessage, "data": map[string]any{"content": content, "flags": flagEphemeral}})
+	}
+	switch {
+	case in.Type == interactionPing:
+		respond(map[string]any{"type": responsePong})
+
+	case in.Type == interactionCommand && in.Data.Name == "agent":
+		model := in.option("model")
+		if !strings.Contains(model, "/") {
+			ephemeral(`Expected an agent and a model such as "support/gpt-4o" or a deployment such as "support/#1/production".`)
+			return
+		}
+		b.mu.Lock()
+		b.models[in.ChannelID] = model
+		b.mu.Unlock()
+		ephemeral(fmt.Sprintf("This channel now uses `%s`.", model))
+
+	case in.Type == interactionCommand && in.Data.Name == "reset":
+		if err := b.manager.Store().Trim(r.Context(), in.ChannelID, 0); err != nil {
+			ephemeral("Sorry, the conversation could not be reset.")
+			return
+		}
+		ephemeral("Conversation forgotten.")
+
+	case in.Type == interactionCommand && in.Data.Name == "ask":
+		// Discord expects a response within 3 seconds: defer it and stream
+		// the reply by editing it
+		respond(map[string]any{"type": responseDeferredMessage})
+		go func() {
+			// Interaction tokens are valid for 15 minutes
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+			defer cancel()
+			if err := b.ask(ctx, &in, in.option("prompt")); err != nil {
+				log.Printf("answering in %s: %v", in.ChannelID, workflowai.ScrubError(err))
+				b.discord.editResponse(ctx, in.Token, "@original", "Sorry, something went wrong. Please try again.")
+			}
+		}()
+
+	default:
+		ephemeral("Unknown command.")
+	}
+}
+
+func (b *bot) channelModel(channel string) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if model, ok := b.models[channel]; ok {
+		return model
+	}
+	return b.defaultModel
+}
+
+func (b *bot) channelLock(channel string) *sync.Mutex {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	lock, ok := b.channels[channel]
+	if !ok {
+		lock = &sync.Mutex{}
+		b.channels[channel] = lock
+	}
+	return lock
+}
+
+// ask answers a prompt in the conversation of the channel, running the tools
+// called by the model until it replies.
+func (b *bot) ask(ctx context.Context, in *interaction, prompt string) error {
+	lock := b.channelLock(in.ChannelID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	user := in.user()
+	messages := []openai.ChatCompletionMessageParamUnion{openai.UserMessage(fmt.Sprintf("%s: %s", user.Username, prompt))}
+	params := openai.ChatCompletionNewParams{
+		Model: b.channelModel(in.ChannelID),
+		Tools: serverTools,
+		// The runs can be searched by Discord user, server and channel in
+		// WorkflowAI
+		Metadata: shared.Metadata{"discord_user": user.ID, "discord_guild": in.GuildID, "discord_channel": in.ChannelID},
+	}
+
+	reply := &streamedReply{discord: b.discord, token: in.Token}
+	for round := 0; ; round++ {
+		completion, err := b.manager.SendStreaming(ctx, in.ChannelID, params, reply.add, messages...)
+		if err != nil {
+			return err
+		}
+		calls := completion.Choices[0].Message.ToolCalls
+		if len(calls) == 0 {
+			return reply.flush(ctx, true)
+		}
+		if round == maxToolRounds {
+			return errors.New("too many tool calls")
+		}
+		messages = messages[:0]
+		for _, call := range calls {
+			messages = append(messages, openai.ToolMessage(b.runTool(ctx, in.GuildID, call), call.ID))
+		}
+	}
+}
+
+// channelMemory sends the instructions before the history of a channel,
+// trimmed to the last turns holding at most maxMessages messages. The
+// instructions are not stored, so that trimming never drops them, and the
+// history is cut before a user message, so that tool results are not
+// separated from their tool calls.
+type channelMemory struct {
+	instructions string
+	maxMessages  int
+}
+
+func (m channelMemory) Context(_ context.Context, _ string, history []openai.ChatCompletionMessageParamUnion) ([]openai.ChatCompletionMessageParamUnion, error) {
+	return append([]openai.ChatCompletionMessageParamUnion{openai.SystemMessage(m.instructions)}, history...), nil
+}
+
+func (m channelMemory) Update(_ context.Context, _ string, history []openai.ChatCompletionMessageParamUnion, _ int) (int, error) {
+	if m.maxMessages <= 0 || len(history) <= m.maxMessages {
+		return len(history), nil
+	}
+	// The last turn is kept whole even when it is over maxMessages
+	start := len(history) - 1
+	for i := len(history) - m.maxMessages; i < len(history); i++ {
+		if history[i].OfUser != nil {
+			start = i
+			break
+		}
+	}
+	for start > 0 && history[start].OfUser == nil {
+		start--
+	}
+	return len(history) - start, nil
+}
+
+// streamedReply shows a streamed reply in the response of an interaction and
+// its follow-up messages, each holding up to messageLimit characters.
+type streamedReply struct {
+	discord  *discordAPI
+	token    string
+	content  strings.Builder
+	ids      []string
+	sent     []string
+	lastEdit time.Time
+}
+
+func (s *streamedReply) add(chunk openai.ChatCompletionChunk) {
+	if len(chunk.Choices) == 0 || chunk.Choices[0].Delta.Content == "" {
+		return
+	}
+	s.content.WriteString(chunk.Choices[0].Delta.Content)
+	if time.Since(s.lastEdit) >= editInterval {
+		if err := s.flush(context.Background(), false); err != nil {
+			log.Printf("updating the reply: %v", err)
+		}
+	}
+}
+
+// flush edits the messages whose content changed and posts the new ones.
+func (s *streamedReply) flush(ctx context.Context, final bool) error {
+	s.lastEdit = time.Now()
+	text := s.content.String()
+	if text == "" {
+		text = thinking
+	}
+	if !final {
+		text += " …"
+	}
+	for i, part := range splitMessage(text, messageLimit) {
+		switch {
+		case i < len(s.sent) && s.sent[i] == part:
+			continue
+		case i == 0 && len(s.ids) == 0:
+			s.ids = append(s.ids, "@original")
+			fallthrough
+		case i < len(s.ids):
+			if err := s.discord.editResponse(ctx, s.token, s.ids[i], part); err != nil {
+				return err
+			}
+		default:
+			id, err := s.discord.followUp(ctx, s.token, part)
+			if err != nil {
+				return err
+			}
+			s.ids = append(s.ids, id)
+		}
+		if i < len(s.sent) {
+			s.sent[i] = part
+		} else {
+			s.sent = append(s.sent, part)
+		}
+	}
+	return nil
+}
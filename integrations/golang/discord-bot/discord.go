@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// messageLimit is the maximum length of a Discord message, in characters.
+const messageLimit = 2000
+
+// discordAPI calls the endpoints of the Discord REST API used by the bot.
+type discordAPI struct {
+	token      string
+	appID      string
+	baseURL    string
+	httpClient *http.Client
+}
+
+func (d *discordAPI) do(ctx context.Context, method string, path string, body any, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, d.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bot "+d.token)
+	res, err := d.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	raw, err := io.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	if res.StatusCode >= 300 {
+		return fmt.Errorf("discord %s %s: %s: %s", method, path, res.Status, raw)
+	}
+	if out != nil {
+		return json.Unmarshal(raw, out)
+	}
+	return nil
+}
+
+// editResponse replaces the content of a message of an interaction, "@original"
+// for the response itself or the ID of a follow-up message.
+func (d *discordAPI) editResponse(ctx context.Context, token string, messageID string, content string) error {
+	return d.do(ctx, http.MethodPatch, fmt.Sprintf("/webhooks/%s/%s/messages/%s", d.appID, token, messageID), map[string]any{"content": content}, nil)
+}
+
+// followUp posts a follow-up message to an interaction and returns its ID.
+func (d *discordAPI) followUp(ctx context.Context, token string, content string) (string, error) {
+	var message struct {
+		ID string `json:"id"`
+	}
+	err := d.do(ctx, http.MethodPost, fmt.Sprintf("/webhooks/%s/%s?wait=true", d.appID, token), map[string]any{"content": content}, &message)
+	return message.ID, err
+}
+
+// registerCommands replaces the slash commands of the application, in a guild
+// when guildID is set, since global commands take up to an hour to appear.
+func (d *discordAPI) registerCommands(ctx context.Context, guildID string) error {
+	path := "/applications/" + d.appID + "/commands"
+	if guildID != "" {
+		path = "/applications/" + d.appID + "/guilds/" + guildID + "/commands"
+	}
+	option := func(name string, description string) map[string]any {
+		// 3 is the string option type
+		return map[string]any{"type": 3, "name": name, "description": description, "required": true}
+	}
+	commands := []any{
+		map[string]any{"name": "ask", "description": "Ask the assistant", "options": []any{option("prompt", "Your message")}},
+		map[string]any{"name": "agent", "description": "Switch the agent of the channel", "options": []any{
+			option("model", `An agent and model such as "support/gpt-4o", or a deployment such as "support/#1/production"`),
+		}},
+		map[string]any{"name": "reset", "description": "Forget the conversation of the channel"},
+	}
+	return d.do(ctx, http.MethodPut, path, commands, nil)
+}
+
+// verifyInteraction checks the Ed25519 signature Discord adds to the
+// interactions it sends.
+func verifyInteraction(header http.Header, body []byte, publicKey ed25519.PublicKey) error {
+	signature, err := hex.DecodeString(header.Get("X-Signature-Ed25519"))
+	if err != nil || len(signature) != ed25519.SignatureSize {
+		return errors.New("invalid signature")
+	}
+	message := append([]byte(header.Get("X-Signature-Timestamp")), body...)
+	if !ed25519.Verify(publicKey, message, signature) {
+		return errors.New("invalid signature")
+	}
+	return nil
+}
+
+// splitMessage splits text into messages of at most limit characters,
+// preferably at line then word boundaries.
+func splitMessage(text string, limit int) []string {
+	runes := []rune(text)
+	var parts []string
+	for len(runes) > limit {
+		end := limit
+		window := string(runes[:limit])
+		for _, sep := range []string{"\n", " "} {
+			// Only split in the second half, to avoid tiny messages
+			if i := strings.LastIndex(window, sep); i >= 0 && len([]rune(window[:i])) > limit/2 {
+				end = len([]rune(window[:i])) + 1
+				break
+			}
+		}
+		parts = append(parts, strings.TrimRight(string(runes[:end]), " \n"))
+		runes = runes[end:]
+	}
+	return append(parts, string(runes))
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"flag"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/openai/openai-go/option"
+
+	"github.com/workflowai/workflowai/go/examples/workflowai"
+	"github.com/workflowai/workflowai/go/examples/workflowai/vaultsecrets"
+)
+
+// A Discord bot answering slash commands with an agent, through the
+// interactions endpoint of the application, so no gateway connection is
+// needed:
+//
+//   - /ask streams the reply by editing the response, continued in follow-up
+//     messages past the 2000 characters limit of Discord messages
+//   - /agent switches the agent, model or deployment of the channel
+//   - /reset forgets the conversation of the channel
+//
+// The history is kept per channel by a conversation manager, and the model
+// can call tools returning information about the server.
+//
+// Set the interactions endpoint URL of the application to /discord/interactions,
+// then register the commands and start the bot:
+//
+//	export DISCORD_APP_ID=... DISCORD_PUBLIC_KEY=... DISCORD_BOT_TOKEN=... WORKFLOWAI_API_KEY=...
+//	go run ./discord-bot -register -guild <server ID>
+//	go run ./discord-bot -addr :3000
+//
+// In production, store the same keys in a Vault secret and pass its path with
+// -vault, VAULT_ADDR and VAULT_TOKEN instead. The WorkflowAI API key is then
+// renewed when it is rotated.
+func main() {
+	addr := flag.String("addr", ":3000", "listen address")
+	model := flag.String("model", "discord-assistant/gpt-4o-mini-latest", "default agent and model")
+	instructions := flag.String("instructions", defaultInstructionsForServers, "system prompt")
+	maxMessages := flag.Int("max-messages", 40, "messages of history kept per channel")
+	register := flag.Bool("register", false, "register the slash commands and exit")
+	guild := flag.String("guild", "", "register the commands in this server only, where they are available immediately")
+	vault := flag.String("vault", "", "Vault path of the keys, e.g. discord-bot/production, read from the environment otherwise")
+	flag.Parse()
+
+	secrets, err := loadSecrets(context.Background(), *vault)
+	if err != nil {
+		log.Fatal(err)
+	}
+	discord := &discordAPI{
+		token:      secret(secrets, "DISCORD_BOT_TOKEN"),
+		appID:      secret(secrets, "DISCORD_APP_ID"),
+		baseURL:    "https://discord.com/api/v10",
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+	if *register {
+		if err := discord.registerCommands(context.Background(), *guild); err != nil {
+			log.Fatal(err)
+		}
+		log.Print("commands registered")
+		return
+	}
+	publicKey, err := hex.DecodeString(secret(secrets, "DISCORD_PUBLIC_KEY"))
+	if err != nil || len(publicKey) != ed25519.PublicKeySize {
+		log.Fatal("DISCORD_PUBLIC_KEY must be the hex encoded public key of the application")
+	}
+
+	client := workflowai.NewClient(option.WithMiddleware(workflowai.APIKeyMiddleware(secrets.Key("WORKFLOWAI_API_KEY"))))
+	b := &bot{
+		discord: discord,
+		// In-process history, use workflowai/redisstore to share it between
+		// replicas
+		manager: workflowai.NewConversationManager(&client.Chat.Completions, workflowai.NewMemoryConversationStore(),
+			workflowai.WithConversationMemory(channelMemory{instructions: *instructions, maxMessages: *maxMessages})),
+		publicKey:    publicKey,
+		defaultModel: *model,
+		models:       map[string]string{},
+		channels:     map[string]*sync.Mutex{},
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /discord/interactions", b.handleInteractions)
+	log.Printf("listening on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, mux))
+}
+
+// loadSecrets loads the keys of the bot from the Vault secret at path, or
+// from the environment when path is empty.
+func loadSecrets(ctx context.Context, path string) (*workflowai.Secrets, error) {
+	if path != "" {
+		return vaultsecrets.New(ctx, path)
+	}
+	return workflowai.NewSecrets(ctx, workflowai.EnvSecrets("DISCORD_BOT_TOKEN", "DISCORD_APP_ID", "DISCORD_PUBLIC_KEY", "WORKFLOWAI_API_KEY"), 0)
+}
+
+func secret(secrets *workflowai.Secrets, name string) string {
+	value, err := secrets.Get(context.Background(), name, false)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return value
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/openai/openai-go"
+)
+
+// serverTools are the tools the model can call to answer questions about the
+// Discord server.
+var serverTools = []openai.ChatCompletionToolParam{
+	{Function: openai.FunctionDefinitionParam{
+		Name:        "server_info",
+		Description: openai.String("Returns the name of the Discord server, its number of members and of online members"),
+		Parameters:  openai.FunctionParameters{"type": "object", "properties": map[string]any{}},
+	}},
+	{Function: openai.FunctionDefinitionParam{
+		Name:        "list_channels",
+		Description: openai.String("Lists the channels of the Discord server, with their type and topic"),
+		Parameters:  openai.FunctionParameters{"type": "object", "properties": map[string]any{}},
+	}},
+	{Function: openai.FunctionDefinitionParam{
+		Name:        "current_time",
+		Description: openai.String("Returns the current time in a time zone"),
+		Parameters: openai.FunctionParameters{
+			"type": "object",
+			"properties": map[string]any{
+				"time_zone": map[string]any{"type": "string", "description": `An IANA time zone such as "Europe/Paris", UTC when empty`},
+			},
+		},
+	}},
+}
+
+var channelTypes = map[int]string{0: "text", 2: "voice", 4: "category", 5: "announcement", 13: "stage", 15: "forum"}
+
+// runTool runs a tool call and returns its result, or the error for the model
+// to recover from.
+func (b *bot) runTool(ctx context.Context, guildID string, call openai.ChatCompletionMessageToolCall) string {
+	result, err := b.tool(ctx, guildID, call.Function.Name, call.Function.Arguments)
+	if err != nil {
+		return fmt.Sprintf(`{"error": %q}`, err.Error())
+	}
+	data, _ := json.Marshal(result)
+	return string(data)
+}
+
+func (b *bot) tool(ctx context.Context, guildID string, name string, arguments string) (any, error) {
+	if guildID == "" && name != "current_time" {
+		return nil, fmt.Errorf("%s is only available in a server", name)
+	}
+	switch name {
+	case "server_info":
+		var guild struct {
+			Name         string `json:"name"`
+			Description  string `json:"description"`
+			MemberCount  int    `json:"approximate_member_count"`
+			PresentCount int    `json:"approximate_presence_count"`
+		}
+		err := b.discord.do(ctx, http.MethodGet, "/guilds/"+guildID+"?with_counts=true", nil, &guild)
+		return guild, err
+
+	case "list_channels":
+		var channels []struct {
+			Name  string `json:"name"`
+			Type  int    `json:"type"`
+			Topic string `json:"topic"`
+		}
+		if err := b.discord.do(ctx, http.MethodGet, "/guilds/"+guildID+"/channels", nil, &channels); err != nil {
+			return nil, err
+		}
+		out := make([]map[string]string, 0, len(channels))
+		for _, c := range channels {
+			out = append(out, map[string]string{"name": c.Name, "type": channelTypes[c.Type], "topic": c.Topic})
+		}
+		return out, nil
+
+	case "current_time":
+		var args struct {
+			TimeZone string `json:"time_zone"`
+		}
+		json.Unmarshal([]byte(arguments), &args)
+		location, err := time.LoadLocation(args.TimeZone)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]string{"time": time.Now().In(location).Format(time.RFC1123)}, nil
+	}
+	return nil, fmt.Errorf("unknown tool %s", name)
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-imap/client"
+)
+
+// triagedFlag marks the emails already triaged, so that emails left in the
+// inbox are not sent again.
+const triagedFlag = "$Triaged"
+
+// maxBodyLength truncates long emails, the beginning is enough to triage them
+const maxBodyLength = 8000
+
+type email struct {
+	UID       uint32
+	MessageID string
+	From      string
+	To        string
+	Subject   string
+	Date      time.Time
+	Body      string
+}
+
+type mailbox struct {
+	addr     string
+	user     string
+	password string
+	name     string
+
+	// uidValidity identifies the UIDs of the mailbox, they must not be reused
+	// after it changes
+	uidValidity uint32
+}
+
+// connect logs in and selects the mailbox. The connection is only kept while
+// the emails are fetched or filed, not during the triage runs.
+func (m *mailbox) connect() (*client.Client, error) {
+	c, err := client.DialTLS(m.addr, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.Login(m.user, m.password); err != nil {
+		c.Logout()
+		return nil, err
+	}
+	status, err := c.Select(m.name, false)
+	if err != nil {
+		c.Logout()
+		return nil, err
+	}
+	if m.uidValidity != 0 && status.UidValidity != m.uidValidity {
+		c.Logout()
+		return nil, fmt.Errorf("the UIDs of %s changed during the triage", m.name)
+	}
+	m.uidValidity = status.UidValidity
+	return c, nil
+}
+
+// fetch returns up to limit unread emails that were not triaged yet, without
+// marking them as read.
+func (m *mailbox) fetch(c *client.Client, limit int) ([]email, error) {
+	criteria := imap.NewSearchCriteria()
+	criteria.WithoutFlags = []string{imap.SeenFlag, triagedFlag}
+	uids, err := c.UidSearch(criteria)
+	if err != nil || len(uids) == 0 {
+		return nil, err
+	}
+	if len(uids) > limit {
+		uids = uids[:limit]
+	}
+	set := new(imap.SeqSet)
+	set.AddNum(uids...)
+	section := &imap.BodySectionName{Peek: true}
+	messages := make(chan *imap.Message, len(uids))
+	if err := c.UidFetch(set, []imap.FetchItem{imap.FetchUid, section.FetchItem()}, messages); err != nil {
+		return nil, err
+	}
+	var emails []email
+	for msg := range messages {
+		body := msg.GetBody(section)
+		if body == nil {
+			continue
+		}
+		e, err := parseEmail(body)
+		if err != nil {
+			return nil, fmt.Errorf("parsing email %d: %w", msg.Uid, err)
+		}
+		e.UID = msg.Uid
+		emails = append(emails, e)
+	}
+	return emails, nil
+}
+
+// file moves an email to a folder, created when missing, and flags it when
+// urgent.
+func (m *mailbox) file(c *client.Client, uid uint32, folder string, flagged bool) error {
+	set := new(imap.SeqSet)
+	set.AddNum(uid)
+	flags := []interface{}{triagedFlag}
+	if flagged {
+		flags = append(flags, imap.FlaggedFlag)
+	}
+	if err := c.UidStore(set, imap.FormatFlagsOp(imap.AddFlags, true), flags, nil); err != nil {
+		return err
+	}
+	if err := c.UidMove(set, folder); err != nil {
+		// Most servers answer [TRYCREATE] for missing folders
+		if err := c.Create(folder); err != nil {
+			return fmt.Errorf("creating folder %s: %w", folder, err)
+		}
+		return c.UidMove(set, folder)
+	}
+	return nil
+}
+
+// draft saves a reply to an email in the drafts folder, for a human to review
+// and send.
+func (m *mailbox) draft(c *client.Client, folder string, e email, body string) error {
+	var msg bytes.Buffer
+	subject := e.Subject
+	if !strings.HasPrefix(strings.ToLower(subject), "re:") {
+		subject = "Re: " + subject
+	}
+	fmt.Fprintf(&msg, "From: %s\r\n", m.user)
+	fmt.Fprintf(&msg, "To: %s\r\n", e.From)
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	if e.MessageID != "" {
+		fmt.Fprintf(&msg, "In-Reply-To: %s\r\nReferences: %s\r\n", e.MessageID, e.MessageID)
+	}
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\nContent-Transfer-Encoding: quoted-printable\r\n\r\n")
+	w := quotedprintable.NewWriter(&msg)
+	w.Write([]byte(strings.ReplaceAll(body, "\n", "\r\n")))
+	w.Close()
+	return c.Append(folder, []string{imap.DraftFlag}, time.Now(), &msg)
+}
+
+func parseEmail(r io.Reader) (email, error) {
+	msg, err := mail.ReadMessage(r)
+	if err != nil {
+		return email{}, err
+	}
+	decoder := mime.WordDecoder{}
+	header := func(key string) string {
+		value, err := decoder.DecodeHeader(msg.Header.Get(key))
+		if err != nil {
+			return msg.Header.Get(key)
+		}
+		return value
+	}
+	e := email{
+		MessageID: msg.Header.Get("Message-Id"),
+		From:      header("From"),
+		To:        header("To"),
+		Subject:   header("Subject"),
+	}
+	e.Date, _ = msg.Header.Date()
+	body, err := textBody(msg.Header.Get("Content-Type"), msg.Header.Get("Content-Transfer-Encoding"), msg.Body)
+	if err != nil {
+		return email{}, err
+	}
+	if len(body) > maxBodyLength {
+		// Cut on a rune boundary, the body being sent as UTF-8
+		cut := maxBodyLength
+		for cut > 0 && !utf8.RuneStart(body[cut]) {
+			cut--
+		}
+		body = body[:cut] + "\n[truncated]"
+	}
+	e.Body = strings.TrimSpace(body)
+	return e, nil
+}
+
+var (
+	htmlTags   = regexp.MustCompile(`(?s)<(script|style).*?</(script|style)>|<[^>]+>`)
+	blankLines = regexp.MustCompile(`\n\s*\n+`)
+)
+
+// textBody returns the text of a MIME part, preferring the text/plain
+// alternative of multipart emails. Attachments are ignored.
+func textBody(contentType string, encoding string, body io.Reader) (string, error) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = "text/plain"
+	}
+	switch strings.ToLower(encoding) {
+	case "quoted-printable":
+		body = quotedprintable.NewReader(body)
+	case "base64":
+		body = base64.NewDecoder(base64.StdEncoding, body)
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		reader := multipart.NewReader(body, params["boundary"])
+		var htmlText string
+		for {
+			part, err := reader.NextPart()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return "", err
+			}
+			if strings.HasPrefix(part.Header.Get("Content-Disposition"), "attachment") {
+				continue
+			}
+			text, err := textBody(part.Header.Get("Content-Type"), part.Header.Get("Content-Transfer-Encoding"), part)
+			if err != nil {
+				return "", err
+			}
+			partType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+			if text != "" && partType != "text/html" {
+				return text, nil
+			}
+			if htmlText == "" {
+				htmlText = text
+			}
+		}
+		return htmlText, nil
+	}
+
+	if !strings.HasPrefix(mediaType, "text/") {
+		return "", nil
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return "", err
+	}
+	text := string(data)
+	if mediaType == "text/html" {
+		text = htmlTags.ReplaceAllString(text, "\n")
+		text = blankLines.ReplaceAllString(html.UnescapeString(text), "\n\n")
+	}
+	return text, nil
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/openai/openai-go/option"
+
+	"github.com/workflowai/workflowai/go/examples/workflowai"
+	"github.com/workflowai/workflowai/go/examples/workflowai/vaultsecrets"
+)
+
+// Triages an IMAP inbox: every poll sends the unread emails, in concurrent
+// chat completions, to an agent that categorizes them, rates their urgency
+// and suggests a reply, as the strict arguments of a file_email tool call.
+// The calls are then run against the mailbox: the emails are moved to a
+// folder per category, the urgent ones are flagged and the suggested replies
+// are saved as drafts.
+//
+//	export IMAP_ADDR=imap.example.com:993 IMAP_USER=support@example.com IMAP_PASSWORD=... WORKFLOWAI_API_KEY=...
+//	go run ./email-triage -interval 10m
+//
+// In production, store the same keys in a Vault secret and pass its path with
+// -vault, VAULT_ADDR and VAULT_TOKEN instead. The WorkflowAI API key is then
+// renewed when it is rotated.
+//
+// The emails are fetched without being marked as read and marked $Triaged once
+// filed, so failed emails are triaged again at the next poll.
+func main() {
+	model := flag.String("model", "email-triage/gpt-4o-mini-latest", "agent and model")
+	mailboxName := flag.String("mailbox", "INBOX", "mailbox to triage")
+	folder := flag.String("folder", "Triage", "parent folder of the category folders")
+	drafts := flag.String("drafts", "Drafts", "folder of the suggested replies")
+	interval := flag.Duration("interval", 15*time.Minute, "interval between polls, 0 to poll once")
+	concurrency := flag.Int("concurrency", 8, "maximum concurrent triage runs")
+	pageSize := flag.Int("limit", 100, "maximum emails per poll")
+	dryRun := flag.Bool("dry-run", false, "print the triage without changing the mailbox")
+	vault := flag.String("vault", "", "Vault path of the keys, e.g. email-triage/production, read from the environment otherwise")
+	flag.Parse()
+
+	secrets, err := loadSecrets(context.Background(), *vault)
+	if err != nil {
+		log.Fatal(err)
+	}
+	m := &mailbox{
+		addr:     secret(secrets, "IMAP_ADDR"),
+		user:     secret(secrets, "IMAP_USER"),
+		password: secret(secrets, "IMAP_PASSWORD"),
+		name:     *mailboxName,
+	}
+	t := &triager{
+		client:      workflowai.NewClient(option.WithMiddleware(workflowai.APIKeyMiddleware(secrets.Key("WORKFLOWAI_API_KEY")))),
+		mailbox:     m,
+		model:       *model,
+		folder:      *folder,
+		drafts:      *drafts,
+		concurrency: *concurrency,
+		pageSize:    *pageSize,
+		dryRun:      *dryRun,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	for {
+		if err := t.poll(ctx); err != nil {
+			log.Print(workflowai.ScrubError(err))
+		}
+		if *interval == 0 {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(*interval):
+		}
+	}
+}
+
+// loadSecrets loads the keys of the triage from the Vault secret at path, or
+// from the environment when path is empty.
+func loadSecrets(ctx context.Context, path string) (*workflowai.Secrets, error) {
+	if path != "" {
+		return vaultsecrets.New(ctx, path)
+	}
+	return workflowai.NewSecrets(ctx, workflowai.EnvSecrets("IMAP_ADDR", "IMAP_USER", "IMAP_PASSWORD", "WORKFLOWAI_API_KEY"), 0)
+}
+
+func secret(secrets *workflowai.Secrets, name string) string {
+	value, err := secrets.Get(context.Background(), name, false)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return value
+}
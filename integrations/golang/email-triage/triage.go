@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/mail"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/emersion/go-imap/client"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/shared"
+
+	"github.com/workflowai/workflowai/go/examples/workflowai"
+)
+
+var categories = []string{"support", "sales", "billing", "partnership", "recruiting", "newsletter", "spam", "personal", "other"}
+
+// Triage is the structured output of the agent, the arguments of its call to
+// file_email.
+type Triage struct {
+	Category string `json:"category"`
+	// Urgency is "low", "normal", "high" or "critical"
+	Urgency string `json:"urgency"`
+	Summary string `json:"summary"`
+	// SuggestedReply is empty when the email does not need a reply
+	SuggestedReply string `json:"suggested_reply"`
+}
+
+var fileEmailSchema = json.RawMessage(`{
+	"type": "object",
+	"properties": {
+		"category": {"type": "string", "enum": ` + mustJSON(categories) + `},
+		"urgency": {
+			"type": "string",
+			"enum": ["low", "normal", "high", "critical"],
+			"description": "high when the sender waits for an answer today, critical for outages, security and legal issues"
+		},
+		"summary": {"type": "string", "description": "One sentence summary of the email"},
+		"suggested_reply": {"type": "string", "description": "A reply to send after review, empty for newsletters, spam and emails that do not need one"}
+	},
+	"required": ["category", "urgency", "summary", "suggested_reply"],
+	"additionalProperties": false
+}`)
+
+func mustJSON(v any) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return string(data)
+}
+
+// triageParams returns the parameters of a triage run. The model must call
+// file_email, whose strict schema makes the call a structured output.
+func triageParams(model string) openai.ChatCompletionNewParams {
+	var schema map[string]any
+	json.Unmarshal(fileEmailSchema, &schema)
+	return openai.ChatCompletionNewParams{
+		Model: model,
+		Messages: []openai.ChatCompletionMessageParamUnion{
+			openai.SystemMessage("You triage the inbox of {{mailbox}}. Categorize each email, rate its urgency and suggest a reply in the language of the sender, then file it."),
+			openai.UserMessage("From: {{from}}\nSubject: {{subject}}\nDate: {{date}}\n\n{{body}}"),
+		},
+		Tools: []openai.ChatCompletionToolParam{{Function: openai.FunctionDefinitionParam{
+			Name:        "file_email",
+			Description: openai.String("Files the email in the folder of its category, flags it when urgent and saves the suggested reply as a draft"),
+			Parameters:  schema,
+			Strict:      openai.Bool(true),
+		}}},
+		ToolChoice: openai.ChatCompletionToolChoiceOptionUnionParam{OfChatCompletionNamedToolChoice: &openai.ChatCompletionNamedToolChoiceParam{
+			Function: openai.ChatCompletionNamedToolChoiceFunctionParam{Name: "file_email"},
+		}},
+		Temperature: openai.Float(0),
+	}
+}
+
+type triager struct {
+	client      workflowai.Client
+	mailbox     *mailbox
+	model       string
+	folder      string
+	drafts      string
+	concurrency int
+	pageSize    int
+	dryRun      bool
+}
+
+// triaged is the triage run of an email.
+type triaged struct {
+	email      email
+	completion *openai.ChatCompletion
+	err        error
+}
+
+// poll triages the new emails with concurrent runs, then files them from the
+// tool calls of the completions.
+func (t *triager) poll(ctx context.Context) error {
+	c, err := t.mailbox.connect()
+	if err != nil {
+		return fmt.Errorf("connecting to the mailbox: %w", err)
+	}
+	emails, err := t.mailbox.fetch(c, t.pageSize)
+	c.Logout()
+	if err != nil {
+		return fmt.Errorf("fetching emails: %w", err)
+	}
+	if len(emails) == 0 {
+		return nil
+	}
+
+	log.Printf("triaging %d emails", len(emails))
+	runs := make([]triaged, len(emails))
+	sem := make(chan struct{}, max(t.concurrency, 1))
+	var wg sync.WaitGroup
+	for i, e := range emails {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			completion, err := t.triage(ctx, e)
+			runs[i] = triaged{email: e, completion: completion, err: err}
+		}()
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// The mailbox connection is not safe for concurrent use, so the emails are
+	// filed one by one
+	if c, err = t.mailbox.connect(); err != nil {
+		return fmt.Errorf("connecting to the mailbox: %w", err)
+	}
+	defer c.Logout()
+	var cost float64
+	for _, run := range runs {
+		if run.err != nil {
+			// The email is triaged again at the next poll
+			log.Printf("%q: %v", run.email.Subject, workflowai.ScrubError(run.err))
+			continue
+		}
+		cost += workflowai.CompletionCost(run.completion)
+		if err := t.apply(c, run.email, run.completion); err != nil {
+			log.Printf("%q: %v", run.email.Subject, workflowai.ScrubError(err))
+		}
+	}
+	log.Printf("%d emails triaged, cost $%.4f", len(emails), cost)
+	return nil
+}
+
+// triage runs the agent on an email.
+func (t *triager) triage(ctx context.Context, e email) (*openai.ChatCompletion, error) {
+	id := strconv.FormatUint(uint64(e.UID), 10)
+	params := triageParams(t.model)
+	// The runs can be searched by sender domain and message in WorkflowAI
+	params.Metadata = shared.Metadata{
+		"mailbox":       t.mailbox.user + "/" + t.mailbox.name,
+		"email_uid":     id,
+		"sender_domain": senderDomain(e.From),
+		"message_id":    e.MessageID,
+		"uidvalidity":   strconv.FormatUint(uint64(t.mailbox.uidValidity), 10),
+	}
+	params.SetExtraFields(map[string]any{"input": map[string]any{
+		"mailbox": t.mailbox.user,
+		"from":    e.From,
+		"subject": e.Subject,
+		"date":    e.Date.Format(time.RFC1123Z),
+		"body":    e.Body,
+	}})
+	return t.client.Chat.Completions.New(ctx, params)
+}
+
+// apply runs the file_email call of a completion. An email is filed once, so
+// the calls after the first one are ignored.
+func (t *triager) apply(c *client.Client, e email, completion *openai.ChatCompletion) error {
+	if len(completion.Choices) == 0 || len(completion.Choices[0].Message.ToolCalls) == 0 {
+		return fmt.Errorf("run %s did not file the email", completion.ID)
+	}
+	calls := completion.Choices[0].Message.ToolCalls
+	if len(calls) > 1 {
+		log.Printf("%q: run %s filed the email %d times, keeping the first call", e.Subject, completion.ID, len(calls))
+	}
+	call := calls[0]
+	if call.Function.Name != "file_email" {
+		return fmt.Errorf("unknown tool %s", call.Function.Name)
+	}
+	triage, err := parseTriage(call.Function.Arguments)
+	if err != nil {
+		return err
+	}
+	folder := t.folder + "/" + strings.ToUpper(triage.Category[:1]) + triage.Category[1:]
+	urgent := triage.Urgency == "high" || triage.Urgency == "critical"
+	fmt.Printf("%-40.40s %-12s %-8s -> %s\n  %s\n", e.Subject, triage.Category, triage.Urgency, folder, triage.Summary)
+	if t.dryRun {
+		return nil
+	}
+	// The email is filed first: once flagged as triaged it is not fetched
+	// again, so a failure cannot save its draft a second time at the next poll
+	if err := t.mailbox.file(c, e.UID, folder, urgent); err != nil {
+		return fmt.Errorf("filing the email: %w", err)
+	}
+	if triage.SuggestedReply != "" {
+		if err := t.mailbox.draft(c, t.drafts, e, triage.SuggestedReply); err != nil {
+			return fmt.Errorf("saving the draft: %w", err)
+		}
+	}
+	return nil
+}
+
+// parseTriage validates the arguments of a file_email call.
+func parseTriage(arguments string) (*Triage, error) {
+	if err := workflowai.ValidateJSONSchema(fileEmailSchema, json.RawMessage(arguments)); err != nil {
+		return nil, fmt.Errorf("invalid file_email call: %w", err)
+	}
+	var triage Triage
+	if err := json.Unmarshal([]byte(arguments), &triage); err != nil {
+		return nil, err
+	}
+	return &triage, nil
+}
+
+func senderDomain(from string) string {
+	address, err := mail.ParseAddress(from)
+	if err != nil {
+		return ""
+	}
+	_, domain, _ := strings.Cut(address.Address, "@")
+	return strings.ToLower(domain)
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/openai/openai-go"
+
+	"github.com/workflowai/workflowai/go/examples/workflowai"
+)
+
+// LineItem is a line of an invoice.
+type LineItem struct {
+	Description string  `json:"description"`
+	Quantity    float64 `json:"quantity" jsonschema:"minimum=0"`
+	UnitPrice   float64 `json:"unit_price"`
+	Amount      float64 `json:"amount" description:"Quantity times unit price, as printed"`
+}
+
+// Invoice is the output of the extraction agent. Its JSON schema is
+// generated by workflowai.JSONSchemaFor.
+type Invoice struct {
+	Vendor   string  `json:"vendor" description:"The name of the company issuing the invoice"`
+	Number   string  `json:"number" description:"The invoice number, as printed"`
+	Date     string  `json:"date" pattern:"^\\d{4}-\\d{2}-\\d{2}$" description:"The issue date, YYYY-MM-DD"`
+	DueDate  *string `json:"due_date" pattern:"^\\d{4}-\\d{2}-\\d{2}$" description:"The due date, YYYY-MM-DD, null when not printed"`
+	Currency string  `json:"currency" pattern:"^[A-Z]{3}$" description:"ISO 4217 code"`
+	// Total includes the taxes
+	Total     float64    `json:"total"`
+	Tax       *float64   `json:"tax" description:"The total of the taxes, null when not printed"`
+	LineItems []LineItem `json:"line_items"`
+}
+
+// check returns the inconsistencies of an invoice that the schema cannot
+// express.
+func (inv *Invoice) check() []string {
+	var problems []string
+	sum := 0.0
+	for i, item := range inv.LineItems {
+		sum += item.Amount
+		if math.Abs(item.Quantity*item.UnitPrice-item.Amount) > 0.01 {
+			problems = append(problems, fmt.Sprintf("line %d: %g x %g != %g", i+1, item.Quantity, item.UnitPrice, item.Amount))
+		}
+	}
+	if inv.Tax != nil {
+		sum += *inv.Tax
+	}
+	if len(inv.LineItems) > 0 && math.Abs(sum-inv.Total) > 0.01 {
+		problems = append(problems, fmt.Sprintf("the lines and taxes add up to %.2f, not %.2f", sum, inv.Total))
+	}
+	if inv.DueDate != nil && *inv.DueDate < inv.Date {
+		problems = append(problems, "due before the issue date")
+	}
+	return problems
+}
+
+// Extracts invoices from a directory of text files, e.g. OCRed or converted
+// from PDF, with concurrent chat completions and a strict structured output
+// generated from the Invoice struct:
+//
+//	go run ./extract -dir invoices -out invoices.csv
+//
+// Each file is a row of the CSV, with the validation failures of the file in
+// the error column. -jsonl also writes the invoices with their line items.
+func main() {
+	dir := flag.String("dir", "", "directory of .txt files (required)")
+	out := flag.String("out", "invoices.csv", "output CSV")
+	jsonl := flag.String("jsonl", "", "also write the invoices, with their line items, to this JSONL file")
+	model := flag.String("model", "invoice-extraction/gpt-4o-mini-latest", "agent and model")
+	concurrency := flag.Int("concurrency", 8, "maximum concurrent extractions")
+	flag.Parse()
+	if *dir == "" {
+		fmt.Fprintln(os.Stderr, "usage: extract -dir <directory> [-out invoices.csv]")
+		os.Exit(2)
+	}
+	files, err := filepath.Glob(filepath.Join(*dir, "*.txt"))
+	if err != nil || len(files) == 0 {
+		log.Fatalf("no .txt files in %s", *dir)
+	}
+	sort.Strings(files)
+
+	responseFormat, err := workflowai.ResponseFormatFor[Invoice]("invoice")
+	if err != nil {
+		log.Fatal(err)
+	}
+	schema, _ := workflowai.JSONSchemaFor[Invoice]()
+	params := openai.ChatCompletionNewParams{
+		Model: *model,
+		Messages: []openai.ChatCompletionMessageParamUnion{
+			openai.SystemMessage("Extract the invoice from the document. Copy the amounts as printed, do not compute missing values: use null when a value is not printed."),
+			openai.UserMessage("{{document}}"),
+		},
+		ResponseFormat: responseFormat,
+		Temperature:    openai.Float(0),
+	}
+
+	client := workflowai.NewClient()
+	ctx := context.Background()
+	log.Printf("extracting %d files", len(files))
+	rows := make(map[string]row, len(files))
+	var mu sync.Mutex
+	sem := make(chan struct{}, max(*concurrency, 1))
+	var wg sync.WaitGroup
+	for _, file := range files {
+		text, err := os.ReadFile(file)
+		if err != nil {
+			log.Fatal(err)
+		}
+		name := filepath.Base(file)
+		p := params
+		p.Metadata = map[string]string{"file": name}
+		p.SetExtraFields(map[string]any{"input": map[string]any{"document": string(text)}})
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			completion, err := client.Chat.Completions.New(ctx, p)
+			r := extract(completion, err, schema)
+			mu.Lock()
+			rows[name] = r
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	failed, err := writeOutputs(*out, *jsonl, files, rows)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("%d files extracted, %d failed, written to %s\n", len(files)-failed, failed, *out)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+type row struct {
+	invoice  *Invoice
+	problems []string
+	cost     float64
+}
+
+// extract decodes and validates the invoice of a completion.
+func extract(completion *openai.ChatCompletion, err error, schema json.RawMessage) row {
+	if err != nil {
+		return row{problems: []string{workflowai.ScrubError(err).Error()}}
+	}
+	r := row{cost: workflowai.CompletionCost(completion)}
+	if len(completion.Choices) == 0 {
+		r.problems = []string{"no output"}
+		return r
+	}
+	content := completion.Choices[0].Message.Content
+	var schemaErrors workflowai.SchemaErrors
+	if err := workflowai.ValidateJSONSchema(schema, json.RawMessage(content)); errors.As(err, &schemaErrors) {
+		for _, e := range schemaErrors {
+			r.problems = append(r.problems, e.Error())
+		}
+		return r
+	} else if err != nil {
+		r.problems = []string{err.Error()}
+		return r
+	}
+	var invoice Invoice
+	if err := json.Unmarshal([]byte(content), &invoice); err != nil {
+		r.problems = []string{err.Error()}
+		return r
+	}
+	r.invoice = &invoice
+	r.problems = invoice.check()
+	return r
+}
+
+var columns = []string{"file", "vendor", "number", "date", "due_date", "currency", "total", "tax", "line_items", "cost_usd", "error"}
+
+// writeOutputs writes a CSV row per file and reports the failures on stderr.
+// It returns the number of failed files.
+func writeOutputs(csvPath string, jsonlPath string, files []string, rows map[string]row) (int, error) {
+	f, err := os.Create(csvPath)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	w := csv.NewWriter(f)
+	w.Write(columns)
+
+	var enc *json.Encoder
+	if jsonlPath != "" {
+		jf, err := os.Create(jsonlPath)
+		if err != nil {
+			return 0, err
+		}
+		defer jf.Close()
+		enc = json.NewEncoder(jf)
+	}
+
+	failed := 0
+	for _, file := range files {
+		name := filepath.Base(file)
+		r := rows[name]
+		if len(r.problems) > 0 {
+			failed++
+			fmt.Fprintf(os.Stderr, "%s:\n  %s\n", name, strings.Join(r.problems, "\n  "))
+		}
+		record := make([]string, len(columns))
+		record[0] = name
+		if inv := r.invoice; inv != nil {
+			record[1], record[2], record[3], record[5] = inv.Vendor, inv.Number, inv.Date, inv.Currency
+			if inv.DueDate != nil {
+				record[4] = *inv.DueDate
+			}
+			record[6] = strconv.FormatFloat(inv.Total, 'f', 2, 64)
+			if inv.Tax != nil {
+				record[7] = strconv.FormatFloat(*inv.Tax, 'f', 2, 64)
+			}
+			record[8] = strconv.Itoa(len(inv.LineItems))
+			if enc != nil {
+				enc.Encode(map[string]any{"file": name, "invoice": inv, "errors": r.problems})
+			}
+		}
+		record[9] = strconv.FormatFloat(r.cost, 'f', 6, 64)
+		record[10] = strings.Join(r.problems, "; ")
+		w.Write(record)
+	}
+	w.Flush()
+	return failed, w.Error()
+}
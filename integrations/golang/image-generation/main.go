@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/openai/openai-go"
+
+	"github.com/workflowai/workflowai/go/examples/workflowai"
+)
+
+// Generates an image, saving the partial images as they are streamed, then
+// two images at once with other size and quality options. WorkflowAI does not
+// serve the image endpoints, so the images are generated with the OpenAI API
+// and OPENAI_API_KEY.
+func main() {
+	images := workflowai.NewImageService(openai.NewClient(workflowai.OpenAIClientOptions()...))
+
+	ctx := context.Background()
+
+	prompt := "A watercolor painting of a lighthouse at dawn"
+
+	print("> ")
+	println(prompt)
+
+	stream := images.GenerateStreaming(ctx, openai.ImageGenerateParams{
+		Prompt:  prompt,
+		Model:   openai.ImageModelGPTImage1,
+		Size:    openai.ImageGenerateParamsSize1024x1024,
+		Quality: openai.ImageGenerateParamsQualityMedium,
+	}, 2)
+	defer stream.Close()
+
+	for stream.Next() {
+		event := stream.Current()
+		image, err := event.Image()
+		if err != nil {
+			panic(err)
+		}
+		path := fmt.Sprintf("lighthouse-partial-%d.png", event.PartialImageIndex)
+		if event.Type == workflowai.ImageEventCompleted {
+			path = "lighthouse.png"
+		}
+		if err := os.WriteFile(path, image, 0o644); err != nil {
+			panic(err)
+		}
+		fmt.Printf("saved %s\n", path)
+	}
+	if err := stream.Err(); err != nil {
+		panic(err)
+	}
+
+	// Models without streaming support return the images at once
+	res, err := images.Generate(ctx, openai.ImageGenerateParams{
+		Prompt:  prompt + ", at night",
+		Model:   openai.ImageModelGPTImage1,
+		Size:    openai.ImageGenerateParamsSize1536x1024,
+		Quality: openai.ImageGenerateParamsQualityLow,
+		N:       openai.Int(2),
+	})
+	if err != nil {
+		panic(err)
+	}
+	paths, err := workflowai.SaveImages(ctx, res, ".", "lighthouse-night", "png")
+	if err != nil {
+		panic(err)
+	}
+	for _, path := range paths {
+		fmt.Printf("saved %s\n", path)
+	}
+}
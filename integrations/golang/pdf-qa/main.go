@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/openai/openai-go"
+
+	"github.com/workflowai/workflowai/go/examples/workflowai"
+)
+
+// Answer is the answer to one of the questions.
+type Answer struct {
+	Question string `json:"question"`
+	// Found is false when the document does not answer the question
+	Found  bool   `json:"found"`
+	Answer string `json:"answer"`
+	// Pages are the pages supporting the answer, from 1
+	Pages []int `json:"pages"`
+	// Quote is an excerpt of the document supporting the answer
+	Quote string `json:"quote"`
+}
+
+type Output struct {
+	Answers []Answer `json:"answers"`
+}
+
+var outputSchema = json.RawMessage(`{
+	"type": "object",
+	"properties": {
+		"answers": {
+			"type": "array",
+			"description": "One answer per question, in the order of the questions",
+			"items": {
+				"type": "object",
+				"properties": {
+					"question": {"type": "string"},
+					"found": {"type": "boolean", "description": "Whether the document answers the question"},
+					"answer": {"type": "string", "description": "The answer, empty when not found"},
+					"pages": {"type": "array", "items": {"type": "integer", "minimum": 1}, "description": "The pages supporting the answer"},
+					"quote": {"type": "string", "description": "A short excerpt of the document supporting the answer"}
+				},
+				"required": ["question", "found", "answer", "pages", "quote"]
+			}
+		}
+	},
+	"required": ["answers"]
+}`)
+
+// Answers questions about a PDF with a structured output. The PDF is sent
+// inline as base64, or by URL:
+//
+//	go run ./pdf-qa -pdf contract.pdf "Who are the parties?" "When does the contract end?"
+func main() {
+	pdf := flag.String("pdf", "", "path or URL of the PDF (required)")
+	model := flag.String("model", "pdf-qa/gemini-2.0-flash-001", "agent and model, which must support PDF inputs")
+	flag.Parse()
+	questions := flag.Args()
+	if *pdf == "" || len(questions) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: pdf-qa -pdf <path or URL> <question>...")
+		os.Exit(2)
+	}
+
+	client := workflowai.NewClient()
+	ctx := context.Background()
+
+	// Not all models accept PDFs: check the catalog before sending one
+	_, modelID, _ := strings.Cut(*model, "/")
+	supports, err := workflowai.FindModelCapabilities(ctx, client.Client, modelID)
+	if err != nil {
+		panic(err)
+	}
+	if !supports.Input.PDF {
+		fmt.Fprintf(os.Stderr, "%s does not support PDF inputs\n", modelID)
+		os.Exit(1)
+	}
+
+	var document openai.ChatCompletionContentPartUnionParam
+	if strings.HasPrefix(*pdf, "https://") || strings.HasPrefix(*pdf, "http://") {
+		// WorkflowAI guesses the content type from the extension of the URL, add
+		// ?content_type=application/pdf to URLs not ending with .pdf
+		document = workflowai.ImageURLPart(*pdf, "")
+	} else {
+		document, err = workflowai.DocumentFilePart(*pdf)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	var numbered strings.Builder
+	for i, q := range questions {
+		fmt.Fprintf(&numbered, "%d. %s\n", i+1, q)
+	}
+	var schema map[string]any
+	json.Unmarshal(outputSchema, &schema)
+	params := openai.ChatCompletionNewParams{
+		Model: *model,
+		Messages: []openai.ChatCompletionMessageParamUnion{
+			openai.SystemMessage("Answer the questions using only the attached document. Cite the pages and quote the passage supporting each answer. When the document does not answer a question, set found to false."),
+			openai.UserMessage([]openai.ChatCompletionContentPartUnionParam{
+				openai.TextContentPart("Questions:\n{{questions}}"),
+				document,
+			}),
+		},
+		ResponseFormat: openai.ChatCompletionNewParamsResponseFormatUnion{OfJSONSchema: &openai.ResponseFormatJSONSchemaParam{
+			JSONSchema: openai.ResponseFormatJSONSchemaJSONSchemaParam{Name: "answers", Schema: schema},
+		}},
+		Temperature: openai.Float(0),
+	}
+	params.SetExtraFields(map[string]any{"input": map[string]any{"questions": numbered.String()}})
+
+	completion, err := client.Chat.Completions.New(ctx, params)
+	if err != nil {
+		panic(err)
+	}
+	output, err := parseOutput(completion.Choices[0].Message.Content, questions)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid answer: %v\n%s\n", err, completion.Choices[0].Message.Content)
+		os.Exit(1)
+	}
+
+	for _, a := range output.Answers {
+		fmt.Printf("Q: %s\n", a.Question)
+		if !a.Found {
+			fmt.Print("A: not found in the document\n\n")
+			continue
+		}
+		fmt.Printf("A: %s\n   pages %v: %q\n\n", a.Answer, a.Pages, a.Quote)
+	}
+	fmt.Fprintf(os.Stderr, "cost: $%.6f\n", workflowai.CompletionCost(completion))
+}
+
+// parseOutput validates the output against the schema, then checks that
+// there is one answer per question.
+func parseOutput(content string, questions []string) (*Output, error) {
+	if err := workflowai.ValidateJSONSchema(outputSchema, json.RawMessage(content)); err != nil {
+		return nil, err
+	}
+	var output Output
+	if err := json.Unmarshal([]byte(content), &output); err != nil {
+		return nil, err
+	}
+	if len(output.Answers) != len(questions) {
+		return nil, fmt.Errorf("got %d answers for %d questions", len(output.Answers), len(questions))
+	}
+	for i, a := range output.Answers {
+		if a.Found && (a.Answer == "" || len(a.Pages) == 0) {
+			return nil, fmt.Errorf("answer %d: found without an answer and pages", i+1)
+		}
+		// Keep the questions as asked, the model may rephrase them
+		output.Answers[i].Question = questions[i]
+	}
+	return &output, nil
+}
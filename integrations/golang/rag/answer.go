@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/openai/openai-go"
+
+	"github.com/workflowai/workflowai/go/examples/workflowai"
+	"github.com/workflowai/workflowai/go/examples/workflowai/textsplit"
+)
+
+type citation struct {
+	// Chunk is the number of the cited chunk in the context, from 1
+	Chunk int    `json:"chunk"`
+	Quote string `json:"quote"`
+}
+
+type answer struct {
+	Answer    string     `json:"answer"`
+	Citations []citation `json:"citations"`
+}
+
+var answerSchema = json.RawMessage(`{
+	"type": "object",
+	"properties": {
+		"answer": {"type": "string", "description": "The answer, or a statement that the context does not contain it"},
+		"citations": {
+			"type": "array",
+			"description": "The chunks supporting the answer",
+			"items": {
+				"type": "object",
+				"properties": {
+					"chunk": {"type": "integer", "description": "The number of the chunk"},
+					"quote": {"type": "string", "description": "The sentence of the chunk supporting the answer"}
+				},
+				"required": ["chunk", "quote"]
+			}
+		}
+	},
+	"required": ["answer", "citations"]
+}`)
+
+// The context and the question are input variables, so that WorkflowAI
+// stores them as the input of the run and they can be reviewed separately
+const instructions = `Answer the question using only the numbered chunks of the context. Cite the chunks supporting
+the answer with a short quote. If the context does not contain the answer, say so and do not cite any chunk.
+
+Context:
+{{context}}`
+
+// ask answers a question from the retrieved chunks and returns the answer
+// with the cost of the completion.
+func ask(ctx context.Context, completions workflowai.ChatCompleter, model string, question string, chunks []chunk) (*answer, float64, error) {
+	var sources strings.Builder
+	for i, c := range chunks {
+		fmt.Fprintf(&sources, "[%d] (%s)\n%s\n\n", i+1, c.Source, c.Content)
+	}
+	var schema map[string]any
+	json.Unmarshal(answerSchema, &schema)
+	params := openai.ChatCompletionNewParams{
+		Model: model,
+		Messages: []openai.ChatCompletionMessageParamUnion{
+			openai.SystemMessage(instructions),
+			openai.UserMessage("{{question}}"),
+		},
+		ResponseFormat: openai.ChatCompletionNewParamsResponseFormatUnion{OfJSONSchema: &openai.ResponseFormatJSONSchemaParam{
+			JSONSchema: openai.ResponseFormatJSONSchemaJSONSchemaParam{Name: "answer", Schema: schema},
+		}},
+	}
+	params.SetExtraFields(map[string]any{"input": map[string]any{"question": question, "context": sources.String()}})
+	completion, err := completions.New(ctx, params)
+	if err != nil {
+		return nil, 0, err
+	}
+	output := json.RawMessage(completion.Choices[0].Message.Content)
+	if err := workflowai.ValidateJSONSchema(answerSchema, output); err != nil {
+		return nil, 0, fmt.Errorf("invalid answer: %w", err)
+	}
+	var a answer
+	if err := json.Unmarshal(output, &a); err != nil {
+		return nil, 0, err
+	}
+	// Models sometimes cite chunks that were not in the context
+	citations := a.Citations[:0]
+	for _, c := range a.Citations {
+		if c.Chunk >= 1 && c.Chunk <= len(chunks) {
+			citations = append(citations, c)
+		}
+	}
+	a.Citations = citations
+	return &a, workflowai.CompletionCost(completion), nil
+}
+
+// splitter returns the splitter of a document: Markdown documents are split
+// at headings, source files at declarations and other documents at
+// sentences.
+func splitter(path string, tokens int, overlap int) *textsplit.Splitter {
+	opt := textsplit.WithOverlap(overlap)
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".md", ".markdown":
+		return textsplit.Markdown(tokens, opt)
+	case ".go", ".py", ".js", ".ts", ".java", ".rs", ".c", ".cpp", ".rb":
+		return textsplit.Code(tokens, opt)
+	}
+	return textsplit.Sentence(tokens, opt)
+}
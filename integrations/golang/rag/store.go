@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// chunk is a part of a document, numbered from 0 in its source.
+type chunk struct {
+	Source  string
+	Index   int
+	Content string
+	// Distance is the cosine distance to the question
+	Distance float64
+}
+
+// store keeps the chunks and their embeddings in a pgvector table.
+type store struct {
+	db         *sql.DB
+	dimensions int
+}
+
+func (s *store) migrate(ctx context.Context) error {
+	for _, stmt := range []string{
+		`CREATE EXTENSION IF NOT EXISTS vector`,
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS rag_chunks (
+			source TEXT NOT NULL,
+			chunk INTEGER NOT NULL,
+			content TEXT NOT NULL,
+			embedding vector(%d) NOT NULL,
+			PRIMARY KEY (source, chunk)
+		)`, s.dimensions),
+		`CREATE INDEX IF NOT EXISTS rag_chunks_embedding ON rag_chunks USING hnsw (embedding vector_cosine_ops)`,
+	} {
+		if _, err := s.db.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("migrating: %w", err)
+		}
+	}
+	return nil
+}
+
+// replace stores the chunks of a source, replacing the chunks of a previous
+// ingestion of the source.
+func (s *store) replace(ctx context.Context, source string, chunks []string, embeddings [][]float64) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, `DELETE FROM rag_chunks WHERE source = $1`, source); err != nil {
+		return err
+	}
+	for i, content := range chunks {
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO rag_chunks (source, chunk, content, embedding) VALUES ($1, $2, $3, $4::vector)`,
+			source, i, content, vectorLiteral(embeddings[i]),
+		); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// search returns the k chunks closest to the embedding, closest first.
+func (s *store) search(ctx context.Context, embedding []float64, k int) ([]chunk, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT source, chunk, content, embedding <=> $1::vector AS distance
+		FROM rag_chunks ORDER BY distance LIMIT $2`,
+		vectorLiteral(embedding), k,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var chunks []chunk
+	for rows.Next() {
+		var c chunk
+		if err := rows.Scan(&c.Source, &c.Index, &c.Content, &c.Distance); err != nil {
+			return nil, err
+		}
+		chunks = append(chunks, c)
+	}
+	return chunks, rows.Err()
+}
+
+// vectorLiteral formats an embedding as a pgvector literal, e.g. "[0.1,0.2]".
+func vectorLiteral(embedding []float64) string {
+	parts := make([]string, len(embedding))
+	for i, v := range embedding {
+		parts[i] = strconv.FormatFloat(v, 'f', -1, 32)
+	}
+	return "[" + strings.Join(parts, ",") + "]"
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/workflowai/workflowai/go/examples/workflowai/realtime"
+)
+
+// An interactive text loop over a realtime session: each line typed is sent
+// to the model and the reply is printed as it is streamed.
+func main() {
+	ctx := context.Background()
+
+	session, err := realtime.Dial(ctx, "gpt-4o-realtime-preview")
+	if err != nil {
+		panic(err)
+	}
+	defer session.Close()
+
+	err = session.UpdateSession(ctx, realtime.SessionConfig{
+		Modalities:   []string{"text"},
+		Instructions: "You are a helpful assistant. Keep your answers short.",
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	scanner := bufio.NewScanner(os.Stdin)
+	for {
+		print("> ")
+		if !scanner.Scan() {
+			return
+		}
+		if err := session.SendText(ctx, scanner.Text()); err != nil {
+			panic(err)
+		}
+
+		// Print the reply until the response is done
+		for done := false; !done; {
+			event, err := session.Read(ctx)
+			if err != nil {
+				panic(err)
+			}
+			switch event.Type {
+			case realtime.EventResponseTextDelta:
+				fmt.Print(event.Delta)
+			case realtime.EventError:
+				fmt.Println(event.Error)
+				done = true
+			case realtime.EventResponseDone:
+				fmt.Println()
+				done = true
+			}
+		}
+	}
+}
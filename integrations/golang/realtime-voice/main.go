@@ -0,0 +1,282 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/workflowai/workflowai/go/examples/workflowai/realtime"
+)
+
+// A voice conversation over a realtime session. The user audio is read from
+// a WAV file or from stdin, e.g. captured from the microphone with sox:
+//
+//	sox -d -t raw -r 24000 -e signed -b 16 -c 1 - | go run ./realtime-voice -input -
+//
+// The replies are played with -play, a command reading raw audio from its
+// stdin, and saved to -output. When the user speaks over a reply, the reply
+// is interrupted (barge-in).
+func main() {
+	input := flag.String("input", "", `WAV file with the user audio, or "-" for raw 16-bit PCM at 24kHz on stdin`)
+	play := flag.String("play", "play -q -t raw -r 24000 -e signed -b 16 -c 1 -", "command playing raw audio from its stdin, empty to disable playback")
+	output := flag.String("output", "", "WAV file the replies are saved to")
+	model := flag.String("model", "gpt-4o-realtime-preview", "realtime model")
+	flag.Parse()
+	if *input == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	var audio io.ReadCloser = os.Stdin
+	if *input != "-" {
+		f, err := openWAV(*input)
+		if err != nil {
+			panic(err)
+		}
+		audio = f
+	}
+	defer audio.Close()
+
+	session, err := realtime.Dial(ctx, *model)
+	if err != nil {
+		panic(err)
+	}
+	defer session.Close()
+
+	err = session.UpdateSession(ctx, realtime.SessionConfig{
+		Modalities:         []string{"text", "audio"},
+		Instructions:       "You are a friendly voice assistant. Keep your answers short.",
+		Voice:              "alloy",
+		InputAudioFormat:   realtime.AudioPCM16,
+		OutputAudioFormat:  realtime.AudioPCM16,
+		TurnDetection:      &realtime.TurnDetection{Type: "server_vad", SilenceDurationMS: 500},
+		InputTranscription: &realtime.InputTranscription{Model: "whisper-1"},
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	speaker := &player{command: *play}
+	defer speaker.Interrupt()
+	var recording *wavWriter
+	if *output != "" {
+		if recording, err = createWAV(*output); err != nil {
+			panic(err)
+		}
+		defer recording.Close()
+	}
+
+	// The audio is streamed up while the replies are streamed back
+	inputDone := make(chan error, 1)
+	go func() {
+		inputDone <- streamAudio(ctx, session, audio, *input != "-")
+	}()
+
+	finished := false
+	responding := false
+	for {
+		select {
+		case err := <-inputDone:
+			if err != nil {
+				panic(err)
+			}
+			// The conversation ends with the reply to the end of the file
+			finished = true
+			inputDone = nil
+		default:
+		}
+
+		event, err := session.Read(ctx)
+		if errors.Is(err, context.Canceled) {
+			return
+		}
+		if err != nil {
+			panic(err)
+		}
+
+		switch event.Type {
+		case realtime.EventSpeechStarted:
+			if responding {
+				// Barge-in: stop the reply and drop the part that was not
+				// heard from the conversation
+				fmt.Println(" [interrupted]")
+				if err := session.CancelResponse(ctx); err != nil {
+					panic(err)
+				}
+				itemID, playedMS := speaker.Interrupt()
+				if itemID != "" {
+					err := session.Send(ctx, map[string]any{
+						"type":          "conversation.item.truncate",
+						"item_id":       itemID,
+						"content_index": 0,
+						"audio_end_ms":  playedMS,
+					})
+					if err != nil {
+						panic(err)
+					}
+				}
+				responding = false
+			}
+		case realtime.EventInputTranscriptDone:
+			fmt.Printf("you: %s\n", strings.TrimSpace(event.Transcript))
+		case realtime.EventResponseCreated:
+			responding = true
+			fmt.Print("assistant: ")
+		case realtime.EventResponseTranscriptDelta:
+			fmt.Print(event.Delta)
+		case realtime.EventResponseAudioDelta:
+			chunk, err := event.Audio()
+			if err != nil {
+				panic(err)
+			}
+			speaker.Write(event.ItemID, chunk)
+			if recording != nil {
+				if _, err := recording.Write(chunk); err != nil {
+					panic(err)
+				}
+			}
+		case realtime.EventResponseDone:
+			if responding {
+				fmt.Println()
+			}
+			responding = false
+			speaker.Done()
+			if finished {
+				speaker.Wait()
+				return
+			}
+		case realtime.EventError:
+			fmt.Fprintln(os.Stderr, event.Error)
+		}
+	}
+}
+
+// streamAudio sends the audio in chunks of 100ms. Files are sent at the pace
+// they would be spoken, followed by silence so that the server detects the
+// end of the turn.
+func streamAudio(ctx context.Context, session *realtime.Session, audio io.Reader, paced bool) error {
+	chunk := make([]byte, 100*bytesPerMS)
+	for {
+		n, err := io.ReadFull(audio, chunk)
+		if n > 0 {
+			if err := session.AppendAudio(ctx, chunk[:n]); err != nil {
+				return err
+			}
+			if paced {
+				time.Sleep(100 * time.Millisecond)
+			}
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return session.AppendAudio(ctx, make([]byte, 1000*bytesPerMS))
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// player plays the replies with an external command, started for each reply
+// and killed when the reply is interrupted. It tracks how much of the reply
+// was played to truncate the conversation.
+type player struct {
+	command string
+
+	mu    sync.Mutex
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+	// finishing is the command playing the end of the previous reply
+	finishing *exec.Cmd
+	itemID    string
+	started   time.Time
+	written   int
+}
+
+func (p *player) Write(itemID string, pcm []byte) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.itemID != itemID {
+		p.itemID, p.started, p.written = itemID, time.Now(), 0
+	}
+	p.written += len(pcm)
+	if p.command == "" {
+		return
+	}
+	if p.cmd == nil {
+		// A new reply follows a new turn of the user, who no longer listens
+		// to the end of the previous one
+		p.stopFinishing()
+		args := strings.Fields(p.command)
+		p.cmd = exec.Command(args[0], args[1:]...)
+		stdin, err := p.cmd.StdinPipe()
+		if err == nil {
+			err = p.cmd.Start()
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "playback disabled: %v\n", err)
+			p.command, p.cmd = "", nil
+			return
+		}
+		p.stdin = stdin
+	}
+	_, _ = p.stdin.Write(pcm)
+}
+
+// Done lets the current reply finish playing.
+func (p *player) Done() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.cmd != nil {
+		p.stdin.Close()
+		p.finishing, p.cmd = p.cmd, nil
+	}
+	p.itemID = ""
+}
+
+// Wait waits for the end of the playback of the last reply.
+func (p *player) Wait() {
+	p.mu.Lock()
+	cmd := p.finishing
+	p.finishing = nil
+	p.mu.Unlock()
+	if cmd != nil {
+		_ = cmd.Wait()
+	}
+}
+
+// Interrupt stops the current reply and returns its item and the duration
+// that was played, in milliseconds.
+func (p *player) Interrupt() (string, int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.cmd != nil {
+		_ = p.cmd.Process.Kill()
+		go p.cmd.Wait()
+		p.cmd = nil
+	}
+	p.stopFinishing()
+	itemID := p.itemID
+	// Audio arrives faster than it is played, so the elapsed time is the
+	// played duration, bounded by the audio received
+	playedMS := min(int(time.Since(p.started).Milliseconds()), p.written/bytesPerMS)
+	p.itemID = ""
+	return itemID, playedMS
+}
+
+func (p *player) stopFinishing() {
+	if p.finishing != nil {
+		_ = p.finishing.Process.Kill()
+		go p.finishing.Wait()
+		p.finishing = nil
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// The realtime API uses 16-bit PCM at 24kHz, mono.
+const (
+	sampleRate     = 24000
+	bytesPerSample = 2
+	bytesPerMS     = sampleRate * bytesPerSample / 1000
+)
+
+// openWAV opens a WAV file and returns a reader positioned at the start of
+// its samples, after checking that they are in the realtime format.
+func openWAV(path string) (io.ReadCloser, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := seekSamples(f); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return f, nil
+}
+
+func seekSamples(r io.ReadSeeker) error {
+	var header [12]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return err
+	}
+	if string(header[0:4]) != "RIFF" || string(header[8:12]) != "WAVE" {
+		return errors.New("not a WAV file")
+	}
+	for {
+		var chunk [8]byte
+		if _, err := io.ReadFull(r, chunk[:]); err != nil {
+			return errors.New("no data chunk")
+		}
+		size := int64(binary.LittleEndian.Uint32(chunk[4:8]))
+		switch string(chunk[0:4]) {
+		case "fmt ":
+			var format [16]byte
+			if _, err := io.ReadFull(r, format[:]); err != nil {
+				return err
+			}
+			channels := binary.LittleEndian.Uint16(format[2:4])
+			rate := binary.LittleEndian.Uint32(format[4:8])
+			bits := binary.LittleEndian.Uint16(format[14:16])
+			if channels != 1 || rate != sampleRate || bits != 16 {
+				return fmt.Errorf("expected 16-bit mono audio at %dHz, got %d-bit, %d channels at %dHz", sampleRate, bits, channels, rate)
+			}
+			if _, err := r.Seek(size-16+size%2, io.SeekCurrent); err != nil {
+				return err
+			}
+		case "data":
+			return nil
+		default:
+			if _, err := r.Seek(size+size%2, io.SeekCurrent); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// wavWriter writes the replies to a WAV file. The sizes of the header are
+// written on Close.
+type wavWriter struct {
+	f    *os.File
+	size uint32
+}
+
+func createWAV(path string) (*wavWriter, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	w := &wavWriter{f: f}
+	if err := w.writeHeader(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	// The samples follow the header, which WriteAt does not skip
+	if _, err := f.Seek(44, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *wavWriter) writeHeader() error {
+	header := make([]byte, 44)
+	copy(header[0:], "RIFF")
+	binary.LittleEndian.PutUint32(header[4:], 36+w.size)
+	copy(header[8:], "WAVEfmt ")
+	binary.LittleEndian.PutUint32(header[16:], 16)
+	binary.LittleEndian.PutUint16(header[20:], 1) // PCM
+	binary.LittleEndian.PutUint16(header[22:], 1) // mono
+	binary.LittleEndian.PutUint32(header[24:], sampleRate)
+	binary.LittleEndian.PutUint32(header[28:], sampleRate*bytesPerSample)
+	binary.LittleEndian.PutUint16(header[32:], bytesPerSample)
+	binary.LittleEndian.PutUint16(header[34:], 16)
+	copy(header[36:], "data")
+	binary.LittleEndian.PutUint32(header[40:], w.size)
+	_, err := w.f.WriteAt(header, 0)
+	return err
+}
+
+func (w *wavWriter) Write(p []byte) (int, error) {
+	n, err := w.f.Write(p)
+	w.size += uint32(n)
+	return n, err
+}
+
+func (w *wavWriter) Close() error {
+	if err := w.writeHeader(); err != nil {
+		w.f.Close()
+		return err
+	}
+	return w.f.Close()
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/shared"
+
+	"github.com/workflowai/workflowai/go/examples/workflowai"
+)
+
+// editInterval is the minimum interval between two edits of a streamed
+// reply, below the rate limit of chat.update.
+const editInterval = time.Second
+
+// Action IDs of the feedback buttons
+const (
+	actionPositive = "feedback_positive"
+	actionNegative = "feedback_negative"
+)
+
+var mentionPattern = regexp.MustCompile(`<@[A-Z0-9]+>\s*`)
+
+type bot struct {
+	slack         *slackAPI
+	client        workflowai.Client
+	store         workflowai.ConversationStore
+	model         string
+	instructions  string
+	maxMessages   int
+	signingSecret string
+
+	mu   sync.Mutex
+	seen map[string]time.Time
+	// channels serializes the replies of each channel, so that concurrent
+	// messages do not interleave in the history
+	channels map[string]*sync.Mutex
+}
+
+// slackEvent is the part of the Events API payloads read by the bot.
+type slackEvent struct {
+	Type      string `json:"type"`
+	Challenge string `json:"challenge"`
+	EventID   string `json:"event_id"`
+	TeamID    string `json:"team_id"`
+	Event     struct {
+		Type        string `json:"type"`
+		User        string `json:"user"`
+		BotID       string `json:"bot_id"`
+		Text        string `json:"text"`
+		Channel     string `json:"channel"`
+		ChannelType string `json:"channel_type"`
+		TS          string `json:"ts"`
+		ThreadTS    string `json:"thread_ts"`
+	} `json:"event"`
+}
+
+// handleEvents receives the mentions of the bot and its direct messages.
+func (b *bot) handleEvents(w http.ResponseWriter, r *http.Request) {
+	body, ok := b.readSigned(w, r)
+	if !ok {
+		return
+	}
+	var payload slackEvent
+	if err := json.Unmarshal(body, &payload); err != nil {
+		http.Error(w, "invalid payload", http.StatusBadRequest)
+		return
+	}
+	if payload.Type == "url_verification" {
+		w.Header().Set("Content-Type", "text/plain")
+		io.WriteString(w, payload.Challenge)
+		return
+	}
+
+	// Slack retries the events that are not acknowledged within 3 seconds:
+	// acknowledge first and reply in the background
+	w.WriteHeader(http.StatusOK)
+	e := payload.Event
+	isMessage := e.Type == "app_mention" || (e.Type == "message" && e.ChannelType == "im")
+	if payload.Type != "event_callback" || !isMessage || e.BotID != "" || e.User == "" || !b.firstDelivery(payload.EventID) {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+		defer cancel()
+		if err := b.reply(ctx, payload.TeamID, e.User, e.Channel, e.ThreadTS, strings.TrimSpace(mentionPattern.ReplaceAllString(e.Text, ""))); err != nil {
+			log.Printf("replying in %s: %v", e.Channel, workflowai.ScrubError(err))
+		}
+	}()
+}
+
+// firstDelivery returns false for the events already received.
+func (b *bot) firstDelivery(eventID string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for id, at := range b.seen {
+		if time.Since(at) > time.Hour {
+			delete(b.seen, id)
+		}
+	}
+	if _, ok := b.seen[eventID]; ok {
+		return false
+	}
+	b.seen[eventID] = time.Now()
+	return true
+}
+
+func (b *bot) channelLock(channel string) *sync.Mutex {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	lock, ok := b.channels[channel]
+	if !ok {
+		lock = &sync.Mutex{}
+		b.channels[channel] = lock
+	}
+	return lock
+}
+
+// reply streams the answer to a message, editing a placeholder message as the
+// chunks arrive, then adds the feedback buttons.
+func (b *bot) reply(ctx context.Context, team string, user string, channel string, threadTS string, text string) error {
+	lock := b.channelLock(channel)
+	lock.Lock()
+	defer lock.Unlock()
+
+	ts, err := b.slack.postMessage(ctx, channel, threadTS, "_Thinking…_")
+	if err != nil {
+		return err
+	}
+	history, err := b.store.Load(ctx, channel)
+	if err != nil {
+		return err
+	}
+	message := openai.UserMessage(text)
+	params := openai.ChatCompletionNewParams{
+		Model:    b.model,
+		Messages: append(append([]openai.ChatCompletionMessageParamUnion{openai.SystemMessage(b.instructions)}, history...), message),
+		// The runs can be searched by Slack user and channel in WorkflowAI
+		Metadata: shared.Metadata{"slack_user": user, "slack_channel": channel, "slack_team": team},
+	}
+
+	stream := b.client.Chat.Completions.NewStreaming(ctx, params)
+	defer stream.Close()
+	var (
+		content  strings.Builder
+		token    string
+		lastEdit time.Time
+	)
+	for stream.Next() {
+		chunk := stream.Current()
+		if len(chunk.Choices) > 0 {
+			content.WriteString(chunk.Choices[0].Delta.Content)
+		}
+		if t := workflowai.ChunkFeedbackToken(chunk); t != "" {
+			token = t
+		}
+		if time.Since(lastEdit) >= editInterval && content.Len() > 0 {
+			lastEdit = time.Now()
+			if err := b.slack.updateMessage(ctx, channel, ts, content.String()+" …", nil); err != nil {
+				log.Printf("updating the reply: %v", err)
+			}
+		}
+	}
+	if err := stream.Err(); err != nil {
+		b.slack.updateMessage(ctx, channel, ts, "Sorry, something went wrong. Please try again.", nil)
+		return err
+	}
+
+	if err := b.slack.updateMessage(ctx, channel, ts, content.String(), replyBlocks(content.String(), token)); err != nil {
+		return err
+	}
+	if err := b.store.Append(ctx, channel, message, openai.AssistantMessage(content.String())); err != nil {
+		return err
+	}
+	if b.maxMessages > 0 {
+		return b.store.Trim(ctx, channel, b.maxMessages)
+	}
+	return nil
+}
+
+// replyBlocks returns the blocks of a reply with its feedback buttons, whose
+// value is the feedback token of the run.
+func replyBlocks(text string, token string) []any {
+	blocks := []any{map[string]any{"type": "section", "text": map[string]any{"type": "mrkdwn", "text": text}}}
+	if token == "" {
+		return blocks
+	}
+	button := func(id string, label string) map[string]any {
+		return map[string]any{"type": "button", "action_id": id, "value": token, "text": map[string]any{"type": "plain_text", "text": label, "emoji": true}}
+	}
+	return append(blocks, map[string]any{
+		"type":     "actions",
+		"elements": []any{button(actionPositive, ":thumbsup:"), button(actionNegative, ":thumbsdown:")},
+	})
+}
+
+// handleInteractions receives the clicks on the feedback buttons and posts
+// the feedback of the Slack user to WorkflowAI.
+func (b *bot) handleInteractions(w http.ResponseWriter, r *http.Request) {
+	body, ok := b.readSigned(w, r)
+	if !ok {
+		return
+	}
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		http.Error(w, "invalid form", http.StatusBadRequest)
+		return
+	}
+	var payload struct {
+		Type        string `json:"type"`
+		ResponseURL string `json:"response_url"`
+		User        struct {
+			ID string `json:"id"`
+		} `json:"user"`
+		Actions []struct {
+			ActionID string `json:"action_id"`
+			Value    string `json:"value"`
+		} `json:"actions"`
+	}
+	if err := json.Unmarshal([]byte(form.Get("payload")), &payload); err != nil {
+		http.Error(w, "invalid payload", http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	if payload.Type != "block_actions" {
+		return
+	}
+	for _, action := range payload.Actions {
+		outcome := map[string]string{actionPositive: workflowai.FeedbackPositive, actionNegative: workflowai.FeedbackNegative}[action.ActionID]
+		if outcome == "" {
+			continue
+		}
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			// A new feedback of the same user replaces the previous one
+			err := b.client.Feedback.Create(ctx, workflowai.FeedbackParams{Token: action.Value, Outcome: outcome, UserID: payload.User.ID})
+			reply := "Thanks for your feedback!"
+			if err != nil {
+				log.Printf("posting feedback: %v", workflowai.ScrubError(err))
+				reply = "Sorry, your feedback could not be saved."
+			}
+			if err := b.slack.respond(ctx, payload.ResponseURL, reply); err != nil {
+				log.Printf("responding to the interaction: %v", err)
+			}
+		}()
+	}
+}
+
+// readSigned reads the body of a request signed by Slack, answering with an
+// error when the signature is invalid.
+func (b *bot) readSigned(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 1<<20))
+	if err != nil {
+		http.Error(w, "invalid body", http.StatusBadRequest)
+		return nil, false
+	}
+	if err := verifySignature(r.Header, body, b.signingSecret, time.Now()); err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return nil, false
+	}
+	return body, true
+}
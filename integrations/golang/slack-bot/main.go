@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/openai/openai-go/option"
+
+	"github.com/workflowai/workflowai/go/examples/workflowai"
+	"github.com/workflowai/workflowai/go/examples/workflowai/vaultsecrets"
+)
+
+// A Slack bot answering the mentions of the bot and its direct messages with
+// an agent. The replies are streamed by editing the message as the chunks
+// arrive, the history is kept per channel, and the thumbs up and down buttons
+// of each reply post the feedback of the Slack user to WorkflowAI.
+//
+// Create a Slack app with the app_mention and message.im bot events sent to
+// /slack/events, interactivity sent to /slack/interactions, and the chat:write
+// scope, then:
+//
+//	export SLACK_BOT_TOKEN=xoxb-... SLACK_SIGNING_SECRET=... WORKFLOWAI_API_KEY=...
+//	go run ./slack-bot -addr :3000
+//
+// In production, store the same keys in a Vault secret and pass its path with
+// -vault, VAULT_ADDR and VAULT_TOKEN instead. The WorkflowAI API key is then
+// renewed when it is rotated.
+func main() {
+	addr := flag.String("addr", ":3000", "listen address")
+	model := flag.String("model", "slack-assistant/gpt-4o-mini-latest", "agent and model answering the messages")
+	instructions := flag.String("instructions", "You are a helpful assistant in a Slack workspace. Answer concisely, using Slack markdown.", "system prompt")
+	maxMessages := flag.Int("max-messages", 40, "messages of history kept per channel")
+	vault := flag.String("vault", "", "Vault path of the keys, e.g. slack-bot/production, read from the environment otherwise")
+	flag.Parse()
+
+	secrets, err := loadSecrets(context.Background(), *vault)
+	if err != nil {
+		log.Fatal(err)
+	}
+	token := secret(secrets, "SLACK_BOT_TOKEN")
+
+	b := &bot{
+		slack:  &slackAPI{token: token, baseURL: "https://slack.com/api", httpClient: &http.Client{Timeout: 10 * time.Second}},
+		client: workflowai.NewClient(option.WithMiddleware(workflowai.APIKeyMiddleware(secrets.Key("WORKFLOWAI_API_KEY")))),
+		// In-process history, use workflowai/redisstore to share it between
+		// replicas
+		store:         workflowai.NewMemoryConversationStore(),
+		model:         *model,
+		instructions:  *instructions,
+		maxMessages:   *maxMessages,
+		signingSecret: secret(secrets, "SLACK_SIGNING_SECRET"),
+		seen:          map[string]time.Time{},
+		channels:      map[string]*sync.Mutex{},
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /slack/events", b.handleEvents)
+	mux.HandleFunc("POST /slack/interactions", b.handleInteractions)
+	log.Printf("listening on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, mux))
+}
+
+// loadSecrets loads the keys of the bot from the Vault secret at path, or
+// from the environment when path is empty.
+func loadSecrets(ctx context.Context, path string) (*workflowai.Secrets, error) {
+	if path != "" {
+		return vaultsecrets.New(ctx, path)
+	}
+	return workflowai.NewSecrets(ctx, workflowai.EnvSecrets("SLACK_BOT_TOKEN", "SLACK_SIGNING_SECRET", "WORKFLOWAI_API_KEY"), 0)
+}
+
+func secret(secrets *workflowai.Secrets, name string) string {
+	value, err := secrets.Get(context.Background(), name, false)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return value
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// slackAPI calls the methods of the Slack Web API used by the bot.
+type slackAPI struct {
+	token      string
+	baseURL    string
+	httpClient *http.Client
+}
+
+// call posts a JSON body to a Web API method and decodes the response into
+// out, which can be nil.
+func (s *slackAPI) call(ctx context.Context, method string, body any, out any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.baseURL+"/"+method, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("Authorization", "Bearer "+s.token)
+	res, err := s.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	raw, err := io.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	var status struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(raw, &status); err != nil {
+		return fmt.Errorf("slack %s: %s: %w", method, res.Status, err)
+	}
+	if !status.OK {
+		return fmt.Errorf("slack %s: %s", method, status.Error)
+	}
+	if out != nil {
+		return json.Unmarshal(raw, out)
+	}
+	return nil
+}
+
+// postMessage posts a message and returns its timestamp, which identifies it
+// in the channel.
+func (s *slackAPI) postMessage(ctx context.Context, channel string, threadTS string, text string) (string, error) {
+	var res struct {
+		TS string `json:"ts"`
+	}
+	body := map[string]any{"channel": channel, "text": text}
+	if threadTS != "" {
+		body["thread_ts"] = threadTS
+	}
+	err := s.call(ctx, "chat.postMessage", body, &res)
+	return res.TS, err
+}
+
+// updateMessage replaces the text and the blocks of a message.
+func (s *slackAPI) updateMessage(ctx context.Context, channel string, ts string, text string, blocks []any) error {
+	body := map[string]any{"channel": channel, "ts": ts, "text": text}
+	if blocks != nil {
+		body["blocks"] = blocks
+	}
+	return s.call(ctx, "chat.update", body, nil)
+}
+
+// respond posts an ephemeral reply to an interaction, through its response
+// URL.
+func (s *slackAPI) respond(ctx context.Context, responseURL string, text string) error {
+	data, _ := json.Marshal(map[string]any{"response_type": "ephemeral", "replace_original": false, "text": text})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, responseURL, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	res, err := s.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	return nil
+}
+
+// maxRequestAge is the age after which a signed request is rejected, to
+// prevent replays.
+const maxRequestAge = 5 * time.Minute
+
+// verifySignature checks the signature Slack adds to the requests it sends,
+// computed with the signing secret of the app.
+func verifySignature(header http.Header, body []byte, secret string, now time.Time) error {
+	timestamp := header.Get("X-Slack-Request-Timestamp")
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return errors.New("missing request timestamp")
+	}
+	if math.Abs(now.Sub(time.Unix(seconds, 0)).Seconds()) > maxRequestAge.Seconds() {
+		return errors.New("request timestamp too old")
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "v0:%s:%s", timestamp, body)
+	want := "v0=" + hex.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(want), []byte(header.Get("X-Slack-Signature"))) {
+		return errors.New("invalid signature")
+	}
+	return nil
+}
@@ -0,0 +1,284 @@
+package workflowai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"strings"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+	"github.com/openai/openai-go/shared"
+)
+
+// Agent runs an agent with a typed input and output. The input is sent as
+// the WorkflowAI input, rendering the {{variables}} of the instructions and
+// the prompt, and the output is a strict structured output generated from O
+// by [JSONSchemaFor]. Agents whose O is string return the text of the reply.
+//
+//	type Input struct {
+//		Review string `json:"review"`
+//	}
+//	agent, err := workflowai.NewAgent[Input, Sentiment](&client.Chat.Completions,
+//		"sentiment", "gpt-4o-mini-latest", "Classify the sentiment of the review: {{review}}")
+//	run, err := agent.Run(ctx, Input{Review: "Great"})
+//	fmt.Println(run.Output.Label)
+type Agent[I, O any] struct {
+	// ID is the agent ID, e.g. "sentiment"
+	ID string
+	// Model is the model, e.g. "gpt-4o-mini-latest", or a deployment of the
+	// agent, e.g. "#1/production"
+	Model        string
+	Instructions string
+	// Prompt is the user message, none when empty
+	Prompt string
+	// Params holds the other parameters of the runs, e.g. Temperature
+	Params openai.ChatCompletionNewParams
+	// Tools are the tools the model can call before answering, e.g. other
+	// agents, see [Agent.AsTool]
+	Tools []Tool
+	// MaxToolRounds is the maximum number of completions calling tools in a
+	// run, 10 when zero. The completion following the last round cannot call
+	// tools, for the model to answer with their results
+	MaxToolRounds int
+	// RepairAttempts is the number of times an output that does not match
+	// the schema is sent back to the model with the validation errors, 1 when
+	// zero and none when negative
+	RepairAttempts int
+	// LenientJSON repairs near valid outputs with [RepairJSON] before they
+	// are validated, e.g. wrapped in a markdown code block
+	LenientJSON bool
+
+	completions ChatCompleter
+	schema      json.RawMessage
+	format      openai.ChatCompletionNewParamsResponseFormatUnion
+}
+
+// NewAgent returns an agent, or an error when O has no JSON schema.
+func NewAgent[I, O any](completions ChatCompleter, id string, model string, instructions string) (*Agent[I, O], error) {
+	a := &Agent[I, O]{ID: id, Model: model, Instructions: instructions, completions: completions}
+	var output O
+	if _, ok := any(output).(string); ok {
+		return a, nil
+	}
+	var err error
+	if a.schema, err = JSONSchemaFor[O](); err != nil {
+		return nil, err
+	}
+	a.format, _ = ResponseFormatFor[O](id)
+	return a, nil
+}
+
+// InputSchema returns the JSON schema of the input of the agent.
+func (a *Agent[I, O]) InputSchema() (json.RawMessage, error) {
+	return JSONSchemaFor[I]()
+}
+
+// OutputSchema returns the JSON schema of the output of the agent, nil when
+// the output is text.
+func (a *Agent[I, O]) OutputSchema() json.RawMessage {
+	return a.schema
+}
+
+// AgentRun is a successful run of an [Agent].
+type AgentRun[O any] struct {
+	Output O
+	// Completion is the completion of the output
+	Completion *openai.ChatCompletion
+	// Completions are all the completions of the run, the ones calling tools
+	// then Completion
+	Completions []*openai.ChatCompletion
+	// JSONRepaired reports whether the output was repaired by
+	// [Agent.LenientJSON]
+	JSONRepaired bool
+}
+
+// RunID returns the ID of the run of the output.
+func (r *AgentRun[O]) RunID() string {
+	_, runID := splitCompletionID(r.Completion.ID)
+	return runID
+}
+
+// RunInfo returns the run, version and agent of the output.
+func (r *AgentRun[O]) RunInfo() RunInfo {
+	return CompletionRunInfo(r.Completion)
+}
+
+// CostUSD returns the cost of the completions of the run. The runs of the
+// agents called as tools are not included.
+func (r *AgentRun[O]) CostUSD() float64 {
+	total := 0.0
+	for _, completion := range r.Completions {
+		total += CompletionCost(completion)
+	}
+	return total
+}
+
+// Run runs the agent. The input is validated first when it has a Validate
+// method, e.g. generated by workflowai/codegen, and the output is validated
+// against its schema. The metadata of ctx, see [ContextWithRunMetadata], is
+// added to the run. When the model calls tools, they are run concurrently and
+// their results are sent back until the model answers. An invalid output is
+// sent back with its validation errors, up to RepairAttempts times, before
+// failing with an [*OutputError].
+func (a *Agent[I, O]) Run(ctx context.Context, input I, opts ...option.RequestOption) (*AgentRun[O], error) {
+	if v, ok := any(&input).(interface{ Validate() error }); ok {
+		if err := v.Validate(); err != nil {
+			return nil, fmt.Errorf("workflowai: invalid input of %s: %w", a.ID, err)
+		}
+	}
+	params := a.params(ctx, input)
+	repairs := a.RepairAttempts
+	if repairs == 0 {
+		repairs = defaultRepairAttempts
+	}
+	run := &AgentRun[O]{}
+	for attempt := 1; ; attempt++ {
+		if err := a.complete(ctx, &params, run, opts); err != nil {
+			return nil, err
+		}
+		content := run.Completion.Choices[0].Message.Content
+		if a.schema == nil {
+			*any(&run.Output).(*string) = content
+			return run, nil
+		}
+		if a.LenientJSON {
+			if fixed, repaired, err := RepairJSON([]byte(content)); err == nil {
+				content, run.JSONRepaired = string(fixed), repaired
+			}
+		}
+		err := ValidateJSONSchema(a.schema, json.RawMessage(content))
+		if err == nil {
+			if err = json.Unmarshal([]byte(content), &run.Output); err == nil {
+				return run, nil
+			}
+		}
+		if attempt > repairs {
+			return nil, &OutputError{RunID: run.RunID(), CostUSD: run.CostUSD(), Content: content, Attempts: attempt, Err: err}
+		}
+		params.Messages = append(params.Messages, run.Completion.Choices[0].Message.ToParam(), openai.UserMessage(repairPrompt(err)))
+	}
+}
+
+// complete runs the completions of params until the model answers without
+// calling tools.
+func (a *Agent[I, O]) complete(ctx context.Context, params *openai.ChatCompletionNewParams, run *AgentRun[O], opts []option.RequestOption) error {
+	maxRounds := a.MaxToolRounds
+	if maxRounds <= 0 {
+		maxRounds = defaultMaxToolRounds
+	}
+	for round := 0; ; round++ {
+		request := *params
+		if round == maxRounds {
+			request.ToolChoice = openai.ChatCompletionToolChoiceOptionUnionParam{OfAuto: openai.String(string(openai.ChatCompletionToolChoiceOptionAutoNone))}
+		}
+		completion, err := a.completions.New(ctx, request, opts...)
+		if err != nil {
+			return err
+		}
+		if len(completion.Choices) == 0 {
+			return errors.New("workflowai: completion has no choices")
+		}
+		run.Completion = completion
+		run.Completions = append(run.Completions, completion)
+		if len(completion.Choices[0].Message.ToolCalls) == 0 {
+			return nil
+		}
+		if round == maxRounds {
+			return fmt.Errorf("workflowai: %s did not answer after %d rounds of tool calls", a.ID, maxRounds)
+		}
+		messages, err := callTools(ctx, a.ID, a.Tools, completion)
+		if err != nil {
+			return err
+		}
+		params.Messages = append(params.Messages, messages...)
+	}
+}
+
+const defaultRepairAttempts = 1
+
+// repairPrompt asks the model to correct an output, from its validation
+// errors.
+func repairPrompt(err error) string {
+	var b strings.Builder
+	b.WriteString("Your answer does not match the output schema:\n")
+	var schemaErrs SchemaErrors
+	if errors.As(err, &schemaErrs) {
+		for _, e := range schemaErrs {
+			fmt.Fprintf(&b, "- %s\n", e)
+		}
+	} else {
+		fmt.Fprintf(&b, "- %v\n", err)
+	}
+	b.WriteString("\nAnswer again with the corrected output only.")
+	return b.String()
+}
+
+func (a *Agent[I, O]) params(ctx context.Context, input I) openai.ChatCompletionNewParams {
+	params := a.Params
+	params.Model = a.ID + "/" + a.Model
+	params.Messages = nil
+	if a.Instructions != "" {
+		params.Messages = append(params.Messages, openai.SystemMessage(a.Instructions))
+	}
+	if a.Prompt != "" {
+		params.Messages = append(params.Messages, openai.UserMessage(a.Prompt))
+	}
+	if a.schema != nil {
+		params.ResponseFormat = a.format
+	}
+	params.Tools = append([]openai.ChatCompletionToolParam(nil), a.Params.Tools...)
+	for _, tool := range a.Tools {
+		params.Tools = append(params.Tools, tool.param())
+	}
+	if metadata := RunMetadata(ctx); len(metadata) > 0 {
+		maps.Copy(metadata, a.Params.Metadata)
+		params.Metadata = metadata
+	}
+	extra := map[string]any{"input": input}
+	maps.Copy(extra, a.Params.ExtraFields())
+	params.SetExtraFields(extra)
+	return params
+}
+
+// OutputError is returned by [Agent.Run] when the output of the run does not
+// match the schema of the agent, after the repair attempts.
+type OutputError struct {
+	// RunID and Content are the ones of the last output
+	RunID string
+	// CostUSD is the cost of all the completions of the run
+	CostUSD float64
+	Content string
+	// Attempts is the number of invalid outputs, the first one included
+	Attempts int
+	Err      error
+}
+
+func (e *OutputError) Error() string {
+	return fmt.Sprintf("workflowai: invalid output of run %s: %v", e.RunID, e.Err)
+}
+
+func (e *OutputError) Unwrap() error {
+	return e.Err
+}
+
+type runMetadataKey struct{}
+
+// ContextWithRunMetadata returns a context whose agent runs are tagged with
+// metadata, in addition to the metadata already set on ctx.
+func ContextWithRunMetadata(ctx context.Context, metadata map[string]string) context.Context {
+	merged := RunMetadata(ctx)
+	maps.Copy(merged, metadata)
+	return context.WithValue(ctx, runMetadataKey{}, shared.Metadata(merged))
+}
+
+// RunMetadata returns the metadata set with [ContextWithRunMetadata].
+func RunMetadata(ctx context.Context) map[string]string {
+	metadata, _ := ctx.Value(runMetadataKey{}).(shared.Metadata)
+	// Copied so that changes do not leak across contexts
+	copied := make(map[string]string, len(metadata))
+	maps.Copy(copied, metadata)
+	return copied
+}
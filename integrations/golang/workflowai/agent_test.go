@@ -0,0 +1,179 @@
+package workflowai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+type agentInput struct {
+	Review string `json:"review"`
+}
+
+func (i *agentInput) Validate() error {
+	if i.Review == "" {
+		return errors.New("review is required")
+	}
+	return nil
+}
+
+type agentOutput struct {
+	Label string  `json:"label" enum:"positive,negative"`
+	Score float64 `json:"score"`
+}
+
+func TestAgent(t *testing.T) {
+	var body map[string]any
+	content := `{"label": "positive", "score": 0.9}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body = nil
+		json.NewDecoder(r.Body).Decode(&body)
+		writeJSON(w, http.StatusOK, map[string]any{
+			"id":      "sentiment/run-1",
+			"choices": []any{map[string]any{"index": 0, "cost_usd": 0.002, "message": map[string]any{"role": "assistant", "content": content}}},
+		})
+	}))
+	defer server.Close()
+	client := NewClient(option.WithBaseURL(server.URL+"/v1"), option.WithAPIKey("test"), option.WithMaxRetries(0))
+
+	agent, err := NewAgent[agentInput, agentOutput](&client.Chat.Completions, "sentiment", "gpt-4o-mini-latest", "Classify {{review}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	agent.Params.Temperature = openai.Float(0)
+	agent.Params.Metadata = map[string]string{"team": "support"}
+	ctx := ContextWithRunMetadata(context.Background(), map[string]string{"trace": "t-1", "team": "default"})
+
+	run, err := agent.Run(ctx, agentInput{Review: "Great"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if run.Output.Label != "positive" || run.RunID() != "run-1" || run.CostUSD() != 0.002 {
+		t.Errorf("run = %+v, run ID %s, cost %v", run.Output, run.RunID(), run.CostUSD())
+	}
+	if body["model"] != "sentiment/gpt-4o-mini-latest" || body["temperature"] != 0.0 {
+		t.Errorf("model %v, temperature %v", body["model"], body["temperature"])
+	}
+	if input, _ := body["input"].(map[string]any); input["review"] != "Great" {
+		t.Errorf("input = %v", body["input"])
+	}
+	if metadata, _ := body["metadata"].(map[string]any); metadata["trace"] != "t-1" || metadata["team"] != "support" {
+		t.Errorf("metadata = %v", body["metadata"])
+	}
+	format, _ := body["response_format"].(map[string]any)
+	if schema, _ := format["json_schema"].(map[string]any); schema["strict"] != true {
+		t.Errorf("response format = %v", format)
+	}
+
+	if _, err := agent.Run(ctx, agentInput{}); err == nil {
+		t.Error("expected an error for an invalid input")
+	}
+
+	content = `{"label": "great", "score": 0.9}`
+	_, err = agent.Run(ctx, agentInput{Review: "Great"})
+	var outputErr *OutputError
+	if !errors.As(err, &outputErr) || outputErr.RunID != "run-1" || outputErr.Content != content {
+		t.Errorf("expected an output error, got %v", err)
+	}
+
+	content = "Hello"
+	text, err := NewAgent[agentInput, string](&client.Chat.Completions, "greeter", "gpt-4o-mini-latest", "Greet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	textRun, err := text.Run(context.Background(), agentInput{Review: "x"})
+	if err != nil || textRun.Output != "Hello" {
+		t.Errorf("text output = %q, %v", textRun.Output, err)
+	}
+	if _, ok := body["response_format"]; ok {
+		t.Error("text agents should not send a response format")
+	}
+	if _, ok := body["metadata"]; ok {
+		t.Errorf("unexpected metadata %v", body["metadata"])
+	}
+}
+
+func TestAgentRepair(t *testing.T) {
+	var bodies []map[string]any
+	contents := []string{`{"label": "great", "score": 0.9}`, `{"label": "positive", "score": 0.9}`}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		bodies = append(bodies, body)
+		content := contents[min(len(bodies), len(contents))-1]
+		writeJSON(w, http.StatusOK, map[string]any{
+			"id":      "sentiment/run-" + strconv.Itoa(len(bodies)),
+			"choices": []any{map[string]any{"index": 0, "cost_usd": 0.002, "message": map[string]any{"role": "assistant", "content": content}}},
+		})
+	}))
+	defer server.Close()
+	client := NewClient(option.WithBaseURL(server.URL+"/v1"), option.WithAPIKey("test"), option.WithMaxRetries(0))
+	agent, err := NewAgent[agentInput, agentOutput](&client.Chat.Completions, "sentiment", "gpt-4o-mini-latest", "Classify {{review}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	run, err := agent.Run(ctx, agentInput{Review: "Great"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if run.Output.Label != "positive" || run.RunID() != "run-2" || len(run.Completions) != 2 || run.CostUSD() != 0.004 {
+		t.Errorf("unexpected run %+v", run)
+	}
+	messages, _ := bodies[1]["messages"].([]any)
+	if len(messages) != 3 {
+		t.Fatalf("expected the invalid output and the errors to be sent back, got %v", messages)
+	}
+	if repair, _ := messages[2].(map[string]any); !strings.Contains(repair["content"].(string), "$.label") {
+		t.Errorf("unexpected repair message %v", repair)
+	}
+
+	// The output is still invalid after the repair attempts
+	bodies, contents = nil, contents[:1]
+	agent.RepairAttempts = 2
+	_, err = agent.Run(ctx, agentInput{Review: "Great"})
+	var outputErr *OutputError
+	if !errors.As(err, &outputErr) || outputErr.Attempts != 3 || outputErr.RunID != "run-3" || len(bodies) != 3 {
+		t.Errorf("expected an output error after 3 attempts, got %v", err)
+	}
+
+	bodies = nil
+	agent.RepairAttempts = -1
+	if _, err := agent.Run(ctx, agentInput{Review: "Great"}); !errors.As(err, &outputErr) || outputErr.Attempts != 1 || len(bodies) != 1 {
+		t.Errorf("expected no repair, got %v", err)
+	}
+}
+
+func TestAgentLenientJSON(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		writeJSON(w, http.StatusOK, map[string]any{
+			"id":      "sentiment/run-1",
+			"choices": []any{map[string]any{"index": 0, "message": map[string]any{"role": "assistant", "content": "```json\n{\"label\": \"positive\", \"score\": 0.9,}\n```"}}},
+		})
+	}))
+	defer server.Close()
+	client := NewClient(option.WithBaseURL(server.URL+"/v1"), option.WithAPIKey("test"), option.WithMaxRetries(0))
+	agent, err := NewAgent[agentInput, agentOutput](&client.Chat.Completions, "sentiment", "gpt-4o-mini-latest", "Classify {{review}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	agent.LenientJSON = true
+	run, err := agent.Run(context.Background(), agentInput{Review: "Great"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if run.Output.Label != "positive" || !run.JSONRepaired || requests != 1 {
+		t.Errorf("unexpected run %+v after %d requests", run, requests)
+	}
+}
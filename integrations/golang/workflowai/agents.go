@@ -0,0 +1,234 @@
+package workflowai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+// AgentService lists the agents of the organization of the API key and
+// returns their run statistics.
+type AgentService struct {
+	client openai.Client
+}
+
+// AgentSchema is a schema of an agent. A new schema is created each time the
+// input or output schema of an agent changes.
+type AgentSchema struct {
+	SchemaID     int             `json:"schema_id"`
+	VariantID    string          `json:"variant_id"`
+	InputSchema  json.RawMessage `json:"input_schema"`
+	OutputSchema json.RawMessage `json:"output_schema"`
+	CreatedAt    time.Time       `json:"created_at"`
+	IsHidden     bool            `json:"is_hidden,omitempty"`
+	// LastActiveAt is the time of the last run of the schema, nil when it
+	// never ran
+	LastActiveAt *time.Time `json:"last_active_at,omitempty"`
+}
+
+// AgentInfo is an agent as returned by [AgentService.List].
+type AgentInfo struct {
+	ID          string `json:"id"`
+	UID         int    `json:"uid"`
+	Name        string `json:"name,omitempty"`
+	Description string `json:"description,omitempty"`
+	// Schemas are sorted by schema ID
+	Schemas []AgentSchema `json:"versions"`
+	// RunCount and TotalCostUSD are the runs of the agent over the last 30
+	// days, from the agent stats
+	RunCount     int     `json:"-"`
+	TotalCostUSD float64 `json:"-"`
+}
+
+// Schema returns the schema with the ID, or nil.
+func (a *AgentInfo) Schema(schemaID int) *AgentSchema {
+	for i := range a.Schemas {
+		if a.Schemas[i].SchemaID == schemaID {
+			return &a.Schemas[i]
+		}
+	}
+	return nil
+}
+
+// LastActiveAt returns the time of the last run of any schema of the agent,
+// nil when it never ran.
+func (a *AgentInfo) LastActiveAt() *time.Time {
+	var last *time.Time
+	for _, schema := range a.Schemas {
+		if schema.LastActiveAt != nil && (last == nil || schema.LastActiveAt.After(*last)) {
+			last = schema.LastActiveAt
+		}
+	}
+	return last
+}
+
+// RanSince reports whether the agent ran after t, e.g. to find the agents
+// that are no longer used.
+func (a *AgentInfo) RanSince(t time.Time) bool {
+	last := a.LastActiveAt()
+	return last != nil && last.After(t)
+}
+
+type agentStat struct {
+	AgentUID     int     `json:"agent_uid"`
+	RunCount     int     `json:"run_count"`
+	TotalCostUSD float64 `json:"total_cost_usd"`
+}
+
+// List returns the agents with their schemas and their run count and cost
+// over the last 30 days.
+func (s *AgentService) List(ctx context.Context, opts ...option.RequestOption) ([]AgentInfo, error) {
+	var page Page[AgentInfo]
+	// The agents are listed by the tenant route, outside of /v1
+	if err := s.client.Execute(ctx, http.MethodGet, "../_/agents", nil, &page, opts...); err != nil {
+		return nil, err
+	}
+	// The agents do not include their runs, which are aggregated by agent
+	// uid by the stats endpoint
+	var stats Page[agentStat]
+	query := url.Values{"from_date": {time.Now().AddDate(0, 0, -30).UTC().Format(time.RFC3339)}}
+	if err := s.client.Execute(ctx, http.MethodGet, "_/agents/stats?"+query.Encode(), nil, &stats, opts...); err != nil {
+		return nil, err
+	}
+	byUID := make(map[int]agentStat, len(stats.Items))
+	for _, stat := range stats.Items {
+		byUID[stat.AgentUID] = stat
+	}
+	for i := range page.Items {
+		stat := byUID[page.Items[i].UID]
+		page.Items[i].RunCount, page.Items[i].TotalCostUSD = stat.RunCount, stat.TotalCostUSD
+	}
+	return page.Items, nil
+}
+
+// AgentStatsParams selects the runs of [AgentService.Stats].
+type AgentStatsParams struct {
+	// From and To bound the creation time of the runs, unbounded when zero
+	From, To time.Time
+	// SchemaID restricts the stats to a schema, 0 for every schema
+	SchemaID int
+	// Version restricts the stats to a version, by ID or semver, e.g. "2.1"
+	Version string
+}
+
+// AgentStatsDay aggregates the runs of an agent created on a day.
+type AgentStatsDay struct {
+	// Date is the day, at midnight UTC
+	Date         time.Time `json:"-"`
+	RunCount     int       `json:"total_count"`
+	TotalCostUSD float64   `json:"total_cost_usd"`
+}
+
+func (d *AgentStatsDay) UnmarshalJSON(data []byte) error {
+	type day AgentStatsDay
+	var v struct {
+		day
+		Date string `json:"date"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	date, err := time.Parse(time.DateOnly, v.Date)
+	if err != nil {
+		return fmt.Errorf("workflowai: invalid stats date %q: %w", v.Date, err)
+	}
+	*d = AgentStatsDay(v.day)
+	d.Date = date
+	return nil
+}
+
+// AverageCostUSD returns the average cost of a run.
+func (d AgentStatsDay) AverageCostUSD() float64 {
+	if d.RunCount == 0 {
+		return 0
+	}
+	return d.TotalCostUSD / float64(d.RunCount)
+}
+
+// StatsPoint is a point of a time series.
+type StatsPoint struct {
+	Time  time.Time
+	Value float64
+}
+
+// AgentStats are the run statistics of an agent, one entry per day with
+// runs, sorted by date.
+type AgentStats struct {
+	Days []AgentStatsDay `json:"data"`
+}
+
+// Series returns the time series of a value of the days, e.g. to feed a
+// dashboard:
+//
+//	stats.Series(AgentStatsDay.AverageCostUSD)
+func (s *AgentStats) Series(value func(AgentStatsDay) float64) []StatsPoint {
+	points := make([]StatsPoint, len(s.Days))
+	for i, d := range s.Days {
+		points[i] = StatsPoint{Time: d.Date, Value: value(d)}
+	}
+	return points
+}
+
+// RunCounts returns the number of runs per day.
+func (s *AgentStats) RunCounts() []StatsPoint {
+	return s.Series(func(d AgentStatsDay) float64 { return float64(d.RunCount) })
+}
+
+// Costs returns the total cost of the runs per day, in USD.
+func (s *AgentStats) Costs() []StatsPoint {
+	return s.Series(func(d AgentStatsDay) float64 { return d.TotalCostUSD })
+}
+
+// AverageCosts returns the average cost of a run per day, in USD.
+func (s *AgentStats) AverageCosts() []StatsPoint {
+	return s.Series(AgentStatsDay.AverageCostUSD)
+}
+
+// Total aggregates every day, its Date being the first day.
+func (s *AgentStats) Total() AgentStatsDay {
+	var total AgentStatsDay
+	for i, d := range s.Days {
+		if i == 0 {
+			total.Date = d.Date
+		}
+		total.RunCount += d.RunCount
+		total.TotalCostUSD += d.TotalCostUSD
+	}
+	return total
+}
+
+// Stats returns the daily run count and cost of an agent. The API aggregates
+// neither errors, latencies nor schema validation failures.
+func (s *AgentService) Stats(ctx context.Context, agentID string, params AgentStatsParams, opts ...option.RequestOption) (*AgentStats, error) {
+	query := url.Values{}
+	if !params.From.IsZero() {
+		query.Set("created_after", params.From.UTC().Format(time.RFC3339))
+	}
+	if !params.To.IsZero() {
+		query.Set("created_before", params.To.UTC().Format(time.RFC3339))
+	}
+	if params.SchemaID != 0 {
+		query.Set("task_schema_id", strconv.Itoa(params.SchemaID))
+	}
+	if params.Version != "" {
+		query.Set("version", params.Version)
+	}
+	// The stats are served by the tenant route, outside of /v1, where
+	// runs/stats would be read as the run "stats"
+	path := "../" + agentPath(agentID, "runs", "stats")
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	var stats AgentStats
+	if err := s.client.Execute(ctx, http.MethodGet, path, nil, &stats, opts...); err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
@@ -0,0 +1,95 @@
+package workflowai
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/openai/openai-go/option"
+)
+
+func TestAgentService_List(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /_/agents", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]any{"items": []map[string]any{
+			{
+				"id":  "my-agent",
+				"uid": 7,
+				"versions": []map[string]any{
+					{"schema_id": 1, "variant_id": "a", "input_schema": map[string]any{"type": "object"}, "last_active_at": "2025-02-01T10:00:00Z"},
+					{"schema_id": 2, "variant_id": "b", "input_schema": map[string]any{"type": "object"}, "last_active_at": "2025-03-28T10:00:00Z"},
+				},
+			},
+			{"id": "unused-agent", "uid": 8, "versions": []map[string]any{{"schema_id": 1, "variant_id": "c"}}},
+		}})
+	})
+	mux.HandleFunc("GET /v1/_/agents/stats", func(w http.ResponseWriter, r *http.Request) {
+		if _, err := time.Parse(time.RFC3339, r.URL.Query().Get("from_date")); err != nil {
+			t.Errorf("unexpected query %s", r.URL.RawQuery)
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"items": []map[string]any{
+			{"agent_uid": 7, "run_count": 42, "total_cost_usd": 1.5},
+		}})
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := NewClient(option.WithBaseURL(server.URL+"/v1"), option.WithAPIKey("test"), option.WithMaxRetries(0))
+	agents, err := client.Agents.List(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(agents) != 2 {
+		t.Fatalf("unexpected agents %+v", agents)
+	}
+	agent := agents[0]
+	if agent.RunCount != 42 || agent.TotalCostUSD != 1.5 || agents[1].RunCount != 0 {
+		t.Errorf("unexpected agents %+v", agents)
+	}
+	if agent.Schema(2) == nil || agent.Schema(3) != nil {
+		t.Errorf("unexpected schemas %+v", agent.Schemas)
+	}
+	if last := agent.LastActiveAt(); last == nil || !last.Equal(time.Date(2025, 3, 28, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected last activity %v", last)
+	}
+	since := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	if !agent.RanSince(since) || agents[1].RanSince(since) {
+		t.Error("unexpected RanSince")
+	}
+}
+
+func TestAgentService_Stats(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /_/agents/my-agent/runs/stats", func(w http.ResponseWriter, r *http.Request) {
+		if got := r.URL.RawQuery; got != "created_after=2025-03-01T00%3A00%3A00Z&task_schema_id=2" {
+			t.Errorf("unexpected query %s", got)
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"data": []map[string]any{
+			{"date": "2025-03-01", "total_count": 10, "total_cost_usd": 0.5},
+			{"date": "2025-03-03", "total_count": 5, "total_cost_usd": 0.25},
+		}})
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := NewClient(option.WithBaseURL(server.URL+"/v1"), option.WithAPIKey("test"), option.WithMaxRetries(0))
+	stats, err := client.Agents.Stats(context.Background(), "my-agent", AgentStatsParams{
+		From:     time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC),
+		SchemaID: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	runCounts := stats.RunCounts()
+	if len(runCounts) != 2 || runCounts[0].Value != 10 || !runCounts[1].Time.Equal(time.Date(2025, 3, 3, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected run counts %+v", runCounts)
+	}
+	if day := stats.Days[0]; day.AverageCostUSD() != 0.05 {
+		t.Errorf("unexpected day %+v", day)
+	}
+	if total := stats.Total(); total.RunCount != 15 || total.TotalCostUSD != 0.75 {
+		t.Errorf("unexpected total %+v", total)
+	}
+}
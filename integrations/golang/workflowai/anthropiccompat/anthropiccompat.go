@@ -0,0 +1,163 @@
+// Package anthropiccompat speaks the Anthropic Messages API format against the
+// WorkflowAI OpenAI compatible endpoint, for code written against Anthropic's
+// request and response shapes.
+//
+// Requests are translated to chat completions: the system prompt becomes a
+// system message, tool use and tool result blocks become tool calls and tool
+// messages, and the completion is translated back to a [Message]. Streamed
+// completions are translated to the Anthropic stream events.
+//
+//	client := workflowai.NewClient()
+//	messages := anthropiccompat.NewMessageService(&client.Chat.Completions)
+//	msg, err := messages.New(ctx, anthropiccompat.MessageNewParams{
+//		Model:     "my-agent/claude-sonnet-4-20250514",
+//		MaxTokens: 1024,
+//		System:    "You are a helpful assistant.",
+//		Messages:  []anthropiccompat.MessageParam{anthropiccompat.NewUserMessage(anthropiccompat.NewTextBlock("Hello"))},
+//	})
+package anthropiccompat
+
+import (
+	"encoding/json"
+)
+
+// Content block types
+const (
+	BlockText       = "text"
+	BlockImage      = "image"
+	BlockToolUse    = "tool_use"
+	BlockToolResult = "tool_result"
+)
+
+// Stop reasons
+const (
+	StopEndTurn   = "end_turn"
+	StopMaxTokens = "max_tokens"
+	StopToolUse   = "tool_use"
+	StopRefusal   = "refusal"
+)
+
+// ContentBlock is a block of a message. The fields used depend on the Type.
+type ContentBlock struct {
+	Type string `json:"type"`
+	// Text is set for text blocks
+	Text string `json:"text,omitempty"`
+	// Source is set for image blocks
+	Source *ImageSource `json:"source,omitempty"`
+	// ID, Name and Input are set for tool use blocks
+	ID    string          `json:"id,omitempty"`
+	Name  string          `json:"name,omitempty"`
+	Input json.RawMessage `json:"input,omitempty"`
+	// ToolUseID, Content and IsError are set for tool result blocks
+	ToolUseID string         `json:"tool_use_id,omitempty"`
+	Content   []ContentBlock `json:"content,omitempty"`
+	IsError   bool           `json:"is_error,omitempty"`
+}
+
+// ImageSource is the data of an image block, either base64 encoded or a URL.
+type ImageSource struct {
+	// Type is "base64" or "url"
+	Type      string `json:"type"`
+	MediaType string `json:"media_type,omitempty"`
+	Data      string `json:"data,omitempty"`
+	URL       string `json:"url,omitempty"`
+}
+
+// NewTextBlock returns a text block.
+func NewTextBlock(text string) ContentBlock {
+	return ContentBlock{Type: BlockText, Text: text}
+}
+
+// NewImageBlock returns an image block with base64 encoded data.
+func NewImageBlock(mediaType string, data string) ContentBlock {
+	return ContentBlock{Type: BlockImage, Source: &ImageSource{Type: "base64", MediaType: mediaType, Data: data}}
+}
+
+// NewToolResultBlock returns the result of the tool use with the given ID.
+func NewToolResultBlock(toolUseID string, content string, isError bool) ContentBlock {
+	return ContentBlock{Type: BlockToolResult, ToolUseID: toolUseID, Content: []ContentBlock{NewTextBlock(content)}, IsError: isError}
+}
+
+// MessageParam is a message of the conversation.
+type MessageParam struct {
+	// Role is "user" or "assistant"
+	Role    string         `json:"role"`
+	Content []ContentBlock `json:"content"`
+}
+
+// NewUserMessage returns a user message made of blocks.
+func NewUserMessage(blocks ...ContentBlock) MessageParam {
+	return MessageParam{Role: "user", Content: blocks}
+}
+
+// NewAssistantMessage returns an assistant message made of blocks.
+func NewAssistantMessage(blocks ...ContentBlock) MessageParam {
+	return MessageParam{Role: "assistant", Content: blocks}
+}
+
+// ToolParam declares a tool the model may use.
+type ToolParam struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	InputSchema map[string]any `json:"input_schema"`
+}
+
+// ToolChoice controls how the model uses the tools.
+type ToolChoice struct {
+	// Type is "auto", "any", "tool" or "none"
+	Type string `json:"type"`
+	// Name is the tool to use when Type is "tool"
+	Name string `json:"name,omitempty"`
+}
+
+// MessageNewParams is the body of a message creation.
+type MessageNewParams struct {
+	Model     string         `json:"model"`
+	MaxTokens int64          `json:"max_tokens"`
+	Messages  []MessageParam `json:"messages"`
+	System    string         `json:"system,omitempty"`
+	Tools     []ToolParam    `json:"tools,omitempty"`
+	// ToolChoice defaults to "auto" when tools are provided
+	ToolChoice  *ToolChoice `json:"tool_choice,omitempty"`
+	Temperature *float64    `json:"temperature,omitempty"`
+	TopP        *float64    `json:"top_p,omitempty"`
+	// StopSequences are not supported by WorkflowAI, which rejects the stop
+	// parameter: messages with stop sequences fail before being sent
+	StopSequences []string `json:"stop_sequences,omitempty"`
+	// Metadata is sent as the WorkflowAI run metadata
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// Usage is the number of tokens of a message.
+type Usage struct {
+	InputTokens  int64 `json:"input_tokens"`
+	OutputTokens int64 `json:"output_tokens"`
+}
+
+// Message is the reply of the model.
+type Message struct {
+	ID         string         `json:"id"`
+	Type       string         `json:"type"`
+	Role       string         `json:"role"`
+	Model      string         `json:"model"`
+	Content    []ContentBlock `json:"content"`
+	StopReason string         `json:"stop_reason,omitempty"`
+	Usage      Usage          `json:"usage"`
+}
+
+// Text returns the concatenated text blocks of the message.
+func (m *Message) Text() string {
+	text := ""
+	for _, block := range m.Content {
+		if block.Type == BlockText {
+			text += block.Text
+		}
+	}
+	return text
+}
+
+// ToParam returns the message as a MessageParam, to continue the
+// conversation.
+func (m *Message) ToParam() MessageParam {
+	return MessageParam{Role: m.Role, Content: m.Content}
+}
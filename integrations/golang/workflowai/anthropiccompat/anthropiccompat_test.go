@@ -0,0 +1,167 @@
+package anthropiccompat
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+const toolCompletion = `{"id":"my-agent/run-1","object":"chat.completion","created":1,"model":"claude-sonnet",
+"choices":[{"index":0,"finish_reason":"tool_calls","message":{"role":"assistant","content":"Let me check.",
+"tool_calls":[{"id":"call_1","type":"function","function":{"name":"get_weather","arguments":"{\"city\":\"Paris\"}"}}]}}],
+"usage":{"prompt_tokens":12,"completion_tokens":8,"total_tokens":20}}`
+
+var streamChunks = []string{
+	`{"id":"my-agent/run-2","object":"chat.completion.chunk","created":1,"model":"claude-sonnet","choices":[{"index":0,"delta":{"role":"assistant","content":"Hello"}}]}`,
+	`{"id":"my-agent/run-2","object":"chat.completion.chunk","created":1,"model":"claude-sonnet","choices":[{"index":0,"delta":{"content":" world"}}]}`,
+	`{"id":"my-agent/run-2","object":"chat.completion.chunk","created":1,"model":"claude-sonnet","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"get_weather","arguments":""}}]}}]}`,
+	`{"id":"my-agent/run-2","object":"chat.completion.chunk","created":1,"model":"claude-sonnet","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{\"city\":"}}]}}]}`,
+	`{"id":"my-agent/run-2","object":"chat.completion.chunk","created":1,"model":"claude-sonnet","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"Paris\"}"}}]},"finish_reason":"tool_calls"}]}`,
+	`{"id":"my-agent/run-2","object":"chat.completion.chunk","created":1,"model":"claude-sonnet","choices":[],"usage":{"prompt_tokens":12,"completion_tokens":8,"total_tokens":20}}`,
+}
+
+func newTestService(t *testing.T, requests *[]map[string]any) *MessageService {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var req map[string]any
+		_ = json.Unmarshal(body, &req)
+		*requests = append(*requests, req)
+		if req["stream"] == true {
+			w.Header().Set("Content-Type", "text/event-stream")
+			for _, chunk := range streamChunks {
+				fmt.Fprintf(w, "data: %s\n\n", chunk)
+			}
+			fmt.Fprint(w, "data: [DONE]\n\n")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, toolCompletion)
+	}))
+	t.Cleanup(server.Close)
+	client := openai.NewClient(option.WithBaseURL(server.URL), option.WithAPIKey("key"))
+	return NewMessageService(&client.Chat.Completions)
+}
+
+var weatherParams = MessageNewParams{
+	Model:     "my-agent/claude-sonnet",
+	MaxTokens: 256,
+	System:    "You are a weather assistant.",
+	Messages: []MessageParam{
+		NewUserMessage(NewTextBlock("Weather in Lyon?")),
+		NewAssistantMessage(ContentBlock{Type: BlockToolUse, ID: "call_0", Name: "get_weather", Input: json.RawMessage(`{"city":"Lyon"}`)}),
+		NewUserMessage(NewToolResultBlock("call_0", "sunny", false), NewTextBlock("And in Paris?")),
+	},
+	Tools: []ToolParam{{
+		Name:        "get_weather",
+		InputSchema: map[string]any{"type": "object", "properties": map[string]any{"city": map[string]any{"type": "string"}}},
+	}},
+	ToolChoice: &ToolChoice{Type: "any"},
+}
+
+func TestMessageService_New(t *testing.T) {
+	var requests []map[string]any
+	service := newTestService(t, &requests)
+
+	msg, err := service.New(context.Background(), weatherParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sent, _ := json.Marshal(requests[0]["messages"])
+	expected := `[{"content":"You are a weather assistant.","role":"system"},` +
+		`{"content":"Weather in Lyon?","role":"user"},` +
+		`{"role":"assistant","tool_calls":[{"function":{"arguments":"{\"city\":\"Lyon\"}","name":"get_weather"},"id":"call_0","type":"function"}]},` +
+		`{"content":"sunny","role":"tool","tool_call_id":"call_0"},` +
+		`{"content":"And in Paris?","role":"user"}]`
+	if string(sent) != expected {
+		t.Errorf("unexpected messages\n%s\nexpected\n%s", sent, expected)
+	}
+	if requests[0]["tool_choice"] != "required" || requests[0]["max_tokens"] != 256.0 {
+		t.Errorf("unexpected request %v", requests[0])
+	}
+
+	if msg.ID != "my-agent/run-1" || msg.StopReason != StopToolUse || msg.Usage.InputTokens != 12 || msg.Text() != "Let me check." {
+		t.Errorf("unexpected message %+v", msg)
+	}
+	if len(msg.Content) != 2 || msg.Content[1].Name != "get_weather" || string(msg.Content[1].Input) != `{"city":"Paris"}` {
+		t.Errorf("unexpected content %+v", msg.Content)
+	}
+}
+
+func TestMessageService_NewStreaming(t *testing.T) {
+	var requests []map[string]any
+	service := newTestService(t, &requests)
+
+	stream := service.NewStreaming(context.Background(), weatherParams)
+	defer stream.Close()
+	var events []string
+	for stream.Next() {
+		event := stream.Current()
+		switch {
+		case event.Delta != nil && event.Delta.Type != "":
+			events = append(events, fmt.Sprintf("%s:%d:%s%s", event.Type, event.Index, event.Delta.Text, event.Delta.PartialJSON))
+		case event.Type == EventMessageDelta:
+			events = append(events, fmt.Sprintf("%s:%s:%d", event.Type, event.Delta.StopReason, event.Usage.OutputTokens))
+		default:
+			events = append(events, fmt.Sprintf("%s:%d", event.Type, event.Index))
+		}
+	}
+	if err := stream.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"message_start:0",
+		"content_block_start:0",
+		"content_block_delta:0:Hello",
+		"content_block_delta:0: world",
+		"content_block_stop:0",
+		"content_block_start:1",
+		`content_block_delta:1:{"city":`,
+		`content_block_delta:1:"Paris"}`,
+		"content_block_stop:1",
+		"message_delta:tool_use:8",
+		"message_stop:0",
+	}
+	if strings.Join(events, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("unexpected events\n%s", strings.Join(events, "\n"))
+	}
+
+	msg := stream.Message()
+	if msg.Text() != "Hello world" || string(msg.Content[1].Input) != `{"city":"Paris"}` || msg.Usage.InputTokens != 12 {
+		t.Errorf("unexpected accumulated message %+v", msg)
+	}
+	if options, _ := requests[0]["stream_options"].(map[string]any); options["include_usage"] != true {
+		t.Errorf("expected usage to be requested, got %v", requests[0]["stream_options"])
+	}
+}
+
+func TestMessageService_InvalidParams(t *testing.T) {
+	var requests []map[string]any
+	service := newTestService(t, &requests)
+	params := MessageNewParams{Model: "m", Messages: []MessageParam{{Role: "system"}}}
+	if _, err := service.New(context.Background(), params); err == nil {
+		t.Error("expected an error for an unsupported role")
+	}
+	stream := service.NewStreaming(context.Background(), params)
+	if stream.Next() || stream.Err() == nil {
+		t.Error("expected the stream to fail")
+	}
+	// WorkflowAI rejects the stop parameter
+	stop := MessageNewParams{Model: "m", Messages: []MessageParam{NewUserMessage(NewTextBlock("Hi"))}, StopSequences: []string{"\n\n"}}
+	if _, err := service.New(context.Background(), stop); err == nil || !strings.Contains(err.Error(), "stop sequences") {
+		t.Errorf("expected an unsupported stop sequences error, got %v", err)
+	}
+	if len(requests) != 0 {
+		t.Errorf("expected no request, got %d", len(requests))
+	}
+}
@@ -0,0 +1,228 @@
+package anthropiccompat
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+	"github.com/openai/openai-go/shared"
+
+	"github.com/workflowai/workflowai/go/examples/workflowai"
+)
+
+// MessageService creates messages through a chat completion service.
+type MessageService struct {
+	completions workflowai.ChatService
+}
+
+// NewMessageService returns a MessageService sending chat completions with
+// completions, e.g. &client.Chat.Completions.
+func NewMessageService(completions workflowai.ChatService) *MessageService {
+	return &MessageService{completions: completions}
+}
+
+// New creates a message.
+func (s *MessageService) New(ctx context.Context, params MessageNewParams, opts ...option.RequestOption) (*Message, error) {
+	body, err := completionParams(params)
+	if err != nil {
+		return nil, err
+	}
+	completion, err := s.completions.New(ctx, body, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if len(completion.Choices) == 0 {
+		return nil, errors.New("anthropiccompat: completion has no choices")
+	}
+	choice := completion.Choices[0]
+	msg := &Message{
+		ID:         completion.ID,
+		Type:       "message",
+		Role:       "assistant",
+		Model:      completion.Model,
+		StopReason: stopReason(choice.FinishReason),
+		Usage: Usage{
+			InputTokens:  completion.Usage.PromptTokens,
+			OutputTokens: completion.Usage.CompletionTokens,
+		},
+	}
+	if choice.Message.Refusal != "" {
+		msg.StopReason = StopRefusal
+		msg.Content = append(msg.Content, NewTextBlock(choice.Message.Refusal))
+	}
+	if choice.Message.Content != "" {
+		msg.Content = append(msg.Content, NewTextBlock(choice.Message.Content))
+	}
+	for _, call := range choice.Message.ToolCalls {
+		msg.Content = append(msg.Content, ContentBlock{
+			Type:  BlockToolUse,
+			ID:    call.ID,
+			Name:  call.Function.Name,
+			Input: toolInput(call.Function.Arguments),
+		})
+	}
+	return msg, nil
+}
+
+// toolInput returns the arguments of a tool call as a JSON object.
+func toolInput(arguments string) json.RawMessage {
+	if strings.TrimSpace(arguments) == "" || !json.Valid([]byte(arguments)) {
+		return json.RawMessage(`{}`)
+	}
+	return json.RawMessage(arguments)
+}
+
+func stopReason(finishReason string) string {
+	switch finishReason {
+	case "length":
+		return StopMaxTokens
+	case "tool_calls", "function_call":
+		return StopToolUse
+	case "content_filter":
+		return StopRefusal
+	case "":
+		return ""
+	default:
+		return StopEndTurn
+	}
+}
+
+// completionParams translates a message creation to a chat completion.
+func completionParams(params MessageNewParams) (openai.ChatCompletionNewParams, error) {
+	body := openai.ChatCompletionNewParams{Model: params.Model}
+	if params.MaxTokens > 0 {
+		body.MaxTokens = openai.Int(params.MaxTokens)
+	}
+	if params.Temperature != nil {
+		body.Temperature = openai.Float(*params.Temperature)
+	}
+	if params.TopP != nil {
+		body.TopP = openai.Float(*params.TopP)
+	}
+	if len(params.StopSequences) > 0 {
+		return body, errors.New("anthropiccompat: stop sequences are not supported by WorkflowAI")
+	}
+	if len(params.Metadata) > 0 {
+		body.Metadata = shared.Metadata(params.Metadata)
+	}
+	if params.System != "" {
+		body.Messages = append(body.Messages, openai.SystemMessage(params.System))
+	}
+
+	for i, m := range params.Messages {
+		var messages []openai.ChatCompletionMessageParamUnion
+		var err error
+		switch m.Role {
+		case "user":
+			messages, err = userMessages(m.Content)
+		case "assistant":
+			messages, err = assistantMessage(m.Content)
+		default:
+			err = fmt.Errorf("unsupported role %q", m.Role)
+		}
+		if err != nil {
+			return body, fmt.Errorf("anthropiccompat: message %d: %w", i, err)
+		}
+		body.Messages = append(body.Messages, messages...)
+	}
+
+	for _, tool := range params.Tools {
+		fn := shared.FunctionDefinitionParam{Name: tool.Name, Parameters: shared.FunctionParameters(tool.InputSchema)}
+		if tool.Description != "" {
+			fn.Description = openai.String(tool.Description)
+		}
+		body.Tools = append(body.Tools, openai.ChatCompletionToolParam{Function: fn})
+	}
+	if params.ToolChoice != nil {
+		switch params.ToolChoice.Type {
+		case "auto", "none":
+			body.ToolChoice = openai.ChatCompletionToolChoiceOptionUnionParam{OfAuto: openai.String(params.ToolChoice.Type)}
+		case "any":
+			body.ToolChoice = openai.ChatCompletionToolChoiceOptionUnionParam{OfAuto: openai.String("required")}
+		case "tool":
+			body.ToolChoice = openai.ChatCompletionToolChoiceOptionUnionParam{
+				OfChatCompletionNamedToolChoice: &openai.ChatCompletionNamedToolChoiceParam{
+					Function: openai.ChatCompletionNamedToolChoiceFunctionParam{Name: params.ToolChoice.Name},
+				},
+			}
+		default:
+			return body, fmt.Errorf("anthropiccompat: unsupported tool choice %q", params.ToolChoice.Type)
+		}
+	}
+	return body, nil
+}
+
+// userMessages translates a user message. Tool results become tool messages,
+// sent before the other blocks as they answer the previous assistant message.
+func userMessages(blocks []ContentBlock) ([]openai.ChatCompletionMessageParamUnion, error) {
+	var messages []openai.ChatCompletionMessageParamUnion
+	var parts []openai.ChatCompletionContentPartUnionParam
+	for _, block := range blocks {
+		switch block.Type {
+		case BlockText:
+			parts = append(parts, openai.TextContentPart(block.Text))
+		case BlockImage:
+			if block.Source == nil {
+				return nil, errors.New("image block without source")
+			}
+			url := block.Source.URL
+			if block.Source.Type == "base64" {
+				url = "data:" + block.Source.MediaType + ";base64," + block.Source.Data
+			}
+			parts = append(parts, openai.ImageContentPart(openai.ChatCompletionContentPartImageImageURLParam{URL: url}))
+		case BlockToolResult:
+			content := blocksText(block.Content)
+			if block.IsError {
+				content = "Error: " + content
+			}
+			messages = append(messages, openai.ToolMessage(content, block.ToolUseID))
+		default:
+			return nil, fmt.Errorf("unsupported user block %q", block.Type)
+		}
+	}
+	if len(parts) == 1 && parts[0].OfText != nil {
+		messages = append(messages, openai.UserMessage(parts[0].OfText.Text))
+	} else if len(parts) > 0 {
+		messages = append(messages, openai.UserMessage(parts))
+	}
+	return messages, nil
+}
+
+func assistantMessage(blocks []ContentBlock) ([]openai.ChatCompletionMessageParamUnion, error) {
+	var assistant openai.ChatCompletionAssistantMessageParam
+	text := blocksText(blocks)
+	if text != "" {
+		assistant.Content.OfString = openai.String(text)
+	}
+	for _, block := range blocks {
+		switch block.Type {
+		case BlockText:
+		case BlockToolUse:
+			input := string(block.Input)
+			if input == "" {
+				input = "{}"
+			}
+			assistant.ToolCalls = append(assistant.ToolCalls, openai.ChatCompletionMessageToolCallParam{
+				ID:       block.ID,
+				Function: openai.ChatCompletionMessageToolCallFunctionParam{Name: block.Name, Arguments: input},
+			})
+		default:
+			return nil, fmt.Errorf("unsupported assistant block %q", block.Type)
+		}
+	}
+	return []openai.ChatCompletionMessageParamUnion{{OfAssistant: &assistant}}, nil
+}
+
+func blocksText(blocks []ContentBlock) string {
+	var texts []string
+	for _, block := range blocks {
+		if block.Type == BlockText {
+			texts = append(texts, block.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
@@ -0,0 +1,193 @@
+package anthropiccompat
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+	"github.com/openai/openai-go/packages/ssestream"
+)
+
+// Stream event types
+const (
+	EventMessageStart      = "message_start"
+	EventContentBlockStart = "content_block_start"
+	EventContentBlockDelta = "content_block_delta"
+	EventContentBlockStop  = "content_block_stop"
+	EventMessageDelta      = "message_delta"
+	EventMessageStop       = "message_stop"
+)
+
+// StreamEvent is an event of a streamed message. The fields used depend on
+// the Type.
+type StreamEvent struct {
+	Type string `json:"type"`
+	// Message is set for message_start events, without content
+	Message *Message `json:"message,omitempty"`
+	// Index is the index of the block for content block events
+	Index        int           `json:"index"`
+	ContentBlock *ContentBlock `json:"content_block,omitempty"`
+	Delta        *EventDelta   `json:"delta,omitempty"`
+	// Usage is set for message_delta events
+	Usage *Usage `json:"usage,omitempty"`
+}
+
+// EventDelta is the change carried by a content_block_delta or a
+// message_delta event.
+type EventDelta struct {
+	// Type is "text_delta" or "input_json_delta" for content block deltas
+	Type        string `json:"type,omitempty"`
+	Text        string `json:"text,omitempty"`
+	PartialJSON string `json:"partial_json,omitempty"`
+	StopReason  string `json:"stop_reason,omitempty"`
+}
+
+// MessageStream translates a streamed chat completion to message stream
+// events. Only the first choice is translated.
+type MessageStream struct {
+	stream  *ssestream.Stream[openai.ChatCompletionChunk]
+	err     error
+	done    bool
+	queue   []StreamEvent
+	current StreamEvent
+
+	started    bool
+	message    Message
+	open       int
+	toolBlocks map[int64]int
+	toolInputs map[int]*strings.Builder
+}
+
+// NewStreaming creates a streamed message.
+func (s *MessageService) NewStreaming(ctx context.Context, params MessageNewParams, opts ...option.RequestOption) *MessageStream {
+	stream := &MessageStream{open: -1, toolBlocks: map[int64]int{}, toolInputs: map[int]*strings.Builder{}}
+	body, err := completionParams(params)
+	if err != nil {
+		stream.err, stream.done = err, true
+		return stream
+	}
+	body.StreamOptions = openai.ChatCompletionStreamOptionsParam{IncludeUsage: openai.Bool(true)}
+	stream.stream = s.completions.NewStreaming(ctx, body, opts...)
+	return stream
+}
+
+// Next advances to the next event and returns false at the end of the stream
+// or on error.
+func (s *MessageStream) Next() bool {
+	for len(s.queue) == 0 {
+		if s.done {
+			return false
+		}
+		if s.stream.Next() {
+			s.addChunk(s.stream.Current())
+			continue
+		}
+		s.done = true
+		if s.err = s.stream.Err(); s.err != nil {
+			return false
+		}
+		s.finish()
+	}
+	s.current, s.queue = s.queue[0], s.queue[1:]
+	return true
+}
+
+// Current returns the current event.
+func (s *MessageStream) Current() StreamEvent {
+	return s.current
+}
+
+func (s *MessageStream) Err() error {
+	return s.err
+}
+
+func (s *MessageStream) Close() error {
+	if s.stream == nil {
+		return nil
+	}
+	return s.stream.Close()
+}
+
+// Message returns the message accumulated from the events read so far.
+func (s *MessageStream) Message() *Message {
+	msg := s.message
+	return &msg
+}
+
+func (s *MessageStream) push(event StreamEvent) {
+	s.queue = append(s.queue, event)
+}
+
+func (s *MessageStream) start(id string, model string) {
+	s.started = true
+	s.message = Message{ID: id, Type: "message", Role: "assistant", Model: model, Content: []ContentBlock{}}
+	start := s.message
+	s.push(StreamEvent{Type: EventMessageStart, Message: &start})
+}
+
+func (s *MessageStream) openBlock(block ContentBlock) int {
+	s.closeBlock()
+	s.open = len(s.message.Content)
+	s.message.Content = append(s.message.Content, block)
+	s.push(StreamEvent{Type: EventContentBlockStart, Index: s.open, ContentBlock: &block})
+	return s.open
+}
+
+func (s *MessageStream) closeBlock() {
+	if s.open < 0 {
+		return
+	}
+	if input, ok := s.toolInputs[s.open]; ok {
+		s.message.Content[s.open].Input = toolInput(input.String())
+	}
+	s.push(StreamEvent{Type: EventContentBlockStop, Index: s.open})
+	s.open = -1
+}
+
+func (s *MessageStream) addChunk(chunk openai.ChatCompletionChunk) {
+	if !s.started {
+		s.start(chunk.ID, chunk.Model)
+	}
+	if chunk.Usage.PromptTokens > 0 || chunk.Usage.CompletionTokens > 0 {
+		s.message.Usage = Usage{InputTokens: chunk.Usage.PromptTokens, OutputTokens: chunk.Usage.CompletionTokens}
+	}
+	for _, choice := range chunk.Choices {
+		if choice.Index != 0 {
+			continue
+		}
+		if text := choice.Delta.Content; text != "" {
+			if s.open < 0 || s.message.Content[s.open].Type != BlockText {
+				s.openBlock(NewTextBlock(""))
+			}
+			s.message.Content[s.open].Text += text
+			s.push(StreamEvent{Type: EventContentBlockDelta, Index: s.open, Delta: &EventDelta{Type: "text_delta", Text: text}})
+		}
+		for _, call := range choice.Delta.ToolCalls {
+			index, ok := s.toolBlocks[call.Index]
+			if !ok {
+				index = s.openBlock(ContentBlock{Type: BlockToolUse, ID: call.ID, Name: call.Function.Name, Input: json.RawMessage(`{}`)})
+				s.toolBlocks[call.Index] = index
+				s.toolInputs[index] = &strings.Builder{}
+			}
+			if args := call.Function.Arguments; args != "" {
+				s.toolInputs[index].WriteString(args)
+				s.push(StreamEvent{Type: EventContentBlockDelta, Index: index, Delta: &EventDelta{Type: "input_json_delta", PartialJSON: args}})
+			}
+		}
+		if choice.FinishReason != "" {
+			s.message.StopReason = stopReason(choice.FinishReason)
+		}
+	}
+}
+
+func (s *MessageStream) finish() {
+	if !s.started {
+		s.start("", "")
+	}
+	s.closeBlock()
+	usage := s.message.Usage
+	s.push(StreamEvent{Type: EventMessageDelta, Delta: &EventDelta{StopReason: s.message.StopReason}, Usage: &usage})
+	s.push(StreamEvent{Type: EventMessageStop})
+}
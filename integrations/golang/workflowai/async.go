@@ -0,0 +1,168 @@
+package workflowai
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+// MetadataAsyncRunID is the metadata key of the ID of the [RunHandle] of a
+// run submitted with [AsyncRuns.Submit], to find the run in WorkflowAI with
+// [RunService.Search] on "metadata.async_run_id".
+const MetadataAsyncRunID = "async_run_id"
+
+// ErrRunNotDone is returned by [RunHandle.Poll] while the run is in progress.
+var ErrRunNotDone = errors.New("workflowai: the run is not done")
+
+// AsyncRuns runs chat completions in the background, for long agent tasks
+// started by short-lived HTTP handlers: [AsyncRuns.Submit] returns a handle
+// immediately, and a later request finds it by ID with [AsyncRuns.Handle] to
+// poll or await the completion.
+//
+// The handles are held in memory and do not survive a restart of the
+// process: the runs in progress are stopped, and [AsyncRuns.Handle] no
+// longer finds any handle. The runs that finished before the restart remain
+// in WorkflowAI, where [RunService.Search] finds them on
+// "metadata.async_run_id". Deployments restarting the processes running
+// long tasks should resubmit the handles that are not found.
+type AsyncRuns struct {
+	completions ChatCompleter
+	timeout     time.Duration
+	retention   time.Duration
+
+	mu      sync.Mutex
+	handles map[string]*RunHandle
+}
+
+type AsyncOption func(*AsyncRuns)
+
+// WithAsyncTimeout bounds the duration of the runs, 10 minutes by default.
+func WithAsyncTimeout(d time.Duration) AsyncOption {
+	return func(a *AsyncRuns) {
+		a.timeout = d
+	}
+}
+
+// WithAsyncRetention sets how long the handles of finished runs are kept, 1
+// hour by default.
+func WithAsyncRetention(d time.Duration) AsyncOption {
+	return func(a *AsyncRuns) {
+		a.retention = d
+	}
+}
+
+func NewAsyncRuns(completions ChatCompleter, opts ...AsyncOption) *AsyncRuns {
+	a := &AsyncRuns{
+		completions: completions,
+		timeout:     10 * time.Minute,
+		retention:   time.Hour,
+		handles:     map[string]*RunHandle{},
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+// RunHandle is a run submitted with [AsyncRuns.Submit].
+type RunHandle struct {
+	ID          string
+	SubmittedAt time.Time
+
+	cancel context.CancelFunc
+	done   chan struct{}
+	// Set before done is closed
+	completion *openai.ChatCompletion
+	err        error
+	finishedAt time.Time
+}
+
+// Submit starts a completion in the background and returns its handle. The
+// run keeps the values of ctx but not its cancellation, so that it outlives
+// the request that submitted it. The run is tagged with the handle ID under
+// [MetadataAsyncRunID].
+func (a *AsyncRuns) Submit(ctx context.Context, params openai.ChatCompletionNewParams, opts ...option.RequestOption) *RunHandle {
+	id := make([]byte, 12)
+	rand.Read(id)
+	runCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), a.timeout)
+	h := &RunHandle{
+		ID:          "async_" + hex.EncodeToString(id),
+		SubmittedAt: time.Now(),
+		cancel:      cancel,
+		done:        make(chan struct{}),
+	}
+	a.mu.Lock()
+	a.evict()
+	a.handles[h.ID] = h
+	a.mu.Unlock()
+
+	opts = append([]option.RequestOption{option.WithJSONSet("metadata."+MetadataAsyncRunID, h.ID)}, opts...)
+	go func() {
+		defer cancel()
+		h.completion, h.err = a.completions.New(runCtx, params, opts...)
+		h.finishedAt = time.Now()
+		close(h.done)
+	}()
+	return h
+}
+
+// Handle returns the handle of a run submitted in this process, until its
+// retention expires. The handles submitted before a restart are not found.
+func (a *AsyncRuns) Handle(id string) (*RunHandle, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.evict()
+	h, ok := a.handles[id]
+	return h, ok
+}
+
+// evict removes the handles of the runs finished for longer than the
+// retention. It must be called with mu held.
+func (a *AsyncRuns) evict() {
+	for id, h := range a.handles {
+		select {
+		case <-h.done:
+			if time.Since(h.finishedAt) > a.retention {
+				delete(a.handles, id)
+			}
+		default:
+		}
+	}
+}
+
+// Done is closed when the run ends.
+func (h *RunHandle) Done() <-chan struct{} {
+	return h.done
+}
+
+// Poll returns the result of the run without waiting, or [ErrRunNotDone].
+func (h *RunHandle) Poll() (*openai.ChatCompletion, error) {
+	select {
+	case <-h.done:
+		return h.completion, h.err
+	default:
+		return nil, ErrRunNotDone
+	}
+}
+
+// Wait waits for the end of the run and returns its result. Canceling ctx
+// stops the wait, not the run.
+func (h *RunHandle) Wait(ctx context.Context) (*openai.ChatCompletion, error) {
+	select {
+	case <-h.done:
+		return h.completion, h.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Cancel stops the run. Its result is then the cancellation error.
+func (h *RunHandle) Cancel() {
+	h.cancel()
+}
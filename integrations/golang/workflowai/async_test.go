@@ -0,0 +1,96 @@
+package workflowai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+func TestAsyncRuns(t *testing.T) {
+	release := make(chan struct{})
+	asyncIDs := make(chan string, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Metadata map[string]string `json:"metadata"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		asyncIDs <- body.Metadata[MetadataAsyncRunID]
+		select {
+		case <-release:
+		case <-r.Context().Done():
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, testCompletion)
+	}))
+	defer server.Close()
+
+	client := NewClient(option.WithBaseURL(server.URL+"/v1"), option.WithAPIKey("test"), option.WithMaxRetries(0))
+	runs := NewAsyncRuns(&client.Chat.Completions)
+	params := openai.ChatCompletionNewParams{
+		Model:    "my-agent/gpt-4o-mini-latest",
+		Messages: []openai.ChatCompletionMessageParamUnion{openai.UserMessage("Hello")},
+	}
+
+	// The run outlives the context of the submitting request
+	ctx, cancel := context.WithCancel(context.Background())
+	h := runs.Submit(ctx, params)
+	cancel()
+	if id := <-asyncIDs; id != h.ID {
+		t.Errorf("run tagged with %q, want %q", id, h.ID)
+	}
+	if _, err := h.Poll(); !errors.Is(err, ErrRunNotDone) {
+		t.Errorf("expected the run to be in progress, got %v", err)
+	}
+	waitCtx, waitCancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer waitCancel()
+	if _, err := h.Wait(waitCtx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the wait to time out, got %v", err)
+	}
+
+	close(release)
+	found, ok := runs.Handle(h.ID)
+	if !ok {
+		t.Fatal("handle not found")
+	}
+	completion, err := found.Wait(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if completion.ID != "my-agent/run-1" {
+		t.Errorf("unexpected completion %s", completion.ID)
+	}
+	if polled, err := h.Poll(); err != nil || polled != completion {
+		t.Errorf("unexpected poll %v %v", polled, err)
+	}
+	if _, ok := runs.Handle("async_unknown"); ok {
+		t.Error("unexpected handle")
+	}
+}
+
+func TestAsyncRunsCancelAndRetention(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	client := NewClient(option.WithBaseURL(server.URL+"/v1"), option.WithAPIKey("test"), option.WithMaxRetries(0))
+	runs := NewAsyncRuns(&client.Chat.Completions, WithAsyncRetention(0))
+	h := runs.Submit(context.Background(), openai.ChatCompletionNewParams{Model: "my-agent/gpt-4o-mini-latest"})
+	h.Cancel()
+	if _, err := h.Wait(context.Background()); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected a cancellation error, got %v", err)
+	}
+	time.Sleep(time.Millisecond)
+	if _, ok := runs.Handle(h.ID); ok {
+		t.Error("expected the handle to be evicted")
+	}
+}
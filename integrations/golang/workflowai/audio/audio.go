@@ -0,0 +1,284 @@
+// Package audio converts audio to the formats accepted by the models, 16kHz
+// mono WAV or PCM, and splits long recordings for transcription, without
+// external tools such as ffmpeg.
+//
+//	clip, err := audio.DecodeFile("meeting.mp3")
+//	text, err := audio.Transcribe(ctx, &client.Audio.Transcriptions, clip, openai.AudioTranscriptionNewParams{
+//		Model: openai.AudioModelWhisper1,
+//	})
+//
+// WAV (integer and float PCM) and MP3 files are decoded. Audio is encoded as
+// 16-bit PCM WAV: Go has no MP3 encoder, and MP3 files can be sent as is.
+package audio
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"time"
+
+	"github.com/hajimehoshi/go-mp3"
+	"github.com/openai/openai-go"
+)
+
+// SpeechRate is the sample rate of the speech models, 16kHz.
+const SpeechRate = 16000
+
+// Clip is a mono recording, with samples between -1 and 1.
+type Clip struct {
+	Samples    []float32
+	SampleRate int
+}
+
+// Duration returns the duration of the clip.
+func (c *Clip) Duration() time.Duration {
+	if c.SampleRate == 0 {
+		return 0
+	}
+	return time.Duration(len(c.Samples)) * time.Second / time.Duration(c.SampleRate)
+}
+
+// DecodeFile decodes a WAV or MP3 file, mixed down to mono.
+func DecodeFile(path string) (*Clip, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	clip, err := Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", err, path)
+	}
+	return clip, nil
+}
+
+// Decode decodes WAV or MP3 audio, detected from its header, mixed down to
+// mono.
+func Decode(r io.Reader) (*Clip, error) {
+	br := bufio.NewReader(r)
+	header, _ := br.Peek(12)
+	switch {
+	case len(header) == 12 && string(header[0:4]) == "RIFF" && string(header[8:12]) == "WAVE":
+		return decodeWAV(br)
+	case len(header) >= 3 && string(header[0:3]) == "ID3",
+		len(header) >= 2 && header[0] == 0xFF && header[1]&0xE0 == 0xE0:
+		return decodeMP3(br)
+	}
+	return nil, errors.New("audio: unsupported format, expected WAV or MP3")
+}
+
+func decodeWAV(r io.Reader) (*Clip, error) {
+	var header [12]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, fmt.Errorf("audio: reading WAV header: %w", err)
+	}
+	var format, channels, bits uint16
+	var rate uint32
+	for {
+		var chunk [8]byte
+		if _, err := io.ReadFull(r, chunk[:]); err != nil {
+			return nil, errors.New("audio: WAV without data chunk")
+		}
+		size := int64(binary.LittleEndian.Uint32(chunk[4:8]))
+		switch string(chunk[0:4]) {
+		case "fmt ":
+			data := make([]byte, size+size%2)
+			if _, err := io.ReadFull(r, data); err != nil || size < 16 {
+				return nil, errors.New("audio: invalid WAV format chunk")
+			}
+			format = binary.LittleEndian.Uint16(data[0:2])
+			channels = binary.LittleEndian.Uint16(data[2:4])
+			rate = binary.LittleEndian.Uint32(data[4:8])
+			bits = binary.LittleEndian.Uint16(data[14:16])
+			// WAVE_FORMAT_EXTENSIBLE carries the format in its sub-format GUID
+			if format == 0xFFFE && size >= 26 {
+				format = binary.LittleEndian.Uint16(data[24:26])
+			}
+		case "data":
+			if channels == 0 || rate == 0 {
+				return nil, errors.New("audio: WAV data before the format chunk")
+			}
+			data, err := io.ReadAll(io.LimitReader(r, size))
+			if err != nil {
+				return nil, fmt.Errorf("audio: reading WAV samples: %w", err)
+			}
+			sample, err := wavSampleReader(format, bits)
+			if err != nil {
+				return nil, err
+			}
+			width := int(bits / 8)
+			frames := len(data) / (width * int(channels))
+			clip := &Clip{Samples: make([]float32, frames), SampleRate: int(rate)}
+			for i := 0; i < frames; i++ {
+				var sum float32
+				for ch := 0; ch < int(channels); ch++ {
+					offset := (i*int(channels) + ch) * width
+					sum += sample(data[offset : offset+width])
+				}
+				clip.Samples[i] = sum / float32(channels)
+			}
+			return clip, nil
+		default:
+			if _, err := io.CopyN(io.Discard, r, size+size%2); err != nil {
+				return nil, errors.New("audio: WAV without data chunk")
+			}
+		}
+	}
+}
+
+func wavSampleReader(format, bits uint16) (func([]byte) float32, error) {
+	switch {
+	case format == 1 && bits == 8:
+		return func(b []byte) float32 { return (float32(b[0]) - 128) / 128 }, nil
+	case format == 1 && bits == 16:
+		return func(b []byte) float32 { return float32(int16(binary.LittleEndian.Uint16(b))) / 32768 }, nil
+	case format == 1 && bits == 24:
+		return func(b []byte) float32 {
+			v := int32(b[0]) | int32(b[1])<<8 | int32(int8(b[2]))<<16
+			return float32(v) / (1 << 23)
+		}, nil
+	case format == 1 && bits == 32:
+		return func(b []byte) float32 { return float32(int32(binary.LittleEndian.Uint32(b))) / (1 << 31) }, nil
+	case format == 3 && bits == 32:
+		return func(b []byte) float32 { return math.Float32frombits(binary.LittleEndian.Uint32(b)) }, nil
+	case format == 3 && bits == 64:
+		return func(b []byte) float32 { return float32(math.Float64frombits(binary.LittleEndian.Uint64(b))) }, nil
+	}
+	return nil, fmt.Errorf("audio: unsupported WAV encoding %d with %d bits", format, bits)
+}
+
+func decodeMP3(r io.Reader) (*Clip, error) {
+	decoder, err := mp3.NewDecoder(r)
+	if err != nil {
+		return nil, fmt.Errorf("audio: decoding MP3: %w", err)
+	}
+	// The decoder outputs 16-bit stereo samples
+	data, err := io.ReadAll(decoder)
+	if err != nil {
+		return nil, fmt.Errorf("audio: decoding MP3: %w", err)
+	}
+	clip := &Clip{Samples: make([]float32, len(data)/4), SampleRate: decoder.SampleRate()}
+	for i := range clip.Samples {
+		left := int16(binary.LittleEndian.Uint16(data[i*4:]))
+		right := int16(binary.LittleEndian.Uint16(data[i*4+2:]))
+		clip.Samples[i] = (float32(left) + float32(right)) / 65536
+	}
+	return clip, nil
+}
+
+// Resample returns the clip at rate, e.g. [SpeechRate]. Downsampling
+// averages the samples of each output sample, which filters out most of the
+// frequencies the lower rate cannot carry.
+func (c *Clip) Resample(rate int) *Clip {
+	if rate == c.SampleRate || len(c.Samples) == 0 {
+		return &Clip{Samples: c.Samples, SampleRate: rate}
+	}
+	ratio := float64(c.SampleRate) / float64(rate)
+	out := make([]float32, int(float64(len(c.Samples))/ratio))
+	for i := range out {
+		pos := float64(i) * ratio
+		if ratio > 1 {
+			start, end := int(pos), min(int(pos+ratio), len(c.Samples))
+			var sum float32
+			for _, s := range c.Samples[start:end] {
+				sum += s
+			}
+			out[i] = sum / float32(max(end-start, 1))
+			continue
+		}
+		j := int(pos)
+		next := min(j+1, len(c.Samples)-1)
+		frac := float32(pos - float64(j))
+		out[i] = c.Samples[j]*(1-frac) + c.Samples[next]*frac
+	}
+	return &Clip{Samples: out, SampleRate: rate}
+}
+
+// PCM16 returns the samples as 16-bit little-endian PCM, the format of the
+// realtime API at 24kHz.
+func (c *Clip) PCM16() []byte {
+	data := make([]byte, len(c.Samples)*2)
+	for i, s := range c.Samples {
+		v := math.Round(float64(s) * 32767)
+		binary.LittleEndian.PutUint16(data[i*2:], uint16(int16(max(-32768, min(32767, v)))))
+	}
+	return data
+}
+
+// WAV returns the clip as a 16-bit PCM WAV file.
+func (c *Clip) WAV() []byte {
+	pcm := c.PCM16()
+	var buf bytes.Buffer
+	buf.Grow(44 + len(pcm))
+	buf.WriteString("RIFF")
+	binary.Write(&buf, binary.LittleEndian, uint32(36+len(pcm)))
+	buf.WriteString("WAVEfmt ")
+	binary.Write(&buf, binary.LittleEndian, struct {
+		Size             uint32
+		Format, Channels uint16
+		Rate, ByteRate   uint32
+		Align, Bits      uint16
+	}{16, 1, 1, uint32(c.SampleRate), uint32(c.SampleRate * 2), 2, 16})
+	buf.WriteString("data")
+	binary.Write(&buf, binary.LittleEndian, uint32(len(pcm)))
+	buf.Write(pcm)
+	return buf.Bytes()
+}
+
+// Part returns the clip as an input audio content part, as WAV at
+// [SpeechRate].
+func (c *Clip) Part() openai.ChatCompletionContentPartUnionParam {
+	return openai.InputAudioContentPart(openai.ChatCompletionContentPartInputAudioInputAudioParam{
+		Data:   base64.StdEncoding.EncodeToString(c.Resample(SpeechRate).WAV()),
+		Format: "wav",
+	})
+}
+
+// minSplitRate is the lowest sample rate of the clips Split cuts, whose
+// 100ms frames have at least 2 samples.
+const minSplitRate = 20
+
+// Split splits the clip in chunks of at most maxDuration. Each cut is made at
+// the quietest 100ms of the last tenth of the chunk, so that words are
+// rarely cut. Clips sampled below 20Hz are rejected.
+func (c *Clip) Split(maxDuration time.Duration) ([]*Clip, error) {
+	if c.SampleRate < minSplitRate {
+		return nil, fmt.Errorf("audio: cannot split a clip sampled at %dHz, below %dHz", c.SampleRate, minSplitRate)
+	}
+	size := int(maxDuration.Seconds() * float64(c.SampleRate))
+	if size <= 0 || len(c.Samples) <= size {
+		return []*Clip{c}, nil
+	}
+	frame := c.SampleRate / 10
+	var chunks []*Clip
+	for start := 0; start < len(c.Samples); {
+		end := start + size
+		if end >= len(c.Samples) {
+			chunks = append(chunks, &Clip{Samples: c.Samples[start:], SampleRate: c.SampleRate})
+			break
+		}
+		cut, quietest := end, math.Inf(1)
+		for at := end - frame; at >= end-size/10 && at > start; at -= frame / 2 {
+			if energy := rms(c.Samples[at : at+frame]); energy < quietest {
+				cut, quietest = at+frame/2, energy
+			}
+		}
+		chunks = append(chunks, &Clip{Samples: c.Samples[start:cut], SampleRate: c.SampleRate})
+		start = cut
+	}
+	return chunks, nil
+}
+
+func rms(samples []float32) float64 {
+	var sum float64
+	for _, s := range samples {
+		sum += float64(s) * float64(s)
+	}
+	return math.Sqrt(sum / float64(len(samples)))
+}
@@ -0,0 +1,144 @@
+package audio
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+// stereoWAV returns a 16-bit stereo WAV of a 440Hz tone at rate, with a
+// silence from silenceAt for 200ms.
+func stereoWAV(rate int, duration, silenceAt time.Duration) []byte {
+	frames := int(duration.Seconds() * float64(rate))
+	pcm := make([]byte, frames*4)
+	for i := 0; i < frames; i++ {
+		at := time.Duration(i) * time.Second / time.Duration(rate)
+		v := int16(math.Sin(2*math.Pi*440*float64(i)/float64(rate)) * 16000)
+		if at >= silenceAt && at < silenceAt+200*time.Millisecond {
+			v = 0
+		}
+		binary.LittleEndian.PutUint16(pcm[i*4:], uint16(v))
+		binary.LittleEndian.PutUint16(pcm[i*4+2:], uint16(v))
+	}
+	var buf bytes.Buffer
+	buf.WriteString("RIFF")
+	binary.Write(&buf, binary.LittleEndian, uint32(36+len(pcm)+12))
+	buf.WriteString("WAVE")
+	// An unknown chunk before the format
+	buf.WriteString("LIST")
+	binary.Write(&buf, binary.LittleEndian, uint32(4))
+	buf.WriteString("INFO")
+	buf.WriteString("fmt ")
+	binary.Write(&buf, binary.LittleEndian, []uint32{16})
+	binary.Write(&buf, binary.LittleEndian, []uint16{1, 2})
+	binary.Write(&buf, binary.LittleEndian, []uint32{uint32(rate), uint32(rate * 4)})
+	binary.Write(&buf, binary.LittleEndian, []uint16{4, 16})
+	buf.WriteString("data")
+	binary.Write(&buf, binary.LittleEndian, uint32(len(pcm)))
+	buf.Write(pcm)
+	return buf.Bytes()
+}
+
+func TestDecodeAndResample(t *testing.T) {
+	clip, err := Decode(bytes.NewReader(stereoWAV(44100, time.Second, time.Hour)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if clip.SampleRate != 44100 || len(clip.Samples) != 44100 || clip.Duration() != time.Second {
+		t.Fatalf("unexpected clip of %d samples at %dHz", len(clip.Samples), clip.SampleRate)
+	}
+
+	speech := clip.Resample(SpeechRate)
+	if len(speech.Samples) != SpeechRate {
+		t.Errorf("unexpected %d samples", len(speech.Samples))
+	}
+	decoded, err := Decode(bytes.NewReader(speech.WAV()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.SampleRate != SpeechRate || len(decoded.Samples) != SpeechRate {
+		t.Errorf("unexpected round trip of %d samples at %dHz", len(decoded.Samples), decoded.SampleRate)
+	}
+	for i := range decoded.Samples {
+		if math.Abs(float64(decoded.Samples[i]-speech.Samples[i])) > 1e-4 {
+			t.Fatalf("sample %d is %v, want %v", i, decoded.Samples[i], speech.Samples[i])
+		}
+	}
+
+	if _, err := Decode(bytes.NewReader([]byte("OggS not supported"))); err == nil {
+		t.Error("expected an unsupported format error")
+	}
+}
+
+func TestSplit(t *testing.T) {
+	clip, err := Decode(bytes.NewReader(stereoWAV(SpeechRate, 25*time.Second, 9500*time.Millisecond)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunks, err := clip.Split(10 * time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chunks) != 3 {
+		t.Fatalf("unexpected %d chunks", len(chunks))
+	}
+	// The first cut is in the silence
+	if d := chunks[0].Duration(); d < 9500*time.Millisecond || d > 9700*time.Millisecond {
+		t.Errorf("unexpected first chunk of %s", d)
+	}
+	total := 0
+	for _, chunk := range chunks {
+		if chunk.Duration() > 10*time.Second {
+			t.Errorf("chunk of %s is too long", chunk.Duration())
+		}
+		total += len(chunk.Samples)
+	}
+	if total != len(clip.Samples) {
+		t.Errorf("chunks have %d samples, want %d", total, len(clip.Samples))
+	}
+
+	low := &Clip{Samples: make([]float32, 100), SampleRate: 10}
+	if _, err := low.Split(time.Second); err == nil {
+		t.Error("expected a clip below 20Hz to be rejected")
+	}
+}
+
+type fakeTranscriptions struct {
+	prompts []string
+	sizes   []int
+}
+
+func (f *fakeTranscriptions) New(ctx context.Context, body openai.AudioTranscriptionNewParams, opts ...option.RequestOption) (*openai.Transcription, error) {
+	data, _ := io.ReadAll(body.File)
+	f.prompts = append(f.prompts, body.Prompt.Value)
+	f.sizes = append(f.sizes, len(data))
+	return &openai.Transcription{Text: " part " + string(rune('a'+len(f.prompts)-1)) + " "}, nil
+}
+
+func TestTranscribe(t *testing.T) {
+	clip := &Clip{Samples: make([]float32, 48000*int((MaxChunkDuration+time.Minute).Seconds())), SampleRate: 48000}
+	service := &fakeTranscriptions{}
+	text, err := Transcribe(context.Background(), service, clip, openai.AudioTranscriptionNewParams{
+		Model:  openai.AudioModelWhisper1,
+		Prompt: openai.String("WorkflowAI"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != "part a part b" {
+		t.Errorf("unexpected text %q", text)
+	}
+	if len(service.prompts) != 2 || service.prompts[0] != "WorkflowAI" || service.prompts[1] != "part a" {
+		t.Errorf("unexpected prompts %q", service.prompts)
+	}
+	if service.sizes[0] > 25<<20 {
+		t.Errorf("chunk of %d bytes exceeds the upload limit", service.sizes[0])
+	}
+}
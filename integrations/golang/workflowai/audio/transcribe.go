@@ -0,0 +1,60 @@
+package audio
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+// MaxChunkDuration is the duration of the chunks sent by [Transcribe]: 10
+// minutes of 16kHz WAV are 19MB, below the 25MB limit of the transcription
+// endpoint.
+const MaxChunkDuration = 10 * time.Minute
+
+// TranscriptionService is the interface of
+// [openai.AudioTranscriptionService]. WorkflowAI has no transcription
+// endpoint, so the service is the one of an OpenAI client, not of a
+// WorkflowAI client.
+type TranscriptionService interface {
+	New(ctx context.Context, body openai.AudioTranscriptionNewParams, opts ...option.RequestOption) (*openai.Transcription, error)
+}
+
+var _ TranscriptionService = (*openai.AudioTranscriptionService)(nil)
+
+// Transcribe transcribes a clip of any duration: it is resampled to
+// [SpeechRate] and split in chunks of [MaxChunkDuration], transcribed in
+// order. The end of the transcript of a chunk is the prompt of the next one,
+// for the model to keep the spelling and context. params.File is ignored.
+func Transcribe(ctx context.Context, transcriptions TranscriptionService, clip *Clip, params openai.AudioTranscriptionNewParams, opts ...option.RequestOption) (string, error) {
+	chunks, err := clip.Resample(SpeechRate).Split(MaxChunkDuration)
+	if err != nil {
+		return "", err
+	}
+	texts := make([]string, 0, len(chunks))
+	for i, chunk := range chunks {
+		p := params
+		p.File = openai.File(bytes.NewReader(chunk.WAV()), fmt.Sprintf("chunk-%d.wav", i+1), "audio/wav")
+		if i > 0 {
+			p.Prompt = openai.String(lastWords(texts[i-1], 50))
+		}
+		res, err := transcriptions.New(ctx, p, opts...)
+		if err != nil {
+			return "", fmt.Errorf("audio: transcribing chunk %d of %d: %w", i+1, len(chunks), err)
+		}
+		texts = append(texts, strings.TrimSpace(res.Text))
+	}
+	return strings.Join(texts, " "), nil
+}
+
+func lastWords(text string, n int) string {
+	words := strings.Fields(text)
+	if len(words) > n {
+		words = words[len(words)-n:]
+	}
+	return strings.Join(words, " ")
+}
@@ -0,0 +1,248 @@
+// Package audit emits a structured audit event for every chat completion sent
+// by a client: who called which agent, with what metadata, the cost and the
+// outcome. Message content is never included.
+//
+// Events are written as JSON lines to a [Sink] (a file, syslog or an HTTP
+// endpoint). Each line contains the hash of the previous one, so that a
+// modified, inserted or removed line is detected by [Verify], except the
+// removal of the last lines.
+//
+//	sink, err := audit.OpenFile("/var/log/workflowai/audit.jsonl")
+//	if err != nil {
+//		return err
+//	}
+//	logger := audit.NewLogger(sink)
+//	client := workflowai.NewClient(option.WithMiddleware(logger.Middleware()))
+//	completion, err := client.Chat.Completions.New(audit.ContextWithActor(ctx, userID), params)
+package audit
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openai/openai-go/option"
+
+	"github.com/workflowai/workflowai/go/examples/workflowai"
+)
+
+// Event is an audited chat completion.
+type Event struct {
+	Time time.Time `json:"time"`
+	// Actor is the caller set with ContextWithActor
+	Actor     string            `json:"actor,omitempty"`
+	AgentID   string            `json:"agent_id,omitempty"`
+	Model     string            `json:"model"`
+	RunID     string            `json:"run_id,omitempty"`
+	VersionID string            `json:"version_id,omitempty"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+	CostUSD   float64           `json:"cost_usd"`
+	LatencyMS int64             `json:"latency_ms"`
+	// Outcome is "success" or "error"
+	Outcome    string `json:"outcome"`
+	StatusCode int    `json:"status_code,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// Entry is a line of the audit log. Hash is the SHA-256 of PrevHash followed
+// by the bytes of Event.
+type Entry struct {
+	Seq      int64           `json:"seq"`
+	Event    json.RawMessage `json:"event"`
+	PrevHash string          `json:"prev_hash"`
+	Hash     string          `json:"hash"`
+}
+
+func entryHash(prevHash string, event []byte) string {
+	h := sha256.New()
+	h.Write([]byte(prevHash))
+	h.Write(event)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Sink receives the lines of the audit log, in order. Lines do not end with
+// a newline.
+type Sink interface {
+	WriteEntry(ctx context.Context, line []byte) error
+}
+
+// Resumer is implemented by sinks that can read back the last entry they
+// stored, so that a new logger continues the hash chain.
+type Resumer interface {
+	LastEntry() (Entry, bool)
+}
+
+type actorKey struct{}
+
+// ContextWithActor returns a context whose completions are audited as made
+// by actor, e.g. a user or service ID.
+func ContextWithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// Logger chains the events and writes them to its sink.
+type Logger struct {
+	sink    Sink
+	onError func(error)
+
+	mu       sync.Mutex
+	seq      int64
+	prevHash string
+}
+
+type Option func(*Logger)
+
+// WithErrorHandler is called when an event could not be written. By default
+// errors are logged with the standard logger. Failing to write an event never
+// fails the completion.
+func WithErrorHandler(fn func(error)) Option {
+	return func(l *Logger) {
+		l.onError = fn
+	}
+}
+
+// NewLogger creates a logger writing to sink. When the sink implements
+// [Resumer], the chain continues after its last entry.
+func NewLogger(sink Sink, opts ...Option) *Logger {
+	l := &Logger{
+		sink: sink,
+		onError: func(err error) {
+			log.Printf("audit: failed to write event: %v", workflowai.ScrubError(err))
+		},
+	}
+	if r, ok := sink.(Resumer); ok {
+		if last, ok := r.LastEntry(); ok {
+			l.seq, l.prevHash = last.Seq, last.Hash
+		}
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+// Emit appends an event to the log.
+func (l *Logger) Emit(ctx context.Context, event Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	entry := Entry{Seq: l.seq + 1, Event: data, PrevHash: l.prevHash, Hash: entryHash(l.prevHash, data)}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if err := l.sink.WriteEntry(ctx, line); err != nil {
+		return err
+	}
+	l.seq, l.prevHash = entry.Seq, entry.Hash
+	return nil
+}
+
+// Middleware returns a client middleware that emits an event for every chat
+// completion, once its response has been fully read.
+func (l *Logger) Middleware() option.Middleware {
+	return func(req *http.Request, next option.MiddlewareNext) (*http.Response, error) {
+		if req.Method != http.MethodPost || !strings.HasSuffix(req.URL.Path, "/chat/completions") {
+			return next(req)
+		}
+		var payload struct {
+			Metadata map[string]string `json:"metadata"`
+		}
+		if req.GetBody != nil {
+			if body, err := req.GetBody(); err == nil {
+				_ = json.NewDecoder(body).Decode(&payload)
+				body.Close()
+			}
+		}
+		actor, _ := req.Context().Value(actorKey{}).(string)
+
+		store := recordFunc(func(ctx context.Context, record workflowai.RunRecord) error {
+			event := Event{
+				Time:       record.CreatedAt.UTC(),
+				Actor:      actor,
+				AgentID:    record.AgentID,
+				Model:      record.Model,
+				RunID:      record.RunID,
+				VersionID:  record.VersionID,
+				Metadata:   payload.Metadata,
+				CostUSD:    record.CostUSD,
+				LatencyMS:  record.Latency.Milliseconds(),
+				Outcome:    "success",
+				StatusCode: record.StatusCode,
+			}
+			if event.AgentID == "" {
+				event.AgentID, _, _ = strings.Cut(record.Model, "/")
+			}
+			if record.Error != "" || record.StatusCode >= 400 {
+				event.Outcome = "error"
+				event.Error = workflowai.Scrub(record.Error)
+			}
+			return l.Emit(ctx, event)
+		})
+		// The run log middleware extracts the run fields, streams included
+		return workflowai.RunLogMiddleware(store, workflowai.WithRunLogContentLimit(1), workflowai.WithRunLogErrorHandler(l.onError))(req, next)
+	}
+}
+
+type recordFunc func(ctx context.Context, record workflowai.RunRecord) error
+
+func (f recordFunc) RecordRun(ctx context.Context, record workflowai.RunRecord) error {
+	return f(ctx, record)
+}
+
+// Verify reads an audit log and checks the hash chain from its first entry,
+// which must have the sequence number 1 and no previous hash, so that removing
+// the head of the log is detected. It returns the number of entries and a
+// [*ChainError] for the first inconsistent line.
+//
+// Removing the last entries cannot be detected from the log alone: compare
+// the number of entries, or the hash of the last one, with a copy kept
+// elsewhere, e.g. in the HTTP sink.
+func Verify(data []byte) (int64, error) {
+	var prev Entry
+	var n int64
+	for i, line := range bytes.Split(bytes.TrimSpace(data), []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var entry Entry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return n, &ChainError{Line: i + 1, Reason: "invalid entry: " + err.Error()}
+		}
+		switch {
+		case n == 0 && (entry.Seq != 1 || entry.PrevHash != ""):
+			return n, &ChainError{Line: i + 1, Reason: "missing head of the log"}
+		case n > 0 && entry.Seq != prev.Seq+1:
+			return n, &ChainError{Line: i + 1, Reason: "sequence gap"}
+		case n > 0 && entry.PrevHash != prev.Hash:
+			return n, &ChainError{Line: i + 1, Reason: "previous hash mismatch"}
+		case entry.Hash != entryHash(entry.PrevHash, entry.Event):
+			return n, &ChainError{Line: i + 1, Reason: "hash mismatch"}
+		}
+		prev = entry
+		n++
+	}
+	return n, nil
+}
+
+// ChainError reports a line of the audit log that breaks the hash chain.
+type ChainError struct {
+	Line   int
+	Reason string
+}
+
+func (e *ChainError) Error() string {
+	return fmt.Sprintf("audit: line %d: %s", e.Line, e.Reason)
+}
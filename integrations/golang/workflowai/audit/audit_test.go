@@ -0,0 +1,128 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+const testCompletion = `{"id":"my-agent/run-1","object":"chat.completion","created":1,"model":"gpt-4o","version_id":"v1",
+"choices":[{"index":0,"finish_reason":"stop","cost_usd":0.5,"message":{"role":"assistant","content":"Secret answer"}}]}`
+
+func newClient(t *testing.T, logger *Logger) openai.Client {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, testCompletion)
+	}))
+	t.Cleanup(server.Close)
+	return openai.NewClient(option.WithBaseURL(server.URL+"/v1/"), option.WithAPIKey("key"), option.WithMiddleware(logger.Middleware()))
+}
+
+func send(t *testing.T, client openai.Client, actor string) {
+	t.Helper()
+	_, err := client.Chat.Completions.New(ContextWithActor(context.Background(), actor), openai.ChatCompletionNewParams{
+		Model:    "my-agent/gpt-4o",
+		Messages: []openai.ChatCompletionMessageParamUnion{openai.UserMessage("Secret question")},
+		Metadata: map[string]string{"feature": "search"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLogger_File(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	sink, err := OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := newClient(t, NewLogger(sink))
+	send(t, client, "alice")
+	send(t, client, "bob")
+	sink.Close()
+
+	// A new logger continues the chain
+	sink, err = OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	send(t, newClient(t, NewLogger(sink)), "carol")
+	sink.Close()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "Secret") {
+		t.Fatalf("message content was audited: %s", data)
+	}
+	n, err := Verify(data)
+	if err != nil || n != 3 {
+		t.Fatalf("expected 3 valid entries, got %d: %v", n, err)
+	}
+
+	var entry Entry
+	var event Event
+	_ = json.Unmarshal(bytes.Split(data, []byte("\n"))[1], &entry)
+	_ = json.Unmarshal(entry.Event, &event)
+	if event.Actor != "bob" || event.AgentID != "my-agent" || event.RunID != "run-1" || event.CostUSD != 0.5 ||
+		event.Metadata["feature"] != "search" || event.Outcome != "success" {
+		t.Errorf("unexpected event %+v", event)
+	}
+
+	tampered := bytes.Replace(data, []byte(`"actor":"bob"`), []byte(`"actor":"eve"`), 1)
+	_, err = Verify(tampered)
+	var chainErr *ChainError
+	if !errors.As(err, &chainErr) || chainErr.Line != 2 {
+		t.Errorf("expected line 2 to be reported, got %v", err)
+	}
+
+	lines := bytes.Split(bytes.TrimSpace(data), []byte("\n"))
+	removed := bytes.Join([][]byte{lines[0], lines[2]}, []byte("\n"))
+	if _, err := Verify(removed); !errors.As(err, &chainErr) || chainErr.Reason != "sequence gap" {
+		t.Errorf("expected a sequence gap, got %v", err)
+	}
+	headless := bytes.Join(lines[1:], []byte("\n"))
+	if _, err := Verify(headless); !errors.As(err, &chainErr) || chainErr.Line != 1 || chainErr.Reason != "missing head of the log" {
+		t.Errorf("expected the missing first line to be reported, got %v", err)
+	}
+}
+
+func TestHTTPSink(t *testing.T) {
+	var received [][]byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		body := new(bytes.Buffer)
+		_, _ = body.ReadFrom(r.Body)
+		received = append(received, body.Bytes())
+	}))
+	defer server.Close()
+
+	logger := NewLogger(NewHTTPSink(server.URL, nil, http.Header{"Authorization": {"Bearer token"}}))
+	if err := logger.Emit(context.Background(), Event{Model: "m", Outcome: "success"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Verify(bytes.Join(received, nil)); err != nil || len(received) != 1 {
+		t.Fatalf("unexpected entries %q: %v", received, err)
+	}
+
+	unauthorized := NewLogger(NewHTTPSink(server.URL, nil, nil))
+	if err := unauthorized.Emit(context.Background(), Event{Model: "m"}); err == nil {
+		t.Error("expected an error for a 401 response")
+	}
+}
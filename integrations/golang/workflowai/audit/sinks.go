@@ -0,0 +1,116 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+)
+
+// FileSink appends entries to a file, one per line, and syncs the file after
+// each write.
+type FileSink struct {
+	mu   sync.Mutex
+	file *os.File
+	last *Entry
+}
+
+var (
+	_ Sink    = (*FileSink)(nil)
+	_ Resumer = (*FileSink)(nil)
+)
+
+// OpenFile opens or creates an append-only audit file. The last entry of an
+// existing file is read so that a new [Logger] continues its chain.
+func OpenFile(path string) (*FileSink, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	s := &FileSink{file: f}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		if line := bytes.TrimSpace(scanner.Bytes()); len(line) > 0 {
+			var entry Entry
+			if err := json.Unmarshal(line, &entry); err != nil {
+				f.Close()
+				return nil, fmt.Errorf("audit: invalid entry in %s: %w", path, err)
+			}
+			s.last = &entry
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *FileSink) LastEntry() (Entry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.last == nil {
+		return Entry{}, false
+	}
+	return *s.last, true
+}
+
+func (s *FileSink) WriteEntry(_ context.Context, line []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	return s.file.Sync()
+}
+
+func (s *FileSink) Close() error {
+	return s.file.Close()
+}
+
+// HTTPSink posts each entry as a JSON line to an endpoint, e.g. a log
+// collector. Responses other than 2xx are errors.
+type HTTPSink struct {
+	url     string
+	client  *http.Client
+	headers http.Header
+}
+
+var _ Sink = (*HTTPSink)(nil)
+
+// NewHTTPSink creates a sink posting to url with client, or
+// http.DefaultClient when nil. Headers are added to every request, e.g. an
+// authorization header.
+func NewHTTPSink(url string, client *http.Client, headers http.Header) *HTTPSink {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &HTTPSink{url: url, client: client, headers: headers}
+}
+
+func (s *HTTPSink) WriteEntry(ctx context.Context, line []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(append(line, '\n')))
+	if err != nil {
+		return err
+	}
+	for k, v := range s.headers {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	res, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	_, _ = io.Copy(io.Discard, res.Body)
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("audit: %s returned %s", s.url, res.Status)
+	}
+	return nil
+}
@@ -0,0 +1,25 @@
+//go:build !windows && !plan9
+
+package audit
+
+import (
+	"context"
+	"log/syslog"
+)
+
+// SyslogSink writes entries to syslog with the info severity.
+type SyslogSink struct {
+	writer *syslog.Writer
+}
+
+var _ Sink = (*SyslogSink)(nil)
+
+// NewSyslogSink creates a sink writing to w, e.g. the result of
+// syslog.Dial("udp", "logs:514", syslog.LOG_INFO|syslog.LOG_AUTH, "workflowai").
+func NewSyslogSink(w *syslog.Writer) *SyslogSink {
+	return &SyslogSink{writer: w}
+}
+
+func (s *SyslogSink) WriteEntry(_ context.Context, line []byte) error {
+	return s.writer.Info(string(line))
+}
@@ -0,0 +1,90 @@
+// Package awssecrets loads the WorkflowAI API key and the provider keys from
+// an AWS Secrets Manager secret.
+//
+//	cfg, err := config.LoadDefaultConfig(ctx)
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	secrets, err := awssecrets.New(ctx, secretsmanager.NewFromConfig(cfg), "workflowai/production")
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	client := workflowai.NewClient(option.WithMiddleware(
+//		workflowai.APIKeyMiddleware(secrets.Key("WORKFLOWAI_API_KEY")),
+//	))
+package awssecrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+
+	"github.com/workflowai/workflowai/go/examples/workflowai"
+)
+
+// Client is the part of [secretsmanager.Client] used by the package.
+type Client interface {
+	GetSecretValue(ctx context.Context, params *secretsmanager.GetSecretValueInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error)
+}
+
+type config struct {
+	ttl   time.Duration
+	stage string
+}
+
+type Option func(*config)
+
+// WithTTL sets how long the secret is cached before it is read again.
+// Defaults to 5 minutes.
+func WithTTL(ttl time.Duration) Option {
+	return func(c *config) {
+		c.ttl = ttl
+	}
+}
+
+// WithVersionStage reads a version stage other than AWSCURRENT, e.g.
+// AWSPENDING while testing a rotation.
+func WithVersionStage(stage string) Option {
+	return func(c *config) {
+		c.stage = stage
+	}
+}
+
+// New reads the secret secretID, a name or an ARN, and returns its values as
+// cached secrets. Secrets stored as a JSON object provide their string
+// fields, other secrets provide their whole value under secretID.
+func New(ctx context.Context, client Client, secretID string, opts ...Option) (*workflowai.Secrets, error) {
+	cfg := &config{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return workflowai.NewSecrets(ctx, func(ctx context.Context) (map[string]string, error) {
+		input := &secretsmanager.GetSecretValueInput{SecretId: &secretID}
+		if cfg.stage != "" {
+			input.VersionStage = &cfg.stage
+		}
+		out, err := client.GetSecretValue(ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("awssecrets: reading %s: %w", secretID, err)
+		}
+		value := string(out.SecretBinary)
+		if out.SecretString != nil {
+			value = *out.SecretString
+		}
+
+		var fields map[string]any
+		if err := json.Unmarshal([]byte(value), &fields); err != nil {
+			return map[string]string{secretID: value}, nil
+		}
+		values := make(map[string]string, len(fields))
+		for k, v := range fields {
+			if s, ok := v.(string); ok {
+				values[k] = s
+			}
+		}
+		return values, nil
+	}, cfg.ttl)
+}
@@ -0,0 +1,59 @@
+package awssecrets
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+)
+
+type fakeClient struct {
+	values map[string]string
+	inputs []*secretsmanager.GetSecretValueInput
+}
+
+func (c *fakeClient) GetSecretValue(_ context.Context, input *secretsmanager.GetSecretValueInput, _ ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error) {
+	c.inputs = append(c.inputs, input)
+	value, ok := c.values[*input.SecretId]
+	if !ok {
+		return nil, errors.New("ResourceNotFoundException")
+	}
+	return &secretsmanager.GetSecretValueOutput{SecretString: &value}, nil
+}
+
+func TestNew(t *testing.T) {
+	ctx := context.Background()
+	client := &fakeClient{values: map[string]string{
+		"workflowai/production": `{"WORKFLOWAI_API_KEY":"wai-key-1","OPENAI_API_KEY":"sk-1"}`,
+		"workflowai/plain":      "wai-plain",
+	}}
+
+	secrets, err := New(ctx, client, "workflowai/production", WithVersionStage("AWSPENDING"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *client.inputs[0].VersionStage != "AWSPENDING" {
+		t.Errorf("unexpected stage %v", client.inputs[0].VersionStage)
+	}
+	if v, _ := secrets.Get(ctx, "OPENAI_API_KEY", false); v != "sk-1" {
+		t.Errorf("unexpected provider key %q", v)
+	}
+
+	client.values["workflowai/production"] = `{"WORKFLOWAI_API_KEY":"wai-key-2"}`
+	if v, _ := secrets.Key("WORKFLOWAI_API_KEY").APIKey(ctx, true); v != "wai-key-2" {
+		t.Errorf("expected the rotated key, got %q", v)
+	}
+
+	plain, err := New(ctx, client, "workflowai/plain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := plain.Get(ctx, "workflowai/plain", false); v != "wai-plain" {
+		t.Errorf("unexpected plain secret %q", v)
+	}
+
+	if _, err := New(ctx, client, "missing"); err == nil {
+		t.Error("expected an error for a missing secret")
+	}
+}
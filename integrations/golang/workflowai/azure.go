@@ -0,0 +1,127 @@
+package workflowai
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/openai/openai-go/option"
+	"golang.org/x/oauth2"
+)
+
+// AzureScope is the scope of the Microsoft Entra ID tokens requested by
+// [AzureClientOptions].
+const AzureScope = "https://cognitiveservices.azure.com/.default"
+
+// AzureConfig configures a client for WorkflowAI fronted by an Azure
+// compatible path, using the Azure SDK conventions: the api-version query
+// parameter, deployment names in the path and api-key or Entra ID (AAD)
+// authentication.
+type AzureConfig struct {
+	// Endpoint is the URL of the gateway, without the "/openai" suffix.
+	// Defaults to AZURE_OPENAI_ENDPOINT.
+	Endpoint string
+	// APIVersion defaults to OPENAI_API_VERSION.
+	APIVersion string
+	// APIKey is sent in the api-key header. Defaults to AZURE_OPENAI_API_KEY.
+	APIKey string
+	// TokenCredential authenticates with Entra ID tokens instead of an API
+	// key, e.g. an azidentity.DefaultAzureCredential.
+	TokenCredential azcore.TokenCredential
+	// Deployments maps models to deployment names, e.g. "my-agent/gpt-4o" to
+	// "my-agent". Other models are used as deployment names.
+	Deployments map[string]string
+}
+
+// azureRoutes are the endpoints routed to a deployment, relative to the
+// "/openai" path.
+var azureRoutes = []string{"chat/completions", "completions", "embeddings", "images/generations", "audio/speech"}
+
+// AzureClientOptions returns the client options for cfg. Unlike the
+// openai-go azure package, the endpoint can have a path, e.g. the prefix of
+// an API gateway.
+func AzureClientOptions(cfg AzureConfig) ([]option.RequestOption, error) {
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = os.Getenv("AZURE_OPENAI_ENDPOINT")
+	}
+	if cfg.APIVersion == "" {
+		cfg.APIVersion = os.Getenv("OPENAI_API_VERSION")
+	}
+	if cfg.APIKey == "" && cfg.TokenCredential == nil {
+		cfg.APIKey = os.Getenv("AZURE_OPENAI_API_KEY")
+	}
+	if cfg.Endpoint == "" || cfg.APIVersion == "" {
+		return nil, errors.New("workflowai: an Azure endpoint and API version are required")
+	}
+
+	opts := []option.RequestOption{
+		option.WithBaseURL(strings.TrimRight(cfg.Endpoint, "/") + "/openai/"),
+		option.WithQueryAdd("api-version", cfg.APIVersion),
+		option.WithMiddleware(azureDeploymentMiddleware(cfg.Deployments)),
+	}
+	switch {
+	case cfg.TokenCredential != nil:
+		ts := azureTokenSource{credential: cfg.TokenCredential}
+		opts = append(opts, option.WithMiddleware(TokenSourceMiddleware(ts)))
+	case cfg.APIKey != "":
+		opts = append(opts, option.WithHeader("Api-Key", cfg.APIKey))
+	default:
+		return nil, errors.New("workflowai: an Azure API key or token credential is required")
+	}
+	return opts, nil
+}
+
+// azureDeploymentMiddleware moves the model of the request to the path, e.g.
+// "/openai/chat/completions" becomes "/openai/deployments/<deployment>/chat/completions".
+func azureDeploymentMiddleware(deployments map[string]string) option.Middleware {
+	return func(req *http.Request, next option.MiddlewareNext) (*http.Response, error) {
+		for _, route := range azureRoutes {
+			prefix, ok := strings.CutSuffix(req.URL.Path, "/openai/"+route)
+			if !ok {
+				continue
+			}
+			body, err := readRequestBody(req)
+			if err != nil {
+				return nil, err
+			}
+			payload, err := decodeJSONObject(body)
+			if err != nil {
+				break
+			}
+			model, _ := payload["model"].(string)
+			deployment := model
+			if d, ok := deployments[model]; ok {
+				deployment = d
+			}
+			if deployment == "" {
+				break
+			}
+			req.URL.Path = prefix + "/openai/deployments/" + deployment + "/" + route
+			req.URL.RawPath = escapedPath(prefix) + "/openai/deployments/" + url.PathEscape(deployment) + "/" + route
+			break
+		}
+		return next(req)
+	}
+}
+
+func escapedPath(path string) string {
+	return (&url.URL{Path: path}).EscapedPath()
+}
+
+// azureTokenSource adapts an Azure credential to an oauth2.TokenSource.
+type azureTokenSource struct {
+	credential azcore.TokenCredential
+}
+
+func (s azureTokenSource) Token() (*oauth2.Token, error) {
+	token, err := s.credential.GetToken(context.Background(), policy.TokenRequestOptions{Scopes: []string{AzureScope}})
+	if err != nil {
+		return nil, err
+	}
+	return &oauth2.Token{AccessToken: token.Token, TokenType: "Bearer", Expiry: token.ExpiresOn}, nil
+}
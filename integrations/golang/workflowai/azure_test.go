@@ -0,0 +1,78 @@
+package workflowai
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/openai/openai-go"
+)
+
+type fakeCredential struct {
+	scopes []string
+}
+
+func (c *fakeCredential) GetToken(_ context.Context, options policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	c.scopes = options.Scopes
+	return azcore.AccessToken{Token: "aad-token", ExpiresOn: time.Now().Add(time.Hour)}, nil
+}
+
+func TestAzureClientOptions(t *testing.T) {
+	type request struct{ path, version, apiKey, auth string }
+	var requests []request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, request{r.URL.EscapedPath(), r.URL.Query().Get("api-version"), r.Header.Get("Api-Key"), r.Header.Get("Authorization")})
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, testCompletion)
+	}))
+	defer server.Close()
+	params := openai.ChatCompletionNewParams{
+		Model:    "my-agent/gpt-4o",
+		Messages: []openai.ChatCompletionMessageParamUnion{openai.UserMessage("Hi")},
+	}
+
+	opts, err := AzureClientOptions(AzureConfig{Endpoint: server.URL + "/gateway/", APIVersion: "2024-06-01", APIKey: "azure-key"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := openai.NewClient(opts...)
+	if _, err := client.Chat.Completions.New(context.Background(), params); err != nil {
+		t.Fatal(err)
+	}
+	expected := request{"/gateway/openai/deployments/my-agent%2Fgpt-4o/chat/completions", "2024-06-01", "azure-key", ""}
+	if requests[0] != expected {
+		t.Errorf("expected %+v, got %+v", expected, requests[0])
+	}
+
+	credential := &fakeCredential{}
+	t.Setenv("AZURE_OPENAI_ENDPOINT", server.URL)
+	t.Setenv("OPENAI_API_VERSION", "2024-10-21")
+	opts, err = AzureClientOptions(AzureConfig{
+		TokenCredential: credential,
+		Deployments:     map[string]string{"my-agent/gpt-4o": "my-agent"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client = openai.NewClient(opts...)
+	if _, err := client.Chat.Completions.New(context.Background(), params); err != nil {
+		t.Fatal(err)
+	}
+	expected = request{"/openai/deployments/my-agent/chat/completions", "2024-10-21", "", "Bearer aad-token"}
+	if requests[1] != expected {
+		t.Errorf("expected %+v, got %+v", expected, requests[1])
+	}
+	if len(credential.scopes) != 1 || credential.scopes[0] != AzureScope {
+		t.Errorf("unexpected scopes %v", credential.scopes)
+	}
+
+	t.Setenv("AZURE_OPENAI_API_KEY", "")
+	if _, err := AzureClientOptions(AzureConfig{}); err == nil {
+		t.Error("expected an error without credentials")
+	}
+}
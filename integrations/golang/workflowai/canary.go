@@ -0,0 +1,203 @@
+package workflowai
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// CanaryPolicy controls how a [CanaryController] shifts traffic to the new
+// deployment, which is arm B of the router.
+type CanaryPolicy struct {
+	// Steps are the successive percentages of traffic routed to the new
+	// deployment. Defaults to 1, 5, 25, 50 and 100.
+	Steps []float64
+	// StepDuration is the minimum time spent at each step. Defaults to 10 minutes.
+	StepDuration time.Duration
+	// MinRequests is the minimum number of requests the new deployment must
+	// serve at a step before moving to the next one. Defaults to 20.
+	MinRequests int64
+
+	// Thresholds evaluated on the requests of the new deployment during the
+	// current step. Exceeding any of them rolls back all the traffic to the
+	// current deployment. Zero values disable a threshold.
+	MaxErrorRate         float64
+	MaxSchemaFailureRate float64
+	MaxAverageLatency    time.Duration
+	// MaxLatencyRatio is the maximum ratio between the average latency of the
+	// new deployment and the current one, e.g. 1.2 for 20% slower
+	MaxLatencyRatio float64
+}
+
+// CanaryStatus is the status of a [CanaryController] rollout.
+type CanaryStatus string
+
+const (
+	CanaryRunning    CanaryStatus = "running"
+	CanaryPromoted   CanaryStatus = "promoted"
+	CanaryRolledBack CanaryStatus = "rolled_back"
+)
+
+// CanaryState is the state of a rollout.
+type CanaryState struct {
+	Status CanaryStatus
+	// Step is the index of the current step in the policy
+	Step    int
+	Percent float64
+	// Reason explains the last transition, e.g. the threshold that triggered
+	// a rollback
+	Reason string
+	// Current and Candidate are the counters of the current step
+	Current   ArmStats
+	Candidate ArmStats
+}
+
+// CanaryController gradually shifts the traffic of an [ABRouter] from arm A,
+// the current deployment, to arm B, the new deployment, and rolls back when
+// the new deployment exceeds the thresholds of the policy.
+//
+// The controller only changes the split of the router: requests keep going
+// through the router, and the decisions are taken from its counters.
+type CanaryController struct {
+	router   *ABRouter
+	policy   CanaryPolicy
+	onChange func(CanaryState)
+
+	mu        sync.Mutex
+	state     CanaryState
+	stepStart time.Time
+	baseline  map[string]ArmStats
+}
+
+type CanaryOption func(*CanaryController)
+
+// WithCanaryObserver is called after every change of step or status.
+func WithCanaryObserver(fn func(CanaryState)) CanaryOption {
+	return func(c *CanaryController) {
+		c.onChange = fn
+	}
+}
+
+// NewCanaryController creates a controller and routes the traffic of the
+// first step to the new deployment.
+func NewCanaryController(router *ABRouter, policy CanaryPolicy, opts ...CanaryOption) *CanaryController {
+	if len(policy.Steps) == 0 {
+		policy.Steps = []float64{1, 5, 25, 50, 100}
+	}
+	if policy.StepDuration <= 0 {
+		policy.StepDuration = 10 * time.Minute
+	}
+	if policy.MinRequests <= 0 {
+		policy.MinRequests = 20
+	}
+	c := &CanaryController{router: router, policy: policy}
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.state = CanaryState{Status: CanaryRunning}
+	c.startStep(0, "started")
+	return c
+}
+
+// State returns the current state of the rollout.
+func (c *CanaryController) State() CanaryState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.state
+}
+
+func (c *CanaryController) startStep(step int, reason string) {
+	c.state.Step = step
+	c.state.Percent = c.policy.Steps[step]
+	c.state.Reason = reason
+	c.stepStart = time.Now()
+	c.baseline = c.router.Stats()
+	c.router.SetPercentB(c.state.Percent)
+}
+
+func (c *CanaryController) finish(status CanaryStatus, percent float64, reason string) {
+	c.state.Status = status
+	c.state.Percent = percent
+	c.state.Reason = reason
+	c.router.SetPercentB(percent)
+}
+
+// Check evaluates the counters of the current step, then rolls back, moves to
+// the next step or promotes the new deployment. It returns the new state.
+func (c *CanaryController) Check() CanaryState {
+	c.mu.Lock()
+	state, changed := c.check()
+	c.mu.Unlock()
+	if changed && c.onChange != nil {
+		c.onChange(state)
+	}
+	return state
+}
+
+func (c *CanaryController) check() (CanaryState, bool) {
+	if c.state.Status != CanaryRunning {
+		return c.state, false
+	}
+	a, b := c.router.Arms()
+	stats := c.router.Stats()
+	c.state.Current = stats[a.Name].sub(c.baseline[a.Name])
+	c.state.Candidate = stats[b.Name].sub(c.baseline[b.Name])
+
+	if reason := c.violation(); reason != "" {
+		c.finish(CanaryRolledBack, 0, reason)
+		return c.state, true
+	}
+	if c.state.Candidate.Requests < c.policy.MinRequests || time.Now().Sub(c.stepStart) < c.policy.StepDuration {
+		return c.state, false
+	}
+	if c.state.Step == len(c.policy.Steps)-1 {
+		c.finish(CanaryPromoted, 100, "all steps passed")
+		return c.state, true
+	}
+	c.startStep(c.state.Step+1, fmt.Sprintf("step at %v%% passed", c.state.Percent))
+	return c.state, true
+}
+
+// violation returns the threshold exceeded by the new deployment, if any.
+// Thresholds are only evaluated once the new deployment served enough
+// requests to be significant.
+func (c *CanaryController) violation() string {
+	p, candidate := c.policy, c.state.Candidate
+	if candidate.Requests < p.MinRequests {
+		return ""
+	}
+	if p.MaxErrorRate > 0 && candidate.ErrorRate() > p.MaxErrorRate {
+		return fmt.Sprintf("error rate %.3f exceeds %.3f", candidate.ErrorRate(), p.MaxErrorRate)
+	}
+	if p.MaxSchemaFailureRate > 0 && candidate.SchemaFailureRate() > p.MaxSchemaFailureRate {
+		return fmt.Sprintf("schema failure rate %.3f exceeds %.3f", candidate.SchemaFailureRate(), p.MaxSchemaFailureRate)
+	}
+	latency := candidate.AverageLatency()
+	if p.MaxAverageLatency > 0 && latency > p.MaxAverageLatency {
+		return fmt.Sprintf("average latency %s exceeds %s", latency, p.MaxAverageLatency)
+	}
+	if current := c.state.Current.AverageLatency(); p.MaxLatencyRatio > 0 && current > 0 {
+		if ratio := float64(latency) / float64(current); ratio > p.MaxLatencyRatio {
+			return fmt.Sprintf("latency ratio %.2f exceeds %.2f", ratio, p.MaxLatencyRatio)
+		}
+	}
+	return ""
+}
+
+// Run checks the rollout every interval until the new deployment is promoted,
+// rolled back, or ctx is done.
+func (c *CanaryController) Run(ctx context.Context, interval time.Duration) CanaryState {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return c.State()
+		case <-ticker.C:
+			if state := c.Check(); state.Status != CanaryRunning {
+				return state
+			}
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package workflowai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+// newCanaryClient returns a client whose completions for the "#2" deployment
+// are produced by candidate.
+func newCanaryClient(t *testing.T, candidate func(w http.ResponseWriter)) openai.Client {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Model string `json:"model"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if strings.Contains(body.Model, "#2") {
+			candidate(w)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, strings.Replace(testCompletion, "Hello world", `{\"answer\": 42}`, 1))
+	}))
+	t.Cleanup(server.Close)
+	return openai.NewClient(option.WithBaseURL(server.URL+"/v1/"), option.WithAPIKey("key"), option.WithMaxRetries(0))
+}
+
+func runCanary(t *testing.T, router *ABRouter, controller *CanaryController) CanaryState {
+	t.Helper()
+	params := openai.ChatCompletionNewParams{
+		Model:    "my-agent/gpt-4o",
+		Messages: []openai.ChatCompletionMessageParamUnion{openai.UserMessage("Hello")},
+	}
+	for range 1000 {
+		_, _ = router.New(context.Background(), params)
+		if state := controller.Check(); state.Status != CanaryRunning {
+			return state
+		}
+	}
+	t.Fatal("the rollout did not finish")
+	return CanaryState{}
+}
+
+func testRouter(client openai.Client, opts ...ABRouterOption) *ABRouter {
+	return NewABRouter(&client.Chat.Completions, "canary",
+		Arm{Name: "current", Model: "my-agent/#1/production"},
+		Arm{Name: "new", Model: "my-agent/#2/production"},
+		opts...,
+	)
+}
+
+func TestCanaryController_Promotes(t *testing.T) {
+	client := newCanaryClient(t, func(w http.ResponseWriter) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, testCompletion)
+	})
+	router := testRouter(client)
+	var steps []float64
+	controller := NewCanaryController(router, CanaryPolicy{
+		Steps:        []float64{10, 50, 100},
+		StepDuration: time.Nanosecond,
+		MinRequests:  3,
+		MaxErrorRate: 0.1,
+	}, WithCanaryObserver(func(s CanaryState) { steps = append(steps, s.Percent) }))
+
+	state := runCanary(t, router, controller)
+	if state.Status != CanaryPromoted || router.PercentB() != 100 {
+		t.Fatalf("expected the new deployment to be promoted, got %+v", state)
+	}
+	if fmt.Sprint(steps) != "[50 100 100]" {
+		t.Errorf("unexpected steps %v", steps)
+	}
+}
+
+func TestCanaryController_RollsBackOnErrors(t *testing.T) {
+	client := newCanaryClient(t, func(w http.ResponseWriter) {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"error": map[string]any{"message": "boom"}})
+	})
+	router := testRouter(client)
+	controller := NewCanaryController(router, CanaryPolicy{
+		Steps:        []float64{50, 100},
+		StepDuration: time.Nanosecond,
+		MinRequests:  3,
+		MaxErrorRate: 0.1,
+	})
+
+	state := runCanary(t, router, controller)
+	if state.Status != CanaryRolledBack || router.PercentB() != 0 {
+		t.Fatalf("expected a rollback, got %+v", state)
+	}
+	if !strings.HasPrefix(state.Reason, "error rate") {
+		t.Errorf("unexpected reason %q", state.Reason)
+	}
+}
+
+func TestCanaryController_RollsBackOnSchemaFailures(t *testing.T) {
+	client := newCanaryClient(t, func(w http.ResponseWriter) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, testCompletion)
+	})
+	router := testRouter(client, WithResponseSchema(json.RawMessage(`{"type": "object", "required": ["answer"]}`)))
+	controller := NewCanaryController(router, CanaryPolicy{
+		Steps:                []float64{50, 100},
+		StepDuration:         time.Hour,
+		MinRequests:          3,
+		MaxSchemaFailureRate: 0.1,
+	})
+
+	state := runCanary(t, router, controller)
+	if state.Status != CanaryRolledBack {
+		t.Fatalf("expected a rollback, got %+v", state)
+	}
+	if state.Current.SchemaFailures != 0 || state.Candidate.SchemaFailures == 0 {
+		t.Errorf("unexpected schema failures %+v %+v", state.Current, state.Candidate)
+	}
+}
@@ -0,0 +1,257 @@
+package workflowai
+
+import (
+	"cmp"
+	"encoding/json"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/openai/openai-go"
+)
+
+// Citation types
+const (
+	CitationURL  = "url_citation"
+	CitationFile = "file_citation"
+	// CitationMarker is a "[1]" marker of the content referencing a source
+	// of the citations list of the completion, as returned by some search
+	// models
+	CitationMarker = "citation"
+)
+
+// Citation is a source of a span of the content of a completion, e.g. a web
+// page found by a search-enabled model or a file of a retrieval-backed model.
+type Citation struct {
+	Type  string
+	URL   string
+	Title string
+	// FileID is the ID of the cited file of file citations
+	FileID string
+	// Start and End are the offsets of the cited span of the content, in
+	// characters, End excluded
+	Start int
+	End   int
+	// Text is the cited span of the content
+	Text string
+}
+
+// rawAnnotation is an annotation of a message, with its citation nested by
+// type, e.g. {"type": "url_citation", "url_citation": {...}}, or flat.
+type rawAnnotation struct {
+	Type string `json:"type"`
+	rawCitation
+	URLCitation  *rawCitation `json:"url_citation"`
+	FileCitation *rawCitation `json:"file_citation"`
+}
+
+type rawCitation struct {
+	URL        string `json:"url"`
+	Title      string `json:"title"`
+	FileID     string `json:"file_id"`
+	Filename   string `json:"filename"`
+	StartIndex int    `json:"start_index"`
+	EndIndex   int    `json:"end_index"`
+}
+
+func (a rawAnnotation) citation() (Citation, bool) {
+	raw := a.rawCitation
+	switch {
+	case a.URLCitation != nil:
+		raw = *a.URLCitation
+	case a.FileCitation != nil:
+		nested := *a.FileCitation
+		// The offsets of file citations are on the annotation
+		nested.StartIndex, nested.EndIndex = raw.StartIndex, raw.EndIndex
+		raw = nested
+	}
+	if raw.URL == "" && raw.FileID == "" {
+		return Citation{}, false
+	}
+	c := Citation{Type: a.Type, URL: raw.URL, Title: raw.Title, FileID: raw.FileID, Start: raw.StartIndex, End: raw.EndIndex}
+	if c.Title == "" {
+		c.Title = raw.Filename
+	}
+	return c, true
+}
+
+// parseAnnotations returns the citations of a JSON array of annotations,
+// skipping the annotations that are not citations.
+func parseAnnotations(data string) []Citation {
+	var annotations []rawAnnotation
+	if data == "" || json.Unmarshal([]byte(data), &annotations) != nil {
+		return nil
+	}
+	var citations []Citation
+	for _, a := range annotations {
+		if c, ok := a.citation(); ok {
+			citations = append(citations, c)
+		}
+	}
+	return citations
+}
+
+var citationMarker = regexp.MustCompile(`\[(\d+)\]`)
+
+// markerCitations returns the citations of the "[1]" markers of content,
+// referencing the sources of a JSON array of URLs.
+func markerCitations(content, sources string) []Citation {
+	var urls []string
+	if sources == "" || json.Unmarshal([]byte(sources), &urls) != nil {
+		return nil
+	}
+	var citations []Citation
+	for _, m := range citationMarker.FindAllStringSubmatchIndex(content, -1) {
+		n, err := strconv.Atoi(content[m[2]:m[3]])
+		if err != nil || n < 1 || n > len(urls) {
+			continue
+		}
+		start := len([]rune(content[:m[0]]))
+		citations = append(citations, Citation{
+			Type:  CitationMarker,
+			URL:   urls[n-1],
+			Start: start,
+			End:   start + m[1] - m[0],
+		})
+	}
+	return citations
+}
+
+// fillCitationText sets the text of the citations from content.
+func fillCitationText(citations []Citation, content string) {
+	runes := []rune(content)
+	for i := range citations {
+		c := &citations[i]
+		if c.Start >= 0 && c.Start <= c.End && c.End <= len(runes) {
+			c.Text = string(runes[c.Start:c.End])
+		}
+	}
+}
+
+// CompletionCitations returns the citations of the first choice of a
+// completion: its annotations, e.g. the URL citations of search-enabled
+// models, and the "[1]" markers of its content when the completion lists
+// its sources, in the order of the content.
+//
+// The models served by WorkflowAI return neither annotations nor a list of
+// citations, and WorkflowAI rejects web_search_options, so the completions
+// of WorkflowAI have no citations: the citations are those of the
+// completions of other OpenAI-compatible endpoints.
+func CompletionCitations(completion *openai.ChatCompletion) []Citation {
+	if len(completion.Choices) == 0 {
+		return nil
+	}
+	message := completion.Choices[0].Message
+	var raw struct {
+		Annotations json.RawMessage `json:"annotations"`
+	}
+	_ = json.Unmarshal([]byte(message.RawJSON()), &raw)
+	citations := parseAnnotations(string(raw.Annotations))
+	if f, ok := completion.JSON.ExtraFields["citations"]; ok {
+		citations = append(citations, markerCitations(message.Content, f.Raw())...)
+	}
+	fillCitationText(citations, message.Content)
+	sortCitations(citations)
+	return citations
+}
+
+// ChunkCitations returns the citations annotated on the delta of the first
+// choice of a chunk. Their text is not known until the content is received,
+// see [CitationAccumulator].
+func ChunkCitations(chunk openai.ChatCompletionChunk) []Citation {
+	if len(chunk.Choices) == 0 {
+		return nil
+	}
+	f, ok := chunk.Choices[0].Delta.JSON.ExtraFields["annotations"]
+	if !ok {
+		return nil
+	}
+	return parseAnnotations(f.Raw())
+}
+
+// CitationAccumulator collects the citations of the chunks of a stream.
+//
+//	var citations workflowai.CitationAccumulator
+//	for stream.Next() {
+//		citations.AddChunk(stream.Current())
+//	}
+//	for _, c := range citations.Citations() {
+//		fmt.Printf("%q: %s\n", c.Text, c.URL)
+//	}
+type CitationAccumulator struct {
+	content   strings.Builder
+	citations []Citation
+	// sources is the last list of sources of the chunks
+	sources string
+}
+
+// AddChunk adds a chunk to the accumulator and returns its citations.
+func (a *CitationAccumulator) AddChunk(chunk openai.ChatCompletionChunk) []Citation {
+	if f, ok := chunk.JSON.ExtraFields["citations"]; ok {
+		a.sources = f.Raw()
+	}
+	if len(chunk.Choices) == 0 {
+		return nil
+	}
+	a.content.WriteString(chunk.Choices[0].Delta.Content)
+	citations := ChunkCitations(chunk)
+	a.citations = append(a.citations, citations...)
+	return citations
+}
+
+// Citations returns the citations of the chunks added so far, with the text
+// of the content received so far, in the order of the content.
+func (a *CitationAccumulator) Citations() []Citation {
+	content := a.content.String()
+	citations := append(append([]Citation(nil), a.citations...), markerCitations(content, a.sources)...)
+	fillCitationText(citations, content)
+	sortCitations(citations)
+	return citations
+}
+
+func sortCitations(citations []Citation) {
+	slices.SortStableFunc(citations, func(a, b Citation) int {
+		return cmp.Compare(a.Start, b.Start)
+	})
+}
+
+// AnnotateCitations inserts a marker after each cited span of content, e.g.
+// a markdown link, to render sourced answers. The markers of the sources are
+// numbered from 1 in the order of their first citation, a source being a URL
+// or a file. Markers already in the content, [CitationMarker] citations, are
+// kept as they are.
+//
+//	text := workflowai.AnnotateCitations(content, citations, func(n int, c workflowai.Citation) string {
+//		return fmt.Sprintf(" [[%d]](%s)", n, c.URL)
+//	})
+func AnnotateCitations(content string, citations []Citation, marker func(n int, c Citation) string) string {
+	runes := []rune(content)
+	numbers := map[string]int{}
+	inserts := map[int][]string{}
+	for _, c := range citations {
+		if c.Type == CitationMarker || c.End < 0 || c.End > len(runes) {
+			continue
+		}
+		source := c.URL
+		if source == "" {
+			source = "file:" + c.FileID
+		}
+		n, ok := numbers[source]
+		if !ok {
+			n = len(numbers) + 1
+			numbers[source] = n
+		}
+		inserts[c.End] = append(inserts[c.End], marker(n, c))
+	}
+	var b strings.Builder
+	for i := 0; i <= len(runes); i++ {
+		for _, m := range inserts[i] {
+			b.WriteString(m)
+		}
+		if i < len(runes) {
+			b.WriteRune(runes[i])
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,79 @@
+package workflowai
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/openai/openai-go"
+)
+
+func TestCompletionCitations(t *testing.T) {
+	var completion openai.ChatCompletion
+	err := json.Unmarshal([]byte(`{"id": "search/run-1", "choices": [{"index": 0, "message": {
+		"role": "assistant",
+		"content": "Café prices rose in 2024. Tea is cheaper.",
+		"annotations": [
+			{"type": "url_citation", "url_citation": {"start_index": 26, "end_index": 41, "url": "https://b.example", "title": "Tea"}},
+			{"type": "url_citation", "url_citation": {"start_index": 0, "end_index": 25, "url": "https://a.example", "title": "Coffee"}},
+			{"type": "file_citation", "start_index": 0, "end_index": 4, "file_citation": {"file_id": "file-1", "filename": "menu.pdf"}},
+			{"type": "unknown"}
+		]
+	}}]}`), &completion)
+	if err != nil {
+		t.Fatal(err)
+	}
+	citations := CompletionCitations(&completion)
+	if len(citations) != 3 {
+		t.Fatalf("unexpected citations %+v", citations)
+	}
+	if c := citations[0]; c.Text != "Café prices rose in 2024." || c.URL != "https://a.example" || c.Title != "Coffee" {
+		t.Errorf("unexpected citation %+v", c)
+	}
+	if c := citations[1]; c.Type != CitationFile || c.FileID != "file-1" || c.Title != "menu.pdf" || c.Text != "Café" {
+		t.Errorf("unexpected citation %+v", c)
+	}
+	if c := citations[2]; c.Text != "Tea is cheaper." {
+		t.Errorf("unexpected citation %+v", c)
+	}
+
+	annotated := AnnotateCitations(completion.Choices[0].Message.Content, citations, func(n int, c Citation) string {
+		return fmt.Sprintf("[%d]", n)
+	})
+	if annotated != "Café[2] prices rose in 2024.[1] Tea is cheaper.[3]" {
+		t.Errorf("unexpected annotated content %q", annotated)
+	}
+
+	// Sources listed on the completion and referenced by markers
+	err = json.Unmarshal([]byte(`{"id": "search/run-2", "citations": ["https://a.example", "https://b.example"], "choices": [{"index": 0, "message": {
+		"role": "assistant", "content": "Prices rose [2][1]. See [3]."
+	}}]}`), &completion)
+	if err != nil {
+		t.Fatal(err)
+	}
+	citations = CompletionCitations(&completion)
+	if len(citations) != 2 || citations[0].URL != "https://b.example" || citations[0].Text != "[2]" || citations[1].Start != 15 {
+		t.Errorf("unexpected citations %+v", citations)
+	}
+}
+
+func TestCitationAccumulator(t *testing.T) {
+	var citations CitationAccumulator
+	for _, data := range []string{
+		`{"choices": [{"index": 0, "delta": {"role": "assistant", "content": "It is sunny"}}]}`,
+		`{"choices": [{"index": 0, "delta": {"content": " today.", "annotations": [{"type": "url_citation", "url_citation": {"start_index": 0, "end_index": 18, "url": "https://weather.example", "title": "Weather"}}]}}]}`,
+		`{"choices": []}`,
+	} {
+		var chunk openai.ChatCompletionChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			t.Fatal(err)
+		}
+		if added := citations.AddChunk(chunk); len(added) == 1 && added[0].Text != "" {
+			t.Error("the text of the chunk citations is not known yet")
+		}
+	}
+	all := citations.Citations()
+	if len(all) != 1 || all[0].Text != "It is sunny today." || all[0].Title != "Weather" {
+		t.Errorf("unexpected citations %+v", all)
+	}
+}
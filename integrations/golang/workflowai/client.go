@@ -0,0 +1,100 @@
+package workflowai
+
+import (
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+// DefaultBaseURL is the base URL of the WorkflowAI OpenAI compatible API.
+const DefaultBaseURL = "https://run.workflowai.com/v1"
+
+// Client is an [openai.Client] configured for WorkflowAI, with additional
+// services for the WorkflowAI specific endpoints. The OpenAI services
+// (Chat, Models, ...) are accessible through the embedded client.
+type Client struct {
+	openai.Client
+
+	Agents   AgentService
+	Runs     RunService
+	Versions VersionService
+	Feedback FeedbackService
+
+	// RateLimits tracks the rate limits reported by the responses, and
+	// throttles the requests when enabled
+	RateLimits *RateLimitTracker
+}
+
+// DefaultClientOptions returns the options read from the environment.
+//
+// The base URL is read from WORKFLOWAI_API_URL (the "/v1" suffix is added),
+// falling back to OPENAI_BASE_URL and then to [DefaultBaseURL]. The API key is
+// read from WORKFLOWAI_API_KEY, falling back to OPENAI_API_KEY.
+func DefaultClientOptions() []option.RequestOption {
+	opts := []option.RequestOption{withBaseURL(DefaultBaseURL)}
+	if v, ok := os.LookupEnv("OPENAI_BASE_URL"); ok {
+		opts = append(opts, withBaseURL(v))
+	}
+	if v, ok := os.LookupEnv("WORKFLOWAI_API_URL"); ok {
+		opts = append(opts, withBaseURL(strings.TrimRight(v, "/")+"/v1"))
+	}
+	if v, ok := os.LookupEnv("WORKFLOWAI_API_KEY"); ok {
+		opts = append(opts, option.WithAPIKey(v))
+	}
+	return opts
+}
+
+// OpenAIBaseURL is the base URL of the OpenAI API.
+const OpenAIBaseURL = "https://api.openai.com/v1"
+
+// OpenAIClientOptions returns the options of a client of the OpenAI API
+// itself, for the endpoints that WorkflowAI does not serve, e.g. the image
+// generations and the embeddings:
+//
+//	openaiClient := openai.NewClient(workflowai.OpenAIClientOptions()...)
+//
+// The API key is read from OPENAI_API_KEY. OPENAI_BASE_URL is ignored, as
+// [DefaultClientOptions] reads it as a WorkflowAI base URL.
+func OpenAIClientOptions() []option.RequestOption {
+	return []option.RequestOption{withBaseURL(OpenAIBaseURL), option.WithAPIKey(os.Getenv("OPENAI_API_KEY"))}
+}
+
+// withBaseURL is option.WithBaseURL with a trailing slash: the option adds
+// it to the parsed URL it shares between requests otherwise, which races
+// when the first requests of a client are concurrent.
+func withBaseURL(base string) option.RequestOption {
+	return option.WithBaseURL(strings.TrimRight(base, "/") + "/")
+}
+
+// NewClient creates a client with the options read from the environment
+// (see [DefaultClientOptions]). Options passed as arguments are applied after
+// the defaults.
+func NewClient(opts ...option.RequestOption) Client {
+	rateLimits := NewRateLimitTracker()
+	opts = append(DefaultClientOptions(), opts...)
+	// After the other middlewares, so that they also see the scrubbed errors
+	opts = append(opts, option.WithMiddleware(scrubResponses))
+	// Last, so that it observes each attempt after the other middlewares
+	opts = append(opts, option.WithMiddleware(rateLimits.middleware))
+
+	c := Client{Client: openai.NewClient(opts...), RateLimits: rateLimits}
+	c.Agents = AgentService{client: c.Client}
+	c.Runs = RunService{client: c.Client}
+	c.Versions = VersionService{client: c.Client}
+	c.Feedback = FeedbackService{client: c.Client}
+	return c
+}
+
+// agentPath returns the path of an agent scoped endpoint, relative to the
+// base URL. The "_" tenant resolves to the organization of the API key.
+func agentPath(agentID string, parts ...string) string {
+	escaped := make([]string, 0, len(parts)+3)
+	escaped = append(escaped, "_", "agents", url.PathEscape(agentID))
+	for _, p := range parts {
+		escaped = append(escaped, url.PathEscape(p))
+	}
+	return strings.Join(escaped, "/")
+}
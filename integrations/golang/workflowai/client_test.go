@@ -0,0 +1,66 @@
+package workflowai
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+// Run with -race: the first requests of a client share the parsed base URL
+func TestWithBaseURL_ConcurrentRequests(t *testing.T) {
+	var mu sync.Mutex
+	paths := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths[r.URL.Path]++
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(testCompletion))
+	}))
+	defer server.Close()
+
+	client := NewClient(withBaseURL(server.URL+"/v1"), option.WithAPIKey("test"), option.WithMaxRetries(0))
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := client.Chat.Completions.New(context.Background(), openai.ChatCompletionNewParams{
+				Model:    "my-agent/gpt-4o-mini-latest",
+				Messages: []openai.ChatCompletionMessageParamUnion{openai.UserMessage("Hello")},
+			})
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if paths["/v1/chat/completions"] != 8 {
+		t.Errorf("unexpected paths %v", paths)
+	}
+}
+
+func TestOpenAIClientOptions(t *testing.T) {
+	t.Setenv("OPENAI_BASE_URL", "https://workflowai.example.com/v1")
+	t.Setenv("OPENAI_API_KEY", "sk-test")
+	var sent *http.Request
+	client := openai.NewClient(append(OpenAIClientOptions(),
+		option.WithMaxRetries(0),
+		option.WithMiddleware(func(req *http.Request, _ option.MiddlewareNext) (*http.Response, error) {
+			sent = req
+			return nil, errors.New("not sent")
+		}))...)
+	client.Embeddings.New(context.Background(), openai.EmbeddingNewParams{
+		Model: openai.EmbeddingModelTextEmbedding3Small,
+		Input: openai.EmbeddingNewParamsInputUnion{OfString: openai.String("hello")},
+	})
+	if sent == nil || sent.URL.String() != "https://api.openai.com/v1/embeddings" || sent.Header.Get("Authorization") != "Bearer sk-test" {
+		t.Fatalf("expected the request to be sent to OpenAI, got %v", sent)
+	}
+}
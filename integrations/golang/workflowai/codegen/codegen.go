@@ -0,0 +1,422 @@
+// Package codegen generates Go types and a typed client from the input and
+// output schemas of an agent, as run by "workflowai gen":
+//
+//	//go:generate workflowai gen -agent my-agent -schema 3 -out types_gen.go
+//
+// Each generated struct has a Validate method checking the constraints of its
+// schema (required references, enums, patterns, lengths and ranges) in plain
+// Go, and the client validates the input before running the agent.
+//
+// The output only depends on the schemas: fields are sorted by name and the
+// nested types are emitted in a fixed order, so regenerating an unchanged
+// schema yields the same file and schema changes yield small diffs.
+package codegen
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/format"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Config describes the code to generate.
+type Config struct {
+	// Package is the name of the package of the generated file
+	Package  string
+	AgentID  string
+	SchemaID int
+	// Name prefixes the generated identifiers, derived from the agent ID
+	// when empty, e.g. "MyAgent" for "my-agent"
+	Name         string
+	InputSchema  json.RawMessage
+	OutputSchema json.RawMessage
+}
+
+// schema holds the parts of a JSON schema used by the generator.
+type schema struct {
+	Type                 any                `json:"type"`
+	Description          string             `json:"description"`
+	Properties           map[string]*schema `json:"properties"`
+	Required             []string           `json:"required"`
+	Items                *schema            `json:"items"`
+	AdditionalProperties json.RawMessage    `json:"additionalProperties"`
+	Ref                  string             `json:"$ref"`
+	Defs                 map[string]*schema `json:"$defs"`
+	Definitions          map[string]*schema `json:"definitions"`
+	AnyOf                []*schema          `json:"anyOf"`
+	OneOf                []*schema          `json:"oneOf"`
+	Enum                 []any              `json:"enum"`
+	Pattern              string             `json:"pattern"`
+	MinLength            *int               `json:"minLength"`
+	MaxLength            *int               `json:"maxLength"`
+	MinItems             *int               `json:"minItems"`
+	MaxItems             *int               `json:"maxItems"`
+	Minimum              *float64           `json:"minimum"`
+	Maximum              *float64           `json:"maximum"`
+	// ExclusiveMinimum and ExclusiveMaximum are numbers since draft 6, the
+	// booleans of draft 4 are ignored
+	ExclusiveMinimum json.RawMessage `json:"exclusiveMinimum"`
+	ExclusiveMaximum json.RawMessage `json:"exclusiveMaximum"`
+}
+
+// types returns the types of the schema, without "null".
+func (s *schema) types() (types []string, nullable bool) {
+	switch t := s.Type.(type) {
+	case string:
+		types = []string{t}
+	case []any:
+		for _, v := range t {
+			if name, ok := v.(string); ok {
+				types = append(types, name)
+			}
+		}
+	}
+	filtered := types[:0]
+	for _, t := range types {
+		if t == "null" {
+			nullable = true
+		} else {
+			filtered = append(filtered, t)
+		}
+	}
+	return filtered, nullable
+}
+
+type generator struct {
+	cfg Config
+	// decls are the declarations of the named types, by name
+	decls map[string]string
+	// defs are the definitions of the schema being generated
+	defs map[string]*schema
+	// generating holds the names of the definitions being generated, to
+	// detect recursive references
+	generating map[string]bool
+	// imports are the standard packages used by the validations
+	imports map[string]bool
+	// patterns are the regular expressions of the validations, in order of
+	// use
+	patterns []string
+}
+
+// Generate returns the formatted source of the types and client of the agent.
+func Generate(cfg Config) ([]byte, error) {
+	if cfg.Package == "" || cfg.AgentID == "" {
+		return nil, errors.New("codegen: the package and the agent ID are required")
+	}
+	if cfg.Name == "" {
+		cfg.Name = GoName(cfg.AgentID)
+	}
+	g := &generator{cfg: cfg, decls: map[string]string{}, generating: map[string]bool{}, imports: map[string]bool{}}
+
+	for _, io := range []struct {
+		name string
+		doc  string
+		raw  json.RawMessage
+	}{
+		{cfg.Name + "Input", "input", cfg.InputSchema},
+		{cfg.Name + "Output", "output", cfg.OutputSchema},
+	} {
+		var s schema
+		if len(io.raw) > 0 {
+			if err := json.Unmarshal(io.raw, &s); err != nil {
+				return nil, fmt.Errorf("codegen: invalid schema of %s: %w", io.name, err)
+			}
+		}
+		g.defs = s.Defs
+		if g.defs == nil {
+			g.defs = s.Definitions
+		}
+		if s.Properties == nil {
+			s.Properties = map[string]*schema{}
+		}
+		s.Type = "object"
+		if s.Description == "" {
+			s.Description = fmt.Sprintf("%s is the %s of the %s agent.", io.name, io.doc, cfg.AgentID)
+		}
+		g.goType(&s, io.name)
+	}
+	// The generated code compiles the patterns when its package is
+	// initialized, where an invalid pattern would panic. JSON schema patterns
+	// are ECMAScript regular expressions, whose lookarounds and backreferences
+	// Go does not support
+	for _, pattern := range g.patterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return nil, fmt.Errorf("codegen: unsupported pattern %q: %w", pattern, err)
+		}
+	}
+
+	var b bytes.Buffer
+	b.WriteString("// Code generated by workflowai gen. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&b, "package %s\n\n", cfg.Package)
+	imports := []string{"context", "encoding/json", "errors", "fmt"}
+	for pkg := range g.imports {
+		imports = append(imports, pkg)
+	}
+	sort.Strings(imports)
+	b.WriteString("import (\n")
+	for _, pkg := range imports {
+		fmt.Fprintf(&b, "%q\n", pkg)
+	}
+	b.WriteString("\n\"github.com/openai/openai-go\"\n\"github.com/openai/openai-go/option\"\n\n")
+	b.WriteString("\"github.com/workflowai/workflowai/go/examples/workflowai\"\n)\n\n")
+	g.writeClient(&b)
+	if len(g.patterns) > 0 {
+		b.WriteString("var (\n")
+		for i, pattern := range g.patterns {
+			fmt.Fprintf(&b, "%s = regexp.MustCompile(%s)\n", g.patternVar(i), strconv.Quote(pattern))
+		}
+		b.WriteString(")\n\n")
+	}
+
+	// The input and output first, then the nested types by name
+	names := make([]string, 0, len(g.decls))
+	for name := range g.decls {
+		if name != cfg.Name+"Input" && name != cfg.Name+"Output" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range append([]string{cfg.Name + "Input", cfg.Name + "Output"}, names...) {
+		b.WriteString(g.decls[name])
+	}
+
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("codegen: formatting the generated code: %w", err)
+	}
+	return src, nil
+}
+
+func (g *generator) writeClient(b *bytes.Buffer) {
+	name, pkg := g.cfg.Name, g.cfg.Package
+	fmt.Fprintf(b, "const (\n%sID = %q\n%sSchemaID = %d\n)\n\n", name, g.cfg.AgentID, name, g.cfg.SchemaID)
+	fmt.Fprintf(b, "// %s runs the versions of the %s agent deployed for schema #%d.\n", name, g.cfg.AgentID, g.cfg.SchemaID)
+	fmt.Fprintf(b, "type %s struct {\ncompletions workflowai.ChatCompleter\n", name)
+	b.WriteString("// Environment is the deployment used by the runs, \"production\" by default\nEnvironment string\n}\n\n")
+	fmt.Fprintf(b, "func New%s(completions workflowai.ChatCompleter) *%s {\n", name, name)
+	fmt.Fprintf(b, "return &%s{completions: completions, Environment: workflowai.EnvironmentProduction}\n}\n\n", name)
+	fmt.Fprintf(b, "// Model returns the model of the runs, e.g. \"%s/#%d/production\".\n", g.cfg.AgentID, g.cfg.SchemaID)
+	fmt.Fprintf(b, "func (a *%s) Model() string {\n", name)
+	fmt.Fprintf(b, "return fmt.Sprintf(\"%%s/#%%d/%%s\", %sID, %sSchemaID, a.Environment)\n}\n\n", name, name)
+	b.WriteString("// Run validates input, runs the deployed version with it and decodes its\n// output.\n")
+	fmt.Fprintf(b, "func (a *%s) Run(ctx context.Context, input %sInput, opts ...option.RequestOption) (*%sOutput, error) {\n", name, name, name)
+	fmt.Fprintf(b, "if err := input.Validate(); err != nil {\nreturn nil, fmt.Errorf(\"%s: invalid input: %%w\", err)\n}\n", pkg)
+	b.WriteString("params := openai.ChatCompletionNewParams{Model: a.Model(), Messages: []openai.ChatCompletionMessageParamUnion{}}\n")
+	b.WriteString("params.SetExtraFields(map[string]any{\"input\": input})\n")
+	b.WriteString("completion, err := a.completions.New(ctx, params, opts...)\nif err != nil {\nreturn nil, err\n}\n")
+	fmt.Fprintf(b, "if len(completion.Choices) == 0 {\nreturn nil, errors.New(\"%s: the completion has no choices\")\n}\n", pkg)
+	fmt.Fprintf(b, "var output %sOutput\n", name)
+	b.WriteString("if err := json.Unmarshal([]byte(completion.Choices[0].Message.Content), &output); err != nil {\n")
+	fmt.Fprintf(b, "return nil, fmt.Errorf(\"%s: decoding the output: %%w\", err)\n}\n", pkg)
+	b.WriteString("return &output, nil\n}\n\n")
+}
+
+// goType returns the Go type of s, declaring the named types it needs. name
+// is the name of the type declared when s is an object.
+func (g *generator) goType(s *schema, name string) string {
+	if s == nil {
+		return "json.RawMessage"
+	}
+	if s.Ref != "" {
+		return g.refType(s.Ref)
+	}
+	variants := s.AnyOf
+	if len(variants) == 0 {
+		variants = s.OneOf
+	}
+	if len(variants) > 0 {
+		var nonNull []*schema
+		for _, v := range variants {
+			if types, _ := v.types(); len(types) > 0 || v.Ref != "" || len(v.AnyOf)+len(v.OneOf) > 0 {
+				nonNull = append(nonNull, v)
+			}
+		}
+		if len(nonNull) == 1 {
+			return nullable(g.goType(nonNull[0], name))
+		}
+		return "json.RawMessage"
+	}
+
+	types, isNullable := s.types()
+	if len(types) == 0 && len(s.Properties) > 0 {
+		types = []string{"object"}
+	}
+	if len(types) != 1 {
+		return "json.RawMessage"
+	}
+	var t string
+	switch types[0] {
+	case "string":
+		t = "string"
+	case "integer":
+		t = "int64"
+	case "number":
+		t = "float64"
+	case "boolean":
+		t = "bool"
+	case "array":
+		return "[]" + g.goType(s.Items, name+"Item")
+	case "object":
+		if len(s.Properties) > 0 || name == g.cfg.Name+"Input" || name == g.cfg.Name+"Output" {
+			g.declareStruct(s, name)
+			t = name
+		} else {
+			var additional *schema
+			if json.Unmarshal(s.AdditionalProperties, &additional) == nil && additional != nil {
+				return "map[string]" + g.goType(additional, name+"Value")
+			}
+			return "map[string]any"
+		}
+	default:
+		return "json.RawMessage"
+	}
+	if isNullable {
+		return nullable(t)
+	}
+	return t
+}
+
+// nullable returns the type holding either a value of t or null.
+func nullable(t string) string {
+	if strings.HasPrefix(t, "*") || strings.HasPrefix(t, "[]") || strings.HasPrefix(t, "map[") || t == "json.RawMessage" {
+		return t
+	}
+	return "*" + t
+}
+
+// refType returns the type of a local reference, "#/$defs/Name" or
+// "#/definitions/Name".
+func (g *generator) refType(ref string) string {
+	var def string
+	for _, prefix := range []string{"#/$defs/", "#/definitions/"} {
+		if strings.HasPrefix(ref, prefix) {
+			def = strings.TrimPrefix(ref, prefix)
+		}
+	}
+	s := g.defs[def]
+	if s == nil {
+		return "json.RawMessage"
+	}
+	name := g.cfg.Name + GoName(def)
+	if g.generating[name] {
+		// Recursive reference, the declaration is being generated
+		return "*" + name
+	}
+	g.generating[name] = true
+	defer delete(g.generating, name)
+	return g.goType(s, name)
+}
+
+func (g *generator) declareStruct(s *schema, name string) {
+	if _, ok := g.decls[name]; ok {
+		return
+	}
+	// Reserve the name for recursive types
+	g.decls[name] = ""
+
+	required := map[string]bool{}
+	for _, field := range s.Required {
+		required[field] = true
+	}
+	fields := make([]string, 0, len(s.Properties))
+	for field := range s.Properties {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	var b, checks strings.Builder
+	writeComment(&b, s.Description, "")
+	fmt.Fprintf(&b, "type %s struct {\n", name)
+	for _, field := range fields {
+		property := s.Properties[field]
+		fieldName := GoName(field)
+		t := g.goType(property, name+fieldName)
+		tag := field
+		if !required[field] {
+			tag += ",omitempty"
+			if _, ok := g.decls[t]; ok {
+				// Optional structs are pointers for omitempty to apply
+				t = "*" + t
+			}
+		}
+		writeComment(&b, property.Description, "\t")
+		fmt.Fprintf(&b, "\t%s %s `json:%q`\n", fieldName, t, tag)
+
+		expr := "v." + fieldName
+		if required[field] && isNilable(t) {
+			fmt.Fprintf(&checks, "if %s == nil {\n%s\n}\n", expr, failure("path", fmt.Sprintf("missing required property %q", field)))
+		}
+		c := g.checks(property, t, expr, fmt.Sprintf("path+%q", "."+field), 0)
+		if present := isPresent(t, expr); c != "" && !required[field] && present != "" {
+			// Optional zero values are omitted from the JSON
+			c = fmt.Sprintf("if %s {\n%s}\n", present, c)
+		}
+		checks.WriteString(c)
+	}
+	b.WriteString("}\n\n")
+	g.writeValidate(&b, name, checks.String())
+	g.decls[name] = b.String()
+}
+
+func writeComment(b *strings.Builder, text string, indent string) {
+	for _, line := range strings.Split(strings.TrimSpace(text), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			fmt.Fprintf(b, "%s// %s\n", indent, line)
+		}
+	}
+}
+
+// initialisms are written in upper case in Go names.
+var initialisms = map[string]bool{
+	"api": true, "csv": true, "html": true, "http": true, "id": true, "ip": true,
+	"json": true, "pdf": true, "sql": true, "ui": true, "url": true, "uuid": true,
+}
+
+// GoName returns the exported Go identifier of a JSON name, e.g. "UserID"
+// for "user_id" and "MyAgent" for "my-agent".
+func GoName(s string) string {
+	var words []string
+	var word []rune
+	flush := func() {
+		if len(word) > 0 {
+			words = append(words, string(word))
+			word = nil
+		}
+	}
+	runes := []rune(s)
+	for i, r := range runes {
+		switch {
+		case !unicode.IsLetter(r) && !unicode.IsDigit(r):
+			flush()
+		case unicode.IsUpper(r) && i > 0 && unicode.IsLower(runes[i-1]):
+			// camelCase boundary
+			flush()
+			word = append(word, r)
+		default:
+			word = append(word, r)
+		}
+	}
+	flush()
+
+	var b strings.Builder
+	for _, w := range words {
+		if initialisms[strings.ToLower(w)] {
+			b.WriteString(strings.ToUpper(w))
+			continue
+		}
+		r := []rune(w)
+		b.WriteRune(unicode.ToUpper(r[0]))
+		b.WriteString(string(r[1:]))
+	}
+	name := b.String()
+	if name == "" || unicode.IsDigit([]rune(name)[0]) {
+		name = "X" + name
+	}
+	return name
+}
@@ -0,0 +1,160 @@
+package codegen
+
+import (
+	"bytes"
+	"encoding/json"
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+)
+
+const testInputSchema = `{
+	"type": "object",
+	"properties": {
+		"text": {"type": "string", "description": "The text to summarize"},
+		"max_words": {"type": "integer"},
+		"author": {"$ref": "#/$defs/Person"},
+		"tags": {"type": "array", "items": {"type": "string"}},
+		"options": {"type": "object", "properties": {"language": {"type": ["string", "null"]}}},
+		"extra": {"type": "object", "additionalProperties": {"type": "number"}}
+	},
+	"required": ["text", "author"],
+	"$defs": {
+		"Person": {
+			"type": "object",
+			"properties": {"name": {"type": "string"}, "friend": {"$ref": "#/$defs/Person"}},
+			"required": ["name"]
+		}
+	}
+}`
+
+const testOutputSchema = `{
+	"type": "object",
+	"properties": {
+		"summary": {"type": "string"},
+		"score": {"anyOf": [{"type": "number"}, {"type": "null"}]},
+		"sections": {"type": "array", "items": {"type": "object", "properties": {"title": {"type": "string"}, "user_id": {"type": "string"}}}}
+	},
+	"required": ["summary"]
+}`
+
+func TestGenerate(t *testing.T) {
+	cfg := Config{
+		Package:      "agents",
+		AgentID:      "my-agent",
+		SchemaID:     3,
+		InputSchema:  json.RawMessage(testInputSchema),
+		OutputSchema: json.RawMessage(testOutputSchema),
+	}
+	src, err := Generate(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parser.ParseFile(token.NewFileSet(), "types_gen.go", src, 0); err != nil {
+		t.Fatalf("invalid code: %v\n%s", err, src)
+	}
+	for _, want := range []string{
+		"// Code generated by workflowai gen. DO NOT EDIT.\n\npackage agents\n",
+		"MyAgentID       = \"my-agent\"\n\tMyAgentSchemaID = 3\n",
+		"func (a *MyAgent) Run(ctx context.Context, input MyAgentInput, opts ...option.RequestOption) (*MyAgentOutput, error) {",
+		"type MyAgentInput struct {\n" +
+			"\tAuthor   MyAgentPerson        `json:\"author\"`\n" +
+			"\tExtra    map[string]float64   `json:\"extra,omitempty\"`\n" +
+			"\tMaxWords int64                `json:\"max_words,omitempty\"`\n" +
+			"\tOptions  *MyAgentInputOptions `json:\"options,omitempty\"`\n" +
+			"\tTags     []string             `json:\"tags,omitempty\"`\n" +
+			"\t// The text to summarize\n" +
+			"\tText string `json:\"text\"`\n}",
+		"\tScore    *float64                    `json:\"score,omitempty\"`\n",
+		"\tUserID string `json:\"user_id,omitempty\"`\n",
+		"\tFriend *MyAgentPerson `json:\"friend,omitempty\"`\n",
+	} {
+		if !strings.Contains(string(src), want) {
+			t.Errorf("generated code does not contain %q:\n%s", want, src)
+		}
+	}
+
+	// The output is stable, with the nested types after the input and output
+	again, _ := Generate(cfg)
+	if !bytes.Equal(src, again) {
+		t.Error("the generated code changes between runs")
+	}
+	order := []string{"type MyAgentInput ", "type MyAgentOutput ", "type MyAgentInputOptions ", "type MyAgentOutputSectionsItem ", "type MyAgentPerson "}
+	for i := 1; i < len(order); i++ {
+		if strings.Index(string(src), order[i-1]) > strings.Index(string(src), order[i]) {
+			t.Errorf("%q is generated after %q", order[i-1], order[i])
+		}
+	}
+}
+
+func TestGoName(t *testing.T) {
+	for s, want := range map[string]string{
+		"user_id":     "UserID",
+		"firstName":   "FirstName",
+		"my-agent":    "MyAgent",
+		"image_url":   "ImageURL",
+		"3d_model":    "X3dModel",
+		"HTTPHeaders": "HTTPHeaders",
+	} {
+		if got := GoName(s); got != want {
+			t.Errorf("GoName(%q) = %q, want %q", s, got, want)
+		}
+	}
+}
+
+func TestGenerate_Validate(t *testing.T) {
+	src, err := Generate(Config{
+		Package: "agents",
+		AgentID: "my-agent",
+		InputSchema: json.RawMessage(`{
+			"type": "object",
+			"properties": {
+				"code": {"type": "string", "pattern": "^[A-Z]{3}$"},
+				"lang": {"type": "string", "enum": ["en", "fr"]},
+				"count": {"type": "integer", "minimum": 1, "exclusiveMaximum": 10},
+				"text": {"type": "string", "maxLength": 100},
+				"tags": {"type": "array", "minItems": 1, "items": {"type": "string", "pattern": "^[a-z]+$"}},
+				"author": {"$ref": "#/$defs/Person"}
+			},
+			"required": ["text", "tags"],
+			"$defs": {"Person": {"type": "object", "properties": {"name": {"type": "string", "minLength": 1}}}}
+		}`),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parser.ParseFile(token.NewFileSet(), "types_gen.go", src, 0); err != nil {
+		t.Fatalf("invalid code: %v\n%s", err, src)
+	}
+	for _, want := range []string{
+		"\t\"regexp\"\n\t\"slices\"\n\t\"unicode/utf8\"\n",
+		"myAgentPattern0 = regexp.MustCompile(\"^[A-Z]{3}$\")\n\tmyAgentPattern1 = regexp.MustCompile(\"^[a-z]+$\")\n",
+		"if err := input.Validate(); err != nil {\n\t\treturn nil, fmt.Errorf(\"agents: invalid input: %w\", err)\n\t}",
+		// Optional values are only checked when set
+		"\tif v.Code != \"\" {\n\t\tif !myAgentPattern0.MatchString(v.Code) {\n" +
+			"\t\t\t*errs = append(*errs, workflowai.SchemaError{Path: path + \".code\", Message: \"value does not match pattern \\\"^[A-Z]{3}$\\\"\"})\n",
+		"\t\tif float64(v.Count) >= 10 {\n\t\t\t*errs = append(*errs, workflowai.SchemaError{Path: path + \".count\", Message: \"expected a value < 10\"})\n",
+		"if !slices.Contains([]string{\"en\", \"fr\"}, v.Lang) {",
+		"\tif v.Tags == nil {\n\t\t*errs = append(*errs, workflowai.SchemaError{Path: path, Message: \"missing required property \\\"tags\\\"\"})\n",
+		"\tfor i0, item0 := range v.Tags {\n\t\tpath0 := fmt.Sprintf(\"%s[%d]\", path+\".tags\", i0)\n\t\tif !myAgentPattern1.MatchString(item0) {",
+		"\tif utf8.RuneCountInString(v.Text) > 100 {",
+		"\tif v.Author != nil {\n\t\tv.Author.validate(path+\".author\", errs)\n\t}",
+		"func (v *MyAgentPerson) Validate() error {",
+	} {
+		if !strings.Contains(string(src), want) {
+			t.Errorf("generated code does not contain %q:\n%s", want, src)
+		}
+	}
+}
+
+func TestGenerate_UnsupportedPattern(t *testing.T) {
+	_, err := Generate(Config{
+		Package:     "agents",
+		AgentID:     "my-agent",
+		InputSchema: json.RawMessage(`{"type": "object", "properties": {"password": {"type": "string", "pattern": "^(?=.*\\d).{8,}$"}}}`),
+	})
+	if err == nil || !strings.Contains(err.Error(), `unsupported pattern "^(?=.*\\d).{8,}$"`) {
+		t.Errorf("expected the pattern to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,195 @@
+package codegen
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// writeValidate writes the Validate method of the struct name, and the
+// validate method checking its fields with checks.
+func (g *generator) writeValidate(b *strings.Builder, name string, checks string) {
+	fmt.Fprintf(b, "// Validate checks the required references, enums, patterns, lengths and\n// ranges of the schema of %s, and returns workflowai.SchemaErrors\n// when they are not met.\n", name)
+	fmt.Fprintf(b, "func (v *%s) Validate() error {\n", name)
+	b.WriteString("var errs workflowai.SchemaErrors\nv.validate(\"$\", &errs)\nif len(errs) > 0 {\nreturn errs\n}\nreturn nil\n}\n\n")
+	fmt.Fprintf(b, "func (v *%s) validate(path string, errs *workflowai.SchemaErrors) {\n%s}\n\n", name, checks)
+}
+
+// failure returns the statement adding an error at pathExpr.
+func failure(pathExpr string, message string) string {
+	return fmt.Sprintf("*errs = append(*errs, workflowai.SchemaError{Path: %s, Message: %q})", pathExpr, message)
+}
+
+// isPresent returns the condition of expr being set, for the types whose
+// zero value is omitted from the JSON.
+func isPresent(t string, expr string) string {
+	switch {
+	case t == "string":
+		return expr + ` != ""`
+	case t == "int64" || t == "float64":
+		return expr + " != 0"
+	case strings.HasPrefix(t, "[]"):
+		return "len(" + expr + ") > 0"
+	}
+	return ""
+}
+
+func isNilable(t string) bool {
+	return strings.HasPrefix(t, "*") || strings.HasPrefix(t, "[]") || strings.HasPrefix(t, "map[") || t == "json.RawMessage"
+}
+
+func (g *generator) patternVar(i int) string {
+	name := []rune(g.cfg.Name)
+	return strings.ToLower(string(name[0])) + string(name[1:]) + "Pattern" + strconv.Itoa(i)
+}
+
+// resolve follows the reference of s and its single non null variant.
+func (g *generator) resolve(s *schema) *schema {
+	for s != nil {
+		switch {
+		case s.Ref != "":
+			s = g.defs[strings.TrimPrefix(strings.TrimPrefix(s.Ref, "#/$defs/"), "#/definitions/")]
+		case len(s.AnyOf)+len(s.OneOf) > 0:
+			var nonNull []*schema
+			for _, v := range append(s.AnyOf, s.OneOf...) {
+				if types, _ := v.types(); len(types) > 0 || v.Ref != "" || len(v.AnyOf)+len(v.OneOf) > 0 {
+					nonNull = append(nonNull, v)
+				}
+			}
+			if len(nonNull) != 1 {
+				return nil
+			}
+			s = nonNull[0]
+		default:
+			return s
+		}
+	}
+	return nil
+}
+
+// checks returns the statements validating expr, of Go type t and schema s.
+// pathExpr is the Go expression of the JSON path of expr, and depth the
+// nesting of the loops, used to name their variables.
+func (g *generator) checks(s *schema, t string, expr string, pathExpr string, depth int) string {
+	s = g.resolve(s)
+	if s == nil {
+		return ""
+	}
+	var b strings.Builder
+	switch {
+	case strings.HasPrefix(t, "*"):
+		inner := t[1:]
+		value := "*" + expr
+		if _, ok := g.decls[inner]; ok {
+			// The pointer receiver of validate
+			value = expr
+		}
+		if c := g.checks(s, inner, value, pathExpr, depth); c != "" {
+			fmt.Fprintf(&b, "if %s != nil {\n%s}\n", expr, c)
+		}
+	case strings.HasPrefix(t, "[]"):
+		if s.MinItems != nil {
+			fmt.Fprintf(&b, "if len(%s) < %d {\n%s\n}\n", expr, *s.MinItems, failure(pathExpr, fmt.Sprintf("expected at least %d items", *s.MinItems)))
+		}
+		if s.MaxItems != nil {
+			fmt.Fprintf(&b, "if len(%s) > %d {\n%s\n}\n", expr, *s.MaxItems, failure(pathExpr, fmt.Sprintf("expected at most %d items", *s.MaxItems)))
+		}
+		i, item, itemPath := fmt.Sprintf("i%d", depth), fmt.Sprintf("item%d", depth), fmt.Sprintf("path%d", depth)
+		if c := g.checks(s.Items, t[2:], item, itemPath, depth+1); c != "" {
+			if _, ok := g.decls[t[2:]]; ok {
+				// The pointer receiver of validate
+				fmt.Fprintf(&b, "for %s := range %s {\n%s := &%s[%s]\n", i, expr, item, expr, i)
+			} else {
+				fmt.Fprintf(&b, "for %s, %s := range %s {\n", i, item, expr)
+			}
+			fmt.Fprintf(&b, "%s := fmt.Sprintf(\"%%s[%%d]\", %s, %s)\n%s}\n", itemPath, pathExpr, i, c)
+		}
+	case strings.HasPrefix(t, "map[string]"):
+		var additional *schema
+		if json.Unmarshal(s.AdditionalProperties, &additional) != nil || additional == nil {
+			break
+		}
+		key, value, valuePath := fmt.Sprintf("k%d", depth), fmt.Sprintf("value%d", depth), fmt.Sprintf("path%d", depth)
+		if c := g.checks(additional, t[len("map[string]"):], value, valuePath, depth+1); c != "" {
+			fmt.Fprintf(&b, "for %s, %s := range %s {\n%s := %s + \".\" + %s\n%s}\n", key, value, expr, valuePath, pathExpr, key, c)
+		}
+	case t == "string":
+		g.stringChecks(&b, s, expr, pathExpr)
+	case t == "int64" || t == "float64":
+		g.numberChecks(&b, s, "float64("+expr+")", pathExpr)
+	default:
+		if _, ok := g.decls[t]; ok {
+			fmt.Fprintf(&b, "%s.validate(%s, errs)\n", expr, pathExpr)
+		}
+	}
+	return b.String()
+}
+
+func (g *generator) stringChecks(b *strings.Builder, s *schema, expr string, pathExpr string) {
+	var values []string
+	for _, v := range s.Enum {
+		if str, ok := v.(string); ok {
+			values = append(values, strconv.Quote(str))
+		}
+	}
+	if len(values) > 0 && len(values) == len(s.Enum) {
+		g.imports["slices"] = true
+		fmt.Fprintf(b, "if !slices.Contains([]string{%s}, %s) {\n%s\n}\n", strings.Join(values, ", "), expr, failure(pathExpr, "value is not one of the allowed values"))
+	}
+	if s.MinLength != nil || s.MaxLength != nil {
+		g.imports["unicode/utf8"] = true
+	}
+	if s.MinLength != nil {
+		fmt.Fprintf(b, "if utf8.RuneCountInString(%s) < %d {\n%s\n}\n", expr, *s.MinLength, failure(pathExpr, fmt.Sprintf("expected at least %d characters", *s.MinLength)))
+	}
+	if s.MaxLength != nil {
+		fmt.Fprintf(b, "if utf8.RuneCountInString(%s) > %d {\n%s\n}\n", expr, *s.MaxLength, failure(pathExpr, fmt.Sprintf("expected at most %d characters", *s.MaxLength)))
+	}
+	if s.Pattern != "" {
+		g.imports["regexp"] = true
+		i := len(g.patterns)
+		for j, p := range g.patterns {
+			if p == s.Pattern {
+				i = j
+			}
+		}
+		if i == len(g.patterns) {
+			g.patterns = append(g.patterns, s.Pattern)
+		}
+		fmt.Fprintf(b, "if !%s.MatchString(%s) {\n%s\n}\n", g.patternVar(i), expr, failure(pathExpr, fmt.Sprintf("value does not match pattern %q", s.Pattern)))
+	}
+}
+
+func (g *generator) numberChecks(b *strings.Builder, s *schema, expr string, pathExpr string) {
+	var values []string
+	for _, v := range s.Enum {
+		if n, ok := v.(float64); ok {
+			values = append(values, formatNumber(n))
+		}
+	}
+	if len(values) > 0 && len(values) == len(s.Enum) {
+		g.imports["slices"] = true
+		fmt.Fprintf(b, "if !slices.Contains([]float64{%s}, %s) {\n%s\n}\n", strings.Join(values, ", "), expr, failure(pathExpr, "value is not one of the allowed values"))
+	}
+	bound := func(op string, n float64, message string) {
+		fmt.Fprintf(b, "if %s %s %s {\n%s\n}\n", expr, op, formatNumber(n), failure(pathExpr, message+" "+formatNumber(n)))
+	}
+	if s.Minimum != nil {
+		bound("<", *s.Minimum, "expected a value >=")
+	}
+	if s.Maximum != nil {
+		bound(">", *s.Maximum, "expected a value <=")
+	}
+	var n float64
+	if json.Unmarshal(s.ExclusiveMinimum, &n) == nil {
+		bound("<=", n, "expected a value >")
+	}
+	if json.Unmarshal(s.ExclusiveMaximum, &n) == nil {
+		bound(">=", n, "expected a value <")
+	}
+}
+
+func formatNumber(n float64) string {
+	return strconv.FormatFloat(n, 'g', -1, 64)
+}
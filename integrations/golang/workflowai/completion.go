@@ -0,0 +1,102 @@
+package workflowai
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+// CompletionCost returns the cost in USD reported by WorkflowAI for a
+// completion, summed over its choices. It returns 0 when the cost is missing,
+// e.g. for completions that do not come from WorkflowAI.
+func CompletionCost(completion *openai.ChatCompletion) float64 {
+	total := 0.0
+	for _, choice := range completion.Choices {
+		if f, ok := choice.JSON.ExtraFields["cost_usd"]; ok {
+			cost, _ := strconv.ParseFloat(f.Raw(), 64)
+			total += cost
+		}
+	}
+	return total
+}
+
+// RunInfo links a completion to its WorkflowAI run, to be persisted for
+// support and debugging.
+type RunInfo struct {
+	AgentID   string
+	RunID     string
+	VersionID string
+	// TraceID is the trace ID of the W3C traceparent header of the request,
+	// e.g. set by an OpenTelemetry transport. Only captured by [WithRunInfo].
+	TraceID string
+	// RequestID is the X-Request-Id header of the response. Only captured by
+	// [WithRunInfo].
+	RequestID string
+}
+
+// CompletionRunInfo returns the run of a completion. The version ID is only
+// set for completions that were not streamed, see [ChunkRunInfo].
+func CompletionRunInfo(completion *openai.ChatCompletion) RunInfo {
+	info := RunInfo{VersionID: extraString(completion.JSON.ExtraFields, "version_id")}
+	info.AgentID, info.RunID = splitCompletionID(completion.ID)
+	return info
+}
+
+// ChunkRunInfo returns the run of a chunk of a streamed completion. The
+// chunks have no version ID: the version of a streamed run is the Version of
+// the run returned by [RunService.Get].
+func ChunkRunInfo(chunk openai.ChatCompletionChunk) RunInfo {
+	var info RunInfo
+	info.AgentID, info.RunID = splitCompletionID(chunk.ID)
+	return info
+}
+
+// WithRunInfo fills info with the run of the completion and the trace
+// headers of the exchange, also for streams, whose run is filled as the
+// chunks are read, without the version ID. info must not be shared between
+// concurrent requests.
+//
+//	var info workflowai.RunInfo
+//	completion, err := client.Chat.Completions.New(ctx, params, workflowai.WithRunInfo(&info))
+//	log.Printf("run %s of version %s, trace %s", info.RunID, info.VersionID, info.TraceID)
+func WithRunInfo(info *RunInfo) option.RequestOption {
+	return option.WithMiddleware(func(req *http.Request, next option.MiddlewareNext) (*http.Response, error) {
+		if parts := strings.Split(req.Header.Get("traceparent"), "-"); len(parts) == 4 {
+			info.TraceID = parts[1]
+		}
+		res, err := next(req)
+		if err != nil || !isChatCompletionRequest(req) {
+			return res, err
+		}
+		info.RequestID = res.Header.Get("X-Request-Id")
+		if res.StatusCode >= 400 {
+			return res, nil
+		}
+		if strings.HasPrefix(res.Header.Get("Content-Type"), "text/event-stream") {
+			recorder := &streamRecorder{ReadCloser: res.Body, onDone: func(*completionSummary) {}}
+			recorder.onEvent = func([]byte) { recorder.summary.applyRunInfo(info) }
+			res.Body = recorder
+			return res, nil
+		}
+		body, err := io.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		res.Body = io.NopCloser(bytes.NewReader(body))
+		var summary completionSummary
+		summary.parseCompletion(body)
+		summary.applyRunInfo(info)
+		return res, nil
+	})
+}
+
+func (s *completionSummary) applyRunInfo(info *RunInfo) {
+	info.AgentID, info.RunID = splitCompletionID(s.id)
+	info.VersionID = s.versionID
+}
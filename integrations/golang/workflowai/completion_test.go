@@ -0,0 +1,69 @@
+package workflowai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+func TestCompletionCost(t *testing.T) {
+	var completion openai.ChatCompletion
+	if err := json.Unmarshal([]byte(testCompletion), &completion); err != nil {
+		t.Fatal(err)
+	}
+	if cost := CompletionCost(&completion); cost != 0.5 {
+		t.Errorf("expected a cost of 0.5, got %f", cost)
+	}
+}
+
+func TestRunInfo(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Request-Id", "req-1")
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		if body["stream"] == true {
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprint(w, "data: {\"id\":\"my-agent/run-2\",\"object\":\"chat.completion.chunk\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"hi\"}}]}\n\n")
+			fmt.Fprint(w, "data: [DONE]\n\n")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(testCompletion))
+	}))
+	defer server.Close()
+	client := NewClient(option.WithBaseURL(server.URL+"/v1"), option.WithAPIKey("test"), option.WithMaxRetries(0))
+	params := openai.ChatCompletionNewParams{Model: "my-agent/gpt-4o", Messages: []openai.ChatCompletionMessageParamUnion{openai.UserMessage("hi")}}
+
+	var info RunInfo
+	completion, err := client.Chat.Completions.New(context.Background(), params, WithRunInfo(&info),
+		option.WithHeader("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := RunInfo{AgentID: "my-agent", RunID: "run-1", VersionID: "version-1", TraceID: "4bf92f3577b34da6a3ce929d0e0e4736", RequestID: "req-1"}
+	if info != want {
+		t.Errorf("info = %+v", info)
+	}
+	if got := CompletionRunInfo(completion); got != (RunInfo{AgentID: "my-agent", RunID: "run-1", VersionID: "version-1"}) {
+		t.Errorf("completion info = %+v", got)
+	}
+
+	info = RunInfo{}
+	stream := client.Chat.Completions.NewStreaming(context.Background(), params, WithRunInfo(&info))
+	var chunkInfo RunInfo
+	for stream.Next() {
+		chunkInfo = ChunkRunInfo(stream.Current())
+	}
+	if err := stream.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if chunkInfo != (RunInfo{AgentID: "my-agent", RunID: "run-2"}) || info.RunID != "run-2" || info.VersionID != "" || info.RequestID != "req-1" {
+		t.Errorf("chunk info = %+v, info = %+v", chunkInfo, info)
+	}
+}
@@ -0,0 +1,391 @@
+package workflowai
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+
+	"github.com/workflowai/workflowai/go/examples/workflowai/textsplit"
+)
+
+// Metadata keys of the estimated prompt tokens of the runs compressed by
+// [PromptCompressionMiddleware], before and after the compression.
+const (
+	MetadataOriginalTokens   = "compression_original_tokens"
+	MetadataCompressedTokens = "compression_compressed_tokens"
+)
+
+// Compressor shortens a text of a prompt to about maxTokens tokens, keeping
+// what is relevant to query, the last user message of the request.
+type Compressor interface {
+	Compress(ctx context.Context, text string, query string, maxTokens int) (string, error)
+}
+
+// CompressorFunc adapts a function to the Compressor interface.
+type CompressorFunc func(ctx context.Context, text string, query string, maxTokens int) (string, error)
+
+func (f CompressorFunc) Compress(ctx context.Context, text string, query string, maxTokens int) (string, error) {
+	return f(ctx, text, query, maxTokens)
+}
+
+// ExtractiveCompressor keeps the sentences of a text sharing the most words
+// with the query, then the first and last ones, in their original order. The
+// removed sentences are marked with "[...]". Tokens are counted with count,
+// [textsplit.EstimateTokens] when nil.
+func ExtractiveCompressor(count func(string) int) Compressor {
+	if count == nil {
+		count = textsplit.EstimateTokens
+	}
+	return CompressorFunc(func(_ context.Context, text string, query string, maxTokens int) (string, error) {
+		if count(text) <= maxTokens {
+			return text, nil
+		}
+		units := textsplit.Sentence(40, textsplit.WithTokenCounter(count)).Split(text)
+		terms := map[string]bool{}
+		for _, w := range compressionWords(query) {
+			terms[w] = true
+		}
+		scores := make([]float64, len(units))
+		for i, unit := range units {
+			words := compressionWords(unit)
+			for _, w := range words {
+				if terms[w] {
+					scores[i]++
+				}
+			}
+			if len(words) > 0 {
+				scores[i] /= float64(len(words))
+			}
+			if i == 0 || i == len(units)-1 {
+				// The introduction and the conclusion, on ties
+				scores[i] += 1e-6
+			}
+		}
+		order := make([]int, len(units))
+		for i := range order {
+			order[i] = i
+		}
+		slices.SortStableFunc(order, func(a, b int) int {
+			switch {
+			case scores[a] > scores[b]:
+				return -1
+			case scores[a] < scores[b]:
+				return 1
+			}
+			return 0
+		})
+		kept := make([]bool, len(units))
+		tokens := 0
+		for _, i := range order {
+			if n := count(units[i]); tokens+n <= maxTokens {
+				kept[i], tokens = true, tokens+n
+			}
+		}
+		var b strings.Builder
+		for i, unit := range units {
+			switch {
+			case kept[i]:
+				if b.Len() > 0 {
+					b.WriteString("\n")
+				}
+				b.WriteString(unit)
+			case i == 0 || kept[i-1]:
+				if b.Len() > 0 {
+					b.WriteString("\n")
+				}
+				b.WriteString("[...]")
+			}
+		}
+		return b.String(), nil
+	})
+}
+
+func compressionWords(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// ModelCompressor condenses texts with a chat completion of model, typically
+// a cheap model such as "compressor/gpt-4o-mini-latest".
+func ModelCompressor(completions ChatCompleter, model string) Compressor {
+	return CompressorFunc(func(ctx context.Context, text string, query string, maxTokens int) (string, error) {
+		params := openai.ChatCompletionNewParams{
+			Model: model,
+			Messages: []openai.ChatCompletionMessageParamUnion{
+				openai.SystemMessage("Condense the text to at most {{max_tokens}} tokens. Keep the facts, names, numbers and instructions relevant to the request, and drop the rest. Answer with the condensed text only.\n\nRequest:\n{{query}}"),
+				openai.UserMessage("{{text}}"),
+			},
+			MaxCompletionTokens: openai.Int(int64(maxTokens)),
+			Temperature:         openai.Float(0),
+		}
+		params.SetExtraFields(map[string]any{"input": map[string]any{"text": text, "query": query, "max_tokens": maxTokens}})
+		completion, err := completions.New(ctx, params)
+		if err != nil {
+			return "", err
+		}
+		if len(completion.Choices) == 0 || completion.Choices[0].Message.Content == "" {
+			return "", errors.New("workflowai: completion has no content")
+		}
+		return completion.Choices[0].Message.Content, nil
+	})
+}
+
+type promptCompressor struct {
+	compressor Compressor
+	threshold  int
+	count      func(string) int
+	minTokens  int
+	onError    func(error)
+
+	// compressed are the compressed bodies of the last requests, by hash of
+	// their original body, so that the retries of a request are not
+	// compressed again
+	mu         sync.Mutex
+	compressed map[[sha256.Size]byte][]byte
+	order      [][sha256.Size]byte
+}
+
+// compressionCacheSize is the number of compressed bodies kept for the
+// retries.
+const compressionCacheSize = 64
+
+func (c *promptCompressor) cached(key [sha256.Size]byte) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	body, ok := c.compressed[key]
+	return body, ok
+}
+
+func (c *promptCompressor) cache(key [sha256.Size]byte, body []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.compressed[key]; ok {
+		return
+	}
+	if len(c.order) >= compressionCacheSize {
+		delete(c.compressed, c.order[0])
+		c.order = c.order[1:]
+	}
+	c.compressed[key] = body
+	c.order = append(c.order, key)
+}
+
+type CompressionOption func(*promptCompressor)
+
+// WithCompressionTokenCounter counts the tokens with count, e.g. the
+// tokenizer of the model, instead of [textsplit.EstimateTokens].
+func WithCompressionTokenCounter(count func(string) int) CompressionOption {
+	return func(c *promptCompressor) {
+		c.count = count
+	}
+}
+
+// WithCompressionMinTokens sets the size of the texts that are too short to
+// be compressed, and of the shortest compressed texts. Defaults to 200.
+func WithCompressionMinTokens(n int) CompressionOption {
+	return func(c *promptCompressor) {
+		c.minTokens = n
+	}
+}
+
+// WithCompressionErrorHandler is called when a prompt could not be
+// compressed, in which case it is sent as is. By default errors are logged
+// with the standard logger.
+func WithCompressionErrorHandler(fn func(error)) CompressionOption {
+	return func(c *promptCompressor) {
+		c.onError = fn
+	}
+}
+
+type compressingKey struct{}
+
+// PromptCompressionMiddleware returns a client middleware compressing the
+// chat completion requests whose estimated tokens exceed threshold, down to
+// threshold. The longest input variables and user and assistant messages are
+// compressed first, except the last user message, which is the query of the
+// compressor. The system and developer messages, and the messages with
+// {{placeholders}}, are the instructions of the agent and are never
+// compressed. The tokens are estimated like [BreakdownTokens], so that
+// images and files do not count as the text of their base64. The retries of
+// a compressed request reuse its compression. The estimated tokens of the
+// compressed runs before and after the compression are recorded under
+// [MetadataOriginalTokens] and [MetadataCompressedTokens], so that the
+// quality of the compressed runs can be compared:
+//
+//	compressor := workflowai.ModelCompressor(&client.Chat.Completions, "compressor/gpt-4o-mini-latest")
+//	client := workflowai.NewClient(option.WithMiddleware(workflowai.PromptCompressionMiddleware(compressor, 8000)))
+//
+// The completions of a [ModelCompressor] are not compressed, even when it
+// uses the same client.
+func PromptCompressionMiddleware(compressor Compressor, threshold int, opts ...CompressionOption) option.Middleware {
+	c := &promptCompressor{
+		compressor: compressor,
+		threshold:  threshold,
+		count:      textsplit.EstimateTokens,
+		minTokens:  200,
+		onError:    func(err error) { log.Printf("workflowai: prompt compression: %v", ScrubError(err)) },
+		compressed: map[[sha256.Size]byte][]byte{},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c.middleware
+}
+
+// compressionText is a text of a request that can be compressed.
+type compressionText struct {
+	text   string
+	tokens int
+	set    func(string)
+}
+
+func (c *promptCompressor) middleware(req *http.Request, next option.MiddlewareNext) (*http.Response, error) {
+	if !isChatCompletionRequest(req) || req.Context().Value(compressingKey{}) != nil {
+		return next(req)
+	}
+	body, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	original, err := requestTokens(body, c.count)
+	if err != nil {
+		// Let the server report invalid requests
+		return next(req)
+	}
+	if original <= c.threshold {
+		return next(req)
+	}
+	key := sha256.Sum256(body)
+	if compressed, ok := c.cached(key); ok {
+		setRequestBody(req, compressed)
+		return next(req)
+	}
+	payload, err := decodeJSONObject(body)
+	if err != nil {
+		return next(req)
+	}
+
+	query, texts := c.compressionTexts(payload)
+	slices.SortStableFunc(texts, func(a, b compressionText) int { return b.tokens - a.tokens })
+	ctx := context.WithValue(req.Context(), compressingKey{}, true)
+	excess := original - c.threshold
+	for _, t := range texts {
+		if excess <= 0 || t.tokens <= c.minTokens {
+			break
+		}
+		compressed, err := c.compressor.Compress(ctx, t.text, query, max(t.tokens-excess, c.minTokens))
+		if err != nil {
+			c.onError(fmt.Errorf("compressing a text of %d tokens: %w", t.tokens, err))
+			return next(req)
+		}
+		if n := c.count(compressed); n < t.tokens {
+			t.set(compressed)
+			excess -= t.tokens - n
+		}
+	}
+
+	if excess == original-c.threshold {
+		return next(req)
+	}
+	metadata, _ := payload["metadata"].(map[string]any)
+	if metadata == nil {
+		metadata = map[string]any{}
+	}
+	metadata[MetadataOriginalTokens] = strconv.Itoa(original)
+	metadata[MetadataCompressedTokens] = strconv.Itoa(c.threshold + excess)
+	payload["metadata"] = metadata
+	encoded, err := encodeJSON(payload)
+	if err != nil {
+		return nil, err
+	}
+	c.cache(key, encoded)
+	setRequestBody(req, encoded)
+	return next(req)
+}
+
+// compressionTexts returns the last user message of a request, and the texts
+// of its other user and assistant messages without placeholders and the
+// strings of its input.
+func (c *promptCompressor) compressionTexts(payload map[string]any) (string, []compressionText) {
+	var texts []compressionText
+	add := func(text string, set func(string)) {
+		texts = append(texts, compressionText{text: text, tokens: c.count(text), set: set})
+	}
+	messages, _ := payload["messages"].([]any)
+	last := -1
+	for i, m := range messages {
+		if message, _ := m.(map[string]any); message["role"] == "user" {
+			last = i
+		}
+	}
+	var query string
+	for i, m := range messages {
+		message, _ := m.(map[string]any)
+		if i != last && (message["role"] != "user" && message["role"] != "assistant" || isTemplatedMessage(message)) {
+			continue
+		}
+		switch content := message["content"].(type) {
+		case string:
+			if i == last {
+				query = content
+			} else {
+				add(content, func(s string) { message["content"] = s })
+			}
+		case []any:
+			for _, p := range content {
+				part, ok := p.(map[string]any)
+				text, isText := part["text"].(string)
+				if !ok || !isText {
+					continue
+				}
+				if i == last {
+					query += text
+				} else {
+					add(text, func(s string) { part["text"] = s })
+				}
+			}
+		}
+	}
+	if input, ok := payload["input"].(map[string]any); ok {
+		keys := make([]string, 0, len(input))
+		for k := range input {
+			keys = append(keys, k)
+		}
+		slices.Sort(keys)
+		for _, k := range keys {
+			if text, ok := input[k].(string); ok {
+				add(text, func(s string) { input[k] = s })
+			}
+		}
+	}
+	return query, texts
+}
+
+// isTemplatedMessage reports whether the content of a message has
+// {{placeholders}} of the input variables.
+func isTemplatedMessage(message map[string]any) bool {
+	switch content := message["content"].(type) {
+	case string:
+		return strings.Contains(content, "{{")
+	case []any:
+		for _, p := range content {
+			part, _ := p.(map[string]any)
+			if text, ok := part["text"].(string); ok && strings.Contains(text, "{{") {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,159 @@
+package workflowai
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+func TestExtractiveCompressor(t *testing.T) {
+	var sentences []string
+	for i := range 40 {
+		sentences = append(sentences, fmt.Sprintf("Paragraph %d is about the weather in the mountains.", i))
+	}
+	sentences[20] = "The refund policy allows refunds within 30 days."
+	text := strings.Join(sentences, "\n\n")
+	compressor := ExtractiveCompressor(nil)
+
+	compressed, err := compressor.Compress(context.Background(), text, "What is the refund policy?", 60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(compressed, "refunds within 30 days") || !strings.Contains(compressed, "[...]") || len(compressed) >= len(text)/2 {
+		t.Errorf("unexpected compression %q", compressed)
+	}
+	if strings.Index(compressed, "Paragraph 0 ") > strings.Index(compressed, "refund") {
+		t.Error("expected the original order")
+	}
+	if short, _ := compressor.Compress(context.Background(), "Short text.", "", 60); short != "Short text." {
+		t.Errorf("expected short texts to be kept, got %q", short)
+	}
+}
+
+func TestPromptCompressionMiddleware(t *testing.T) {
+	var bodies []map[string]any
+	var failures int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		bodies = append(bodies, body)
+		if body["model"] == "compressor/gpt-4o-mini" {
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `{"id": "compressor/run-1", "object": "chat.completion", "created": 1, "model": "gpt-4o-mini", "choices": [{"index": 0, "finish_reason": "stop", "message": {"role": "assistant", "content": "The document is about refunds."}}]}`)
+			return
+		}
+		if failures > 0 {
+			failures--
+			w.Header().Set("Retry-After-Ms", "1")
+			writeJSON(w, http.StatusServiceUnavailable, map[string]any{"error": map[string]any{"message": "unavailable"}})
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, testCompletion)
+	}))
+	defer server.Close()
+
+	document := strings.Repeat("This is a long document about many things. ", 200)
+	var compressor Compressor
+	var handled []error
+	client := NewClient(option.WithBaseURL(server.URL+"/v1"), option.WithAPIKey("test"), option.WithMaxRetries(0),
+		option.WithMiddleware(PromptCompressionMiddleware(CompressorFunc(func(ctx context.Context, text, query string, maxTokens int) (string, error) {
+			return compressor.Compress(ctx, text, query, maxTokens)
+		}), 1000, WithCompressionErrorHandler(func(err error) { handled = append(handled, err) }))))
+	compressor = ModelCompressor(&client.Chat.Completions, "compressor/gpt-4o-mini")
+	ctx := context.Background()
+	instructions := "Answer from the document. " + strings.Repeat("Be precise and cite the document. ", 50) + "\n\n{{document}}"
+	params := openai.ChatCompletionNewParams{
+		Model: "support/gpt-4o",
+		Messages: []openai.ChatCompletionMessageParamUnion{
+			openai.SystemMessage(instructions),
+			openai.UserMessage("What is the document about?"),
+		},
+	}
+	params.SetExtraFields(map[string]any{"input": map[string]any{"document": document}})
+
+	// The retry of the completion is not compressed again
+	failures = 1
+	if _, err := client.Chat.Completions.New(ctx, params, option.WithMaxRetries(1)); err != nil {
+		t.Fatal(err)
+	}
+	if len(bodies) != 3 || bodies[0]["model"] != "compressor/gpt-4o-mini" || bodies[2]["model"] == "compressor/gpt-4o-mini" {
+		t.Fatalf("expected one compression and two attempts, got %d requests", len(bodies))
+	}
+	input := bodies[0]["input"].(map[string]any)
+	if input["query"] != "What is the document about?" || input["text"] != document {
+		t.Errorf("unexpected compression input %v", input["query"])
+	}
+	if document := bodies[2]["input"].(map[string]any)["document"]; document != "The document is about refunds." {
+		t.Errorf("unexpected compressed input %q", document)
+	}
+	messages := bodies[2]["messages"].([]any)
+	if content := messages[0].(map[string]any)["content"]; content != instructions {
+		t.Errorf("expected the instructions to be sent as is, got %q", content)
+	}
+	metadata := bodies[2]["metadata"].(map[string]any)
+	original, _ := strconv.Atoi(metadata[MetadataOriginalTokens].(string))
+	compressed, _ := strconv.Atoi(metadata[MetadataCompressedTokens].(string))
+	if original < 2000 || compressed >= 1000 {
+		t.Errorf("unexpected metadata %v", metadata)
+	}
+
+	// Short prompts are sent as is
+	bodies = nil
+	short := openai.ChatCompletionNewParams{Model: "support/gpt-4o", Messages: []openai.ChatCompletionMessageParamUnion{openai.UserMessage("Hi")}}
+	if _, err := client.Chat.Completions.New(ctx, short); err != nil {
+		t.Fatal(err)
+	}
+	if len(bodies) != 1 || bodies[0]["metadata"] != nil {
+		t.Errorf("unexpected requests %v", bodies)
+	}
+
+	// Prompts that cannot be compressed are sent as is
+	bodies = nil
+	compressor = CompressorFunc(func(context.Context, string, string, int) (string, error) {
+		return "", errors.New("compressor unavailable")
+	})
+	// Another prompt, not compressed before
+	params.SetExtraFields(map[string]any{"input": map[string]any{"document": document, "language": "en"}})
+	if _, err := client.Chat.Completions.New(ctx, params); err != nil {
+		t.Fatal(err)
+	}
+	if len(handled) != 1 || len(bodies) != 1 || bodies[0]["input"].(map[string]any)["document"] != document {
+		t.Errorf("expected the original prompt, got errors %v", handled)
+	}
+
+	// Images count from their dimensions, not from their base64
+	bodies = nil
+	compressions := 0
+	compressor = CompressorFunc(func(context.Context, string, string, int) (string, error) {
+		compressions++
+		return "compressed", nil
+	})
+	data := append(testPNG(t, 512, 512, 255), make([]byte, 1<<20)...)
+	image := openai.ChatCompletionNewParams{
+		Model: "support/gpt-4o",
+		Messages: []openai.ChatCompletionMessageParamUnion{
+			openai.AssistantMessage(strings.Repeat("An earlier answer. ", 100)),
+			openai.UserMessage([]openai.ChatCompletionContentPartUnionParam{
+				openai.TextContentPart("What is in this image?"),
+				openai.ImageContentPart(openai.ChatCompletionContentPartImageImageURLParam{URL: "data:image/png;base64," + base64.StdEncoding.EncodeToString(data)}),
+			}),
+		},
+	}
+	if _, err := client.Chat.Completions.New(ctx, image); err != nil {
+		t.Fatal(err)
+	}
+	if compressions != 0 || len(bodies) != 1 || bodies[0]["metadata"] != nil {
+		t.Errorf("expected the request with an image to be sent as is, got %d compressions", compressions)
+	}
+}
@@ -0,0 +1,275 @@
+package workflowai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+// ChatCompleter creates chat completions. It is implemented by
+// [openai.ChatCompletionService], so `&client.Chat.Completions` can be passed
+// wherever a ChatCompleter is expected.
+type ChatCompleter interface {
+	New(ctx context.Context, body openai.ChatCompletionNewParams, opts ...option.RequestOption) (*openai.ChatCompletion, error)
+}
+
+// WithConversationID sets the conversation of a completion, so that the
+// runs of a conversation are grouped in WorkflowAI instead of appearing as
+// unrelated runs. The ID must be stable across the turns of the conversation.
+// Without it, WorkflowAI matches the messages of a completion to the
+// conversations of the agent.
+func WithConversationID(conversationID string) option.RequestOption {
+	return option.WithJSONSet("conversation_id", conversationID)
+}
+
+// ConversationManager sends chat completions for multi-turn conversations,
+// loading the history from a ConversationStore before each call and saving the
+// new messages and the assistant reply afterwards. The completions are tagged
+// with the conversation ID, see [WithConversationID].
+type ConversationManager struct {
+	completions ChatCompleter
+	store       ConversationStore
+	maxMessages int
+	// memory returns the memory of a conversation, nil to send the full
+	// history
+	memory func(ctx context.Context, conversationID string) ConversationMemory
+	// onMemoryError is called when the memory of a conversation cannot be
+	// updated
+	onMemoryError func(conversationID string, err error)
+}
+
+type ConversationOption func(*ConversationManager)
+
+// WithMaxMessages trims the stored history to its system and developer
+// messages, moved first, and its last n other messages after each exchange.
+// The kept messages start past the tool messages whose call was trimmed. A
+// value <= 0 keeps the full history, which is the default. It is ignored for
+// the conversations with a memory, which decides the messages the store
+// keeps: trimming would drop messages the memory has not remembered.
+func WithMaxMessages(n int) ConversationOption {
+	return func(m *ConversationManager) {
+		m.maxMessages = n
+	}
+}
+
+// WithConversationMemory builds the context of the conversations with
+// memory, e.g. a [SummaryMemory], instead of sending their full history.
+func WithConversationMemory(memory ConversationMemory) ConversationOption {
+	return func(m *ConversationManager) {
+		m.memory = func(context.Context, string) ConversationMemory { return memory }
+	}
+}
+
+// WithConversationMemorySelector selects the memory of each conversation,
+// e.g. from its ID or from a value of the context set by the caller of Send. A
+// nil memory sends the full history. A conversation should keep its memory
+// across its turns, as the memories remove the messages they remember from
+// the store.
+func WithConversationMemorySelector(selector func(ctx context.Context, conversationID string) ConversationMemory) ConversationOption {
+	return func(m *ConversationManager) {
+		m.memory = selector
+	}
+}
+
+// WithMemoryErrorHandler sets the function called when the memory of a
+// conversation cannot be updated after an exchange, which logs the error by
+// default. The exchange is saved regardless and the store keeps the messages
+// the memory did not remember, so the memory catches up on a later exchange.
+func WithMemoryErrorHandler(fn func(conversationID string, err error)) ConversationOption {
+	return func(m *ConversationManager) {
+		m.onMemoryError = fn
+	}
+}
+
+func NewConversationManager(completions ChatCompleter, store ConversationStore, opts ...ConversationOption) *ConversationManager {
+	m := &ConversationManager{
+		completions: completions,
+		store:       store,
+		onMemoryError: func(conversationID string, err error) {
+			log.Printf("workflowai: updating the memory of conversation %s: %v", conversationID, ScrubError(err))
+		},
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// Store returns the store backing the manager.
+func (m *ConversationManager) Store() ConversationStore {
+	return m.store
+}
+
+// Send appends messages to the conversation history and creates a completion
+// with the full history. params.Messages is ignored and replaced by the history.
+// Nothing is persisted if the completion fails.
+func (m *ConversationManager) Send(
+	ctx context.Context,
+	conversationID string,
+	params openai.ChatCompletionNewParams,
+	messages ...openai.ChatCompletionMessageParamUnion,
+) (*openai.ChatCompletion, error) {
+	memory, err := m.conversationMemory(ctx, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	history, err := m.prepare(ctx, conversationID, memory, &params, messages)
+	if err != nil {
+		return nil, err
+	}
+	completion, err := m.completions.New(ctx, params, WithConversationID(conversationID))
+	if err != nil {
+		return nil, err
+	}
+	if err := m.save(ctx, conversationID, memory, history, completion, messages); err != nil {
+		return nil, err
+	}
+	return completion, nil
+}
+
+// SendStreaming is [ConversationManager.Send] with a streamed completion,
+// calling onChunk with each chunk as it arrives. The completion is accumulated
+// from the chunks and persisted once the stream ends. It requires the
+// completions of the manager to be a [ChatService].
+func (m *ConversationManager) SendStreaming(
+	ctx context.Context,
+	conversationID string,
+	params openai.ChatCompletionNewParams,
+	onChunk func(openai.ChatCompletionChunk),
+	messages ...openai.ChatCompletionMessageParamUnion,
+) (*openai.ChatCompletion, error) {
+	service, ok := m.completions.(ChatService)
+	if !ok {
+		return nil, errors.New("workflowai: the completions of the conversation manager do not support streaming")
+	}
+	memory, err := m.conversationMemory(ctx, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	history, err := m.prepare(ctx, conversationID, memory, &params, messages)
+	if err != nil {
+		return nil, err
+	}
+	stream := service.NewStreaming(ctx, params, WithConversationID(conversationID))
+	defer stream.Close()
+	var acc openai.ChatCompletionAccumulator
+	for stream.Next() {
+		chunk := stream.Current()
+		acc.AddChunk(chunk)
+		if onChunk != nil {
+			onChunk(chunk)
+		}
+	}
+	if err := stream.Err(); err != nil {
+		return nil, err
+	}
+	if err := m.save(ctx, conversationID, memory, history, &acc.ChatCompletion, messages); err != nil {
+		return nil, err
+	}
+	return &acc.ChatCompletion, nil
+}
+
+// conversationMemory returns the memory of a conversation, storing its state
+// in the store of the manager by default.
+func (m *ConversationManager) conversationMemory(ctx context.Context, conversationID string) (ConversationMemory, error) {
+	if m.memory == nil {
+		return nil, nil
+	}
+	memory := m.memory(ctx, conversationID)
+	if w, ok := memory.(*windowMemory); ok {
+		return w.withStore(m.store)
+	}
+	return memory, nil
+}
+
+// prepare replaces the messages of params with the context of the memory, or
+// the history, followed by the new messages. It returns the history.
+func (m *ConversationManager) prepare(ctx context.Context, conversationID string, memory ConversationMemory, params *openai.ChatCompletionNewParams, messages []openai.ChatCompletionMessageParamUnion) ([]openai.ChatCompletionMessageParamUnion, error) {
+	if len(messages) == 0 {
+		return nil, errors.New("workflowai: at least one message is required")
+	}
+	history, err := m.store.Load(ctx, conversationID)
+	if err != nil {
+		return nil, fmt.Errorf("workflowai: loading conversation %s: %w", conversationID, err)
+	}
+	sent := history
+	if memory != nil {
+		if sent, err = memory.Context(ctx, conversationID, history); err != nil {
+			return nil, err
+		}
+	}
+	params.Messages = append(slices.Clip(sent), messages...)
+	return history, nil
+}
+
+// save persists the new messages and the reply of the completion, and
+// updates the memory. Once the exchange is persisted, the errors of the memory
+// are reported to onMemoryError instead of failing the exchange, whose retry
+// would append it again.
+func (m *ConversationManager) save(ctx context.Context, conversationID string, memory ConversationMemory, history []openai.ChatCompletionMessageParamUnion, completion *openai.ChatCompletion, messages []openai.ChatCompletionMessageParamUnion) error {
+	if len(completion.Choices) == 0 {
+		return errors.New("workflowai: completion has no choices")
+	}
+
+	newMessages := make([]openai.ChatCompletionMessageParamUnion, 0, len(messages)+1)
+	newMessages = append(newMessages, messages...)
+	newMessages = append(newMessages, completion.Choices[0].Message.ToParam())
+	if err := m.store.Append(ctx, conversationID, newMessages...); err != nil {
+		return fmt.Errorf("workflowai: saving conversation %s: %w", conversationID, err)
+	}
+	if memory != nil {
+		history = append(slices.Clip(history), newMessages...)
+		keep, err := memory.Update(ctx, conversationID, history, len(newMessages))
+		if err != nil {
+			m.onMemoryError(conversationID, err)
+			return nil
+		}
+		if keep < len(history) {
+			if err := m.store.Trim(ctx, conversationID, keep); err != nil {
+				m.onMemoryError(conversationID, fmt.Errorf("workflowai: trimming conversation %s: %w", conversationID, err))
+			}
+		}
+		return nil
+	}
+	if m.maxMessages > 0 {
+		if err := m.trim(ctx, conversationID, append(slices.Clip(history), newMessages...)); err != nil {
+			return fmt.Errorf("workflowai: trimming conversation %s: %w", conversationID, err)
+		}
+	}
+	return nil
+}
+
+// trim keeps the system and developer messages of the history and its last
+// maxMessages other messages. The history is rewritten when it has system or
+// developer messages to keep, as the store only trims from the start.
+func (m *ConversationManager) trim(ctx context.Context, conversationID string, history []openai.ChatCompletionMessageParamUnion) error {
+	var pinned, rest []openai.ChatCompletionMessageParamUnion
+	for _, message := range history {
+		if message.OfSystem != nil || message.OfDeveloper != nil {
+			pinned = append(pinned, message)
+		} else {
+			rest = append(rest, message)
+		}
+	}
+	if len(rest) <= m.maxMessages {
+		return nil
+	}
+	cut := windowStart(rest, len(rest)-m.maxMessages)
+	if len(pinned) == 0 {
+		return m.store.Trim(ctx, conversationID, len(rest)-cut)
+	}
+	if err := m.store.Trim(ctx, conversationID, 0); err != nil {
+		return err
+	}
+	return m.store.Append(ctx, conversationID, append(pinned, rest[cut:]...)...)
+}
+
+// History returns the stored messages of a conversation.
+func (m *ConversationManager) History(ctx context.Context, conversationID string) ([]openai.ChatCompletionMessageParamUnion, error) {
+	return m.store.Load(ctx, conversationID)
+}
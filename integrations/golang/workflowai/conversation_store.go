@@ -0,0 +1,99 @@
+package workflowai
+
+import (
+	"bytes"
+	"context"
+	"sync"
+
+	"github.com/openai/openai-go"
+)
+
+// ConversationStore persists the message history of conversations, keyed by
+// conversation ID. Implementations must be safe for concurrent use.
+type ConversationStore interface {
+	// Load returns the messages of the conversation in order. An unknown
+	// conversation returns an empty history and no error.
+	Load(ctx context.Context, conversationID string) ([]openai.ChatCompletionMessageParamUnion, error)
+	// Append adds messages at the end of the conversation, creating it if needed.
+	Append(ctx context.Context, conversationID string, messages ...openai.ChatCompletionMessageParamUnion) error
+	// Trim only keeps the last maxMessages messages of the conversation.
+	Trim(ctx context.Context, conversationID string, maxMessages int) error
+}
+
+// ConversationStateStore persists the state of the conversation memories,
+// e.g. the summary of a conversation, see [ConversationMemory].
+// Implementations must be safe for concurrent use.
+type ConversationStateStore interface {
+	// LoadState returns the state stored under key for the conversation, nil
+	// when there is none.
+	LoadState(ctx context.Context, conversationID, key string) ([]byte, error)
+	SaveState(ctx context.Context, conversationID, key string, state []byte) error
+}
+
+// MemoryConversationStore is an in-process ConversationStore and
+// ConversationStateStore. History is lost when the process exits, so it is
+// mostly useful for tests and single replica deployments.
+type MemoryConversationStore struct {
+	mu            sync.RWMutex
+	conversations map[string][]openai.ChatCompletionMessageParamUnion
+	states        map[[2]string][]byte
+}
+
+func NewMemoryConversationStore() *MemoryConversationStore {
+	return &MemoryConversationStore{
+		conversations: map[string][]openai.ChatCompletionMessageParamUnion{},
+		states:        map[[2]string][]byte{},
+	}
+}
+
+func (s *MemoryConversationStore) Load(_ context.Context, conversationID string) ([]openai.ChatCompletionMessageParamUnion, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	messages := s.conversations[conversationID]
+	// Returning a copy so that callers can not mutate the stored history
+	out := make([]openai.ChatCompletionMessageParamUnion, len(messages))
+	copy(out, messages)
+	return out, nil
+}
+
+func (s *MemoryConversationStore) Append(_ context.Context, conversationID string, messages ...openai.ChatCompletionMessageParamUnion) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.conversations[conversationID] = append(s.conversations[conversationID], messages...)
+	return nil
+}
+
+func (s *MemoryConversationStore) Trim(_ context.Context, conversationID string, maxMessages int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	messages, ok := s.conversations[conversationID]
+	if !ok || len(messages) <= maxMessages {
+		return nil
+	}
+	if maxMessages <= 0 {
+		delete(s.conversations, conversationID)
+		return nil
+	}
+	trimmed := make([]openai.ChatCompletionMessageParamUnion, maxMessages)
+	copy(trimmed, messages[len(messages)-maxMessages:])
+	s.conversations[conversationID] = trimmed
+	return nil
+}
+
+func (s *MemoryConversationStore) LoadState(_ context.Context, conversationID, key string) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return bytes.Clone(s.states[[2]string{conversationID, key}]), nil
+}
+
+func (s *MemoryConversationStore) SaveState(_ context.Context, conversationID, key string, state []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.states[[2]string{conversationID, key}] = bytes.Clone(state)
+	return nil
+}
@@ -0,0 +1,50 @@
+package workflowai
+
+import (
+	"context"
+	"testing"
+
+	"github.com/openai/openai-go"
+)
+
+func TestMemoryConversationStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryConversationStore()
+
+	history, err := store.Load(ctx, "unknown")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 0 {
+		t.Fatalf("expected empty history, got %d messages", len(history))
+	}
+
+	if err := store.Append(ctx, "conv", openai.UserMessage("1"), openai.AssistantMessage("2"), openai.UserMessage("3")); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Trim(ctx, "conv", 2); err != nil {
+		t.Fatal(err)
+	}
+
+	history, err = store.Load(ctx, "conv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 || history[0].OfAssistant == nil {
+		t.Fatalf("unexpected history after trim: %v", history)
+	}
+
+	// Mutating the loaded history does not change the store
+	history[0] = openai.UserMessage("mutated")
+	reloaded, _ := store.Load(ctx, "conv")
+	if reloaded[0].OfAssistant == nil {
+		t.Fatal("store was mutated through the loaded slice")
+	}
+
+	if err := store.Trim(ctx, "conv", 0); err != nil {
+		t.Fatal(err)
+	}
+	if history, _ := store.Load(ctx, "conv"); len(history) != 0 {
+		t.Fatalf("expected conversation to be cleared, got %d messages", len(history))
+	}
+}
@@ -0,0 +1,163 @@
+package workflowai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+type fakeCompleter struct {
+	calls [][]openai.ChatCompletionMessageParamUnion
+	reply string
+	err   error
+}
+
+func (f *fakeCompleter) New(_ context.Context, body openai.ChatCompletionNewParams, _ ...option.RequestOption) (*openai.ChatCompletion, error) {
+	f.calls = append(f.calls, body.Messages)
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &openai.ChatCompletion{
+		Choices: []openai.ChatCompletionChoice{
+			{Message: openai.ChatCompletionMessage{Role: "assistant", Content: f.reply}},
+		},
+	}, nil
+}
+
+func TestConversationManager_Send(t *testing.T) {
+	ctx := context.Background()
+	completer := &fakeCompleter{reply: "hello"}
+	manager := NewConversationManager(completer, NewMemoryConversationStore(), WithMaxMessages(3))
+
+	if _, err := manager.Send(ctx, "conv", openai.ChatCompletionNewParams{}, openai.SystemMessage("sys"), openai.UserMessage("hi")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := manager.Send(ctx, "conv", openai.ChatCompletionNewParams{}, openai.UserMessage("again")); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(completer.calls[1]) != 4 {
+		t.Fatalf("expected the second call to replay 3 messages plus the new one, got %d", len(completer.calls[1]))
+	}
+
+	history, err := manager.History(ctx, "conv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The system message is kept on top of the last 3 messages
+	if len(history) != 4 || history[0].OfSystem == nil {
+		t.Fatalf("expected history to be trimmed to the system message and 3 messages, got %d", len(history))
+	}
+	if history[3].OfAssistant == nil {
+		t.Fatalf("expected last message to be the assistant reply")
+	}
+}
+
+func TestConversationManager_SendTrimsToolResults(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryConversationStore()
+	call := openai.ChatCompletionMessage{Role: "assistant", ToolCalls: []openai.ChatCompletionMessageToolCall{
+		{ID: "call_1", Type: "function", Function: openai.ChatCompletionMessageToolCallFunction{Name: "search", Arguments: "{}"}},
+		{ID: "call_2", Type: "function", Function: openai.ChatCompletionMessageToolCallFunction{Name: "search", Arguments: "{}"}},
+	}}
+	store.Append(ctx, "conv", openai.UserMessage("find"), call.ToParam(), openai.ToolMessage("r1", "call_1"), openai.ToolMessage("r2", "call_2"))
+	manager := NewConversationManager(&fakeCompleter{reply: "hello"}, store, WithMaxMessages(3))
+
+	if _, err := manager.Send(ctx, "conv", openai.ChatCompletionNewParams{}, openai.UserMessage("again")); err != nil {
+		t.Fatal(err)
+	}
+	// The last 3 messages would start with the result of call_2, without its call
+	history, _ := manager.History(ctx, "conv")
+	if len(history) != 2 || history[0].OfUser == nil {
+		t.Errorf("expected the history to start past the tool results, got %q", messageTexts(history))
+	}
+}
+
+func TestConversationManager_SendFailureDoesNotPersist(t *testing.T) {
+	ctx := context.Background()
+	completer := &fakeCompleter{err: errors.New("boom")}
+	manager := NewConversationManager(completer, NewMemoryConversationStore())
+
+	if _, err := manager.Send(ctx, "conv", openai.ChatCompletionNewParams{}, openai.UserMessage("hi")); err == nil {
+		t.Fatal("expected an error")
+	}
+	history, _ := manager.History(ctx, "conv")
+	if len(history) != 0 {
+		t.Fatalf("expected no history to be persisted, got %d messages", len(history))
+	}
+}
+
+func TestConversationManager_SendStreaming(t *testing.T) {
+	ctx := context.Background()
+	var body map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&body)
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, content := range []string{"hel", "lo"} {
+			fmt.Fprintf(w, "data: {\"id\":\"a/1\",\"object\":\"chat.completion.chunk\",\"choices\":[{\"index\":0,\"delta\":{\"role\":\"assistant\",\"content\":%q}}]}\n\n", content)
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+	client := NewClient(option.WithBaseURL(server.URL), option.WithAPIKey("test"), option.WithMaxRetries(0))
+	manager := NewConversationManager(&client.Chat.Completions, NewMemoryConversationStore())
+
+	var chunks []string
+	completion, err := manager.SendStreaming(ctx, "conv", openai.ChatCompletionNewParams{}, func(chunk openai.ChatCompletionChunk) {
+		chunks = append(chunks, chunk.Choices[0].Delta.Content)
+	}, openai.UserMessage("hi"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if completion.Choices[0].Message.Content != "hello" || len(chunks) != 2 {
+		t.Errorf("content = %q, chunks = %q", completion.Choices[0].Message.Content, chunks)
+	}
+	if body["conversation_id"] != "conv" {
+		t.Errorf("conversation_id = %v", body["conversation_id"])
+	}
+	history, _ := manager.History(ctx, "conv")
+	if len(history) != 2 || history[1].OfAssistant == nil || history[1].OfAssistant.Content.OfString.Value != "hello" {
+		t.Fatalf("unexpected history %+v", history)
+	}
+
+	// A ChatCompleter without streaming
+	manager = NewConversationManager(&fakeCompleter{}, NewMemoryConversationStore())
+	if _, err := manager.SendStreaming(ctx, "conv", openai.ChatCompletionNewParams{}, nil, openai.UserMessage("hi")); err == nil {
+		t.Error("expected an error for a completer without streaming")
+	}
+}
+
+func TestRunService_SearchConversation(t *testing.T) {
+	var body RunSearchParams
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/_/agents/support/runs/search" {
+			json.NewDecoder(r.Body).Decode(&body)
+			writeJSON(w, http.StatusOK, map[string]any{"items": []any{map[string]any{"id": "run-1"}, map[string]any{"id": "run-2"}}})
+			return
+		}
+		id := strings.TrimPrefix(r.URL.Path, "/v1/_/agents/support/runs/")
+		run := map[string]any{"id": id, "conversation_id": "conv", "metadata": map[string]any{"conversation_id": "other"}}
+		if id == "run-2" {
+			run["conversation_id"] = "other"
+		}
+		writeJSON(w, http.StatusOK, run)
+	}))
+	defer server.Close()
+	client := NewClient(option.WithBaseURL(server.URL+"/v1"), option.WithAPIKey("test"), option.WithMaxRetries(0))
+
+	page, err := client.Runs.SearchConversation(context.Background(), "support", "conv", RunSearchParams{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Items) != 1 || page.Items[0].ID != "run-1" || page.Items[0].Conversation() != "conv" || body.Limit != 10 {
+		t.Errorf("page = %+v, params = %+v", page, body)
+	}
+}
@@ -0,0 +1,129 @@
+package workflowai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openai/openai-go/option"
+)
+
+// KeyProvider returns the API key used for each request, so that keys can be
+// rotated without recreating the client.
+type KeyProvider interface {
+	// APIKey returns the current key. refresh is true when the server
+	// rejected the previous key, in which case providers that cache the key
+	// must load it again.
+	APIKey(ctx context.Context, refresh bool) (string, error)
+}
+
+// KeyProviderFunc adapts a function to the KeyProvider interface.
+type KeyProviderFunc func(ctx context.Context, refresh bool) (string, error)
+
+func (f KeyProviderFunc) APIKey(ctx context.Context, refresh bool) (string, error) {
+	return f(ctx, refresh)
+}
+
+// APIKeyMiddleware returns a client middleware that authenticates each
+// request with the key of p, replacing the API key of the client. Requests
+// already sent keep the key they were sent with.
+//
+// When the server answers 401 Unauthorized, the key is refreshed immediately
+// and, if it changed, the request is sent again with the new key.
+func APIKeyMiddleware(p KeyProvider) option.Middleware {
+	return func(req *http.Request, next option.MiddlewareNext) (*http.Response, error) {
+		key, err := p.APIKey(req.Context(), false)
+		if err != nil {
+			return nil, fmt.Errorf("workflowai: loading the API key: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+key)
+		res, err := next(req)
+		if err != nil || res.StatusCode != http.StatusUnauthorized {
+			return res, err
+		}
+
+		newKey, err := p.APIKey(req.Context(), true)
+		if err != nil || newKey == key || req.GetBody == nil {
+			return res, nil
+		}
+		body, err := req.GetBody()
+		if err != nil {
+			return res, nil
+		}
+		res.Body.Close()
+		retry := req.Clone(req.Context())
+		retry.Body = body
+		retry.Header.Set("Authorization", "Bearer "+newKey)
+		return next(retry)
+	}
+}
+
+// KeyFile is a KeyProvider reading the key from a file, e.g. a mounted
+// Kubernetes secret. The file is checked for changes at most every check
+// interval, and read again immediately when the key is rejected.
+type KeyFile struct {
+	path     string
+	interval time.Duration
+
+	mu      sync.Mutex
+	key     string
+	modTime time.Time
+	checked time.Time
+}
+
+var _ KeyProvider = (*KeyFile)(nil)
+
+// NewKeyFile reads the key from path, whose surrounding whitespace is
+// ignored. checkInterval defaults to 30 seconds.
+func NewKeyFile(path string, checkInterval time.Duration) (*KeyFile, error) {
+	if checkInterval <= 0 {
+		checkInterval = 30 * time.Second
+	}
+	f := &KeyFile{path: path, interval: checkInterval}
+	if err := f.load(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *KeyFile) load() error {
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return fmt.Errorf("workflowai: reading the key file: %w", err)
+	}
+	f.checked = time.Now()
+	if f.key != "" && info.ModTime().Equal(f.modTime) {
+		return nil
+	}
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		return fmt.Errorf("workflowai: reading the key file: %w", err)
+	}
+	key := strings.TrimSpace(string(data))
+	if key == "" {
+		return errors.New("workflowai: the key file is empty")
+	}
+	f.key, f.modTime = key, info.ModTime()
+	return nil
+}
+
+// APIKey returns the key of the file. When the file cannot be read, e.g. while
+// it is being replaced, the previous key is returned.
+func (f *KeyFile) APIKey(_ context.Context, refresh bool) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if refresh {
+		// A rejected key may have been replaced within the same second as the
+		// previous write, so the modification time is not trusted.
+		f.modTime = time.Time{}
+	}
+	if refresh || time.Since(f.checked) >= f.interval {
+		_ = f.load()
+	}
+	return f.key, nil
+}
@@ -0,0 +1,113 @@
+package workflowai
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+func TestAPIKeyMiddleware_KeyFile(t *testing.T) {
+	var mu sync.Mutex
+	validKey := "wai-key-1"
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		received = append(received, r.Header.Get("Authorization"))
+		if r.Header.Get("Authorization") != "Bearer "+validKey {
+			writeJSON(w, http.StatusUnauthorized, map[string]any{"error": map[string]any{"message": "invalid key"}})
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, testCompletion)
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "key")
+	if err := os.WriteFile(path, []byte("wai-key-1\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	keys, err := NewKeyFile(path, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := openai.NewClient(
+		option.WithBaseURL(server.URL),
+		option.WithAPIKey("static"),
+		option.WithMaxRetries(0),
+		option.WithMiddleware(APIKeyMiddleware(keys)),
+	)
+	params := openai.ChatCompletionNewParams{
+		Model:    "my-agent/gpt-4o",
+		Messages: []openai.ChatCompletionMessageParamUnion{openai.UserMessage("Hi")},
+	}
+	if _, err := client.Chat.Completions.New(context.Background(), params); err != nil {
+		t.Fatal(err)
+	}
+
+	// The key is rotated before the check interval elapses
+	mu.Lock()
+	validKey = "wai-key-2"
+	mu.Unlock()
+	if err := os.WriteFile(path, []byte("wai-key-2\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Chat.Completions.New(context.Background(), params); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"Bearer wai-key-1", "Bearer wai-key-1", "Bearer wai-key-2"}
+	if fmt.Sprint(received) != fmt.Sprint(expected) {
+		t.Errorf("expected %v, got %v", expected, received)
+	}
+
+	// A rejected key that did not change is not retried
+	mu.Lock()
+	validKey = "wai-key-3"
+	mu.Unlock()
+	received = nil
+	if _, err := client.Chat.Completions.New(context.Background(), params); err == nil {
+		t.Fatal("expected an authentication error")
+	}
+	if len(received) != 1 {
+		t.Errorf("expected a single attempt, got %v", received)
+	}
+}
+
+func TestKeyFile_CheckInterval(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "key")
+	if err := os.WriteFile(path, []byte("wai-key-1"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	keys, err := NewKeyFile(path, time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("wai-key-2"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	future := time.Now().Add(time.Minute)
+	_ = os.Chtimes(path, future, future)
+	time.Sleep(2 * time.Millisecond)
+	if key, _ := keys.APIKey(context.Background(), false); key != "wai-key-2" {
+		t.Errorf("expected the new key, got %q", key)
+	}
+
+	// The previous key is kept while the file is missing
+	os.Remove(path)
+	time.Sleep(2 * time.Millisecond)
+	if key, _ := keys.APIKey(context.Background(), false); key != "wai-key-2" {
+		t.Errorf("expected the previous key, got %q", key)
+	}
+	if _, err := NewKeyFile(path, 0); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
@@ -0,0 +1,139 @@
+// Package datadog traces the chat completions of a WorkflowAI client with
+// dd-trace-go:
+//
+//	tracer.Start()
+//	defer tracer.Stop()
+//	client := workflowai.NewClient(option.WithMiddleware(datadog.Middleware()))
+//
+// Every attempt of a completion is a span of type "llm", child of the span
+// of the request context. Spans are tagged with the LLM Observability
+// attributes: model_name, model_provider, input_tokens, output_tokens and
+// total_tokens, along with the WorkflowAI agent, run and version IDs, the
+// cost and the time to first token of streamed completions.
+package datadog
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/openai/openai-go/option"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+
+	"github.com/workflowai/workflowai/go/examples/workflowai"
+)
+
+// OperationName is the name of the completion spans.
+const OperationName = "workflowai.chat.completion"
+
+type config struct {
+	service string
+	mlApp   string
+}
+
+type Option func(*config)
+
+// WithService sets the service of the spans. Defaults to the service of the
+// tracer.
+func WithService(name string) Option {
+	return func(c *config) {
+		c.service = name
+	}
+}
+
+// WithMLApp sets the ml_app tag, under which LLM Observability groups the
+// spans.
+func WithMLApp(name string) Option {
+	return func(c *config) {
+		c.mlApp = name
+	}
+}
+
+// Middleware returns a client middleware tracing the chat completions.
+func Middleware(opts ...Option) option.Middleware {
+	c := &config{}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	var hooks workflowai.Hooks
+	hooks.OnRequestStart(func(ctx context.Context, e workflowai.RequestStartEvent) {
+		if span, ok := tracer.SpanFromContext(ctx); ok {
+			span.SetTag("model_name", e.Model)
+			span.SetTag(ext.ResourceName, e.Model)
+			span.SetTag("workflowai.stream", e.Stream)
+			span.SetTag("workflowai.retry_count", e.RetryCount)
+		}
+	})
+	hooks.OnFirstToken(func(ctx context.Context, e workflowai.FirstTokenEvent) {
+		if span, ok := tracer.SpanFromContext(ctx); ok {
+			span.SetTag("workflowai.ttft_ms", e.TimeToFirstToken.Milliseconds())
+		}
+	})
+	hooks.OnToolCall(func(ctx context.Context, e workflowai.ToolCallEvent) {
+		if span, ok := tracer.SpanFromContext(ctx); ok {
+			span.SetTag("workflowai.tool_calls", true)
+		}
+	})
+	hooks.OnComplete(func(ctx context.Context, e workflowai.CompleteEvent) {
+		span, ok := tracer.SpanFromContext(ctx)
+		if !ok {
+			return
+		}
+		setRunTags(span, e.AgentID, e.RunID)
+		if e.VersionID != "" {
+			span.SetTag("workflowai.version_id", e.VersionID)
+		}
+		span.SetTag("input_tokens", e.PromptTokens)
+		span.SetTag("output_tokens", e.CompletionTokens)
+		span.SetTag("total_tokens", e.PromptTokens+e.CompletionTokens)
+		span.SetTag("workflowai.cost_usd", e.CostUSD)
+		span.Finish()
+	})
+	hooks.OnError(func(ctx context.Context, e workflowai.ErrorEvent) {
+		span, ok := tracer.SpanFromContext(ctx)
+		if !ok {
+			return
+		}
+		setRunTags(span, e.AgentID, e.RunID)
+		if e.StatusCode != 0 {
+			span.SetTag(ext.HTTPCode, e.StatusCode)
+		}
+		if e.Code != "" {
+			span.SetTag("workflowai.error_code", e.Code)
+		}
+		span.Finish(tracer.WithError(workflowai.ScrubError(e.Err)))
+	})
+	next := hooks.Middleware()
+
+	return func(req *http.Request, mwNext option.MiddlewareNext) (*http.Response, error) {
+		if req.Method != http.MethodPost || !strings.HasSuffix(req.URL.Path, "/chat/completions") {
+			return mwNext(req)
+		}
+		spanOpts := []tracer.StartSpanOption{
+			tracer.SpanType("llm"),
+			tracer.Measured(),
+			tracer.Tag(ext.SpanKind, ext.SpanKindClient),
+			tracer.Tag(ext.Component, "workflowai"),
+			tracer.Tag("model_provider", "workflowai"),
+		}
+		if c.service != "" {
+			spanOpts = append(spanOpts, tracer.ServiceName(c.service))
+		}
+		if c.mlApp != "" {
+			spanOpts = append(spanOpts, tracer.Tag("ml_app", c.mlApp))
+		}
+		_, ctx := tracer.StartSpanFromContext(req.Context(), OperationName, spanOpts...)
+		return next(req.WithContext(ctx), mwNext)
+	}
+}
+
+func setRunTags(span tracer.Span, agentID string, runID string) {
+	if agentID != "" {
+		span.SetTag("workflowai.agent_id", agentID)
+	}
+	if runID != "" {
+		span.SetTag("workflowai.run_id", runID)
+	}
+}
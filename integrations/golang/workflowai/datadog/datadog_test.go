@@ -0,0 +1,88 @@
+package datadog
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/mocktracer"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+)
+
+func TestMiddleware(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/models" {
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `{"object":"list","data":[]}`)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		if strings.Contains(string(body), "fail") {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"id":"my-agent/run-2","error":{"code":"bad_request","message":"invalid"}}`)
+			return
+		}
+		fmt.Fprint(w, `{"id":"my-agent/run-1","object":"chat.completion","created":1,"model":"gpt-4o","version_id":"v1",
+			"choices":[{"index":0,"finish_reason":"stop","cost_usd":0.5,"message":{"role":"assistant","content":"Hello"}}],
+			"usage":{"prompt_tokens":10,"completion_tokens":2,"total_tokens":12}}`)
+	}))
+	defer server.Close()
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	client := openai.NewClient(
+		option.WithBaseURL(server.URL),
+		option.WithAPIKey("test"),
+		option.WithMaxRetries(0),
+		option.WithMiddleware(Middleware(WithService("my-service"), WithMLApp("support"))),
+	)
+	parent, ctx := tracer.StartSpanFromContext(context.Background(), "handler")
+	for _, message := range []string{"hello", "fail"} {
+		client.Chat.Completions.New(ctx, openai.ChatCompletionNewParams{
+			Model:    "my-agent/gpt-4o",
+			Messages: []openai.ChatCompletionMessageParamUnion{openai.UserMessage(message)},
+		})
+	}
+	// Other endpoints are not traced
+	client.Models.List(ctx)
+	parent.Finish()
+
+	spans := mt.FinishedSpans()
+	if len(spans) != 3 {
+		t.Fatalf("expected 3 spans, got %d", len(spans))
+	}
+	ok, failed := spans[0], spans[1]
+	if ok.OperationName() != OperationName || ok.ParentID() != parent.Context().SpanID() {
+		t.Errorf("unexpected span %v", ok)
+	}
+	for key, value := range map[string]any{
+		"service.name":           "my-service",
+		"resource.name":          "my-agent/gpt-4o",
+		"span.type":              "llm",
+		"ml_app":                 "support",
+		"model_name":             "my-agent/gpt-4o",
+		"model_provider":         "workflowai",
+		"input_tokens":           int64(10),
+		"output_tokens":          int64(2),
+		"total_tokens":           int64(12),
+		"workflowai.cost_usd":    0.5,
+		"workflowai.agent_id":    "my-agent",
+		"workflowai.run_id":      "run-1",
+		"workflowai.version_id":  "v1",
+		"workflowai.retry_count": 0,
+	} {
+		if ok.Tag(key) != value {
+			t.Errorf("tag %s = %v (%T), expected %v", key, ok.Tag(key), ok.Tag(key), value)
+		}
+	}
+	if failed.Tag("error") == nil || failed.Tag("workflowai.error_code") != "bad_request" || failed.Tag("workflowai.run_id") != "run-2" {
+		t.Errorf("unexpected error tags %v", failed.Tags())
+	}
+}
@@ -0,0 +1,190 @@
+package workflowai
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// DatasetCase is a single input of an evaluation dataset, with an optional
+// expected output.
+type DatasetCase struct {
+	ID             string          `json:"id"`
+	Input          json.RawMessage `json:"input"`
+	ExpectedOutput json.RawMessage `json:"expected_output,omitempty"`
+	Metadata       map[string]any  `json:"metadata,omitempty"`
+}
+
+// Dataset is a list of evaluation cases.
+//
+// WorkflowAI does not store datasets, so the dataset ID is derived from the
+// content of the cases: the same file always yields the same ID, which makes
+// it possible to track which version of a dataset produced a run through the
+// run metadata returned by [Dataset.Metadata]. Datasets cannot be uploaded
+// either: running the cases, e.g. with workflowaieval, is what records them
+// in WorkflowAI, as runs tagged with that metadata.
+type Dataset struct {
+	ID    string
+	Name  string
+	Cases []DatasetCase
+}
+
+// LoadDataset reads a dataset from a ".jsonl" or ".csv" file. The name of the
+// dataset is the file name without its extension.
+func LoadDataset(path string) (*Dataset, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	ext := filepath.Ext(path)
+	name := strings.TrimSuffix(filepath.Base(path), ext)
+	switch strings.ToLower(ext) {
+	case ".jsonl", ".ndjson":
+		return ReadJSONLDataset(f, name)
+	case ".csv":
+		return ReadCSVDataset(f, name)
+	default:
+		return nil, fmt.Errorf("workflowai: unsupported dataset extension %q", ext)
+	}
+}
+
+// ReadJSONLDataset reads one [DatasetCase] per line. Empty lines are ignored
+// and cases without an ID are numbered by line.
+func ReadJSONLDataset(r io.Reader, name string) (*Dataset, error) {
+	ds := &Dataset{Name: name}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		raw := bytes.TrimSpace(scanner.Bytes())
+		if len(raw) == 0 {
+			continue
+		}
+		var c DatasetCase
+		if err := json.Unmarshal(raw, &c); err != nil {
+			return nil, fmt.Errorf("workflowai: dataset line %d: %w", line, err)
+		}
+		if len(c.Input) == 0 {
+			return nil, fmt.Errorf("workflowai: dataset line %d: missing input", line)
+		}
+		if c.ID == "" {
+			c.ID = strconv.Itoa(line)
+		}
+		ds.Cases = append(ds.Cases, c)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return ds, ds.finalize()
+}
+
+// ReadCSVDataset reads a dataset from a CSV file with a header row.
+//
+// An "input" column is parsed as JSON, falling back to a JSON string. Without
+// an "input" column, the input is an object built from all the other columns.
+// The optional "expected_output" and "id" columns are handled the same way as
+// in the JSONL format.
+func ReadCSVDataset(r io.Reader, name string) (*Dataset, error) {
+	reader := csv.NewReader(r)
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("workflowai: reading dataset header: %w", err)
+	}
+
+	ds := &Dataset{Name: name}
+	for row := 1; ; row++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("workflowai: dataset row %d: %w", row, err)
+		}
+
+		c := DatasetCase{ID: strconv.Itoa(row)}
+		fields := map[string]string{}
+		for i, column := range header {
+			switch column {
+			case "id":
+				c.ID = record[i]
+			case "input":
+				c.Input = jsonOrString(record[i])
+			case "expected_output":
+				if record[i] != "" {
+					c.ExpectedOutput = jsonOrString(record[i])
+				}
+			default:
+				fields[column] = record[i]
+			}
+		}
+		if c.Input == nil {
+			if c.Input, err = json.Marshal(fields); err != nil {
+				return nil, err
+			}
+		}
+		ds.Cases = append(ds.Cases, c)
+	}
+	return ds, ds.finalize()
+}
+
+func jsonOrString(s string) json.RawMessage {
+	if json.Valid([]byte(s)) {
+		return json.RawMessage(s)
+	}
+	b, _ := json.Marshal(s)
+	return b
+}
+
+func (ds *Dataset) finalize() error {
+	if len(ds.Cases) == 0 {
+		return errors.New("workflowai: dataset is empty")
+	}
+	seen := make(map[string]bool, len(ds.Cases))
+	h := sha256.New()
+	for _, c := range ds.Cases {
+		if seen[c.ID] {
+			return fmt.Errorf("workflowai: duplicate dataset case id %q", c.ID)
+		}
+		seen[c.ID] = true
+
+		// Encoding through json.Compact so that formatting does not change the ID
+		var buf bytes.Buffer
+		for _, part := range []json.RawMessage{c.Input, c.ExpectedOutput} {
+			buf.Reset()
+			if len(part) > 0 {
+				if err := json.Compact(&buf, part); err != nil {
+					return fmt.Errorf("workflowai: dataset case %q: %w", c.ID, err)
+				}
+			}
+			h.Write(buf.Bytes())
+			h.Write([]byte{0})
+		}
+		h.Write([]byte(c.ID))
+		h.Write([]byte{0})
+	}
+	ds.ID = "ds_" + hex.EncodeToString(h.Sum(nil))[:16]
+	return nil
+}
+
+// Metadata returns the completion metadata that links a run to a case of the
+// dataset, so that runs can be searched by dataset on WorkflowAI.
+func (ds *Dataset) Metadata(c DatasetCase) map[string]any {
+	return map[string]any{
+		"dataset_id":      ds.ID,
+		"dataset_name":    ds.Name,
+		"dataset_case_id": c.ID,
+	}
+}
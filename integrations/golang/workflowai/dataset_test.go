@@ -0,0 +1,68 @@
+package workflowai
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestReadJSONLDataset(t *testing.T) {
+	ds, err := ReadJSONLDataset(strings.NewReader(`{"input": {"q": "1"}, "expected_output": {"a": 1}}
+
+{"id": "custom", "input": {"q": "2"}}
+`), "cases")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ds.Cases) != 2 || ds.Cases[0].ID != "1" || ds.Cases[1].ID != "custom" {
+		t.Fatalf("unexpected cases %+v", ds.Cases)
+	}
+	if string(ds.Cases[0].ExpectedOutput) != `{"a": 1}` || ds.Cases[1].ExpectedOutput != nil {
+		t.Fatalf("unexpected expected outputs %+v", ds.Cases)
+	}
+
+	// Formatting does not change the ID
+	reformatted, err := ReadJSONLDataset(strings.NewReader(`{"input":{"q":"1"},"expected_output":{"a":1}}
+{"id":"custom","input":{"q":"2"}}`), "cases")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ds.ID != reformatted.ID || !strings.HasPrefix(ds.ID, "ds_") {
+		t.Fatalf("expected stable ids, got %s and %s", ds.ID, reformatted.ID)
+	}
+
+	if _, err := ReadJSONLDataset(strings.NewReader(`{"id": "a", "input": 1}`+"\n"+`{"id": "a", "input": 2}`), "dup"); err == nil {
+		t.Fatal("expected an error for duplicate ids")
+	}
+}
+
+func TestReadCSVDataset(t *testing.T) {
+	ds, err := ReadCSVDataset(strings.NewReader("question,language,expected_output\nWhat?,fr,\"{\"\"answer\"\": \"\"Quoi\"\"}\"\nWho?,en,\n"), "cases")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ds.Cases) != 2 {
+		t.Fatalf("expected 2 cases, got %d", len(ds.Cases))
+	}
+	var input map[string]string
+	if err := json.Unmarshal(ds.Cases[0].Input, &input); err != nil {
+		t.Fatal(err)
+	}
+	if input["question"] != "What?" || input["language"] != "fr" {
+		t.Fatalf("unexpected input %v", input)
+	}
+	if string(ds.Cases[0].ExpectedOutput) != `{"answer": "Quoi"}` || ds.Cases[1].ExpectedOutput != nil {
+		t.Fatalf("unexpected expected outputs %+v", ds.Cases)
+	}
+
+	ds, err = ReadCSVDataset(strings.NewReader("id,input\na,plain text\n"), "cases")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ds.Cases[0].ID != "a" || string(ds.Cases[0].Input) != `"plain text"` {
+		t.Fatalf("unexpected case %+v", ds.Cases[0])
+	}
+	if ds.Metadata(ds.Cases[0])["dataset_case_id"] != "a" {
+		t.Fatal("expected the case id in the metadata")
+	}
+}
@@ -0,0 +1,391 @@
+package workflowai
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// DiffKind is the kind of a difference between two outputs.
+type DiffKind string
+
+const (
+	DiffAdded   DiffKind = "added"
+	DiffRemoved DiffKind = "removed"
+	DiffChanged DiffKind = "changed"
+)
+
+// Difference is a difference between two outputs at a JSON path, e.g.
+// "$.items[0].price". Old is nil for added fields and New for removed ones.
+type Difference struct {
+	Path string
+	Kind DiffKind
+	Old  any
+	New  any
+}
+
+func (d Difference) String() string {
+	switch d.Kind {
+	case DiffAdded:
+		return fmt.Sprintf("+ %s: %s", d.Path, diffValue(d.New))
+	case DiffRemoved:
+		return fmt.Sprintf("- %s: %s", d.Path, diffValue(d.Old))
+	}
+	return fmt.Sprintf("~ %s: %s -> %s", d.Path, diffValue(d.Old), diffValue(d.New))
+}
+
+func diffValue(v any) string {
+	data, err := encodeJSON(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
+
+// OutputDiff is the field level difference between two outputs.
+type OutputDiff struct {
+	Differences []Difference
+	// Fields is the number of leaf values of the outputs, counting the
+	// values at the same path of both outputs once, and Unchanged the number
+	// of those that are equal
+	Fields    int
+	Unchanged int
+}
+
+// Equal reports whether the outputs have no differences.
+func (d *OutputDiff) Equal() bool {
+	return len(d.Differences) == 0
+}
+
+// Similarity returns the share of the leaf values that are unchanged, from 0
+// to 1 for equal outputs, to quantify the change between two versions.
+func (d *OutputDiff) Similarity() float64 {
+	if d.Fields == 0 {
+		return 1
+	}
+	return float64(d.Unchanged) / float64(d.Fields)
+}
+
+// String returns the differences, one per line.
+func (d *OutputDiff) String() string {
+	var b strings.Builder
+	for _, diff := range d.Differences {
+		b.WriteString(diff.String())
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// ListMatch is how the elements of two lists are paired before being
+// compared.
+type ListMatch struct {
+	key       string
+	unordered bool
+}
+
+var (
+	// ListByIndex pairs the elements at the same index. It is the default.
+	ListByIndex = ListMatch{}
+	// ListUnordered pairs the equal elements whatever their order, then the
+	// other elements in order, for lists used as sets.
+	ListUnordered = ListMatch{unordered: true}
+)
+
+// ListByKey pairs the objects with the same value of field, e.g. an ID, so
+// that reordered, inserted or removed elements are reported as such. The
+// paths of the paired elements have their index in the new list.
+func ListByKey(field string) ListMatch {
+	return ListMatch{key: field}
+}
+
+type differ struct {
+	tolerance float64
+	// lists are the list matchings by path pattern, "" for all the lists
+	lists   map[string]ListMatch
+	ignored map[string]bool
+}
+
+type DiffOption func(*differ)
+
+// WithNumericTolerance sets the largest absolute difference of numbers that
+// are considered equal, e.g. 0.01 for prices. Defaults to 0.
+func WithNumericTolerance(tolerance float64) DiffOption {
+	return func(d *differ) {
+		d.tolerance = tolerance
+	}
+}
+
+// WithListMatching sets how the elements of the lists at path are paired,
+// e.g. "$.items" or "$.orders[*].lines" with [*] for any index, or of all the
+// lists when path is empty.
+func WithListMatching(path string, match ListMatch) DiffOption {
+	return func(d *differ) {
+		d.lists[path] = match
+	}
+}
+
+// WithIgnoredFields ignores the fields at the paths, e.g. "$.generated_at" or
+// "$.items[*].id".
+func WithIgnoredFields(paths ...string) DiffOption {
+	return func(d *differ) {
+		for _, path := range paths {
+			d.ignored[path] = true
+		}
+	}
+}
+
+func newDiffer(opts []DiffOption) *differ {
+	d := &differ{lists: map[string]ListMatch{}, ignored: map[string]bool{}}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
+}
+
+// DiffOutputs compares two outputs, e.g. the outputs of two versions of an
+// agent for the same input. Structured outputs are compared field by field
+// and text outputs as a whole.
+func DiffOutputs(before, after string, opts ...DiffOption) *OutputDiff {
+	a, errA := decodeDiffValue([]byte(before))
+	b, errB := decodeDiffValue([]byte(after))
+	if errA != nil || errB != nil {
+		a, b = before, after
+	}
+	d := newDiffer(opts)
+	var out OutputDiff
+	d.diff("$", a, b, &out)
+	return &out
+}
+
+// DiffValues compares two values encoded as JSON, e.g. the decoded outputs
+// of an agent.
+func DiffValues(before, after any, opts ...DiffOption) (*OutputDiff, error) {
+	a, err := json.Marshal(before)
+	if err != nil {
+		return nil, err
+	}
+	b, err := json.Marshal(after)
+	if err != nil {
+		return nil, err
+	}
+	return DiffOutputs(string(a), string(b), opts...), nil
+}
+
+func decodeDiffValue(data []byte) (any, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var v any
+	if err := decoder.Decode(&v); err != nil {
+		return nil, err
+	}
+	if decoder.More() {
+		return nil, errNoJSON
+	}
+	return v, nil
+}
+
+var diffIndex = regexp.MustCompile(`\[\d+\]`)
+
+// pattern returns path with [*] for its indexes.
+func (d *differ) pattern(path string) string {
+	return diffIndex.ReplaceAllString(path, "[*]")
+}
+
+func (d *differ) diff(path string, a, b any, out *OutputDiff) {
+	if d.ignored[d.pattern(path)] {
+		return
+	}
+	switch av := a.(type) {
+	case map[string]any:
+		bv, ok := b.(map[string]any)
+		if !ok {
+			break
+		}
+		keys := make([]string, 0, len(av)+len(bv))
+		for k := range av {
+			keys = append(keys, k)
+		}
+		for k := range bv {
+			if _, ok := av[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		slices.Sort(keys)
+		for _, k := range keys {
+			va, inA := av[k]
+			vb, inB := bv[k]
+			switch {
+			case inA && inB:
+				d.diff(path+"."+k, va, vb, out)
+			case inA:
+				d.removed(path+"."+k, va, out)
+			default:
+				d.added(path+"."+k, vb, out)
+			}
+		}
+		return
+	case []any:
+		if bv, ok := b.([]any); ok {
+			d.diffList(path, av, bv, out)
+			return
+		}
+	}
+	if d.equalLeaf(a, b) {
+		out.Fields++
+		out.Unchanged++
+		return
+	}
+	out.Fields += max(leaves(a), leaves(b))
+	out.Differences = append(out.Differences, Difference{Path: path, Kind: DiffChanged, Old: a, New: b})
+}
+
+func (d *differ) added(path string, v any, out *OutputDiff) {
+	if d.ignored[d.pattern(path)] {
+		return
+	}
+	out.Fields += leaves(v)
+	out.Differences = append(out.Differences, Difference{Path: path, Kind: DiffAdded, New: v})
+}
+
+func (d *differ) removed(path string, v any, out *OutputDiff) {
+	if d.ignored[d.pattern(path)] {
+		return
+	}
+	out.Fields += leaves(v)
+	out.Differences = append(out.Differences, Difference{Path: path, Kind: DiffRemoved, Old: v})
+}
+
+func (d *differ) diffList(path string, a, b []any, out *OutputDiff) {
+	match, ok := d.lists[d.pattern(path)]
+	if !ok {
+		match = d.lists[""]
+	}
+	// pairs are the indexes of the paired elements of b, by index of a, -1
+	// when unpaired
+	pairs := make([]int, len(a))
+	used := make([]bool, len(b))
+	for i := range pairs {
+		pairs[i] = -1
+	}
+	switch {
+	case match.key != "":
+		for i, x := range a {
+			for j, y := range b {
+				if !used[j] && d.sameKey(x, y, match.key) {
+					pairs[i], used[j] = j, true
+					break
+				}
+			}
+		}
+	case match.unordered:
+		for i, x := range a {
+			for j, y := range b {
+				if !used[j] && d.equal(path, x, y) {
+					pairs[i], used[j] = j, true
+					break
+				}
+			}
+		}
+		// The other elements in order
+		j := 0
+		for i := range a {
+			if pairs[i] >= 0 {
+				continue
+			}
+			for j < len(b) && used[j] {
+				j++
+			}
+			if j == len(b) {
+				break
+			}
+			pairs[i], used[j] = j, true
+		}
+	default:
+		for i := range min(len(a), len(b)) {
+			pairs[i], used[i] = i, true
+		}
+	}
+	for i, j := range pairs {
+		if j < 0 {
+			d.removed(fmt.Sprintf("%s[%d]", path, i), a[i], out)
+		} else {
+			d.diff(fmt.Sprintf("%s[%d]", path, j), a[i], b[j], out)
+		}
+	}
+	for j, y := range b {
+		if !used[j] {
+			d.added(fmt.Sprintf("%s[%d]", path, j), y, out)
+		}
+	}
+}
+
+// equal reports whether a and b have no differences.
+func (d *differ) equal(path string, a, b any) bool {
+	var out OutputDiff
+	d.diff(path, a, b, &out)
+	return out.Equal()
+}
+
+func (d *differ) sameKey(a, b any, key string) bool {
+	x, ok := a.(map[string]any)
+	if !ok {
+		return false
+	}
+	y, ok := b.(map[string]any)
+	if !ok {
+		return false
+	}
+	kx, ok := x[key]
+	if !ok {
+		return false
+	}
+	ky, ok := y[key]
+	return ok && d.equalLeaf(kx, ky)
+}
+
+func (d *differ) equalLeaf(a, b any) bool {
+	na, okA := a.(json.Number)
+	nb, okB := b.(json.Number)
+	if okA && okB {
+		if na == nb {
+			return true
+		}
+		fa, errA := na.Float64()
+		fb, errB := nb.Float64()
+		return errA == nil && errB == nil && math.Abs(fa-fb) <= d.tolerance
+	}
+	if isContainer(a) || isContainer(b) {
+		// Containers of different types
+		return false
+	}
+	return a == b
+}
+
+func isContainer(v any) bool {
+	switch v.(type) {
+	case map[string]any, []any:
+		return true
+	}
+	return false
+}
+
+// leaves returns the number of leaf values of v, empty objects and lists
+// being leaves.
+func leaves(v any) int {
+	n := 0
+	switch v := v.(type) {
+	case map[string]any:
+		for _, x := range v {
+			n += leaves(x)
+		}
+	case []any:
+		for _, x := range v {
+			n += leaves(x)
+		}
+	}
+	return max(n, 1)
+}
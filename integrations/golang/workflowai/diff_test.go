@@ -0,0 +1,72 @@
+package workflowai
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDiffOutputs(t *testing.T) {
+	before := `{"summary": "Late delivery", "priority": 2, "score": 0.81, "tags": ["shipping", "refund"],
+		"items": [{"sku": "A", "qty": 1}, {"sku": "B", "qty": 2}], "internal": {"cached": true}}`
+	after := `{"summary": "Late delivery", "priority": 3, "score": 0.8, "tags": ["refund", "shipping", "urgent"],
+		"items": [{"sku": "C", "qty": 1}, {"sku": "B", "qty": 3}], "language": "en"}`
+
+	diff := DiffOutputs(before, after)
+	expected := []string{
+		`- $.internal: {"cached":true}`,
+		`~ $.items[0].sku: "A" -> "C"`,
+		`~ $.items[1].qty: 2 -> 3`,
+		`+ $.language: "en"`,
+		`~ $.priority: 2 -> 3`,
+		`~ $.score: 0.81 -> 0.8`,
+		`~ $.tags[0]: "shipping" -> "refund"`,
+		`~ $.tags[1]: "refund" -> "shipping"`,
+		`+ $.tags[2]: "urgent"`,
+	}
+	if got := strings.TrimSpace(diff.String()); got != strings.Join(expected, "\n") {
+		t.Errorf("unexpected diff\n%s", got)
+	}
+
+	diff = DiffOutputs(before, after,
+		WithNumericTolerance(0.05),
+		WithListMatching("$.tags", ListUnordered),
+		WithListMatching("$.items", ListByKey("sku")),
+		WithIgnoredFields("$.internal", "$.language"),
+	)
+	expected = []string{
+		`- $.items[0]: {"qty":1,"sku":"A"}`,
+		`~ $.items[1].qty: 2 -> 3`,
+		`+ $.items[0]: {"qty":1,"sku":"C"}`,
+		`~ $.priority: 2 -> 3`,
+		`+ $.tags[2]: "urgent"`,
+	}
+	if got := strings.TrimSpace(diff.String()); got != strings.Join(expected, "\n") {
+		t.Errorf("unexpected diff\n%s", got)
+	}
+	// summary, score, the 2 tags and the sku of B are unchanged, A and C
+	// count for their 2 fields
+	if diff.Unchanged != 5 || diff.Fields != 12 {
+		t.Errorf("unexpected counts %d/%d", diff.Unchanged, diff.Fields)
+	}
+
+	if diff := DiffOutputs(before, before); !diff.Equal() || diff.Similarity() != 1 {
+		t.Errorf("expected equal outputs, got %v", diff)
+	}
+	if diff := DiffOutputs("Hello", "Hi"); diff.String() != "~ $: \"Hello\" -> \"Hi\"\n" {
+		t.Errorf("unexpected text diff %q", diff)
+	}
+}
+
+func TestDiffValues(t *testing.T) {
+	type output struct {
+		Label string   `json:"label"`
+		Tags  []string `json:"tags,omitempty"`
+	}
+	diff, err := DiffValues(output{Label: "a", Tags: []string{"x"}}, output{Label: "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diff.Differences) != 1 || diff.Differences[0].Kind != DiffRemoved || diff.Differences[0].Path != "$.tags" {
+		t.Errorf("unexpected diff %v", diff)
+	}
+}
@@ -0,0 +1,8 @@
+// Package workflowai contains helpers for building Go applications on top of
+// the WorkflowAI OpenAI compatible endpoint.
+//
+// The package builds on github.com/openai/openai-go: completions are still
+// created with an [openai.Client] configured with the WorkflowAI base URL and
+// API key, and the helpers in this package add WorkflowAI specific behavior
+// around it.
+package workflowai
@@ -0,0 +1,142 @@
+package workflowai
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/openai/openai-go"
+
+	"github.com/workflowai/workflowai/go/examples/workflowai/textsplit"
+)
+
+// CostEstimate is the cost of a chat completion predicted before it is sent,
+// from the pricing of the models catalog.
+type CostEstimate struct {
+	// Model is the catalog model of the request, e.g. "gpt-4o-mini-latest"
+	// for "my-agent/#1/production"
+	Model        string
+	PromptTokens int
+	// MaxCompletionTokens bounds the completion tokens of all the choices:
+	// the max_completion_tokens or max_tokens of the request, or the output
+	// limit of the model. It is 0 when the completion is not bounded.
+	MaxCompletionTokens int64
+	// PromptUSD is the cost of the prompt, the cost of the request when the
+	// model replies with no tokens
+	PromptUSD float64
+	// MaxUSD adds the cost of the largest completion to the cost of the
+	// prompt. It is infinite when the completion is not bounded.
+	MaxUSD float64
+}
+
+// Within reports whether the request cannot cost more than budgetUSD.
+func (e *CostEstimate) Within(budgetUSD float64) bool {
+	return e.MaxUSD <= budgetUSD
+}
+
+func (e *CostEstimate) String() string {
+	return fmt.Sprintf("$%.6f to $%.6f (%s, %d prompt tokens, up to %d completion tokens)",
+		e.PromptUSD, e.MaxUSD, e.Model, e.PromptTokens, e.MaxCompletionTokens)
+}
+
+// EstimateRequestCost estimates the cost of params with model, its entry of
+// the models catalog. count counts the tokens of a text, e.g. the Count
+// method of a workflowai/tiktoken tokenizer for the prompt cost to be exact,
+// and defaults to [textsplit.EstimateTokens].
+func EstimateRequestCost(params openai.ChatCompletionNewParams, model *ModelInfo, count func(string) int) (*CostEstimate, error) {
+	if count == nil {
+		count = textsplit.EstimateTokens
+	}
+	breakdown, err := BreakdownTokens(params, count)
+	if err != nil {
+		return nil, err
+	}
+	e := &CostEstimate{Model: model.ID, PromptTokens: breakdown.Total}
+	e.PromptUSD = float64(e.PromptTokens) * model.Pricing.InputTokenUSD
+
+	choices := int64(1)
+	if params.N.Valid() && params.N.Value > 1 {
+		choices = params.N.Value
+	}
+	// The output limit of the model, within the rest of its context window
+	perChoice, bounded := model.ContextWindow.MaxOutputTokens, model.ContextWindow.MaxOutputTokens > 0
+	if window := model.ContextWindow.MaxTokens; window > 0 {
+		if remaining := max(window-int64(e.PromptTokens), 0); !bounded || remaining < perChoice {
+			perChoice, bounded = remaining, true
+		}
+	}
+	requested := params.MaxCompletionTokens
+	if !requested.Valid() {
+		requested = params.MaxTokens
+	}
+	if requested.Valid() && (!bounded || requested.Value < perChoice) {
+		perChoice, bounded = requested.Value, true
+	}
+	if !bounded {
+		e.MaxUSD = math.Inf(1)
+		return e, nil
+	}
+	e.MaxCompletionTokens = perChoice * choices
+	e.MaxUSD = e.PromptUSD + float64(e.MaxCompletionTokens)*model.Pricing.OutputTokenUSD
+	return e, nil
+}
+
+type estimateConfig struct {
+	models []ModelInfo
+	count  func(string) int
+}
+
+type EstimateOption func(*estimateConfig)
+
+// WithEstimateModels sets the models catalog, e.g. listed once with
+// [ListModels], instead of listing it for each estimate.
+func WithEstimateModels(models []ModelInfo) EstimateOption {
+	return func(c *estimateConfig) {
+		c.models = models
+	}
+}
+
+// WithEstimateTokenCounter sets the token counter of the prompt, see
+// [EstimateRequestCost].
+func WithEstimateTokenCounter(count func(string) int) EstimateOption {
+	return func(c *estimateConfig) {
+		c.count = count
+	}
+}
+
+// EstimateCost estimates the cost of a chat completion before sending it,
+// e.g. to check a per-request budget or to display the cost in internal
+// tools. The model of a deployment, e.g. "my-agent/#1/production", is the
+// model of its deployed version.
+//
+//	estimate, err := client.EstimateCost(ctx, params, workflowai.WithEstimateModels(models))
+//	if err == nil && !estimate.Within(0.05) {
+//		return fmt.Errorf("the request may cost up to $%.2f", estimate.MaxUSD)
+//	}
+func (c Client) EstimateCost(ctx context.Context, params openai.ChatCompletionNewParams, opts ...EstimateOption) (*CostEstimate, error) {
+	var cfg estimateConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.models == nil {
+		models, err := ListModels(ctx, c.Client)
+		if err != nil {
+			return nil, err
+		}
+		cfg.models = models
+	}
+	requested := params.Model
+	if strings.Contains(requested, "#") {
+		model, err := c.deployedModel(ctx, requested, nil)
+		if err != nil {
+			return nil, fmt.Errorf("workflowai: resolving the model of %s: %w", requested, err)
+		}
+		requested = model
+	}
+	model := catalogModel(cfg.models, requested)
+	if model == nil {
+		return nil, fmt.Errorf("workflowai: model %s is not in the models catalog", requested)
+	}
+	return EstimateRequestCost(params, model, cfg.count)
+}
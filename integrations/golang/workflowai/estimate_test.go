@@ -0,0 +1,116 @@
+package workflowai
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+func TestEstimateRequestCost(t *testing.T) {
+	model := &ModelInfo{
+		ID:            "gpt-4o",
+		Pricing:       ModelPricing{InputTokenUSD: 0.001, OutputTokenUSD: 0.002},
+		ContextWindow: ModelContextWindow{MaxTokens: 1000, MaxOutputTokens: 500},
+	}
+	words := func(s string) int { return len(strings.Fields(s)) }
+	params := openai.ChatCompletionNewParams{
+		Model:    "support/gpt-4o",
+		Messages: []openai.ChatCompletionMessageParamUnion{openai.UserMessage("one two three four")},
+	}
+
+	// The role and the 4 words, the overheads of the message and of the
+	// reply priming
+	estimate, err := EstimateRequestCost(params, model, words)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if estimate.PromptTokens != 11 || math.Abs(estimate.PromptUSD-0.011) > 1e-9 || estimate.MaxCompletionTokens != 500 ||
+		math.Abs(estimate.MaxUSD-1.011) > 1e-9 {
+		t.Errorf("unexpected estimate %v", estimate)
+	}
+
+	params.MaxCompletionTokens = openai.Int(100)
+	params.N = openai.Int(2)
+	if estimate, _ := EstimateRequestCost(params, model, words); estimate.MaxCompletionTokens != 200 || !estimate.Within(0.412) || estimate.Within(0.41) {
+		t.Errorf("unexpected estimate %v", estimate)
+	}
+
+	// Bounded by the rest of the context window
+	model.ContextWindow.MaxTokens = 111
+	params.MaxCompletionTokens, params.N = openai.ChatCompletionNewParams{}.MaxCompletionTokens, openai.ChatCompletionNewParams{}.N
+	if estimate, _ := EstimateRequestCost(params, model, words); estimate.MaxCompletionTokens != 100 {
+		t.Errorf("unexpected estimate %v", estimate)
+	}
+
+	model.ContextWindow = ModelContextWindow{}
+	if estimate, _ := EstimateRequestCost(params, model, words); !math.IsInf(estimate.MaxUSD, 1) || estimate.Within(100) {
+		t.Errorf("expected an unbounded estimate, got %v", estimate)
+	}
+}
+
+func TestClient_EstimateCost(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/models", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]any{"object": "list", "data": []map[string]any{
+			{"id": "gpt-4o-mini", "object": "model", "pricing": map[string]any{"input_token_usd": 0.5, "output_token_usd": 1},
+				"context_window": map[string]any{"max_tokens": 1000, "max_output_tokens": 10}},
+			{"id": "gpt-4o", "object": "model", "pricing": map[string]any{"input_token_usd": 2, "output_token_usd": 4},
+				"context_window": map[string]any{"max_tokens": 1000, "max_output_tokens": 10}},
+		}})
+	})
+	// The deployed versions are served by the tenant route, outside of /v1
+	deployedRequests := 0
+	mux.HandleFunc("GET /_/agents/support/versions/deployed", func(w http.ResponseWriter, r *http.Request) {
+		deployedRequests++
+		writeJSON(w, http.StatusOK, map[string]any{"items": []map[string]any{
+			{
+				"id": "v-1", "schema_id": 1, "iteration": 1, "properties": map[string]any{"model": "gpt-4o-mini"},
+				"deployments": []map[string]any{{"environment": "production", "deployed_at": "2024-05-01T00:00:00Z"}},
+			},
+			{
+				"id": "v-2", "schema_id": 2, "iteration": 1, "properties": map[string]any{"model": "gpt-4o"},
+				"deployments": []map[string]any{{"environment": "production", "deployed_at": "2024-06-01T00:00:00Z"}},
+			},
+		}, "count": 2})
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	client := NewClient(option.WithBaseURL(server.URL+"/v1"), option.WithAPIKey("test"), option.WithMaxRetries(0))
+	ctx := context.Background()
+
+	params := openai.ChatCompletionNewParams{
+		Model:    "support/#1/production",
+		Messages: []openai.ChatCompletionMessageParamUnion{openai.UserMessage("Hi")},
+	}
+	estimate, err := client.EstimateCost(ctx, params, WithEstimateTokenCounter(func(string) int { return 1 }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if estimate.Model != "gpt-4o-mini" || estimate.PromptTokens != 8 || estimate.PromptUSD != 4 || estimate.MaxUSD != 14 {
+		t.Errorf("unexpected estimate %v", estimate)
+	}
+
+	params.Model = "support/#2/production"
+	if estimate, err := client.EstimateCost(ctx, params, WithEstimateTokenCounter(func(string) int { return 1 })); err != nil || estimate.Model != "gpt-4o" {
+		t.Errorf("expected the model deployed for schema 2, got %v, %v", estimate, err)
+	}
+	if deployedRequests != 2 {
+		t.Errorf("expected the deployments to be listed for each estimate, got %d requests", deployedRequests)
+	}
+
+	params.Model = "support/#1/staging"
+	if _, err := client.EstimateCost(ctx, params); err == nil || !strings.Contains(err.Error(), "resolving the model of support/#1/staging: no version of schema 1 is deployed to staging") {
+		t.Errorf("expected an undeployed environment error, got %v", err)
+	}
+
+	params.Model = "support/unknown"
+	if _, err := client.EstimateCost(ctx, params); err == nil || !strings.Contains(err.Error(), "unknown") {
+		t.Errorf("expected an unknown model error, got %v", err)
+	}
+}
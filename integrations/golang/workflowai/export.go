@@ -0,0 +1,154 @@
+package workflowai
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// DatasetRecord is a single line of a run export. The format is suitable for
+// fine-tuning or offline evaluation pipelines.
+type DatasetRecord struct {
+	RunID     string          `json:"run_id"`
+	AgentID   string          `json:"agent_id"`
+	SchemaID  int             `json:"schema_id"`
+	Input     json.RawMessage `json:"input"`
+	Output    json.RawMessage `json:"output"`
+	VersionID string          `json:"version_id"`
+	Model     string          `json:"model,omitempty"`
+	// Score is 1 for a positive review and 0 for a negative one. The user review
+	// takes precedence over the AI review. Unreviewed runs have no score.
+	Score      *float64  `json:"score,omitempty"`
+	UserReview string    `json:"user_review,omitempty"`
+	AIReview   string    `json:"ai_review,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func NewDatasetRecord(run *Run) DatasetRecord {
+	return DatasetRecord{
+		RunID:      run.ID,
+		AgentID:    run.AgentID,
+		SchemaID:   run.SchemaID,
+		Input:      run.Input,
+		Output:     run.Output,
+		VersionID:  run.Version.ID,
+		Model:      run.Version.Properties.Model,
+		Score:      reviewScore(run.UserReview, run.AIReview),
+		UserReview: run.UserReview,
+		AIReview:   run.AIReview,
+		CreatedAt:  run.CreatedAt,
+	}
+}
+
+func reviewScore(reviews ...string) *float64 {
+	for _, review := range reviews {
+		switch review {
+		case "positive":
+			score := 1.0
+			return &score
+		case "negative":
+			score := 0.0
+			return &score
+		}
+	}
+	return nil
+}
+
+// ExportCursor is the position of an export. Exports only include runs
+// created before the export started so that runs created during the export do
+// not shift the pagination.
+type ExportCursor struct {
+	Before time.Time `json:"before"`
+	Offset int       `json:"offset"`
+}
+
+// String encodes the cursor as an opaque string that can be stored and parsed
+// back with [ParseExportCursor].
+func (c ExportCursor) String() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func ParseExportCursor(s string) (ExportCursor, error) {
+	var c ExportCursor
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, fmt.Errorf("workflowai: invalid export cursor: %w", err)
+	}
+	if err := json.Unmarshal(b, &c); err != nil {
+		return c, fmt.Errorf("workflowai: invalid export cursor: %w", err)
+	}
+	return c, nil
+}
+
+type ExportParams struct {
+	FieldQueries []FieldQuery
+	// PageSize is the number of runs fetched per search request. Defaults to 50.
+	PageSize int
+	// Cursor resumes a previous export. Leave empty to start a new export.
+	Cursor string
+	// OnProgress is called after each exported run with the cursor from which
+	// the export can be resumed.
+	OnProgress func(exported int, cursor string)
+}
+
+// Export writes the runs of an agent matching params to w as JSON lines of
+// [DatasetRecord]. On failure, the returned cursor can be passed in
+// [ExportParams.Cursor] to resume the export. The returned cursor is empty once
+// all runs have been exported.
+func (s *RunService) Export(ctx context.Context, agentID string, w io.Writer, params ExportParams) (string, error) {
+	cursor := ExportCursor{Before: time.Now().UTC()}
+	if params.Cursor != "" {
+		var err error
+		if cursor, err = ParseExportCursor(params.Cursor); err != nil {
+			return "", err
+		}
+	}
+	pageSize := params.PageSize
+	if pageSize <= 0 {
+		pageSize = 50
+	}
+
+	queries := append([]FieldQuery{}, params.FieldQueries...)
+	queries = append(queries, FieldQuery{
+		FieldName: "time",
+		Operator:  SearchOperatorIsBefore,
+		Values:    []any{cursor.Before.Format(time.RFC3339Nano)},
+		Type:      "date",
+	})
+
+	encoder := json.NewEncoder(w)
+	exported := 0
+	for {
+		page, err := s.Search(ctx, agentID, RunSearchParams{
+			FieldQueries: queries,
+			Limit:        pageSize,
+			Offset:       cursor.Offset,
+		})
+		if err != nil {
+			return cursor.String(), fmt.Errorf("workflowai: searching runs: %w", err)
+		}
+
+		for _, item := range page.Items {
+			run, err := s.Get(ctx, agentID, item.ID)
+			if err != nil {
+				return cursor.String(), fmt.Errorf("workflowai: fetching run %s: %w", item.ID, err)
+			}
+			if err := encoder.Encode(NewDatasetRecord(run)); err != nil {
+				return cursor.String(), err
+			}
+			cursor.Offset++
+			exported++
+			if params.OnProgress != nil {
+				params.OnProgress(exported, cursor.String())
+			}
+		}
+
+		if len(page.Items) < pageSize {
+			return "", nil
+		}
+	}
+}
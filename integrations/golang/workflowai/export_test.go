@@ -0,0 +1,115 @@
+package workflowai
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openai/openai-go/option"
+)
+
+func newRunsServer(t *testing.T, runCount int, failRun string) *httptest.Server {
+	t.Helper()
+	failed := false
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/_/agents/my-agent/runs/search", func(w http.ResponseWriter, r *http.Request) {
+		var params RunSearchParams
+		if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+			t.Fatal(err)
+		}
+		if len(params.FieldQueries) != 2 || params.FieldQueries[1].FieldName != "time" {
+			t.Errorf("expected the status and time queries, got %+v", params.FieldQueries)
+		}
+		page := Page[RunItem]{Items: []RunItem{}}
+		for i := params.Offset; i < runCount && i < params.Offset+params.Limit; i++ {
+			page.Items = append(page.Items, RunItem{RunBase: RunBase{ID: fmt.Sprintf("run-%d", i)}})
+		}
+		writeJSON(w, http.StatusOK, page)
+	})
+	mux.HandleFunc("GET /v1/_/agents/my-agent/runs/{run_id}", func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("run_id")
+		if id == failRun && !failed {
+			failed = true
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": map[string]any{"message": "boom"}})
+			return
+		}
+		writeJSON(w, http.StatusOK, Run{
+			RunBase: RunBase{ID: id, AgentID: "my-agent", UserReview: "positive", CreatedAt: time.Unix(0, 0)},
+			Input:   json.RawMessage(`{"q":"` + id + `"}`),
+			Output:  json.RawMessage(`{"a":1}`),
+		})
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func exportedIDs(t *testing.T, data string) []string {
+	t.Helper()
+	var ids []string
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	for scanner.Scan() {
+		var record DatasetRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatal(err)
+		}
+		if record.Score == nil || *record.Score != 1 {
+			t.Errorf("expected a score of 1 for %s", record.RunID)
+		}
+		ids = append(ids, record.RunID)
+	}
+	return ids
+}
+
+func TestRunService_Export(t *testing.T) {
+	server := newRunsServer(t, 5, "run-3")
+	client := NewClient(option.WithBaseURL(server.URL+"/v1"), option.WithAPIKey("test"), option.WithMaxRetries(0))
+	ctx := context.Background()
+	params := ExportParams{
+		FieldQueries: []FieldQuery{{FieldName: "status", Operator: SearchOperatorIs, Values: []any{"success"}}},
+		PageSize:     2,
+	}
+
+	var out bytes.Buffer
+	cursor, err := client.Runs.Export(ctx, "my-agent", &out, params)
+	if err == nil {
+		t.Fatal("expected the export to fail on run-3")
+	}
+	if ids := exportedIDs(t, out.String()); len(ids) != 3 {
+		t.Fatalf("expected 3 runs before the failure, got %v (%v)", ids, err)
+	}
+
+	params.Cursor = cursor
+	cursor, err = client.Runs.Export(ctx, "my-agent", &out, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cursor != "" {
+		t.Fatalf("expected an empty cursor after a complete export, got %s", cursor)
+	}
+	ids := exportedIDs(t, out.String())
+	if strings.Join(ids, ",") != "run-0,run-1,run-2,run-3,run-4" {
+		t.Fatalf("unexpected exported runs %v", ids)
+	}
+}
+
+func TestParseExportCursor(t *testing.T) {
+	cursor := ExportCursor{Before: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), Offset: 12}
+	parsed, err := ParseExportCursor(cursor.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !parsed.Before.Equal(cursor.Before) || parsed.Offset != 12 {
+		t.Fatalf("unexpected cursor %+v", parsed)
+	}
+	if _, err := ParseExportCursor("not a cursor"); err == nil {
+		t.Fatal("expected an error")
+	}
+}
@@ -0,0 +1,68 @@
+package workflowai
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+// FeedbackService posts the feedback of end users on runs. The feedback
+// endpoint is authenticated by the feedback token of the run, so tokens can be
+// handed to client side code.
+type FeedbackService struct {
+	client openai.Client
+}
+
+// Feedback outcomes
+const (
+	FeedbackPositive = "positive"
+	FeedbackNegative = "negative"
+)
+
+type FeedbackParams struct {
+	// Token is the feedback token of the run, see [CompletionFeedbackToken]
+	Token   string `json:"feedback_token"`
+	Outcome string `json:"outcome"`
+	Comment string `json:"comment,omitempty"`
+	// UserID identifies the user giving the feedback. A new feedback of the
+	// same user on the same run replaces the previous one.
+	UserID string `json:"user_id,omitempty"`
+}
+
+// Create posts a feedback.
+func (s *FeedbackService) Create(ctx context.Context, params FeedbackParams, opts ...option.RequestOption) error {
+	// Decoding the response, even if unused, closes its body
+	var res json.RawMessage
+	return s.client.Execute(ctx, http.MethodPost, "feedback", params, &res, opts...)
+}
+
+// CompletionFeedbackToken returns the feedback token of a completion, empty
+// for completions that do not come from WorkflowAI.
+func CompletionFeedbackToken(completion *openai.ChatCompletion) string {
+	if len(completion.Choices) == 0 {
+		return ""
+	}
+	return extraString(completion.Choices[0].JSON.ExtraFields, "feedback_token")
+}
+
+// ChunkFeedbackToken returns the feedback token of a streamed chunk, which
+// WorkflowAI sets on the final chunk.
+func ChunkFeedbackToken(chunk openai.ChatCompletionChunk) string {
+	if len(chunk.Choices) == 0 {
+		return ""
+	}
+	return extraString(chunk.Choices[0].JSON.ExtraFields, "feedback_token")
+}
+
+func extraString[F interface{ Raw() string }](fields map[string]F, key string) string {
+	f, ok := fields[key]
+	if !ok {
+		return ""
+	}
+	var s string
+	json.Unmarshal([]byte(f.Raw()), &s)
+	return s
+}
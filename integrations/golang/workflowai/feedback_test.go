@@ -0,0 +1,49 @@
+package workflowai
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+func TestFeedbackService(t *testing.T) {
+	var got FeedbackParams
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/feedback", func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+		writeJSON(w, http.StatusOK, map[string]any{"id": "fb-1", "outcome": got.Outcome})
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := NewClient(option.WithBaseURL(server.URL+"/v1"), option.WithAPIKey("test"))
+	params := FeedbackParams{Token: "token-1", Outcome: FeedbackNegative, Comment: "wrong answer", UserID: "U123"}
+	if err := client.Feedback.Create(context.Background(), params); err != nil {
+		t.Fatal(err)
+	}
+	if got != params {
+		t.Errorf("got %+v, want %+v", got, params)
+	}
+}
+
+func TestFeedbackTokens(t *testing.T) {
+	var completion openai.ChatCompletion
+	json.Unmarshal([]byte(`{"id":"a/1","choices":[{"index":0,"message":{"role":"assistant","content":"hi"},"feedback_token":"token-1"}]}`), &completion)
+	if got := CompletionFeedbackToken(&completion); got != "token-1" {
+		t.Errorf("CompletionFeedbackToken = %q", got)
+	}
+	if got := CompletionFeedbackToken(&openai.ChatCompletion{}); got != "" {
+		t.Errorf("CompletionFeedbackToken of an empty completion = %q", got)
+	}
+
+	var chunk openai.ChatCompletionChunk
+	json.Unmarshal([]byte(`{"id":"a/1","choices":[{"index":0,"delta":{},"finish_reason":"stop","feedback_token":"token-2"}]}`), &chunk)
+	if got := ChunkFeedbackToken(chunk); got != "token-2" {
+		t.Errorf("ChunkFeedbackToken = %q", got)
+	}
+}
@@ -0,0 +1,91 @@
+package workflowai
+
+import (
+	"context"
+	"log"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+	"github.com/openai/openai-go/packages/ssestream"
+)
+
+// RequestConfig is the part of a request resolved at call time by a
+// [ConfigResolver]. Zero values keep the value of the request.
+type RequestConfig struct {
+	// Model can be a model or a deployment, e.g. "my-agent/#1/production"
+	Model       string
+	Temperature *float64
+}
+
+// ConfigResolver resolves the configuration of a request, typically from a
+// feature flag system. See the openfeatureconfig package for an OpenFeature
+// implementation.
+type ConfigResolver interface {
+	ResolveConfig(ctx context.Context, params openai.ChatCompletionNewParams) (RequestConfig, error)
+}
+
+// ConfigResolverFunc adapts a function to the ConfigResolver interface.
+type ConfigResolverFunc func(ctx context.Context, params openai.ChatCompletionNewParams) (RequestConfig, error)
+
+func (f ConfigResolverFunc) ResolveConfig(ctx context.Context, params openai.ChatCompletionNewParams) (RequestConfig, error) {
+	return f(ctx, params)
+}
+
+// FlagRouter resolves the model and temperature of every request with a
+// [ConfigResolver] before sending it, so that models can be changed without
+// redeploying. When the resolver fails, the request is sent unchanged.
+type FlagRouter struct {
+	completions ChatService
+	resolver    ConfigResolver
+	onError     func(error)
+}
+
+var _ ChatService = (*FlagRouter)(nil)
+
+type FlagRouterOption func(*FlagRouter)
+
+// WithFlagErrorHandler is called when the resolver fails. By default errors
+// are logged with the standard logger.
+func WithFlagErrorHandler(fn func(error)) FlagRouterOption {
+	return func(r *FlagRouter) {
+		r.onError = fn
+	}
+}
+
+func NewFlagRouter(completions ChatService, resolver ConfigResolver, opts ...FlagRouterOption) *FlagRouter {
+	r := &FlagRouter{
+		completions: completions,
+		resolver:    resolver,
+		onError: func(err error) {
+			log.Printf("workflowai: failed to resolve the request config: %v", ScrubError(err))
+		},
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+func (r *FlagRouter) resolve(ctx context.Context, body *openai.ChatCompletionNewParams) {
+	config, err := r.resolver.ResolveConfig(ctx, *body)
+	if err != nil {
+		r.onError(err)
+		return
+	}
+	if config.Model != "" {
+		body.Model = config.Model
+	}
+	if config.Temperature != nil {
+		body.Temperature = openai.Float(*config.Temperature)
+	}
+}
+
+func (r *FlagRouter) New(ctx context.Context, body openai.ChatCompletionNewParams, opts ...option.RequestOption) (*openai.ChatCompletion, error) {
+	r.resolve(ctx, &body)
+	return r.completions.New(ctx, body, opts...)
+}
+
+func (r *FlagRouter) NewStreaming(ctx context.Context, body openai.ChatCompletionNewParams, opts ...option.RequestOption) *ssestream.Stream[openai.ChatCompletionChunk] {
+	r.resolve(ctx, &body)
+	return r.completions.NewStreaming(ctx, body, opts...)
+}
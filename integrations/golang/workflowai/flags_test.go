@@ -0,0 +1,54 @@
+package workflowai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+func TestFlagRouter(t *testing.T) {
+	var received struct {
+		Model       string   `json:"model"`
+		Temperature *float64 `json:"temperature"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&received)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, testCompletion)
+	}))
+	defer server.Close()
+	client := openai.NewClient(option.WithBaseURL(server.URL+"/v1/"), option.WithAPIKey("key"))
+	params := openai.ChatCompletionNewParams{
+		Model:    "my-agent/gpt-4o",
+		Messages: []openai.ChatCompletionMessageParamUnion{openai.UserMessage("Hello")},
+	}
+
+	temperature := 0.3
+	router := NewFlagRouter(&client.Chat.Completions, ConfigResolverFunc(func(context.Context, openai.ChatCompletionNewParams) (RequestConfig, error) {
+		return RequestConfig{Model: "my-agent/#2/production", Temperature: &temperature}, nil
+	}))
+	if _, err := router.New(context.Background(), params); err != nil {
+		t.Fatal(err)
+	}
+	if received.Model != "my-agent/#2/production" || received.Temperature == nil || *received.Temperature != 0.3 {
+		t.Errorf("the config was not applied: %+v", received)
+	}
+
+	var resolveErr error
+	router = NewFlagRouter(&client.Chat.Completions, ConfigResolverFunc(func(context.Context, openai.ChatCompletionNewParams) (RequestConfig, error) {
+		return RequestConfig{}, errors.New("flags unavailable")
+	}), WithFlagErrorHandler(func(err error) { resolveErr = err }))
+	if _, err := router.New(context.Background(), params); err != nil {
+		t.Fatal(err)
+	}
+	if resolveErr == nil || received.Model != "my-agent/gpt-4o" {
+		t.Errorf("expected the request to be sent unchanged, got %+v (error %v)", received, resolveErr)
+	}
+}
@@ -0,0 +1,178 @@
+// Package grpctools exposes the unary methods of gRPC services as tools of
+// workflowai agents. The schema of the arguments of a tool is derived from
+// the descriptor of the request message, and its call sends the request
+// over a gRPC connection and returns the response in its protojson encoding.
+//
+//	conn, err := grpc.NewClient("inventory:443", grpc.WithTransportCredentials(creds))
+//	tools, err := grpctools.ServiceTools(conn, inventorypb.File_inventory_proto.Services().ByName("Inventory"),
+//		grpctools.WithMethods("GetStock", "ListWarehouses"))
+//	agent.Tools = tools
+//
+// Only the methods listed with [WithMethods] are exposed when it is set, so
+// that adding a method to a service does not expose it to the model.
+package grpctools
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+
+	"github.com/workflowai/workflowai/go/examples/workflowai"
+)
+
+// DefaultTimeout bounds the calls of the methods.
+const DefaultTimeout = 30 * time.Second
+
+type config struct {
+	methods     []string
+	timeout     time.Duration
+	metadata    metadata.MD
+	callOptions []grpc.CallOption
+}
+
+type Option func(*config)
+
+// WithMethods restricts the tools of [ServiceTools] to methods, by name.
+func WithMethods(names ...string) Option {
+	return func(c *config) {
+		c.methods = append(c.methods, names...)
+	}
+}
+
+// WithTimeout bounds each call, [DefaultTimeout] by default. A value <= 0
+// only keeps the deadline of the context.
+func WithTimeout(d time.Duration) Option {
+	return func(c *config) {
+		c.timeout = d
+	}
+}
+
+// WithMetadata adds outgoing metadata to each call, e.g. credentials, which
+// the model never sees.
+func WithMetadata(kv ...string) Option {
+	return func(c *config) {
+		c.metadata = metadata.Join(c.metadata, metadata.Pairs(kv...))
+	}
+}
+
+// WithCallOptions adds options to each call, e.g. grpc.PerRPCCredentials.
+func WithCallOptions(opts ...grpc.CallOption) Option {
+	return func(c *config) {
+		c.callOptions = append(c.callOptions, opts...)
+	}
+}
+
+func newConfig(opts []Option) *config {
+	c := &config{timeout: DefaultTimeout, metadata: metadata.MD{}}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// NewTool returns the tool calling a unary method. The tool is named after
+// the service and the method, e.g. "Inventory_GetStock", with a hash suffix
+// when the name is cut to 64 characters, and described by the comment of the
+// method when the descriptor has its source info.
+func NewTool(conn grpc.ClientConnInterface, method protoreflect.MethodDescriptor, opts ...Option) (workflowai.Tool, error) {
+	return newTool(conn, method, newConfig(opts))
+}
+
+// toolName returns the name of the tool of a method, cut to the 64
+// characters allowed in tool names. Cut names keep their end and are suffixed
+// with a hash of the full name of the method, so that two methods sharing the
+// end of their names do not share a tool.
+func toolName(method protoreflect.MethodDescriptor) string {
+	service := method.Parent().(protoreflect.ServiceDescriptor)
+	name := string(service.Name()) + "_" + string(method.Name())
+	if len(name) <= 64 {
+		return name
+	}
+	sum := sha256.Sum256([]byte(method.FullName()))
+	suffix := "_" + hex.EncodeToString(sum[:4])
+	return name[len(name)-64+len(suffix):] + suffix
+}
+
+func newTool(conn grpc.ClientConnInterface, method protoreflect.MethodDescriptor, cfg *config) (workflowai.Tool, error) {
+	if method.IsStreamingClient() || method.IsStreamingServer() {
+		return workflowai.Tool{}, fmt.Errorf("grpctools: %s is a streaming method", method.FullName())
+	}
+	service := method.Parent().(protoreflect.ServiceDescriptor)
+	name := toolName(method)
+	description := comment(method)
+	if description == "" {
+		description = fmt.Sprintf("Calls the %s method of the %s service.", method.Name(), service.FullName())
+	}
+	fullMethod := "/" + string(service.FullName()) + "/" + string(method.Name())
+	return workflowai.Tool{
+		Name:        name,
+		Description: description,
+		Parameters:  Schema(method.Input()),
+		Call: func(ctx context.Context, arguments string) (string, error) {
+			in := dynamicpb.NewMessage(method.Input())
+			if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal([]byte(arguments), in); err != nil {
+				return "", fmt.Errorf("invalid arguments: %w", err)
+			}
+			if cfg.timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, cfg.timeout)
+				defer cancel()
+			}
+			if len(cfg.metadata) > 0 {
+				ctx = metadata.NewOutgoingContext(ctx, metadata.Join(outgoing(ctx), cfg.metadata))
+			}
+			out := dynamicpb.NewMessage(method.Output())
+			if err := conn.Invoke(ctx, fullMethod, in, out, cfg.callOptions...); err != nil {
+				return "", err
+			}
+			data, err := protojson.Marshal(out)
+			return string(data), err
+		},
+	}, nil
+}
+
+func outgoing(ctx context.Context) metadata.MD {
+	md, _ := metadata.FromOutgoingContext(ctx)
+	return md
+}
+
+// ServiceTools returns the tools of the unary methods of a service, or of
+// the methods of [WithMethods]. Streaming methods are skipped, and listing
+// one with WithMethods is an error.
+func ServiceTools(conn grpc.ClientConnInterface, service protoreflect.ServiceDescriptor, opts ...Option) ([]workflowai.Tool, error) {
+	cfg := newConfig(opts)
+	var methods []protoreflect.MethodDescriptor
+	if len(cfg.methods) > 0 {
+		for _, name := range cfg.methods {
+			method := service.Methods().ByName(protoreflect.Name(name))
+			if method == nil {
+				return nil, fmt.Errorf("grpctools: %s has no method %s", service.FullName(), name)
+			}
+			methods = append(methods, method)
+		}
+	} else {
+		for i := 0; i < service.Methods().Len(); i++ {
+			method := service.Methods().Get(i)
+			if !method.IsStreamingClient() && !method.IsStreamingServer() {
+				methods = append(methods, method)
+			}
+		}
+	}
+	tools := make([]workflowai.Tool, 0, len(methods))
+	for _, method := range methods {
+		tool, err := newTool(conn, method, cfg)
+		if err != nil {
+			return nil, err
+		}
+		tools = append(tools, tool)
+	}
+	return tools, nil
+}
@@ -0,0 +1,185 @@
+package grpctools
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// inventoryFile declares:
+//
+//	service Inventory {
+//	  // Returns the stock of a product.
+//	  rpc GetStock(StockRequest) returns (Stock);
+//	  rpc WatchStock(StockRequest) returns (stream Stock);
+//	}
+func inventoryFile(t *testing.T) protoreflect.FileDescriptor {
+	t.Helper()
+	field := func(name string, number int32, typ descriptorpb.FieldDescriptorProto_Type, typeName string, label descriptorpb.FieldDescriptorProto_Label) *descriptorpb.FieldDescriptorProto {
+		f := &descriptorpb.FieldDescriptorProto{Name: proto.String(name), Number: proto.Int32(number), Type: typ.Enum(), Label: label.Enum()}
+		if typeName != "" {
+			f.TypeName = proto.String(typeName)
+		}
+		return f
+	}
+	optional, repeated := descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL, descriptorpb.FieldDescriptorProto_LABEL_REPEATED
+	file := &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("inventory.proto"),
+		Package: proto.String("shop.v1"),
+		Syntax:  proto.String("proto3"),
+		EnumType: []*descriptorpb.EnumDescriptorProto{{
+			Name: proto.String("Unit"),
+			Value: []*descriptorpb.EnumValueDescriptorProto{
+				{Name: proto.String("UNIT_UNSPECIFIED"), Number: proto.Int32(0)},
+				{Name: proto.String("UNIT_KG"), Number: proto.Int32(1)},
+			},
+		}},
+		MessageType: []*descriptorpb.DescriptorProto{
+			{Name: proto.String("StockRequest"), Field: []*descriptorpb.FieldDescriptorProto{
+				field("product_id", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING, "", optional),
+				field("warehouses", 2, descriptorpb.FieldDescriptorProto_TYPE_STRING, "", repeated),
+			}},
+			{Name: proto.String("Stock"), Field: []*descriptorpb.FieldDescriptorProto{
+				field("quantity", 1, descriptorpb.FieldDescriptorProto_TYPE_INT64, "", optional),
+				field("unit", 2, descriptorpb.FieldDescriptorProto_TYPE_ENUM, ".shop.v1.Unit", optional),
+				field("replaced_by", 3, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, ".shop.v1.Stock", optional),
+			}},
+		},
+		Service: []*descriptorpb.ServiceDescriptorProto{{
+			Name: proto.String("Inventory"),
+			Method: []*descriptorpb.MethodDescriptorProto{
+				{Name: proto.String("GetStock"), InputType: proto.String(".shop.v1.StockRequest"), OutputType: proto.String(".shop.v1.Stock")},
+				{Name: proto.String("WatchStock"), InputType: proto.String(".shop.v1.StockRequest"), OutputType: proto.String(".shop.v1.Stock"), ServerStreaming: proto.Bool(true)},
+			},
+		}},
+		SourceCodeInfo: &descriptorpb.SourceCodeInfo{Location: []*descriptorpb.SourceCodeInfo_Location{{
+			// service 0, method 0
+			Path:            []int32{6, 0, 2, 0},
+			Span:            []int32{10, 2, 40},
+			LeadingComments: proto.String(" Returns the stock of a product.\n"),
+		}}},
+	}
+	fd, err := protodesc.NewFile(file, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fd
+}
+
+func TestServiceTools(t *testing.T) {
+	fd := inventoryFile(t)
+	service := fd.Services().ByName("Inventory")
+	method := service.Methods().ByName("GetStock")
+
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	server.RegisterService(&grpc.ServiceDesc{
+		ServiceName: "shop.v1.Inventory",
+		HandlerType: (*any)(nil),
+		Methods: []grpc.MethodDesc{{
+			MethodName: "GetStock",
+			Handler: func(_ any, ctx context.Context, dec func(any) error, _ grpc.UnaryServerInterceptor) (any, error) {
+				in := dynamicpb.NewMessage(method.Input())
+				if err := dec(in); err != nil {
+					return nil, err
+				}
+				md, _ := metadata.FromIncomingContext(ctx)
+				out := dynamicpb.NewMessage(method.Output())
+				text := `{"quantity": "42", "unit": "UNIT_KG"}`
+				if in.Get(method.Input().Fields().ByName("product_id")).String() != "p-1" || len(md.Get("authorization")) == 0 {
+					text = `{}`
+				}
+				return out, protojson.Unmarshal([]byte(text), out)
+			},
+		}},
+	}, struct{}{})
+	go server.Serve(listener)
+	defer server.Stop()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	tools, err := ServiceTools(conn, service, WithMetadata("authorization", "Bearer secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The streaming method is skipped
+	if len(tools) != 1 || tools[0].Name != "Inventory_GetStock" || tools[0].Description != "Returns the stock of a product." {
+		t.Fatalf("unexpected tools %+v", tools)
+	}
+	if want := `{"properties":{"productId":{"type":"string"},"warehouses":{"items":{"type":"string"},"type":"array"}},"type":"object"}`; string(tools[0].Parameters) != want {
+		t.Errorf("unexpected parameters %s", tools[0].Parameters)
+	}
+	result, err := tools[0].Call(context.Background(), `{"productId": "p-1", "warehouses": ["paris"]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.ReplaceAll(result, " ", "") != `{"quantity":"42","unit":"UNIT_KG"}` {
+		t.Errorf("unexpected result %s", result)
+	}
+	if _, err := tools[0].Call(context.Background(), `{"productId": 1}`); err == nil {
+		t.Error("expected an invalid arguments error")
+	}
+
+	if _, err := ServiceTools(conn, service, WithMethods("WatchStock")); err == nil {
+		t.Error("expected a streaming method error")
+	}
+}
+
+func TestSchema(t *testing.T) {
+	stock := inventoryFile(t).Messages().ByName("Stock")
+	want := `{"$defs":{"shop_v1_Stock":{"properties":{"quantity":{"type":["integer","string"]},"replacedBy":{"$ref":"#/$defs/shop_v1_Stock"},"unit":{"enum":["UNIT_UNSPECIFIED","UNIT_KG"],"type":"string"}},"type":"object"}},` +
+		`"properties":{"quantity":{"type":["integer","string"]},"replacedBy":{"$ref":"#/$defs/shop_v1_Stock"},"unit":{"enum":["UNIT_UNSPECIFIED","UNIT_KG"],"type":"string"}},"type":"object"}`
+	if got := string(Schema(stock)); got != want {
+		t.Errorf("got %s", got)
+	}
+}
+
+func TestToolName(t *testing.T) {
+	long := strings.Repeat("Warehouse", 7)
+	var services []*descriptorpb.ServiceDescriptorProto
+	for _, prefix := range []string{"Eu", "Us"} {
+		services = append(services, &descriptorpb.ServiceDescriptorProto{
+			Name:   proto.String(prefix + long),
+			Method: []*descriptorpb.MethodDescriptorProto{{Name: proto.String("GetStock"), InputType: proto.String(".shop.v1.Empty"), OutputType: proto.String(".shop.v1.Empty")}},
+		})
+	}
+	fd, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:        proto.String("warehouses.proto"),
+		Package:     proto.String("shop.v1"),
+		Syntax:      proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{{Name: proto.String("Empty")}},
+		Service:     services,
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	eu := toolName(fd.Services().Get(0).Methods().Get(0))
+	us := toolName(fd.Services().Get(1).Methods().Get(0))
+	if len(eu) != 64 || len(us) != 64 || eu == us {
+		t.Errorf("expected distinct names of 64 characters, got %q and %q", eu, us)
+	}
+	if !strings.Contains(eu, "_GetStock_") {
+		t.Errorf("expected the end of the name to be kept, got %q", eu)
+	}
+	if got := toolName(inventoryFile(t).Services().Get(0).Methods().Get(0)); got != "Inventory_GetStock" {
+		t.Errorf("expected short names to be kept, got %q", got)
+	}
+}
@@ -0,0 +1,126 @@
+package grpctools
+
+import (
+	"encoding/json"
+	"strings"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// wellKnownSchemas are the JSON schemas of the well-known types, which
+// protojson encodes as scalars.
+var wellKnownSchemas = map[protoreflect.FullName]map[string]any{
+	"google.protobuf.Timestamp":   {"type": "string", "format": "date-time"},
+	"google.protobuf.Duration":    {"type": "string", "description": `a duration in seconds with an "s" suffix, e.g. "1.5s"`},
+	"google.protobuf.FieldMask":   {"type": "string", "description": "comma separated field paths"},
+	"google.protobuf.Struct":      {"type": "object"},
+	"google.protobuf.Value":       {},
+	"google.protobuf.ListValue":   {"type": "array"},
+	"google.protobuf.Empty":       {"type": "object", "properties": map[string]any{}},
+	"google.protobuf.StringValue": {"type": "string"},
+	"google.protobuf.BytesValue":  {"type": "string", "contentEncoding": "base64"},
+	"google.protobuf.BoolValue":   {"type": "boolean"},
+	"google.protobuf.DoubleValue": {"type": "number"},
+	"google.protobuf.FloatValue":  {"type": "number"},
+	"google.protobuf.Int32Value":  {"type": "integer"},
+	"google.protobuf.UInt32Value": {"type": "integer", "minimum": 0},
+	"google.protobuf.Int64Value":  {"type": []string{"integer", "string"}},
+	"google.protobuf.UInt64Value": {"type": []string{"integer", "string"}},
+}
+
+// Schema returns the JSON schema of the protojson encoding of a message.
+// Fields are named by their JSON names, enums are their value names and
+// nested messages are defined in "$defs", which supports recursive messages.
+// The comments of the fields in the descriptor, when available, are their
+// descriptions.
+func Schema(desc protoreflect.MessageDescriptor) json.RawMessage {
+	g := &schemaGenerator{defs: map[string]any{}}
+	schema := g.message(desc)
+	if len(g.defs) > 0 {
+		schema["$defs"] = g.defs
+	}
+	data, _ := json.Marshal(schema)
+	return data
+}
+
+type schemaGenerator struct {
+	defs map[string]any
+}
+
+func (g *schemaGenerator) message(desc protoreflect.MessageDescriptor) map[string]any {
+	properties := map[string]any{}
+	fields := desc.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		field := fields.Get(i)
+		schema := g.field(field)
+		if comment := comment(field); comment != "" {
+			schema["description"] = comment
+		}
+		properties[field.JSONName()] = schema
+	}
+	return map[string]any{"type": "object", "properties": properties}
+}
+
+func (g *schemaGenerator) field(field protoreflect.FieldDescriptor) map[string]any {
+	switch {
+	case field.IsMap():
+		return map[string]any{"type": "object", "additionalProperties": g.value(field.MapValue())}
+	case field.IsList():
+		return map[string]any{"type": "array", "items": g.value(field)}
+	default:
+		return g.value(field)
+	}
+}
+
+// value returns the schema of a single value of a field.
+func (g *schemaGenerator) value(field protoreflect.FieldDescriptor) map[string]any {
+	switch field.Kind() {
+	case protoreflect.BoolKind:
+		return map[string]any{"type": "boolean"}
+	case protoreflect.StringKind:
+		return map[string]any{"type": "string"}
+	case protoreflect.BytesKind:
+		return map[string]any{"type": "string", "contentEncoding": "base64"}
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		return map[string]any{"type": "number"}
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		return map[string]any{"type": "integer"}
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return map[string]any{"type": "integer", "minimum": 0}
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind, protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		// protojson encodes 64-bit integers as strings and accepts both
+		return map[string]any{"type": []string{"integer", "string"}}
+	case protoreflect.EnumKind:
+		values := field.Enum().Values()
+		names := make([]string, values.Len())
+		for i := range names {
+			names[i] = string(values.Get(i).Name())
+		}
+		return map[string]any{"type": "string", "enum": names}
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		msg := field.Message()
+		if schema, ok := wellKnownSchemas[msg.FullName()]; ok {
+			copied := make(map[string]any, len(schema))
+			for k, v := range schema {
+				copied[k] = v
+			}
+			return copied
+		}
+		name := strings.ReplaceAll(string(msg.FullName()), ".", "_")
+		if _, ok := g.defs[name]; !ok {
+			// Reserved before generating for recursive messages to terminate
+			g.defs[name] = nil
+			g.defs[name] = g.message(msg)
+		}
+		return map[string]any{"$ref": "#/$defs/" + name}
+	default:
+		return map[string]any{}
+	}
+}
+
+// comment returns the leading comment of a descriptor, when the descriptor
+// was built with its source info.
+func comment(desc protoreflect.Descriptor) string {
+	loc := desc.ParentFile().SourceLocations().ByDescriptor(desc)
+	return strings.TrimSpace(loc.LeadingComments)
+}
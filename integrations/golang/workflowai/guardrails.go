@@ -0,0 +1,488 @@
+package workflowai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+// GuardrailAction is what happens when an output violates a [Guardrail].
+type GuardrailAction string
+
+// The actions, from the most to the least severe.
+const (
+	// GuardrailReject fails the completion with a [*GuardrailError]
+	GuardrailReject GuardrailAction = "reject"
+	// GuardrailRetry sends the output back to the model with the violations,
+	// then rejects it when retries are exhausted
+	GuardrailRetry GuardrailAction = "retry"
+	// GuardrailRedact replaces the matches of the banned patterns with
+	// "[redacted]", truncates the values over the maximum length and removes
+	// the forbidden fields. The other violations are rejected.
+	GuardrailRedact GuardrailAction = "redact"
+	// GuardrailFlag lets the output through and reports the violation to the
+	// flag handler
+	GuardrailFlag GuardrailAction = "flag"
+)
+
+var guardrailSeverity = map[GuardrailAction]int{GuardrailFlag: 1, GuardrailRedact: 2, GuardrailRetry: 3, GuardrailReject: 4}
+
+// Guardrail is a rule on the outputs of the completions. Zero values do not
+// check anything, so a rule combines the checks it sets:
+//
+//	{"name": "no-emails", "field": "reply", "banned_patterns": ["[\\w.]+@[\\w.]+"], "action": "redact"}
+type Guardrail struct {
+	Name string `json:"name"`
+	// Field is the path of the checked values in a JSON output, e.g.
+	// "reply" or "items.*.price", where "*" matches every element of an
+	// array or every value of an object. When empty, the length and the
+	// patterns are checked on the text of the output, and the other checks on
+	// its JSON value.
+	Field string `json:"field,omitempty"`
+	// MaxLength is the maximum number of characters of the strings
+	MaxLength int `json:"max_length,omitempty"`
+	// Required and Forbidden are fields of the objects
+	Required  []string `json:"required,omitempty"`
+	Forbidden []string `json:"forbidden,omitempty"`
+	// BannedPatterns are regular expressions the strings must not match
+	BannedPatterns []string `json:"banned_patterns,omitempty"`
+	// Min and Max bound the numbers
+	Min *float64 `json:"min,omitempty"`
+	Max *float64 `json:"max,omitempty"`
+	// Predicate is the name of a predicate registered with
+	// [WithGuardrailPredicate], checking the values
+	Predicate string `json:"predicate,omitempty"`
+	// Check is a predicate set in code, returning an error for the invalid
+	// values, decoded as with encoding/json into an any
+	Check func(value any) error `json:"-"`
+	// Action defaults to GuardrailReject
+	Action GuardrailAction `json:"action,omitempty"`
+}
+
+// GuardrailViolation is a violation of a [Guardrail] by an output.
+type GuardrailViolation struct {
+	Rule   string
+	Action GuardrailAction
+	// Path is the path of the value in the output, e.g. "$.items[0].price"
+	Path    string
+	Message string
+}
+
+func (v GuardrailViolation) String() string {
+	return fmt.Sprintf("%s: %s (%s)", v.Path, v.Message, v.Rule)
+}
+
+// GuardrailError is returned when an output is rejected by the guardrails.
+type GuardrailError struct {
+	Violations []GuardrailViolation
+	// Attempts is the number of completions whose output was rejected
+	Attempts int
+}
+
+func (e *GuardrailError) Error() string {
+	msgs := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		msgs[i] = v.String()
+	}
+	return "workflowai: output rejected by the guardrails: " + strings.Join(msgs, "; ")
+}
+
+// GuardrailResult is the evaluation of an output by [Guardrails.Evaluate].
+type GuardrailResult struct {
+	// Content is the output with the redactions
+	Content    string
+	Violations []GuardrailViolation
+}
+
+// Action returns the most severe action of the violations, empty when there
+// are none.
+func (r *GuardrailResult) Action() GuardrailAction {
+	var action GuardrailAction
+	for _, v := range r.Violations {
+		if guardrailSeverity[v.Action] > guardrailSeverity[action] {
+			action = v.Action
+		}
+	}
+	return action
+}
+
+// Err returns a [*GuardrailError] when the output is rejected or must be
+// retried.
+func (r *GuardrailResult) Err() error {
+	if action := r.Action(); action == GuardrailReject || action == GuardrailRetry {
+		return &GuardrailError{Violations: r.Violations, Attempts: 1}
+	}
+	return nil
+}
+
+type compiledGuardrail struct {
+	Guardrail
+	patterns []*regexp.Regexp
+	check    func(any) error
+}
+
+// Guardrails evaluates outputs with a list of rules, typically loaded from a
+// configuration file with [LoadGuardrails].
+type Guardrails struct {
+	rules []compiledGuardrail
+}
+
+type GuardrailsOption func(*guardrailsConfig)
+
+type guardrailsConfig struct {
+	predicates map[string]func(any) error
+}
+
+// WithGuardrailPredicate registers a predicate referenced by the Predicate of
+// the rules, e.g. a check of the product IDs against the catalog.
+func WithGuardrailPredicate(name string, check func(value any) error) GuardrailsOption {
+	return func(c *guardrailsConfig) {
+		c.predicates[name] = check
+	}
+}
+
+// NewGuardrails returns the guardrails of rules, or an error when a pattern,
+// an action or a predicate is invalid.
+func NewGuardrails(rules []Guardrail, opts ...GuardrailsOption) (*Guardrails, error) {
+	config := guardrailsConfig{predicates: map[string]func(any) error{}}
+	for _, opt := range opts {
+		opt(&config)
+	}
+	g := &Guardrails{}
+	for i, rule := range rules {
+		if rule.Name == "" {
+			rule.Name = "guardrail " + strconv.Itoa(i+1)
+		}
+		if rule.Action == "" {
+			rule.Action = GuardrailReject
+		}
+		if guardrailSeverity[rule.Action] == 0 {
+			return nil, fmt.Errorf("workflowai: guardrail %s: unknown action %q", rule.Name, rule.Action)
+		}
+		compiled := compiledGuardrail{Guardrail: rule, check: rule.Check}
+		for _, pattern := range rule.BannedPatterns {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("workflowai: guardrail %s: %w", rule.Name, err)
+			}
+			compiled.patterns = append(compiled.patterns, re)
+		}
+		if rule.Predicate != "" {
+			if compiled.check = config.predicates[rule.Predicate]; compiled.check == nil {
+				return nil, fmt.Errorf("workflowai: guardrail %s: unknown predicate %q", rule.Name, rule.Predicate)
+			}
+		}
+		g.rules = append(g.rules, compiled)
+	}
+	return g, nil
+}
+
+// LoadGuardrails reads rules from a JSON file, either an array of rules or an
+// object of rules by name:
+//
+//	{"short-replies": {"field": "reply", "max_length": 500, "action": "retry"}}
+func LoadGuardrails(path string, opts ...GuardrailsOption) (*Guardrails, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseGuardrails(data, opts...)
+}
+
+// ParseGuardrails parses rules in the format of [LoadGuardrails].
+func ParseGuardrails(data []byte, opts ...GuardrailsOption) (*Guardrails, error) {
+	var rules []Guardrail
+	if err := json.Unmarshal(data, &rules); err != nil {
+		var byName map[string]Guardrail
+		if err := json.Unmarshal(data, &byName); err != nil {
+			return nil, fmt.Errorf("workflowai: decoding guardrails: %w", err)
+		}
+		for name, rule := range byName {
+			rule.Name = name
+			rules = append(rules, rule)
+		}
+		// Sorted, so that the violations are reported in a stable order
+		slices.SortFunc(rules, func(a, b Guardrail) int { return strings.Compare(a.Name, b.Name) })
+	}
+	return NewGuardrails(rules, opts...)
+}
+
+// guardrailValue is a value of an output matched by the field of a rule.
+type guardrailValue struct {
+	path  string
+	value any
+	set   func(any)
+}
+
+// Evaluate checks an output against the rules, in order, and applies the
+// redactions.
+func (g *Guardrails) Evaluate(content string) *GuardrailResult {
+	result := &GuardrailResult{Content: content}
+	var root any
+	decoded := json.Unmarshal([]byte(content), &root) == nil
+	redacted := false
+	for _, rule := range g.rules {
+		violate := func(path string, redactable bool, format string, args ...any) {
+			action := rule.Action
+			if action == GuardrailRedact && !redactable {
+				action = GuardrailReject
+			}
+			result.Violations = append(result.Violations, GuardrailViolation{
+				Rule: rule.Name, Action: action, Path: path, Message: fmt.Sprintf(format, args...),
+			})
+		}
+		structural := len(rule.Required) > 0 || len(rule.Forbidden) > 0 || rule.Min != nil || rule.Max != nil || rule.check != nil
+		if rule.Field == "" {
+			text := g.checkString(rule, "$", result.Content, violate)
+			if rule.Action == GuardrailRedact && text != result.Content {
+				result.Content = text
+				decoded = json.Unmarshal([]byte(text), &root) == nil
+			}
+			if !structural {
+				continue
+			}
+		}
+		if !decoded {
+			if rule.Field != "" || len(rule.Required) > 0 || len(rule.Forbidden) > 0 {
+				violate("$", false, "the output is not JSON")
+				continue
+			}
+			// The checks of text outputs
+			root = result.Content
+		}
+		values := guardrailValues(guardrailValue{path: "$", value: root, set: func(v any) { root = v }}, splitGuardrailField(rule.Field))
+		for _, v := range values {
+			if rule.Field != "" {
+				if s, ok := v.value.(string); ok {
+					if text := g.checkString(rule, v.path, s, violate); text != s && rule.Action == GuardrailRedact {
+						v.set(text)
+						redacted = true
+					}
+				}
+			}
+			if g.checkValue(rule, v, violate) {
+				redacted = true
+			}
+		}
+	}
+	if redacted && decoded {
+		if data, err := encodeJSON(root); err == nil {
+			result.Content = string(data)
+		}
+	}
+	return result
+}
+
+// guardrailViolate reports a violation of a rule at a path, and whether the
+// redact action can redact it.
+type guardrailViolate func(path string, redactable bool, format string, args ...any)
+
+// checkString checks the length and the patterns of a string, and returns it
+// redacted.
+func (g *Guardrails) checkString(rule compiledGuardrail, path string, s string, violate guardrailViolate) string {
+	for _, re := range rule.patterns {
+		if re.MatchString(s) {
+			violate(path, true, "matches %s", re)
+			s = re.ReplaceAllString(s, "[redacted]")
+		}
+	}
+	if rule.MaxLength > 0 && utf8.RuneCountInString(s) > rule.MaxLength {
+		violate(path, true, "longer than %d characters", rule.MaxLength)
+		s = string([]rune(s)[:rule.MaxLength])
+	}
+	return s
+}
+
+// checkValue checks the fields, the range and the predicate of a value, and
+// reports whether forbidden fields were redacted.
+func (g *Guardrails) checkValue(rule compiledGuardrail, v guardrailValue, violate guardrailViolate) bool {
+	redacted := false
+	if len(rule.Required) > 0 || len(rule.Forbidden) > 0 {
+		obj, ok := v.value.(map[string]any)
+		if !ok {
+			violate(v.path, false, "not an object")
+			return false
+		}
+		for _, name := range rule.Required {
+			if obj[name] == nil {
+				violate(v.path, false, "missing required field %s", name)
+			}
+		}
+		for _, name := range rule.Forbidden {
+			if _, ok := obj[name]; ok {
+				violate(v.path, true, "forbidden field %s", name)
+				if rule.Action == GuardrailRedact {
+					delete(obj, name)
+					redacted = true
+				}
+			}
+		}
+	}
+	if rule.Min != nil || rule.Max != nil {
+		n, ok := v.value.(float64)
+		switch {
+		case !ok:
+			violate(v.path, false, "not a number")
+		case rule.Min != nil && n < *rule.Min:
+			violate(v.path, false, "%v is less than %v", n, *rule.Min)
+		case rule.Max != nil && n > *rule.Max:
+			violate(v.path, false, "%v is greater than %v", n, *rule.Max)
+		}
+	}
+	if rule.check != nil {
+		if err := rule.check(v.value); err != nil {
+			violate(v.path, false, "%v", err)
+		}
+	}
+	return redacted
+}
+
+func splitGuardrailField(field string) []string {
+	if field == "" {
+		return nil
+	}
+	return strings.Split(field, ".")
+}
+
+// guardrailValues returns the values at the path of parts in v. Missing
+// values are not matched.
+func guardrailValues(v guardrailValue, parts []string) []guardrailValue {
+	if len(parts) == 0 {
+		return []guardrailValue{v}
+	}
+	var values []guardrailValue
+	switch container := v.value.(type) {
+	case map[string]any:
+		var keys []string
+		if parts[0] == "*" {
+			for k := range container {
+				keys = append(keys, k)
+			}
+			slices.Sort(keys)
+		} else if _, ok := container[parts[0]]; ok {
+			keys = []string{parts[0]}
+		}
+		for _, k := range keys {
+			child := guardrailValue{path: v.path + "." + k, value: container[k], set: func(x any) { container[k] = x }}
+			values = append(values, guardrailValues(child, parts[1:])...)
+		}
+	case []any:
+		for i := range container {
+			if parts[0] != "*" && parts[0] != strconv.Itoa(i) {
+				continue
+			}
+			child := guardrailValue{path: fmt.Sprintf("%s[%d]", v.path, i), value: container[i], set: func(x any) { container[i] = x }}
+			values = append(values, guardrailValues(child, parts[1:])...)
+		}
+	}
+	return values
+}
+
+// GuardrailPipeline evaluates the outputs of the completions with guardrails:
+// rejected outputs fail the completion with a [*GuardrailError], outputs to
+// retry are sent back to the model with their violations, redacted outputs
+// replace the content of the completion, and flagged outputs are reported to
+// the flag handler.
+//
+// Evaluating an output requires the full completion, so the pipeline does not
+// support streaming.
+type GuardrailPipeline struct {
+	completions ChatCompleter
+	guardrails  *Guardrails
+	retries     int
+	onFlag      func([]GuardrailViolation, *openai.ChatCompletion)
+}
+
+var _ ChatCompleter = (*GuardrailPipeline)(nil)
+
+type GuardrailOption func(*GuardrailPipeline)
+
+// WithGuardrailRetries sets the number of times an output is sent back to the
+// model for the retry violations, 1 by default.
+func WithGuardrailRetries(n int) GuardrailOption {
+	return func(p *GuardrailPipeline) {
+		p.retries = n
+	}
+}
+
+// WithGuardrailFlagHandler is called with the flagged and redacted violations
+// of the outputs that are let through. By default they are logged with the
+// standard logger.
+func WithGuardrailFlagHandler(fn func(violations []GuardrailViolation, completion *openai.ChatCompletion)) GuardrailOption {
+	return func(p *GuardrailPipeline) {
+		p.onFlag = fn
+	}
+}
+
+func NewGuardrailPipeline(completions ChatCompleter, guardrails *Guardrails, opts ...GuardrailOption) *GuardrailPipeline {
+	p := &GuardrailPipeline{
+		completions: completions,
+		guardrails:  guardrails,
+		retries:     1,
+		onFlag: func(violations []GuardrailViolation, completion *openai.ChatCompletion) {
+			for _, v := range violations {
+				// The messages quote the output, e.g. the value of a pattern
+				log.Printf("workflowai: guardrail %s %s completion %s: %s", v.Rule, v.Action, completion.ID, Scrub(v.String()))
+			}
+		},
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+func (p *GuardrailPipeline) New(ctx context.Context, body openai.ChatCompletionNewParams, opts ...option.RequestOption) (*openai.ChatCompletion, error) {
+	// Messages are copied since retries append to them
+	body.Messages = append([]openai.ChatCompletionMessageParamUnion{}, body.Messages...)
+	for attempt := 1; ; attempt++ {
+		completion, err := p.completions.New(ctx, body, opts...)
+		if err != nil {
+			return nil, err
+		}
+		var violations []GuardrailViolation
+		action := GuardrailAction("")
+		results := make([]*GuardrailResult, len(completion.Choices))
+		for i, choice := range completion.Choices {
+			results[i] = p.guardrails.Evaluate(choice.Message.Content)
+			violations = append(violations, results[i].Violations...)
+			if a := results[i].Action(); guardrailSeverity[a] > guardrailSeverity[action] {
+				action = a
+			}
+		}
+		switch {
+		case action == GuardrailRetry && attempt <= p.retries:
+			body.Messages = append(body.Messages, completion.Choices[0].Message.ToParam(), openai.UserMessage(guardrailRetryPrompt(violations)))
+			continue
+		case action == GuardrailRetry || action == GuardrailReject:
+			return nil, &GuardrailError{Violations: violations, Attempts: attempt}
+		}
+		for i := range completion.Choices {
+			completion.Choices[i].Message.Content = results[i].Content
+		}
+		if len(violations) > 0 {
+			p.onFlag(violations, completion)
+		}
+		return completion, nil
+	}
+}
+
+func guardrailRetryPrompt(violations []GuardrailViolation) string {
+	var b strings.Builder
+	b.WriteString("Your answer violates the output rules:\n")
+	for _, v := range violations {
+		fmt.Fprintf(&b, "- %s: %s\n", v.Path, v.Message)
+	}
+	b.WriteString("\nAnswer again, following the rules.")
+	return b.String()
+}
@@ -0,0 +1,137 @@
+package workflowai
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+func TestGuardrails_Evaluate(t *testing.T) {
+	guardrails, err := ParseGuardrails([]byte(`{
+		"no-emails": {"field": "reply", "banned_patterns": ["[\\w.]+@[\\w.]+"], "action": "redact"},
+		"short-replies": {"field": "reply", "max_length": 20, "action": "flag"},
+		"fields": {"required": ["reply", "confidence"], "forbidden": ["internal_notes"], "action": "redact"},
+		"prices": {"field": "items.*.price", "min": 0, "max": 1000},
+		"skus": {"field": "items.*.sku", "predicate": "known_sku", "action": "retry"}
+	}`), WithGuardrailPredicate("known_sku", func(v any) error {
+		if v != "A-1" {
+			return errors.New("unknown SKU")
+		}
+		return nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := guardrails.Evaluate(`{"reply": "Please write to ann@example.com", "confidence": 0.9, "internal_notes": "vip", "items": [{"sku": "A-1", "price": 10}]}`)
+	if result.Action() != GuardrailRedact || result.Err() != nil {
+		t.Fatalf("unexpected violations %v", result.Violations)
+	}
+	if result.Content != `{"confidence":0.9,"items":[{"price":10,"sku":"A-1"}],"reply":"Please write to [redacted]"}` {
+		t.Errorf("unexpected redaction %s", result.Content)
+	}
+	if len(result.Violations) != 3 || result.Violations[2].Rule != "short-replies" || result.Violations[2].Path != "$.reply" {
+		t.Errorf("unexpected violations %v", result.Violations)
+	}
+
+	result = guardrails.Evaluate(`{"reply": "Hi", "items": [{"sku": "B-2", "price": 10}, {"sku": "A-1", "price": -1}]}`)
+	var guardrailErr *GuardrailError
+	if !errors.As(result.Err(), &guardrailErr) || result.Action() != GuardrailReject {
+		t.Fatalf("expected a rejection, got %v", result.Violations)
+	}
+	var messages []string
+	for _, v := range result.Violations {
+		messages = append(messages, v.String())
+	}
+	expected := []string{
+		"$: missing required field confidence (fields)",
+		"$.items[1].price: -1 is less than 0 (prices)",
+		"$.items[0].sku: unknown SKU (skus)",
+	}
+	if strings.Join(messages, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("unexpected violations\n%s", strings.Join(messages, "\n"))
+	}
+	// The missing field cannot be redacted
+	if result.Violations[0].Action != GuardrailReject || result.Violations[2].Action != GuardrailRetry {
+		t.Errorf("unexpected actions %v", result.Violations)
+	}
+
+	if result := guardrails.Evaluate("not JSON"); result.Err() == nil {
+		t.Error("expected text outputs to be rejected by the JSON rules")
+	}
+
+	// Text outputs
+	text, err := NewGuardrails([]Guardrail{{MaxLength: 10, Action: GuardrailRedact}, {BannedPatterns: []string{`(?i)guarantee`}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result := text.Evaluate("Hello world!"); result.Content != "Hello worl" || result.Err() != nil {
+		t.Errorf("unexpected result %+v", result)
+	}
+	if result := text.Evaluate("Guaranteed"); result.Err() == nil {
+		t.Error("expected a rejection")
+	}
+
+	for _, rules := range []string{`[{"banned_patterns": ["("]}]`, `[{"action": "ignore"}]`, `[{"predicate": "unknown"}]`, `"rules"`} {
+		if _, err := ParseGuardrails([]byte(rules)); err == nil {
+			t.Errorf("expected an error for %s", rules)
+		}
+	}
+}
+
+// scriptedCompleter replies with the replies in order, then with the last one.
+type scriptedCompleter struct {
+	replies  []string
+	requests []openai.ChatCompletionNewParams
+}
+
+func (c *scriptedCompleter) New(_ context.Context, body openai.ChatCompletionNewParams, _ ...option.RequestOption) (*openai.ChatCompletion, error) {
+	c.requests = append(c.requests, body)
+	reply := c.replies[min(len(c.requests), len(c.replies))-1]
+	return &openai.ChatCompletion{
+		Choices: []openai.ChatCompletionChoice{{Message: openai.ChatCompletionMessage{Role: "assistant", Content: reply}}},
+	}, nil
+}
+
+func TestGuardrailPipeline(t *testing.T) {
+	guardrails, err := NewGuardrails([]Guardrail{
+		{Name: "length", MaxLength: 20, Action: GuardrailRetry},
+		{Name: "refunds", BannedPatterns: []string{`(?i)refund`}, Action: GuardrailFlag},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	completer := &scriptedCompleter{replies: []string{"This answer is far too long to be displayed", "No refunds."}}
+	var flagged []GuardrailViolation
+	pipeline := NewGuardrailPipeline(completer, guardrails, WithGuardrailFlagHandler(func(violations []GuardrailViolation, _ *openai.ChatCompletion) {
+		flagged = append(flagged, violations...)
+	}))
+	params := openai.ChatCompletionNewParams{Model: "support/gpt-4o", Messages: []openai.ChatCompletionMessageParamUnion{openai.UserMessage("Hi")}}
+
+	completion, err := pipeline.New(context.Background(), params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if completion.Choices[0].Message.Content != "No refunds." || len(completer.requests) != 2 || len(completer.requests[1].Messages) != 3 {
+		t.Errorf("expected a retry, got %d requests", len(completer.requests))
+	}
+	if len(flagged) != 1 || flagged[0].Rule != "refunds" {
+		t.Errorf("unexpected flags %v", flagged)
+	}
+	if len(params.Messages) != 1 {
+		t.Error("the messages of the caller were modified")
+	}
+
+	// The output is still too long after the retry
+	completer = &scriptedCompleter{replies: []string{"This answer is far too long to be displayed"}}
+	pipeline = NewGuardrailPipeline(completer, guardrails)
+	_, err = pipeline.New(context.Background(), params)
+	var guardrailErr *GuardrailError
+	if !errors.As(err, &guardrailErr) || guardrailErr.Attempts != 2 || len(completer.requests) != 2 {
+		t.Errorf("expected a rejection after the retry, got %v", err)
+	}
+}
@@ -0,0 +1,88 @@
+package workflowai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+// HealthStatus is the status of the platform.
+type HealthStatus string
+
+const (
+	HealthOK   HealthStatus = "ok"
+	HealthDown HealthStatus = "down"
+)
+
+// Health is the status of the platform returned by [Client.Health].
+type Health struct {
+	Status HealthStatus `json:"status"`
+	// Latency is the duration of the check
+	Latency   time.Duration `json:"-"`
+	CheckedAt time.Time     `json:"-"`
+}
+
+// Ready reports whether the platform serves requests.
+func (h *Health) Ready() bool {
+	return h.Status == HealthOK
+}
+
+// Health returns the status of the platform, from its health probe, which
+// answers 200 when the API and its storage are up and 503 otherwise. An
+// error is returned when the platform cannot be reached: a platform
+// answering that it is down is not an error.
+//
+// The check is not retried, so that readiness probes get a timely answer.
+func (c Client) Health(ctx context.Context, opts ...option.RequestOption) (*Health, error) {
+	start := time.Now()
+	opts = append([]option.RequestOption{option.WithMaxRetries(0)}, opts...)
+	// The probes are served at the root of the host, not under /v1
+	err := c.Execute(ctx, http.MethodGet, "../probes/health", nil, nil, opts...)
+	health := Health{Status: HealthOK}
+	var apiErr *openai.Error
+	switch {
+	case err == nil:
+	case errors.As(err, &apiErr) && apiErr.StatusCode >= 500:
+		health.Status = HealthDown
+	default:
+		return nil, err
+	}
+	health.Latency, health.CheckedAt = time.Since(start), time.Now()
+	return &health, nil
+}
+
+// HealthHandler returns an HTTP handler for the readiness probes of a
+// service, answering 200 when the platform is ready and 503 otherwise, with
+// the [Health] as JSON. Each probe checks the platform, with timeout when it
+// is > 0.
+func HealthHandler(client Client, timeout time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		if timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+		health, err := client.Health(ctx)
+		if err != nil {
+			writeHealth(w, http.StatusServiceUnavailable, map[string]any{"status": HealthDown, "error": err.Error()})
+			return
+		}
+		status := http.StatusOK
+		if !health.Ready() {
+			status = http.StatusServiceUnavailable
+		}
+		writeHealth(w, status, health)
+	})
+}
+
+func writeHealth(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
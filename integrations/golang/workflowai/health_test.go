@@ -0,0 +1,52 @@
+package workflowai
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/openai/openai-go/option"
+)
+
+func TestClient_Health(t *testing.T) {
+	healthCode := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/probes/health" {
+			http.NotFound(w, r)
+			return
+		}
+		w.WriteHeader(healthCode)
+	}))
+	defer server.Close()
+	client := NewClient(option.WithBaseURL(server.URL+"/v1"), option.WithAPIKey("test"))
+	ctx := context.Background()
+
+	health, err := client.Health(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !health.Ready() || health.Status != HealthOK || health.CheckedAt.IsZero() {
+		t.Errorf("unexpected health %+v", health)
+	}
+
+	healthCode = http.StatusServiceUnavailable
+	if health, err := client.Health(ctx); err != nil || health.Ready() || health.Status != HealthDown {
+		t.Errorf("unexpected health %+v %v", health, err)
+	}
+
+	// The readiness probe of a service
+	rec := httptest.NewRecorder()
+	HealthHandler(client, time.Second).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ready", nil))
+	var body Health
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || rec.Code != http.StatusServiceUnavailable || body.Status != HealthDown {
+		t.Errorf("unexpected probe %d %s", rec.Code, rec.Body)
+	}
+
+	server.Close()
+	if _, err := client.Health(ctx); err == nil {
+		t.Error("expected an error when the platform is unreachable")
+	}
+}
@@ -0,0 +1,440 @@
+package workflowai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openai/openai-go/option"
+)
+
+// Hooks is a registry of handlers called during the lifecycle of the chat
+// completions, so that metrics, telemetry or billing can observe them
+// without wrapping every call site:
+//
+//	var hooks workflowai.Hooks
+//	hooks.OnComplete(func(ctx context.Context, e workflowai.CompleteEvent) {
+//		log.Printf("%s cost $%.4f", e.Model, e.CostUSD)
+//	})
+//	client := workflowai.NewClient(option.WithMiddleware(hooks.Middleware()))
+//
+// Handlers can be registered at any time and are called synchronously, in
+// the order they were registered, so they must not block. Every completion
+// ends with either a CompleteEvent or an ErrorEvent. The zero value is ready
+// to use.
+type Hooks struct {
+	onRequestStart hookList[RequestStartEvent]
+	onFirstToken   hookList[FirstTokenEvent]
+	onChunk        hookList[ChunkEvent]
+	onToolCall     hookList[ToolCallEvent]
+	onComplete     hookList[CompleteEvent]
+	onError        hookList[ErrorEvent]
+}
+
+// HookRequest identifies the completion an event belongs to.
+type HookRequest struct {
+	// Model is the model that was requested, e.g. "my-agent/gpt-4o"
+	Model     string
+	Stream    bool
+	StartedAt time.Time
+	// RetryCount is the number of previous attempts of the request, the
+	// hooks being called for every attempt
+	RetryCount int
+}
+
+// RequestStartEvent is emitted before the request is sent.
+type RequestStartEvent struct {
+	HookRequest
+	// Body is the JSON encoded request
+	Body []byte
+}
+
+// FirstTokenEvent is emitted when the first content or tool call chunk of a
+// streamed completion is received.
+type FirstTokenEvent struct {
+	HookRequest
+	TimeToFirstToken time.Duration
+}
+
+// ChunkEvent is emitted for each chunk of a streamed completion.
+type ChunkEvent struct {
+	HookRequest
+	// Index is the position of the chunk in the stream, starting at 0
+	Index int
+	// Content is the content delta of the first choice
+	Content string
+	// Data is the JSON encoded chunk
+	Data []byte
+}
+
+// ToolCallEvent is emitted for each tool call of a completion, once its
+// arguments are complete.
+type ToolCallEvent struct {
+	HookRequest
+	ID        string
+	Name      string
+	Arguments string
+}
+
+// CompleteEvent is emitted when a completion succeeds.
+type CompleteEvent struct {
+	HookRequest
+	AgentID          string
+	RunID            string
+	VersionID        string
+	CostUSD          float64
+	PromptTokens     int64
+	CompletionTokens int64
+	Duration         time.Duration
+	// TimeToFirstToken is 0 for completions that are not streamed
+	TimeToFirstToken time.Duration
+	// Content is the content of the completion
+	Content string
+}
+
+// ErrorEvent is emitted when a completion fails, before or after reaching
+// the server, or when a stream is closed before its end, with
+// [ErrStreamTruncated].
+type ErrorEvent struct {
+	HookRequest
+	// StatusCode is 0 when no response was received
+	StatusCode int
+	// Code is the WorkflowAI error code, e.g. "rate_limit", when the server
+	// returned an error
+	Code string
+	// AgentID and RunID are set when the error occurred during a run
+	AgentID  string
+	RunID    string
+	Duration time.Duration
+	// Err is never nil
+	Err error
+	// Body is the JSON encoded request
+	Body []byte
+}
+
+func (h *Hooks) OnRequestStart(fn func(context.Context, RequestStartEvent)) {
+	h.onRequestStart.add(fn)
+}
+
+func (h *Hooks) OnFirstToken(fn func(context.Context, FirstTokenEvent)) {
+	h.onFirstToken.add(fn)
+}
+
+func (h *Hooks) OnChunk(fn func(context.Context, ChunkEvent)) {
+	h.onChunk.add(fn)
+}
+
+func (h *Hooks) OnToolCall(fn func(context.Context, ToolCallEvent)) {
+	h.onToolCall.add(fn)
+}
+
+func (h *Hooks) OnComplete(fn func(context.Context, CompleteEvent)) {
+	h.onComplete.add(fn)
+}
+
+func (h *Hooks) OnError(fn func(context.Context, ErrorEvent)) {
+	h.onError.add(fn)
+}
+
+type hookList[E any] struct {
+	mu       sync.RWMutex
+	handlers []func(context.Context, E)
+}
+
+func (l *hookList[E]) add(fn func(context.Context, E)) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.handlers = append(l.handlers, fn)
+}
+
+func (l *hookList[E]) call(ctx context.Context, event E) {
+	l.mu.RLock()
+	handlers := l.handlers
+	l.mu.RUnlock()
+	for _, fn := range handlers {
+		fn(ctx, event)
+	}
+}
+
+// Middleware returns the client middleware emitting the events of the chat
+// completions.
+func (h *Hooks) Middleware() option.Middleware {
+	return h.middleware
+}
+
+func (h *Hooks) middleware(req *http.Request, next option.MiddlewareNext) (*http.Response, error) {
+	if !isChatCompletionRequest(req) {
+		return next(req)
+	}
+
+	body, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	var payload struct {
+		Model  string `json:"model"`
+		Stream bool   `json:"stream"`
+	}
+	_ = json.Unmarshal(body, &payload)
+	request := HookRequest{Model: payload.Model, Stream: payload.Stream, StartedAt: time.Now()}
+	request.RetryCount, _ = strconv.Atoi(req.Header.Get("X-Stainless-Retry-Count"))
+	// The request context may already be canceled when a stream is closed early
+	ctx := context.WithoutCancel(req.Context())
+	h.onRequestStart.call(ctx, RequestStartEvent{HookRequest: request, Body: body})
+
+	res, err := next(req)
+	if err != nil {
+		h.onError.call(ctx, ErrorEvent{HookRequest: request, Duration: time.Since(request.StartedAt), Err: err, Body: body})
+		return res, err
+	}
+
+	if strings.HasPrefix(res.Header.Get("Content-Type"), "text/event-stream") {
+		stream := &hookStream{hooks: h, ctx: ctx, request: request, body: body, tools: toolCallAccumulator{}}
+		stream.recorder = &streamRecorder{
+			ReadCloser: res.Body,
+			onEvent:    stream.event,
+			onDone:     stream.done,
+		}
+		res.Body = stream
+		return res, nil
+	}
+
+	resBody, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		h.onError.call(ctx, ErrorEvent{HookRequest: request, StatusCode: res.StatusCode, Duration: time.Since(request.StartedAt), Err: err, Body: body})
+		return nil, err
+	}
+	res.Body = io.NopCloser(bytes.NewReader(resBody))
+
+	if res.StatusCode >= 400 {
+		event := ErrorEvent{HookRequest: request, StatusCode: res.StatusCode, Duration: time.Since(request.StartedAt), Body: body}
+		event.parseError(resBody)
+		if event.Err == nil {
+			event.Err = fmt.Errorf("workflowai: completion failed with status %d: %s", res.StatusCode, bytes.TrimSpace(resBody))
+		}
+		h.onError.call(ctx, event)
+		return res, nil
+	}
+	tools := toolCallAccumulator{}
+	tools.parse(resBody)
+	h.emitToolCalls(ctx, request, tools)
+	var summary completionSummary
+	summary.parseCompletion(resBody)
+	h.onComplete.call(ctx, completeEvent(request, &summary))
+	return res, nil
+}
+
+func (h *Hooks) emitToolCalls(ctx context.Context, request HookRequest, tools toolCallAccumulator) {
+	for _, call := range tools.sorted() {
+		h.onToolCall.call(ctx, ToolCallEvent{HookRequest: request, ID: call.ID, Name: call.Function.Name, Arguments: call.Function.Arguments})
+	}
+}
+
+func completeEvent(request HookRequest, summary *completionSummary) CompleteEvent {
+	var record RunRecord
+	summary.apply(&record, 0)
+	return CompleteEvent{
+		HookRequest:      request,
+		AgentID:          record.AgentID,
+		RunID:            record.RunID,
+		VersionID:        record.VersionID,
+		CostUSD:          record.CostUSD,
+		PromptTokens:     record.PromptTokens,
+		CompletionTokens: record.CompletionTokens,
+		Duration:         time.Since(request.StartedAt),
+		Content:          record.Response,
+	}
+}
+
+// parseError sets the fields of a WorkflowAI error response.
+func (e *ErrorEvent) parseError(data []byte) {
+	var payload struct {
+		ID    string `json:"id"`
+		Error *struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(data, &payload); err != nil || payload.Error == nil {
+		return
+	}
+	if payload.ID != "" {
+		e.AgentID, e.RunID = splitCompletionID(payload.ID)
+	}
+	e.Code = payload.Error.Code
+	e.Err = fmt.Errorf("workflowai: completion failed: %s: %s", payload.Error.Code, payload.Error.Message)
+}
+
+// hookStream emits the events of a streamed completion as the client reads
+// it.
+type hookStream struct {
+	hooks    *Hooks
+	ctx      context.Context
+	request  HookRequest
+	body     []byte
+	recorder *streamRecorder
+
+	chunks int
+	// ttft is 0 until the first token is received
+	ttft  time.Duration
+	tools toolCallAccumulator
+	// errData is the error event of the stream
+	errData []byte
+	readErr error
+}
+
+func (s *hookStream) Read(p []byte) (int, error) {
+	n, err := s.recorder.Read(p)
+	if err != nil && err != io.EOF && s.readErr == nil {
+		s.readErr = err
+	}
+	return n, err
+}
+
+func (s *hookStream) Close() error {
+	return s.recorder.Close()
+}
+
+func (s *hookStream) event(data []byte) {
+	var chunk struct {
+		Error   json.RawMessage `json:"error"`
+		Choices []struct {
+			Delta *struct {
+				Content   string            `json:"content"`
+				ToolCalls []json.RawMessage `json:"tool_calls"`
+			} `json:"delta"`
+		} `json:"choices"`
+	}
+	if err := json.Unmarshal(data, &chunk); err != nil {
+		return
+	}
+	// Some chunks have "error": null
+	if chunk.Error != nil && string(chunk.Error) != "null" {
+		if s.errData == nil {
+			s.errData = data
+		}
+		return
+	}
+	var content string
+	var hasToken bool
+	for i, choice := range chunk.Choices {
+		if choice.Delta == nil {
+			continue
+		}
+		if i == 0 {
+			content = choice.Delta.Content
+		}
+		hasToken = hasToken || choice.Delta.Content != "" || len(choice.Delta.ToolCalls) > 0
+	}
+	if hasToken && s.ttft == 0 {
+		s.ttft = time.Since(s.request.StartedAt)
+		s.hooks.onFirstToken.call(s.ctx, FirstTokenEvent{HookRequest: s.request, TimeToFirstToken: s.ttft})
+	}
+	s.tools.parse(data)
+	s.hooks.onChunk.call(s.ctx, ChunkEvent{HookRequest: s.request, Index: s.chunks, Content: content, Data: data})
+	s.chunks++
+}
+
+func (s *hookStream) done(summary *completionSummary) {
+	if s.errData != nil || s.readErr != nil || summary.truncated {
+		event := ErrorEvent{HookRequest: s.request, StatusCode: http.StatusOK, Duration: time.Since(s.request.StartedAt), Err: s.readErr, Body: s.body}
+		event.AgentID, event.RunID = splitCompletionID(summary.id)
+		if s.errData != nil {
+			event.parseError(s.errData)
+			if event.Err == nil {
+				event.Err = fmt.Errorf("workflowai: completion failed: %s", bytes.TrimSpace(s.errData))
+			}
+		}
+		if event.Err == nil {
+			event.Err = ErrStreamTruncated
+		}
+		s.hooks.onError.call(s.ctx, event)
+		return
+	}
+	s.hooks.emitToolCalls(s.ctx, s.request, s.tools)
+	event := completeEvent(s.request, summary)
+	event.TimeToFirstToken = s.ttft
+	s.hooks.onComplete.call(s.ctx, event)
+}
+
+type toolCallPayload struct {
+	Index    int    `json:"index"`
+	ID       string `json:"id"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+// toolCallAccumulator merges the tool calls of a completion, or the tool call
+// deltas of its chunks, keyed by choice and tool call index.
+type toolCallAccumulator map[[2]int]*toolCallPayload
+
+func (a toolCallAccumulator) parse(data []byte) {
+	var payload struct {
+		Choices []struct {
+			Index   int `json:"index"`
+			Message *struct {
+				ToolCalls []toolCallPayload `json:"tool_calls"`
+			} `json:"message"`
+			Delta *struct {
+				ToolCalls []toolCallPayload `json:"tool_calls"`
+			} `json:"delta"`
+		} `json:"choices"`
+	}
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return
+	}
+	for _, choice := range payload.Choices {
+		if choice.Message != nil {
+			for i, call := range choice.Message.ToolCalls {
+				call := call
+				a[[2]int{choice.Index, i}] = &call
+			}
+		}
+		if choice.Delta != nil {
+			for _, delta := range choice.Delta.ToolCalls {
+				key := [2]int{choice.Index, delta.Index}
+				call, ok := a[key]
+				if !ok {
+					call = &toolCallPayload{Index: delta.Index}
+					a[key] = call
+				}
+				if delta.ID != "" {
+					call.ID = delta.ID
+				}
+				call.Function.Name += delta.Function.Name
+				call.Function.Arguments += delta.Function.Arguments
+			}
+		}
+	}
+}
+
+func (a toolCallAccumulator) sorted() []*toolCallPayload {
+	keys := make([][2]int, 0, len(a))
+	for key := range a {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i][0] != keys[j][0] {
+			return keys[i][0] < keys[j][0]
+		}
+		return keys[i][1] < keys[j][1]
+	})
+	calls := make([]*toolCallPayload, len(keys))
+	for i, key := range keys {
+		calls[i] = a[key]
+	}
+	return calls
+}
+
+var _ io.ReadCloser = (*hookStream)(nil)
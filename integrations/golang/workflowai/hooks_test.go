@@ -0,0 +1,162 @@
+package workflowai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+func recordHooks(hooks *Hooks) *[]string {
+	var events []string
+	hooks.OnRequestStart(func(_ context.Context, e RequestStartEvent) {
+		events = append(events, fmt.Sprintf("start %s stream=%v", e.Model, e.Stream))
+	})
+	hooks.OnFirstToken(func(_ context.Context, e FirstTokenEvent) {
+		events = append(events, "first token")
+	})
+	hooks.OnChunk(func(_ context.Context, e ChunkEvent) {
+		events = append(events, fmt.Sprintf("chunk %d %q", e.Index, e.Content))
+	})
+	hooks.OnToolCall(func(_ context.Context, e ToolCallEvent) {
+		events = append(events, fmt.Sprintf("tool %s %s %s", e.ID, e.Name, e.Arguments))
+	})
+	hooks.OnComplete(func(_ context.Context, e CompleteEvent) {
+		events = append(events, fmt.Sprintf("complete %s %s %q $%v %d/%d", e.AgentID, e.RunID, e.Content, e.CostUSD, e.PromptTokens, e.CompletionTokens))
+	})
+	hooks.OnError(func(_ context.Context, e ErrorEvent) {
+		events = append(events, fmt.Sprintf("error %d %s %s retry=%d", e.StatusCode, e.Code, e.RunID, e.RetryCount))
+		if e.Err == nil {
+			events = append(events, "nil error")
+		}
+	})
+	return &events
+}
+
+func TestHooks(t *testing.T) {
+	server := newTestServer(t)
+	var hooks Hooks
+	events := recordHooks(&hooks)
+	client := openai.NewClient(option.WithBaseURL(server.URL), option.WithAPIKey("test"), option.WithMiddleware(hooks.Middleware()))
+	ctx := context.Background()
+	params := openai.ChatCompletionNewParams{Model: "my-agent/gpt-4o", Messages: []openai.ChatCompletionMessageParamUnion{openai.UserMessage("Hi")}}
+
+	if _, err := client.Chat.Completions.New(ctx, params); err != nil {
+		t.Fatal(err)
+	}
+	stream := client.Chat.Completions.NewStreaming(ctx, params)
+	for stream.Next() {
+	}
+	if err := stream.Err(); err != nil {
+		t.Fatal(err)
+	}
+	stream.Close()
+
+	expected := []string{
+		"start my-agent/gpt-4o stream=false",
+		`complete my-agent run-1 "Hello world" $0.5 10/2`,
+		"start my-agent/gpt-4o stream=true",
+		"first token",
+		`chunk 0 "Hello"`,
+		`chunk 1 " world"`,
+		`chunk 2 ""`,
+		`complete my-agent run-2 "Hello world" $0.25 10/2`,
+	}
+	if strings.Join(*events, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("unexpected events:\n%s", strings.Join(*events, "\n"))
+	}
+}
+
+func TestHooks_ToolCallsAndErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := readRequestBody(r)
+		switch {
+		case strings.Contains(string(body), "fail"):
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": map[string]any{"code": "bad_request", "message": "invalid"}})
+		case strings.Contains(string(body), "broken"):
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprint(w, `data: {"id":"a/r9","error":{"code":"provider_error","message":"down"}}`+"\n\n")
+		case strings.Contains(string(body), "overloaded"):
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprint(w, `data: {"id":"a/r8","error":"overloaded"}`+"\n\n")
+		default:
+			w.Header().Set("Content-Type", "text/event-stream")
+			for _, chunk := range []string{
+				`{"id":"a/r","error":null,"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call-1","type":"function","function":{"name":"get_weather","arguments":""}}]}}]}`,
+				`{"id":"a/r","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{\"city\":"}}]}}]}`,
+				`{"id":"a/r","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"Paris\"}"}}]}}]}`,
+			} {
+				fmt.Fprintf(w, "data: %s\n\n", chunk)
+			}
+			fmt.Fprint(w, "data: [DONE]\n\n")
+		}
+	}))
+	defer server.Close()
+	var hooks Hooks
+	events := recordHooks(&hooks)
+	client := openai.NewClient(option.WithBaseURL(server.URL), option.WithAPIKey("test"), option.WithMaxRetries(0), option.WithMiddleware(hooks.Middleware()))
+	ctx := context.Background()
+
+	for _, message := range []string{"weather", "broken", "overloaded"} {
+		stream := client.Chat.Completions.NewStreaming(ctx, openai.ChatCompletionNewParams{Model: "gpt-4o", Messages: []openai.ChatCompletionMessageParamUnion{openai.UserMessage(message)}})
+		for stream.Next() {
+		}
+		stream.Close()
+	}
+	if _, err := client.Chat.Completions.New(ctx, openai.ChatCompletionNewParams{Model: "gpt-4o", Messages: []openai.ChatCompletionMessageParamUnion{openai.UserMessage("fail")}}); err == nil {
+		t.Fatal("expected an error")
+	}
+
+	expected := []string{
+		"start gpt-4o stream=true",
+		"first token",
+		`chunk 0 ""`,
+		`chunk 1 ""`,
+		`chunk 2 ""`,
+		`tool call-1 get_weather {"city":"Paris"}`,
+		`complete a r "" $0 0/0`,
+		"start gpt-4o stream=true",
+		"error 200 provider_error r9 retry=0",
+		"start gpt-4o stream=true",
+		"error 200  r8 retry=0",
+		"start gpt-4o stream=false",
+		"error 400 bad_request  retry=0",
+	}
+	if strings.Join(*events, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("unexpected events:\n%s", strings.Join(*events, "\n"))
+	}
+}
+
+func TestHooks_TruncatedStream(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, `data: {"id":"a/r","choices":[{"index":0,"delta":{"content":"Once upon"}}]}`+"\n\n")
+		w.(http.Flusher).Flush()
+		// The rest of the completion is never read
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+	var hooks Hooks
+	var errs []error
+	completed := false
+	hooks.OnError(func(_ context.Context, e ErrorEvent) { errs = append(errs, e.Err) })
+	hooks.OnComplete(func(context.Context, CompleteEvent) { completed = true })
+	client := openai.NewClient(option.WithBaseURL(server.URL), option.WithAPIKey("test"), option.WithMaxRetries(0), option.WithMiddleware(hooks.Middleware()))
+
+	stream := client.Chat.Completions.NewStreaming(context.Background(), openai.ChatCompletionNewParams{Model: "gpt-4o", Messages: []openai.ChatCompletionMessageParamUnion{openai.UserMessage("story")}})
+	if !stream.Next() {
+		t.Fatal(stream.Err())
+	}
+	stream.Close()
+	if completed || len(errs) != 1 || !errors.Is(errs[0], ErrStreamTruncated) {
+		t.Errorf("expected the stream to be reported as truncated, got %v", errs)
+	}
+}
@@ -0,0 +1,278 @@
+package workflowai
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"strings"
+
+	"github.com/openai/openai-go"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// ImageLimits are the limits of the images accepted by a model. Zero values
+// are not limited.
+type ImageLimits struct {
+	// MaxBytes is the size of the encoded image
+	MaxBytes int
+	// MaxLongSide and MaxShortSide are the dimensions in pixels above which
+	// the provider downscales the image, so larger images only cost upload
+	// time
+	MaxLongSide  int
+	MaxShortSide int
+}
+
+var (
+	// OpenAIImageLimits are the limits of the OpenAI models for a "high" or
+	// "auto" detail: larger images are scaled to fit 2048x2048, then to a
+	// short side of 768 pixels.
+	OpenAIImageLimits = ImageLimits{MaxBytes: 20 << 20, MaxLongSide: 2048, MaxShortSide: 768}
+	// AnthropicImageLimits are the limits of the Claude models, which scale
+	// images down to a long side of 1568 pixels.
+	AnthropicImageLimits = ImageLimits{MaxBytes: 5 << 20, MaxLongSide: 1568}
+	// GeminiImageLimits are the limits of inline images for the Gemini models.
+	GeminiImageLimits = ImageLimits{MaxBytes: 20 << 20, MaxLongSide: 3072}
+)
+
+// ImageLimitsFor returns the image limits of a model, e.g.
+// "my-agent/claude-3-7-sonnet-latest", defaulting to [OpenAIImageLimits].
+func ImageLimitsFor(model string) ImageLimits {
+	id := model[strings.LastIndex(model, "/")+1:]
+	switch {
+	case strings.HasPrefix(id, "claude"):
+		return AnthropicImageLimits
+	case strings.HasPrefix(id, "gemini"):
+		return GeminiImageLimits
+	default:
+		return OpenAIImageLimits
+	}
+}
+
+// ImageTooLargeError is returned when an image exceeds the limits and is not
+// allowed to be, or cannot be, reduced below them.
+type ImageTooLargeError struct {
+	Bytes, Width, Height int
+	Limits               ImageLimits
+}
+
+func (e *ImageTooLargeError) Error() string {
+	if e.Limits.MaxBytes > 0 && e.Bytes > e.Limits.MaxBytes {
+		return fmt.Sprintf("workflowai: image of %d bytes exceeds the limit of %d bytes", e.Bytes, e.Limits.MaxBytes)
+	}
+	return fmt.Sprintf("workflowai: image of %dx%d pixels exceeds the limits of %d pixels for the long side and %d for the short side", e.Width, e.Height, e.Limits.MaxLongSide, e.Limits.MaxShortSide)
+}
+
+// PreparedImage is an image ready to be embedded in a request.
+type PreparedImage struct {
+	Data          []byte
+	MimeType      string
+	Width, Height int
+	// Reencoded is true when the image was downscaled or converted
+	Reencoded bool
+}
+
+// Part returns the image as an inline content part.
+func (img PreparedImage) Part(detail string) openai.ChatCompletionContentPartUnionParam {
+	return ImageDataPart(img.Data, img.MimeType, detail)
+}
+
+// defaultMaxDecodedPixels is the default limit of the pixels decoded by
+// [PrepareImage], about 200 MB of RGBA.
+const defaultMaxDecodedPixels = 50_000_000
+
+type imageConfig struct {
+	limits    ImageLimits
+	format    string
+	quality   int
+	noResize  bool
+	maxPixels int
+}
+
+type ImageOption func(*imageConfig)
+
+// WithImageLimits sets the limits of the model, [OpenAIImageLimits] by
+// default.
+func WithImageLimits(limits ImageLimits) ImageOption {
+	return func(c *imageConfig) {
+		c.limits = limits
+	}
+}
+
+// WithImageFormat re-encodes every image as "jpeg" or "png". By default,
+// images within the limits are kept as is and downscaled images are encoded
+// as PNG when they have transparency, JPEG otherwise. WebP images are
+// decoded but cannot be encoded.
+func WithImageFormat(format string) ImageOption {
+	return func(c *imageConfig) {
+		c.format = format
+	}
+}
+
+// WithJPEGQuality sets the initial JPEG quality, 85 by default. The quality
+// is lowered when the image exceeds MaxBytes.
+func WithJPEGQuality(quality int) ImageOption {
+	return func(c *imageConfig) {
+		c.quality = quality
+	}
+}
+
+// WithoutDownscale only validates the images: images exceeding the limits
+// fail with an [*ImageTooLargeError].
+func WithoutDownscale() ImageOption {
+	return func(c *imageConfig) {
+		c.noResize = true
+	}
+}
+
+// WithMaxDecodedPixels sets the number of pixels above which images are
+// rejected instead of being decoded to be downscaled, 50 million by default.
+// A small file can declare billions of pixels, which would exhaust the memory
+// once decoded.
+func WithMaxDecodedPixels(pixels int) ImageOption {
+	return func(c *imageConfig) {
+		c.maxPixels = pixels
+	}
+}
+
+// ValidateImage returns an [*ImageTooLargeError] if an image exceeds limits,
+// reading only its header.
+func ValidateImage(data []byte, limits ImageLimits) error {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("workflowai: decoding image: %w", err)
+	}
+	if exceeds(len(data), cfg.Width, cfg.Height, limits) {
+		return &ImageTooLargeError{Bytes: len(data), Width: cfg.Width, Height: cfg.Height, Limits: limits}
+	}
+	return nil
+}
+
+// PrepareImage validates a JPEG, PNG, GIF or WebP image against the limits
+// of a model and downscales and re-encodes it when it exceeds them, so that
+// requests are not rejected as too large and do not upload pixels the
+// provider discards.
+func PrepareImage(data []byte, opts ...ImageOption) (PreparedImage, error) {
+	c := imageConfig{limits: OpenAIImageLimits, quality: 85, maxPixels: defaultMaxDecodedPixels}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return PreparedImage{}, fmt.Errorf("workflowai: decoding image: %w", err)
+	}
+	tooLarge := exceeds(len(data), cfg.Width, cfg.Height, c.limits)
+	if !tooLarge && (c.format == "" || c.format == format) {
+		return PreparedImage{Data: data, MimeType: "image/" + format, Width: cfg.Width, Height: cfg.Height}, nil
+	}
+	if tooLarge && c.noResize {
+		return PreparedImage{}, &ImageTooLargeError{Bytes: len(data), Width: cfg.Width, Height: cfg.Height, Limits: c.limits}
+	}
+	// The header is checked before the pixels are allocated
+	if c.maxPixels > 0 && int64(cfg.Width)*int64(cfg.Height) > int64(c.maxPixels) {
+		return PreparedImage{}, fmt.Errorf("workflowai: image of %dx%d pixels exceeds the limit of %d decoded pixels", cfg.Width, cfg.Height, c.maxPixels)
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return PreparedImage{}, fmt.Errorf("workflowai: decoding image: %w", err)
+	}
+	img := src
+	if width, height := fitImage(cfg.Width, cfg.Height, c.limits); width != cfg.Width || height != cfg.Height {
+		dst := image.NewRGBA(image.Rect(0, 0, width, height))
+		draw.CatmullRom.Scale(dst, dst.Bounds(), src, src.Bounds(), draw.Src, nil)
+		img = dst
+	}
+	bounds := img.Bounds()
+	out := PreparedImage{Width: bounds.Dx(), Height: bounds.Dy(), Reencoded: true}
+
+	target := c.format
+	if target == "" {
+		target = "jpeg"
+		if !opaque(img) {
+			target = "png"
+		}
+	}
+	var buf bytes.Buffer
+	switch target {
+	case "png":
+		if err := png.Encode(&buf, img); err != nil {
+			return PreparedImage{}, fmt.Errorf("workflowai: encoding image: %w", err)
+		}
+	case "jpeg":
+		// Lower the quality until the image fits
+		for quality := c.quality; ; quality -= 15 {
+			buf.Reset()
+			if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+				return PreparedImage{}, fmt.Errorf("workflowai: encoding image: %w", err)
+			}
+			if c.limits.MaxBytes <= 0 || buf.Len() <= c.limits.MaxBytes || quality <= 40 {
+				break
+			}
+		}
+	default:
+		return PreparedImage{}, fmt.Errorf("workflowai: unsupported image format %q", target)
+	}
+	out.Data, out.MimeType = buf.Bytes(), "image/"+target
+	if c.limits.MaxBytes > 0 && len(out.Data) > c.limits.MaxBytes {
+		return PreparedImage{}, &ImageTooLargeError{Bytes: len(out.Data), Width: out.Width, Height: out.Height, Limits: c.limits}
+	}
+	return out, nil
+}
+
+// PrepareImagePart is [PrepareImage] returning an inline content part.
+func PrepareImagePart(data []byte, detail string, opts ...ImageOption) (openai.ChatCompletionContentPartUnionParam, error) {
+	img, err := PrepareImage(data, opts...)
+	if err != nil {
+		return openai.ChatCompletionContentPartUnionParam{}, err
+	}
+	return img.Part(detail), nil
+}
+
+// ImageTokens estimates the input tokens of an image for the OpenAI models:
+// 85 tokens for a "low" detail, plus 170 tokens per 512 pixels tile of the
+// scaled image otherwise.
+func ImageTokens(width, height int, detail string) int {
+	if detail == "low" {
+		return 85
+	}
+	w, h := fitImage(width, height, OpenAIImageLimits)
+	tiles := int(math.Ceil(float64(w)/512) * math.Ceil(float64(h)/512))
+	return 85 + 170*tiles
+}
+
+func exceeds(size, width, height int, limits ImageLimits) bool {
+	if limits.MaxBytes > 0 && size > limits.MaxBytes {
+		return true
+	}
+	w, h := fitImage(width, height, limits)
+	return w != width || h != height
+}
+
+// fitImage returns the dimensions of an image scaled down to the limits,
+// keeping its aspect ratio.
+func fitImage(width, height int, limits ImageLimits) (int, int) {
+	scale := 1.0
+	long, short := max(width, height), min(width, height)
+	if limits.MaxLongSide > 0 && long > limits.MaxLongSide {
+		scale = float64(limits.MaxLongSide) / float64(long)
+	}
+	if limits.MaxShortSide > 0 && float64(short)*scale > float64(limits.MaxShortSide) {
+		scale = float64(limits.MaxShortSide) / float64(short)
+	}
+	if scale == 1 {
+		return width, height
+	}
+	return max(1, int(math.Round(float64(width)*scale))), max(1, int(math.Round(float64(height)*scale)))
+}
+
+func opaque(img image.Image) bool {
+	if o, ok := img.(interface{ Opaque() bool }); ok {
+		return o.Opaque()
+	}
+	return true
+}
@@ -0,0 +1,109 @@
+package workflowai
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func testPNG(t *testing.T, width, height int, alpha uint8) []byte {
+	t.Helper()
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.NRGBA{uint8(x), uint8(y), uint8(x * y), alpha})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestPrepareImage(t *testing.T) {
+	small := testPNG(t, 100, 50, 255)
+	img, err := PrepareImage(small)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Reencoded || img.MimeType != "image/png" || !bytes.Equal(img.Data, small) {
+		t.Errorf("expected the image to be kept, got %s %dx%d", img.MimeType, img.Width, img.Height)
+	}
+
+	large := testPNG(t, 3000, 1000, 255)
+	img, err = PrepareImage(large)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !img.Reencoded || img.MimeType != "image/jpeg" || img.Width != 2048 || img.Height != 683 {
+		t.Errorf("unexpected image %s %dx%d", img.MimeType, img.Width, img.Height)
+	}
+	if cfg, format, err := image.DecodeConfig(bytes.NewReader(img.Data)); err != nil || format != "jpeg" || cfg.Width != 2048 {
+		t.Errorf("unexpected encoded image %s %+v %v", format, cfg, err)
+	}
+
+	img, err = PrepareImage(testPNG(t, 2000, 2000, 128), WithImageLimits(AnthropicImageLimits))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.MimeType != "image/png" || img.Width != 1568 || img.Height != 1568 {
+		t.Errorf("expected a transparent image to stay a png, got %s %dx%d", img.MimeType, img.Width, img.Height)
+	}
+
+	var tooLarge *ImageTooLargeError
+	if _, err := PrepareImage(large, WithoutDownscale()); !errors.As(err, &tooLarge) || tooLarge.Width != 3000 {
+		t.Errorf("expected an image too large error, got %v", err)
+	}
+	if err := ValidateImage(small, ImageLimits{MaxBytes: 100}); !errors.As(err, &tooLarge) {
+		t.Errorf("expected an image too large error, got %v", err)
+	}
+	if err := ValidateImage([]byte("not an image"), OpenAIImageLimits); err == nil {
+		t.Error("expected a decoding error")
+	}
+}
+
+func TestPrepareImage_MaxDecodedPixels(t *testing.T) {
+	// A small PNG whose header declares 100000x100000 pixels
+	bomb := testPNG(t, 10, 10, 255)
+	binary.BigEndian.PutUint32(bomb[16:20], 100000)
+	binary.BigEndian.PutUint32(bomb[20:24], 100000)
+	binary.BigEndian.PutUint32(bomb[29:33], crc32.ChecksumIEEE(bomb[12:29]))
+	if _, err := PrepareImage(bomb); err == nil || !strings.Contains(err.Error(), "decoded pixels") {
+		t.Errorf("expected the image to be rejected before decoding, got %v", err)
+	}
+	if _, err := PrepareImage(testPNG(t, 3000, 1000, 255), WithMaxDecodedPixels(1000*1000)); err == nil {
+		t.Error("expected the limit to apply")
+	}
+}
+
+func TestImageLimitsFor(t *testing.T) {
+	if ImageLimitsFor("my-agent/claude-3-7-sonnet-latest") != AnthropicImageLimits {
+		t.Error("expected the Anthropic limits")
+	}
+	if ImageLimitsFor("gpt-4o-mini-latest") != OpenAIImageLimits {
+		t.Error("expected the OpenAI limits")
+	}
+}
+
+func TestImageTokens(t *testing.T) {
+	for _, tc := range []struct {
+		width, height int
+		detail        string
+		want          int
+	}{
+		{4096, 8192, "low", 85},
+		{1024, 1024, "high", 765},
+		{2048, 4096, "high", 1105},
+	} {
+		if got := ImageTokens(tc.width, tc.height, tc.detail); got != tc.want {
+			t.Errorf("ImageTokens(%d, %d, %q) = %d, want %d", tc.width, tc.height, tc.detail, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package workflowai
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+	"github.com/openai/openai-go/packages/ssestream"
+)
+
+// ImageService generates images with the OpenAI API: WorkflowAI does not
+// serve the image endpoints, so the service uses a client of the OpenAI API,
+// see [OpenAIClientOptions], and the generations are not recorded as runs.
+// Generate, Edit and NewVariation are those of the embedded service.
+type ImageService struct {
+	openai.ImageService
+	client openai.Client
+}
+
+// NewImageService returns the image service of client, e.g.
+// openai.NewClient(workflowai.OpenAIClientOptions()...).
+func NewImageService(client openai.Client) ImageService {
+	return ImageService{ImageService: client.Images, client: client}
+}
+
+// Image stream event types
+const (
+	ImageEventPartial   = "image_generation.partial_image"
+	ImageEventCompleted = "image_generation.completed"
+)
+
+// ImageEvent is an event of a streamed image generation: a partial image
+// rendered while the generation progresses, then the final image.
+type ImageEvent struct {
+	Type string `json:"type"`
+	// B64JSON is the base64 encoded image
+	B64JSON           string `json:"b64_json"`
+	PartialImageIndex int    `json:"partial_image_index"`
+	OutputFormat      string `json:"output_format,omitempty"`
+	Size              string `json:"size,omitempty"`
+	Quality           string `json:"quality,omitempty"`
+}
+
+// Image returns the decoded image of the event.
+func (e ImageEvent) Image() ([]byte, error) {
+	return base64.StdEncoding.DecodeString(e.B64JSON)
+}
+
+// ImageStream reads the events of a streamed image generation.
+type ImageStream struct {
+	res     *http.Response
+	decoder ssestream.Decoder
+	current ImageEvent
+	err     error
+}
+
+// GenerateStreaming generates an image, streaming up to partialImages
+// partial images while it is rendered. Streaming is only supported by some
+// models, e.g. gpt-image-1.
+func (s *ImageService) GenerateStreaming(ctx context.Context, params openai.ImageGenerateParams, partialImages int, opts ...option.RequestOption) *ImageStream {
+	opts = append([]option.RequestOption{
+		option.WithJSONSet("stream", true),
+		option.WithJSONSet("partial_images", partialImages),
+	}, opts...)
+	var res *http.Response
+	err := s.client.Post(ctx, "images/generations", params, &res, opts...)
+	if err != nil {
+		return &ImageStream{err: err}
+	}
+	return &ImageStream{res: res, decoder: ssestream.NewDecoder(res)}
+}
+
+// Next advances to the next event and returns false at the end of the stream
+// or on error.
+func (s *ImageStream) Next() bool {
+	if s.err != nil || s.decoder == nil {
+		return false
+	}
+	for s.decoder.Next() {
+		data := s.decoder.Event().Data
+		if len(data) == 0 {
+			continue
+		}
+		var event struct {
+			ImageEvent
+			Error *struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal(data, &event); err != nil {
+			s.err = fmt.Errorf("workflowai: decoding image event: %w", err)
+			return false
+		}
+		if event.Error != nil {
+			s.err = fmt.Errorf("workflowai: generating image: %s", event.Error.Message)
+			return false
+		}
+		if event.Type == "" {
+			event.Type = s.decoder.Event().Type
+		}
+		s.current = event.ImageEvent
+		return true
+	}
+	s.err = s.decoder.Err()
+	return false
+}
+
+func (s *ImageStream) Current() ImageEvent {
+	return s.current
+}
+
+func (s *ImageStream) Err() error {
+	return s.err
+}
+
+func (s *ImageStream) Close() error {
+	if s.res == nil {
+		return nil
+	}
+	return s.res.Body.Close()
+}
+
+// SaveImages writes the images of res to dir, named <prefix>-<index>.<ext>,
+// and returns their paths. Images returned as URLs are downloaded with
+// http.DefaultClient. ext is the output format of the request, e.g. "png".
+func SaveImages(ctx context.Context, res *openai.ImagesResponse, dir string, prefix string, ext string) ([]string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	var paths []string
+	for i, image := range res.Data {
+		var data []byte
+		var err error
+		switch {
+		case image.B64JSON != "":
+			data, err = base64.StdEncoding.DecodeString(image.B64JSON)
+		case image.URL != "":
+			data, err = downloadImage(ctx, image.URL)
+		default:
+			err = errors.New("image has no data")
+		}
+		if err != nil {
+			return paths, fmt.Errorf("workflowai: saving image %d: %w", i, err)
+		}
+		path := filepath.Join(dir, fmt.Sprintf("%s-%d.%s", prefix, i, ext))
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			return paths, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+func downloadImage(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("downloading %s: %s", url, res.Status)
+	}
+	return io.ReadAll(res.Body)
+}
@@ -0,0 +1,77 @@
+package workflowai
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+func TestImageService(t *testing.T) {
+	png := base64.StdEncoding.EncodeToString([]byte("png"))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if r.URL.Path == "/image.png" {
+			w.Write([]byte("downloaded"))
+			return
+		}
+		if body["stream"] == true {
+			w.Header().Set("Content-Type", "text/event-stream")
+			for i := range int(body["partial_images"].(float64)) {
+				fmt.Fprintf(w, "event: %s\ndata: {\"type\":%q,\"b64_json\":%q,\"partial_image_index\":%d}\n\n", ImageEventPartial, ImageEventPartial, png, i)
+			}
+			fmt.Fprintf(w, "event: %s\ndata: {\"type\":%q,\"b64_json\":%q}\n\n", ImageEventCompleted, ImageEventCompleted, png)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"created": 1, "data": []map[string]any{{"b64_json": png}, {"url": "http://" + r.Host + "/image.png"}}})
+	}))
+	defer server.Close()
+	images := NewImageService(openai.NewClient(option.WithBaseURL(server.URL+"/v1"), option.WithAPIKey("test"), option.WithMaxRetries(0)))
+	ctx := context.Background()
+	params := openai.ImageGenerateParams{Prompt: "A lighthouse", Model: "gpt-image-1", Size: openai.ImageGenerateParamsSize1024x1024}
+
+	res, err := images.Generate(ctx, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	paths, err := SaveImages(ctx, res, dir, "lighthouse", "png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) != 2 || paths[1] != filepath.Join(dir, "lighthouse-1.png") {
+		t.Fatalf("unexpected paths %v", paths)
+	}
+	if data, _ := os.ReadFile(paths[0]); string(data) != "png" {
+		t.Errorf("unexpected decoded image %q", data)
+	}
+	if data, _ := os.ReadFile(paths[1]); string(data) != "downloaded" {
+		t.Errorf("unexpected downloaded image %q", data)
+	}
+
+	stream := images.GenerateStreaming(ctx, params, 2)
+	defer stream.Close()
+	var types []string
+	for stream.Next() {
+		event := stream.Current()
+		if image, err := event.Image(); err != nil || string(image) != "png" {
+			t.Errorf("unexpected image %q: %v", image, err)
+		}
+		types = append(types, event.Type)
+	}
+	if err := stream.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(types) != fmt.Sprint([]string{ImageEventPartial, ImageEventPartial, ImageEventCompleted}) {
+		t.Errorf("unexpected events %v", types)
+	}
+}
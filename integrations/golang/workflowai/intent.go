@@ -0,0 +1,415 @@
+package workflowai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+	"github.com/openai/openai-go/packages/ssestream"
+)
+
+// Route is a specialized agent of an [IntentRouter].
+type Route struct {
+	// Name is recorded in the "route" metadata of the runs, e.g. "billing"
+	Name string
+	// Description tells the classifier which requests the route handles
+	Description string
+	// Examples are typical requests of the route, used by
+	// [EmbeddingClassifier]
+	Examples []string
+	// Model replaces the model of the requests routed to the route, e.g.
+	// "billing-agent/#1/production"
+	Model string
+}
+
+// Classification is the route chosen by a [Classifier] for a request.
+type Classification struct {
+	Route string
+	// Confidence is between 0 and 1
+	Confidence float64
+}
+
+// Classifier picks the route of a request.
+type Classifier interface {
+	Classify(ctx context.Context, text string, routes []Route) (Classification, error)
+}
+
+// ClassifierFunc adapts a function to the Classifier interface.
+type ClassifierFunc func(ctx context.Context, text string, routes []Route) (Classification, error)
+
+func (f ClassifierFunc) Classify(ctx context.Context, text string, routes []Route) (Classification, error) {
+	return f(ctx, text, routes)
+}
+
+// ModelClassifier classifies requests with a chat completion of model,
+// typically a cheap model such as "router/gpt-4o-mini-latest", choosing a
+// route from their names and descriptions.
+func ModelClassifier(completions ChatCompleter, model string) Classifier {
+	return ClassifierFunc(func(ctx context.Context, text string, routes []Route) (Classification, error) {
+		names := make([]string, len(routes))
+		var descriptions strings.Builder
+		for i, route := range routes {
+			names[i] = route.Name
+			fmt.Fprintf(&descriptions, "- %s: %s\n", route.Name, route.Description)
+		}
+		params := openai.ChatCompletionNewParams{
+			Model: model,
+			Messages: []openai.ChatCompletionMessageParamUnion{
+				openai.SystemMessage("Pick the route handling the request, and rate your confidence from 0 to 1.\n\nRoutes:\n{{routes}}"),
+				openai.UserMessage("{{request}}"),
+			},
+			ResponseFormat: openai.ChatCompletionNewParamsResponseFormatUnion{OfJSONSchema: &openai.ResponseFormatJSONSchemaParam{
+				JSONSchema: openai.ResponseFormatJSONSchemaJSONSchemaParam{
+					Name: "route",
+					Schema: map[string]any{
+						"type": "object",
+						"properties": map[string]any{
+							"route":      map[string]any{"type": "string", "enum": names},
+							"confidence": map[string]any{"type": "number", "minimum": 0, "maximum": 1},
+						},
+						"required":             []string{"route", "confidence"},
+						"additionalProperties": false,
+					},
+					Strict: openai.Bool(true),
+				},
+			}},
+			Temperature: openai.Float(0),
+		}
+		params.SetExtraFields(map[string]any{"input": map[string]any{"routes": descriptions.String(), "request": text}})
+		completion, err := completions.New(ctx, params)
+		if err != nil {
+			return Classification{}, err
+		}
+		if len(completion.Choices) == 0 {
+			return Classification{}, errors.New("workflowai: completion has no choices")
+		}
+		var out struct {
+			Route      string  `json:"route"`
+			Confidence float64 `json:"confidence"`
+		}
+		if err := json.Unmarshal([]byte(completion.Choices[0].Message.Content), &out); err != nil {
+			return Classification{}, fmt.Errorf("workflowai: decoding the classification: %w", err)
+		}
+		return Classification{Route: out.Route, Confidence: out.Confidence}, nil
+	})
+}
+
+// EmbeddingClassifier classifies requests by the cosine similarity of their
+// embedding with the embeddings of the description and examples of the
+// routes, computed once per set of routes. The confidence is the best
+// similarity.
+func EmbeddingClassifier(embed func(ctx context.Context, texts []string) ([][]float64, error)) Classifier {
+	c := &embeddingClassifier{embed: embed}
+	return ClassifierFunc(c.classify)
+}
+
+type embeddingClassifier struct {
+	embed func(ctx context.Context, texts []string) ([][]float64, error)
+
+	mu    sync.Mutex
+	cache map[string]routeReferences
+}
+
+// routeReferences are the embeddings of the description and examples of a
+// set of routes, and the route of each.
+type routeReferences struct {
+	vectors [][]float64
+	routes  []string
+}
+
+// references returns the embeddings of the routes and the route of each,
+// computed on the first request with the same routes.
+func (c *embeddingClassifier) references(ctx context.Context, routes []Route) ([][]float64, []string, error) {
+	var texts, names []string
+	for _, route := range routes {
+		for _, text := range append([]string{route.Description}, route.Examples...) {
+			if text != "" {
+				texts = append(texts, text)
+				names = append(names, route.Name)
+			}
+		}
+	}
+	key, _ := json.Marshal([][]string{names, texts})
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if refs, ok := c.cache[string(key)]; ok {
+		return refs.vectors, refs.routes, nil
+	}
+	vectors, err := c.embed(ctx, texts)
+	if err != nil {
+		return nil, nil, fmt.Errorf("workflowai: embedding the routes: %w", err)
+	}
+	if len(vectors) != len(texts) {
+		return nil, nil, fmt.Errorf("workflowai: got %d embeddings for %d route examples", len(vectors), len(texts))
+	}
+	if c.cache == nil {
+		c.cache = map[string]routeReferences{}
+	}
+	c.cache[string(key)] = routeReferences{vectors: vectors, routes: names}
+	return vectors, names, nil
+}
+
+func (c *embeddingClassifier) classify(ctx context.Context, text string, routes []Route) (Classification, error) {
+	references, names, err := c.references(ctx, routes)
+	if err != nil {
+		return Classification{}, err
+	}
+	vectors, err := c.embed(ctx, []string{text})
+	if err != nil {
+		return Classification{}, err
+	}
+	if len(vectors) != 1 {
+		return Classification{}, errors.New("workflowai: the embedder did not return an embedding")
+	}
+	best := Classification{Confidence: -1}
+	for i, reference := range references {
+		if similarity := cosineSimilarity(vectors[0], reference); similarity > best.Confidence {
+			best = Classification{Route: names[i], Confidence: similarity}
+		}
+	}
+	best.Confidence = max(best.Confidence, 0)
+	return best, nil
+}
+
+func cosineSimilarity(a []float64, b []float64) float64 {
+	var dot, na, nb float64
+	for i := range min(len(a), len(b)) {
+		dot += a[i] * b[i]
+		na += a[i] * a[i]
+		nb += b[i] * b[i]
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
+
+// RouteStats are the counters of a route since the creation of the router.
+type RouteStats struct {
+	Requests int64
+	Errors   int64
+	// Fallbacks counts the requests routed to the default route because the
+	// classification failed or was not confident enough
+	Fallbacks int64
+	CostUSD   float64
+	// Latency is the total latency of the requests, streams included
+	Latency time.Duration
+}
+
+type routeCounters struct {
+	Route
+
+	mu    sync.Mutex
+	stats RouteStats
+}
+
+// routeRequest counts a request once in the counters of its route, whatever
+// the number of attempts the client made to send it.
+type routeRequest struct {
+	counters *routeCounters
+	start    time.Time
+
+	mu      sync.Mutex
+	costUSD float64
+	done    bool
+}
+
+// RecordRun implements RunLogStore so that the cost of the attempts is fed
+// by the run log middleware. A successful streamed response is the last
+// attempt of a stream, which completes the request when it ends.
+func (q *routeRequest) RecordRun(_ context.Context, record RunRecord) error {
+	q.mu.Lock()
+	q.costUSD += record.CostUSD
+	q.mu.Unlock()
+	if record.Stream && record.StatusCode > 0 && record.StatusCode < 400 {
+		q.finish(record.Error != "")
+	}
+	return nil
+}
+
+// finish counts the request, once.
+func (q *routeRequest) finish(failed bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.done {
+		return
+	}
+	q.done = true
+	c := q.counters
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stats.Requests++
+	if failed {
+		c.stats.Errors++
+	}
+	c.stats.CostUSD += q.costUSD
+	c.stats.Latency += time.Since(q.start)
+}
+
+// IntentRouter dispatches chat completions to specialized agents: the last
+// user message of each request is classified, and the request is sent to the
+// model of its route. Requests fall back to the default route when the
+// classification fails, names an unknown route or is less confident than the
+// minimum confidence.
+//
+// IntentRouter implements [ChatService] and can be used wherever a
+// ChatCompleter is expected, e.g. in a [ConversationManager].
+type IntentRouter struct {
+	completions   ChatService
+	classifier    Classifier
+	routes        []Route
+	counters      map[string]*routeCounters
+	fallback      *routeCounters
+	minConfidence float64
+}
+
+var _ ChatService = (*IntentRouter)(nil)
+
+type IntentRouterOption func(*IntentRouter)
+
+// WithDefaultRoute sets the route of the requests that cannot be classified.
+// Without a default route, these requests fail.
+func WithDefaultRoute(route Route) IntentRouterOption {
+	return func(r *IntentRouter) {
+		r.fallback = &routeCounters{Route: route}
+	}
+}
+
+// WithMinConfidence sets the confidence below which requests are sent to the
+// default route.
+func WithMinConfidence(confidence float64) IntentRouterOption {
+	return func(r *IntentRouter) {
+		r.minConfidence = confidence
+	}
+}
+
+func NewIntentRouter(completions ChatService, classifier Classifier, routes []Route, opts ...IntentRouterOption) *IntentRouter {
+	r := &IntentRouter{completions: completions, classifier: classifier, routes: routes, counters: map[string]*routeCounters{}}
+	for _, route := range routes {
+		r.counters[route.Name] = &routeCounters{Route: route}
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	if r.fallback != nil {
+		if counters, ok := r.counters[r.fallback.Name]; ok {
+			r.fallback = counters
+		} else {
+			r.counters[r.fallback.Name] = r.fallback
+		}
+	}
+	return r
+}
+
+// Route returns the route of a request whose last user message is text, and
+// the classification, which is empty when the classifier failed.
+func (r *IntentRouter) Route(ctx context.Context, text string) (Route, Classification, error) {
+	route, classification, _, err := r.classify(ctx, text)
+	if err != nil {
+		return Route{}, classification, err
+	}
+	return route.Route, classification, nil
+}
+
+func (r *IntentRouter) classify(ctx context.Context, text string) (*routeCounters, Classification, bool, error) {
+	classification, err := r.classifier.Classify(ctx, text, r.routes)
+	counters, ok := r.counters[classification.Route]
+	if err == nil && ok && classification.Confidence >= r.minConfidence {
+		return counters, classification, false, nil
+	}
+	if r.fallback == nil {
+		if err == nil {
+			err = fmt.Errorf("route %q with confidence %.2f", classification.Route, classification.Confidence)
+		}
+		return nil, classification, false, fmt.Errorf("workflowai: no route for the request: %w", err)
+	}
+	r.fallback.mu.Lock()
+	r.fallback.stats.Fallbacks++
+	r.fallback.mu.Unlock()
+	return r.fallback, classification, true, nil
+}
+
+// Stats returns the counters of each route.
+func (r *IntentRouter) Stats() map[string]RouteStats {
+	stats := make(map[string]RouteStats, len(r.counters))
+	for name, counters := range r.counters {
+		counters.mu.Lock()
+		stats[name] = counters.stats
+		counters.mu.Unlock()
+	}
+	return stats
+}
+
+func (r *IntentRouter) route(ctx context.Context, body *openai.ChatCompletionNewParams, opts []option.RequestOption) ([]option.RequestOption, *routeRequest, error) {
+	route, classification, fallback, err := r.classify(ctx, lastUserText(body.Messages))
+	if err != nil {
+		return nil, nil, err
+	}
+	if route.Model != "" {
+		body.Model = route.Model
+	}
+	request := &routeRequest{counters: route, start: time.Now()}
+	opts = append(opts,
+		option.WithJSONSet("metadata.route", route.Name),
+		option.WithJSONSet("metadata.route_confidence", strconv.FormatFloat(classification.Confidence, 'f', 2, 64)),
+		option.WithMiddleware(RunLogMiddleware(request, WithRunLogContentLimit(1))),
+	)
+	if fallback {
+		opts = append(opts, option.WithJSONSet("metadata.route_fallback", "true"))
+	}
+	return opts, request, nil
+}
+
+func (r *IntentRouter) New(ctx context.Context, body openai.ChatCompletionNewParams, opts ...option.RequestOption) (*openai.ChatCompletion, error) {
+	opts, request, err := r.route(ctx, &body, opts)
+	if err != nil {
+		return nil, err
+	}
+	completion, err := r.completions.New(ctx, body, opts...)
+	request.finish(err != nil)
+	return completion, err
+}
+
+func (r *IntentRouter) NewStreaming(ctx context.Context, body openai.ChatCompletionNewParams, opts ...option.RequestOption) *ssestream.Stream[openai.ChatCompletionChunk] {
+	opts, request, err := r.route(ctx, &body, opts)
+	if err != nil {
+		return ssestream.NewStream[openai.ChatCompletionChunk](nil, err)
+	}
+	stream := r.completions.NewStreaming(ctx, body, opts...)
+	// The attempts are over when the stream fails before its first chunk,
+	// otherwise the request completes when the stream ends
+	if stream.Err() != nil {
+		request.finish(true)
+	}
+	return stream
+}
+
+// lastUserText returns the text of the last user message.
+func lastUserText(messages []openai.ChatCompletionMessageParamUnion) string {
+	for i := len(messages) - 1; i >= 0; i-- {
+		user := messages[i].OfUser
+		if user == nil {
+			continue
+		}
+		if user.Content.OfString.Valid() {
+			return user.Content.OfString.Value
+		}
+		var text strings.Builder
+		for _, part := range user.Content.OfArrayOfContentParts {
+			if part.OfText != nil {
+				text.WriteString(part.OfText.Text)
+			}
+		}
+		return text.String()
+	}
+	return ""
+}
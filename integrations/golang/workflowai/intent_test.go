@@ -0,0 +1,196 @@
+package workflowai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+func TestIntentRouter(t *testing.T) {
+	var mu sync.Mutex
+	var routed []map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		content := "ok"
+		if body["model"] == "router/gpt-4o-mini" {
+			request := body["input"].(map[string]any)["request"].(string)
+			switch {
+			case strings.Contains(request, "invoice"):
+				content = `{"route": "billing", "confidence": 0.9}`
+			case strings.Contains(request, "bug"):
+				content = `{"route": "support", "confidence": 0.3}`
+			default:
+				http.Error(w, `{"error": {"message": "classifier down"}}`, http.StatusInternalServerError)
+				return
+			}
+		} else {
+			mu.Lock()
+			routed = append(routed, body)
+			mu.Unlock()
+		}
+		writeJSON(w, http.StatusOK, map[string]any{
+			"id":      "agent/run-1",
+			"choices": []any{map[string]any{"index": 0, "cost_usd": 0.01, "message": map[string]any{"role": "assistant", "content": content}}},
+		})
+	}))
+	defer server.Close()
+	client := NewClient(option.WithBaseURL(server.URL+"/v1"), option.WithAPIKey("test"), option.WithMaxRetries(0))
+
+	routes := []Route{
+		{Name: "billing", Description: "Invoices and payments", Model: "billing/#1/production"},
+		{Name: "support", Description: "Bugs and outages", Model: "support/#1/production"},
+	}
+	router := NewIntentRouter(&client.Chat.Completions, ModelClassifier(&client.Chat.Completions, "router/gpt-4o-mini"), routes,
+		WithDefaultRoute(Route{Name: "general", Model: "general/#1/production"}),
+		WithMinConfidence(0.5))
+
+	ctx := context.Background()
+	for _, text := range []string{"Where is my invoice?", "I found a bug", "Hello"} {
+		if _, err := router.New(ctx, openai.ChatCompletionNewParams{
+			Model:    "unused",
+			Messages: []openai.ChatCompletionMessageParamUnion{openai.SystemMessage("sys"), openai.UserMessage(text)},
+		}); err != nil {
+			t.Fatalf("%s: %v", text, err)
+		}
+	}
+	want := []struct{ model, route, fallback string }{
+		{"billing/#1/production", "billing", ""},
+		{"general/#1/production", "general", "true"},
+		{"general/#1/production", "general", "true"},
+	}
+	for i, w := range want {
+		metadata, _ := routed[i]["metadata"].(map[string]any)
+		fallback, _ := metadata["route_fallback"].(string)
+		if routed[i]["model"] != w.model || metadata["route"] != w.route || fallback != w.fallback {
+			t.Errorf("request %d: model %v, metadata %v", i, routed[i]["model"], metadata)
+		}
+	}
+	stats := router.Stats()
+	if stats["billing"].Requests != 1 || stats["general"].Requests != 2 || stats["general"].Fallbacks != 2 || stats["support"].Requests != 0 {
+		t.Errorf("stats = %+v", stats)
+	}
+	if stats["billing"].CostUSD != 0.01 {
+		t.Errorf("billing cost = %v", stats["billing"].CostUSD)
+	}
+
+	strict := NewIntentRouter(&client.Chat.Completions, ModelClassifier(&client.Chat.Completions, "router/gpt-4o-mini"), routes)
+	if _, _, err := strict.Route(ctx, "Hello"); err == nil || !strings.Contains(err.Error(), "no route") {
+		t.Errorf("expected an error without a default route, got %v", err)
+	}
+	stream := strict.NewStreaming(ctx, openai.ChatCompletionNewParams{Messages: []openai.ChatCompletionMessageParamUnion{openai.UserMessage("Hello")}})
+	if stream.Next() || stream.Err() == nil {
+		t.Error("expected the stream to fail")
+	}
+}
+
+func TestEmbeddingClassifier(t *testing.T) {
+	vectors := map[string][]float64{
+		"Invoices":        {1, 0, 0},
+		"Refund please":   {0.9, 0.1, 0},
+		"Outages":         {0, 1, 0},
+		"Where is my $":   {0.8, 0.2, 0},
+		"The app crashes": {0.1, 0.9, 0.1},
+	}
+	calls := 0
+	classifier := EmbeddingClassifier(func(ctx context.Context, texts []string) ([][]float64, error) {
+		calls++
+		out := make([][]float64, len(texts))
+		for i, text := range texts {
+			v, ok := vectors[text]
+			if !ok {
+				return nil, errors.New("unknown text " + text)
+			}
+			out[i] = v
+		}
+		return out, nil
+	})
+	routes := []Route{
+		{Name: "billing", Description: "Invoices", Examples: []string{"Refund please"}},
+		{Name: "support", Description: "Outages"},
+	}
+	for text, want := range map[string]string{"Where is my $": "billing", "The app crashes": "support"} {
+		c, err := classifier.Classify(context.Background(), text, routes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.Route != want || c.Confidence < 0.9 {
+			t.Errorf("%s: %+v, want %s", text, c, want)
+		}
+	}
+	// The routes are embedded once
+	if calls != 3 {
+		t.Errorf("expected 3 embedding calls, got %d", calls)
+	}
+
+	// Other routes are embedded again, not matched against the cached ones
+	c, err := classifier.Classify(context.Background(), "Where is my $", []Route{{Name: "payments", Description: "Invoices"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Route != "payments" || calls != 5 {
+		t.Errorf("expected the new routes to be embedded, got %+v after %d calls", c, calls)
+	}
+}
+
+func TestIntentRouter_CountsRequestsOnce(t *testing.T) {
+	var mu sync.Mutex
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		mu.Lock()
+		attempts++
+		failed := attempts%2 == 1
+		mu.Unlock()
+		if failed {
+			http.Error(w, `{"error": {"message": "overloaded"}}`, http.StatusServiceUnavailable)
+			return
+		}
+		if body["stream"] == true {
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprint(w, "data: {\"id\":\"agent/run-2\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"ok\"},\"cost_usd\":0.02}]}\n\n")
+			fmt.Fprint(w, "data: [DONE]\n\n")
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{
+			"id":      "agent/run-1",
+			"choices": []any{map[string]any{"index": 0, "cost_usd": 0.01, "message": map[string]any{"role": "assistant", "content": "ok"}}},
+		})
+	}))
+	defer server.Close()
+	client := NewClient(option.WithBaseURL(server.URL+"/v1"), option.WithAPIKey("test"), option.WithMaxRetries(1))
+	classifier := ClassifierFunc(func(context.Context, string, []Route) (Classification, error) {
+		return Classification{Route: "billing", Confidence: 1}, nil
+	})
+	router := NewIntentRouter(&client.Chat.Completions, classifier, []Route{{Name: "billing"}})
+
+	ctx := context.Background()
+	params := openai.ChatCompletionNewParams{Messages: []openai.ChatCompletionMessageParamUnion{openai.UserMessage("invoice")}}
+	if _, err := router.New(ctx, params); err != nil {
+		t.Fatal(err)
+	}
+	stream := router.NewStreaming(ctx, params)
+	for stream.Next() {
+	}
+	if err := stream.Err(); err != nil {
+		t.Fatal(err)
+	}
+	stream.Close()
+	if attempts != 4 {
+		t.Fatalf("expected each request to be retried once, got %d attempts", attempts)
+	}
+	if stats := router.Stats()["billing"]; stats.Requests != 2 || stats.Errors != 0 || math.Abs(stats.CostUSD-0.03) > 1e-9 {
+		t.Errorf("expected the requests to be counted once, got %+v", stats)
+	}
+}
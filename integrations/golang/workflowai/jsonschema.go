@@ -0,0 +1,364 @@
+package workflowai
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// SchemaError is a single violation of a JSON schema.
+type SchemaError struct {
+	// Path is a JSON path to the invalid value, e.g. "$.items[0].name"
+	Path    string
+	Message string
+}
+
+func (e SchemaError) Error() string {
+	return e.Path + ": " + e.Message
+}
+
+// SchemaErrors is returned by [ValidateJSONSchema] when the value is invalid.
+type SchemaErrors []SchemaError
+
+func (e SchemaErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// ValidateJSONSchema validates data against a JSON schema and returns
+// [SchemaErrors] when it does not match.
+//
+// The validator supports the subset of JSON schema used for structured
+// outputs: type, properties, required, additionalProperties, items, enum,
+// const, numeric and length bounds, pattern, anyOf, oneOf, allOf and local
+// references ("#", "#/$defs/..." and "#/definitions/...").
+func ValidateJSONSchema(schema json.RawMessage, data json.RawMessage) error {
+	var s map[string]any
+	if err := json.Unmarshal(schema, &s); err != nil {
+		return fmt.Errorf("workflowai: invalid schema: %w", err)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var v any
+	if err := decoder.Decode(&v); err != nil {
+		return SchemaErrors{{Path: "$", Message: "invalid JSON: " + err.Error()}}
+	}
+
+	validator := &schemaValidator{root: s}
+	validator.validate(s, v, "$")
+	if len(validator.errors) > 0 {
+		return validator.errors
+	}
+	return nil
+}
+
+type schemaValidator struct {
+	root   map[string]any
+	errors SchemaErrors
+}
+
+func (sv *schemaValidator) fail(path string, format string, args ...any) {
+	sv.errors = append(sv.errors, SchemaError{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+func (sv *schemaValidator) resolve(ref string) (map[string]any, bool) {
+	if ref == "#" {
+		return sv.root, true
+	}
+	if !strings.HasPrefix(ref, "#/") {
+		return nil, false
+	}
+	var current any = sv.root
+	for _, part := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+		m, ok := current.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		current = m[strings.ReplaceAll(strings.ReplaceAll(part, "~1", "/"), "~0", "~")]
+	}
+	resolved, ok := current.(map[string]any)
+	return resolved, ok
+}
+
+// matches returns true if v is valid against schema without recording errors.
+func (sv *schemaValidator) matches(schema map[string]any, v any, path string) bool {
+	sub := &schemaValidator{root: sv.root}
+	sub.validate(schema, v, path)
+	return len(sub.errors) == 0
+}
+
+func (sv *schemaValidator) validate(schema map[string]any, v any, path string) {
+	if ref, ok := schema["$ref"].(string); ok {
+		resolved, ok := sv.resolve(ref)
+		if !ok {
+			sv.fail(path, "unresolved reference %s", ref)
+			return
+		}
+		sv.validate(resolved, v, path)
+	}
+
+	if types := schemaTypes(schema["type"]); len(types) > 0 && !matchesType(types, v) {
+		sv.fail(path, "expected %s, got %s", strings.Join(types, " or "), jsonType(v))
+		return
+	}
+
+	if enum, ok := schema["enum"].([]any); ok {
+		found := false
+		for _, e := range enum {
+			if jsonEqual(e, v) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			sv.fail(path, "value is not one of the allowed values")
+		}
+	}
+	if c, ok := schema["const"]; ok && !jsonEqual(c, v) {
+		sv.fail(path, "value does not match the constant")
+	}
+
+	for _, sub := range schemaList(schema["allOf"]) {
+		sv.validate(sub, v, path)
+	}
+	if anyOf := schemaList(schema["anyOf"]); len(anyOf) > 0 {
+		matched := false
+		for _, sub := range anyOf {
+			if sv.matches(sub, v, path) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			sv.fail(path, "value does not match any of the allowed schemas")
+		}
+	}
+	if oneOf := schemaList(schema["oneOf"]); len(oneOf) > 0 {
+		count := 0
+		for _, sub := range oneOf {
+			if sv.matches(sub, v, path) {
+				count++
+			}
+		}
+		if count != 1 {
+			sv.fail(path, "value must match exactly one schema, matched %d", count)
+		}
+	}
+
+	switch value := v.(type) {
+	case map[string]any:
+		sv.validateObject(schema, value, path)
+	case []any:
+		sv.validateArray(schema, value, path)
+	case string:
+		sv.validateString(schema, value, path)
+	case json.Number:
+		sv.validateNumber(schema, value, path)
+	}
+}
+
+func (sv *schemaValidator) validateObject(schema map[string]any, obj map[string]any, path string) {
+	if required, ok := schema["required"].([]any); ok {
+		for _, r := range required {
+			name, _ := r.(string)
+			if _, ok := obj[name]; !ok {
+				sv.fail(path, "missing required property %q", name)
+			}
+		}
+	}
+
+	properties, _ := schema["properties"].(map[string]any)
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	// Sorting for stable error ordering
+	sort.Strings(keys)
+	for _, k := range keys {
+		childPath := path + "." + k
+		if propSchema, ok := properties[k].(map[string]any); ok {
+			sv.validate(propSchema, obj[k], childPath)
+			continue
+		}
+		switch additional := schema["additionalProperties"].(type) {
+		case bool:
+			if !additional {
+				sv.fail(childPath, "additional property is not allowed")
+			}
+		case map[string]any:
+			sv.validate(additional, obj[k], childPath)
+		}
+	}
+}
+
+func (sv *schemaValidator) validateArray(schema map[string]any, arr []any, path string) {
+	if n, ok := schemaNumber(schema["minItems"]); ok && float64(len(arr)) < n {
+		sv.fail(path, "expected at least %v items, got %d", n, len(arr))
+	}
+	if n, ok := schemaNumber(schema["maxItems"]); ok && float64(len(arr)) > n {
+		sv.fail(path, "expected at most %v items, got %d", n, len(arr))
+	}
+	if items, ok := schema["items"].(map[string]any); ok {
+		for i, item := range arr {
+			sv.validate(items, item, fmt.Sprintf("%s[%d]", path, i))
+		}
+	}
+}
+
+func (sv *schemaValidator) validateString(schema map[string]any, s string, path string) {
+	length := float64(utf8.RuneCountInString(s))
+	if n, ok := schemaNumber(schema["minLength"]); ok && length < n {
+		sv.fail(path, "expected at least %v characters", n)
+	}
+	if n, ok := schemaNumber(schema["maxLength"]); ok && length > n {
+		sv.fail(path, "expected at most %v characters", n)
+	}
+	if pattern, ok := schema["pattern"].(string); ok {
+		re, err := compilePattern(pattern)
+		if err != nil {
+			sv.fail(path, "invalid pattern %q in schema", pattern)
+		} else if !re.MatchString(s) {
+			sv.fail(path, "value does not match pattern %q", pattern)
+		}
+	}
+}
+
+func (sv *schemaValidator) validateNumber(schema map[string]any, n json.Number, path string) {
+	f, err := n.Float64()
+	if err != nil {
+		sv.fail(path, "invalid number")
+		return
+	}
+	if min, ok := schemaNumber(schema["minimum"]); ok && f < min {
+		sv.fail(path, "expected a value >= %v", min)
+	}
+	if max, ok := schemaNumber(schema["maximum"]); ok && f > max {
+		sv.fail(path, "expected a value <= %v", max)
+	}
+	if min, ok := schemaNumber(schema["exclusiveMinimum"]); ok && f <= min {
+		sv.fail(path, "expected a value > %v", min)
+	}
+	if max, ok := schemaNumber(schema["exclusiveMaximum"]); ok && f >= max {
+		sv.fail(path, "expected a value < %v", max)
+	}
+	if m, ok := schemaNumber(schema["multipleOf"]); ok && m > 0 {
+		if q := f / m; math.Abs(q-math.Round(q)) > 1e-9 {
+			sv.fail(path, "expected a multiple of %v", m)
+		}
+	}
+}
+
+func schemaTypes(t any) []string {
+	switch t := t.(type) {
+	case string:
+		return []string{t}
+	case []any:
+		types := make([]string, 0, len(t))
+		for _, s := range t {
+			if s, ok := s.(string); ok {
+				types = append(types, s)
+			}
+		}
+		return types
+	}
+	return nil
+}
+
+func schemaList(v any) []map[string]any {
+	list, _ := v.([]any)
+	out := make([]map[string]any, 0, len(list))
+	for _, item := range list {
+		if m, ok := item.(map[string]any); ok {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+func schemaNumber(v any) (float64, bool) {
+	f, ok := v.(float64)
+	return f, ok
+}
+
+func jsonType(v any) string {
+	switch n := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case json.Number:
+		if _, err := n.Int64(); err == nil {
+			return "integer"
+		}
+		return "number"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+func matchesType(types []string, v any) bool {
+	actual := jsonType(v)
+	for _, t := range types {
+		if t == actual || (t == "number" && actual == "integer") {
+			return true
+		}
+		if t == "integer" && actual == "number" {
+			// 1.0 is a valid integer
+			if f, err := v.(json.Number).Float64(); err == nil && f == math.Trunc(f) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// jsonEqual compares a value decoded from a schema (numbers as float64) with
+// a value decoded from data (numbers as json.Number).
+func jsonEqual(schemaValue any, v any) bool {
+	switch s := schemaValue.(type) {
+	case float64:
+		n, ok := v.(json.Number)
+		if !ok {
+			return false
+		}
+		f, err := n.Float64()
+		return err == nil && f == s
+	case map[string]any:
+		m, ok := v.(map[string]any)
+		if !ok || len(m) != len(s) {
+			return false
+		}
+		for k, sv := range s {
+			mv, ok := m[k]
+			if !ok || !jsonEqual(sv, mv) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		a, ok := v.([]any)
+		if !ok || len(a) != len(s) {
+			return false
+		}
+		for i := range s {
+			if !jsonEqual(s[i], a[i]) {
+				return false
+			}
+		}
+		return true
+	default:
+		return schemaValue == v
+	}
+}
@@ -0,0 +1,84 @@
+package workflowai
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+const testSchema = `{
+	"type": "object",
+	"properties": {
+		"name": {"type": "string", "minLength": 1, "pattern": "^[A-Z]"},
+		"age": {"type": "integer", "minimum": 0, "maximum": 150},
+		"kind": {"enum": ["cat", "dog"]},
+		"tags": {"type": "array", "items": {"type": "string"}, "maxItems": 2},
+		"owner": {"$ref": "#/$defs/Owner"},
+		"nickname": {"anyOf": [{"type": "string"}, {"type": "null"}]}
+	},
+	"required": ["name", "age"],
+	"additionalProperties": false,
+	"$defs": {
+		"Owner": {"type": "object", "properties": {"email": {"type": "string"}}, "required": ["email"]}
+	}
+}`
+
+func TestValidateJSONSchema(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   string
+		errors []string
+	}{
+		{
+			name: "valid",
+			data: `{"name": "Rex", "age": 3.0, "kind": "dog", "tags": ["a"], "owner": {"email": "a@b.c"}, "nickname": null}`,
+		},
+		{
+			name:   "missing required",
+			data:   `{"name": "Rex"}`,
+			errors: []string{`$: missing required property "age"`},
+		},
+		{
+			name: "invalid values",
+			data: `{"name": "rex", "age": -1.5, "kind": "cow", "tags": ["a", 1, "c"], "owner": {}, "nickname": 1, "extra": true}`,
+			errors: []string{
+				"$.age: expected integer, got number",
+				"$.extra: additional property is not allowed",
+				"$.kind: value is not one of the allowed values",
+				`$.name: value does not match pattern "^[A-Z]"`,
+				`$.nickname: value does not match any of the allowed schemas`,
+				`$.owner: missing required property "email"`,
+				"$.tags: expected at most 2 items, got 3",
+				"$.tags[1]: expected string, got integer",
+			},
+		},
+		{
+			name:   "invalid json",
+			data:   `{"name": `,
+			errors: []string{"$: invalid JSON: unexpected EOF"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := ValidateJSONSchema(json.RawMessage(testSchema), json.RawMessage(test.data))
+			if len(test.errors) == 0 {
+				if err != nil {
+					t.Fatalf("expected no error, got %v", err)
+				}
+				return
+			}
+			var schemaErrors SchemaErrors
+			if !errors.As(err, &schemaErrors) {
+				t.Fatalf("expected schema errors, got %v", err)
+			}
+			got := make([]string, len(schemaErrors))
+			for i, e := range schemaErrors {
+				got[i] = e.Error()
+			}
+			if strings.Join(got, "\n") != strings.Join(test.errors, "\n") {
+				t.Fatalf("unexpected errors:\n%s", strings.Join(got, "\n"))
+			}
+		})
+	}
+}
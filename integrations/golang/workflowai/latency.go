@@ -0,0 +1,199 @@
+package workflowai
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LatencyTracker tracks the time to first token, the duration and the
+// output tokens per second of the recent completions of each model, so that
+// model slowdowns are visible from the client side:
+//
+//	latency := workflowai.NewLatencyTracker(0)
+//	latency.Register(&hooks)
+//	go latency.LogEvery(ctx, time.Minute, log.Printf)
+//
+// Percentiles are computed over the last completions of each model, up to the
+// window size. Failed completions are not tracked. It is safe for concurrent
+// use.
+type LatencyTracker struct {
+	mu     sync.Mutex
+	window int
+	models map[string]*latencySamples
+}
+
+// latencySamples are ring buffers of the last samples of a model.
+type latencySamples struct {
+	next            int
+	count           int64
+	ttft            []float64
+	duration        []float64
+	tokensPerSecond []float64
+}
+
+// NewLatencyTracker keeps the last window completions of each model. window
+// defaults to 1000.
+func NewLatencyTracker(window int) *LatencyTracker {
+	if window <= 0 {
+		window = 1000
+	}
+	return &LatencyTracker{window: window, models: map[string]*latencySamples{}}
+}
+
+// Register tracks the completions observed by hooks.
+func (t *LatencyTracker) Register(hooks *Hooks) {
+	hooks.OnComplete(func(_ context.Context, e CompleteEvent) {
+		t.Observe(e)
+	})
+}
+
+// Observe tracks a completion.
+func (t *LatencyTracker) Observe(e CompleteEvent) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	samples, ok := t.models[e.Model]
+	if !ok {
+		samples = &latencySamples{}
+		t.models[e.Model] = samples
+	}
+	// The generation time excludes the wait for the first token when known
+	generation := e.Duration - e.TimeToFirstToken
+	tokensPerSecond := math.NaN()
+	if e.CompletionTokens > 0 && generation > 0 {
+		tokensPerSecond = float64(e.CompletionTokens) / generation.Seconds()
+	}
+	ttft := math.NaN()
+	if e.TimeToFirstToken > 0 {
+		ttft = e.TimeToFirstToken.Seconds()
+	}
+	samples.add(t.window, ttft, e.Duration.Seconds(), tokensPerSecond)
+}
+
+func (s *latencySamples) add(window int, ttft, duration, tokensPerSecond float64) {
+	s.count++
+	if len(s.duration) < window {
+		s.ttft = append(s.ttft, ttft)
+		s.duration = append(s.duration, duration)
+		s.tokensPerSecond = append(s.tokensPerSecond, tokensPerSecond)
+		return
+	}
+	s.ttft[s.next] = ttft
+	s.duration[s.next] = duration
+	s.tokensPerSecond[s.next] = tokensPerSecond
+	s.next = (s.next + 1) % window
+}
+
+// DurationPercentiles are the percentiles of a duration. They are 0 when
+// there is no sample.
+type DurationPercentiles struct {
+	P50, P95, P99 time.Duration
+}
+
+// Percentiles are the percentiles of a rate. They are 0 when there is no
+// sample.
+type Percentiles struct {
+	P50, P95, P99 float64
+}
+
+// LatencySnapshot is the latency of the recent completions of a model.
+type LatencySnapshot struct {
+	// Count is the number of completions since the tracker was created,
+	// including those that are no longer in the window
+	Count int64
+	// TimeToFirstToken only covers the streamed completions
+	TimeToFirstToken DurationPercentiles
+	Duration         DurationPercentiles
+	// TokensPerSecond is the output tokens per second, after the first token
+	// for streamed completions
+	TokensPerSecond Percentiles
+}
+
+// Snapshot returns the latency of every model.
+func (t *LatencyTracker) Snapshot() map[string]LatencySnapshot {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	snapshots := make(map[string]LatencySnapshot, len(t.models))
+	for model, samples := range t.models {
+		snapshots[model] = LatencySnapshot{
+			Count:            samples.count,
+			TimeToFirstToken: durationPercentiles(samples.ttft),
+			Duration:         durationPercentiles(samples.duration),
+			TokensPerSecond:  percentiles(samples.tokensPerSecond),
+		}
+	}
+	return snapshots
+}
+
+// LogEvery logs the snapshot of every model with logf, e.g. log.Printf, every
+// interval until ctx is done.
+func (t *LatencyTracker) LogEvery(ctx context.Context, interval time.Duration, logf func(format string, args ...any)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			snapshots := t.Snapshot()
+			models := make([]string, 0, len(snapshots))
+			for model := range snapshots {
+				models = append(models, model)
+			}
+			sort.Strings(models)
+			for _, model := range models {
+				logf("workflowai: latency %s %s", model, snapshots[model])
+			}
+		}
+	}
+}
+
+func (s LatencySnapshot) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "n=%d", s.Count)
+	if s.TimeToFirstToken != (DurationPercentiles{}) {
+		fmt.Fprintf(&b, " ttft=%s", s.TimeToFirstToken)
+	}
+	fmt.Fprintf(&b, " duration=%s tokens/s=%s", s.Duration, s.TokensPerSecond)
+	return b.String()
+}
+
+func (p DurationPercentiles) String() string {
+	return fmt.Sprintf("%s/%s/%s", p.P50.Round(time.Millisecond), p.P95.Round(time.Millisecond), p.P99.Round(time.Millisecond))
+}
+
+func (p Percentiles) String() string {
+	return fmt.Sprintf("%.1f/%.1f/%.1f", p.P50, p.P95, p.P99)
+}
+
+func durationPercentiles(samples []float64) DurationPercentiles {
+	p := percentiles(samples)
+	return DurationPercentiles{
+		P50: time.Duration(p.P50 * float64(time.Second)),
+		P95: time.Duration(p.P95 * float64(time.Second)),
+		P99: time.Duration(p.P99 * float64(time.Second)),
+	}
+}
+
+// percentiles returns the nearest rank percentiles of samples, ignoring the
+// missing (NaN) ones.
+func percentiles(samples []float64) Percentiles {
+	sorted := make([]float64, 0, len(samples))
+	for _, v := range samples {
+		if !math.IsNaN(v) {
+			sorted = append(sorted, v)
+		}
+	}
+	if len(sorted) == 0 {
+		return Percentiles{}
+	}
+	sort.Float64s(sorted)
+	rank := func(q float64) float64 {
+		return sorted[max(int(math.Ceil(q*float64(len(sorted))))-1, 0)]
+	}
+	return Percentiles{P50: rank(0.5), P95: rank(0.95), P99: rank(0.99)}
+}
@@ -0,0 +1,85 @@
+package workflowai
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+func TestLatencyTracker(t *testing.T) {
+	latency := NewLatencyTracker(100)
+	// 200 completions, only the last 100 are in the window
+	for i := 1; i <= 200; i++ {
+		latency.Observe(CompleteEvent{
+			HookRequest:      HookRequest{Model: "gpt-4o"},
+			Duration:         time.Duration(i) * 100 * time.Millisecond,
+			TimeToFirstToken: time.Duration(i) * time.Millisecond,
+			CompletionTokens: int64(i),
+		})
+	}
+	latency.Observe(CompleteEvent{HookRequest: HookRequest{Model: "o3"}, Duration: 2 * time.Second, CompletionTokens: 100})
+
+	snapshots := latency.Snapshot()
+	gpt := snapshots["gpt-4o"]
+	if gpt.Count != 200 {
+		t.Errorf("unexpected count %d", gpt.Count)
+	}
+	if gpt.Duration != (DurationPercentiles{P50: 15 * time.Second, P95: 19500 * time.Millisecond, P99: 19900 * time.Millisecond}) {
+		t.Errorf("unexpected durations %v", gpt.Duration)
+	}
+	if gpt.TimeToFirstToken.P50 != 150*time.Millisecond {
+		t.Errorf("unexpected ttft %v", gpt.TimeToFirstToken)
+	}
+	o3 := snapshots["o3"]
+	if o3.TimeToFirstToken != (DurationPercentiles{}) || o3.TokensPerSecond.P99 != 50 {
+		t.Errorf("unexpected snapshot %+v", o3)
+	}
+	if s := o3.String(); s != "n=1 duration=2s/2s/2s tokens/s=50.0/50.0/50.0" {
+		t.Errorf("unexpected log line %q", s)
+	}
+}
+
+func TestLatencyTracker_Hooks(t *testing.T) {
+	server := newTestServer(t)
+	var hooks Hooks
+	latency := NewLatencyTracker(0)
+	latency.Register(&hooks)
+	client := openai.NewClient(option.WithBaseURL(server.URL), option.WithAPIKey("test"), option.WithMiddleware(hooks.Middleware()))
+	stream := client.Chat.Completions.NewStreaming(context.Background(), openai.ChatCompletionNewParams{
+		Model:    "my-agent/gpt-4o",
+		Messages: []openai.ChatCompletionMessageParamUnion{openai.UserMessage("Hi")},
+	})
+	for stream.Next() {
+	}
+	stream.Close()
+
+	snapshot := latency.Snapshot()["my-agent/gpt-4o"]
+	if snapshot.Count != 1 || snapshot.TimeToFirstToken.P50 <= 0 || snapshot.TimeToFirstToken.P50 > snapshot.Duration.P50 {
+		t.Errorf("unexpected snapshot %+v", snapshot)
+	}
+
+	var mu sync.Mutex
+	var lines []string
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		latency.LogEvery(ctx, time.Millisecond, func(format string, args ...any) {
+			mu.Lock()
+			defer mu.Unlock()
+			lines = append(lines, fmt.Sprintf(format, args...))
+		})
+		close(done)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	<-done
+	if len(lines) == 0 || !strings.HasPrefix(lines[0], "workflowai: latency my-agent/gpt-4o n=1 ttft=") {
+		t.Errorf("unexpected log lines %q", lines)
+	}
+}
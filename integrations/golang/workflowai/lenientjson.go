@@ -0,0 +1,134 @@
+package workflowai
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"regexp"
+)
+
+// errNoJSON is returned by [RepairJSON] when the text has no JSON value.
+var errNoJSON = errors.New("workflowai: no JSON object or array found")
+
+var markdownFence = regexp.MustCompile("(?s)```[a-zA-Z]*[ \t]*\r?\n(.*?)\r?\n?```")
+
+// RepairJSON returns data when it is valid JSON, and otherwise tries to
+// recover the JSON value of near valid outputs, reporting whether it was
+// repaired:
+//   - the content of a markdown code block, e.g. "```json\n{...}\n```"
+//   - the largest object or array of a text, e.g. after an introduction
+//   - trailing commas before the end of an object or an array
+//   - an output truncated in the middle of a value, at its last complete
+//     element
+//
+// An error is returned when no valid JSON value can be recovered.
+func RepairJSON(data []byte) ([]byte, bool, error) {
+	if json.Valid(data) {
+		return data, false, nil
+	}
+	trimmed := bytes.TrimSpace(data)
+	candidates := [][]byte{trimmed}
+	for _, m := range markdownFence.FindAllSubmatch(trimmed, -1) {
+		candidates = append([][]byte{bytes.TrimSpace(m[1])}, candidates...)
+	}
+	var best []byte
+	for _, candidate := range candidates {
+		if json.Valid(candidate) {
+			return candidate, true, nil
+		}
+		for start := 0; start < len(candidate); start++ {
+			if candidate[start] != '{' && candidate[start] != '[' {
+				continue
+			}
+			if value := recoverJSON(candidate[start:]); len(value) > len(best) {
+				best = value
+			}
+		}
+		if best != nil {
+			return best, true, nil
+		}
+	}
+	return nil, false, errNoJSON
+}
+
+// DecodeLenientJSON decodes data into v after [RepairJSON], reporting whether
+// data had to be repaired.
+func DecodeLenientJSON(data []byte, v any) (bool, error) {
+	fixed, repaired, err := RepairJSON(data)
+	if err != nil {
+		return false, err
+	}
+	return repaired, json.Unmarshal(fixed, v)
+}
+
+// recoverJSON returns the value starting data, an object or an array, without
+// its trailing commas, and closed at its last complete element when it is
+// truncated. It returns nil when the value is invalid.
+func recoverJSON(data []byte) []byte {
+	var out []byte
+	var stack []byte
+	// cut is the length of out after the last complete element, and
+	// cutStack the containers open there
+	cut, cutStack := 0, []byte(nil)
+	inString, escaped := false, false
+	for _, c := range data {
+		if inString {
+			out = append(out, c)
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+			continue
+		}
+		switch c {
+		case '"':
+			inString = true
+		case '{', '[':
+			stack = append(stack, c)
+		case '}', ']':
+			if len(stack) == 0 || (c == '}') != (stack[len(stack)-1] == '{') {
+				return nil
+			}
+			// Trailing comma
+			if trimmed := bytes.TrimRight(out, " \t\r\n"); len(trimmed) > 0 && trimmed[len(trimmed)-1] == ',' {
+				out = trimmed[:len(trimmed)-1]
+			}
+			stack = stack[:len(stack)-1]
+			out = append(out, c)
+			if len(stack) == 0 {
+				if json.Valid(out) {
+					return out
+				}
+				return nil
+			}
+			cut, cutStack = len(out), append(cutStack[:0], stack...)
+			continue
+		case ',':
+			if len(stack) > 0 {
+				cut, cutStack = len(out), append(cutStack[:0], stack...)
+			}
+		}
+		out = append(out, c)
+	}
+	// Truncated: closed after its last complete element
+	if cut == 0 {
+		out, cutStack = out[:1], stack[:1]
+	} else {
+		out = bytes.TrimRight(out[:cut], " \t\r\n,")
+	}
+	for i := len(cutStack) - 1; i >= 0; i-- {
+		if cutStack[i] == '{' {
+			out = append(out, '}')
+		} else {
+			out = append(out, ']')
+		}
+	}
+	if !json.Valid(out) {
+		return nil
+	}
+	return out
+}
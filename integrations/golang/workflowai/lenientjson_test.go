@@ -0,0 +1,50 @@
+package workflowai
+
+import (
+	"testing"
+)
+
+func TestRepairJSON(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		data     string
+		expected string
+		repaired bool
+	}{
+		{"valid", `{"a": 1}`, `{"a": 1}`, false},
+		{"fenced", "```json\n{\"a\": 1}\n```", `{"a": 1}`, true},
+		{"fenced without language", "Here you go:\n```\n[1, 2]\n```\nAnything else?", `[1, 2]`, true},
+		{"surrounded", `The answer is {"a": {"b": "}"}} as requested.`, `{"a": {"b": "}"}}`, true},
+		{"trailing commas", `{"a": [1, 2, ], "b": 3,}`, `{"a": [1, 2], "b": 3}`, true},
+		{"largest", `{"x": 1} and {"a": 1, "b": [1, 2]}`, `{"a": 1, "b": [1, 2]}`, true},
+		{"truncated", `{"items": [{"name": "a"}, {"name": "b"}, {"name": "c`, `{"items": [{"name": "a"}, {"name": "b"}]}`, true},
+		{"truncated in the first value", `{"name": "tru`, `{}`, true},
+		{"escaped quote", `Output: {"a": "say \"hi\"",}`, `{"a": "say \"hi\""}`, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fixed, repaired, err := RepairJSON([]byte(tc.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(fixed) != tc.expected || repaired != tc.repaired {
+				t.Errorf("RepairJSON(%q) = %s, %v", tc.data, fixed, repaired)
+			}
+		})
+	}
+
+	for _, data := range []string{"", "no JSON here", "{name}", `{"a": 1]`} {
+		if fixed, _, err := RepairJSON([]byte(data)); err == nil {
+			t.Errorf("expected an error for %q, got %s", data, fixed)
+		}
+	}
+}
+
+func TestDecodeLenientJSON(t *testing.T) {
+	var out struct {
+		Label string `json:"label"`
+	}
+	repaired, err := DecodeLenientJSON([]byte("```json\n{\"label\": \"positive\",}\n```"), &out)
+	if err != nil || !repaired || out.Label != "positive" {
+		t.Errorf("unexpected output %+v, %v, %v", out, repaired, err)
+	}
+}
@@ -0,0 +1,351 @@
+//go:build live
+
+// The live contract tests run the wrappers of the SDK against the real API,
+// and check that the typed models still match the responses of the platform:
+//
+//	WORKFLOWAI_API_KEY=<sandbox key> go test -tags live -run TestLive ./workflowai
+//
+// They create runs of the agent WORKFLOWAI_LIVE_AGENT ("go-sdk-contract" by
+// default) and deploy its version to the dev environment, so they must use a
+// sandbox organization. The image generation tests only run when
+// WORKFLOWAI_LIVE_IMAGE_MODEL is set, e.g. to "gpt-image-1".
+package workflowai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+const liveModel = "gpt-4o-mini-latest"
+
+// liveResponses records the last response body of each path.
+type liveResponses struct {
+	mu     sync.Mutex
+	bodies map[string][]byte
+}
+
+func (l *liveResponses) middleware(req *http.Request, next option.MiddlewareNext) (*http.Response, error) {
+	res, err := next(req)
+	if err != nil || strings.HasPrefix(res.Header.Get("Content-Type"), "text/event-stream") {
+		return res, err
+	}
+	body, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	res.Body = io.NopCloser(bytes.NewReader(body))
+	l.mu.Lock()
+	l.bodies[req.URL.Path] = body
+	l.mu.Unlock()
+	return res, nil
+}
+
+func (l *liveResponses) body(t *testing.T, suffix string) json.RawMessage {
+	t.Helper()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for path, body := range l.bodies {
+		if strings.HasSuffix(path, suffix) {
+			return body
+		}
+	}
+	t.Fatalf("no response recorded for %s", suffix)
+	return nil
+}
+
+func liveClient(t *testing.T) (Client, *liveResponses, string) {
+	t.Helper()
+	if os.Getenv("WORKFLOWAI_API_KEY") == "" {
+		t.Fatal("WORKFLOWAI_API_KEY must be set to a sandbox key to run the live tests")
+	}
+	agentID := os.Getenv("WORKFLOWAI_LIVE_AGENT")
+	if agentID == "" {
+		agentID = "go-sdk-contract"
+	}
+	responses := &liveResponses{bodies: map[string][]byte{}}
+	return NewClient(option.WithMiddleware(responses.middleware), option.WithRequestTimeout(time.Minute)), responses, agentID
+}
+
+// checkContract fails the test when raw does not decode into v, or when a
+// field that v always encodes is missing from raw, i.e. was removed or
+// renamed on the server. The fields unknown to v are logged, to be added to
+// the models when useful.
+func checkContract(t *testing.T, name string, raw json.RawMessage, v any) {
+	t.Helper()
+	if err := json.Unmarshal(raw, v); err != nil {
+		t.Errorf("%s: the response does not decode: %v\nresponse: %s", name, err, raw)
+		return
+	}
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want, got any
+	json.Unmarshal(encoded, &want)
+	json.Unmarshal(raw, &got)
+	var missing, unknown []string
+	compareFields(name, want, got, &missing, &unknown)
+	for _, field := range missing {
+		t.Errorf("%s: missing from the response", field)
+	}
+	if len(unknown) > 0 {
+		t.Logf("fields unknown to the SDK: %s", strings.Join(unknown, ", "))
+	}
+}
+
+func compareFields(path string, want any, got any, missing *[]string, unknown *[]string) {
+	switch w := want.(type) {
+	case map[string]any:
+		g, ok := got.(map[string]any)
+		if !ok {
+			return
+		}
+		keys := make([]string, 0, len(w))
+		for key := range w {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			value, ok := g[key]
+			if !ok {
+				*missing = append(*missing, path+"."+key)
+				continue
+			}
+			compareFields(path+"."+key, w[key], value, missing, unknown)
+		}
+		for key := range g {
+			if _, ok := w[key]; !ok {
+				*unknown = append(*unknown, path+"."+key)
+			}
+		}
+	case []any:
+		if g, ok := got.([]any); ok && len(w) > 0 && len(g) > 0 {
+			compareFields(path+"[0]", w[0], g[0], missing, unknown)
+		}
+	}
+}
+
+// liveRun runs a structured completion of the agent and returns its run ID.
+func liveRun(t *testing.T, client Client, agentID string) string {
+	t.Helper()
+	params := openai.ChatCompletionNewParams{
+		Model:    agentID + "/" + liveModel,
+		Messages: []openai.ChatCompletionMessageParamUnion{openai.UserMessage("Reply with the length of {{text}}")},
+		ResponseFormat: openai.ChatCompletionNewParamsResponseFormatUnion{OfJSONSchema: &openai.ResponseFormatJSONSchemaParam{
+			JSONSchema: openai.ResponseFormatJSONSchemaJSONSchemaParam{
+				Name: "length",
+				Schema: map[string]any{
+					"type":       "object",
+					"properties": map[string]any{"length": map[string]any{"type": "integer"}},
+					"required":   []string{"length"},
+				},
+			},
+		}},
+	}
+	params.SetExtraFields(map[string]any{"input": map[string]any{"text": "contract"}})
+	completion, err := client.Chat.Completions.New(context.Background(), params)
+	if err != nil {
+		t.Fatalf("running the agent: %v", err)
+	}
+	if CompletionCost(completion) == 0 {
+		t.Error("chat completion: missing choices[0].cost_usd")
+	}
+	gotAgent, runID := splitCompletionID(completion.ID)
+	if gotAgent != agentID || runID == "" {
+		t.Fatalf("completion ID %q is not <agent_id>/<run_id>", completion.ID)
+	}
+	return runID
+}
+
+func TestLiveCompletions(t *testing.T) {
+	client, _, agentID := liveClient(t)
+	liveRun(t, client, agentID)
+
+	stream := client.Chat.Completions.NewStreaming(context.Background(), openai.ChatCompletionNewParams{
+		Model:    agentID + "-stream/" + liveModel,
+		Messages: []openai.ChatCompletionMessageParamUnion{openai.UserMessage("Say hello")},
+	})
+	defer stream.Close()
+	var acc openai.ChatCompletionAccumulator
+	cost := false
+	for stream.Next() {
+		chunk := stream.Current()
+		acc.AddChunk(chunk)
+		for _, choice := range chunk.Choices {
+			_, ok := choice.JSON.ExtraFields["cost_usd"]
+			cost = cost || ok
+		}
+	}
+	if err := stream.Err(); err != nil {
+		t.Fatalf("streaming: %v", err)
+	}
+	if len(acc.Choices) == 0 || acc.Choices[0].Message.Content == "" {
+		t.Error("streamed completion: no content")
+	}
+	if !cost {
+		t.Error("streamed completion: no chunk has cost_usd")
+	}
+}
+
+func TestLiveModels(t *testing.T) {
+	client, responses, _ := liveClient(t)
+	models, err := ListModels(context.Background(), client.Client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(models) == 0 {
+		t.Fatal("empty models catalog")
+	}
+	var page struct {
+		Data []ModelInfo `json:"data"`
+	}
+	checkContract(t, "models", responses.body(t, "/models"), &page)
+	if _, err := FindModelCapabilities(context.Background(), client.Client, models[0].ID); err != nil {
+		t.Errorf("capabilities of %s: %v", models[0].ID, err)
+	}
+}
+
+func TestLiveRuns(t *testing.T) {
+	client, responses, agentID := liveClient(t)
+	ctx := context.Background()
+	runID := liveRun(t, client, agentID)
+
+	run, err := client.Runs.Get(ctx, agentID, runID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkContract(t, "run", responses.body(t, "/runs/"+runID), &Run{})
+	if run.Status != "success" || len(run.Output) == 0 {
+		t.Errorf("run %s: status %q, output %s", runID, run.Status, run.Output)
+	}
+
+	page, err := client.Runs.Search(ctx, agentID, RunSearchParams{Limit: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Items) == 0 {
+		t.Fatal("run search: no runs")
+	}
+	checkContract(t, "run search", responses.body(t, "/runs/search"), &Page[RunItem]{})
+
+	var export bytes.Buffer
+	if _, err := client.Runs.Export(ctx, agentID, &export, ExportParams{
+		FieldQueries: []FieldQuery{{FieldName: "status", Operator: SearchOperatorIs, Values: []any{"success"}}},
+		PageSize:     2,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if export.Len() == 0 {
+		t.Error("export: no records")
+	}
+}
+
+func TestLiveAgents(t *testing.T) {
+	client, responses, agentID := liveClient(t)
+	ctx := context.Background()
+	liveRun(t, client, agentID)
+
+	agents, err := client.Agents.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkContract(t, "agents", responses.body(t, "/_/agents"), &Page[AgentInfo]{})
+	found := false
+	for _, agent := range agents {
+		found = found || agent.ID == agentID
+	}
+	if !found {
+		t.Errorf("the agent %s is not listed", agentID)
+	}
+
+	stats, err := client.Agents.Stats(ctx, agentID, AgentStatsParams{From: time.Now().AddDate(0, 0, -1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkContract(t, "agent stats", responses.body(t, "/runs/stats"), &AgentStats{})
+	if stats.Total().RunCount == 0 {
+		t.Error("agent stats: no runs over the last day")
+	}
+}
+
+func TestLiveVersions(t *testing.T) {
+	client, responses, agentID := liveClient(t)
+	ctx := context.Background()
+	run, err := client.Runs.Get(ctx, agentID, liveRun(t, client, agentID))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deployment, err := client.Versions.Deploy(ctx, agentID, run.Version.ID, EnvironmentDev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkContract(t, "deployment", responses.body(t, "/deploy"), &Deployment{})
+
+	version, err := client.Versions.Get(ctx, agentID, deployment.VersionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkContract(t, "version", responses.body(t, "/versions/"+deployment.VersionID), &Version{})
+	if len(version.InputSchema) == 0 || len(version.OutputSchema) == 0 {
+		t.Error("version: missing schemas")
+	}
+
+	majors, err := client.Versions.List(ctx, agentID, version.SchemaID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkContract(t, "versions", responses.body(t, "/versions"), &Page[MajorVersion]{})
+	if minor, _ := DeployedVersion(majors, EnvironmentDev, version.SchemaID); minor == nil || minor.ID != version.ID {
+		t.Errorf("the deployed version %s is not listed as deployed to dev", version.ID)
+	}
+
+	if err := client.Versions.UpdateNotes(ctx, agentID, version.ID, version.Notes); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLiveImages(t *testing.T) {
+	model := os.Getenv("WORKFLOWAI_LIVE_IMAGE_MODEL")
+	if model == "" {
+		t.Skip("WORKFLOWAI_LIVE_IMAGE_MODEL is not set")
+	}
+	// The images are generated by the OpenAI API, not by WorkflowAI
+	if os.Getenv("OPENAI_API_KEY") == "" {
+		t.Skip("OPENAI_API_KEY is not set")
+	}
+	images := NewImageService(openai.NewClient(OpenAIClientOptions()...))
+	stream := images.GenerateStreaming(context.Background(), openai.ImageGenerateParams{
+		Model:  model,
+		Prompt: "A blue square",
+		Size:   openai.ImageGenerateParamsSize1024x1024,
+	}, 1)
+	defer stream.Close()
+	completed := false
+	for stream.Next() {
+		event := stream.Current()
+		if _, err := event.Image(); err != nil {
+			t.Errorf("%s event: %v", event.Type, err)
+		}
+		completed = completed || event.Type == ImageEventCompleted
+	}
+	if err := stream.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if !completed {
+		t.Error("no completed image event")
+	}
+}
@@ -0,0 +1,827 @@
+// Package mdrender renders the markdown of streamed completions as it is
+// received, to ANSI escape sequences for terminals or to sanitized HTML for
+// browsers.
+//
+//	r := mdrender.NewTerminal(os.Stdout)
+//	for stream.Next() {
+//		if chunk := stream.Current(); len(chunk.Choices) > 0 {
+//			r.WriteString(chunk.Choices[0].Delta.Content)
+//		}
+//	}
+//	r.Flush()
+//
+// Text is rendered as soon as it cannot change anymore: a line once its
+// block is known, e.g. a list item after its marker, then its words up to an
+// unclosed code span, emphasis or link, and the lines of code blocks as they
+// are received. Flush renders the rest and closes the open blocks, e.g. a code
+// block whose closing fence was never received.
+//
+// The renderers support headings, paragraphs, nested ordered and unordered
+// lists, block quotes, fenced code blocks and thematic breaks, and code spans,
+// strong emphasis, emphasis, strikethrough and links inline. Other syntax,
+// e.g. tables, is rendered as text.
+//
+// The HTML renderer escapes the text, including the raw HTML of the markdown,
+// and only links to http, https, mailto and relative URLs, so that its output
+// can be inserted in a page. Until Flush, the fragments written so far may
+// have unclosed elements. The terminal renderer removes the control
+// characters of the text, so that completions cannot send escape sequences to
+// the terminal.
+package mdrender
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const (
+	ansiBold      = "\x1b[1m"
+	ansiHeading1  = "\x1b[1;4m"
+	ansiNormal    = "\x1b[22m"
+	ansiReset     = "\x1b[0m"
+	ansiDim       = "\x1b[2m"
+	ansiItalic    = "\x1b[3m"
+	ansiNoItalic  = "\x1b[23m"
+	ansiUnderline = "\x1b[4m"
+	ansiNoUnder   = "\x1b[24m"
+	ansiStrike    = "\x1b[9m"
+	ansiNoStrike  = "\x1b[29m"
+	ansiCode      = "\x1b[36m"
+	ansiNoCode    = "\x1b[39m"
+)
+
+// Renderer renders markdown written in chunks of any size to a writer. It is
+// not safe for concurrent use.
+type Renderer struct {
+	w    io.Writer
+	html bool
+	err  error
+
+	// buf is the text of the current line that is not rendered yet, the
+	// rest of its content once started
+	buf     string
+	started bool
+	kind    blockKind
+	level   int
+
+	// fence is the marker of the open code block, e.g. "```"
+	fence       string
+	fenceIndent int
+	para        paragraph
+	quote       bool
+	lists       []list
+	blank       bool
+}
+
+// NewTerminal returns a renderer writing markdown to w with ANSI escape
+// sequences.
+func NewTerminal(w io.Writer) *Renderer {
+	return &Renderer{w: w}
+}
+
+// NewHTML returns a renderer writing markdown to w as HTML.
+func NewHTML(w io.Writer) *Renderer {
+	return &Renderer{w: w, html: true}
+}
+
+// Write renders the markdown of p, it implements [io.Writer].
+func (r *Renderer) Write(p []byte) (int, error) {
+	if _, err := r.WriteString(string(p)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// WriteString renders the markdown of s, keeping the text that may still
+// change until the next chunks. It returns the first error of the writer.
+func (r *Renderer) WriteString(s string) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	r.buf += s
+	for {
+		i := strings.IndexByte(r.buf, '\n')
+		if i < 0 {
+			break
+		}
+		line := strings.TrimSuffix(r.buf[:i], "\r")
+		r.buf = r.buf[i+1:]
+		r.render(line, true)
+	}
+	if r.buf != "" {
+		r.buf = r.render(r.buf, false)
+	}
+	if r.err != nil {
+		return 0, r.err
+	}
+	return len(s), nil
+}
+
+// Flush renders the rest of the text and closes the open blocks, so that the
+// renderer can be used for a new document. It returns the first error of the
+// writer.
+func (r *Renderer) Flush() error {
+	if r.buf != "" || r.started {
+		r.buf = r.render(r.buf, true)
+	}
+	if r.fence != "" {
+		if r.html {
+			r.write("</code></pre>\n")
+		}
+		r.fence = ""
+	}
+	r.closeAll()
+	r.blank = false
+	return r.err
+}
+
+type blockKind int
+
+const (
+	blockBlank blockKind = iota
+	blockParagraph
+	blockHeading
+	blockItem
+	blockQuote
+	blockBreak
+	blockFence
+	blockCode
+)
+
+type paragraph int
+
+const (
+	paraNone paragraph = iota
+	// paraOpen is a paragraph in a <p> element
+	paraOpen
+	// paraInline is the text of a list item
+	paraInline
+)
+
+// list is an open list.
+type list struct {
+	ordered bool
+	// indent is the indentation of its markers and content the one of its
+	// content
+	indent  int
+	content int
+	number  int
+	// width is the width of the marker in the terminal
+	width int
+}
+
+// line is the block of a line.
+type line struct {
+	kind    blockKind
+	indent  int
+	level   int
+	ordered bool
+	number  int
+	// marker is the width of the list item marker, including its space
+	marker int
+	fence  string
+	// content is the text rendered inline, the code of code lines, or the
+	// info string of fences
+	content string
+}
+
+// render renders the unrendered text of the current line, all of it when the
+// line is complete, and returns the rest.
+func (r *Renderer) render(text string, complete bool) string {
+	if !r.started {
+		l, ok := r.classify(text, complete)
+		if !ok {
+			return text
+		}
+		r.begin(l)
+		r.started, r.kind, r.level = true, l.kind, l.level
+		text = l.content
+	}
+	switch r.kind {
+	case blockCode:
+		r.write(r.text(text))
+		text = ""
+	case blockParagraph, blockHeading, blockItem, blockQuote:
+		n := len(text)
+		if !complete {
+			n = safeCut(text)
+		}
+		r.write(r.inline(text[:n]))
+		text = text[n:]
+	default:
+		text = ""
+	}
+	if complete {
+		r.end()
+		r.started = false
+	}
+	return text
+}
+
+// classify returns the block of s, or false when s is an incomplete line
+// whose block is not known yet.
+func (r *Renderer) classify(s string, complete bool) (line, bool) {
+	rest := strings.TrimLeft(s, " \t")
+	l := line{indent: len(s) - len(rest)}
+	if r.fence != "" {
+		c := r.fence[0]
+		n := run(rest, c)
+		switch {
+		case l.indent < 4 && n > 0 && n == len(strings.TrimRight(rest, " ")):
+			if !complete {
+				return l, false
+			}
+			if n >= len(r.fence) {
+				l.kind = blockFence
+				return l, true
+			}
+		case rest == "" && !complete:
+			return l, false
+		}
+		l.kind = blockCode
+		l.content = s[min(l.indent, r.fenceIndent):]
+		return l, true
+	}
+
+	l.kind = blockParagraph
+	l.content = rest
+	switch {
+	case rest == "":
+		if !complete {
+			return l, false
+		}
+		l.kind = blockBlank
+	case rest[0] == '`' || rest[0] == '~':
+		n := run(rest, rest[0])
+		if n < 3 && n == len(rest) && !complete {
+			return l, false
+		}
+		if n >= 3 {
+			if !complete {
+				return l, false
+			}
+			l.kind, l.fence, l.content = blockFence, rest[:n], strings.TrimSpace(rest[n:])
+		}
+	case rest[0] == '#':
+		n := run(rest, '#')
+		switch {
+		case n > 6:
+		case n == len(rest):
+			if !complete {
+				return l, false
+			}
+			l.kind, l.level, l.content = blockHeading, n, ""
+		case rest[n] == ' ':
+			l.kind, l.level, l.content = blockHeading, n, strings.TrimLeft(rest[n:], " ")
+		}
+	case strings.Trim(rest, "-*_ ") == "" && !complete:
+		// A thematic break or the marker of a list item
+		return l, false
+	case strings.Trim(rest, "-*_ ") == "" && isBreak(rest):
+		l.kind = blockBreak
+	case rest[0] == '>':
+		if !complete && strings.TrimSpace(rest[1:]) == "" {
+			return l, false
+		}
+		l.kind, l.content = blockQuote, strings.TrimPrefix(rest[1:], " ")
+	case rest[0] == '-' || rest[0] == '*' || rest[0] == '+':
+		if len(rest) == 1 && !complete {
+			return l, false
+		}
+		if len(rest) == 1 || rest[1] == ' ' {
+			l.kind, l.marker, l.content = blockItem, 2, strings.TrimLeft(rest[1:], " ")
+		}
+	case rest[0] >= '0' && rest[0] <= '9':
+		n := 0
+		for n < len(rest) && n < 10 && rest[n] >= '0' && rest[n] <= '9' {
+			n++
+		}
+		if !complete && (n == len(rest) || (n+1 == len(rest) && (rest[n] == '.' || rest[n] == ')'))) {
+			return l, false
+		}
+		if n < 10 && n < len(rest) && (rest[n] == '.' || rest[n] == ')') && (n+1 == len(rest) || rest[n+1] == ' ') {
+			number, _ := strconv.Atoi(rest[:n])
+			l.kind, l.ordered, l.number, l.marker = blockItem, true, number, n+2
+			l.content = strings.TrimLeft(rest[n+1:], " ")
+		}
+	}
+	return l, true
+}
+
+// begin renders the start of the block of a line.
+func (r *Renderer) begin(l line) {
+	defer func() { r.blank = l.kind == blockBlank }()
+	switch l.kind {
+	case blockCode:
+		if !r.html {
+			r.write(r.indentation() + ansiCode)
+		}
+	case blockFence:
+		if r.fence != "" {
+			if r.html {
+				r.write("</code></pre>\n")
+			}
+			r.fence = ""
+			return
+		}
+		r.closeParagraph()
+		r.closeQuote()
+		r.closeLists(l.indent)
+		r.fence, r.fenceIndent = l.fence, l.indent
+		if !r.html {
+			return
+		}
+		if lang := strings.Fields(l.content); len(lang) > 0 && isLanguage(lang[0]) {
+			r.write(`<pre><code class="language-` + lang[0] + `">`)
+		} else {
+			r.write("<pre><code>")
+		}
+	case blockBlank:
+		r.closeParagraph()
+		r.closeQuote()
+		if !r.html && !r.blank {
+			r.write("\n")
+		}
+	case blockHeading:
+		r.closeAll()
+		switch {
+		case r.html:
+			r.write(fmt.Sprintf("<h%d>", l.level))
+		case l.level == 1:
+			r.write(ansiHeading1)
+		default:
+			r.write(ansiBold)
+		}
+	case blockBreak:
+		r.closeAll()
+		if r.html {
+			r.write("<hr>\n")
+		} else {
+			r.write(ansiDim + strings.Repeat("─", 40) + ansiNormal)
+		}
+	case blockQuote:
+		r.closeLists(-1)
+		if !r.quote {
+			r.closeParagraph()
+			if r.html {
+				r.write("<blockquote>\n")
+			}
+			r.quote = true
+		}
+		switch {
+		case l.content == "":
+			r.closeParagraph()
+		case r.para != paraNone:
+			if r.html {
+				r.write("\n")
+			}
+		default:
+			if r.html {
+				r.write("<p>")
+			}
+			r.para = paraOpen
+		}
+		if !r.html {
+			r.write(r.indentation())
+		}
+	case blockItem:
+		r.closeParagraph()
+		r.closeQuote()
+		r.openItem(l)
+	case blockParagraph:
+		if r.para != paraNone {
+			// Continuation of the paragraph
+			if r.html {
+				r.write("\n")
+			} else {
+				r.write(r.indentation())
+			}
+			return
+		}
+		r.closeQuote()
+		r.closeLists(l.indent)
+		if r.html {
+			r.write("<p>")
+		} else {
+			r.write(r.indentation())
+		}
+		r.para = paraOpen
+	}
+}
+
+// end renders the end of the line.
+func (r *Renderer) end() {
+	switch r.kind {
+	case blockCode:
+		if r.html {
+			r.write("\n")
+		} else {
+			r.write(ansiNoCode + "\n")
+		}
+	case blockHeading:
+		if r.html {
+			r.write(fmt.Sprintf("</h%d>\n", r.level))
+		} else {
+			r.write(ansiReset + "\n")
+		}
+	case blockBreak, blockParagraph, blockItem, blockQuote:
+		if !r.html {
+			r.write("\n")
+		}
+	}
+}
+
+// openItem opens a list item, in the open list when it is at its level, or
+// in a new list.
+func (r *Renderer) openItem(l line) {
+	sibling := false
+	for len(r.lists) > 0 {
+		top := r.lists[len(r.lists)-1]
+		if l.indent >= top.content {
+			break
+		}
+		if l.indent >= top.indent && top.ordered == l.ordered {
+			sibling = true
+			break
+		}
+		r.closeList()
+	}
+	if sibling {
+		top := &r.lists[len(r.lists)-1]
+		top.number++
+		top.content = l.indent + l.marker
+		if r.html {
+			r.write("</li>\n<li>")
+		}
+	} else {
+		r.lists = append(r.lists, list{ordered: l.ordered, indent: l.indent, content: l.indent + l.marker, number: l.number})
+		switch {
+		case !r.html:
+		case !l.ordered:
+			r.write("<ul>\n<li>")
+		case l.number != 1:
+			r.write(fmt.Sprintf("<ol start=\"%d\">\n<li>", l.number))
+		default:
+			r.write("<ol>\n<li>")
+		}
+	}
+	r.para = paraInline
+	if r.html {
+		return
+	}
+	top := &r.lists[len(r.lists)-1]
+	marker := "• "
+	if top.ordered {
+		marker = fmt.Sprintf("%d. ", top.number)
+	}
+	top.width = len([]rune(marker))
+	r.write(strings.Repeat(" ", r.indentWidth(len(r.lists)-1)) + marker)
+}
+
+// indentation returns the indentation of the content of the open lists and
+// quote in the terminal.
+func (r *Renderer) indentation() string {
+	s := strings.Repeat(" ", r.indentWidth(len(r.lists)))
+	if r.quote {
+		s += ansiDim + "│" + ansiNormal + " "
+	}
+	return s
+}
+
+// indentWidth returns the indentation of the content of the first n lists.
+func (r *Renderer) indentWidth(n int) int {
+	width := 0
+	for _, l := range r.lists[:n] {
+		width += l.width
+	}
+	return width
+}
+
+func (r *Renderer) closeParagraph() {
+	if r.para == paraOpen && r.html {
+		r.write("</p>\n")
+	}
+	r.para = paraNone
+}
+
+func (r *Renderer) closeQuote() {
+	if !r.quote {
+		return
+	}
+	r.closeParagraph()
+	if r.html {
+		r.write("</blockquote>\n")
+	}
+	r.quote = false
+}
+
+// closeLists closes the lists whose content is indented more than indent.
+func (r *Renderer) closeLists(indent int) {
+	for len(r.lists) > 0 && indent < r.lists[len(r.lists)-1].content {
+		r.closeList()
+	}
+}
+
+func (r *Renderer) closeList() {
+	r.closeParagraph()
+	top := r.lists[len(r.lists)-1]
+	r.lists = r.lists[:len(r.lists)-1]
+	if !r.html {
+		return
+	}
+	if top.ordered {
+		r.write("</li>\n</ol>\n")
+	} else {
+		r.write("</li>\n</ul>\n")
+	}
+}
+
+func (r *Renderer) closeAll() {
+	r.closeParagraph()
+	r.closeQuote()
+	r.closeLists(-1)
+}
+
+func (r *Renderer) write(s string) {
+	if r.err != nil || s == "" {
+		return
+	}
+	_, r.err = io.WriteString(r.w, s)
+}
+
+// text escapes text for the output.
+func (r *Renderer) text(s string) string {
+	if r.html {
+		return html.EscapeString(s)
+	}
+	return strings.Map(func(c rune) rune {
+		if c != '\t' && (unicode.IsControl(c) || c == '\x7f') {
+			return -1
+		}
+		return c
+	}, s)
+}
+
+type spanKind int
+
+const (
+	spanText spanKind = iota
+	spanCode
+	spanStrong
+	spanEm
+	spanDel
+	spanLink
+)
+
+type spanState int
+
+const (
+	spanMatched spanState = iota
+	// spanLiteral is a marker rendered as text
+	spanLiteral
+	// spanOpen is a marker that may be closed by the rest of the text
+	spanOpen
+)
+
+// span is an inline element.
+type span struct {
+	kind spanKind
+	text string
+	url  string
+	// end is the index after the span, or after the marker when it is not
+	// matched
+	end int
+}
+
+// inline renders the inline elements of s.
+func (r *Renderer) inline(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		j := nextMarker(s, i)
+		b.WriteString(r.text(s[i:j]))
+		if j == len(s) {
+			break
+		}
+		sp, state := parseSpan(s, j)
+		if state == spanMatched {
+			b.WriteString(r.span(sp))
+		} else {
+			b.WriteString(r.text(s[j:sp.end]))
+		}
+		i = sp.end
+	}
+	return b.String()
+}
+
+func (r *Renderer) span(sp span) string {
+	if r.html {
+		switch sp.kind {
+		case spanCode:
+			return "<code>" + r.text(sp.text) + "</code>"
+		case spanStrong:
+			return "<strong>" + r.inline(sp.text) + "</strong>"
+		case spanEm:
+			return "<em>" + r.inline(sp.text) + "</em>"
+		case spanDel:
+			return "<del>" + r.inline(sp.text) + "</del>"
+		case spanLink:
+			if !isSafeURL(sp.url) {
+				return r.inline(sp.text)
+			}
+			return `<a href="` + html.EscapeString(sp.url) + `" rel="nofollow noopener">` + r.inline(sp.text) + "</a>"
+		}
+		return r.text(sp.text)
+	}
+	switch sp.kind {
+	case spanCode:
+		return ansiCode + r.text(sp.text) + ansiNoCode
+	case spanStrong:
+		return ansiBold + r.inline(sp.text) + ansiNormal
+	case spanEm:
+		return ansiItalic + r.inline(sp.text) + ansiNoItalic
+	case spanDel:
+		return ansiStrike + r.inline(sp.text) + ansiNoStrike
+	case spanLink:
+		if sp.text == sp.url {
+			return ansiUnderline + r.text(sp.url) + ansiNoUnder
+		}
+		return ansiUnderline + r.inline(sp.text) + ansiNoUnder + " (" + r.text(sp.url) + ")"
+	}
+	return r.text(sp.text)
+}
+
+// safeCut returns the length of the prefix of s that can be rendered before
+// the rest of the line is received: up to the last space before an unclosed
+// marker.
+func safeCut(s string) int {
+	cut := 0
+	for i := 0; i < len(s); {
+		j := nextMarker(s, i)
+		if k := strings.LastIndexAny(s[i:j], " \t"); k >= 0 {
+			cut = i + k + 1
+		}
+		if j == len(s) {
+			break
+		}
+		sp, state := parseSpan(s, j)
+		if state == spanOpen {
+			return cut
+		}
+		i = sp.end
+	}
+	return cut
+}
+
+// nextMarker returns the index of the next possible inline marker of s from
+// i, or len(s).
+func nextMarker(s string, i int) int {
+	for ; i < len(s); i++ {
+		switch s[i] {
+		case '`', '*', '~', '[', '\\':
+			return i
+		case '_':
+			// Not in words, e.g. snake_case
+			if i == 0 || !isWordByte(s[i-1]) {
+				return i
+			}
+		}
+	}
+	return i
+}
+
+// parseSpan parses the inline element at the marker s[i].
+func parseSpan(s string, i int) (span, spanState) {
+	c := s[i]
+	literal := span{kind: spanText, end: i + 1}
+	switch c {
+	case '\\':
+		if i+1 == len(s) {
+			return literal, spanOpen
+		}
+		if unicode.IsPunct(rune(s[i+1])) || unicode.IsSymbol(rune(s[i+1])) {
+			return span{kind: spanText, text: s[i+1 : i+2], end: i + 2}, spanMatched
+		}
+		return literal, spanLiteral
+	case '`':
+		n := run(s[i:], '`')
+		literal.end = i + n
+		j := strings.Index(s[i+n:], s[i:i+n])
+		if j < 0 {
+			return literal, spanOpen
+		}
+		text := s[i+n : i+n+j]
+		if len(text) > 1 && text[0] == ' ' && text[len(text)-1] == ' ' {
+			text = text[1 : len(text)-1]
+		}
+		return span{kind: spanCode, text: text, end: i + n + j + n}, spanMatched
+	case '*', '_', '~':
+		n := run(s[i:], c)
+		literal.end = i + n
+		kind, delim := spanEm, s[i:i+1]
+		switch {
+		case c == '~' && n != 2:
+			return literal, spanLiteral
+		case c == '~':
+			kind, delim = spanDel, "~~"
+		case n >= 2:
+			kind, delim = spanStrong, s[i:i+2]
+		}
+		start := i + len(delim)
+		if start == len(s) {
+			return literal, spanOpen
+		}
+		if s[start] == ' ' || s[start] == '\t' {
+			return literal, spanLiteral
+		}
+		j := strings.Index(s[start:], delim)
+		if j <= 0 {
+			return literal, spanOpen
+		}
+		return span{kind: kind, text: s[start : start+j], end: start + j + len(delim)}, spanMatched
+	case '[':
+		j := strings.IndexByte(s[i+1:], ']')
+		after := i + 1 + j + 1
+		switch {
+		case j < 0 || after == len(s):
+			return literal, spanOpen
+		case s[after] != '(':
+			return literal, spanLiteral
+		}
+		k := closingParen(s[after:])
+		if k < 0 {
+			return literal, spanOpen
+		}
+		target := strings.Fields(s[after+1 : after+k])
+		if len(target) == 0 {
+			return literal, spanLiteral
+		}
+		return span{kind: spanLink, text: s[i+1 : i+1+j], url: strings.Trim(target[0], "<>"), end: after + k + 1}, spanMatched
+	}
+	return literal, spanLiteral
+}
+
+// closingParen returns the index of the parenthesis closing the one s starts
+// with, or -1.
+func closingParen(s string) int {
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// isSafeURL reports whether links to u can be rendered: http, https, mailto
+// and relative URLs.
+func isSafeURL(u string) bool {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return false
+	}
+	switch parsed.Scheme {
+	case "", "http", "https", "mailto":
+		return true
+	}
+	return false
+}
+
+// isBreak reports whether s is a thematic break, e.g. "---" or "* * *".
+func isBreak(s string) bool {
+	s = strings.ReplaceAll(s, " ", "")
+	return len(s) >= 3 && run(s, s[0]) == len(s)
+}
+
+// isLanguage reports whether s can be used in the class of code blocks.
+func isLanguage(s string) bool {
+	for _, c := range s {
+		if !isWordByte(byte(c)) && c != '-' && c != '+' && c != '#' || c > unicode.MaxASCII {
+			return false
+		}
+	}
+	return true
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
+
+// run returns the number of c at the start of s.
+func run(s string, c byte) int {
+	n := 0
+	for n < len(s) && s[n] == c {
+		n++
+	}
+	return n
+}
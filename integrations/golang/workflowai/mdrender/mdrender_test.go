@@ -0,0 +1,126 @@
+package mdrender
+
+import (
+	"strings"
+	"testing"
+)
+
+const document = "# Title\n\nSome **bold** and *italic* text with `code`,\n" +
+	"a [link](https://example.com) and snake_case.\n\n" +
+	"- one\n- two\n  - nested\n3. three\n\n" +
+	"> quoted\n\n---\n\n```go\nfmt.Println(\"<hi>\")\n```\n"
+
+func TestHTML(t *testing.T) {
+	var out strings.Builder
+	r := NewHTML(&out)
+	if _, err := r.WriteString(document); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	expected := "<h1>Title</h1>\n" +
+		"<p>Some <strong>bold</strong> and <em>italic</em> text with <code>code</code>,\n" +
+		"a <a href=\"https://example.com\" rel=\"nofollow noopener\">link</a> and snake_case.</p>\n" +
+		"<ul>\n<li>one</li>\n<li>two<ul>\n<li>nested</li>\n</ul>\n</li>\n</ul>\n" +
+		"<ol start=\"3\">\n<li>three</li>\n</ol>\n" +
+		"<blockquote>\n<p>quoted</p>\n</blockquote>\n" +
+		"<hr>\n" +
+		"<pre><code class=\"language-go\">fmt.Println(&#34;&lt;hi&gt;&#34;)\n</code></pre>\n"
+	if out.String() != expected {
+		t.Errorf("unexpected HTML\n%s", out.String())
+	}
+}
+
+func TestChunks(t *testing.T) {
+	for name, newRenderer := range map[string]func(*strings.Builder) *Renderer{
+		"html":     func(b *strings.Builder) *Renderer { return NewHTML(b) },
+		"terminal": func(b *strings.Builder) *Renderer { return NewTerminal(b) },
+	} {
+		var whole strings.Builder
+		r := newRenderer(&whole)
+		r.WriteString(document)
+		r.Flush()
+		for _, size := range []int{1, 2, 3, 7} {
+			var chunked strings.Builder
+			r := newRenderer(&chunked)
+			for i := 0; i < len(document); i += size {
+				r.WriteString(document[i:min(i+size, len(document))])
+			}
+			r.Flush()
+			if chunked.String() != whole.String() {
+				t.Errorf("%s: chunks of %d bytes give\n%q\ninstead of\n%q", name, size, chunked.String(), whole.String())
+			}
+		}
+	}
+}
+
+func TestPartial(t *testing.T) {
+	var out strings.Builder
+	r := NewHTML(&out)
+	r.WriteString("Hello **wor")
+	if out.String() != "<p>Hello " {
+		t.Errorf("unexpected partial output %q", out.String())
+	}
+	r.WriteString("ld** ")
+	if out.String() != "<p>Hello <strong>world</strong> " {
+		t.Errorf("unexpected partial output %q", out.String())
+	}
+
+	// Code lines are rendered as they are received
+	out.Reset()
+	r = NewHTML(&out)
+	r.WriteString("- item\n```\nfunc main() {")
+	if !strings.HasSuffix(out.String(), "<pre><code>func main() {") {
+		t.Errorf("unexpected partial output %q", out.String())
+	}
+	// The list item marker is not known yet
+	out.Reset()
+	r = NewHTML(&out)
+	r.WriteString("Text\n\n1")
+	if out.String() != "<p>Text</p>\n" {
+		t.Errorf("unexpected partial output %q", out.String())
+	}
+
+	// Flush closes the code block that was never closed
+	out.Reset()
+	r = NewHTML(&out)
+	r.WriteString("```\nunfinished")
+	r.Flush()
+	if out.String() != "<pre><code>unfinished\n</code></pre>\n" {
+		t.Errorf("unexpected output %q", out.String())
+	}
+}
+
+func TestSanitize(t *testing.T) {
+	var out strings.Builder
+	r := NewHTML(&out)
+	r.WriteString(`<script>alert(1)</script> [click](javascript:alert(1)) [ok](/docs "Docs")`)
+	r.Flush()
+	expected := `<p>&lt;script&gt;alert(1)&lt;/script&gt; click <a href="/docs" rel="nofollow noopener">ok</a></p>` + "\n"
+	if out.String() != expected {
+		t.Errorf("unexpected HTML %s", out.String())
+	}
+
+	out.Reset()
+	r = NewTerminal(&out)
+	r.WriteString("Hi \x1b]0;title\x07**there**")
+	r.Flush()
+	if out.String() != "Hi ]0;title"+ansiBold+"there"+ansiNormal+"\n" {
+		t.Errorf("unexpected output %q", out.String())
+	}
+}
+
+func TestTerminal(t *testing.T) {
+	var out strings.Builder
+	r := NewTerminal(&out)
+	r.WriteString("## Steps\n1. First\n   - detail\n2. Second\n")
+	r.Flush()
+	expected := ansiBold + "Steps" + ansiReset + "\n" +
+		"1. First\n" +
+		"   • detail\n" +
+		"2. Second\n"
+	if out.String() != expected {
+		t.Errorf("unexpected output\n%q", out.String())
+	}
+}
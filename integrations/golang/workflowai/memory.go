@@ -0,0 +1,338 @@
+package workflowai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/openai/openai-go"
+)
+
+// ConversationMemory builds the context of the turns of a conversation from
+// its stored history, e.g. its last messages or a summary of the older ones,
+// so that long conversations keep useful context without unbounded token
+// growth, see [WithConversationMemory].
+type ConversationMemory interface {
+	// Context returns the messages sent before the new messages of a turn.
+	Context(ctx context.Context, conversationID string, history []openai.ChatCompletionMessageParamUnion) ([]openai.ChatCompletionMessageParamUnion, error)
+	// Update is called after each exchange with the history ending with the
+	// exchange, its last exchange messages being the new messages and the
+	// reply. It returns the number of last messages of the history the store
+	// keeps, the older ones being remembered by the memory, or len(history).
+	Update(ctx context.Context, conversationID string, history []openai.ChatCompletionMessageParamUnion, exchange int) (int, error)
+}
+
+// BufferMemory sends the last n messages of the history, or the full history
+// when n <= 0. The history is stored in full.
+func BufferMemory(n int) ConversationMemory {
+	return bufferMemory{n: n}
+}
+
+type bufferMemory struct {
+	n int
+}
+
+func (b bufferMemory) Context(_ context.Context, _ string, history []openai.ChatCompletionMessageParamUnion) ([]openai.ChatCompletionMessageParamUnion, error) {
+	if b.n <= 0 || len(history) <= b.n {
+		return history, nil
+	}
+	return history[windowStart(history, len(history)-b.n):], nil
+}
+
+func (bufferMemory) Update(_ context.Context, _ string, history []openai.ChatCompletionMessageParamUnion, _ int) (int, error) {
+	return len(history), nil
+}
+
+// windowStart returns the start of the messages of history from i, moved
+// forward past tool results so that they are not separated from their tool
+// calls.
+func windowStart(history []openai.ChatCompletionMessageParamUnion, i int) int {
+	for i < len(history) && history[i].OfTool != nil {
+		i++
+	}
+	return i
+}
+
+type memoryConfig struct {
+	window int
+	state  ConversationStateStore
+}
+
+type MemoryOption func(*memoryConfig)
+
+// WithMemoryWindow sets the number of last messages sent as they are, 10 by
+// default.
+func WithMemoryWindow(n int) MemoryOption {
+	return func(c *memoryConfig) {
+		c.window = max(n, 1)
+	}
+}
+
+// WithMemoryState sets the store of the state of the memory. Defaults to the
+// [ConversationStore] of the manager, which must then implement
+// [ConversationStateStore]: the memory removes the messages it remembers from
+// the conversation store, so its state must be as durable.
+func WithMemoryState(store ConversationStateStore) MemoryOption {
+	return func(c *memoryConfig) {
+		c.state = store
+	}
+}
+
+// memoryState is the state of a summary or an entity memory.
+type memoryState struct {
+	// Pinned are the system and developer messages removed from the history,
+	// always sent
+	Pinned   []openai.ChatCompletionMessageParamUnion `json:"pinned,omitempty"`
+	Summary  string                                   `json:"summary,omitempty"`
+	Entities map[string]string                        `json:"entities,omitempty"`
+}
+
+// windowMemory sends the last messages of the history after a message
+// recalling the older ones, removed from the store.
+type windowMemory struct {
+	memoryConfig
+	completions ChatCompleter
+	model       string
+	key         string
+	// recall returns the text of the message recalling the older messages,
+	// "" for none
+	recall func(state *memoryState) string
+	// remember updates the state with the exchange and the older messages
+	// removed from the store, when there are any
+	remember func(ctx context.Context, state *memoryState, exchange, older []openai.ChatCompletionMessageParamUnion) error
+}
+
+func newWindowMemory(completions ChatCompleter, model, key string, opts []MemoryOption) *windowMemory {
+	m := &windowMemory{
+		memoryConfig: memoryConfig{window: 10},
+		completions:  completions,
+		model:        model,
+		key:          key,
+	}
+	for _, opt := range opts {
+		opt(&m.memoryConfig)
+	}
+	return m
+}
+
+// withStore returns the memory storing its state in the store of a
+// conversation manager, unless it was set with [WithMemoryState].
+func (m *windowMemory) withStore(store ConversationStore) (ConversationMemory, error) {
+	if m.state != nil {
+		return m, nil
+	}
+	state, ok := store.(ConversationStateStore)
+	if !ok {
+		return nil, fmt.Errorf("workflowai: the %s memory needs a ConversationStateStore: the conversation store does not implement it, see WithMemoryState", m.key)
+	}
+	bound := *m
+	bound.state = state
+	return &bound, nil
+}
+
+func (m *windowMemory) load(ctx context.Context, conversationID string) (*memoryState, error) {
+	if m.state == nil {
+		return nil, fmt.Errorf("workflowai: the %s memory has no state store, see WithMemoryState", m.key)
+	}
+	data, err := m.state.LoadState(ctx, conversationID, m.key)
+	if err != nil {
+		return nil, fmt.Errorf("workflowai: loading the %s memory of conversation %s: %w", m.key, conversationID, err)
+	}
+	var state memoryState
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &state); err != nil {
+			return nil, fmt.Errorf("workflowai: decoding the %s memory of conversation %s: %w", m.key, conversationID, err)
+		}
+	}
+	return &state, nil
+}
+
+func (m *windowMemory) Context(ctx context.Context, conversationID string, history []openai.ChatCompletionMessageParamUnion) ([]openai.ChatCompletionMessageParamUnion, error) {
+	state, err := m.load(ctx, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	messages := slices.Clone(state.Pinned)
+	if text := m.recall(state); text != "" {
+		messages = append(messages, openai.SystemMessage(text))
+	}
+	return append(messages, history...), nil
+}
+
+func (m *windowMemory) Update(ctx context.Context, conversationID string, history []openai.ChatCompletionMessageParamUnion, exchange int) (int, error) {
+	state, err := m.load(ctx, conversationID)
+	if err != nil {
+		return 0, err
+	}
+	// The older messages are removed once the history has twice the window,
+	// not to update the memory at each exchange
+	cut := 0
+	if len(history) >= 2*m.window {
+		cut = windowStart(history, len(history)-m.window)
+		if cut == len(history) {
+			cut = 0
+		}
+	}
+	var older []openai.ChatCompletionMessageParamUnion
+	for _, message := range history[:cut] {
+		if message.OfSystem != nil || message.OfDeveloper != nil {
+			state.Pinned = append(state.Pinned, message)
+		} else {
+			older = append(older, message)
+		}
+	}
+	if err := m.remember(ctx, state, history[len(history)-exchange:], older); err != nil {
+		return 0, fmt.Errorf("workflowai: updating the %s memory of conversation %s: %w", m.key, conversationID, err)
+	}
+	data, err := json.Marshal(state)
+	if err != nil {
+		return 0, err
+	}
+	if err := m.state.SaveState(ctx, conversationID, m.key, data); err != nil {
+		return 0, fmt.Errorf("workflowai: saving the %s memory of conversation %s: %w", m.key, conversationID, err)
+	}
+	return len(history) - cut, nil
+}
+
+func (m *windowMemory) complete(ctx context.Context, system string, input map[string]any) (string, error) {
+	params := openai.ChatCompletionNewParams{
+		Model: m.model,
+		Messages: []openai.ChatCompletionMessageParamUnion{
+			openai.SystemMessage(system),
+			openai.UserMessage("{{messages}}"),
+		},
+		Temperature: openai.Float(0),
+	}
+	params.SetExtraFields(map[string]any{"input": input})
+	completion, err := m.completions.New(ctx, params)
+	if err != nil {
+		return "", err
+	}
+	if len(completion.Choices) == 0 || completion.Choices[0].Message.Content == "" {
+		return "", errors.New("workflowai: completion has no content")
+	}
+	return completion.Choices[0].Message.Content, nil
+}
+
+// SummaryMemory sends the last messages of the history after a rolling
+// summary of the older ones, maintained with a chat completion of model,
+// typically a cheap model such as "memory/gpt-4o-mini-latest". The summarized
+// messages are removed from the store, except the system and developer
+// messages, which are always sent.
+func SummaryMemory(completions ChatCompleter, model string, opts ...MemoryOption) ConversationMemory {
+	m := newWindowMemory(completions, model, "summary", opts)
+	m.recall = func(state *memoryState) string {
+		if state.Summary == "" {
+			return ""
+		}
+		return "Summary of the earlier conversation:\n" + state.Summary
+	}
+	m.remember = func(ctx context.Context, state *memoryState, _, older []openai.ChatCompletionMessageParamUnion) error {
+		if len(older) == 0 {
+			return nil
+		}
+		summary, err := m.complete(ctx, "Update the summary of a conversation with its next messages, for an assistant continuing the conversation. Keep the facts, decisions, user preferences and open questions, in at most 250 words. Answer with the summary only.\n\nSummary:\n{{summary}}",
+			map[string]any{"summary": state.Summary, "messages": memoryTranscript(older)})
+		if err != nil {
+			return err
+		}
+		state.Summary = strings.TrimSpace(summary)
+		return nil
+	}
+	return m
+}
+
+// EntityMemory sends the last messages of the history after the facts known
+// about the entities of the conversation, e.g. people, products or orders,
+// extracted from each exchange with a chat completion of model. The older
+// messages are removed from the store, except the system and developer
+// messages, which are always sent.
+func EntityMemory(completions ChatCompleter, model string, opts ...MemoryOption) ConversationMemory {
+	m := newWindowMemory(completions, model, "entities", opts)
+	m.recall = func(state *memoryState) string {
+		if len(state.Entities) == 0 {
+			return ""
+		}
+		var b strings.Builder
+		b.WriteString("Known facts about the entities of the conversation:")
+		names := make([]string, 0, len(state.Entities))
+		for name := range state.Entities {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		for _, name := range names {
+			fmt.Fprintf(&b, "\n- %s: %s", name, state.Entities[name])
+		}
+		return b.String()
+	}
+	m.remember = func(ctx context.Context, state *memoryState, exchange, _ []openai.ChatCompletionMessageParamUnion) error {
+		known, err := json.Marshal(state.Entities)
+		if err != nil {
+			return err
+		}
+		reply, err := m.complete(ctx, "Extract the entities of the new messages of a conversation, e.g. people, organizations, products, places or orders, with what is known about them. Answer with a JSON object mapping the name of each entity of the new messages to a description of at most two sentences, merging the known facts.\n\nKnown facts:\n{{entities}}",
+			map[string]any{"entities": string(known), "messages": memoryTranscript(exchange)})
+		if err != nil {
+			return err
+		}
+		var entities map[string]string
+		if _, err := DecodeLenientJSON([]byte(reply), &entities); err != nil {
+			return fmt.Errorf("decoding the entities: %w", err)
+		}
+		if state.Entities == nil {
+			state.Entities = map[string]string{}
+		}
+		maps.Copy(state.Entities, entities)
+		return nil
+	}
+	return m
+}
+
+// memoryTranscript returns the messages as "role: text" lines.
+func memoryTranscript(messages []openai.ChatCompletionMessageParamUnion) string {
+	var b strings.Builder
+	for _, message := range messages {
+		data, err := json.Marshal(message)
+		if err != nil {
+			continue
+		}
+		var m struct {
+			Role      string          `json:"role"`
+			Content   json.RawMessage `json:"content"`
+			ToolCalls []struct {
+				Function struct {
+					Name      string `json:"name"`
+					Arguments string `json:"arguments"`
+				} `json:"function"`
+			} `json:"tool_calls"`
+		}
+		if json.Unmarshal(data, &m) != nil {
+			continue
+		}
+		var text string
+		if json.Unmarshal(m.Content, &text) != nil {
+			var parts []struct {
+				Text string `json:"text"`
+			}
+			_ = json.Unmarshal(m.Content, &parts)
+			var texts []string
+			for _, part := range parts {
+				if part.Text != "" {
+					texts = append(texts, part.Text)
+				}
+			}
+			text = strings.Join(texts, "\n")
+		}
+		for _, call := range m.ToolCalls {
+			text += fmt.Sprintf("\n[called %s(%s)]", call.Function.Name, call.Function.Arguments)
+		}
+		if text = strings.TrimSpace(text); text != "" {
+			fmt.Fprintf(&b, "%s: %s\n", m.Role, text)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,157 @@
+package workflowai
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/openai/openai-go"
+)
+
+func messageTexts(messages []openai.ChatCompletionMessageParamUnion) []string {
+	var texts []string
+	for _, message := range messages {
+		texts = append(texts, strings.TrimSpace(memoryTranscript([]openai.ChatCompletionMessageParamUnion{message})))
+	}
+	return texts
+}
+
+func TestBufferMemory(t *testing.T) {
+	ctx := context.Background()
+	completer := &fakeCompleter{reply: "hello"}
+	manager := NewConversationManager(completer, NewMemoryConversationStore(), WithConversationMemory(BufferMemory(2)))
+	for _, text := range []string{"one", "two", "three"} {
+		if _, err := manager.Send(ctx, "conv", openai.ChatCompletionNewParams{}, openai.UserMessage(text)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := strings.Join(messageTexts(completer.calls[2]), "|"); got != "user: two|assistant: hello|user: three" {
+		t.Errorf("expected the last 2 messages, got %q", got)
+	}
+	if history, _ := manager.History(ctx, "conv"); len(history) != 6 {
+		t.Errorf("expected the full history to be stored, got %d messages", len(history))
+	}
+}
+
+func TestSummaryMemory(t *testing.T) {
+	ctx := context.Background()
+	completer := &fakeCompleter{reply: "hello"}
+	summarizer := &fakeCompleter{reply: "The user greeted the assistant."}
+	store := NewMemoryConversationStore()
+	manager := NewConversationManager(completer, store,
+		WithConversationMemory(SummaryMemory(summarizer, "memory/gpt-4o-mini", WithMemoryWindow(2))))
+
+	send := func(messages ...openai.ChatCompletionMessageParamUnion) {
+		t.Helper()
+		if _, err := manager.Send(ctx, "conv", openai.ChatCompletionNewParams{}, messages...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	send(openai.SystemMessage("Be brief."), openai.UserMessage("hi"))
+	send(openai.UserMessage("again"))
+	if len(summarizer.calls) != 1 {
+		t.Fatalf("expected one summary, got %d", len(summarizer.calls))
+	}
+	if history, _ := manager.History(ctx, "conv"); len(history) != 2 {
+		t.Errorf("expected the summarized messages to be removed, got %d messages", len(history))
+	}
+
+	send(openai.UserMessage("third"))
+	want := "system: Be brief.|system: Summary of the earlier conversation:\nThe user greeted the assistant.|user: again|assistant: hello|user: third"
+	if got := strings.Join(messageTexts(completer.calls[2]), "|"); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if len(summarizer.calls) != 2 {
+		t.Errorf("expected the summary to be updated, got %d summaries", len(summarizer.calls))
+	}
+	if state, _ := store.LoadState(ctx, "conv", "summary"); !strings.Contains(string(state), "greeted") {
+		t.Errorf("expected the summary to be stored, got %s", state)
+	}
+}
+
+func TestEntityMemory(t *testing.T) {
+	ctx := context.Background()
+	completer := &fakeCompleter{reply: "Your order 42 ships tomorrow."}
+	extractor := &fakeCompleter{reply: "```json\n{\"Alice\": \"The customer.\", \"order 42\": \"Ships tomorrow.\"}\n```"}
+	manager := NewConversationManager(completer, NewMemoryConversationStore(),
+		WithConversationMemory(EntityMemory(extractor, "memory/gpt-4o-mini")))
+
+	for _, text := range []string{"I am Alice, where is my order 42?", "Thanks"} {
+		if _, err := manager.Send(ctx, "conv", openai.ChatCompletionNewParams{}, openai.UserMessage(text)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(extractor.calls) != 2 {
+		t.Fatalf("expected an extraction per exchange, got %d", len(extractor.calls))
+	}
+	texts := messageTexts(completer.calls[1])
+	if len(texts) != 4 || texts[0] != "system: Known facts about the entities of the conversation:\n- Alice: The customer.\n- order 42: Ships tomorrow." {
+		t.Errorf("unexpected context %q", texts)
+	}
+}
+
+func TestConversationMemorySelector(t *testing.T) {
+	ctx := context.Background()
+	completer := &fakeCompleter{reply: "hello"}
+	manager := NewConversationManager(completer, NewMemoryConversationStore(),
+		WithConversationMemorySelector(func(_ context.Context, conversationID string) ConversationMemory {
+			if conversationID == "short" {
+				return BufferMemory(1)
+			}
+			return nil
+		}))
+	for _, id := range []string{"short", "full", "short", "full"} {
+		if _, err := manager.Send(ctx, id, openai.ChatCompletionNewParams{}, openai.UserMessage("hi")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(completer.calls[2]) != 2 || len(completer.calls[3]) != 3 {
+		t.Errorf("expected the memory of each conversation, got %d and %d messages", len(completer.calls[2]), len(completer.calls[3]))
+	}
+}
+
+func TestConversationMemoryErrors(t *testing.T) {
+	ctx := context.Background()
+	completer := &fakeCompleter{reply: "hello"}
+	summarizer := &fakeCompleter{err: errors.New("boom")}
+	var memoryErrs []error
+	manager := NewConversationManager(completer, NewMemoryConversationStore(),
+		WithConversationMemory(SummaryMemory(summarizer, "memory/gpt-4o-mini", WithMemoryWindow(2))),
+		WithMaxMessages(1),
+		WithMemoryErrorHandler(func(_ string, err error) { memoryErrs = append(memoryErrs, err) }))
+
+	send := func(text string) {
+		t.Helper()
+		if _, err := manager.Send(ctx, "conv", openai.ChatCompletionNewParams{}, openai.UserMessage(text)); err != nil {
+			t.Fatalf("expected the memory error not to fail the exchange, got %v", err)
+		}
+	}
+	send("one")
+	send("two")
+	if len(memoryErrs) != 1 {
+		t.Fatalf("expected the memory error to be reported, got %v", memoryErrs)
+	}
+	if history, _ := manager.History(ctx, "conv"); len(history) != 4 {
+		t.Errorf("expected the exchange to be saved and the history kept in full, got %d messages", len(history))
+	}
+
+	// The memory catches up on the next exchange
+	summarizer.err = nil
+	summarizer.reply = "The user counted."
+	send("three")
+	if history, _ := manager.History(ctx, "conv"); len(history) != 2 {
+		t.Errorf("expected the summarized messages to be removed, got %d messages", len(history))
+	}
+
+	// Without a state store, the exchanges fail before the history is changed
+	historyOnly := struct{ ConversationStore }{NewMemoryConversationStore()}
+	manager = NewConversationManager(completer, historyOnly,
+		WithConversationMemory(SummaryMemory(summarizer, "memory/gpt-4o-mini")))
+	if _, err := manager.Send(ctx, "conv", openai.ChatCompletionNewParams{}, openai.UserMessage("hi")); err == nil || !strings.Contains(err.Error(), "WithMemoryState") {
+		t.Errorf("expected a missing state store error, got %v", err)
+	}
+	if history, _ := historyOnly.Load(ctx, "conv"); len(history) != 0 {
+		t.Errorf("expected nothing to be saved, got %d messages", len(history))
+	}
+}
@@ -0,0 +1,177 @@
+package workflowai
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/openai/openai-go"
+)
+
+// MessageBuilder builds a message with mixed content parts:
+//
+//	msg, err := workflowai.Msg().User().Text("What is in this report?").File("report.pdf").Build()
+//
+// The role defaults to user. Errors, e.g. a missing file, are returned by
+// [MessageBuilder.Build].
+type MessageBuilder struct {
+	role  string
+	name  string
+	parts []openai.ChatCompletionContentPartUnionParam
+	errs  []error
+}
+
+// Msg starts a message.
+func Msg() *MessageBuilder {
+	return &MessageBuilder{role: "user"}
+}
+
+// User makes the message a user message, which accepts all the content parts.
+func (b *MessageBuilder) User() *MessageBuilder { return b.setRole("user") }
+
+// System makes the message a system message, which only accepts text.
+func (b *MessageBuilder) System() *MessageBuilder { return b.setRole("system") }
+
+// Developer makes the message a developer message, which only accepts text.
+func (b *MessageBuilder) Developer() *MessageBuilder { return b.setRole("developer") }
+
+// Assistant makes the message an assistant message, e.g. a previous reply
+// of a conversation, which only accepts text.
+func (b *MessageBuilder) Assistant() *MessageBuilder { return b.setRole("assistant") }
+
+func (b *MessageBuilder) setRole(role string) *MessageBuilder {
+	b.role = role
+	return b
+}
+
+// Name sets the name of the participant, to tell apart the users of a
+// conversation.
+func (b *MessageBuilder) Name(name string) *MessageBuilder {
+	b.name = name
+	return b
+}
+
+// Text adds a text part.
+func (b *MessageBuilder) Text(text string) *MessageBuilder {
+	return b.Part(openai.TextContentPart(text))
+}
+
+// Textf adds a text part formatted with [fmt.Sprintf].
+func (b *MessageBuilder) Textf(format string, args ...any) *MessageBuilder {
+	return b.Text(fmt.Sprintf(format, args...))
+}
+
+// Image adds an image served at url, or a data URL, with the default detail.
+func (b *MessageBuilder) Image(url string) *MessageBuilder {
+	return b.Part(ImageURLPart(url, ""))
+}
+
+// ImageDetail adds an image served at url with a detail of "auto", "low" or
+// "high".
+func (b *MessageBuilder) ImageDetail(url string, detail string) *MessageBuilder {
+	return b.Part(ImageURLPart(url, detail))
+}
+
+// ImageData adds an image sent inline, see [ImageDataPart].
+func (b *MessageBuilder) ImageData(data []byte, mimeType string) *MessageBuilder {
+	return b.Part(ImageDataPart(data, mimeType, ""))
+}
+
+// ImageFile adds a local image, see [ImageFilePart].
+func (b *MessageBuilder) ImageFile(path string) *MessageBuilder {
+	part, err := ImageFilePart(path, "")
+	return b.partOrError(part, err)
+}
+
+// File adds a document, e.g. a PDF: ref is a URL, a data URL or the path of
+// a local file, read by [DocumentFilePart].
+func (b *MessageBuilder) File(ref string) *MessageBuilder {
+	if strings.HasPrefix(ref, "http://") || strings.HasPrefix(ref, "https://") || strings.HasPrefix(ref, "data:") {
+		return b.Part(ImageURLPart(ref, ""))
+	}
+	part, err := DocumentFilePart(ref)
+	return b.partOrError(part, err)
+}
+
+// Audio adds an audio clip, format being "wav" or "mp3".
+func (b *MessageBuilder) Audio(data []byte, format string) *MessageBuilder {
+	return b.Part(openai.InputAudioContentPart(openai.ChatCompletionContentPartInputAudioInputAudioParam{
+		Data:   base64.StdEncoding.EncodeToString(data),
+		Format: format,
+	}))
+}
+
+// Part adds a content part built otherwise.
+func (b *MessageBuilder) Part(part openai.ChatCompletionContentPartUnionParam) *MessageBuilder {
+	b.parts = append(b.parts, part)
+	return b
+}
+
+func (b *MessageBuilder) partOrError(part openai.ChatCompletionContentPartUnionParam, err error) *MessageBuilder {
+	if err != nil {
+		b.errs = append(b.errs, err)
+		return b
+	}
+	return b.Part(part)
+}
+
+// Build returns the message. It fails if a part could not be read, if the
+// message is empty, or if a part is not text for a role that only accepts
+// text.
+func (b *MessageBuilder) Build() (openai.ChatCompletionMessageParamUnion, error) {
+	if len(b.errs) > 0 {
+		return openai.ChatCompletionMessageParamUnion{}, errors.Join(b.errs...)
+	}
+	if len(b.parts) == 0 {
+		return openai.ChatCompletionMessageParamUnion{}, fmt.Errorf("workflowai: empty %s message", b.role)
+	}
+	if b.role == "user" {
+		msg := openai.UserMessage(b.parts)
+		if b.name != "" {
+			msg.OfUser.Name = openai.String(b.name)
+		}
+		return msg, nil
+	}
+
+	texts := make([]openai.ChatCompletionContentPartTextParam, len(b.parts))
+	for i, part := range b.parts {
+		if part.OfText == nil {
+			return openai.ChatCompletionMessageParamUnion{}, fmt.Errorf("workflowai: %s messages only accept text, part %d is not text", b.role, i)
+		}
+		texts[i] = *part.OfText
+	}
+	var msg openai.ChatCompletionMessageParamUnion
+	switch b.role {
+	case "system":
+		msg = openai.SystemMessage(texts)
+		if b.name != "" {
+			msg.OfSystem.Name = openai.String(b.name)
+		}
+	case "developer":
+		msg = openai.DeveloperMessage(texts)
+		if b.name != "" {
+			msg.OfDeveloper.Name = openai.String(b.name)
+		}
+	case "assistant":
+		parts := make([]openai.ChatCompletionAssistantMessageParamContentArrayOfContentPartUnion, len(texts))
+		for i := range texts {
+			parts[i].OfText = &texts[i]
+		}
+		msg = openai.AssistantMessage(parts)
+		if b.name != "" {
+			msg.OfAssistant.Name = openai.String(b.name)
+		}
+	}
+	return msg, nil
+}
+
+// MustBuild is like [MessageBuilder.Build] but panics on errors, for
+// messages defined in code.
+func (b *MessageBuilder) MustBuild() openai.ChatCompletionMessageParamUnion {
+	msg, err := b.Build()
+	if err != nil {
+		panic(err)
+	}
+	return msg
+}
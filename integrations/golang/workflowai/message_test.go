@@ -0,0 +1,49 @@
+package workflowai
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMessageBuilder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.pdf")
+	os.WriteFile(path, []byte("%PDF-1.7\n"), 0o644)
+	msg, err := Msg().User().Name("alice").
+		Text("Compare").
+		ImageDetail("https://example.com/chart.png", "high").
+		File(path).
+		Audio([]byte("RIFF"), "wav").
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := json.Marshal(msg)
+	want := `{"content":[{"text":"Compare","type":"text"},` +
+		`{"image_url":{"url":"https://example.com/chart.png","detail":"high"},"type":"image_url"},` +
+		`{"image_url":{"url":"data:application/pdf;base64,JVBERi0xLjcK"},"type":"image_url"},` +
+		`{"input_audio":{"data":"UklGRg==","format":"wav"},"type":"input_audio"}],"name":"alice","role":"user"}`
+	if string(got) != want {
+		t.Errorf("unexpected message\n%s\nwant\n%s", got, want)
+	}
+
+	system, _ := json.Marshal(Msg().System().Text("Be brief.").Textf("Answer in %s.", "French").MustBuild())
+	if string(system) != `{"content":[{"text":"Be brief.","type":"text"},{"text":"Answer in French.","type":"text"}],"role":"system"}` {
+		t.Errorf("unexpected system message %s", system)
+	}
+	assistant, _ := json.Marshal(Msg().Assistant().Text("Bonjour").MustBuild())
+	if string(assistant) != `{"content":[{"text":"Bonjour","type":"text"}],"role":"assistant"}` {
+		t.Errorf("unexpected assistant message %s", assistant)
+	}
+
+	for name, b := range map[string]*MessageBuilder{
+		"empty":        Msg(),
+		"system image": Msg().System().Image("https://example.com/cat.png"),
+		"missing file": Msg().Text("Summarize").File(filepath.Join(t.TempDir(), "missing.pdf")),
+	} {
+		if _, err := b.Build(); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
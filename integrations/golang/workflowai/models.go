@@ -0,0 +1,112 @@
+package workflowai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+// ModelModalities are the kinds of content a model accepts or produces.
+type ModelModalities struct {
+	Text  bool `json:"text"`
+	Image bool `json:"image"`
+	Audio bool `json:"audio"`
+	PDF   bool `json:"pdf"`
+}
+
+// ModelSupports are the capabilities of a model, as listed by the WorkflowAI
+// models endpoint.
+type ModelSupports struct {
+	Input             ModelModalities `json:"input"`
+	Output            ModelModalities `json:"output"`
+	Tools             bool            `json:"tools"`
+	ParallelToolCalls bool            `json:"parallel_tool_calls"`
+	Temperature       bool            `json:"temperature"`
+	TopP              bool            `json:"top_p"`
+}
+
+// ModelCapabilities returns the capabilities of a model of the models list.
+// The OpenAI API does not list them, so an error is returned for models that
+// do not come from WorkflowAI.
+func ModelCapabilities(model openai.Model) (ModelSupports, error) {
+	var supports ModelSupports
+	field, ok := model.JSON.ExtraFields["supports"]
+	if !ok {
+		return supports, fmt.Errorf("workflowai: model %s has no capabilities", model.ID)
+	}
+	if err := json.Unmarshal([]byte(field.Raw()), &supports); err != nil {
+		return supports, fmt.Errorf("workflowai: decoding the capabilities of %s: %w", model.ID, err)
+	}
+	return supports, nil
+}
+
+// FindModelCapabilities lists the models and returns the capabilities of
+// modelID.
+func FindModelCapabilities(ctx context.Context, client openai.Client, modelID string, opts ...option.RequestOption) (ModelSupports, error) {
+	models := client.Models.ListAutoPaging(ctx, opts...)
+	for models.Next() {
+		if model := models.Current(); model.ID == modelID {
+			return ModelCapabilities(model)
+		}
+	}
+	if err := models.Err(); err != nil {
+		return ModelSupports{}, fmt.Errorf("workflowai: listing models: %w", err)
+	}
+	return ModelSupports{}, fmt.Errorf("workflowai: unknown model %s", modelID)
+}
+
+// ModelPricing is the price of a model in USD per token.
+type ModelPricing struct {
+	InputTokenUSD  float64 `json:"input_token_usd"`
+	OutputTokenUSD float64 `json:"output_token_usd"`
+}
+
+type ModelContextWindow struct {
+	// MaxTokens is the maximum number of input and output tokens combined
+	MaxTokens       int64 `json:"max_tokens"`
+	MaxOutputTokens int64 `json:"max_output_tokens"`
+}
+
+// ModelReasoning are the reasoning budgets of a model, in tokens.
+type ModelReasoning struct {
+	CanBeDisabled               bool  `json:"can_be_disabled"`
+	LowEffortReasoningBudget    int64 `json:"low_effort_reasoning_budget"`
+	MediumEffortReasoningBudget int64 `json:"medium_effort_reasoning_budget"`
+	HighEffortReasoningBudget   int64 `json:"high_effort_reasoning_budget"`
+	MinReasoningBudget          int64 `json:"min_reasoning_budget"`
+	MaxReasoningBudget          int64 `json:"max_reasoning_budget"`
+}
+
+// ModelInfo is a model of the WorkflowAI models catalog.
+type ModelInfo struct {
+	ID            string             `json:"id"`
+	DisplayName   string             `json:"display_name"`
+	IconURL       string             `json:"icon_url,omitempty"`
+	Supports      ModelSupports      `json:"supports"`
+	Pricing       ModelPricing       `json:"pricing"`
+	ReleaseDate   string             `json:"release_date"`
+	Reasoning     *ModelReasoning    `json:"reasoning,omitempty"`
+	ContextWindow ModelContextWindow `json:"context_window"`
+}
+
+// Released returns the release date of the model, zero when unknown.
+func (m ModelInfo) Released() time.Time {
+	t, _ := time.Parse(time.DateOnly, m.ReleaseDate)
+	return t
+}
+
+// ListModels returns the models catalog, with the capabilities, pricing and
+// context window of each model.
+func ListModels(ctx context.Context, client openai.Client, opts ...option.RequestOption) ([]ModelInfo, error) {
+	var page struct {
+		Data []ModelInfo `json:"data"`
+	}
+	if err := client.Get(ctx, "models", nil, &page, opts...); err != nil {
+		return nil, fmt.Errorf("workflowai: listing models: %w", err)
+	}
+	return page.Data, nil
+}
@@ -0,0 +1,70 @@
+package workflowai
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/openai/openai-go/option"
+)
+
+func TestFindModelCapabilities(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]any{"object": "list", "data": []map[string]any{
+			{"id": "gpt-4o", "object": "model", "created": 1, "owned_by": "WorkflowAI", "supports": map[string]any{
+				"input":  map[string]any{"text": true, "image": true, "pdf": true},
+				"output": map[string]any{"text": true},
+				"tools":  true,
+			}},
+			{"id": "o1-mini", "object": "model", "created": 1, "owned_by": "openai"},
+		}})
+	}))
+	defer server.Close()
+	client := NewClient(option.WithBaseURL(server.URL+"/v1"), option.WithAPIKey("test"), option.WithMaxRetries(0))
+	ctx := context.Background()
+
+	supports, err := FindModelCapabilities(ctx, client.Client, "gpt-4o")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !supports.Input.Image || !supports.Input.PDF || supports.Input.Audio || !supports.Tools {
+		t.Errorf("unexpected capabilities %+v", supports)
+	}
+	if _, err := FindModelCapabilities(ctx, client.Client, "o1-mini"); err == nil {
+		t.Error("expected an error for a model without capabilities")
+	}
+	if _, err := FindModelCapabilities(ctx, client.Client, "unknown"); err == nil || !strings.Contains(err.Error(), "unknown model") {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestListModels(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]any{"object": "list", "data": []map[string]any{{
+			"id": "gpt-4o", "object": "model", "created": 1, "owned_by": "WorkflowAI", "display_name": "GPT-4o",
+			"supports":       map[string]any{"input": map[string]any{"text": true}, "tools": true},
+			"pricing":        map[string]any{"input_token_usd": 2.5e-6, "output_token_usd": 1e-5},
+			"release_date":   "2024-05-13",
+			"context_window": map[string]any{"max_tokens": 128000, "max_output_tokens": 16384},
+		}}})
+	}))
+	defer server.Close()
+	client := NewClient(option.WithBaseURL(server.URL+"/v1"), option.WithAPIKey("test"), option.WithMaxRetries(0))
+
+	models, err := ListModels(context.Background(), client.Client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(models) != 1 {
+		t.Fatalf("unexpected models %+v", models)
+	}
+	m := models[0]
+	if m.DisplayName != "GPT-4o" || !m.Supports.Tools || m.Pricing.InputTokenUSD != 2.5e-6 || m.ContextWindow.MaxTokens != 128000 || m.Reasoning != nil {
+		t.Errorf("unexpected model %+v", m)
+	}
+	if m.Released().Year() != 2024 {
+		t.Errorf("unexpected release date %v", m.Released())
+	}
+}
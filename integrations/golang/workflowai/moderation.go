@@ -0,0 +1,276 @@
+package workflowai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+// ModerationVerdict is the result of screening a text.
+type ModerationVerdict struct {
+	Flagged    bool
+	Categories []string
+}
+
+// Moderator screens a text, e.g. with a moderation model, a keyword list or a
+// custom classifier.
+type Moderator interface {
+	Moderate(ctx context.Context, text string) (ModerationVerdict, error)
+}
+
+// ModeratorFunc adapts a function to the Moderator interface.
+type ModeratorFunc func(ctx context.Context, text string) (ModerationVerdict, error)
+
+func (f ModeratorFunc) Moderate(ctx context.Context, text string) (ModerationVerdict, error) {
+	return f(ctx, text)
+}
+
+// KeywordModerator flags the texts containing one of the keywords as whole
+// words, ignoring case, with the given category.
+func KeywordModerator(category string, keywords ...string) Moderator {
+	quoted := make([]string, len(keywords))
+	for i, k := range keywords {
+		quoted[i] = regexp.QuoteMeta(k)
+	}
+	// The keywords are quoted, so the pattern is valid
+	pattern, _ := compilePattern(`(?i)\b(?:` + strings.Join(quoted, "|") + `)\b`)
+	return ModeratorFunc(func(_ context.Context, text string) (ModerationVerdict, error) {
+		if len(keywords) > 0 && pattern.MatchString(text) {
+			return ModerationVerdict{Flagged: true, Categories: []string{category}}, nil
+		}
+		return ModerationVerdict{}, nil
+	})
+}
+
+// OpenAIModerator screens texts with the moderation endpoint of an OpenAI
+// client, e.g. with the "omni-moderation-latest" model.
+func OpenAIModerator(moderations *openai.ModerationService, model string) Moderator {
+	return ModeratorFunc(func(ctx context.Context, text string) (ModerationVerdict, error) {
+		res, err := moderations.New(ctx, openai.ModerationNewParams{
+			Input: openai.ModerationNewParamsInputUnion{OfString: openai.String(text)},
+			Model: openai.ModerationModel(model),
+		})
+		if err != nil {
+			return ModerationVerdict{}, fmt.Errorf("workflowai: moderating text: %w", err)
+		}
+		var verdict ModerationVerdict
+		for _, result := range res.Results {
+			if !result.Flagged {
+				continue
+			}
+			verdict.Flagged = true
+			var categories map[string]bool
+			_ = json.Unmarshal([]byte(result.Categories.RawJSON()), &categories)
+			for name, flagged := range categories {
+				if flagged {
+					verdict.Categories = append(verdict.Categories, name)
+				}
+			}
+		}
+		sort.Strings(verdict.Categories)
+		return verdict, nil
+	})
+}
+
+// ModerationStage is the part of the exchange a rule screens.
+type ModerationStage string
+
+const (
+	// ModerationInput screens the user messages before the request is sent
+	ModerationInput ModerationStage = "input"
+	// ModerationOutput screens the content of the completion before it is returned
+	ModerationOutput ModerationStage = "output"
+)
+
+// ModerationAction is what happens when a rule flags a text.
+type ModerationAction string
+
+const (
+	// ModerationBlock fails the call with a [*ModerationError]
+	ModerationBlock ModerationAction = "block"
+	// ModerationFlag lets the text through. Flagged inputs are sent with the
+	// "moderation_flagged" and "moderation_categories" metadata, flagged
+	// outputs are reported to the flag handler of the pipeline.
+	ModerationFlag ModerationAction = "flag"
+	// ModerationRewrite replaces the text with the result of the rule's
+	// Rewrite function.
+	ModerationRewrite ModerationAction = "rewrite"
+)
+
+// ModerationRule applies a moderator to a stage.
+type ModerationRule struct {
+	Stage     ModerationStage
+	Moderator Moderator
+	// Action defaults to ModerationFlag
+	Action ModerationAction
+	// Rewrite returns the replacement of a flagged text for the rewrite
+	// action. Defaults to replacing the text with "[removed]".
+	Rewrite func(text string, verdict ModerationVerdict) string
+}
+
+// ModerationError is returned when a rule blocks an input or an output.
+type ModerationError struct {
+	Stage   ModerationStage
+	Verdict ModerationVerdict
+}
+
+func (e *ModerationError) Error() string {
+	return fmt.Sprintf("workflowai: %s blocked by moderation (%s)", e.Stage, strings.Join(e.Verdict.Categories, ", "))
+}
+
+// ModerationPipeline screens the user messages of each request and the
+// content of each completion with its rules, in order. A rule that fails to
+// moderate fails the call, so that texts are never let through unscreened.
+//
+// Output screening requires the full completion, so the pipeline does not
+// support streaming.
+type ModerationPipeline struct {
+	completions ChatCompleter
+	rules       []ModerationRule
+	onFlag      func(ModerationStage, ModerationVerdict, *openai.ChatCompletion)
+}
+
+var _ ChatCompleter = (*ModerationPipeline)(nil)
+
+type ModerationOption func(*ModerationPipeline)
+
+// WithModerationFlagHandler is called for every flagged input or output. The
+// completion is nil for inputs. By default flags are logged with the standard
+// logger.
+func WithModerationFlagHandler(fn func(stage ModerationStage, verdict ModerationVerdict, completion *openai.ChatCompletion)) ModerationOption {
+	return func(p *ModerationPipeline) {
+		p.onFlag = fn
+	}
+}
+
+func NewModerationPipeline(completions ChatCompleter, rules []ModerationRule, opts ...ModerationOption) *ModerationPipeline {
+	p := &ModerationPipeline{
+		completions: completions,
+		rules:       rules,
+		onFlag: func(stage ModerationStage, verdict ModerationVerdict, _ *openai.ChatCompletion) {
+			log.Printf("workflowai: %s flagged by moderation (%s)", stage, strings.Join(verdict.Categories, ", "))
+		},
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// screen applies the rules of stage to text and returns the text to use and
+// the verdicts of the flag rules.
+func (p *ModerationPipeline) screen(ctx context.Context, stage ModerationStage, text string) (string, []ModerationVerdict, error) {
+	var flags []ModerationVerdict
+	for _, rule := range p.rules {
+		if rule.Stage != stage || strings.TrimSpace(text) == "" {
+			continue
+		}
+		verdict, err := rule.Moderator.Moderate(ctx, text)
+		if err != nil {
+			return "", nil, err
+		}
+		if !verdict.Flagged {
+			continue
+		}
+		switch rule.Action {
+		case ModerationBlock:
+			return "", nil, &ModerationError{Stage: stage, Verdict: verdict}
+		case ModerationRewrite:
+			if rule.Rewrite != nil {
+				text = rule.Rewrite(text, verdict)
+			} else {
+				text = "[removed]"
+			}
+		default:
+			flags = append(flags, verdict)
+		}
+	}
+	return text, flags, nil
+}
+
+func (p *ModerationPipeline) New(ctx context.Context, body openai.ChatCompletionNewParams, opts ...option.RequestOption) (*openai.ChatCompletion, error) {
+	// Messages are copied since rewrites modify them
+	body.Messages = append([]openai.ChatCompletionMessageParamUnion{}, body.Messages...)
+	var flags []ModerationVerdict
+	for i, message := range body.Messages {
+		if message.OfUser == nil {
+			continue
+		}
+		user := *message.OfUser
+		if user.Content.OfString.Valid() {
+			text, f, err := p.screen(ctx, ModerationInput, user.Content.OfString.Value)
+			if err != nil {
+				return nil, err
+			}
+			user.Content.OfString = openai.String(text)
+			flags = append(flags, f...)
+		}
+		parts := append([]openai.ChatCompletionContentPartUnionParam{}, user.Content.OfArrayOfContentParts...)
+		for j, part := range parts {
+			if part.OfText == nil {
+				continue
+			}
+			text, f, err := p.screen(ctx, ModerationInput, part.OfText.Text)
+			if err != nil {
+				return nil, err
+			}
+			parts[j] = openai.TextContentPart(text)
+			flags = append(flags, f...)
+		}
+		if len(parts) > 0 {
+			user.Content.OfArrayOfContentParts = parts
+		}
+		body.Messages[i] = openai.ChatCompletionMessageParamUnion{OfUser: &user}
+	}
+	if len(flags) > 0 {
+		categories := flaggedCategories(flags)
+		p.onFlag(ModerationInput, ModerationVerdict{Flagged: true, Categories: categories}, nil)
+		opts = append(opts,
+			option.WithJSONSet("metadata.moderation_flagged", "true"),
+			option.WithJSONSet("metadata.moderation_categories", strings.Join(categories, ",")),
+		)
+	}
+
+	completion, err := p.completions.New(ctx, body, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	flags = nil
+	for i := range completion.Choices {
+		message := &completion.Choices[i].Message
+		text, f, err := p.screen(ctx, ModerationOutput, message.Content)
+		if err != nil {
+			return nil, err
+		}
+		message.Content = text
+		flags = append(flags, f...)
+	}
+	if len(flags) > 0 {
+		p.onFlag(ModerationOutput, ModerationVerdict{Flagged: true, Categories: flaggedCategories(flags)}, completion)
+	}
+	return completion, nil
+}
+
+// flaggedCategories returns the sorted union of the categories of verdicts.
+func flaggedCategories(verdicts []ModerationVerdict) []string {
+	seen := map[string]bool{}
+	var categories []string
+	for _, v := range verdicts {
+		for _, c := range v.Categories {
+			if !seen[c] {
+				seen[c] = true
+				categories = append(categories, c)
+			}
+		}
+	}
+	sort.Strings(categories)
+	return categories
+}
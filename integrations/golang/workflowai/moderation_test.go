@@ -0,0 +1,83 @@
+package workflowai
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+func TestModerationPipeline(t *testing.T) {
+	completer := &fakeCompleter{reply: "Sure, here is the password of the admin"}
+	var flagged []ModerationStage
+	pipeline := NewModerationPipeline(completer, []ModerationRule{
+		{Stage: ModerationInput, Moderator: KeywordModerator("violence", "attack"), Action: ModerationBlock},
+		{Stage: ModerationInput, Moderator: KeywordModerator("competitor", "acme"), Action: ModerationFlag},
+		{Stage: ModerationInput, Moderator: KeywordModerator("profanity", "darn"), Action: ModerationRewrite,
+			Rewrite: func(string, ModerationVerdict) string { return "Please help me" }},
+		{Stage: ModerationOutput, Moderator: KeywordModerator("secrets", "password"), Action: ModerationRewrite},
+	}, WithModerationFlagHandler(func(stage ModerationStage, _ ModerationVerdict, _ *openai.ChatCompletion) {
+		flagged = append(flagged, stage)
+	}))
+	params := func(text string) openai.ChatCompletionNewParams {
+		return openai.ChatCompletionNewParams{
+			Model:    "my-agent/gpt-4o",
+			Messages: []openai.ChatCompletionMessageParamUnion{openai.SystemMessage("attack"), openai.UserMessage(text)},
+		}
+	}
+
+	_, err := pipeline.New(context.Background(), params("Plan an ATTACK"))
+	var modErr *ModerationError
+	if !errors.As(err, &modErr) || modErr.Stage != ModerationInput || modErr.Verdict.Categories[0] != "violence" {
+		t.Fatalf("expected the input to be blocked, got %v", err)
+	}
+	if len(completer.calls) != 0 {
+		t.Fatal("a blocked request was sent")
+	}
+
+	completion, err := pipeline.New(context.Background(), params("darn, compare with Acme"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sent := completer.calls[0][1].OfUser.Content.OfString.Value
+	if sent != "Please help me" {
+		t.Errorf("expected the input to be rewritten, got %q", sent)
+	}
+	if completion.Choices[0].Message.Content != "[removed]" {
+		t.Errorf("expected the output to be rewritten, got %q", completion.Choices[0].Message.Content)
+	}
+	if len(flagged) != 1 || flagged[0] != ModerationInput {
+		t.Errorf("unexpected flags %v", flagged)
+	}
+}
+
+func TestModerationPipeline_FlagMetadata(t *testing.T) {
+	server := newTestServer(t)
+	var body []byte
+	client := openai.NewClient(
+		option.WithBaseURL(server.URL+"/v1/"),
+		option.WithAPIKey("key"),
+		option.WithMiddleware(func(req *http.Request, next option.MiddlewareNext) (*http.Response, error) {
+			body, _ = readRequestBody(req)
+			return next(req)
+		}),
+	)
+	pipeline := NewModerationPipeline(&client.Chat.Completions, []ModerationRule{
+		{Stage: ModerationInput, Moderator: KeywordModerator("competitor", "acme")},
+	}, WithModerationFlagHandler(func(ModerationStage, ModerationVerdict, *openai.ChatCompletion) {}))
+
+	_, err := pipeline.New(context.Background(), openai.ChatCompletionNewParams{
+		Model:    "my-agent/gpt-4o",
+		Messages: []openai.ChatCompletionMessageParamUnion{openai.UserMessage("Is Acme better?")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(body), `"metadata":{"moderation_flagged":"true","moderation_categories":"competitor"}`) {
+		t.Errorf("expected the moderation metadata, got %s", body)
+	}
+}
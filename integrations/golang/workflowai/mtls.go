@@ -0,0 +1,185 @@
+package workflowai
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/openai/openai-go/option"
+)
+
+// MTLSConfig configures the client certificate presented to a gateway that
+// enforces mutual TLS, and the certificate authorities trusted for the
+// server certificate.
+type MTLSConfig struct {
+	// CertFile and KeyFile are the PEM encoded client certificate and key
+	CertFile string
+	KeyFile  string
+	// CAFile is a PEM bundle of the authorities trusted for the server
+	// certificate. Defaults to the system pool.
+	CAFile string
+	// ReloadInterval is how often the files are checked for changes, so that
+	// rotated certificates are used without restarting. Defaults to 1 minute,
+	// a negative value disables reloading.
+	ReloadInterval time.Duration
+	// OnReloadError is called when rotated files cannot be loaded, in which
+	// case the previous certificates are kept. By default errors are logged
+	// with the standard logger.
+	OnReloadError func(error)
+}
+
+// NewMTLSTransport returns an HTTP transport that authenticates with the
+// client certificate of cfg. The files are loaded once before returning, so
+// that a misconfiguration is reported at startup.
+//
+// When the files change, new connections use the new certificates and idle
+// connections are closed. In-flight requests finish on their connection.
+func NewMTLSTransport(cfg MTLSConfig) (*http.Transport, error) {
+	if cfg.CertFile == "" || cfg.KeyFile == "" {
+		return nil, errors.New("workflowai: a client certificate and key are required")
+	}
+	if cfg.ReloadInterval == 0 {
+		cfg.ReloadInterval = time.Minute
+	}
+	if cfg.OnReloadError == nil {
+		cfg.OnReloadError = func(err error) {
+			log.Printf("workflowai: keeping the previous certificates: %v", err)
+		}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	r := &certReloader{cfg: cfg, transport: transport}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	transport.TLSClientConfig = &tls.Config{
+		MinVersion:           tls.VersionTLS12,
+		GetClientCertificate: r.clientCertificate,
+	}
+	if cfg.CAFile != "" {
+		// The server certificate is verified in VerifyConnection against the
+		// current pool, which the static RootCAs field cannot express.
+		transport.TLSClientConfig.InsecureSkipVerify = true
+		transport.TLSClientConfig.VerifyConnection = r.verifyConnection
+	}
+	return transport, nil
+}
+
+// MTLSClientOptions returns the client options that send requests through
+// [NewMTLSTransport].
+func MTLSClientOptions(cfg MTLSConfig) ([]option.RequestOption, error) {
+	transport, err := NewMTLSTransport(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return []option.RequestOption{option.WithHTTPClient(&http.Client{Transport: transport})}, nil
+}
+
+type certReloader struct {
+	cfg       MTLSConfig
+	transport *http.Transport
+
+	mu       sync.Mutex
+	cert     *tls.Certificate
+	pool     *x509.CertPool
+	modTimes [3]time.Time
+	checked  time.Time
+}
+
+func (r *certReloader) files() [3]string {
+	return [3]string{r.cfg.CertFile, r.cfg.KeyFile, r.cfg.CAFile}
+}
+
+// load reads the files and replaces the certificates. It must be called with
+// the lock held, or before the reloader is shared.
+func (r *certReloader) load() error {
+	var modTimes [3]time.Time
+	for i, path := range r.files() {
+		if path == "" {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return fmt.Errorf("workflowai: loading certificates: %w", err)
+		}
+		modTimes[i] = info.ModTime()
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.cfg.CertFile, r.cfg.KeyFile)
+	if err != nil {
+		return fmt.Errorf("workflowai: loading the client certificate: %w", err)
+	}
+	var pool *x509.CertPool
+	if r.cfg.CAFile != "" {
+		pem, err := os.ReadFile(r.cfg.CAFile)
+		if err != nil {
+			return fmt.Errorf("workflowai: loading the CA bundle: %w", err)
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("workflowai: no certificate found in %s", r.cfg.CAFile)
+		}
+	}
+
+	r.cert, r.pool, r.modTimes = &cert, pool, modTimes
+	r.checked = time.Now()
+	return nil
+}
+
+// current returns the certificates, reloading them first if the files
+// changed since the last check.
+func (r *certReloader) current() (*tls.Certificate, *x509.CertPool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.cfg.ReloadInterval < 0 || time.Since(r.checked) < r.cfg.ReloadInterval {
+		return r.cert, r.pool
+	}
+	r.checked = time.Now()
+
+	changed := false
+	for i, path := range r.files() {
+		if path == "" {
+			continue
+		}
+		if info, err := os.Stat(path); err != nil || !info.ModTime().Equal(r.modTimes[i]) {
+			changed = true
+		}
+	}
+	if changed {
+		if err := r.load(); err != nil {
+			r.cfg.OnReloadError(err)
+		} else {
+			// Idle connections were authenticated with the previous certificate
+			go r.transport.CloseIdleConnections()
+		}
+	}
+	return r.cert, r.pool
+}
+
+func (r *certReloader) clientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	cert, _ := r.current()
+	return cert, nil
+}
+
+func (r *certReloader) verifyConnection(cs tls.ConnectionState) error {
+	if len(cs.PeerCertificates) == 0 {
+		return errors.New("workflowai: the server did not present a certificate")
+	}
+	_, pool := r.current()
+	intermediates := x509.NewCertPool()
+	for _, cert := range cs.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err := cs.PeerCertificates[0].Verify(x509.VerifyOptions{
+		DNSName:       cs.ServerName,
+		Roots:         pool,
+		Intermediates: intermediates,
+	})
+	return err
+}
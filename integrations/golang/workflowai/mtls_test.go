@@ -0,0 +1,170 @@
+package workflowai
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue returns the PEM encoded certificate and key of a leaf signed by the CA.
+func (ca *testCA) issue(t *testing.T, name string, usage x509.ExtKeyUsage) ([]byte, []byte) {
+	t.Helper()
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func writeFile(t *testing.T, path string, data []byte, modTime time.Time) {
+	t.Helper()
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNewMTLSTransport(t *testing.T) {
+	ca := newTestCA(t)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	serverCert, serverKey := ca.issue(t, "server", x509.ExtKeyUsageServerAuth)
+	pair, err := tls.X509KeyPair(serverCert, serverKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(ca.cert)
+	server.TLS = &tls.Config{Certificates: []tls.Certificate{pair}, ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	server.StartTLS()
+	defer server.Close()
+
+	dir := t.TempDir()
+	cfg := MTLSConfig{
+		CertFile:       filepath.Join(dir, "client.crt"),
+		KeyFile:        filepath.Join(dir, "client.key"),
+		CAFile:         filepath.Join(dir, "ca.crt"),
+		ReloadInterval: time.Nanosecond,
+	}
+	modTime := time.Now().Add(-time.Minute)
+	cert, key := ca.issue(t, "client-1", x509.ExtKeyUsageClientAuth)
+	writeFile(t, cfg.CertFile, cert, modTime)
+	writeFile(t, cfg.KeyFile, key, modTime)
+	writeFile(t, cfg.CAFile, ca.pem, modTime)
+
+	transport, err := NewMTLSTransport(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	transport.DisableKeepAlives = true
+	client := &http.Client{Transport: transport}
+	get := func() string {
+		t.Helper()
+		res, err := client.Get(server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		var buf [64]byte
+		n, _ := res.Body.Read(buf[:])
+		return string(buf[:n])
+	}
+	if name := get(); name != "client-1" {
+		t.Errorf("expected client-1, got %q", name)
+	}
+
+	cert, key = ca.issue(t, "client-2", x509.ExtKeyUsageClientAuth)
+	writeFile(t, cfg.CertFile, cert, time.Now())
+	writeFile(t, cfg.KeyFile, key, time.Now())
+	if name := get(); name != "client-2" {
+		t.Errorf("expected the rotated certificate, got %q", name)
+	}
+
+	// An invalid rotation keeps the previous certificate
+	var reloadErr error
+	cfg.OnReloadError = func(err error) { reloadErr = err }
+	transport, err = NewMTLSTransport(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	transport.DisableKeepAlives = true
+	client.Transport = transport
+	writeFile(t, cfg.KeyFile, []byte("invalid"), time.Now().Add(time.Minute))
+	if name := get(); name != "client-2" || reloadErr == nil {
+		t.Errorf("expected the previous certificate and a reload error, got %q, %v", name, reloadErr)
+	}
+
+	// The server certificate is not signed by the system pool
+	otherCA := newTestCA(t)
+	writeFile(t, cfg.CAFile, otherCA.pem, modTime)
+	writeFile(t, cfg.KeyFile, key, modTime)
+	transport, err = NewMTLSTransport(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := (&http.Client{Transport: transport}).Get(server.URL); err == nil {
+		t.Error("expected the server certificate to be rejected")
+	}
+}
+
+func TestNewMTLSTransport_Missing(t *testing.T) {
+	if _, err := NewMTLSTransport(MTLSConfig{}); err == nil {
+		t.Error("expected an error without certificate")
+	}
+	if _, err := NewMTLSTransport(MTLSConfig{CertFile: "missing.crt", KeyFile: "missing.key"}); err == nil {
+		t.Error("expected an error for missing files")
+	}
+}
@@ -0,0 +1,29 @@
+package workflowai
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/openai/openai-go/option"
+	"golang.org/x/oauth2"
+)
+
+// TokenSourceMiddleware returns a client middleware that authenticates
+// requests with a bearer token from ts instead of a static API key, e.g. the
+// token source of a [golang.org/x/oauth2/clientcredentials.Config] federated
+// with an identity provider. Tokens are cached until they expire, then
+// refreshed from ts.
+//
+// The token replaces the Authorization header set from the API key, so the
+// client can be created without one.
+func TokenSourceMiddleware(ts oauth2.TokenSource) option.Middleware {
+	ts = oauth2.ReuseTokenSource(nil, ts)
+	return func(req *http.Request, next option.MiddlewareNext) (*http.Response, error) {
+		token, err := ts.Token()
+		if err != nil {
+			return nil, fmt.Errorf("workflowai: fetching the access token: %w", err)
+		}
+		token.SetAuthHeader(req)
+		return next(req)
+	}
+}
@@ -0,0 +1,74 @@
+package workflowai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+	"golang.org/x/oauth2"
+)
+
+type countingTokenSource struct {
+	calls  int
+	expiry time.Duration
+	err    error
+}
+
+func (s *countingTokenSource) Token() (*oauth2.Token, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	s.calls++
+	return &oauth2.Token{AccessToken: fmt.Sprintf("token-%d", s.calls), Expiry: time.Now().Add(s.expiry)}, nil
+}
+
+func TestTokenSourceMiddleware(t *testing.T) {
+	var auth []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = append(auth, r.Header.Get("Authorization"))
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, testCompletion)
+	}))
+	defer server.Close()
+	params := openai.ChatCompletionNewParams{
+		Model:    "my-agent/gpt-4o",
+		Messages: []openai.ChatCompletionMessageParamUnion{openai.UserMessage("Hi")},
+	}
+
+	src := &countingTokenSource{expiry: time.Hour}
+	client := openai.NewClient(option.WithBaseURL(server.URL), option.WithAPIKey("static"), option.WithMiddleware(TokenSourceMiddleware(src)))
+	for range 2 {
+		if _, err := client.Chat.Completions.New(context.Background(), params); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if auth[0] != "Bearer token-1" || auth[1] != "Bearer token-1" || src.calls != 1 {
+		t.Errorf("expected the cached token to be reused, got %v after %d calls", auth, src.calls)
+	}
+
+	// Tokens expiring within the refresh margin are refreshed
+	src.expiry = time.Second
+	src.calls = 0
+	auth = nil
+	client = openai.NewClient(option.WithBaseURL(server.URL), option.WithMiddleware(TokenSourceMiddleware(src)))
+	for range 2 {
+		if _, err := client.Chat.Completions.New(context.Background(), params); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if auth[1] != "Bearer token-2" {
+		t.Errorf("expected a refreshed token, got %v", auth)
+	}
+
+	src.err = errors.New("idp unavailable")
+	client = openai.NewClient(option.WithBaseURL(server.URL), option.WithMaxRetries(0), option.WithMiddleware(TokenSourceMiddleware(src)))
+	if _, err := client.Chat.Completions.New(context.Background(), params); !errors.Is(err, src.err) {
+		t.Errorf("expected the token source error, got %v", err)
+	}
+}
@@ -0,0 +1,216 @@
+package openapitools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/workflowai/workflowai/go/examples/workflowai"
+)
+
+// DefaultMaxResponseBytes bounds the responses sent to the model.
+const DefaultMaxResponseBytes = 16 << 10
+
+// Caller sends the requests of the operations called by the model.
+type Caller struct {
+	baseURL  string
+	client   *http.Client
+	auth     func(*http.Request) error
+	maxBytes int
+}
+
+type Option func(*Caller)
+
+// WithHTTPClient sets the client of the requests, http.DefaultClient by
+// default.
+func WithHTTPClient(client *http.Client) Option {
+	return func(c *Caller) {
+		c.client = client
+	}
+}
+
+// WithAuth sets a function adding the credentials to each request, e.g. to
+// sign requests. The credentials are never exposed to the model.
+func WithAuth(fn func(*http.Request) error) Option {
+	return func(c *Caller) {
+		c.auth = fn
+	}
+}
+
+// WithBearerToken authenticates the requests with a bearer token.
+func WithBearerToken(token string) Option {
+	return WithAuth(func(req *http.Request) error {
+		req.Header.Set("Authorization", "Bearer "+token)
+		return nil
+	})
+}
+
+// WithAPIKeyHeader authenticates the requests with an API key in a header,
+// e.g. "X-API-Key".
+func WithAPIKeyHeader(header string, key string) Option {
+	return WithAuth(func(req *http.Request) error {
+		req.Header.Set(header, key)
+		return nil
+	})
+}
+
+// WithMaxResponseBytes truncates the responses sent to the model,
+// [DefaultMaxResponseBytes] by default, for large responses not to fill the
+// context window. A value <= 0 disables the truncation.
+func WithMaxResponseBytes(n int) Option {
+	return func(c *Caller) {
+		c.maxBytes = n
+	}
+}
+
+// NewCaller returns a caller sending the requests to baseURL, e.g. the
+// [Spec.ServerURL].
+func NewCaller(baseURL string, opts ...Option) *Caller {
+	c := &Caller{
+		baseURL:  strings.TrimRight(baseURL, "/"),
+		client:   http.DefaultClient,
+		auth:     func(*http.Request) error { return nil },
+		maxBytes: DefaultMaxResponseBytes,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Tool returns the tool calling an operation.
+func (c *Caller) Tool(op Operation) workflowai.Tool {
+	return workflowai.Tool{
+		Name:        op.Name,
+		Description: op.Description,
+		Parameters:  op.Parameters,
+		Call: func(ctx context.Context, arguments string) (string, error) {
+			return c.Call(ctx, op, arguments)
+		},
+	}
+}
+
+// Tools returns the tools calling operations.
+func (c *Caller) Tools(ops []Operation) []workflowai.Tool {
+	tools := make([]workflowai.Tool, len(ops))
+	for i, op := range ops {
+		tools[i] = c.Tool(op)
+	}
+	return tools
+}
+
+// Call sends the request of an operation with the arguments of a tool call,
+// and returns the response body. Responses with an error status are returned
+// as errors, which are sent to the model for it to recover.
+func (c *Caller) Call(ctx context.Context, op Operation, arguments string) (string, error) {
+	var args map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(arguments), &args); err != nil {
+		return "", fmt.Errorf("invalid arguments: %w", err)
+	}
+	path := op.Path
+	query := url.Values{}
+	header := http.Header{}
+	for _, p := range op.Params {
+		raw, ok := args[p.Name]
+		if !ok || string(raw) == "null" {
+			if p.In == InPath {
+				return "", fmt.Errorf("missing path parameter %q", p.Name)
+			}
+			continue
+		}
+		values := paramValues(raw)
+		switch p.In {
+		case InPath:
+			path = strings.ReplaceAll(path, "{"+p.Name+"}", url.PathEscape(strings.Join(values, ",")))
+		case InQuery:
+			query[p.Name] = values
+		case InHeader:
+			header.Set(p.Name, strings.Join(values, ","))
+		}
+	}
+	u := c.baseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	var body io.Reader
+	if raw, ok := args[BodyArgument]; ok && op.Body {
+		body = bytes.NewReader(raw)
+	}
+	req, err := http.NewRequestWithContext(ctx, op.Method, u, body)
+	if err != nil {
+		return "", err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+	if err := c.auth(req); err != nil {
+		return "", err
+	}
+	res, err := c.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	var r io.Reader = res.Body
+	if c.maxBytes > 0 {
+		r = io.LimitReader(res.Body, int64(c.maxBytes)+1)
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+	text := c.truncate(data)
+	if res.StatusCode >= 400 {
+		return "", fmt.Errorf("%s %s: %s: %s", op.Method, path, res.Status, text)
+	}
+	return text, nil
+}
+
+// truncate cuts data to the maximum size, at a rune boundary, and notes the
+// truncation for the model.
+func (c *Caller) truncate(data []byte) string {
+	if c.maxBytes <= 0 || len(data) <= c.maxBytes {
+		return string(data)
+	}
+	data = data[:c.maxBytes]
+	// Drop the bytes of a rune cut in the middle
+	for i := 0; i < utf8.UTFMax-1 && len(data) > 0; i++ {
+		if r, size := utf8.DecodeLastRune(data); r != utf8.RuneError || size != 1 {
+			break
+		}
+		data = data[:len(data)-1]
+	}
+	return string(data) + fmt.Sprintf("\n[truncated to %d bytes]", c.maxBytes)
+}
+
+// paramValues returns the values of a parameter: the items of arrays, and
+// the text of scalars.
+func paramValues(raw json.RawMessage) []string {
+	var items []json.RawMessage
+	if json.Unmarshal(raw, &items) == nil {
+		values := make([]string, len(items))
+		for i, item := range items {
+			values[i] = scalar(item)
+		}
+		return values
+	}
+	return []string{scalar(raw)}
+}
+
+func scalar(raw json.RawMessage) string {
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return s
+	}
+	return string(raw)
+}
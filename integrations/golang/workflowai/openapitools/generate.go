@@ -0,0 +1,83 @@
+package openapitools
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"strconv"
+	"strings"
+
+	"github.com/workflowai/workflowai/go/examples/workflowai/codegen"
+)
+
+// Generate returns the source of a Go file declaring a function per
+// operation returning its tool, e.g. ListPetsTool for "listPets", and a
+// Tools function returning every tool. The output only depends on the
+// operations, so regenerating an unchanged spec yields the same file.
+func Generate(pkg string, ops []Operation) ([]byte, error) {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by \"workflowai tools\"; DO NOT EDIT.\n\n")
+	fmt.Fprintf(&b, "package %s\n\n", pkg)
+	b.WriteString("import (\n\t\"encoding/json\"\n\n")
+	b.WriteString("\t\"github.com/workflowai/workflowai/go/examples/workflowai\"\n")
+	b.WriteString("\t\"github.com/workflowai/workflowai/go/examples/workflowai/openapitools\"\n)\n\n")
+
+	names := make([]string, len(ops))
+	for i, op := range ops {
+		names[i] = codegen.GoName(op.Name) + "Tool"
+		if op.Description != "" {
+			for _, line := range strings.Split(firstParagraph(op.Description), "\n") {
+				fmt.Fprintf(&b, "// %s\n", strings.TrimSpace(line))
+			}
+			b.WriteString("//\n")
+		}
+		fmt.Fprintf(&b, "// %s calls %s %s.\n", names[i], op.Method, op.Path)
+		fmt.Fprintf(&b, "func %s(c *openapitools.Caller) workflowai.Tool {\n", names[i])
+		b.WriteString("\treturn c.Tool(openapitools.Operation{\n")
+		fmt.Fprintf(&b, "\t\tName: %s,\n", strconv.Quote(op.Name))
+		fmt.Fprintf(&b, "\t\tMethod: %s,\n", strconv.Quote(op.Method))
+		fmt.Fprintf(&b, "\t\tPath: %s,\n", strconv.Quote(op.Path))
+		if op.Description != "" {
+			fmt.Fprintf(&b, "\t\tDescription: %s,\n", strconv.Quote(op.Description))
+		}
+		if len(op.Params) > 0 {
+			b.WriteString("\t\tParams: []openapitools.Param{\n")
+			for _, p := range op.Params {
+				fmt.Fprintf(&b, "\t\t\t{Name: %s, In: %s},\n", strconv.Quote(p.Name), strconv.Quote(p.In))
+			}
+			b.WriteString("\t\t},\n")
+		}
+		if op.Body {
+			b.WriteString("\t\tBody: true,\n")
+		}
+		fmt.Fprintf(&b, "\t\tParameters: json.RawMessage(%s),\n", quote(string(op.Parameters)))
+		b.WriteString("\t})\n}\n\n")
+	}
+
+	b.WriteString("// Tools returns the tools of every operation.\n")
+	b.WriteString("func Tools(c *openapitools.Caller) []workflowai.Tool {\n\treturn []workflowai.Tool{\n")
+	for _, name := range names {
+		fmt.Fprintf(&b, "\t\t%s(c),\n", name)
+	}
+	b.WriteString("\t}\n}\n")
+
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("openapitools: formatting the generated code: %w", err)
+	}
+	return src, nil
+}
+
+func firstParagraph(s string) string {
+	paragraph, _, _ := strings.Cut(s, "\n\n")
+	return paragraph
+}
+
+// quote returns a raw string literal of s when possible, which keeps the
+// JSON schemas readable.
+func quote(s string) string {
+	if strings.Contains(s, "`") {
+		return strconv.Quote(s)
+	}
+	return "`" + s + "`"
+}
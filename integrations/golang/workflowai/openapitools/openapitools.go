@@ -0,0 +1,365 @@
+// Package openapitools exposes the operations of a REST API described by an
+// OpenAPI 3 spec as tools of workflowai agents. Each operation becomes a tool
+// whose arguments are its path, query and header parameters and its JSON
+// body, and whose call sends the HTTP request with the credentials of a
+// [Caller].
+//
+//	spec, err := openapitools.LoadFile("petstore.yaml")
+//	ops, err := spec.Operations()
+//	caller := openapitools.NewCaller("https://petstore.example.com", openapitools.WithBearerToken(token))
+//	agent.Tools = caller.Tools(ops)
+//
+// "workflowai tools" generates a Go file with a function per operation
+// instead, so that the tools are reviewed and versioned with the code.
+package openapitools
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Parameter locations.
+const (
+	InPath   = "path"
+	InQuery  = "query"
+	InHeader = "header"
+)
+
+// BodyArgument is the argument of the JSON body of an operation.
+const BodyArgument = "body"
+
+// Param is a parameter of an operation, sent in the path, the query or a
+// header.
+type Param struct {
+	Name string
+	In   string
+}
+
+// Operation is an operation of a spec, as a tool.
+type Operation struct {
+	// Name is the operation ID, or the method and the path when it has none,
+	// restricted to the characters allowed in tool names
+	Name        string
+	Method      string
+	Path        string
+	Description string
+	Params      []Param
+	// Body is set when the operation has a JSON body, in the "body" argument
+	Body bool
+	// Parameters is the JSON schema of the arguments of the tool
+	Parameters json.RawMessage
+}
+
+// Spec is an OpenAPI 3 spec.
+type Spec struct {
+	Servers []struct {
+		URL string `json:"url"`
+	} `json:"servers"`
+	Paths      map[string]map[string]json.RawMessage `json:"paths"`
+	Components struct {
+		Schemas       map[string]any `json:"schemas"`
+		Parameters    map[string]any `json:"parameters"`
+		RequestBodies map[string]any `json:"requestBodies"`
+	} `json:"components"`
+}
+
+// Parse parses a spec in JSON or YAML.
+func Parse(data []byte) (*Spec, error) {
+	if !json.Valid(data) {
+		var doc any
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("openapitools: invalid spec: %w", err)
+		}
+		var err error
+		if data, err = json.Marshal(doc); err != nil {
+			return nil, fmt.Errorf("openapitools: invalid spec: %w", err)
+		}
+	}
+	var spec Spec
+	if err := json.Unmarshal(data, &spec); err != nil {
+		return nil, fmt.Errorf("openapitools: invalid spec: %w", err)
+	}
+	return &spec, nil
+}
+
+// LoadFile reads and parses a spec file.
+func LoadFile(path string) (*Spec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("openapitools: %w", err)
+	}
+	return Parse(data)
+}
+
+// ServerURL returns the URL of the first server of the spec, or "".
+func (s *Spec) ServerURL() string {
+	if len(s.Servers) == 0 {
+		return ""
+	}
+	return s.Servers[0].URL
+}
+
+var methods = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+
+type specOperation struct {
+	OperationID string            `json:"operationId"`
+	Summary     string            `json:"summary"`
+	Description string            `json:"description"`
+	Parameters  []json.RawMessage `json:"parameters"`
+	RequestBody json.RawMessage   `json:"requestBody"`
+	Deprecated  bool              `json:"deprecated"`
+}
+
+type specParameter struct {
+	Name        string `json:"name"`
+	In          string `json:"in"`
+	Description string `json:"description"`
+	Required    bool   `json:"required"`
+	Schema      any    `json:"schema"`
+}
+
+type specRequestBody struct {
+	Description string `json:"description"`
+	Required    bool   `json:"required"`
+	Content     map[string]struct {
+		Schema any `json:"schema"`
+	} `json:"content"`
+}
+
+// Operations returns the operations of the spec sorted by path and method.
+// Deprecated operations, and operations with a body that is not JSON, are
+// skipped. The schemas referenced by the parameters are copied into the
+// "$defs" of the arguments schema.
+func (s *Spec) Operations() ([]Operation, error) {
+	paths := make([]string, 0, len(s.Paths))
+	for path := range s.Paths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	var ops []Operation
+	names := map[string]bool{}
+	for _, path := range paths {
+		item := s.Paths[path]
+		var shared []json.RawMessage
+		if raw, ok := item["parameters"]; ok {
+			if err := json.Unmarshal(raw, &shared); err != nil {
+				return nil, fmt.Errorf("openapitools: %s: invalid parameters: %w", path, err)
+			}
+		}
+		for _, method := range methods {
+			raw, ok := item[strings.ToLower(method)]
+			if !ok {
+				continue
+			}
+			var so specOperation
+			if err := json.Unmarshal(raw, &so); err != nil {
+				return nil, fmt.Errorf("openapitools: %s %s: %w", method, path, err)
+			}
+			if so.Deprecated {
+				continue
+			}
+			op, ok, err := s.operation(method, path, so, append(shared[:len(shared):len(shared)], so.Parameters...))
+			if err != nil {
+				return nil, fmt.Errorf("openapitools: %s %s: %w", method, path, err)
+			}
+			if !ok {
+				continue
+			}
+			if names[op.Name] {
+				return nil, fmt.Errorf("openapitools: %s %s: duplicate operation name %q", method, path, op.Name)
+			}
+			names[op.Name] = true
+			ops = append(ops, op)
+		}
+	}
+	return ops, nil
+}
+
+func (s *Spec) operation(method, path string, so specOperation, rawParams []json.RawMessage) (Operation, bool, error) {
+	op := Operation{Name: toolName(so.OperationID, method, path), Method: method, Path: path, Description: so.Summary}
+	if so.Description != "" {
+		op.Description = strings.TrimSpace(op.Description + "\n\n" + so.Description)
+	}
+	r := &refResolver{spec: s, defs: map[string]any{}}
+	properties := map[string]any{}
+	var required []string
+	// Parameters of the operation override the parameters of the path
+	index := map[string]int{}
+	for _, raw := range rawParams {
+		var p specParameter
+		if err := r.decode(raw, "parameters", &p); err != nil {
+			return Operation{}, false, err
+		}
+		if p.In != InPath && p.In != InQuery && p.In != InHeader {
+			// Cookies are left to the caller
+			continue
+		}
+		schema, _ := r.rewrite(p.Schema).(map[string]any)
+		if schema == nil {
+			schema = map[string]any{"type": "string"}
+		}
+		if p.Description != "" {
+			schema["description"] = p.Description
+		}
+		if i, ok := index[p.Name]; ok {
+			op.Params[i] = Param{Name: p.Name, In: p.In}
+		} else {
+			index[p.Name] = len(op.Params)
+			op.Params = append(op.Params, Param{Name: p.Name, In: p.In})
+		}
+		properties[p.Name] = schema
+		if p.Required || p.In == InPath {
+			required = append(required, p.Name)
+		}
+	}
+	if len(so.RequestBody) > 0 {
+		var body specRequestBody
+		if err := r.decode(so.RequestBody, "requestBodies", &body); err != nil {
+			return Operation{}, false, err
+		}
+		content, ok := body.Content["application/json"]
+		if !ok {
+			return Operation{}, false, nil
+		}
+		schema, _ := r.rewrite(content.Schema).(map[string]any)
+		if schema == nil {
+			schema = map[string]any{}
+		}
+		if body.Description != "" {
+			schema["description"] = body.Description
+		}
+		op.Body = true
+		properties[BodyArgument] = schema
+		if body.Required {
+			required = append(required, BodyArgument)
+		}
+	}
+	sort.Strings(required)
+	parameters := map[string]any{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		parameters["required"] = slices.Compact(required)
+	}
+	if len(r.defs) > 0 {
+		parameters["$defs"] = r.defs
+	}
+	if r.err != nil {
+		return Operation{}, false, r.err
+	}
+	data, err := json.Marshal(parameters)
+	if err != nil {
+		return Operation{}, false, err
+	}
+	op.Parameters = data
+	return op, true, nil
+}
+
+var invalidNameChars = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+// toolName returns the operation ID, or the method and the path, with the
+// characters allowed in tool names, within 64 characters.
+func toolName(operationID, method, path string) string {
+	name := operationID
+	if name == "" {
+		name = strings.ToLower(method) + "_" + path
+	}
+	name = strings.Trim(invalidNameChars.ReplaceAllString(name, "_"), "_")
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	return name
+}
+
+// refResolver resolves the local references of a spec: parameters and
+// request bodies are inlined, and schemas are copied into the "$defs" of
+// the arguments schema, which supports recursive schemas.
+type refResolver struct {
+	spec *Spec
+	defs map[string]any
+	err  error
+}
+
+// decode decodes raw into v, following a reference to the components of
+// kind.
+func (r *refResolver) decode(raw json.RawMessage, kind string, v any) error {
+	var ref struct {
+		Ref string `json:"$ref"`
+	}
+	json.Unmarshal(raw, &ref)
+	if ref.Ref == "" {
+		return json.Unmarshal(raw, v)
+	}
+	prefix := "#/components/" + kind + "/"
+	name, ok := strings.CutPrefix(ref.Ref, prefix)
+	var components map[string]any
+	switch kind {
+	case "parameters":
+		components = r.spec.Components.Parameters
+	case "requestBodies":
+		components = r.spec.Components.RequestBodies
+	}
+	target, found := components[name]
+	if !ok || !found {
+		return fmt.Errorf("unresolved reference %q", ref.Ref)
+	}
+	data, err := json.Marshal(target)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// rewrite returns a copy of a schema whose references to the components
+// point to "$defs", collecting the referenced schemas.
+func (r *refResolver) rewrite(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for key, value := range v {
+			if ref, ok := value.(string); ok && key == "$ref" {
+				out[key] = r.ref(ref)
+				continue
+			}
+			out[key] = r.rewrite(value)
+		}
+		return out
+	case []any:
+		out := make([]any, len(v))
+		for i, value := range v {
+			out[i] = r.rewrite(value)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+func (r *refResolver) ref(ref string) string {
+	name, ok := strings.CutPrefix(ref, "#/components/schemas/")
+	if !ok {
+		if r.err == nil {
+			r.err = fmt.Errorf("unsupported reference %q", ref)
+		}
+		return ref
+	}
+	if _, done := r.defs[name]; !done {
+		schema, found := r.spec.Components.Schemas[name]
+		if !found {
+			if r.err == nil {
+				r.err = fmt.Errorf("unresolved reference %q", ref)
+			}
+			return ref
+		}
+		// Reserved before rewriting for recursive schemas to terminate
+		r.defs[name] = nil
+		r.defs[name] = r.rewrite(schema)
+	}
+	return "#/$defs/" + name
+}
@@ -0,0 +1,176 @@
+package openapitools
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const petstore = `
+openapi: 3.0.3
+servers:
+  - url: https://petstore.example.com/v1
+paths:
+  /pets:
+    get:
+      operationId: listPets
+      summary: List the pets
+      parameters:
+        - $ref: '#/components/parameters/Limit'
+        - name: tags
+          in: query
+          schema:
+            type: array
+            items: {type: string}
+    post:
+      operationId: createPet
+      summary: Create a pet
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema: {$ref: '#/components/schemas/Pet'}
+  /pets/{petId}:
+    parameters:
+      - name: petId
+        in: path
+        schema: {type: integer}
+    get:
+      operationId: getPet
+      summary: Get a pet
+    delete:
+      deprecated: true
+      operationId: deletePet
+  /pets/{petId}/photo:
+    put:
+      requestBody:
+        content:
+          image/png: {}
+components:
+  parameters:
+    Limit:
+      name: limit
+      in: query
+      description: Maximum number of pets
+      schema: {type: integer, maximum: 100}
+  schemas:
+    Pet:
+      type: object
+      required: [name]
+      properties:
+        name: {type: string}
+        parent: {$ref: '#/components/schemas/Pet'}
+`
+
+func TestSpecOperations(t *testing.T) {
+	spec, err := Parse([]byte(petstore))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spec.ServerURL() != "https://petstore.example.com/v1" {
+		t.Errorf("unexpected server %q", spec.ServerURL())
+	}
+	ops, err := spec.Operations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, op := range ops {
+		names = append(names, op.Name)
+	}
+	// The deprecated operation and the PNG upload are skipped
+	if got := strings.Join(names, " "); got != "listPets createPet getPet" {
+		t.Fatalf("unexpected operations %s", got)
+	}
+	for _, tt := range []struct {
+		op   Operation
+		want string
+	}{
+		{ops[0], `{"properties":{"limit":{"description":"Maximum number of pets","maximum":100,"type":"integer"},"tags":{"items":{"type":"string"},"type":"array"}},"type":"object"}`},
+		{ops[1], `{"$defs":{"Pet":{"properties":{"name":{"type":"string"},"parent":{"$ref":"#/$defs/Pet"}},"required":["name"],"type":"object"}},"properties":{"body":{"$ref":"#/$defs/Pet"}},"required":["body"],"type":"object"}`},
+		{ops[2], `{"properties":{"petId":{"type":"integer"}},"required":["petId"],"type":"object"}`},
+	} {
+		if string(tt.op.Parameters) != tt.want {
+			t.Errorf("%s: got parameters %s", tt.op.Name, tt.op.Parameters)
+		}
+	}
+
+	if _, err := (&Spec{Paths: map[string]map[string]json.RawMessage{
+		"/a": {"get": json.RawMessage(`{"parameters": [{"$ref": "#/components/parameters/Missing"}]}`)},
+	}}).Operations(); err == nil {
+		t.Error("expected an unresolved reference error")
+	}
+}
+
+func TestCaller(t *testing.T) {
+	var got []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := make([]byte, 100)
+		n, _ := r.Body.Read(body)
+		got = append(got, r.Method+" "+r.URL.RequestURI()+" "+r.Header.Get("Authorization")+" "+string(body[:n]))
+		switch {
+		case r.URL.Path == "/v1/pets/404":
+			http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+		case r.Method == http.MethodGet:
+			w.Write([]byte(`[{"name":"` + strings.Repeat("é", 20) + `"}]`))
+		default:
+			w.Write([]byte(`{"id":1}`))
+		}
+	}))
+	defer server.Close()
+
+	spec, _ := Parse([]byte(petstore))
+	ops, _ := spec.Operations()
+	caller := NewCaller(server.URL+"/v1", WithBearerToken("secret"), WithMaxResponseBytes(21))
+	tools := caller.Tools(ops)
+	ctx := context.Background()
+
+	result, err := tools[0].Call(ctx, `{"limit": 10, "tags": ["cat", "dog"]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 21 bytes, without the last half rune
+	if result != `[{"name":"ééééé`+"\n[truncated to 21 bytes]" {
+		t.Errorf("unexpected result %q", result)
+	}
+	if _, err := tools[1].Call(ctx, `{"body": {"name": "Rex"}}`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tools[2].Call(ctx, `{"petId": 404}`); err == nil || !strings.Contains(err.Error(), "404 Not Found") {
+		t.Errorf("expected a not found error, got %v", err)
+	}
+	if _, err := tools[2].Call(ctx, `{}`); err == nil {
+		t.Error("expected a missing path parameter error")
+	}
+	want := []string{
+		`GET /v1/pets?limit=10&tags=cat&tags=dog Bearer secret `,
+		`POST /v1/pets Bearer secret {"name": "Rex"}`,
+		`GET /v1/pets/404 Bearer secret `,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("unexpected requests:\n%s", strings.Join(got, "\n"))
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	spec, _ := Parse([]byte(petstore))
+	ops, _ := spec.Operations()
+	src, err := Generate("petstore", ops)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"package petstore\n",
+		"// List the pets\n//\n// ListPetsTool calls GET /pets.\nfunc ListPetsTool(c *openapitools.Caller) workflowai.Tool {",
+		`{Name: "petId", In: "path"},`,
+		"Parameters: json.RawMessage(`{\"properties\":{\"petId\"",
+		"\t\tCreatePetTool(c),\n",
+	} {
+		if !strings.Contains(string(src), want) {
+			t.Errorf("generated code does not contain %q:\n%s", want, src)
+		}
+	}
+}
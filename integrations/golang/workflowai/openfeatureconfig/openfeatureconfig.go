@@ -0,0 +1,106 @@
+// Package openfeatureconfig resolves the model and temperature of WorkflowAI
+// requests from OpenFeature flags, for use with workflowai.FlagRouter:
+//
+//	resolver := openfeatureconfig.New(openfeature.NewClient("my-service"),
+//		openfeatureconfig.WithModelFlag("summarizer-model"))
+//	router := workflowai.NewFlagRouter(&client.Chat.Completions, resolver)
+package openfeatureconfig
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/open-feature/go-sdk/openfeature"
+	"github.com/openai/openai-go"
+
+	"github.com/workflowai/workflowai/go/examples/workflowai"
+)
+
+// Resolver evaluates a string flag for the model and, optionally, a float flag
+// for the temperature. The model of the request is the default value of the
+// model flag.
+//
+// The evaluation context has the targeting key set with
+// workflowai.ContextWithRoutingKey, and the "model" and "agent_id" attributes
+// of the request.
+type Resolver struct {
+	client          openfeature.IClient
+	modelFlag       string
+	temperatureFlag string
+	extraAttributes func(ctx context.Context) map[string]any
+}
+
+var _ workflowai.ConfigResolver = (*Resolver)(nil)
+
+type Option func(*Resolver)
+
+// WithModelFlag sets the key of the model flag. Defaults to "workflowai-model".
+func WithModelFlag(key string) Option {
+	return func(r *Resolver) {
+		r.modelFlag = key
+	}
+}
+
+// WithTemperatureFlag sets the key of the temperature flag. The temperature is
+// not resolved by default.
+func WithTemperatureFlag(key string) Option {
+	return func(r *Resolver) {
+		r.temperatureFlag = key
+	}
+}
+
+// WithAttributes adds attributes to the evaluation context, e.g. the plan of
+// the user, from the context of the request.
+func WithAttributes(fn func(ctx context.Context) map[string]any) Option {
+	return func(r *Resolver) {
+		r.extraAttributes = fn
+	}
+}
+
+func New(client openfeature.IClient, opts ...Option) *Resolver {
+	r := &Resolver{client: client, modelFlag: "workflowai-model"}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// ResolveConfig implements workflowai.ConfigResolver. When a flag fails to
+// evaluate, it returns the error and the request is sent unchanged.
+func (r *Resolver) ResolveConfig(ctx context.Context, params openai.ChatCompletionNewParams) (workflowai.RequestConfig, error) {
+	attributes := map[string]any{"model": params.Model}
+	if agentID, _, ok := strings.Cut(params.Model, "/"); ok {
+		attributes["agent_id"] = agentID
+	}
+	if r.extraAttributes != nil {
+		for k, v := range r.extraAttributes(ctx) {
+			attributes[k] = v
+		}
+	}
+	evalCtx := openfeature.NewEvaluationContext(workflowai.RoutingKey(ctx), attributes)
+
+	var config workflowai.RequestConfig
+	model, err := r.client.StringValue(ctx, r.modelFlag, params.Model, evalCtx)
+	if err != nil {
+		return workflowai.RequestConfig{}, fmt.Errorf("openfeatureconfig: evaluating %s: %w", r.modelFlag, err)
+	}
+	config.Model = model
+
+	if r.temperatureFlag != "" {
+		defaultTemperature := 1.0
+		if params.Temperature.Valid() {
+			defaultTemperature = params.Temperature.Value
+		}
+		temperature, err := r.client.FloatValue(ctx, r.temperatureFlag, defaultTemperature, evalCtx)
+		if err != nil {
+			return workflowai.RequestConfig{}, fmt.Errorf("openfeatureconfig: evaluating %s: %w", r.temperatureFlag, err)
+		}
+		// Falling back to the default must not set a temperature the
+		// request did not have
+		if params.Temperature.Valid() || temperature != defaultTemperature {
+			config.Temperature = &temperature
+		}
+	}
+	return config, nil
+}
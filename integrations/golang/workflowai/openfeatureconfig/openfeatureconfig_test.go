@@ -0,0 +1,67 @@
+package openfeatureconfig
+
+import (
+	"context"
+	"testing"
+
+	"github.com/open-feature/go-sdk/openfeature"
+	"github.com/open-feature/go-sdk/openfeature/memprovider"
+	"github.com/openai/openai-go"
+
+	"github.com/workflowai/workflowai/go/examples/workflowai"
+)
+
+func TestResolver(t *testing.T) {
+	evaluator := func(flag memprovider.InMemoryFlag, evalCtx openfeature.FlattenedContext) (any, openfeature.ProviderResolutionDetail) {
+		variant := "stable"
+		if evalCtx[openfeature.TargetingKey] == "beta-user" && evalCtx["agent_id"] == "summarizer" {
+			variant = "beta"
+		}
+		return flag.Variants[variant], openfeature.ProviderResolutionDetail{Reason: openfeature.TargetingMatchReason, Variant: variant}
+	}
+	provider := memprovider.NewInMemoryProvider(map[string]memprovider.InMemoryFlag{
+		"summarizer-model": {
+			Key:              "summarizer-model",
+			State:            memprovider.Enabled,
+			DefaultVariant:   "stable",
+			Variants:         map[string]any{"stable": "summarizer/#1/production", "beta": "summarizer/#2/production"},
+			ContextEvaluator: &evaluator,
+		},
+		"summarizer-temperature": {
+			Key:            "summarizer-temperature",
+			State:          memprovider.Enabled,
+			DefaultVariant: "low",
+			Variants:       map[string]any{"low": 0.2},
+		},
+	})
+	if err := openfeature.SetProviderAndWait(provider); err != nil {
+		t.Fatal(err)
+	}
+
+	resolver := New(openfeature.NewClient("test"), WithModelFlag("summarizer-model"), WithTemperatureFlag("summarizer-temperature"))
+	params := openai.ChatCompletionNewParams{Model: "summarizer/gpt-4o"}
+
+	config, err := resolver.ResolveConfig(workflowai.ContextWithRoutingKey(context.Background(), "beta-user"), params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Model != "summarizer/#2/production" {
+		t.Errorf("expected the beta deployment, got %q", config.Model)
+	}
+	if config.Temperature == nil || *config.Temperature != 0.2 {
+		t.Errorf("unexpected temperature %v", config.Temperature)
+	}
+
+	config, err = resolver.ResolveConfig(workflowai.ContextWithRoutingKey(context.Background(), "other-user"), params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Model != "summarizer/#1/production" {
+		t.Errorf("expected the stable deployment, got %q", config.Model)
+	}
+
+	_, err = New(openfeature.NewClient("test"), WithModelFlag("missing")).ResolveConfig(context.Background(), params)
+	if err == nil {
+		t.Error("expected an error for a missing flag")
+	}
+}
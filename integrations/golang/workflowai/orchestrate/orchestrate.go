@@ -0,0 +1,377 @@
+// Package orchestrate composes agents into graphs of steps sharing a typed
+// state: sequences, parallel fan-outs and conditional branches.
+//
+//	type State struct {
+//		Ticket  string
+//		Triage  Triage
+//		Answer  string
+//	}
+//
+//	graph := orchestrate.New("support",
+//		orchestrate.AgentStep("triage", triageAgent,
+//			func(s *State) TriageInput { return TriageInput{Ticket: s.Ticket} },
+//			func(s *State, out Triage) { s.Triage = out },
+//			orchestrate.WithRetries(2, time.Second)),
+//		orchestrate.Branch(func(s *State) bool { return s.Triage.Urgent },
+//			orchestrate.Parallel(escalate, draftAnswer),
+//			draftAnswer),
+//	)
+//	result, err := graph.Run(ctx, &State{Ticket: ticket})
+//
+// The agent runs of a graph run are tagged with metadata linking them: the
+// name of the graph ("workflow"), the ID of the graph run
+// ("workflow_run_id"), the step ("workflow_node") and the runs of the
+// preceding steps ("workflow_parent_runs"), so that the whole pipeline can be
+// searched and followed in WorkflowAI.
+package orchestrate
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/workflowai/workflowai/go/examples/workflowai"
+)
+
+// Metadata keys of the agent runs of a graph.
+const (
+	MetadataWorkflow   = "workflow"
+	MetadataRunID      = "workflow_run_id"
+	MetadataNode       = "workflow_node"
+	MetadataParentRuns = "workflow_parent_runs"
+	MetadataAttempt    = "workflow_attempt"
+)
+
+// Step is a step of a graph over a state S.
+type Step[S any] interface {
+	// run runs the step after the runs parents and returns the runs the next
+	// steps follow.
+	run(ctx context.Context, x *execution[S], parents []string) ([]string, error)
+}
+
+// Graph is a sequence of steps. A graph is also a step, to be nested in
+// other graphs.
+type Graph[S any] struct {
+	Name string
+	root Step[S]
+}
+
+// New returns a graph running steps in sequence.
+func New[S any](name string, steps ...Step[S]) *Graph[S] {
+	return &Graph[S]{Name: name, root: Sequence(steps...)}
+}
+
+// NodeResult is the result of a node of a graph run.
+type NodeResult struct {
+	Name     string
+	Attempts int
+	Duration time.Duration
+	// RunIDs are the agent runs of the node, one per attempt
+	RunIDs  []string
+	CostUSD float64
+	// Err is the error of the last attempt, nil when the node succeeded
+	Err error
+}
+
+// Result is the result of a graph run.
+type Result struct {
+	// RunID identifies the graph run in the metadata of its agent runs
+	RunID string
+	// Nodes are the nodes that ran, in the order they ended
+	Nodes   []NodeResult
+	CostUSD float64
+}
+
+// NodeError is returned when a node fails after its retries.
+type NodeError struct {
+	Node string
+	Err  error
+}
+
+func (e *NodeError) Error() string {
+	return fmt.Sprintf("orchestrate: node %s: %v", e.Node, e.Err)
+}
+
+func (e *NodeError) Unwrap() error {
+	return e.Err
+}
+
+type execution[S any] struct {
+	graph string
+	runID string
+
+	// stateMu serializes the accesses to the state of parallel steps
+	stateMu sync.Mutex
+	state   *S
+
+	mu     sync.Mutex
+	result Result
+}
+
+func (x *execution[S]) record(node NodeResult) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.result.Nodes = append(x.result.Nodes, node)
+	x.result.CostUSD += node.CostUSD
+}
+
+// Run runs the graph on state. The result lists the nodes that ran, also when
+// a node failed.
+func (g *Graph[S]) Run(ctx context.Context, state *S) (*Result, error) {
+	id := make([]byte, 8)
+	rand.Read(id)
+	x := &execution[S]{graph: g.Name, runID: hex.EncodeToString(id), state: state}
+	x.result.RunID = x.runID
+	_, err := g.root.run(ctx, x, nil)
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	result := x.result
+	return &result, err
+}
+
+func (g *Graph[S]) run(ctx context.Context, x *execution[S], parents []string) ([]string, error) {
+	return g.root.run(ctx, x, parents)
+}
+
+type sequence[S any] []Step[S]
+
+// Sequence returns a step running steps one after the other, stopping at the
+// first error.
+func Sequence[S any](steps ...Step[S]) Step[S] {
+	return sequence[S](steps)
+}
+
+func (s sequence[S]) run(ctx context.Context, x *execution[S], parents []string) ([]string, error) {
+	for _, step := range s {
+		var err error
+		if parents, err = step.run(ctx, x, parents); err != nil {
+			return parents, err
+		}
+	}
+	return parents, nil
+}
+
+type parallel[S any] []Step[S]
+
+// Parallel returns a step running steps concurrently. The first error cancels
+// the other steps.
+func Parallel[S any](steps ...Step[S]) Step[S] {
+	return parallel[S](steps)
+}
+
+func (p parallel[S]) run(ctx context.Context, x *execution[S], parents []string) ([]string, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var runs []string
+	errs := make([]error, len(p))
+	for i, step := range p {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			stepRuns, err := step.run(ctx, x, parents)
+			if err != nil {
+				errs[i] = err
+				cancel()
+			}
+			mu.Lock()
+			runs = append(runs, stepRuns...)
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	// The steps cancelled by the failure of another one are not reported
+	var failed []error
+	for _, err := range errs {
+		if err != nil && !errors.Is(err, context.Canceled) {
+			failed = append(failed, err)
+		}
+	}
+	if len(failed) == 0 {
+		failed = errs
+	}
+	return dedupe(runs), errors.Join(failed...)
+}
+
+type branch[S any] struct {
+	cond      func(*S) bool
+	then      Step[S]
+	otherwise Step[S]
+}
+
+// Branch returns a step running then when cond is true, otherwise
+// otherwise, which can be nil.
+func Branch[S any](cond func(*S) bool, then Step[S], otherwise Step[S]) Step[S] {
+	return branch[S]{cond: cond, then: then, otherwise: otherwise}
+}
+
+func (b branch[S]) run(ctx context.Context, x *execution[S], parents []string) ([]string, error) {
+	x.stateMu.Lock()
+	ok := b.cond(x.state)
+	x.stateMu.Unlock()
+	switch {
+	case ok:
+		return b.then.run(ctx, x, parents)
+	case b.otherwise != nil:
+		return b.otherwise.run(ctx, x, parents)
+	}
+	return parents, nil
+}
+
+// NodeOption configures a node.
+type NodeOption func(*node)
+
+// WithRetries retries a failed node up to retries times, waiting backoff
+// before the first retry and doubling it after each one.
+func WithRetries(retries int, backoff time.Duration) NodeOption {
+	return func(n *node) {
+		n.retries = retries
+		n.backoff = backoff
+	}
+}
+
+// WithTimeout limits the duration of each attempt of a node.
+func WithTimeout(timeout time.Duration) NodeOption {
+	return func(n *node) {
+		n.timeout = timeout
+	}
+}
+
+type node struct {
+	name    string
+	retries int
+	backoff time.Duration
+	timeout time.Duration
+}
+
+// attempt is an attempt of a node, returning the agent run it created, if any.
+type attempt func(ctx context.Context) (runID string, costUSD float64, err error)
+
+// runNode runs the attempts of a node with its retries, and records its
+// result.
+func runNode[S any](ctx context.Context, n *node, x *execution[S], parents []string, try attempt) ([]string, error) {
+	metadata := map[string]string{MetadataWorkflow: x.graph, MetadataRunID: x.runID, MetadataNode: n.name}
+	if len(parents) > 0 {
+		metadata[MetadataParentRuns] = strings.Join(parents, ",")
+	}
+	result := NodeResult{Name: n.name}
+	start := time.Now()
+	backoff := n.backoff
+	for {
+		result.Attempts++
+		metadata[MetadataAttempt] = strconv.Itoa(result.Attempts)
+		attemptCtx := workflowai.ContextWithRunMetadata(ctx, metadata)
+		cancel := func() {}
+		if n.timeout > 0 {
+			attemptCtx, cancel = context.WithTimeout(attemptCtx, n.timeout)
+		}
+		runID, cost, err := try(attemptCtx)
+		cancel()
+		if runID != "" {
+			result.RunIDs = append(result.RunIDs, runID)
+		}
+		result.CostUSD += cost
+		result.Err = err
+		if err == nil || result.Attempts > n.retries || ctx.Err() != nil {
+			break
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+	result.Duration = time.Since(start)
+	x.record(result)
+	if result.Err != nil {
+		return parents, &NodeError{Node: n.name, Err: result.Err}
+	}
+	if len(result.RunIDs) == 0 {
+		// Steps without agent runs do not break the links between runs
+		return parents, nil
+	}
+	return result.RunIDs[len(result.RunIDs)-1:], nil
+}
+
+type funcNode[S any] struct {
+	node
+	fn func(context.Context, *S) error
+}
+
+// Func returns a node running fn on the state. fn holds the state lock, so
+// it must not block for long in parallel steps: agents should run in
+// [AgentStep] nodes, which only hold the lock to read their input and write
+// their output.
+func Func[S any](name string, fn func(ctx context.Context, state *S) error, opts ...NodeOption) Step[S] {
+	n := &funcNode[S]{node: node{name: name}, fn: fn}
+	for _, opt := range opts {
+		opt(&n.node)
+	}
+	return n
+}
+
+func (n *funcNode[S]) run(ctx context.Context, x *execution[S], parents []string) ([]string, error) {
+	return runNode(ctx, &n.node, x, parents, func(ctx context.Context) (string, float64, error) {
+		x.stateMu.Lock()
+		defer x.stateMu.Unlock()
+		return "", 0, n.fn(ctx, x.state)
+	})
+}
+
+type agentNode[S, I, O any] struct {
+	node
+	agent  *workflowai.Agent[I, O]
+	input  func(*S) I
+	output func(*S, O)
+}
+
+// AgentStep returns a node running an agent with the input built from the
+// state, then storing its output in the state. Runs whose output does not
+// match the schema of the agent are failed attempts, retried by
+// [WithRetries].
+func AgentStep[S, I, O any](name string, agent *workflowai.Agent[I, O], input func(*S) I, output func(*S, O), opts ...NodeOption) Step[S] {
+	n := &agentNode[S, I, O]{node: node{name: name}, agent: agent, input: input, output: output}
+	for _, opt := range opts {
+		opt(&n.node)
+	}
+	return n
+}
+
+func (n *agentNode[S, I, O]) run(ctx context.Context, x *execution[S], parents []string) ([]string, error) {
+	return runNode(ctx, &n.node, x, parents, func(ctx context.Context) (string, float64, error) {
+		x.stateMu.Lock()
+		input := n.input(x.state)
+		x.stateMu.Unlock()
+		run, err := n.agent.Run(ctx, input)
+		if err != nil {
+			var outputErr *workflowai.OutputError
+			if errors.As(err, &outputErr) {
+				return outputErr.RunID, outputErr.CostUSD, err
+			}
+			return "", 0, err
+		}
+		x.stateMu.Lock()
+		n.output(x.state, run.Output)
+		x.stateMu.Unlock()
+		return run.RunID(), run.CostUSD(), nil
+	})
+}
+
+func dedupe(ids []string) []string {
+	seen := map[string]bool{}
+	out := ids[:0]
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			out = append(out, id)
+		}
+	}
+	return out
+}
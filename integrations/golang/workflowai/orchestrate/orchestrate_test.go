@@ -0,0 +1,127 @@
+package orchestrate
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/workflowai/workflowai/go/examples/workflowai"
+	"github.com/workflowai/workflowai/go/examples/workflowai/workflowaitest"
+)
+
+type ticket struct {
+	Text string `json:"text"`
+}
+
+type triage struct {
+	Urgent bool `json:"urgent"`
+}
+
+type state struct {
+	Ticket    string
+	Triage    triage
+	Escalated string
+	Answer    string
+	Attempts  int
+}
+
+func newAgent[I, O any](t *testing.T, model *workflowaitest.FakeModel, id string) *workflowai.Agent[I, O] {
+	t.Helper()
+	agent, err := workflowai.NewAgent[I, O](model, id, "gpt-4o-mini-latest", "{{text}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return agent
+}
+
+func TestGraph(t *testing.T) {
+	model := workflowaitest.NewFakeModel(
+		workflowaitest.Rule{Match: workflowaitest.ModelIs("triage/gpt-4o-mini-latest"), Reply: `{"urgent": {{if eq (index .Input "text") "down"}}true{{else}}false{{end}}}`, CostUSD: 0.01},
+		workflowaitest.Rule{Match: workflowaitest.ModelIs("escalate/gpt-4o-mini-latest"), Reply: "paged", CostUSD: 0.01},
+		workflowaitest.Rule{Match: workflowaitest.ModelIs("answer/gpt-4o-mini-latest"), Reply: "answered", CostUSD: 0.01},
+	)
+	triageAgent := newAgent[ticket, triage](t, model, "triage")
+	escalateAgent := newAgent[ticket, string](t, model, "escalate")
+	answerAgent := newAgent[ticket, string](t, model, "answer")
+
+	input := func(s *state) ticket { return ticket{Text: s.Ticket} }
+	answer := AgentStep("answer", answerAgent, input, func(s *state, out string) { s.Answer = out })
+	graph := New("support",
+		AgentStep("triage", triageAgent, input, func(s *state, out triage) { s.Triage = out }),
+		Branch(func(s *state) bool { return s.Triage.Urgent },
+			Parallel(AgentStep("escalate", escalateAgent, input, func(s *state, out string) { s.Escalated = out }), answer),
+			answer),
+		Func("flaky", func(ctx context.Context, s *state) error {
+			if s.Attempts++; s.Attempts < 2 {
+				return errors.New("temporary")
+			}
+			return nil
+		}, WithRetries(1, time.Millisecond)),
+	)
+
+	s := &state{Ticket: "down"}
+	result, err := graph.Run(context.Background(), s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Escalated != "paged" || s.Answer != "answered" {
+		t.Errorf("state = %+v", s)
+	}
+	if len(result.Nodes) != 4 || result.CostUSD < 0.029 || result.Nodes[3].Attempts != 2 {
+		t.Errorf("result = %+v", result)
+	}
+
+	requests := model.Requests()
+	triageRun := result.Nodes[0].RunIDs[0]
+	for _, r := range requests[1:] {
+		metadata := r.Metadata()
+		if metadata[MetadataWorkflow] != "support" || metadata[MetadataRunID] != result.RunID || metadata[MetadataParentRuns] != triageRun {
+			t.Errorf("metadata of %s = %v", r.Model(), metadata)
+		}
+	}
+	if requests[0].Metadata()[MetadataParentRuns] != nil {
+		t.Errorf("the first run has parents: %v", requests[0].Metadata())
+	}
+
+	s = &state{Ticket: "question", Attempts: 1}
+	if _, err := graph.Run(context.Background(), s); err != nil {
+		t.Fatal(err)
+	}
+	if s.Escalated != "" || s.Answer != "answered" {
+		t.Errorf("not urgent: state = %+v", s)
+	}
+}
+
+func TestGraphNodeError(t *testing.T) {
+	model := workflowaitest.NewFakeModel(
+		workflowaitest.Rule{Match: workflowaitest.ModelIs("triage/gpt-4o-mini-latest"), Reply: `{"urgent": "yes"}`},
+	)
+	graph := New("support",
+		AgentStep("triage", newAgent[ticket, triage](t, model, "triage"),
+			func(s *state) ticket { return ticket{Text: s.Ticket} },
+			func(s *state, out triage) { s.Triage = out },
+			WithRetries(2, 0)),
+		Func("never", func(ctx context.Context, s *state) error {
+			t.Error("the graph should stop at the failed node")
+			return nil
+		}),
+	)
+	result, err := graph.Run(context.Background(), &state{Ticket: "down"})
+	var nodeErr *NodeError
+	var outputErr *workflowai.OutputError
+	if !errors.As(err, &nodeErr) || nodeErr.Node != "triage" || !errors.As(err, &outputErr) {
+		t.Fatalf("expected a node error, got %v", err)
+	}
+	if len(result.Nodes) != 1 || result.Nodes[0].Attempts != 3 || len(result.Nodes[0].RunIDs) != 3 {
+		t.Errorf("result = %+v", result.Nodes)
+	}
+	// Each attempt sends the output back once to be repaired
+	if got := model.Requests()[4].Metadata()[MetadataAttempt]; got != "3" || len(model.Requests()) != 6 {
+		t.Errorf("attempt = %v", got)
+	}
+	if !strings.Contains(err.Error(), "node triage") {
+		t.Errorf("error = %v", err)
+	}
+}
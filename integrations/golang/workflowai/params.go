@@ -0,0 +1,155 @@
+package workflowai
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+// unsupportedParams are the fields of the chat completions rejected by
+// WorkflowAI, sorted.
+var unsupportedParams = []string{"logit_bias", "logprobs", "modalities", "prediction", "seed", "stop", "top_logprobs", "web_search_options"}
+
+// ParamsError is returned when the sampling parameters of a request are
+// invalid, or not supported by its model. The request is not sent.
+type ParamsError struct {
+	Model      string
+	Violations []PolicyViolation
+}
+
+func (e *ParamsError) Error() string {
+	msgs := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		msgs[i] = v.Field + ": " + v.Message
+	}
+	return fmt.Sprintf("workflowai: invalid parameters for %s: %s", e.Model, strings.Join(msgs, "; "))
+}
+
+type paramsRequest struct {
+	Model               string   `json:"model"`
+	Temperature         *float64 `json:"temperature"`
+	TopP                *float64 `json:"top_p"`
+	PresencePenalty     *float64 `json:"presence_penalty"`
+	FrequencyPenalty    *float64 `json:"frequency_penalty"`
+	MaxTokens           *int64   `json:"max_tokens"`
+	MaxCompletionTokens *int64   `json:"max_completion_tokens"`
+}
+
+// ValidateParams returns a [*ParamsError] if the sampling parameters of params
+// are out of range: temperature, top_p, presence and frequency penalties and
+// output token limits, or not supported by WorkflowAI, e.g. stop sequences. When model is not nil, the
+// parameters are also checked against its capabilities and context window,
+// e.g. models that reject temperature.
+func ValidateParams(params openai.ChatCompletionNewParams, model *ModelInfo) error {
+	body, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	return validateParamsBody(body, func(string) *ModelInfo { return model })
+}
+
+func validateParamsBody(body []byte, find func(model string) *ModelInfo) error {
+	var req paramsRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return fmt.Errorf("workflowai: decoding request for the parameters check: %w", err)
+	}
+	var violations []PolicyViolation
+	violate := func(field string, format string, args ...any) {
+		violations = append(violations, PolicyViolation{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+	ranges := []struct {
+		field    string
+		value    *float64
+		min, max float64
+	}{
+		{"temperature", req.Temperature, 0, 2},
+		{"top_p", req.TopP, 0, 1},
+		{"presence_penalty", req.PresencePenalty, -2, 2},
+		{"frequency_penalty", req.FrequencyPenalty, -2, 2},
+	}
+	for _, r := range ranges {
+		if r.value != nil && (*r.value < r.min || *r.value > r.max) {
+			violate(r.field, "%v is not between %v and %v", *r.value, r.min, r.max)
+		}
+	}
+	if req.TopP != nil && *req.TopP == 0 {
+		violate("top_p", "must be greater than 0")
+	}
+	if req.MaxTokens != nil && req.MaxCompletionTokens != nil {
+		violate("max_tokens", "cannot be set with max_completion_tokens")
+	}
+	limit, limitField := req.MaxCompletionTokens, "max_completion_tokens"
+	if limit == nil {
+		limit, limitField = req.MaxTokens, "max_tokens"
+	}
+	if limit != nil && *limit <= 0 {
+		violate(limitField, "must be positive")
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return fmt.Errorf("workflowai: decoding request for the parameters check: %w", err)
+	}
+	for _, field := range unsupportedParams {
+		if value, ok := fields[field]; ok && string(value) != "null" {
+			violate(field, "is not supported by WorkflowAI")
+		}
+	}
+
+	if model := find(req.Model); model != nil {
+		if !model.Supports.Temperature && req.Temperature != nil {
+			violate("temperature", "%s does not support temperature", model.ID)
+		}
+		if !model.Supports.TopP && req.TopP != nil {
+			violate("top_p", "%s does not support top_p", model.ID)
+		}
+		if maxOutput := model.ContextWindow.MaxOutputTokens; maxOutput > 0 && limit != nil && *limit > maxOutput {
+			violate(limitField, "%d exceeds the %d output tokens of %s", *limit, maxOutput, model.ID)
+		}
+	}
+	if len(violations) > 0 {
+		return &ParamsError{Model: req.Model, Violations: violations}
+	}
+	return nil
+}
+
+// catalogModel returns the model of the catalog used by a requested model,
+// e.g. "gpt-4o-mini-latest" for "my-agent/gpt-4o-mini-latest", or nil for
+// deployments, e.g. "my-agent/#1/production", whose model is only known by
+// the server.
+func catalogModel(models []ModelInfo, requested string) *ModelInfo {
+	if strings.Contains(requested, "#") {
+		return nil
+	}
+	id := requested[strings.LastIndex(requested, "/")+1:]
+	for i := range models {
+		if models[i].ID == id {
+			return &models[i]
+		}
+	}
+	return nil
+}
+
+// ParamsValidationMiddleware returns a client middleware that rejects the
+// chat completions with invalid sampling parameters with a [*ParamsError],
+// before they are sent. The parameters are checked against the capabilities
+// of the requested model in models, e.g. listed by [ListModels]; models that
+// are not in the list are only checked for ranges.
+func ParamsValidationMiddleware(models []ModelInfo) option.Middleware {
+	return func(req *http.Request, next option.MiddlewareNext) (*http.Response, error) {
+		if !isChatCompletionRequest(req) {
+			return next(req)
+		}
+		body, err := readRequestBody(req)
+		if err != nil {
+			return nil, err
+		}
+		if err := validateParamsBody(body, func(model string) *ModelInfo { return catalogModel(models, model) }); err != nil {
+			return abort(err)
+		}
+		return next(req)
+	}
+}
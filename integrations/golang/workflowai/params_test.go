@@ -0,0 +1,101 @@
+package workflowai
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+func TestValidateParams(t *testing.T) {
+	valid := openai.ChatCompletionNewParams{
+		Model:               "my-agent/gpt-4o-mini-latest",
+		Temperature:         openai.Float(0.7),
+		TopP:                openai.Float(0.9),
+		PresencePenalty:     openai.Float(-1),
+		MaxCompletionTokens: openai.Int(500),
+	}
+	if err := ValidateParams(valid, nil); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	invalid := openai.ChatCompletionNewParams{
+		Model:            "my-agent/gpt-4o-mini-latest",
+		Temperature:      openai.Float(2.5),
+		TopP:             openai.Float(0),
+		FrequencyPenalty: openai.Float(3),
+		MaxTokens:        openai.Int(0),
+		Stop:             openai.ChatCompletionNewParamsStopUnion{OfString: openai.String("END")},
+	}
+	var paramsErr *ParamsError
+	if !errors.As(ValidateParams(invalid, nil), &paramsErr) {
+		t.Fatal("expected a params error")
+	}
+	if got := violationFields(paramsErr.Violations); got != "temperature frequency_penalty top_p max_tokens stop" {
+		t.Errorf("unexpected violations %s", got)
+	}
+
+	reasoning := &ModelInfo{ID: "o3-mini", ContextWindow: ModelContextWindow{MaxOutputTokens: 1000}}
+	err := ValidateParams(openai.ChatCompletionNewParams{
+		Model:               "my-agent/o3-mini",
+		Temperature:         openai.Float(0),
+		MaxCompletionTokens: openai.Int(4000),
+	}, reasoning)
+	if !errors.As(err, &paramsErr) || violationFields(paramsErr.Violations) != "temperature max_completion_tokens" {
+		t.Errorf("unexpected error %v", err)
+	}
+	if !strings.Contains(err.Error(), "o3-mini does not support temperature") {
+		t.Errorf("unexpected message %v", err)
+	}
+}
+
+func violationFields(violations []PolicyViolation) string {
+	fields := []string{}
+	for _, v := range violations {
+		fields = append(fields, v.Field)
+	}
+	return strings.Join(fields, " ")
+}
+
+func TestParamsValidationMiddleware(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(testCompletion))
+	}))
+	defer server.Close()
+
+	models := []ModelInfo{{ID: "o3-mini", Supports: ModelSupports{TopP: true}}}
+	client := openai.NewClient(
+		option.WithBaseURL(server.URL+"/v1/"),
+		option.WithAPIKey("key"),
+		option.WithMiddleware(ParamsValidationMiddleware(models)),
+	)
+	params := openai.ChatCompletionNewParams{
+		Model:    "my-agent/o3-mini",
+		Messages: []openai.ChatCompletionMessageParamUnion{openai.UserMessage("Hello")},
+	}
+	if _, err := client.Chat.Completions.New(context.Background(), params); err != nil {
+		t.Fatal(err)
+	}
+	params.Temperature = openai.Float(0.5)
+	var paramsErr *ParamsError
+	if _, err := client.Chat.Completions.New(context.Background(), params); !errors.As(err, &paramsErr) {
+		t.Fatalf("expected a params error, got %v", err)
+	}
+	// Deployments are only checked for ranges
+	params.Model = "my-agent/#1/production"
+	if _, err := client.Chat.Completions.New(context.Background(), params); err != nil {
+		t.Fatal(err)
+	}
+	if calls.Load() != 2 {
+		t.Errorf("expected 2 requests, got %d", calls.Load())
+	}
+}
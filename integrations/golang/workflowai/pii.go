@@ -0,0 +1,406 @@
+package workflowai
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/openai/openai-go/option"
+)
+
+// PIIRule detects a kind of personal data. Matches are replaced by a token
+// made of the rule name, e.g. "<EMAIL_1>".
+type PIIRule struct {
+	// Name is upper case by convention, e.g. "EMAIL"
+	Name    string
+	Pattern *regexp.Regexp
+	// Validate filters out false positives of the pattern. Optional.
+	Validate func(match string) bool
+}
+
+// DefaultPIIRules detect credit card numbers, email addresses and phone
+// numbers. Rules are applied in order, so card numbers are not mistaken for
+// phone numbers.
+var DefaultPIIRules = []PIIRule{
+	{
+		Name:     "CREDIT_CARD",
+		Pattern:  regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`),
+		Validate: luhnValid,
+	},
+	{
+		Name:    "EMAIL",
+		Pattern: regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`),
+	},
+	{
+		Name:    "PHONE",
+		Pattern: regexp.MustCompile(`\+?\(?\d{1,4}\)?(?:[ .-]?\(?\d{2,4}\)?){2,5}`),
+		Validate: func(match string) bool {
+			n := countDigits(match)
+			return n >= 9 && n <= 15
+		},
+	},
+}
+
+func countDigits(s string) int {
+	n := 0
+	for _, c := range s {
+		if c >= '0' && c <= '9' {
+			n++
+		}
+	}
+	return n
+}
+
+// luhnValid returns true if the digits of s pass the Luhn checksum used by
+// card numbers.
+func luhnValid(s string) bool {
+	sum, double := 0, false
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+// PIIRedactor masks personal data in text.
+type PIIRedactor struct {
+	rules      []PIIRule
+	reversible bool
+}
+
+type PIIOption func(*PIIRedactor)
+
+// WithPIIRules replaces the default rules.
+func WithPIIRules(rules ...PIIRule) PIIOption {
+	return func(r *PIIRedactor) {
+		r.rules = rules
+	}
+}
+
+// WithPIIPattern adds a rule matching a custom regular expression, e.g. an
+// internal customer ID.
+func WithPIIPattern(name string, pattern *regexp.Regexp) PIIOption {
+	return func(r *PIIRedactor) {
+		r.rules = append(r.rules, PIIRule{Name: name, Pattern: pattern})
+	}
+}
+
+// WithReversibleTokens controls whether the tokens found in responses are
+// replaced by the original values. Defaults to true.
+func WithReversibleTokens(reversible bool) PIIOption {
+	return func(r *PIIRedactor) {
+		r.reversible = reversible
+	}
+}
+
+func NewPIIRedactor(opts ...PIIOption) *PIIRedactor {
+	r := &PIIRedactor{
+		rules:      append([]PIIRule{}, DefaultPIIRules...),
+		reversible: true,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// PIIVault maps the tokens of a request to the original values. Identical
+// values share a token so that the model can still relate them.
+type PIIVault struct {
+	tokens map[string]string
+	values map[string]string
+	counts map[string]int
+}
+
+// NewPIIVault returns an empty vault.
+func NewPIIVault() *PIIVault {
+	return &PIIVault{tokens: map[string]string{}, values: map[string]string{}, counts: map[string]int{}}
+}
+
+func (v *PIIVault) token(rule string, value string) string {
+	if token, ok := v.tokens[value]; ok {
+		return token
+	}
+	v.counts[rule]++
+	token := fmt.Sprintf("<%s_%d>", rule, v.counts[rule])
+	v.tokens[value] = token
+	v.values[token] = value
+	return token
+}
+
+var piiTokenPattern = regexp.MustCompile(`<[A-Z][A-Z0-9_]*_\d+>`)
+
+// Restore replaces the tokens of the vault in s by the original values.
+// Unknown tokens are left unchanged.
+func (v *PIIVault) Restore(s string) string {
+	return piiTokenPattern.ReplaceAllStringFunc(s, func(token string) string {
+		if value, ok := v.values[token]; ok {
+			return value
+		}
+		return token
+	})
+}
+
+// Redact masks the personal data in s. Tokens are recorded in vault when it
+// is not nil.
+func (r *PIIRedactor) Redact(s string, vault *PIIVault) string {
+	if vault == nil {
+		vault = NewPIIVault()
+	}
+	for _, rule := range r.rules {
+		s = rule.Pattern.ReplaceAllStringFunc(s, func(match string) string {
+			if rule.Validate != nil && !rule.Validate(match) {
+				return match
+			}
+			return vault.token(rule.Name, match)
+		})
+	}
+	return s
+}
+
+// PIIRedactionMiddleware returns a client middleware that masks personal data
+// in the messages and the input of chat completion requests before they are
+// sent. Unless reversible tokens are disabled, tokens found in the content and
+// tool calls of responses are replaced by the original values, so that
+// callers see the real data. In streamed responses only the content is
+// restored.
+func PIIRedactionMiddleware(opts ...PIIOption) option.Middleware {
+	r := NewPIIRedactor(opts...)
+	return r.middleware
+}
+
+func (r *PIIRedactor) middleware(req *http.Request, next option.MiddlewareNext) (*http.Response, error) {
+	if !isChatCompletionRequest(req) {
+		return next(req)
+	}
+	body, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	payload, err := decodeJSONObject(body)
+	if err != nil {
+		// Let the server report invalid requests
+		return next(req)
+	}
+
+	vault := NewPIIVault()
+	if messages, ok := payload["messages"].([]any); ok {
+		for _, m := range messages {
+			if message, ok := m.(map[string]any); ok {
+				r.redactMessage(message, vault)
+			}
+		}
+	}
+	if input, ok := payload["input"]; ok {
+		payload["input"] = r.redactAll(input, vault)
+	}
+
+	redacted, err := encodeJSON(payload)
+	if err != nil {
+		return nil, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(redacted))
+	req.ContentLength = int64(len(redacted))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(redacted)), nil
+	}
+
+	res, err := next(req)
+	if err != nil || !r.reversible || len(vault.values) == 0 {
+		return res, err
+	}
+	if strings.HasPrefix(res.Header.Get("Content-Type"), "text/event-stream") {
+		res.Body = &piiStreamRestorer{body: res.Body, reader: bufio.NewReader(res.Body), vault: vault, pending: map[string]string{}}
+		return res, nil
+	}
+
+	resBody, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	if completion, err := decodeJSONObject(resBody); err == nil {
+		restoreChoices(completion, vault, "message")
+		if restored, err := encodeJSON(completion); err == nil {
+			resBody = restored
+		}
+	}
+	res.Body = io.NopCloser(bytes.NewReader(resBody))
+	res.ContentLength = int64(len(resBody))
+	return res, nil
+}
+
+func (r *PIIRedactor) redactMessage(message map[string]any, vault *PIIVault) {
+	switch content := message["content"].(type) {
+	case string:
+		message["content"] = r.Redact(content, vault)
+	case []any:
+		for _, p := range content {
+			if part, ok := p.(map[string]any); ok {
+				if text, ok := part["text"].(string); ok {
+					part["text"] = r.Redact(text, vault)
+				}
+			}
+		}
+	}
+	if toolCalls, ok := message["tool_calls"].([]any); ok {
+		for _, tc := range toolCalls {
+			toolCall, _ := tc.(map[string]any)
+			if fn, ok := toolCall["function"].(map[string]any); ok {
+				if args, ok := fn["arguments"].(string); ok {
+					fn["arguments"] = r.Redact(args, vault)
+				}
+			}
+		}
+	}
+}
+
+// redactAll masks every string of a JSON value.
+func (r *PIIRedactor) redactAll(v any, vault *PIIVault) any {
+	switch v := v.(type) {
+	case string:
+		return r.Redact(v, vault)
+	case []any:
+		for i := range v {
+			v[i] = r.redactAll(v[i], vault)
+		}
+	case map[string]any:
+		for k := range v {
+			v[k] = r.redactAll(v[k], vault)
+		}
+	}
+	return v
+}
+
+// restoreChoices restores the content and tool call arguments of the
+// "message" or "delta" of each choice of a completion.
+func restoreChoices(completion map[string]any, vault *PIIVault, field string) {
+	choices, _ := completion["choices"].([]any)
+	for _, c := range choices {
+		choice, _ := c.(map[string]any)
+		message, ok := choice[field].(map[string]any)
+		if !ok {
+			continue
+		}
+		if content, ok := message["content"].(string); ok {
+			message["content"] = vault.Restore(content)
+		}
+		toolCalls, _ := message["tool_calls"].([]any)
+		for _, tc := range toolCalls {
+			toolCall, _ := tc.(map[string]any)
+			if fn, ok := toolCall["function"].(map[string]any); ok {
+				if args, ok := fn["arguments"].(string); ok {
+					fn["arguments"] = vault.Restore(args)
+				}
+			}
+		}
+	}
+}
+
+func decodeJSONObject(data []byte) (map[string]any, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var v map[string]any
+	if err := decoder.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func encodeJSON(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(buf.Bytes(), "\n"), nil
+}
+
+// maxPIITokenLength bounds the text held back when a chunk ends with what
+// could be the beginning of a token.
+const maxPIITokenLength = 48
+
+// piiStreamRestorer restores the tokens in the content of streamed chunks. A
+// token can be split across chunks, so a trailing partial token is held back
+// and prepended to the next chunk of the same choice.
+type piiStreamRestorer struct {
+	body   io.ReadCloser
+	reader *bufio.Reader
+	vault  *PIIVault
+	out    bytes.Buffer
+	err    error
+	// pending is the held back content, by choice index
+	pending map[string]string
+}
+
+func (r *piiStreamRestorer) Read(p []byte) (int, error) {
+	for r.out.Len() == 0 && r.err == nil {
+		line, err := r.reader.ReadBytes('\n')
+		r.out.Write(r.restoreLine(line))
+		r.err = err
+	}
+	if r.out.Len() > 0 {
+		return r.out.Read(p)
+	}
+	return 0, r.err
+}
+
+func (r *piiStreamRestorer) Close() error {
+	return r.body.Close()
+}
+
+func (r *piiStreamRestorer) restoreLine(line []byte) []byte {
+	data, ok := bytes.CutPrefix(bytes.TrimRight(line, "\r\n"), []byte("data:"))
+	if !ok {
+		return line
+	}
+	chunk, err := decodeJSONObject(bytes.TrimSpace(data))
+	if err != nil {
+		// e.g. [DONE]
+		return line
+	}
+	choices, _ := chunk["choices"].([]any)
+	for _, c := range choices {
+		choice, _ := c.(map[string]any)
+		delta, ok := choice["delta"].(map[string]any)
+		if !ok {
+			continue
+		}
+		n, _ := choice["index"].(json.Number)
+		index := n.String()
+		content, _ := delta["content"].(string)
+		text := r.pending[index] + content
+		r.pending[index] = ""
+		if choice["finish_reason"] == nil {
+			if i := strings.LastIndexByte(text, '<'); i >= 0 && !strings.Contains(text[i:], ">") && len(text)-i < maxPIITokenLength {
+				r.pending[index] = text[i:]
+				text = text[:i]
+			}
+		}
+		if _, ok := delta["content"]; ok || text != "" {
+			delta["content"] = r.vault.Restore(text)
+		}
+	}
+	restored, err := encodeJSON(chunk)
+	if err != nil {
+		return line
+	}
+	return append(append([]byte("data: "), restored...), '\n')
+}
@@ -0,0 +1,95 @@
+package workflowai
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+func TestPIIRedactor_Redact(t *testing.T) {
+	r := NewPIIRedactor(WithPIIPattern("CUSTOMER_ID", regexp.MustCompile(`cus_[a-z0-9]+`)))
+	vault := NewPIIVault()
+	got := r.Redact("Mail jane.doe@example.com or call +1 (415) 555-0100 about card 4242 4242 4242 4242, "+
+		"customer cus_abc123, order 2024-01-15. Again: jane.doe@example.com", vault)
+	want := "Mail <EMAIL_1> or call <PHONE_1> about card <CREDIT_CARD_1>, customer <CUSTOMER_ID_1>, order 2024-01-15. Again: <EMAIL_1>"
+	if got != want {
+		t.Fatalf("unexpected redaction\n got: %s\nwant: %s", got, want)
+	}
+	if restored := vault.Restore("Sent to <EMAIL_1>, <UNKNOWN_1>"); restored != "Sent to jane.doe@example.com, <UNKNOWN_1>" {
+		t.Errorf("unexpected restore %q", restored)
+	}
+
+	// Not a valid card number
+	if got := r.Redact("ref 4242 4242 4242 4241", nil); strings.Contains(got, "CREDIT_CARD") {
+		t.Errorf("unexpected card match: %s", got)
+	}
+}
+
+func TestPIIRedactionMiddleware(t *testing.T) {
+	var received string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := readRequestBody(r)
+		received = string(body)
+		if strings.Contains(received, `"stream":true`) {
+			w.Header().Set("Content-Type", "text/event-stream")
+			for _, part := range []string{"Writing to <EMA", "IL_1", "> now", ""} {
+				finish := "null"
+				if part == "" {
+					finish = `"stop"`
+				}
+				fmt.Fprintf(w, "data: {\"id\":\"a/1\",\"object\":\"chat.completion.chunk\",\"model\":\"m\",\"choices\":[{\"index\":0,\"delta\":{\"content\":%q},\"finish_reason\":%s}]}\n\n", part, finish)
+			}
+			fmt.Fprint(w, "data: [DONE]\n\n")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, strings.Replace(testCompletion, "Hello world", "Writing to <EMAIL_1>", 1))
+	}))
+	defer server.Close()
+
+	client := openai.NewClient(
+		option.WithBaseURL(server.URL+"/v1/"),
+		option.WithAPIKey("key"),
+		option.WithMiddleware(PIIRedactionMiddleware()),
+	)
+	params := openai.ChatCompletionNewParams{
+		Model:    "my-agent/gpt-4o",
+		Messages: []openai.ChatCompletionMessageParamUnion{openai.UserMessage("Email jane@example.com")},
+	}
+
+	completion, err := client.Chat.Completions.New(context.Background(), params,
+		option.WithJSONSet("input", map[string]any{"contact": "bob@example.com"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(received, "@example.com") {
+		t.Fatalf("personal data was sent: %s", received)
+	}
+	if !strings.Contains(received, `"content":"Email <EMAIL_1>"`) || !strings.Contains(received, `"contact":"<EMAIL_2>"`) {
+		t.Errorf("unexpected request %s", received)
+	}
+	if completion.Choices[0].Message.Content != "Writing to jane@example.com" {
+		t.Errorf("unexpected content %q", completion.Choices[0].Message.Content)
+	}
+
+	stream := client.Chat.Completions.NewStreaming(context.Background(), params)
+	var content strings.Builder
+	for stream.Next() {
+		if chunk := stream.Current(); len(chunk.Choices) > 0 {
+			content.WriteString(chunk.Choices[0].Delta.Content)
+		}
+	}
+	if err := stream.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if content.String() != "Writing to jane@example.com now" {
+		t.Errorf("unexpected streamed content %q", content.String())
+	}
+}
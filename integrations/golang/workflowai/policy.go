@@ -0,0 +1,175 @@
+package workflowai
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+// Policy restricts the chat completions that can be sent by a client, e.g.
+// when the client is shared by several teams. Zero values do not restrict.
+type Policy struct {
+	// AllowedModels are patterns matched against the requested model, where
+	// "*" matches any sequence of characters, e.g. "my-agent/gpt-4o*" or
+	// "*/#*/production". Deployments are matched by their name, since the
+	// model they use is only known by the server.
+	AllowedModels []string
+	// MaxTemperature applies when set
+	MaxTemperature *float64
+	// MaxTokens caps max_tokens and max_completion_tokens. Requests without a
+	// limit are allowed.
+	MaxTokens int64
+	// BannedTools are patterns matched against the names of the tools of the
+	// request.
+	BannedTools []string
+}
+
+// PolicyViolation is a single violation of a [Policy].
+type PolicyViolation struct {
+	// Field is the request field, e.g. "temperature" or "tools[1]"
+	Field   string
+	Message string
+}
+
+// PolicyError is returned when a request violates the policy of the client.
+// The request is not sent.
+type PolicyError struct {
+	Violations []PolicyViolation
+}
+
+func (e *PolicyError) Error() string {
+	msgs := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		msgs[i] = v.Field + ": " + v.Message
+	}
+	return "workflowai: request violates the client policy: " + strings.Join(msgs, "; ")
+}
+
+type policyRequest struct {
+	Model               string   `json:"model"`
+	Temperature         *float64 `json:"temperature"`
+	MaxTokens           *int64   `json:"max_tokens"`
+	MaxCompletionTokens *int64   `json:"max_completion_tokens"`
+	Tools               []struct {
+		Function struct {
+			Name string `json:"name"`
+		} `json:"function"`
+	} `json:"tools"`
+}
+
+// Check returns a [*PolicyError] if params violate the policy.
+func (p Policy) Check(params openai.ChatCompletionNewParams) error {
+	body, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	return p.checkBody(body)
+}
+
+func (p Policy) checkBody(body []byte) error {
+	var req policyRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return fmt.Errorf("workflowai: decoding request for the policy check: %w", err)
+	}
+
+	var violations []PolicyViolation
+	if len(p.AllowedModels) > 0 && !matchesAny(p.AllowedModels, req.Model) {
+		violations = append(violations, PolicyViolation{Field: "model", Message: fmt.Sprintf("model %q is not allowed", req.Model)})
+	}
+	if p.MaxTemperature != nil && req.Temperature != nil && *req.Temperature > *p.MaxTemperature {
+		violations = append(violations, PolicyViolation{
+			Field:   "temperature",
+			Message: fmt.Sprintf("temperature %v exceeds %v", *req.Temperature, *p.MaxTemperature),
+		})
+	}
+	if p.MaxTokens > 0 {
+		limits := []struct {
+			field string
+			value *int64
+		}{{"max_tokens", req.MaxTokens}, {"max_completion_tokens", req.MaxCompletionTokens}}
+		for _, l := range limits {
+			if l.value != nil && *l.value > p.MaxTokens {
+				violations = append(violations, PolicyViolation{Field: l.field, Message: fmt.Sprintf("%d exceeds %d", *l.value, p.MaxTokens)})
+			}
+		}
+	}
+	for i, tool := range req.Tools {
+		if matchesAny(p.BannedTools, tool.Function.Name) {
+			violations = append(violations, PolicyViolation{
+				Field:   fmt.Sprintf("tools[%d]", i),
+				Message: fmt.Sprintf("tool %q is banned", tool.Function.Name),
+			})
+		}
+	}
+	if len(violations) > 0 {
+		return &PolicyError{Violations: violations}
+	}
+	return nil
+}
+
+// matchesAny returns true if s matches one of the glob patterns, where "*"
+// matches any sequence of characters.
+func matchesAny(patterns []string, s string) bool {
+	for _, pattern := range patterns {
+		parts := strings.Split(pattern, "*")
+		for i, part := range parts {
+			parts[i] = regexp.QuoteMeta(part)
+		}
+		re, err := compilePattern("^" + strings.Join(parts, ".*") + "$")
+		if err == nil && re.MatchString(s) {
+			return true
+		}
+	}
+	return false
+}
+
+// compiledPatterns caches the regular expressions built at run time, e.g.
+// from the patterns of a policy or of a JSON schema, which are checked for
+// every request. They come from the configuration of the program, so the
+// cache stays small.
+var compiledPatterns sync.Map
+
+// compilePattern returns the compiled pattern, compiling it on first use.
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	if re, ok := compiledPatterns.Load(pattern); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	compiledPatterns.Store(pattern, re)
+	return re, nil
+}
+
+// PolicyMiddleware returns a client middleware that rejects the chat
+// completions violating policy with a [*PolicyError], before they are sent.
+// Since it checks the final request body, fields set with option.WithJSONSet
+// are checked as well.
+func PolicyMiddleware(policy Policy) option.Middleware {
+	return func(req *http.Request, next option.MiddlewareNext) (*http.Response, error) {
+		if !isChatCompletionRequest(req) {
+			return next(req)
+		}
+		body, err := readRequestBody(req)
+		if err != nil {
+			return nil, err
+		}
+		if err := policy.checkBody(body); err != nil {
+			return abort(err)
+		}
+		return next(req)
+	}
+}
+
+// abort fails a request from a middleware without the client retrying it,
+// which it does for errors without a response.
+func abort(err error) (*http.Response, error) {
+	return &http.Response{Header: http.Header{"X-Should-Retry": []string{"false"}}, Body: http.NoBody}, err
+}
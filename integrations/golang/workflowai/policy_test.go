@@ -0,0 +1,99 @@
+package workflowai
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+func TestPolicy_Check(t *testing.T) {
+	policy := Policy{
+		AllowedModels:  []string{"my-agent/gpt-4o*", "*/#*/production"},
+		MaxTemperature: openai.Ptr(1.0),
+		MaxTokens:      1000,
+		BannedTools:    []string{"shell_*"},
+	}
+
+	valid := openai.ChatCompletionNewParams{
+		Model:       "other-agent/#3/production",
+		Temperature: openai.Float(0.5),
+		MaxTokens:   openai.Int(500),
+	}
+	if err := policy.Check(valid); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	invalid := openai.ChatCompletionNewParams{
+		Model:               "my-agent/o1",
+		Temperature:         openai.Float(1.5),
+		MaxCompletionTokens: openai.Int(4000),
+		Tools: []openai.ChatCompletionToolParam{
+			{Function: openai.FunctionDefinitionParam{Name: "search"}},
+			{Function: openai.FunctionDefinitionParam{Name: "shell_exec"}},
+		},
+	}
+	var policyErr *PolicyError
+	if !errors.As(policy.Check(invalid), &policyErr) {
+		t.Fatal("expected a policy error")
+	}
+	fields := []string{}
+	for _, v := range policyErr.Violations {
+		fields = append(fields, v.Field)
+	}
+	if got := strings.Join(fields, " "); got != "model temperature max_completion_tokens tools[1]" {
+		t.Errorf("unexpected violations %s", got)
+	}
+}
+
+func TestPolicyMiddleware(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(testCompletion))
+	}))
+	defer server.Close()
+
+	client := openai.NewClient(
+		option.WithBaseURL(server.URL+"/v1/"),
+		option.WithAPIKey("key"),
+		option.WithMiddleware(PolicyMiddleware(Policy{AllowedModels: []string{"my-agent/*"}})),
+	)
+	params := openai.ChatCompletionNewParams{
+		Model:    "my-agent/gpt-4o",
+		Messages: []openai.ChatCompletionMessageParamUnion{openai.UserMessage("Hello")},
+	}
+	if _, err := client.Chat.Completions.New(context.Background(), params); err != nil {
+		t.Fatal(err)
+	}
+
+	// Overrides of the body are checked too
+	_, err := client.Chat.Completions.New(context.Background(), params, option.WithJSONSet("model", "other/gpt-4o"))
+	var policyErr *PolicyError
+	if !errors.As(err, &policyErr) {
+		t.Fatalf("expected a policy error, got %v", err)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("expected the rejected request not to be sent, got %d calls", calls.Load())
+	}
+}
+
+func TestCompilePattern_Cached(t *testing.T) {
+	first, err := compilePattern(`^my-agent/.*$`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second, _ := compilePattern(`^my-agent/.*$`); second != first {
+		t.Error("expected the compiled pattern to be reused")
+	}
+	if _, err := compilePattern(`(`); err == nil {
+		t.Error("expected an invalid pattern to fail")
+	}
+}
@@ -0,0 +1,378 @@
+package workflowai
+
+import (
+	"cmp"
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/openai/openai-go/option"
+)
+
+// TenantConfig configures the client of a tenant of a [ClientPool].
+type TenantConfig struct {
+	// APIKey is the WorkflowAI API key of the tenant. Get fails for a
+	// tenant without a key, unless the pool has a WithSharedAPIKey key.
+	APIKey string
+	// BudgetUSD caps the cost of the chat completions of the tenant over
+	// each BudgetPeriod, 0 for no budget. The cost is known once a
+	// completion ends, so concurrent completions can exceed the budget.
+	BudgetUSD float64
+	// BudgetPeriod is the period of the budget, e.g. 24 hours. 0 makes the
+	// budget apply to the lifetime of the client.
+	BudgetPeriod time.Duration
+	// SpentUSD is the cost already spent in the current period, e.g.
+	// restored from the spend reported to WithEvictionHandler
+	SpentUSD float64
+	// MaxConcurrency bounds the requests in flight of the tenant, 0 for no
+	// limit
+	MaxConcurrency int
+	// ThrottleReserve enables the throttling of the rate limits of the
+	// tenant with the reserve of [RateLimitTracker.EnableThrottling] when
+	// it is > 0
+	ThrottleReserve float64
+	// Options are added to the options of the pool
+	Options []option.RequestOption
+}
+
+// TenantLoader returns the configuration of a tenant, e.g. from a database.
+type TenantLoader func(ctx context.Context, tenant string) (TenantConfig, error)
+
+// BudgetExceededError is returned for the chat completions of a tenant that
+// spent its budget. The request is not sent.
+type BudgetExceededError struct {
+	Tenant    string
+	BudgetUSD float64
+	SpentUSD  float64
+	// ResetAt is the start of the next period, zero without period
+	ResetAt time.Time
+}
+
+func (e *BudgetExceededError) Error() string {
+	return fmt.Sprintf("workflowai: tenant %s spent $%.4f of its budget of $%.4f", e.Tenant, e.SpentUSD, e.BudgetUSD)
+}
+
+// TenantClient is the client of a tenant of a [ClientPool].
+type TenantClient struct {
+	Client
+	Tenant string
+	// Usage accumulates the usage of the chat completions of the tenant, by
+	// the labels of their context
+	Usage *UsageAccumulator
+
+	config TenantConfig
+	now    func() time.Time
+
+	mu          sync.Mutex
+	periodStart time.Time
+	spent       float64
+}
+
+// Spent returns the cost spent in the current period of the budget.
+func (c *TenantClient) Spent() float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.rollPeriod()
+	return c.spent
+}
+
+// rollPeriod starts a new period when the current one is over, with c.mu
+// held.
+func (c *TenantClient) rollPeriod() {
+	period := c.config.BudgetPeriod
+	if period <= 0 {
+		return
+	}
+	if now := c.now(); now.Sub(c.periodStart) >= period {
+		c.periodStart = c.periodStart.Add(now.Sub(c.periodStart).Truncate(period))
+		c.spent = 0
+	}
+}
+
+func (c *TenantClient) budgetMiddleware(req *http.Request, next option.MiddlewareNext) (*http.Response, error) {
+	if c.config.BudgetUSD > 0 && isChatCompletionRequest(req) {
+		c.mu.Lock()
+		c.rollPeriod()
+		if c.spent >= c.config.BudgetUSD {
+			err := &BudgetExceededError{Tenant: c.Tenant, BudgetUSD: c.config.BudgetUSD, SpentUSD: c.spent}
+			if c.config.BudgetPeriod > 0 {
+				err.ResetAt = c.periodStart.Add(c.config.BudgetPeriod)
+			}
+			c.mu.Unlock()
+			return nil, err
+		}
+		c.mu.Unlock()
+	}
+	return next(req)
+}
+
+func (c *TenantClient) observe(ctx context.Context, e CompleteEvent) {
+	c.Usage.Add(e, UsageLabels(ctx)...)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.rollPeriod()
+	c.spent += e.CostUSD
+}
+
+// ClientPool holds the clients of the tenants of a multi-tenant service,
+// each with the API key, the budget, the concurrency and the rate limits of
+// its tenant. The clients are created on their first use from the
+// configuration returned by the loader, and evicted when the pool is full or
+// when they are idle:
+//
+//	pool := workflowai.NewClientPool(func(ctx context.Context, tenant string) (workflowai.TenantConfig, error) {
+//		org, err := db.Org(ctx, tenant)
+//		return workflowai.TenantConfig{APIKey: org.WorkflowAIKey, BudgetUSD: org.DailyBudget, BudgetPeriod: 24 * time.Hour}, err
+//	}, workflowai.WithMaxTenants(1000), workflowai.WithIdleTimeout(time.Hour))
+//	client, err := pool.Get(ctx, tenantID)
+//	completion, err := client.Chat.Completions.New(ctx, params)
+//
+// The state of an evicted tenant is lost, including its spend: the handler
+// of WithEvictionHandler receives it, to restore it with
+// TenantConfig.SpentUSD. It is safe for concurrent use.
+type ClientPool struct {
+	load        TenantLoader
+	options     []option.RequestOption
+	sharedKey   string
+	maxTenants  int
+	idleTimeout time.Duration
+	loadTimeout time.Duration
+	onEvict     func(tenant string, usage UsageSnapshot, spentUSD float64)
+	now         func() time.Time
+
+	mu      sync.Mutex
+	tenants map[string]*poolEntry
+	// lru orders the loaded entries from the most recently used
+	lru *list.List
+}
+
+type poolEntry struct {
+	tenant string
+	ready  chan struct{}
+	client *TenantClient
+	err    error
+	used   time.Time
+	elem   *list.Element
+}
+
+type ClientPoolOption func(*ClientPool)
+
+// WithPoolOptions sets the options of the clients of all the tenants.
+func WithPoolOptions(opts ...option.RequestOption) ClientPoolOption {
+	return func(p *ClientPool) {
+		p.options = append(p.options, opts...)
+	}
+}
+
+// WithSharedAPIKey sets the API key of the tenants whose configuration has
+// no APIKey. Without it, Get fails for them rather than falling back to
+// WORKFLOWAI_API_KEY, which would bill their completions to the organization
+// of the service.
+func WithSharedAPIKey(key string) ClientPoolOption {
+	return func(p *ClientPool) {
+		p.sharedKey = key
+	}
+}
+
+// WithMaxTenants bounds the clients of the pool, evicting the least recently
+// used ones, 0 for no limit.
+func WithMaxTenants(n int) ClientPoolOption {
+	return func(p *ClientPool) {
+		p.maxTenants = n
+	}
+}
+
+// WithIdleTimeout evicts the clients unused for d, 0 to keep them.
+func WithIdleTimeout(d time.Duration) ClientPoolOption {
+	return func(p *ClientPool) {
+		p.idleTimeout = d
+	}
+}
+
+// WithLoadTimeout bounds the loads of the tenant configurations, 30 seconds
+// by default. The loads are not canceled with the context of the call that
+// started them, since concurrent calls for the tenant wait for them too.
+func WithLoadTimeout(d time.Duration) ClientPoolOption {
+	return func(p *ClientPool) {
+		p.loadTimeout = d
+	}
+}
+
+// WithEvictionHandler calls fn with the usage and the spend of the evicted
+// tenants.
+func WithEvictionHandler(fn func(tenant string, usage UsageSnapshot, spentUSD float64)) ClientPoolOption {
+	return func(p *ClientPool) {
+		p.onEvict = fn
+	}
+}
+
+// NewClientPool returns a pool loading the configuration of the tenants with
+// load.
+func NewClientPool(load TenantLoader, opts ...ClientPoolOption) *ClientPool {
+	p := &ClientPool{load: load, loadTimeout: 30 * time.Second, now: time.Now, tenants: map[string]*poolEntry{}, lru: list.New()}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// Get returns the client of a tenant, loading its configuration on first use.
+// Concurrent calls for a tenant share the load, and a failed load is not
+// cached. A call whose context is done returns without canceling the load.
+func (p *ClientPool) Get(ctx context.Context, tenant string) (*TenantClient, error) {
+	p.mu.Lock()
+	evicted := p.evictIdle()
+	entry, ok := p.tenants[tenant]
+	if !ok {
+		entry = &poolEntry{tenant: tenant, ready: make(chan struct{})}
+		p.tenants[tenant] = entry
+		p.mu.Unlock()
+		p.notify(evicted)
+		go p.create(context.WithoutCancel(ctx), entry)
+	} else {
+		p.mu.Unlock()
+		p.notify(evicted)
+	}
+
+	select {
+	case <-entry.ready:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if entry.err != nil {
+		return nil, entry.err
+	}
+	p.mu.Lock()
+	if entry.elem != nil {
+		entry.used = p.now()
+		p.lru.MoveToFront(entry.elem)
+	}
+	p.mu.Unlock()
+	return entry.client, nil
+}
+
+// create loads the configuration of an entry, on a context detached from the
+// call that created the entry.
+func (p *ClientPool) create(ctx context.Context, entry *poolEntry) {
+	defer close(entry.ready)
+	if p.loadTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.loadTimeout)
+		defer cancel()
+	}
+	config, err := p.load(ctx, entry.tenant)
+	if err == nil && config.APIKey == "" && p.sharedKey == "" {
+		err = errors.New("no API key")
+	}
+	if err != nil {
+		entry.err = fmt.Errorf("workflowai: loading tenant %s: %w", entry.tenant, err)
+		p.mu.Lock()
+		delete(p.tenants, entry.tenant)
+		p.mu.Unlock()
+		return
+	}
+	entry.client = p.newClient(entry.tenant, config)
+
+	p.mu.Lock()
+	entry.used = p.now()
+	entry.elem = p.lru.PushFront(entry)
+	var evicted []*poolEntry
+	for p.maxTenants > 0 && p.lru.Len() > p.maxTenants {
+		evicted = append(evicted, p.remove(p.lru.Back().Value.(*poolEntry)))
+	}
+	p.mu.Unlock()
+	p.notify(evicted)
+}
+
+func (p *ClientPool) newClient(tenant string, config TenantConfig) *TenantClient {
+	c := &TenantClient{
+		Tenant:      tenant,
+		Usage:       NewUsageAccumulator(),
+		config:      config,
+		now:         p.now,
+		periodStart: p.now(),
+		spent:       config.SpentUSD,
+	}
+	hooks := &Hooks{}
+	hooks.OnComplete(c.observe)
+	opts := append([]option.RequestOption(nil), p.options...)
+	opts = append(opts, option.WithAPIKey(cmp.Or(config.APIKey, p.sharedKey)))
+	opts = append(opts, config.Options...)
+	opts = append(opts, option.WithMiddleware(c.budgetMiddleware))
+	if config.MaxConcurrency > 0 {
+		opts = append(opts, option.WithMiddleware(NewPriorityQueue(config.MaxConcurrency).Middleware()))
+	}
+	opts = append(opts, option.WithMiddleware(hooks.Middleware()))
+	c.Client = NewClient(opts...)
+	if config.ThrottleReserve > 0 {
+		c.RateLimits.EnableThrottling(config.ThrottleReserve)
+	}
+	return c
+}
+
+// evictIdle removes the idle entries, with p.mu held.
+func (p *ClientPool) evictIdle() []*poolEntry {
+	if p.idleTimeout <= 0 {
+		return nil
+	}
+	var evicted []*poolEntry
+	now := p.now()
+	for back := p.lru.Back(); back != nil; back = p.lru.Back() {
+		entry := back.Value.(*poolEntry)
+		if now.Sub(entry.used) < p.idleTimeout {
+			break
+		}
+		evicted = append(evicted, p.remove(entry))
+	}
+	return evicted
+}
+
+// remove removes a loaded entry, with p.mu held.
+func (p *ClientPool) remove(entry *poolEntry) *poolEntry {
+	p.lru.Remove(entry.elem)
+	entry.elem = nil
+	if p.tenants[entry.tenant] == entry {
+		delete(p.tenants, entry.tenant)
+	}
+	return entry
+}
+
+func (p *ClientPool) notify(evicted []*poolEntry) {
+	if p.onEvict == nil {
+		return
+	}
+	for _, entry := range evicted {
+		p.onEvict(entry.tenant, entry.client.Usage.Snapshot(), entry.client.Spent())
+	}
+}
+
+// Evict removes the client of a tenant, e.g. after its configuration
+// changed. It is reloaded on its next use.
+func (p *ClientPool) Evict(tenant string) {
+	p.mu.Lock()
+	entry, ok := p.tenants[tenant]
+	if !ok || entry.elem == nil {
+		p.mu.Unlock()
+		return
+	}
+	p.remove(entry)
+	p.mu.Unlock()
+	p.notify([]*poolEntry{entry})
+}
+
+// Tenants returns the tenants with a client in the pool, sorted.
+func (p *ClientPool) Tenants() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	tenants := make([]string, 0, p.lru.Len())
+	for e := p.lru.Front(); e != nil; e = e.Next() {
+		tenants = append(tenants, e.Value.(*poolEntry).tenant)
+	}
+	sort.Strings(tenants)
+	return tenants
+}
@@ -0,0 +1,186 @@
+package workflowai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+func TestClientPool(t *testing.T) {
+	var mu sync.Mutex
+	var keys []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		keys = append(keys, r.Header.Get("Authorization"))
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, testCompletion) // $0.5
+	}))
+	defer server.Close()
+
+	loads := map[string]int{}
+	var evicted []string
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	pool := NewClientPool(func(_ context.Context, tenant string) (TenantConfig, error) {
+		loads[tenant]++
+		if tenant == "unknown" {
+			return TenantConfig{}, errors.New("no such tenant")
+		}
+		return TenantConfig{APIKey: "key-" + tenant, BudgetUSD: 1, BudgetPeriod: time.Hour, SpentUSD: 0.25}, nil
+	},
+		WithPoolOptions(option.WithBaseURL(server.URL+"/v1"), option.WithMaxRetries(0)),
+		WithMaxTenants(2),
+		WithIdleTimeout(time.Minute),
+		WithEvictionHandler(func(tenant string, usage UsageSnapshot, spent float64) {
+			evicted = append(evicted, fmt.Sprintf("%s:%d:%.2f", tenant, usage.Total.Requests, spent))
+		}),
+	)
+	pool.now = func() time.Time { return now }
+	ctx := context.Background()
+	params := openai.ChatCompletionNewParams{
+		Model:    "my-agent/gpt-4o",
+		Messages: []openai.ChatCompletionMessageParamUnion{openai.UserMessage("Hi")},
+	}
+
+	acme, err := pool.Get(ctx, "acme")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := pool.Get(ctx, "acme"); again != acme || loads["acme"] != 1 {
+		t.Error("expected the client to be reused")
+	}
+	// $0.25 + 2 * $0.5 exceeds the budget of $1
+	for i := 0; i < 2; i++ {
+		if _, err := acme.Chat.Completions.New(ctx, params); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var budgetErr *BudgetExceededError
+	if _, err := acme.Chat.Completions.New(ctx, params); !errors.As(err, &budgetErr) || budgetErr.SpentUSD != 1.25 || !budgetErr.ResetAt.Equal(now.Add(time.Hour)) {
+		t.Fatalf("expected a budget error, got %v", err)
+	}
+	if acme.Usage.Total().Requests != 2 || keys[0] != "Bearer key-acme" {
+		t.Errorf("unexpected usage %+v and keys %v", acme.Usage.Total(), keys)
+	}
+	// The budget resets with the period
+	now = now.Add(time.Hour)
+	if _, err := acme.Chat.Completions.New(ctx, params); err != nil {
+		t.Fatal(err)
+	}
+	if spent := acme.Spent(); spent != 0.5 {
+		t.Errorf("expected $0.5 spent in the new period, got %v", spent)
+	}
+
+	if _, err := pool.Get(ctx, "unknown"); err == nil {
+		t.Error("expected a load error")
+	}
+	if _, err := pool.Get(ctx, "unknown"); err == nil || loads["unknown"] != 2 {
+		t.Error("expected the failed load to be retried")
+	}
+
+	// The least recently used tenant is evicted past 2 tenants
+	if _, err := pool.Get(ctx, "globex"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pool.Get(ctx, "initech"); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"globex", "initech"}; !reflect.DeepEqual(pool.Tenants(), want) {
+		t.Errorf("got tenants %v", pool.Tenants())
+	}
+	// The idle tenants are evicted
+	now = now.Add(2 * time.Minute)
+	if _, err := pool.Get(ctx, "acme"); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"acme:3:0.50", "globex:0:0.25", "initech:0:0.25"}; !reflect.DeepEqual(evicted, want) {
+		t.Errorf("got evictions %v", evicted)
+	}
+	if loads["acme"] != 2 {
+		t.Errorf("expected acme to be reloaded, got %d loads", loads["acme"])
+	}
+}
+
+func TestClientPool_DetachedLoad(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	pool := NewClientPool(func(ctx context.Context, tenant string) (TenantConfig, error) {
+		close(started)
+		<-release
+		return TenantConfig{APIKey: "key-" + tenant}, ctx.Err()
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error)
+	go func() {
+		_, err := pool.Get(ctx, "acme")
+		first <- err
+	}()
+	<-started
+	second := make(chan error)
+	go func() {
+		_, err := pool.Get(context.Background(), "acme")
+		second <- err
+	}()
+	cancel()
+	if err := <-first; !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the canceled call to return, got %v", err)
+	}
+	close(release)
+	if err := <-second; err != nil {
+		t.Errorf("expected the load not to be canceled with the first call, got %v", err)
+	}
+}
+
+func TestClientPool_APIKey(t *testing.T) {
+	var keys []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get("Authorization"))
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, testCompletion)
+	}))
+	defer server.Close()
+	t.Setenv("WORKFLOWAI_API_KEY", "service-key")
+	load := func(_ context.Context, tenant string) (TenantConfig, error) {
+		if tenant == "acme" {
+			return TenantConfig{APIKey: "key-acme"}, nil
+		}
+		return TenantConfig{}, nil
+	}
+	ctx := context.Background()
+
+	// A tenant without a key does not fall back to WORKFLOWAI_API_KEY
+	pool := NewClientPool(load, WithPoolOptions(option.WithBaseURL(server.URL+"/v1"), option.WithMaxRetries(0)))
+	if _, err := pool.Get(ctx, "globex"); err == nil {
+		t.Error("expected an error for a tenant without a key")
+	}
+	if tenants := pool.Tenants(); len(tenants) != 0 {
+		t.Errorf("expected the failed load not to be cached, got %v", tenants)
+	}
+
+	pool = NewClientPool(load, WithPoolOptions(option.WithBaseURL(server.URL+"/v1"), option.WithMaxRetries(0)), WithSharedAPIKey("shared-key"))
+	params := openai.ChatCompletionNewParams{
+		Model:    "my-agent/gpt-4o",
+		Messages: []openai.ChatCompletionMessageParamUnion{openai.UserMessage("Hi")},
+	}
+	for _, tenant := range []string{"acme", "globex"} {
+		client, err := pool.Get(ctx, tenant)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := client.Chat.Completions.New(ctx, params); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if want := []string{"Bearer key-acme", "Bearer shared-key"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("got keys %v", keys)
+	}
+}
@@ -0,0 +1,231 @@
+package workflowai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+// Capability is a capability a model must have for [Client.Preflight].
+type Capability string
+
+const (
+	CapabilityTools             Capability = "tools"
+	CapabilityParallelToolCalls Capability = "parallel_tool_calls"
+	// CapabilityStructuredOutput is the support of response formats. The
+	// platform enforces the output schemas of the agents on every model
+	// producing text.
+	CapabilityStructuredOutput Capability = "structured_output"
+	CapabilityImageInput       Capability = "image_input"
+	CapabilityAudioInput       Capability = "audio_input"
+	CapabilityPDFInput         Capability = "pdf_input"
+	CapabilityTemperature      Capability = "temperature"
+)
+
+// Has reports whether the capabilities include c. Unknown capabilities are
+// not supported.
+func (s ModelSupports) Has(c Capability) bool {
+	switch c {
+	case CapabilityTools:
+		return s.Tools
+	case CapabilityParallelToolCalls:
+		return s.ParallelToolCalls
+	case CapabilityStructuredOutput:
+		return s.Output.Text
+	case CapabilityImageInput:
+		return s.Input.Image
+	case CapabilityAudioInput:
+		return s.Input.Audio
+	case CapabilityPDFInput:
+		return s.Input.PDF
+	case CapabilityTemperature:
+		return s.Temperature
+	}
+	return false
+}
+
+// PreflightModel is a model a service uses, with the capabilities it needs.
+type PreflightModel struct {
+	// Model is the model of the requests, e.g. "my-agent/gpt-4o-latest", or
+	// a deployment, e.g. "my-agent/#1/production"
+	Model    string
+	Requires []Capability
+}
+
+// PreflightCheck is a check of a [PreflightReport].
+type PreflightCheck struct {
+	// Name is what was checked, e.g. "api key" or a model
+	Name string
+	Err  error
+	// Detail describes a successful check, e.g. the model of a deployment
+	Detail string
+}
+
+func (c PreflightCheck) OK() bool {
+	return c.Err == nil
+}
+
+// PreflightReport is the result of [Client.Preflight].
+type PreflightReport struct {
+	Checks []PreflightCheck
+	// Organization is the organization of the API key, when it is valid
+	Organization string
+	CreditsUSD   float64
+}
+
+// OK reports whether all the checks passed.
+func (r *PreflightReport) OK() bool {
+	return r.Err() == nil
+}
+
+// Err joins the errors of the failed checks.
+func (r *PreflightReport) Err() error {
+	var errs []error
+	for _, c := range r.Checks {
+		if c.Err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", c.Name, c.Err))
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("workflowai: preflight failed: %w", errors.Join(errs...))
+}
+
+func (r *PreflightReport) String() string {
+	var b strings.Builder
+	for _, c := range r.Checks {
+		if c.Err != nil {
+			fmt.Fprintf(&b, "FAIL %s: %v\n", c.Name, c.Err)
+		} else if c.Detail != "" {
+			fmt.Fprintf(&b, "ok   %s: %s\n", c.Name, c.Detail)
+		} else {
+			fmt.Fprintf(&b, "ok   %s\n", c.Name)
+		}
+	}
+	return b.String()
+}
+
+type organizationSettings struct {
+	Name              string  `json:"name"`
+	Slug              string  `json:"slug"`
+	CurrentCreditsUSD float64 `json:"current_credits_usd"`
+}
+
+// Preflight checks the configuration of a service at startup: that the API
+// key is valid, and that its models and deployments exist and have the
+// capabilities it needs. The report lists every check, and the error joins
+// the failed ones, so that a misconfiguration fails the startup with all its
+// causes:
+//
+//	report, err := client.Preflight(ctx, []workflowai.PreflightModel{
+//		{Model: "support-agent/#1/production", Requires: []workflowai.Capability{workflowai.CapabilityTools}},
+//	})
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	log.Print(report)
+//
+// The models are not checked when the API key is invalid. Deployments are
+// checked with the model of their deployed version.
+func (c Client) Preflight(ctx context.Context, models []PreflightModel, opts ...option.RequestOption) (*PreflightReport, error) {
+	report := &PreflightReport{}
+	var org organizationSettings
+	// The organization endpoints are not under /v1
+	if err := c.Execute(ctx, http.MethodGet, "../organization/settings", nil, &org, opts...); err != nil {
+		report.Checks = append(report.Checks, PreflightCheck{Name: "api key", Err: err})
+		return report, report.Err()
+	}
+	report.Organization, report.CreditsUSD = org.Name, org.CurrentCreditsUSD
+	if report.Organization == "" {
+		report.Organization = org.Slug
+	}
+	credits := PreflightCheck{Name: "credits", Detail: fmt.Sprintf("$%.2f", org.CurrentCreditsUSD)}
+	if org.CurrentCreditsUSD <= 0 {
+		credits.Err = errors.New("the organization has no credits left")
+	}
+	report.Checks = append(report.Checks, PreflightCheck{Name: "api key", Detail: report.Organization}, credits)
+	if len(models) == 0 {
+		return report, report.Err()
+	}
+
+	catalog, err := ListModels(ctx, c.Client, opts...)
+	if err != nil {
+		report.Checks = append(report.Checks, PreflightCheck{Name: "models", Err: err})
+		return report, report.Err()
+	}
+	for _, m := range models {
+		check := PreflightCheck{Name: m.Model}
+		model := m.Model
+		if strings.Contains(m.Model, "#") {
+			model, check.Err = c.deployedModel(ctx, m.Model, opts)
+			check.Detail = model
+		}
+		if check.Err == nil {
+			check.Err = checkCapabilities(catalog, model, m.Requires)
+		}
+		report.Checks = append(report.Checks, check)
+	}
+	return report, report.Err()
+}
+
+// deployedModel returns the model of the version of a deployment, e.g.
+// "my-agent/#1/production".
+func (c Client) deployedModel(ctx context.Context, deployment string, opts []option.RequestOption) (string, error) {
+	parts := strings.Split(deployment, "/")
+	if len(parts) != 3 || !strings.HasPrefix(parts[1], "#") {
+		return "", fmt.Errorf("invalid deployment, expected <agent>/#<schema>/<environment>")
+	}
+	agentID, environment := parts[0], parts[2]
+	schemaID, err := strconv.Atoi(parts[1][1:])
+	if err != nil {
+		return "", fmt.Errorf("invalid schema %q", parts[1])
+	}
+	versions, err := c.Versions.ListDeployed(ctx, agentID, opts...)
+	if err != nil {
+		var apiErr *openai.Error
+		if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+			return "", fmt.Errorf("unknown agent %s", agentID)
+		}
+		return "", err
+	}
+	for _, version := range versions {
+		if version.SchemaID != schemaID {
+			continue
+		}
+		for _, d := range version.Deployments {
+			if d.Environment != environment {
+				continue
+			}
+			// The deployed versions have their model in their properties
+			if model, _ := version.Properties["model"].(string); model != "" {
+				return model, nil
+			}
+			return version.Model, nil
+		}
+	}
+	return "", fmt.Errorf("no version of schema %d is deployed to %s", schemaID, environment)
+}
+
+func checkCapabilities(catalog []ModelInfo, model string, requires []Capability) error {
+	info := catalogModel(catalog, model)
+	if info == nil {
+		return fmt.Errorf("unknown model %s", model)
+	}
+	var missing []string
+	for _, c := range requires {
+		if !info.Supports.Has(c) {
+			missing = append(missing, string(c))
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%s does not support %s", info.ID, strings.Join(missing, ", "))
+	}
+	return nil
+}
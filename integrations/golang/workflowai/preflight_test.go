@@ -0,0 +1,89 @@
+package workflowai
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+func TestClient_Preflight(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /organization/settings", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer test" {
+			writeJSON(w, http.StatusUnauthorized, map[string]any{"error": map[string]any{"message": "invalid API key"}})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"name": "Acme", "slug": "acme", "current_credits_usd": 12.5})
+	})
+	mux.HandleFunc("GET /v1/models", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]any{"object": "list", "data": []map[string]any{
+			{"id": "gpt-4o", "object": "model", "supports": map[string]any{
+				"input": map[string]any{"text": true, "image": true}, "output": map[string]any{"text": true}, "tools": true,
+			}},
+			{"id": "o1-mini", "object": "model", "supports": map[string]any{
+				"input": map[string]any{"text": true}, "output": map[string]any{"text": true},
+			}},
+		}})
+	})
+	mux.HandleFunc("GET /_/agents/support/versions/deployed", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]any{"items": []map[string]any{{
+			"id": "v-1", "schema_id": 1, "iteration": 1, "properties": map[string]any{"model": "o1-mini"},
+			"deployments": []map[string]any{{"environment": "production", "deployed_at": "2024-05-01T00:00:00Z"}},
+		}}, "count": 1})
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	client := NewClient(option.WithBaseURL(server.URL+"/v1"), option.WithAPIKey("test"), option.WithMaxRetries(0))
+	ctx := context.Background()
+
+	report, err := client.Preflight(ctx, []PreflightModel{
+		{Model: "support/gpt-4o", Requires: []Capability{CapabilityTools, CapabilityStructuredOutput, CapabilityImageInput}},
+		{Model: "support/#1/production", Requires: []Capability{CapabilityStructuredOutput}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.OK() || report.Organization != "Acme" || report.CreditsUSD != 12.5 || len(report.Checks) != 4 ||
+		report.Checks[3].Detail != "o1-mini" {
+		t.Errorf("unexpected report %+v", report)
+	}
+
+	// Every misconfiguration is reported
+	report, err = client.Preflight(ctx, []PreflightModel{
+		{Model: "support/#1/production", Requires: []Capability{CapabilityTools}},
+		{Model: "support/#1/staging"},
+		{Model: "other/#1/production"},
+		{Model: "support/gpt-5"},
+		{Model: "support/gpt-4o", Requires: []Capability{CapabilityAudioInput}},
+	})
+	if err == nil || report.OK() {
+		t.Fatal("expected an error")
+	}
+	for _, expected := range []string{
+		"support/#1/production: o1-mini does not support tools",
+		"support/#1/staging: no version of schema 1 is deployed to staging",
+		"other/#1/production: unknown agent other",
+		"support/gpt-5: unknown model support/gpt-5",
+		"support/gpt-4o: gpt-4o does not support audio_input",
+	} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected %q in %v", expected, err)
+		}
+	}
+	if !strings.Contains(report.String(), "ok   api key: Acme") || !strings.Contains(report.String(), "FAIL support/gpt-5") {
+		t.Errorf("unexpected report\n%s", report)
+	}
+
+	// An invalid API key
+	report, err = client.Preflight(ctx, []PreflightModel{{Model: "gpt-4o"}}, option.WithAPIKey("invalid"))
+	var apiErr *openai.Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized || len(report.Checks) != 1 || report.Checks[0].Name != "api key" {
+		t.Errorf("unexpected report %+v %v", report, err)
+	}
+}
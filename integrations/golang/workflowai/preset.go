@@ -0,0 +1,170 @@
+package workflowai
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+// MetadataPreset is the metadata key of the name of the preset of a run.
+const MetadataPreset = "preset"
+
+// Preset is a named set of sampling parameters, shared between the services
+// of a team instead of copying parameter blocks. Nil parameters keep the
+// value of the request.
+type Preset struct {
+	Name             string   `json:"name"`
+	Temperature      *float64 `json:"temperature,omitempty"`
+	TopP             *float64 `json:"top_p,omitempty"`
+	PresencePenalty  *float64 `json:"presence_penalty,omitempty"`
+	FrequencyPenalty *float64 `json:"frequency_penalty,omitempty"`
+}
+
+var (
+	// PresetPrecise is for extraction and classification, where the same
+	// input should give the same output.
+	PresetPrecise = Preset{Name: "precise", Temperature: floatPtr(0)}
+	// PresetBalanced is for assistants and most conversations.
+	PresetBalanced = Preset{Name: "balanced", Temperature: floatPtr(0.7)}
+	// PresetCreative is for writing and brainstorming, with less repeated
+	// wording.
+	PresetCreative = Preset{Name: "creative", Temperature: floatPtr(1.1), TopP: floatPtr(0.95), PresencePenalty: floatPtr(0.6)}
+)
+
+func floatPtr(v float64) *float64 { return &v }
+
+// Apply sets the parameters of the preset on params.
+func (p Preset) Apply(params *openai.ChatCompletionNewParams) {
+	if p.Temperature != nil {
+		params.Temperature = openai.Float(*p.Temperature)
+	}
+	if p.TopP != nil {
+		params.TopP = openai.Float(*p.TopP)
+	}
+	if p.PresencePenalty != nil {
+		params.PresencePenalty = openai.Float(*p.PresencePenalty)
+	}
+	if p.FrequencyPenalty != nil {
+		params.FrequencyPenalty = openai.Float(*p.FrequencyPenalty)
+	}
+}
+
+// Validate returns a [*ParamsError] if the parameters of the preset are out
+// of range.
+func (p Preset) Validate() error {
+	params := openai.ChatCompletionNewParams{Model: "preset " + p.Name}
+	p.Apply(&params)
+	return ValidateParams(params, nil)
+}
+
+// Option returns a request option that sets the parameters of the preset on
+// a chat completion, and tags its run with the name of the preset under
+// [MetadataPreset].
+func (p Preset) Option() option.RequestOption {
+	return option.WithMiddleware(func(req *http.Request, next option.MiddlewareNext) (*http.Response, error) {
+		if !isChatCompletionRequest(req) {
+			return next(req)
+		}
+		body, err := readRequestBody(req)
+		if err != nil {
+			return nil, err
+		}
+		payload, err := decodeJSONObject(body)
+		if err != nil {
+			// Let the server report invalid requests
+			return next(req)
+		}
+		for key, value := range map[string]*float64{
+			"temperature":       p.Temperature,
+			"top_p":             p.TopP,
+			"presence_penalty":  p.PresencePenalty,
+			"frequency_penalty": p.FrequencyPenalty,
+		} {
+			if value != nil {
+				payload[key] = *value
+			}
+		}
+		if p.Name != "" {
+			metadata, _ := payload["metadata"].(map[string]any)
+			if metadata == nil {
+				metadata = map[string]any{}
+			}
+			metadata[MetadataPreset] = p.Name
+			payload["metadata"] = metadata
+		}
+		encoded, err := encodeJSON(payload)
+		if err != nil {
+			return nil, err
+		}
+		setRequestBody(req, encoded)
+		return next(req)
+	})
+}
+
+// Presets are presets by name. The built-in presets are available under
+// their names unless overridden.
+type Presets map[string]Preset
+
+// LoadPresets reads user-defined presets from a JSON file, either an array of
+// presets or an object of presets by name:
+//
+//	{"support": {"temperature": 0.3, "presence_penalty": 0.2}}
+//
+// The presets are validated with [Preset.Validate].
+func LoadPresets(path string) (Presets, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePresets(data)
+}
+
+// ParsePresets parses presets in the format of [LoadPresets].
+func ParsePresets(data []byte) (Presets, error) {
+	var list []Preset
+	if err := json.Unmarshal(data, &list); err != nil {
+		var byName map[string]Preset
+		if err := json.Unmarshal(data, &byName); err != nil {
+			return nil, fmt.Errorf("workflowai: decoding presets: %w", err)
+		}
+		for name, p := range byName {
+			p.Name = name
+			list = append(list, p)
+		}
+	}
+	presets := make(Presets, len(list))
+	for _, p := range list {
+		if p.Name == "" {
+			return nil, errors.New("workflowai: preset without a name")
+		}
+		if err := p.Validate(); err != nil {
+			return nil, err
+		}
+		presets[p.Name] = p
+	}
+	return presets, nil
+}
+
+// Get returns the preset name, falling back to the built-in presets.
+func (p Presets) Get(name string) (Preset, error) {
+	all := Presets{PresetPrecise.Name: PresetPrecise, PresetBalanced.Name: PresetBalanced, PresetCreative.Name: PresetCreative}
+	for n, preset := range p {
+		all[n] = preset
+	}
+	if preset, ok := all[name]; ok {
+		return preset, nil
+	}
+	names := make([]string, 0, len(all))
+	for n := range all {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return Preset{}, fmt.Errorf("workflowai: unknown preset %q, available presets are %s", name, strings.Join(names, ", "))
+}
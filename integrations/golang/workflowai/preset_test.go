@@ -0,0 +1,80 @@
+package workflowai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+func TestPresetOption(t *testing.T) {
+	var sent map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&sent)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, testCompletion)
+	}))
+	defer server.Close()
+
+	client := NewClient(option.WithBaseURL(server.URL+"/v1"), option.WithAPIKey("test"), option.WithMaxRetries(0))
+	_, err := client.Chat.Completions.New(context.Background(), openai.ChatCompletionNewParams{
+		Model:       "my-agent/gpt-4o-mini-latest",
+		Messages:    []openai.ChatCompletionMessageParamUnion{openai.UserMessage("Write a poem")},
+		Temperature: openai.Float(0.2),
+		Metadata:    map[string]string{"user": "u1"},
+	}, PresetCreative.Option())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sent["temperature"] != 1.1 || sent["top_p"] != 0.95 || sent["presence_penalty"] != 0.6 {
+		t.Errorf("unexpected parameters %v", sent)
+	}
+	if _, ok := sent["frequency_penalty"]; ok {
+		t.Error("unexpected frequency_penalty")
+	}
+	metadata := sent["metadata"].(map[string]any)
+	if metadata[MetadataPreset] != "creative" || metadata["user"] != "u1" {
+		t.Errorf("unexpected metadata %v", metadata)
+	}
+}
+
+func TestLoadPresets(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "presets.json")
+	os.WriteFile(path, []byte(`{"support": {"temperature": 0.3, "presence_penalty": 0.2}, "precise": {"temperature": 0.1}}`), 0o600)
+	presets, err := LoadPresets(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	support, err := presets.Get("support")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var params openai.ChatCompletionNewParams
+	support.Apply(&params)
+	if params.Temperature.Value != 0.3 || params.PresencePenalty.Value != 0.2 || params.TopP.Valid() {
+		t.Errorf("unexpected params %+v", params)
+	}
+	if precise, _ := presets.Get("precise"); *precise.Temperature != 0.1 {
+		t.Errorf("expected the user-defined precise preset, got %v", *precise.Temperature)
+	}
+	if creative, _ := presets.Get("creative"); creative.Name != "creative" {
+		t.Errorf("expected the built-in creative preset, got %+v", creative)
+	}
+	if _, err := presets.Get("wild"); err == nil || err.Error() != `workflowai: unknown preset "wild", available presets are balanced, creative, precise, support` {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	var paramsErr *ParamsError
+	if _, err := ParsePresets([]byte(`[{"name": "hot", "temperature": 3}]`)); !errors.As(err, &paramsErr) {
+		t.Errorf("expected a params error, got %v", err)
+	}
+}
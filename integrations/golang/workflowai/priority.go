@@ -0,0 +1,237 @@
+package workflowai
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/openai/openai-go/option"
+)
+
+// Priority is the class of a request in a [PriorityQueue].
+type Priority int
+
+const (
+	// PriorityInteractive is for the requests a user is waiting for, the
+	// default of the requests without priority.
+	PriorityInteractive Priority = iota
+	// PriorityBackground is for the requests of background work.
+	PriorityBackground
+	// PriorityBatch is for the requests of batch jobs.
+	PriorityBatch
+
+	priorityCount = 3
+)
+
+func (p Priority) String() string {
+	switch p {
+	case PriorityInteractive:
+		return "interactive"
+	case PriorityBackground:
+		return "background"
+	case PriorityBatch:
+		return "batch"
+	}
+	return "unknown"
+}
+
+type priorityKey struct{}
+
+// ContextWithPriority returns a context whose requests are queued with
+// priority p.
+func ContextWithPriority(ctx context.Context, p Priority) context.Context {
+	return context.WithValue(ctx, priorityKey{}, p)
+}
+
+// PriorityFromContext returns the priority set with [ContextWithPriority],
+// [PriorityInteractive] by default.
+func PriorityFromContext(ctx context.Context) Priority {
+	if p, ok := ctx.Value(priorityKey{}).(Priority); ok && p >= 0 && p < priorityCount {
+		return p
+	}
+	return PriorityInteractive
+}
+
+// PriorityQueue bounds the requests in flight of a client, and gives the
+// free slots to the waiting requests of the highest priority first, so that a
+// flood of batch requests cannot delay the interactive ones:
+//
+//	queue := workflowai.NewPriorityQueue(16, workflowai.WithPriorityLimit(workflowai.PriorityBatch, 4))
+//	client := workflowai.NewClient(option.WithMiddleware(queue.Middleware()))
+//	...
+//	ctx = workflowai.ContextWithPriority(ctx, workflowai.PriorityBatch)
+//
+// The per class limits keep slots free for the higher classes. The requests
+// of a class are served in order. A request holds its slot until its
+// response body is closed or read to the end, so that streams count while they
+// are read. It is safe for concurrent use.
+type PriorityQueue struct {
+	mu       sync.Mutex
+	capacity int
+	limits   [priorityCount]int
+	running  [priorityCount]int
+	total    int
+	waiting  [priorityCount][]*queueWaiter
+}
+
+type queueWaiter struct {
+	ready   chan struct{}
+	granted bool
+}
+
+type PriorityQueueOption func(*PriorityQueue)
+
+// WithPriorityLimit bounds the requests in flight of a class, the capacity of
+// the queue by default.
+func WithPriorityLimit(p Priority, n int) PriorityQueueOption {
+	return func(q *PriorityQueue) {
+		if p >= 0 && p < priorityCount {
+			q.limits[p] = n
+		}
+	}
+}
+
+// NewPriorityQueue returns a queue running up to capacity requests at once.
+func NewPriorityQueue(capacity int, opts ...PriorityQueueOption) *PriorityQueue {
+	q := &PriorityQueue{capacity: max(capacity, 1)}
+	for _, opt := range opts {
+		opt(q)
+	}
+	return q
+}
+
+// Acquire waits for a slot for a request of priority p. release frees the
+// slot and must be called once.
+func (q *PriorityQueue) Acquire(ctx context.Context, p Priority) (release func(), err error) {
+	if p < 0 || p >= priorityCount {
+		p = PriorityInteractive
+	}
+	q.mu.Lock()
+	if len(q.waiting[p]) == 0 && q.canRun(p) {
+		q.start(p)
+		q.mu.Unlock()
+		return q.releaser(p), nil
+	}
+	w := &queueWaiter{ready: make(chan struct{})}
+	q.waiting[p] = append(q.waiting[p], w)
+	q.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		return q.releaser(p), nil
+	case <-ctx.Done():
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		if w.granted {
+			// The slot was given in between
+			q.finish(p)
+		} else {
+			q.remove(p, w)
+		}
+		return nil, ctx.Err()
+	}
+}
+
+func (q *PriorityQueue) canRun(p Priority) bool {
+	limit := q.limits[p]
+	return q.total < q.capacity && (limit <= 0 || q.running[p] < limit)
+}
+
+func (q *PriorityQueue) start(p Priority) {
+	q.running[p]++
+	q.total++
+}
+
+func (q *PriorityQueue) releaser(p Priority) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			q.mu.Lock()
+			defer q.mu.Unlock()
+			q.finish(p)
+		})
+	}
+}
+
+// finish frees a slot and gives the free slots to the waiting requests, with
+// q.mu held.
+func (q *PriorityQueue) finish(p Priority) {
+	q.running[p]--
+	q.total--
+	for class := Priority(0); class < priorityCount; class++ {
+		for len(q.waiting[class]) > 0 && q.canRun(class) {
+			w := q.waiting[class][0]
+			q.waiting[class] = q.waiting[class][1:]
+			q.start(class)
+			w.granted = true
+			close(w.ready)
+		}
+	}
+}
+
+func (q *PriorityQueue) remove(p Priority, w *queueWaiter) {
+	for i, other := range q.waiting[p] {
+		if other == w {
+			q.waiting[p] = append(q.waiting[p][:i], q.waiting[p][i+1:]...)
+			return
+		}
+	}
+}
+
+// QueueStats are the requests of a class of a [PriorityQueue].
+type QueueStats struct {
+	Running int
+	Waiting int
+}
+
+// Stats returns the requests running and waiting by priority.
+func (q *PriorityQueue) Stats() map[Priority]QueueStats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	stats := make(map[Priority]QueueStats, priorityCount)
+	for p := Priority(0); p < priorityCount; p++ {
+		stats[p] = QueueStats{Running: q.running[p], Waiting: len(q.waiting[p])}
+	}
+	return stats
+}
+
+// Middleware returns the client middleware queuing the requests with the
+// priority of their context.
+func (q *PriorityQueue) Middleware() option.Middleware {
+	return q.middleware
+}
+
+func (q *PriorityQueue) middleware(req *http.Request, next option.MiddlewareNext) (*http.Response, error) {
+	release, err := q.Acquire(req.Context(), PriorityFromContext(req.Context()))
+	if err != nil {
+		return nil, err
+	}
+	res, err := next(req)
+	if err != nil || res.Body == nil {
+		release()
+		return res, err
+	}
+	res.Body = &releasingBody{ReadCloser: res.Body, release: release}
+	return res, nil
+}
+
+// releasingBody releases the slot of its request when it is closed or read
+// to the end.
+type releasingBody struct {
+	io.ReadCloser
+	release func()
+}
+
+func (b *releasingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil {
+		b.release()
+	}
+	return n, err
+}
+
+func (b *releasingBody) Close() error {
+	b.release()
+	return b.ReadCloser.Close()
+}
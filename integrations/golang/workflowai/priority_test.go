@@ -0,0 +1,104 @@
+package workflowai
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+func TestPriorityQueue_Order(t *testing.T) {
+	q := NewPriorityQueue(1)
+	ctx := context.Background()
+	release, err := q.Acquire(ctx, PriorityBatch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	order := make(chan Priority, 3)
+	waitFor := func(p Priority) {
+		release, err := q.Acquire(ctx, p)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		order <- p
+		release()
+	}
+	go waitFor(PriorityBatch)
+	waitQueued(t, q, PriorityBatch, 1)
+	go waitFor(PriorityBackground)
+	waitQueued(t, q, PriorityBackground, 1)
+	go waitFor(PriorityInteractive)
+	waitQueued(t, q, PriorityInteractive, 1)
+
+	release()
+	release() // No-op
+	for _, want := range []Priority{PriorityInteractive, PriorityBackground, PriorityBatch} {
+		if got := <-order; got != want {
+			t.Errorf("got %s, want %s", got, want)
+		}
+	}
+}
+
+func waitQueued(t *testing.T, q *PriorityQueue, p Priority, n int) {
+	t.Helper()
+	for i := 0; i < 100; i++ {
+		if q.Stats()[p].Waiting == n {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("expected %d waiting %s requests, got %+v", n, p, q.Stats())
+}
+
+func TestPriorityQueue_Limits(t *testing.T) {
+	q := NewPriorityQueue(3, WithPriorityLimit(PriorityBatch, 1))
+	ctx := context.Background()
+	if _, err := q.Acquire(ctx, PriorityBatch); err != nil {
+		t.Fatal(err)
+	}
+	// The second batch request waits while interactive ones run
+	timeout, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if _, err := q.Acquire(timeout, PriorityBatch); err == nil {
+		t.Error("expected the batch request to wait")
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := q.Acquire(ctx, PriorityInteractive); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stats := q.Stats()
+	if stats[PriorityBatch] != (QueueStats{Running: 1}) || stats[PriorityInteractive] != (QueueStats{Running: 2}) {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestPriorityQueue_Middleware(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, testCompletion)
+	}))
+	defer server.Close()
+	q := NewPriorityQueue(1)
+	client := NewClient(option.WithBaseURL(server.URL+"/v1"), option.WithAPIKey("test"), option.WithMaxRetries(0),
+		option.WithMiddleware(q.Middleware()))
+	params := openai.ChatCompletionNewParams{
+		Model:    "my-agent/gpt-4o",
+		Messages: []openai.ChatCompletionMessageParamUnion{openai.UserMessage("Hi")},
+	}
+	ctx := ContextWithPriority(context.Background(), PriorityBatch)
+	for i := 0; i < 2; i++ {
+		if _, err := client.Chat.Completions.New(ctx, params); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if stats := q.Stats()[PriorityBatch]; stats != (QueueStats{}) {
+		t.Errorf("expected the slots to be released, got %+v", stats)
+	}
+}
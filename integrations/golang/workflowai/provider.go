@@ -0,0 +1,127 @@
+package workflowai
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+
+	"github.com/openai/openai-go/option"
+)
+
+// ProviderPreferences choose the provider serving a model. WorkflowAI takes
+// a single provider per request and a flag disabling the fallbacks to other
+// models; ordered lists of providers and regions cannot be expressed.
+type ProviderPreferences struct {
+	// Provider is the only provider of the model used, e.g. "azure_openai":
+	// WorkflowAI does not fall back to the other providers of the model once
+	// a provider is set
+	Provider string
+	// NoFallbacks fails the request instead of falling back to other models
+	// when the model fails
+	NoFallbacks bool
+}
+
+// ProviderConstraintError is returned when the provider of a request cannot
+// serve it, or when no provider supports the model.
+type ProviderConstraintError struct {
+	Preferences ProviderPreferences
+	StatusCode  int
+	// Code is the error code of WorkflowAI, e.g. "no_provider_supporting_model"
+	Code    string
+	Message string
+}
+
+func (e *ProviderConstraintError) Error() string {
+	if e.Preferences.Provider == "" {
+		return fmt.Sprintf("workflowai: no provider serves the request: %s", e.Message)
+	}
+	constraint := "provider " + e.Preferences.Provider
+	if e.Preferences.NoFallbacks {
+		constraint += " without model fallbacks"
+	}
+	return fmt.Sprintf("workflowai: no provider serves the request (%s): %s", constraint, e.Message)
+}
+
+// providerErrorCodes are the error codes of the requests whose provider
+// preferences cannot be met.
+var providerErrorCodes = []string{"no_provider_supporting_model", "provider_unavailable"}
+
+// RequireProvider restricts the request to provider, e.g. "azure_openai",
+// and to its model, without fallbacks to other models.
+func RequireProvider(provider string) option.RequestOption {
+	return WithProviderPreferences(ProviderPreferences{Provider: provider, NoFallbacks: true})
+}
+
+// WithProviderPreferences sends the provider preferences of a request, as
+// the "provider" field and "use_fallback": "never" without fallbacks. The
+// preferences of several options are merged, the last provider winning.
+// Preferences that cannot be met fail the request with a
+// [*ProviderConstraintError], which is not retried.
+func WithProviderPreferences(prefs ProviderPreferences) option.RequestOption {
+	return option.WithMiddleware(func(req *http.Request, next option.MiddlewareNext) (*http.Response, error) {
+		if !isChatCompletionRequest(req) {
+			return next(req)
+		}
+		body, err := readRequestBody(req)
+		if err != nil {
+			return nil, err
+		}
+		payload, err := decodeJSONObject(body)
+		if err != nil {
+			// Let the server report invalid requests
+			return next(req)
+		}
+		var existing ProviderPreferences
+		existing.Provider, _ = payload["provider"].(string)
+		existing.NoFallbacks = payload["use_fallback"] == "never"
+		merged := existing.merge(prefs)
+		if merged.Provider != "" {
+			payload["provider"] = merged.Provider
+		}
+		if merged.NoFallbacks {
+			payload["use_fallback"] = "never"
+		}
+		encoded, err := encodeJSON(payload)
+		if err != nil {
+			return nil, err
+		}
+		setRequestBody(req, encoded)
+
+		res, err := next(req)
+		if err != nil || res.StatusCode < 400 {
+			return res, err
+		}
+		resBody, err := io.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		res.Body = io.NopCloser(bytes.NewReader(resBody))
+		var payloadErr struct {
+			Error struct {
+				Code    string `json:"code"`
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if json.Unmarshal(resBody, &payloadErr) == nil && slices.Contains(providerErrorCodes, payloadErr.Error.Code) {
+			return abort(&ProviderConstraintError{
+				Preferences: merged,
+				StatusCode:  res.StatusCode,
+				Code:        payloadErr.Error.Code,
+				Message:     payloadErr.Error.Message,
+			})
+		}
+		return res, nil
+	})
+}
+
+func (p ProviderPreferences) merge(other ProviderPreferences) ProviderPreferences {
+	if other.Provider != "" {
+		p.Provider = other.Provider
+	}
+	p.NoFallbacks = p.NoFallbacks || other.NoFallbacks
+	return p
+}
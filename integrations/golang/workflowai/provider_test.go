@@ -0,0 +1,70 @@
+package workflowai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+func TestWithProviderPreferences(t *testing.T) {
+	var sent map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&sent)
+		if sent["provider"] == "mistral_ai" {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": map[string]any{
+				"code":    "no_provider_supporting_model",
+				"message": "mistral_ai does not support gpt-4o-mini-latest",
+			}})
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, testCompletion)
+	}))
+	defer server.Close()
+
+	client := NewClient(option.WithBaseURL(server.URL+"/v1"), option.WithAPIKey("test"), option.WithMaxRetries(0))
+	params := openai.ChatCompletionNewParams{
+		Model:    "my-agent/gpt-4o-mini-latest",
+		Messages: []openai.ChatCompletionMessageParamUnion{openai.UserMessage("Hello")},
+	}
+	if _, err := client.Chat.Completions.New(context.Background(), params, WithProviderPreferences(ProviderPreferences{Provider: "azure_openai"})); err != nil {
+		t.Fatal(err)
+	}
+	if sent["provider"] != "azure_openai" {
+		t.Errorf("expected the provider as a string, got %v", sent["provider"])
+	}
+	if _, ok := sent["use_fallback"]; ok {
+		t.Error("expected the model fallbacks to be left to their default")
+	}
+
+	_, err := client.Chat.Completions.New(context.Background(), params,
+		WithProviderPreferences(ProviderPreferences{Provider: "azure_openai"}),
+		WithProviderPreferences(ProviderPreferences{Provider: "openai"}),
+		WithProviderPreferences(ProviderPreferences{NoFallbacks: true}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sent["provider"] != "openai" || sent["use_fallback"] != "never" {
+		t.Errorf("unexpected preferences %v, %v", sent["provider"], sent["use_fallback"])
+	}
+
+	_, err = client.Chat.Completions.New(context.Background(), params, RequireProvider("mistral_ai"))
+	var constraintErr *ProviderConstraintError
+	if !errors.As(err, &constraintErr) {
+		t.Fatalf("expected a provider constraint error, got %v", err)
+	}
+	if constraintErr.Code != "no_provider_supporting_model" || constraintErr.StatusCode != http.StatusBadRequest {
+		t.Errorf("unexpected error %+v", constraintErr)
+	}
+	if got := constraintErr.Error(); got != "workflowai: no provider serves the request (provider mistral_ai without model fallbacks): mistral_ai does not support gpt-4o-mini-latest" {
+		t.Errorf("unexpected message %q", got)
+	}
+}
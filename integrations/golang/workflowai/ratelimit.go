@@ -0,0 +1,298 @@
+package workflowai
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openai/openai-go/option"
+)
+
+// RateLimits are the rate limits of the API key, as reported by the
+// x-ratelimit-* headers of the last response. The fields are 0 when the
+// headers are missing, which is always the case with WorkflowAI: it only
+// sends Retry-After on the errors of the providers.
+type RateLimits struct {
+	LimitRequests     int
+	RemainingRequests int
+	// ResetRequests is when the requests limit is fully replenished
+	ResetRequests   time.Time
+	LimitTokens     int
+	RemainingTokens int
+	ResetTokens     time.Time
+	// RetryAfter is the end of the pause requested by the last 429 response,
+	// zero after a response that was not rate limited
+	RetryAfter time.Time
+	UpdatedAt  time.Time
+}
+
+// ParseRateLimits reads the rate limits of response headers received at now.
+// ok is false when the headers have no rate limit information.
+func ParseRateLimits(h http.Header, now time.Time) (limits RateLimits, ok bool) {
+	readInt := func(name string) int {
+		v, err := strconv.Atoi(strings.TrimSpace(h.Get(name)))
+		if err == nil {
+			ok = true
+		}
+		return v
+	}
+	readReset := func(name string) time.Time {
+		d, valid := parseResetDuration(h.Get(name))
+		if !valid {
+			return time.Time{}
+		}
+		ok = true
+		return now.Add(d)
+	}
+	limits = RateLimits{
+		LimitRequests:     readInt("X-Ratelimit-Limit-Requests"),
+		RemainingRequests: readInt("X-Ratelimit-Remaining-Requests"),
+		ResetRequests:     readReset("X-Ratelimit-Reset-Requests"),
+		LimitTokens:       readInt("X-Ratelimit-Limit-Tokens"),
+		RemainingTokens:   readInt("X-Ratelimit-Remaining-Tokens"),
+		ResetTokens:       readReset("X-Ratelimit-Reset-Tokens"),
+		UpdatedAt:         now,
+	}
+	if d, valid := retryAfter(h, now); valid {
+		limits.RetryAfter = now.Add(d)
+		ok = true
+	}
+	return limits, ok
+}
+
+// parseResetDuration parses the reset headers, durations such as "6m0s" or
+// "20ms", or numbers of seconds.
+func parseResetDuration(v string) (time.Duration, bool) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0, false
+	}
+	if d, err := time.ParseDuration(v); err == nil {
+		return max(d, 0), true
+	}
+	if s, err := strconv.ParseFloat(v, 64); err == nil {
+		return max(time.Duration(s*float64(time.Second)), 0), true
+	}
+	return 0, false
+}
+
+// retryAfter reads the Retry-After-Ms and Retry-After headers, in seconds or
+// as an HTTP date.
+func retryAfter(h http.Header, now time.Time) (time.Duration, bool) {
+	if ms, err := strconv.ParseFloat(h.Get("Retry-After-Ms"), 64); err == nil {
+		return max(time.Duration(ms*float64(time.Millisecond)), 0), true
+	}
+	v := h.Get("Retry-After")
+	if s, err := strconv.ParseFloat(v, 64); err == nil {
+		return max(time.Duration(s*float64(time.Second)), 0), true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return max(t.Sub(now), 0), true
+	}
+	return 0, false
+}
+
+// RateLimitTracker records the rate limits reported by the responses of a
+// client and, when throttling is enabled, delays the requests that would
+// exceed them instead of letting the server answer 429. [NewClient] installs
+// one as Client.RateLimits:
+//
+//	client := workflowai.NewClient()
+//	client.RateLimits.EnableThrottling(0.05)
+//	...
+//	limits, _ := client.RateLimits.Current()
+//	log.Printf("%d requests left until %s", limits.RemainingRequests, limits.ResetRequests)
+//
+// The throttling counts the requests in flight against the remaining limits,
+// since the headers only reflect the requests the server has seen. The
+// tokens of a request are estimated from the size of its body and its
+// max_tokens. It is safe for concurrent use.
+type RateLimitTracker struct {
+	mu     sync.Mutex
+	limits RateLimits
+	known  bool
+	// requests and tokens are the remaining limits minus the requests sent
+	// since the last response
+	requests, tokens int
+	throttle         bool
+	reserve          float64
+	now              func() time.Time
+}
+
+// NewRateLimitTracker returns a tracker, to install with its Middleware on
+// clients not created with [NewClient].
+func NewRateLimitTracker() *RateLimitTracker {
+	return &RateLimitTracker{now: time.Now}
+}
+
+// Current returns the last rate limits, ok is false before a response
+// reported them.
+func (t *RateLimitTracker) Current() (limits RateLimits, ok bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.limits, t.known
+}
+
+// EnableThrottling delays the requests that would leave less than reserve
+// (a fraction of the limits, e.g. 0.05) of the requests or tokens until the
+// limits reset, and all the requests during the pause of a 429 response.
+func (t *RateLimitTracker) EnableThrottling(reserve float64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.throttle, t.reserve = true, min(max(reserve, 0), 1)
+}
+
+// DisableThrottling stops delaying the requests.
+func (t *RateLimitTracker) DisableThrottling() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.throttle = false
+}
+
+// Middleware returns the client middleware recording the rate limits and
+// throttling the requests.
+func (t *RateLimitTracker) Middleware() option.Middleware {
+	return t.middleware
+}
+
+func (t *RateLimitTracker) middleware(req *http.Request, next option.MiddlewareNext) (*http.Response, error) {
+	release, err := t.wait(req)
+	if err != nil {
+		return nil, err
+	}
+	res, err := next(req)
+	if err != nil {
+		release()
+		return res, err
+	}
+	if res.StatusCode >= 400 {
+		// A failed request may not count against the limits, the headers of
+		// the response tell when it does
+		release()
+	}
+	t.observe(res)
+	return res, nil
+}
+
+// Update records the rate limits of response headers.
+func (t *RateLimitTracker) Update(h http.Header) {
+	limits, ok := ParseRateLimits(h, t.now())
+	if !ok {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.known {
+		t.limits = limits
+	} else {
+		// Responses without some of the headers keep the previous values
+		merged := t.limits
+		if limits.LimitRequests > 0 || limits.RemainingRequests > 0 || !limits.ResetRequests.IsZero() {
+			merged.LimitRequests, merged.RemainingRequests, merged.ResetRequests = limits.LimitRequests, limits.RemainingRequests, limits.ResetRequests
+		}
+		if limits.LimitTokens > 0 || limits.RemainingTokens > 0 || !limits.ResetTokens.IsZero() {
+			merged.LimitTokens, merged.RemainingTokens, merged.ResetTokens = limits.LimitTokens, limits.RemainingTokens, limits.ResetTokens
+		}
+		merged.RetryAfter, merged.UpdatedAt = limits.RetryAfter, limits.UpdatedAt
+		t.limits = merged
+	}
+	t.known = true
+	t.requests, t.tokens = t.limits.RemainingRequests, t.limits.RemainingTokens
+}
+
+func (t *RateLimitTracker) observe(res *http.Response) {
+	h := res.Header
+	if res.StatusCode != http.StatusTooManyRequests {
+		// Only the pauses of rate limited responses are tracked
+		h = h.Clone()
+		h.Del("Retry-After")
+		h.Del("Retry-After-Ms")
+	}
+	t.Update(h)
+}
+
+// wait blocks until the request fits the limits, and reserves its share.
+// release returns the share, for the requests that failed.
+func (t *RateLimitTracker) wait(req *http.Request) (release func(), err error) {
+	tokens := -1
+	for {
+		t.mu.Lock()
+		if !t.throttle || !t.known {
+			t.mu.Unlock()
+			return func() {}, nil
+		}
+		if tokens < 0 {
+			// The body is only read when throttling
+			t.mu.Unlock()
+			tokens = estimateRequestTokens(req)
+			continue
+		}
+		delay := t.delay(tokens)
+		if delay <= 0 {
+			t.requests--
+			t.tokens -= tokens
+			t.mu.Unlock()
+			return sync.OnceFunc(func() {
+				t.mu.Lock()
+				defer t.mu.Unlock()
+				t.requests++
+				t.tokens += tokens
+			}), nil
+		}
+		t.mu.Unlock()
+		timer := time.NewTimer(delay)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// delay returns how long a request of tokens must wait, with t.mu held. The
+// limits past their reset are assumed replenished.
+func (t *RateLimitTracker) delay(tokens int) time.Duration {
+	now := t.now()
+	if wait := t.limits.RetryAfter.Sub(now); wait > 0 {
+		return wait
+	}
+	var delay time.Duration
+	if t.limits.LimitRequests > 0 {
+		if !now.Before(t.limits.ResetRequests) {
+			t.requests = t.limits.LimitRequests
+		} else if float64(t.requests-1) < t.reserve*float64(t.limits.LimitRequests) {
+			delay = max(delay, t.limits.ResetRequests.Sub(now))
+		}
+	}
+	if t.limits.LimitTokens > 0 {
+		if !now.Before(t.limits.ResetTokens) {
+			t.tokens = t.limits.LimitTokens
+		} else if float64(t.tokens-tokens) < t.reserve*float64(t.limits.LimitTokens) {
+			delay = max(delay, t.limits.ResetTokens.Sub(now))
+		}
+	}
+	return delay
+}
+
+// estimateRequestTokens estimates the tokens a request counts against the
+// limits: about 4 bytes per token of its body, plus the tokens it may
+// generate.
+func estimateRequestTokens(req *http.Request) int {
+	if req.Body == nil || req.Body == http.NoBody {
+		return 0
+	}
+	body, err := readRequestBody(req)
+	if err != nil {
+		return 0
+	}
+	var payload struct {
+		MaxTokens           int `json:"max_tokens"`
+		MaxCompletionTokens int `json:"max_completion_tokens"`
+	}
+	_ = json.Unmarshal(body, &payload)
+	return len(body)/4 + max(payload.MaxTokens, payload.MaxCompletionTokens)
+}
@@ -0,0 +1,125 @@
+package workflowai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+func TestParseRateLimits(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	h := http.Header{}
+	h.Set("X-Ratelimit-Limit-Requests", "500")
+	h.Set("X-Ratelimit-Remaining-Requests", "499")
+	h.Set("X-Ratelimit-Reset-Requests", "120ms")
+	h.Set("X-Ratelimit-Limit-Tokens", "30000")
+	h.Set("X-Ratelimit-Remaining-Tokens", "29000")
+	h.Set("X-Ratelimit-Reset-Tokens", "2")
+	h.Set("Retry-After-Ms", "1500")
+	limits, ok := ParseRateLimits(h, now)
+	want := RateLimits{
+		LimitRequests: 500, RemainingRequests: 499, ResetRequests: now.Add(120 * time.Millisecond),
+		LimitTokens: 30000, RemainingTokens: 29000, ResetTokens: now.Add(2 * time.Second),
+		RetryAfter: now.Add(1500 * time.Millisecond), UpdatedAt: now,
+	}
+	if !ok || limits != want {
+		t.Errorf("got %+v", limits)
+	}
+	if _, ok := ParseRateLimits(http.Header{"Content-Type": {"application/json"}}, now); ok {
+		t.Error("expected no rate limits")
+	}
+}
+
+func TestRateLimitTracker_Throttling(t *testing.T) {
+	// 3 requests per window of 200ms
+	const limit, window = 3, 200 * time.Millisecond
+	var mu sync.Mutex
+	var remaining int
+	var resetAt time.Time
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		now := time.Now()
+		if !now.Before(resetAt) {
+			remaining, resetAt = limit, now.Add(window)
+		}
+		w.Header().Set("X-Ratelimit-Limit-Requests", fmt.Sprint(limit))
+		w.Header().Set("X-Ratelimit-Reset-Requests", resetAt.Sub(now).String())
+		if remaining == 0 {
+			w.Header().Set("X-Ratelimit-Remaining-Requests", "0")
+			w.Header().Set("Retry-After-Ms", fmt.Sprint(resetAt.Sub(now).Milliseconds()+1))
+			writeJSON(w, http.StatusTooManyRequests, map[string]any{"error": map[string]any{"message": "rate limited"}})
+			return
+		}
+		remaining--
+		w.Header().Set("X-Ratelimit-Remaining-Requests", fmt.Sprint(remaining))
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, testCompletion)
+	}))
+	defer server.Close()
+
+	client := NewClient(option.WithBaseURL(server.URL+"/v1"), option.WithAPIKey("test"), option.WithMaxRetries(0))
+	if _, ok := client.RateLimits.Current(); ok {
+		t.Error("expected no rate limits before the first response")
+	}
+	params := openai.ChatCompletionNewParams{
+		Model:    "my-agent/gpt-4o",
+		Messages: []openai.ChatCompletionMessageParamUnion{openai.UserMessage("Hi")},
+	}
+	ctx := context.Background()
+	// Without throttling, the request past the limit is rejected
+	var err error
+	for i := 0; i <= limit && err == nil; i++ {
+		_, err = client.Chat.Completions.New(ctx, params)
+	}
+	if err == nil {
+		t.Fatal("expected a rate limit error")
+	}
+	limits, ok := client.RateLimits.Current()
+	if !ok || limits.LimitRequests != limit || limits.RemainingRequests != 0 || limits.RetryAfter.IsZero() {
+		t.Fatalf("unexpected limits %+v", limits)
+	}
+
+	// With throttling, the requests wait for the windows instead
+	client.RateLimits.EnableThrottling(0)
+	start := time.Now()
+	for i := 0; i < 2*limit; i++ {
+		if _, err := client.Chat.Completions.New(ctx, params); err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+	}
+	if d := time.Since(start); d < window {
+		t.Errorf("expected the requests to wait for the reset, took %s", d)
+	}
+}
+
+func TestRateLimitTracker_ReleasesFailedRequests(t *testing.T) {
+	tracker := NewRateLimitTracker()
+	tracker.Update(http.Header{
+		"X-Ratelimit-Limit-Requests":     {"10"},
+		"X-Ratelimit-Remaining-Requests": {"2"},
+		"X-Ratelimit-Reset-Requests":     {"1h"},
+	})
+	tracker.EnableThrottling(0)
+	failing := func(*http.Request) (*http.Response, error) { return nil, errors.New("connection refused") }
+	for i := 0; i < 3; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		req := httptest.NewRequest(http.MethodGet, "/v1/models", nil).WithContext(ctx)
+		_, err := tracker.middleware(req, failing)
+		cancel()
+		if err == nil || errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("request %d: expected the failure of the request, got %v", i, err)
+		}
+	}
+	if tracker.requests != 2 {
+		t.Errorf("expected the failed requests to be released, %d left", tracker.requests)
+	}
+}
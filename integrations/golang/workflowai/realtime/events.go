@@ -0,0 +1,117 @@
+package realtime
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+)
+
+// Server event types
+const (
+	EventError                   = "error"
+	EventSessionCreated          = "session.created"
+	EventSessionUpdated          = "session.updated"
+	EventSpeechStarted           = "input_audio_buffer.speech_started"
+	EventSpeechStopped           = "input_audio_buffer.speech_stopped"
+	EventInputTranscriptDelta    = "conversation.item.input_audio_transcription.delta"
+	EventInputTranscriptDone     = "conversation.item.input_audio_transcription.completed"
+	EventInputTranscriptFailed   = "conversation.item.input_audio_transcription.failed"
+	EventResponseCreated         = "response.created"
+	EventResponseTextDelta       = "response.text.delta"
+	EventResponseAudioDelta      = "response.audio.delta"
+	EventResponseTranscriptDelta = "response.audio_transcript.delta"
+	EventResponseDone            = "response.done"
+)
+
+// Audio formats
+const (
+	// AudioPCM16 is 16-bit PCM at 24kHz, mono, little-endian
+	AudioPCM16 = "pcm16"
+	AudioG711U = "g711_ulaw"
+	AudioG711A = "g711_alaw"
+)
+
+// SessionConfig is the configuration of a session. Zero fields are left
+// unchanged.
+type SessionConfig struct {
+	// Modalities are "text" and "audio"
+	Modalities        []string       `json:"modalities,omitempty"`
+	Instructions      string         `json:"instructions,omitempty"`
+	Voice             string         `json:"voice,omitempty"`
+	InputAudioFormat  string         `json:"input_audio_format,omitempty"`
+	OutputAudioFormat string         `json:"output_audio_format,omitempty"`
+	TurnDetection     *TurnDetection `json:"turn_detection,omitempty"`
+	// NoTurnDetection disables the turn detection, which is enabled by
+	// default, so that the client commits the audio and creates the
+	// responses, e.g. for push-to-talk
+	NoTurnDetection bool `json:"-"`
+	// InputTranscription enables the transcription of the user audio, e.g.
+	// with "whisper-1"
+	InputTranscription *InputTranscription `json:"input_audio_transcription,omitempty"`
+	Temperature        *float64            `json:"temperature,omitempty"`
+}
+
+// MarshalJSON sends "turn_detection": null when NoTurnDetection is set.
+func (c SessionConfig) MarshalJSON() ([]byte, error) {
+	type config SessionConfig
+	if !c.NoTurnDetection {
+		return json.Marshal(config(c))
+	}
+	return json.Marshal(struct {
+		config
+		TurnDetection *TurnDetection `json:"turn_detection"`
+	}{config: config(c)})
+}
+
+// TurnDetection configures the detection of the end of the user speech by
+// the server.
+type TurnDetection struct {
+	// Type is "server_vad"
+	Type              string   `json:"type"`
+	Threshold         *float64 `json:"threshold,omitempty"`
+	SilenceDurationMS int      `json:"silence_duration_ms,omitempty"`
+	PrefixPaddingMS   int      `json:"prefix_padding_ms,omitempty"`
+}
+
+type InputTranscription struct {
+	Model string `json:"model"`
+	// Language is the ISO-639-1 code of the audio, e.g. "fr", detected when
+	// empty
+	Language string `json:"language,omitempty"`
+	// Prompt guides the transcription, e.g. with the spelling of names
+	Prompt string `json:"prompt,omitempty"`
+}
+
+// ServerError is the error of an "error" event.
+type ServerError struct {
+	Type    string `json:"type"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Param   string `json:"param"`
+}
+
+func (e *ServerError) Error() string {
+	return fmt.Sprintf("realtime: %s: %s", e.Code, e.Message)
+}
+
+// Event is a server event. The common fields are decoded, the others can be
+// decoded from Raw.
+type Event struct {
+	Type       string `json:"type"`
+	EventID    string `json:"event_id"`
+	ResponseID string `json:"response_id,omitempty"`
+	ItemID     string `json:"item_id,omitempty"`
+	// Delta is the text of text and transcript deltas, and the base64
+	// encoded audio of audio deltas
+	Delta string `json:"delta,omitempty"`
+	// Transcript is set for completed input transcriptions
+	Transcript string       `json:"transcript,omitempty"`
+	Error      *ServerError `json:"error,omitempty"`
+	// Raw is the JSON encoded event
+	Raw json.RawMessage `json:"-"`
+}
+
+// Audio returns the decoded audio of a "response.audio.delta" event.
+func (e Event) Audio() ([]byte, error) {
+	return base64.StdEncoding.DecodeString(e.Delta)
+}
@@ -0,0 +1,215 @@
+// Package realtime is a client for realtime sessions over WebSocket, using
+// the event protocol of the OpenAI Realtime API: text and audio are sent
+// incrementally and the response is streamed back as events, in both
+// directions at the same time.
+//
+// WorkflowAI has no realtime endpoint, so the sessions connect to the OpenAI
+// API by default, with OPENAI_API_KEY, and are not recorded as WorkflowAI
+// runs.
+//
+//	session, err := realtime.Dial(ctx, "gpt-4o-realtime-preview")
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	defer session.Close()
+//	if err := session.SendText(ctx, "Hello"); err != nil {
+//		log.Fatal(err)
+//	}
+//	for {
+//		event, err := session.Read(ctx)
+//		if err != nil {
+//			log.Fatal(err)
+//		}
+//		switch event.Type {
+//		case realtime.EventResponseTextDelta:
+//			fmt.Print(event.Delta)
+//		case realtime.EventResponseDone:
+//			return
+//		}
+//	}
+package realtime
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/coder/websocket"
+)
+
+// readLimit bounds the size of a server event. Audio deltas are larger than
+// the default limit of the websocket package.
+const readLimit = 16 << 20
+
+// DefaultBaseURL is the base URL of the OpenAI API, which serves the realtime
+// sessions.
+const DefaultBaseURL = "https://api.openai.com/v1"
+
+type dialConfig struct {
+	baseURL    string
+	apiKey     string
+	header     http.Header
+	httpClient *http.Client
+}
+
+type DialOption func(*dialConfig)
+
+// WithBaseURL sets the base URL of the API, OPENAI_REALTIME_BASE_URL or
+// [DefaultBaseURL] by default. The scheme is replaced by the WebSocket scheme.
+func WithBaseURL(baseURL string) DialOption {
+	return func(c *dialConfig) {
+		c.baseURL = baseURL
+	}
+}
+
+// WithAPIKey sets the API key.
+func WithAPIKey(apiKey string) DialOption {
+	return func(c *dialConfig) {
+		c.apiKey = apiKey
+	}
+}
+
+// WithHeader adds a header to the handshake request.
+func WithHeader(key string, value string) DialOption {
+	return func(c *dialConfig) {
+		c.header.Add(key, value)
+	}
+}
+
+// WithHTTPClient sets the client of the handshake request.
+func WithHTTPClient(client *http.Client) DialOption {
+	return func(c *dialConfig) {
+		c.httpClient = client
+	}
+}
+
+// Session is a realtime session. Events can be sent while others are read,
+// but Read must not be called concurrently.
+type Session struct {
+	conn *websocket.Conn
+}
+
+// Dial opens a session with model. The base URL and API key are read from
+// OPENAI_REALTIME_BASE_URL and OPENAI_API_KEY. OPENAI_BASE_URL is ignored: the
+// workflowai clients read it as the WorkflowAI base URL, which must not
+// receive the OpenAI API key.
+func Dial(ctx context.Context, model string, opts ...DialOption) (*Session, error) {
+	return dial(ctx, url.Values{"model": {model}}, opts)
+}
+
+func dial(ctx context.Context, query url.Values, opts []DialOption) (*Session, error) {
+	cfg := &dialConfig{baseURL: DefaultBaseURL, apiKey: os.Getenv("OPENAI_API_KEY"), header: http.Header{}}
+	if v, ok := os.LookupEnv("OPENAI_REALTIME_BASE_URL"); ok {
+		cfg.baseURL = v
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	u, err := url.Parse(strings.TrimRight(cfg.baseURL, "/") + "/realtime")
+	if err != nil {
+		return nil, fmt.Errorf("realtime: invalid base URL: %w", err)
+	}
+	switch u.Scheme {
+	case "https":
+		u.Scheme = "wss"
+	case "http":
+		u.Scheme = "ws"
+	}
+	u.RawQuery = query.Encode()
+
+	header := cfg.header.Clone()
+	if cfg.apiKey != "" {
+		header.Set("Authorization", "Bearer "+cfg.apiKey)
+	}
+	header.Set("OpenAI-Beta", "realtime=v1")
+	conn, res, err := websocket.Dial(ctx, u.String(), &websocket.DialOptions{HTTPClient: cfg.httpClient, HTTPHeader: header})
+	if err != nil {
+		if res != nil {
+			return nil, fmt.Errorf("realtime: connecting: %s: %w", res.Status, err)
+		}
+		return nil, fmt.Errorf("realtime: connecting: %w", err)
+	}
+	conn.SetReadLimit(readLimit)
+	return &Session{conn: conn}, nil
+}
+
+// Close closes the session.
+func (s *Session) Close() error {
+	return s.conn.Close(websocket.StatusNormalClosure, "")
+}
+
+// Send sends a client event, e.g. a map or a struct with a "type" field.
+func (s *Session) Send(ctx context.Context, event any) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	if err := s.conn.Write(ctx, websocket.MessageText, data); err != nil {
+		return fmt.Errorf("realtime: sending event: %w", err)
+	}
+	return nil
+}
+
+// Read returns the next server event. Errors reported by the server are
+// returned as events of type "error", not as Go errors.
+func (s *Session) Read(ctx context.Context) (Event, error) {
+	_, data, err := s.conn.Read(ctx)
+	if err != nil {
+		return Event{}, fmt.Errorf("realtime: reading event: %w", err)
+	}
+	var event Event
+	if err := json.Unmarshal(data, &event); err != nil {
+		return Event{}, fmt.Errorf("realtime: decoding event: %w", err)
+	}
+	event.Raw = data
+	return event, nil
+}
+
+// UpdateSession changes the configuration of the session. Unset fields are
+// left unchanged.
+func (s *Session) UpdateSession(ctx context.Context, config SessionConfig) error {
+	return s.Send(ctx, map[string]any{"type": "session.update", "session": config})
+}
+
+// AppendAudio appends audio, in the input format of the session, to the input
+// buffer. With server turn detection, the server commits the buffer and
+// creates a response when the user stops speaking.
+func (s *Session) AppendAudio(ctx context.Context, audio []byte) error {
+	return s.Send(ctx, map[string]any{"type": "input_audio_buffer.append", "audio": base64.StdEncoding.EncodeToString(audio)})
+}
+
+// CommitAudio commits the input buffer as a user message, when turn
+// detection is disabled.
+func (s *Session) CommitAudio(ctx context.Context) error {
+	return s.Send(ctx, map[string]any{"type": "input_audio_buffer.commit"})
+}
+
+// SendText adds a user message to the conversation and asks for a response.
+func (s *Session) SendText(ctx context.Context, text string) error {
+	item := map[string]any{
+		"type":    "message",
+		"role":    "user",
+		"content": []map[string]any{{"type": "input_text", "text": text}},
+	}
+	if err := s.Send(ctx, map[string]any{"type": "conversation.item.create", "item": item}); err != nil {
+		return err
+	}
+	return s.CreateResponse(ctx)
+}
+
+// CreateResponse asks the model to respond to the conversation.
+func (s *Session) CreateResponse(ctx context.Context) error {
+	return s.Send(ctx, map[string]any{"type": "response.create"})
+}
+
+// CancelResponse interrupts the response in progress, e.g. when the user
+// starts speaking over it.
+func (s *Session) CancelResponse(ctx context.Context) error {
+	return s.Send(ctx, map[string]any{"type": "response.cancel"})
+}
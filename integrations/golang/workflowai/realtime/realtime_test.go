@@ -0,0 +1,149 @@
+package realtime
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+)
+
+// newTestServer answers each response.create with a text delta, an audio
+// delta and response.done, and records the client events.
+func newTestServer(t *testing.T, received chan<- map[string]any) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/realtime" || r.URL.Query().Get("model") != "my-agent/gpt-4o-realtime" ||
+			r.Header.Get("Authorization") != "Bearer key" || r.Header.Get("OpenAI-Beta") != "realtime=v1" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		conn, err := websocket.Accept(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.CloseNow()
+		ctx := r.Context()
+		send := func(event map[string]any) {
+			data, _ := json.Marshal(event)
+			_ = conn.Write(ctx, websocket.MessageText, data)
+		}
+		send(map[string]any{"type": EventSessionCreated, "event_id": "evt_0"})
+		for {
+			_, data, err := conn.Read(ctx)
+			if err != nil {
+				return
+			}
+			var event map[string]any
+			_ = json.Unmarshal(data, &event)
+			received <- event
+			if event["type"] == "response.create" {
+				send(map[string]any{"type": EventResponseTextDelta, "event_id": "evt_1", "response_id": "resp_1", "delta": "Hello"})
+				send(map[string]any{"type": EventResponseAudioDelta, "event_id": "evt_2", "response_id": "resp_1", "delta": base64.StdEncoding.EncodeToString([]byte{1, 2})})
+				send(map[string]any{"type": EventResponseDone, "event_id": "evt_3", "response_id": "resp_1"})
+			}
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestSession(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	received := make(chan map[string]any, 10)
+	server := newTestServer(t, received)
+
+	// OPENAI_BASE_URL is the WorkflowAI base URL, it must not receive the key
+	t.Setenv("OPENAI_BASE_URL", "http://127.0.0.1:1/v1")
+	t.Setenv("OPENAI_REALTIME_BASE_URL", server.URL+"/v1")
+	session, err := Dial(ctx, "my-agent/gpt-4o-realtime", WithAPIKey("key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Close()
+
+	if event, err := session.Read(ctx); err != nil || event.Type != EventSessionCreated {
+		t.Fatalf("expected session.created, got %+v: %v", event, err)
+	}
+	if err := session.UpdateSession(ctx, SessionConfig{Modalities: []string{"text", "audio"}, TurnDetection: &TurnDetection{Type: "server_vad"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := session.AppendAudio(ctx, []byte{0, 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := session.SendText(ctx, "Hi"); err != nil {
+		t.Fatal(err)
+	}
+
+	var text string
+	var audio []byte
+	for {
+		event, err := session.Read(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if event.Type == EventResponseTextDelta {
+			text += event.Delta
+		}
+		if event.Type == EventResponseAudioDelta {
+			chunk, err := event.Audio()
+			if err != nil {
+				t.Fatal(err)
+			}
+			audio = append(audio, chunk...)
+		}
+		if event.Type == EventResponseDone {
+			break
+		}
+	}
+	if text != "Hello" || len(audio) != 2 {
+		t.Errorf("unexpected response %q %v", text, audio)
+	}
+
+	var types []string
+	for range 4 {
+		event := <-received
+		types = append(types, event["type"].(string))
+		if event["type"] == "input_audio_buffer.append" && event["audio"] != "AAE=" {
+			t.Errorf("unexpected audio %v", event["audio"])
+		}
+	}
+	expected := []string{"session.update", "input_audio_buffer.append", "conversation.item.create", "response.create"}
+	for i := range expected {
+		if types[i] != expected[i] {
+			t.Errorf("expected events %v, got %v", expected, types)
+			break
+		}
+	}
+}
+
+func TestDial_Unauthorized(t *testing.T) {
+	server := newTestServer(t, make(chan map[string]any, 1))
+	if _, err := Dial(context.Background(), "my-agent/gpt-4o-realtime", WithBaseURL(server.URL+"/v1"), WithAPIKey("other")); err == nil {
+		t.Error("expected the handshake to fail")
+	}
+}
+
+func TestSessionConfig_MarshalJSON(t *testing.T) {
+	for _, tt := range []struct {
+		config SessionConfig
+		want   string
+	}{
+		{SessionConfig{Voice: "alloy"}, `{"voice":"alloy"}`},
+		{SessionConfig{TurnDetection: &TurnDetection{Type: "server_vad"}}, `{"turn_detection":{"type":"server_vad"}}`},
+		{SessionConfig{Voice: "alloy", NoTurnDetection: true}, `{"voice":"alloy","turn_detection":null}`},
+	} {
+		data, err := json.Marshal(tt.config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != tt.want {
+			t.Errorf("got %s, want %s", data, tt.want)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package realtime
+
+import (
+	"context"
+	"net/url"
+)
+
+// TranscriptionConfig is the configuration of a transcription session.
+type TranscriptionConfig struct {
+	// InputAudioFormat is [AudioPCM16] by default
+	InputAudioFormat string `json:"input_audio_format,omitempty"`
+	// Transcription sets the model, e.g. "gpt-4o-transcribe", the language and
+	// the prompt of the transcription
+	Transcription InputTranscription `json:"input_audio_transcription"`
+	// TurnDetection splits the audio into utterances when the speaker pauses.
+	// Without it, the turn detection is disabled and the audio is transcribed
+	// at each [Transcriber.Commit].
+	TurnDetection *TurnDetection `json:"turn_detection"`
+}
+
+// Transcript is the transcript of an utterance.
+type Transcript struct {
+	// ItemID identifies the utterance
+	ItemID string
+	// Text is the transcript so far of interim transcripts, and the full
+	// transcript of final ones
+	Text string
+	// Final is set once the utterance is fully transcribed. The text of a
+	// final transcript may differ from the last interim one.
+	Final bool
+}
+
+// Transcriber is a streaming transcription session: audio is appended as it
+// is captured while the transcripts are read from another goroutine. Read
+// must not be called concurrently.
+//
+//	t, err := realtime.DialTranscription(ctx, realtime.TranscriptionConfig{
+//		Transcription: realtime.InputTranscription{Model: "gpt-4o-transcribe"},
+//		TurnDetection: &realtime.TurnDetection{Type: "server_vad"},
+//	})
+//	go func() {
+//		for chunk := range microphone {
+//			t.AppendAudio(ctx, chunk)
+//		}
+//	}()
+//	for {
+//		transcript, err := t.Read(ctx)
+//		...
+//	}
+type Transcriber struct {
+	session *Session
+	// texts are the interim transcripts by item
+	texts map[string]string
+}
+
+// DialTranscription opens a transcription session. The options are the
+// options of [Dial].
+func DialTranscription(ctx context.Context, config TranscriptionConfig, opts ...DialOption) (*Transcriber, error) {
+	session, err := dial(ctx, url.Values{"intent": {"transcription"}}, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := session.Send(ctx, map[string]any{"type": "transcription_session.update", "session": config}); err != nil {
+		session.Close()
+		return nil, err
+	}
+	return &Transcriber{session: session, texts: map[string]string{}}, nil
+}
+
+// Session returns the underlying session, e.g. to read the speech events.
+func (t *Transcriber) Session() *Session {
+	return t.session
+}
+
+// AppendAudio appends audio captured in the input format of the session.
+func (t *Transcriber) AppendAudio(ctx context.Context, audio []byte) error {
+	return t.session.AppendAudio(ctx, audio)
+}
+
+// Commit ends the current utterance, when turn detection is disabled, e.g.
+// when a push-to-talk button is released.
+func (t *Transcriber) Commit(ctx context.Context) error {
+	return t.session.CommitAudio(ctx)
+}
+
+// Read returns the next interim or final transcript. Errors reported by the
+// server, including failed transcriptions, are returned as [*ServerError].
+func (t *Transcriber) Read(ctx context.Context) (Transcript, error) {
+	for {
+		event, err := t.session.Read(ctx)
+		if err != nil {
+			return Transcript{}, err
+		}
+		switch event.Type {
+		case EventInputTranscriptDelta:
+			t.texts[event.ItemID] += event.Delta
+			return Transcript{ItemID: event.ItemID, Text: t.texts[event.ItemID]}, nil
+		case EventInputTranscriptDone:
+			delete(t.texts, event.ItemID)
+			return Transcript{ItemID: event.ItemID, Text: event.Transcript, Final: true}, nil
+		case EventInputTranscriptFailed, EventError:
+			delete(t.texts, event.ItemID)
+			if event.Error == nil {
+				return Transcript{}, &ServerError{Type: event.Type, Message: "the transcription failed"}
+			}
+			return Transcript{}, event.Error
+		}
+	}
+}
+
+// Close closes the session.
+func (t *Transcriber) Close() error {
+	return t.session.Close()
+}
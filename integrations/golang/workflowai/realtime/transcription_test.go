@@ -0,0 +1,91 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+)
+
+func TestTranscriber(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var config map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("intent") != "transcription" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		conn, err := websocket.Accept(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.CloseNow()
+		send := func(event map[string]any) {
+			data, _ := json.Marshal(event)
+			_ = conn.Write(r.Context(), websocket.MessageText, data)
+		}
+		for {
+			_, data, err := conn.Read(r.Context())
+			if err != nil {
+				return
+			}
+			var event map[string]any
+			_ = json.Unmarshal(data, &event)
+			switch event["type"] {
+			case "transcription_session.update":
+				config = event["session"].(map[string]any)
+			case "input_audio_buffer.commit":
+				send(map[string]any{"type": EventInputTranscriptDelta, "item_id": "item_1", "delta": "Hello"})
+				send(map[string]any{"type": EventInputTranscriptDelta, "item_id": "item_1", "delta": " wor"})
+				send(map[string]any{"type": EventInputTranscriptDone, "item_id": "item_1", "transcript": "Hello world"})
+				send(map[string]any{"type": EventInputTranscriptFailed, "item_id": "item_2", "error": map[string]any{"code": "audio_unintelligible", "message": "no speech"}})
+			}
+		}
+	}))
+	defer server.Close()
+
+	transcriber, err := DialTranscription(ctx, TranscriptionConfig{
+		Transcription: InputTranscription{Model: "gpt-4o-transcribe", Language: "en"},
+	}, WithBaseURL(server.URL+"/v1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer transcriber.Close()
+	if err := transcriber.AppendAudio(ctx, []byte{0, 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := transcriber.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Transcript{
+		{ItemID: "item_1", Text: "Hello"},
+		{ItemID: "item_1", Text: "Hello wor"},
+		{ItemID: "item_1", Text: "Hello world", Final: true},
+	}
+	for _, w := range want {
+		got, err := transcriber.Read(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != w {
+			t.Errorf("got %+v, want %+v", got, w)
+		}
+	}
+	var serverErr *ServerError
+	if _, err := transcriber.Read(ctx); !errors.As(err, &serverErr) || serverErr.Code != "audio_unintelligible" {
+		t.Errorf("expected a failed transcription, got %v", err)
+	}
+	if model := config["input_audio_transcription"].(map[string]any)["model"]; model != "gpt-4o-transcribe" {
+		t.Errorf("unexpected config %v", config)
+	}
+	if turnDetection, ok := config["turn_detection"]; !ok || turnDetection != nil {
+		t.Errorf("expected the turn detection to be disabled, got %v", config)
+	}
+}
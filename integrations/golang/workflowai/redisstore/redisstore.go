@@ -0,0 +1,223 @@
+// Package redisstore provides a Redis backed workflowai.ConversationStore and
+// workflowai.ConversationStateStore so that conversation history and the
+// state of the conversation memories can be shared between replicas.
+package redisstore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/openai/openai-go"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/workflowai/workflowai/go/examples/workflowai"
+)
+
+var (
+	// ErrConflict is returned when a write could not be applied after
+	// concurrent modifications of the same conversation exhausted the retries.
+	ErrConflict = errors.New("redisstore: conversation was modified concurrently")
+	// ErrTooLarge is returned when the appended messages alone exceed the
+	// configured byte cap.
+	ErrTooLarge = errors.New("redisstore: messages exceed the conversation size cap")
+)
+
+// Store keeps each conversation in a Redis list of JSON encoded messages, and
+// the states of its memories in a Redis hash next to it.
+//
+// Writes use optimistic transactions (WATCH / MULTI) so that size caps are
+// enforced consistently when several replicas append to the same conversation.
+type Store struct {
+	client      redis.UniversalClient
+	prefix      string
+	ttl         time.Duration
+	maxMessages int
+	maxBytes    int
+	maxRetries  int
+}
+
+var (
+	_ workflowai.ConversationStore      = (*Store)(nil)
+	_ workflowai.ConversationStateStore = (*Store)(nil)
+)
+
+type Option func(*Store)
+
+// WithKeyPrefix sets the prefix of the Redis keys. Defaults to "workflowai:conversation:".
+func WithKeyPrefix(prefix string) Option {
+	return func(s *Store) {
+		s.prefix = prefix
+	}
+}
+
+// WithTTL expires conversations that have not been written to for the given
+// duration, as well as the states of their memories. By default conversations
+// never expire.
+func WithTTL(ttl time.Duration) Option {
+	return func(s *Store) {
+		s.ttl = ttl
+	}
+}
+
+// WithMaxMessages caps the number of messages kept per conversation. The
+// oldest messages are dropped first.
+func WithMaxMessages(n int) Option {
+	return func(s *Store) {
+		s.maxMessages = n
+	}
+}
+
+// WithMaxBytes caps the total size of the encoded messages kept per
+// conversation. The oldest messages are dropped first.
+func WithMaxBytes(n int) Option {
+	return func(s *Store) {
+		s.maxBytes = n
+	}
+}
+
+// WithMaxRetries sets how many times a write is retried when the conversation
+// is modified concurrently. Defaults to 5.
+func WithMaxRetries(n int) Option {
+	return func(s *Store) {
+		s.maxRetries = n
+	}
+}
+
+func New(client redis.UniversalClient, opts ...Option) *Store {
+	s := &Store{
+		client:     client,
+		prefix:     "workflowai:conversation:",
+		maxRetries: 5,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+func (s *Store) key(conversationID string) string {
+	return s.prefix + conversationID
+}
+
+func (s *Store) stateKey(conversationID string) string {
+	return s.prefix + conversationID + ":state"
+}
+
+func (s *Store) Load(ctx context.Context, conversationID string) ([]openai.ChatCompletionMessageParamUnion, error) {
+	raw, err := s.client.LRange(ctx, s.key(conversationID), 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+	messages := make([]openai.ChatCompletionMessageParamUnion, len(raw))
+	for i, r := range raw {
+		if err := json.Unmarshal([]byte(r), &messages[i]); err != nil {
+			return nil, fmt.Errorf("redisstore: decoding message %d of conversation %s: %w", i, conversationID, err)
+		}
+	}
+	return messages, nil
+}
+
+func (s *Store) Append(ctx context.Context, conversationID string, messages ...openai.ChatCompletionMessageParamUnion) error {
+	if len(messages) == 0 {
+		return nil
+	}
+
+	encoded := make([]any, len(messages))
+	newBytes := 0
+	for i, m := range messages {
+		b, err := json.Marshal(m)
+		if err != nil {
+			return fmt.Errorf("redisstore: encoding message %d: %w", i, err)
+		}
+		encoded[i] = b
+		newBytes += len(b)
+	}
+	if s.maxBytes > 0 && newBytes > s.maxBytes {
+		return ErrTooLarge
+	}
+
+	key := s.key(conversationID)
+	return s.transaction(ctx, key, func(tx *redis.Tx) error {
+		drop := 0
+		if s.maxBytes > 0 {
+			existing, err := tx.LRange(ctx, key, 0, -1).Result()
+			if err != nil {
+				return err
+			}
+			drop = messagesToDrop(existing, newBytes, s.maxBytes)
+		}
+
+		_, err := tx.TxPipelined(ctx, func(p redis.Pipeliner) error {
+			p.RPush(ctx, key, encoded...)
+			if drop > 0 {
+				p.LTrim(ctx, key, int64(drop), -1)
+			}
+			if s.maxMessages > 0 {
+				p.LTrim(ctx, key, int64(-s.maxMessages), -1)
+			}
+			if s.ttl > 0 {
+				p.Expire(ctx, key, s.ttl)
+			}
+			return nil
+		})
+		return err
+	})
+}
+
+func (s *Store) Trim(ctx context.Context, conversationID string, maxMessages int) error {
+	key := s.key(conversationID)
+	if maxMessages <= 0 {
+		return s.client.Del(ctx, key).Err()
+	}
+	return s.client.LTrim(ctx, key, int64(-maxMessages), -1).Err()
+}
+
+func (s *Store) LoadState(ctx context.Context, conversationID, key string) ([]byte, error) {
+	state, err := s.client.HGet(ctx, s.stateKey(conversationID), key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	return state, err
+}
+
+func (s *Store) SaveState(ctx context.Context, conversationID, key string, state []byte) error {
+	stateKey := s.stateKey(conversationID)
+	_, err := s.client.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		p.HSet(ctx, stateKey, key, state)
+		if s.ttl > 0 {
+			p.Expire(ctx, stateKey, s.ttl)
+		}
+		return nil
+	})
+	return err
+}
+
+// transaction runs fn in an optimistic transaction watching key, retrying
+// when the key is modified before the transaction is executed.
+func (s *Store) transaction(ctx context.Context, key string, fn func(tx *redis.Tx) error) error {
+	for i := 0; i <= s.maxRetries; i++ {
+		err := s.client.Watch(ctx, fn, key)
+		if !errors.Is(err, redis.TxFailedErr) {
+			return err
+		}
+	}
+	return ErrConflict
+}
+
+// messagesToDrop returns how many of the oldest existing messages must be removed
+// so that adding newBytes keeps the conversation under maxBytes.
+func messagesToDrop(existing []string, newBytes int, maxBytes int) int {
+	total := newBytes
+	for _, e := range existing {
+		total += len(e)
+	}
+	drop := 0
+	for drop < len(existing) && total > maxBytes {
+		total -= len(existing[drop])
+		drop++
+	}
+	return drop
+}
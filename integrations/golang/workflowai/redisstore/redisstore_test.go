@@ -0,0 +1,129 @@
+package redisstore
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/openai/openai-go"
+	"github.com/redis/go-redis/v9"
+)
+
+func newStore(t *testing.T, opts ...Option) (*Store, *miniredis.Miniredis) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return New(client, opts...), server
+}
+
+func TestStore_AppendLoad(t *testing.T) {
+	ctx := context.Background()
+	store, _ := newStore(t)
+
+	if err := store.Append(ctx, "conv", openai.UserMessage("hi"), openai.AssistantMessage("hello")); err != nil {
+		t.Fatal(err)
+	}
+	history, err := store.Load(ctx, "conv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 || history[0].OfUser == nil || history[1].OfAssistant == nil {
+		t.Fatalf("unexpected history: %v", history)
+	}
+	if history[1].OfAssistant.Content.OfString.Value != "hello" {
+		t.Fatalf("unexpected content: %v", history[1].OfAssistant.Content)
+	}
+}
+
+func TestStore_MaxMessages(t *testing.T) {
+	ctx := context.Background()
+	store, _ := newStore(t, WithMaxMessages(2))
+
+	for _, content := range []string{"1", "2", "3"} {
+		if err := store.Append(ctx, "conv", openai.UserMessage(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	history, _ := store.Load(ctx, "conv")
+	if len(history) != 2 || history[0].OfUser.Content.OfString.Value != "2" {
+		t.Fatalf("unexpected history: %v", history)
+	}
+}
+
+func TestStore_MaxBytes(t *testing.T) {
+	ctx := context.Background()
+	// A user message with a single character content encodes to 32 bytes
+	store, _ := newStore(t, WithMaxBytes(70))
+
+	for _, content := range []string{"1", "2", "3"} {
+		if err := store.Append(ctx, "conv", openai.UserMessage(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	history, _ := store.Load(ctx, "conv")
+	if len(history) != 2 || history[0].OfUser.Content.OfString.Value != "2" {
+		t.Fatalf("unexpected history: %v", history)
+	}
+
+	if err := store.Append(ctx, "conv", openai.UserMessage("this message is way too long for the cap of the store")); err != ErrTooLarge {
+		t.Fatalf("expected ErrTooLarge, got %v", err)
+	}
+}
+
+func TestStore_TTL(t *testing.T) {
+	ctx := context.Background()
+	store, server := newStore(t, WithTTL(time.Minute))
+
+	if err := store.Append(ctx, "conv", openai.UserMessage("hi")); err != nil {
+		t.Fatal(err)
+	}
+	server.FastForward(2 * time.Minute)
+
+	history, _ := store.Load(ctx, "conv")
+	if len(history) != 0 {
+		t.Fatalf("expected conversation to expire, got %d messages", len(history))
+	}
+}
+
+func TestStore_Trim(t *testing.T) {
+	ctx := context.Background()
+	store, _ := newStore(t)
+
+	if err := store.Append(ctx, "conv", openai.UserMessage("1"), openai.UserMessage("2"), openai.UserMessage("3")); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Trim(ctx, "conv", 1); err != nil {
+		t.Fatal(err)
+	}
+	history, _ := store.Load(ctx, "conv")
+	if len(history) != 1 || history[0].OfUser.Content.OfString.Value != "3" {
+		t.Fatalf("unexpected history: %v", history)
+	}
+}
+
+func TestStore_State(t *testing.T) {
+	ctx := context.Background()
+	store, server := newStore(t, WithTTL(time.Minute))
+
+	if state, err := store.LoadState(ctx, "conv", "summary"); err != nil || state != nil {
+		t.Fatalf("expected no state, got %q, %v", state, err)
+	}
+	if err := store.SaveState(ctx, "conv", "summary", []byte(`{"summary":"hi"}`)); err != nil {
+		t.Fatal(err)
+	}
+	// Another replica reads the state
+	replica := New(redis.NewClient(&redis.Options{Addr: server.Addr()}))
+	if state, _ := replica.LoadState(ctx, "conv", "summary"); string(state) != `{"summary":"hi"}` {
+		t.Errorf("unexpected state %q", state)
+	}
+	if state, _ := replica.LoadState(ctx, "conv", "entities"); state != nil {
+		t.Errorf("expected the states to be stored by key, got %q", state)
+	}
+
+	server.FastForward(2 * time.Minute)
+	if state, _ := store.LoadState(ctx, "conv", "summary"); state != nil {
+		t.Errorf("expected the state to expire, got %q", state)
+	}
+}
@@ -0,0 +1,261 @@
+package workflowai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openai/openai-go/option"
+)
+
+// Region is a regional endpoint of the API.
+type Region struct {
+	Name string
+	// BaseURL is the base URL of the API in the region, e.g.
+	// "https://workflowai.eu.example.com/v1"
+	BaseURL string
+}
+
+// RegionStatus is the state of a region of a [RegionSelector].
+type RegionStatus struct {
+	Region
+	Healthy bool
+	// Latency is the moving average of the latency of the health checks
+	Latency   time.Duration
+	LastCheck time.Time
+	LastError error
+}
+
+type regionState struct {
+	RegionStatus
+	base *url.URL
+}
+
+// RegionSelector sends the requests of a client to the healthy region with
+// the lowest latency, and fails over to the next one when a region cannot be
+// reached. The hosted platform has a single endpoint, so the regions are
+// those of a self-hosted deployment:
+//
+//	regions, err := workflowai.NewRegionSelector([]workflowai.Region{
+//		{Name: "eu", BaseURL: "https://workflowai.eu.example.com/v1"},
+//		{Name: "us", BaseURL: "https://workflowai.us.example.com/v1"},
+//	})
+//	go regions.Run(ctx)
+//	client := workflowai.NewClient(regions.ClientOptions()...)
+//
+// The regions are checked with HEAD /probes/health on their hosts. A request
+// failing with a network error marks its region unhealthy until its next
+// successful check, and is sent again to the next region when its body can
+// be replayed. Error statuses are returned as they are: a 502, 503 or 504 is
+// an error of a model provider relayed by the API, not of the region. Until
+// the first checks, the regions are tried in order. It is safe for
+// concurrent use.
+type RegionSelector struct {
+	interval   time.Duration
+	httpClient *http.Client
+
+	mu      sync.Mutex
+	regions []*regionState
+	checked bool
+}
+
+type RegionOption func(*RegionSelector)
+
+// WithRegionCheckInterval sets the interval of the health checks of Run, 30
+// seconds by default.
+func WithRegionCheckInterval(d time.Duration) RegionOption {
+	return func(s *RegionSelector) {
+		s.interval = d
+	}
+}
+
+// WithRegionHTTPClient sets the client of the health checks, with a 5 seconds
+// timeout by default.
+func WithRegionHTTPClient(c *http.Client) RegionOption {
+	return func(s *RegionSelector) {
+		s.httpClient = c
+	}
+}
+
+// NewRegionSelector returns a selector of regions, in order of preference
+// until their latencies are known.
+func NewRegionSelector(regions []Region, opts ...RegionOption) (*RegionSelector, error) {
+	if len(regions) == 0 {
+		return nil, errors.New("workflowai: no regions")
+	}
+	s := &RegionSelector{interval: 30 * time.Second, httpClient: &http.Client{Timeout: 5 * time.Second}}
+	for _, r := range regions {
+		base, err := url.Parse(strings.TrimRight(r.BaseURL, "/") + "/")
+		if err != nil || base.Host == "" {
+			return nil, fmt.Errorf("workflowai: invalid base URL of region %s: %q", r.Name, r.BaseURL)
+		}
+		s.regions = append(s.regions, &regionState{RegionStatus: RegionStatus{Region: r, Healthy: true}, base: base})
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s, nil
+}
+
+// ClientOptions returns the options sending the requests of a client to the
+// selected region. They set the base URL of the client to the first region:
+// the requests sent to another base URL, e.g. set by a later
+// option.WithBaseURL, are not routed.
+func (s *RegionSelector) ClientOptions() []option.RequestOption {
+	return []option.RequestOption{
+		withBaseURL(s.regions[0].BaseURL),
+		option.WithMiddleware(s.middleware),
+	}
+}
+
+// Run checks the regions every interval until ctx is done.
+func (s *RegionSelector) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		s.Check(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Check checks the health and the latency of the regions concurrently.
+func (s *RegionSelector) Check(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, r := range s.regions {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			latency, err := s.check(ctx, r.base)
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			r.LastCheck, r.LastError, r.Healthy = time.Now(), err, err == nil
+			if err == nil {
+				if r.Latency == 0 {
+					r.Latency = latency
+				} else {
+					// Smoothed so that a slow check does not switch regions
+					r.Latency = (3*r.Latency + latency) / 4
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	s.mu.Lock()
+	s.checked = true
+	s.mu.Unlock()
+}
+
+func (s *RegionSelector) check(ctx context.Context, base *url.URL) (time.Duration, error) {
+	health := base.ResolveReference(&url.URL{Path: "/probes/health"})
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, health.String(), nil)
+	if err != nil {
+		return 0, err
+	}
+	start := time.Now()
+	res, err := s.httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("workflowai: health check returned %d", res.StatusCode)
+	}
+	return time.Since(start), nil
+}
+
+// Status returns the state of the regions, in the order of the selector.
+func (s *RegionSelector) Status() []RegionStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	statuses := make([]RegionStatus, len(s.regions))
+	for i, r := range s.regions {
+		statuses[i] = r.RegionStatus
+	}
+	return statuses
+}
+
+// Current returns the region the next request goes to.
+func (s *RegionSelector) Current() Region {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.candidates()[0].Region
+}
+
+// candidates returns the regions in the order they are tried, with s.mu
+// held: the healthy ones by latency, then the unhealthy ones, in case they
+// recovered.
+func (s *RegionSelector) candidates() []*regionState {
+	var healthy, unhealthy []*regionState
+	for _, r := range s.regions {
+		if r.Healthy {
+			healthy = append(healthy, r)
+		} else {
+			unhealthy = append(unhealthy, r)
+		}
+	}
+	if s.checked {
+		// Stable, so that the order of preference breaks the ties
+		for i := 1; i < len(healthy); i++ {
+			for j := i; j > 0 && healthy[j].Latency < healthy[j-1].Latency; j-- {
+				healthy[j], healthy[j-1] = healthy[j-1], healthy[j]
+			}
+		}
+	}
+	return append(healthy, unhealthy...)
+}
+
+func (s *RegionSelector) middleware(req *http.Request, next option.MiddlewareNext) (*http.Response, error) {
+	s.mu.Lock()
+	candidates := s.candidates()
+	s.mu.Unlock()
+	primary := s.regions[0].base
+	relative, ok := strings.CutPrefix(req.URL.Path, primary.Path)
+	if !ok || req.URL.Host != primary.Host || req.URL.Scheme != primary.Scheme {
+		// Not a request of the regions, e.g. an absolute URL or another base
+		// URL
+		return next(req)
+	}
+
+	var res *http.Response
+	var err error
+	for i, r := range candidates {
+		attempt := req
+		if i > 0 {
+			// Failover, when the body can be sent again
+			if req.GetBody == nil && req.Body != nil && req.Body != http.NoBody {
+				break
+			}
+			attempt = req.Clone(req.Context())
+			if req.GetBody != nil {
+				body, bodyErr := req.GetBody()
+				if bodyErr != nil {
+					break
+				}
+				attempt.Body = body
+			}
+		}
+		target := *attempt.URL
+		target.Scheme, target.Host = r.base.Scheme, r.base.Host
+		target.Path = r.base.Path + relative
+		target.RawPath = ""
+		attempt.URL, attempt.Host = &target, ""
+
+		res, err = next(attempt)
+		if err == nil || req.Context().Err() != nil {
+			return res, err
+		}
+		s.mu.Lock()
+		r.Healthy, r.LastError = false, err
+		s.mu.Unlock()
+	}
+	return res, err
+}
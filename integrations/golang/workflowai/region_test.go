@@ -0,0 +1,122 @@
+package workflowai
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+type testRegion struct {
+	*httptest.Server
+	down        atomic.Bool
+	unavailable atomic.Bool
+	delay       time.Duration
+	completions atomic.Int32
+}
+
+func newTestRegion(t *testing.T, delay time.Duration) *testRegion {
+	r := &testRegion{delay: delay}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if r.down.Load() {
+			// Closes the connection, as an unreachable region
+			panic(http.ErrAbortHandler)
+		}
+		switch req.URL.Path {
+		case "/probes/health":
+			time.Sleep(r.delay)
+		case "/v1/chat/completions":
+			r.completions.Add(1)
+			if r.unavailable.Load() {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, testCompletion)
+		default:
+			http.NotFound(w, req)
+		}
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+func TestRegionSelector(t *testing.T) {
+	eu, us := newTestRegion(t, 50*time.Millisecond), newTestRegion(t, 0)
+	regions, err := NewRegionSelector([]Region{{Name: "eu", BaseURL: eu.URL + "/v1"}, {Name: "us", BaseURL: us.URL + "/v1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if regions.Current().Name != "eu" {
+		t.Errorf("expected the first region before the checks, got %s", regions.Current().Name)
+	}
+	ctx := context.Background()
+	regions.Check(ctx)
+	if regions.Current().Name != "us" {
+		t.Errorf("expected the fastest region, got %+v", regions.Status())
+	}
+
+	opts := append(regions.ClientOptions(), option.WithAPIKey("test"), option.WithMaxRetries(0))
+	client := NewClient(opts...)
+	params := openai.ChatCompletionNewParams{
+		Model:    "my-agent/gpt-4o",
+		Messages: []openai.ChatCompletionMessageParamUnion{openai.UserMessage("Hi")},
+	}
+	if _, err := client.Chat.Completions.New(ctx, params); err != nil {
+		t.Fatal(err)
+	}
+	if us.completions.Load() != 1 {
+		t.Errorf("expected the completion to go to us")
+	}
+
+	// The request fails over to eu, which is selected until us recovers
+	us.down.Store(true)
+	if _, err := client.Chat.Completions.New(ctx, params); err != nil {
+		t.Fatal(err)
+	}
+	if eu.completions.Load() != 1 || regions.Current().Name != "eu" {
+		t.Errorf("expected a failover to eu, got %+v", regions.Status())
+	}
+	if status := regions.Status(); status[1].Healthy || status[1].LastError == nil {
+		t.Errorf("expected us to be unhealthy, got %+v", status[1])
+	}
+	us.down.Store(false)
+	regions.Check(ctx)
+	if regions.Current().Name != "us" {
+		t.Errorf("expected us to recover, got %+v", regions.Status())
+	}
+
+	// An error status of the region is returned without failover
+	us.unavailable.Store(true)
+	if _, err := client.Chat.Completions.New(ctx, params); err == nil {
+		t.Error("expected the 503 to be returned")
+	}
+	if eu.completions.Load() != 1 || !regions.Status()[1].Healthy {
+		t.Errorf("expected us to stay healthy, got %+v", regions.Status())
+	}
+	us.unavailable.Store(false)
+
+	// A later base URL is not routed
+	other := newTestRegion(t, 0)
+	direct := NewClient(append(opts, option.WithBaseURL(other.URL+"/v1"))...)
+	if _, err := direct.Chat.Completions.New(ctx, params); err != nil || other.completions.Load() != 1 {
+		t.Errorf("expected the completion to go to the base URL, got %v", err)
+	}
+
+	// All the regions down
+	us.down.Store(true)
+	eu.down.Store(true)
+	if _, err := client.Chat.Completions.New(ctx, params); err == nil {
+		t.Error("expected an error")
+	}
+
+	if _, err := NewRegionSelector(nil); err == nil {
+		t.Error("expected an error without regions")
+	}
+}
@@ -0,0 +1,276 @@
+package workflowai
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/openai/openai-go/option"
+)
+
+// ReplayModeEnv is the environment variable read by [ReplayModeFromEnv].
+const ReplayModeEnv = "WORKFLOWAI_REPLAY"
+
+// ReplayMode selects whether [ReplayOptions] records or replays fixtures.
+type ReplayMode string
+
+const (
+	// ReplayModeReplay serves every request from the fixtures and never
+	// accesses the network. Requests without a fixture fail.
+	ReplayModeReplay ReplayMode = "replay"
+	// ReplayModeRecord sends requests to the server and saves the responses
+	// as fixtures, overwriting existing ones.
+	ReplayModeRecord ReplayMode = "record"
+)
+
+// ReplayModeFromEnv returns ReplayModeRecord when WORKFLOWAI_REPLAY is
+// "record" and ReplayModeReplay otherwise, so that CI never hits the network.
+func ReplayModeFromEnv() ReplayMode {
+	if os.Getenv(ReplayModeEnv) == string(ReplayModeRecord) {
+		return ReplayModeRecord
+	}
+	return ReplayModeReplay
+}
+
+// Fixture is a recorded response, stored as JSON in the fixture directory.
+type Fixture struct {
+	// Request is stored for readability in code review, it is not used when
+	// replaying.
+	Request json.RawMessage `json:"request,omitempty"`
+	Method  string          `json:"method"`
+	// Path includes the query of the request, e.g. "/v1/runs?limit=10"
+	Path        string `json:"path"`
+	StatusCode  int    `json:"status_code"`
+	ContentType string `json:"content_type"`
+	// Body is the raw response body. Streamed responses are stored as the raw
+	// server sent events.
+	Body string `json:"body"`
+}
+
+// FixtureMissError is returned in replay mode when no fixture matches a request.
+type FixtureMissError struct {
+	Hash   string
+	Method string
+	Path   string
+}
+
+func (e *FixtureMissError) Error() string {
+	return fmt.Sprintf(
+		"workflowai: no fixture for %s %s (hash %s), run with %s=record to record it",
+		e.Method, e.Path, e.Hash, ReplayModeEnv,
+	)
+}
+
+// ErrNetworkDisabled is returned when a request reaches the network in replay mode.
+var ErrNetworkDisabled = errors.New("workflowai: network access is disabled in replay mode")
+
+// Content returns the content of the first choice of a recorded chat
+// completion, also when it was streamed.
+func (f Fixture) Content() string {
+	var summary completionSummary
+	if !strings.HasPrefix(f.ContentType, "text/event-stream") {
+		summary.parseCompletion([]byte(f.Body))
+		return summary.content.String()
+	}
+	recorder := &streamRecorder{ReadCloser: io.NopCloser(strings.NewReader(f.Body)), onDone: func(*completionSummary) {}}
+	_, _ = io.Copy(io.Discard, recorder)
+	return recorder.summary.content.String()
+}
+
+type replayer struct {
+	dir  string
+	mode ReplayMode
+	// onDiff is called with the changes of the re-recorded completions
+	onDiff   func(hash string, diff *OutputDiff)
+	diffOpts []DiffOption
+}
+
+type ReplayOption func(*replayer)
+
+// WithReplayDiff calls fn in record mode when a chat completion fixture is
+// re-recorded with a different output, e.g. after deploying a new version of
+// an agent, with the differences of the outputs.
+//
+//	opts := workflowai.ReplayOptions("testdata/fixtures", workflowai.ReplayModeFromEnv(),
+//		workflowai.WithReplayDiff(func(hash string, diff *workflowai.OutputDiff) {
+//			t.Logf("fixture %s changed, %.0f%% similar:\n%s", hash, 100*diff.Similarity(), diff)
+//		}))
+func WithReplayDiff(fn func(hash string, diff *OutputDiff), opts ...DiffOption) ReplayOption {
+	return func(r *replayer) {
+		r.onDiff = fn
+		r.diffOpts = opts
+	}
+}
+
+// ReplayOptions returns the client options that serve responses from the
+// fixtures stored in dir, keyed by a hash of the request method, path, query
+// and body. Headers, including the API key, are not part of the hash.
+//
+// In replay mode the network is disabled: requests without a fixture fail
+// with a [*FixtureMissError] and retries are disabled.
+func ReplayOptions(dir string, mode ReplayMode, replayOpts ...ReplayOption) []option.RequestOption {
+	r := &replayer{dir: dir, mode: mode}
+	for _, opt := range replayOpts {
+		opt(r)
+	}
+	opts := []option.RequestOption{option.WithMiddleware(r.middleware)}
+	if mode == ReplayModeReplay {
+		opts = append(
+			opts,
+			option.WithHTTPClient(&http.Client{Transport: disabledTransport{}}),
+			option.WithMaxRetries(0),
+		)
+	}
+	return opts
+}
+
+type disabledTransport struct{}
+
+func (disabledTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, ErrNetworkDisabled
+}
+
+// RequestHash returns the key of a request in the fixture store. path
+// includes the query of the request, see [RequestTarget].
+func RequestHash(method string, path string, body []byte) (string, error) {
+	canonical, err := canonicalJSON(body)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	fmt.Fprintf(h, "%s %s\n", method, path)
+	h.Write(canonical)
+	return hex.EncodeToString(h.Sum(nil))[:24], nil
+}
+
+// RequestTarget returns the path and the query of a request URL, with the
+// query parameters sorted so that their order does not change the hash.
+func RequestTarget(u *url.URL) string {
+	if u.RawQuery == "" {
+		return u.Path
+	}
+	query, err := url.ParseQuery(u.RawQuery)
+	if err != nil {
+		return u.Path + "?" + u.RawQuery
+	}
+	return u.Path + "?" + query.Encode()
+}
+
+// canonicalJSON re-encodes a JSON body with sorted keys so that the hash does
+// not depend on field order or formatting.
+func canonicalJSON(body []byte) ([]byte, error) {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return nil, nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var v any
+	if err := decoder.Decode(&v); err != nil {
+		// Not a JSON body, e.g. a multipart upload
+		return body, nil
+	}
+	return json.Marshal(v)
+}
+
+func (r *replayer) path(hash string) string {
+	return filepath.Join(r.dir, hash+".json")
+}
+
+func (r *replayer) middleware(req *http.Request, next option.MiddlewareNext) (*http.Response, error) {
+	body, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	target := RequestTarget(req.URL)
+	hash, err := RequestHash(req.Method, target, body)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.mode == ReplayModeRecord {
+		return r.record(req, next, hash, target, body)
+	}
+
+	data, err := os.ReadFile(r.path(hash))
+	if errors.Is(err, os.ErrNotExist) {
+		return abort(&FixtureMissError{Hash: hash, Method: req.Method, Path: target})
+	}
+	if err != nil {
+		return nil, err
+	}
+	var fixture Fixture
+	if err := json.Unmarshal(data, &fixture); err != nil {
+		return nil, fmt.Errorf("workflowai: decoding fixture %s: %w", r.path(hash), err)
+	}
+	return &http.Response{
+		Status:        http.StatusText(fixture.StatusCode),
+		StatusCode:    fixture.StatusCode,
+		Header:        http.Header{"Content-Type": []string{fixture.ContentType}},
+		Body:          io.NopCloser(bytes.NewReader([]byte(fixture.Body))),
+		ContentLength: int64(len(fixture.Body)),
+		Request:       req,
+	}, nil
+}
+
+func (r *replayer) record(req *http.Request, next option.MiddlewareNext, hash string, target string, body []byte) (*http.Response, error) {
+	res, err := next(req)
+	if err != nil {
+		return res, err
+	}
+	resBody, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	res.Body = io.NopCloser(bytes.NewReader(resBody))
+
+	fixture := Fixture{
+		Method:      req.Method,
+		Path:        target,
+		StatusCode:  res.StatusCode,
+		ContentType: res.Header.Get("Content-Type"),
+		Body:        string(resBody),
+	}
+	if json.Valid(body) {
+		fixture.Request = body
+	}
+	if r.onDiff != nil && isChatCompletionRequest(req) && res.StatusCode < 400 {
+		r.diffFixture(hash, fixture)
+	}
+	data, err := json.MarshalIndent(fixture, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(r.dir, 0o755); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(r.path(hash), data, 0o644); err != nil {
+		return nil, fmt.Errorf("workflowai: writing fixture: %w", err)
+	}
+	return res, nil
+}
+
+// diffFixture reports the changes of the output of the fixture that is
+// overwritten by fixture.
+func (r *replayer) diffFixture(hash string, fixture Fixture) {
+	data, err := os.ReadFile(r.path(hash))
+	if err != nil {
+		return
+	}
+	var previous Fixture
+	if json.Unmarshal(data, &previous) != nil || previous.StatusCode >= 400 {
+		return
+	}
+	if diff := DiffOutputs(previous.Content(), fixture.Content(), r.diffOpts...); !diff.Equal() {
+		r.onDiff(hash, diff)
+	}
+}
@@ -0,0 +1,154 @@
+package workflowai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+func TestReplayOptions(t *testing.T) {
+	server := newTestServer(t)
+	dir := t.TempDir()
+	params := openai.ChatCompletionNewParams{
+		Model:    "my-agent/gpt-4o",
+		Messages: []openai.ChatCompletionMessageParamUnion{openai.UserMessage("Hello")},
+	}
+
+	recorder := openai.NewClient(append(
+		[]option.RequestOption{option.WithBaseURL(server.URL + "/v1/"), option.WithAPIKey("key")},
+		ReplayOptions(dir, ReplayModeRecord)...,
+	)...)
+	if _, err := recorder.Chat.Completions.New(context.Background(), params); err != nil {
+		t.Fatal(err)
+	}
+	stream := recorder.Chat.Completions.NewStreaming(context.Background(), params)
+	for stream.Next() {
+	}
+	if err := stream.Err(); err != nil {
+		t.Fatal(err)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 fixtures, got %d", len(entries))
+	}
+
+	server.Close()
+	replayer := openai.NewClient(append(
+		[]option.RequestOption{option.WithBaseURL(server.URL + "/v1/"), option.WithAPIKey("other-key")},
+		ReplayOptions(dir, ReplayModeReplay)...,
+	)...)
+	completion, err := replayer.Chat.Completions.New(context.Background(), params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if completion.Choices[0].Message.Content != "Hello world" {
+		t.Errorf("unexpected content %q", completion.Choices[0].Message.Content)
+	}
+
+	stream = replayer.Chat.Completions.NewStreaming(context.Background(), params)
+	acc := openai.ChatCompletionAccumulator{}
+	for stream.Next() {
+		acc.AddChunk(stream.Current())
+	}
+	if err := stream.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if acc.Choices[0].Message.Content != "Hello world" {
+		t.Errorf("unexpected streamed content %q", acc.Choices[0].Message.Content)
+	}
+
+	params.Messages = []openai.ChatCompletionMessageParamUnion{openai.UserMessage("Goodbye")}
+	_, err = replayer.Chat.Completions.New(context.Background(), params)
+	var miss *FixtureMissError
+	if !errors.As(err, &miss) {
+		t.Fatalf("expected a fixture miss, got %v", err)
+	}
+	if miss.Path != "/v1/chat/completions" {
+		t.Errorf("unexpected path %q", miss.Path)
+	}
+}
+
+func TestRequestHash_IgnoresKeyOrder(t *testing.T) {
+	a, err := RequestHash("POST", "/v1/chat/completions", []byte(`{"model":"gpt-4o","temperature":0.5}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := RequestHash("POST", "/v1/chat/completions", []byte(`{ "temperature": 0.5, "model": "gpt-4o" }`))
+	if a != b {
+		t.Errorf("hashes differ: %s != %s", a, b)
+	}
+	c, _ := RequestHash("POST", "/v1/chat/completions", []byte(`{"model":"gpt-4o","temperature":0.7}`))
+	if a == c {
+		t.Error("expected different hashes for different bodies")
+	}
+}
+
+func TestRequestTarget(t *testing.T) {
+	for raw, want := range map[string]string{
+		"https://api.workflowai.com/v1/runs":                   "/v1/runs",
+		"https://api.workflowai.com/v1/runs?offset=10&limit=5": "/v1/runs?limit=5&offset=10",
+		"https://api.workflowai.com/v1/runs?limit=5&offset=10": "/v1/runs?limit=5&offset=10",
+	} {
+		u, _ := url.Parse(raw)
+		if got := RequestTarget(u); got != want {
+			t.Errorf("%s: got %q, want %q", raw, got, want)
+		}
+	}
+	first, _ := RequestHash("GET", "/v1/runs?offset=0", nil)
+	second, _ := RequestHash("GET", "/v1/runs?offset=100", nil)
+	if first == second {
+		t.Error("expected the pages of a list to have different hashes")
+	}
+}
+
+func TestReplayOptions_Diff(t *testing.T) {
+	label := "positive"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		content, _ := json.Marshal(fmt.Sprintf(`{"label": %q, "score": 0.9}`, label))
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"id": "my-agent/run-1", "object": "chat.completion", "choices": [{"index": 0, "finish_reason": "stop", "message": {"role": "assistant", "content": %s}}]}`, content)
+	}))
+	defer server.Close()
+
+	var diffs []*OutputDiff
+	client := openai.NewClient(append(
+		[]option.RequestOption{option.WithBaseURL(server.URL + "/v1/"), option.WithAPIKey("key")},
+		ReplayOptions(t.TempDir(), ReplayModeRecord, WithReplayDiff(func(_ string, diff *OutputDiff) {
+			diffs = append(diffs, diff)
+		}))...,
+	)...)
+	params := openai.ChatCompletionNewParams{
+		Model:    "my-agent/#1/production",
+		Messages: []openai.ChatCompletionMessageParamUnion{openai.UserMessage("Great product")},
+	}
+	for _, l := range []string{"positive", "positive", "negative"} {
+		label = l
+		if _, err := client.Chat.Completions.New(context.Background(), params); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(diffs) != 1 || diffs[0].String() != "~ $.label: \"positive\" -> \"negative\"\n" || diffs[0].Similarity() != 0.5 {
+		t.Errorf("unexpected diffs %v", diffs)
+	}
+}
+
+func TestFixture_Content(t *testing.T) {
+	fixture := Fixture{
+		ContentType: "text/event-stream",
+		Body: "data: {\"id\":\"my-agent/run-1\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"Hello\"}}]}\n\n" +
+			"data: {\"id\":\"my-agent/run-1\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\" world\"}}]}\n\n" +
+			"data: [DONE]\n\n",
+	}
+	if content := fixture.Content(); content != "Hello world" {
+		t.Errorf("unexpected content %q", content)
+	}
+}
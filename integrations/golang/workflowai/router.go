@@ -0,0 +1,295 @@
+package workflowai
+
+import (
+	"context"
+	"encoding/json"
+	"hash/fnv"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+	"github.com/openai/openai-go/packages/ssestream"
+)
+
+// ChatService creates chat completions, streamed or not. It is implemented by
+// [openai.ChatCompletionService].
+type ChatService interface {
+	ChatCompleter
+	NewStreaming(ctx context.Context, body openai.ChatCompletionNewParams, opts ...option.RequestOption) *ssestream.Stream[openai.ChatCompletionChunk]
+}
+
+// Arm is one side of an [ABRouter].
+type Arm struct {
+	// Name is recorded in the metadata of the runs, e.g. "control"
+	Name string
+	// Model replaces the model of the requests routed to the arm, e.g.
+	// "my-agent/#1/production". The model of the request is kept when empty.
+	Model string
+}
+
+// ArmStats are the counters of an arm since the creation of the router.
+type ArmStats struct {
+	Requests int64
+	Errors   int64
+	CostUSD  float64
+	// Latency is the total latency of the requests, streams included
+	Latency time.Duration
+	// SchemaFailures counts the successful responses that did not match the
+	// schema set with [WithResponseSchema]
+	SchemaFailures int64
+}
+
+// AverageLatency returns the mean latency of the requests of the arm.
+func (s ArmStats) AverageLatency() time.Duration {
+	if s.Requests == 0 {
+		return 0
+	}
+	return s.Latency / time.Duration(s.Requests)
+}
+
+// ErrorRate returns the fraction of the requests of the arm that failed.
+func (s ArmStats) ErrorRate() float64 {
+	if s.Requests == 0 {
+		return 0
+	}
+	return float64(s.Errors) / float64(s.Requests)
+}
+
+// SchemaFailureRate returns the fraction of the successful requests of the
+// arm whose response did not match the schema.
+func (s ArmStats) SchemaFailureRate() float64 {
+	if s.Requests == s.Errors {
+		return 0
+	}
+	return float64(s.SchemaFailures) / float64(s.Requests-s.Errors)
+}
+
+// sub returns the counters accumulated since prev.
+func (s ArmStats) sub(prev ArmStats) ArmStats {
+	return ArmStats{
+		Requests:       s.Requests - prev.Requests,
+		Errors:         s.Errors - prev.Errors,
+		CostUSD:        s.CostUSD - prev.CostUSD,
+		Latency:        s.Latency - prev.Latency,
+		SchemaFailures: s.SchemaFailures - prev.SchemaFailures,
+	}
+}
+
+type armCounters struct {
+	Arm
+	schema json.RawMessage
+
+	mu    sync.Mutex
+	stats ArmStats
+}
+
+// armRequest counts a request once in the counters of its arm, whatever the
+// number of attempts the client made to send it.
+type armRequest struct {
+	counters *armCounters
+	start    time.Time
+
+	mu      sync.Mutex
+	costUSD float64
+	// response is the content of the last attempt
+	response string
+	done     bool
+}
+
+// RecordRun implements RunLogStore so that the attempts are fed by the run
+// log middleware, which handles streamed completions. A successful streamed
+// response is the last attempt of a stream, which completes the request when
+// it ends.
+func (q *armRequest) RecordRun(_ context.Context, record RunRecord) error {
+	q.mu.Lock()
+	q.costUSD += record.CostUSD
+	q.response = record.Response
+	q.mu.Unlock()
+	if record.Stream && record.StatusCode > 0 && record.StatusCode < 400 {
+		q.finish(record.Error != "")
+	}
+	return nil
+}
+
+// finish counts the request, once.
+func (q *armRequest) finish(failed bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.done {
+		return
+	}
+	q.done = true
+	c := q.counters
+	invalid := !failed && c.schema != nil && ValidateJSONSchema(c.schema, json.RawMessage(q.response)) != nil
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stats.Requests++
+	if failed {
+		c.stats.Errors++
+	}
+	if invalid {
+		c.stats.SchemaFailures++
+	}
+	c.stats.CostUSD += q.costUSD
+	c.stats.Latency += time.Since(q.start)
+}
+
+// ABRouter splits chat completions between two arms, typically two
+// deployments of the same agent. Requests are assigned to arm B with the
+// configured probability, or by a stable hash of the routing key when one is
+// set on the context with [ContextWithRoutingKey], so that a user always sees
+// the same arm.
+//
+// ABRouter implements [ChatService] and can be used wherever a ChatCompleter
+// is expected, e.g. in a [ConversationManager].
+type ABRouter struct {
+	completions ChatService
+	experiment  string
+	arms        [2]*armCounters
+	// percentB is the percentage of traffic routed to B, times 100
+	percentB atomic.Int64
+}
+
+var _ ChatService = (*ABRouter)(nil)
+
+type ABRouterOption func(*ABRouter)
+
+// WithPercentB sets the percentage of traffic, between 0 and 100, routed to
+// arm B. Defaults to 50.
+func WithPercentB(percent float64) ABRouterOption {
+	return func(r *ABRouter) {
+		r.SetPercentB(percent)
+	}
+}
+
+// WithResponseSchema validates the content of the responses against a JSON
+// schema and counts the failures in the arm stats, e.g. for an agent with
+// structured outputs.
+func WithResponseSchema(schema json.RawMessage) ABRouterOption {
+	return func(r *ABRouter) {
+		for _, arm := range r.arms {
+			arm.schema = schema
+		}
+	}
+}
+
+// NewABRouter creates a router for the experiment. The experiment name and
+// the arm are sent as the "ab_experiment" and "ab_arm" metadata of each run.
+func NewABRouter(completions ChatService, experiment string, a Arm, b Arm, opts ...ABRouterOption) *ABRouter {
+	r := &ABRouter{
+		completions: completions,
+		experiment:  experiment,
+		arms:        [2]*armCounters{{Arm: a}, {Arm: b}},
+	}
+	r.percentB.Store(5000)
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// SetPercentB changes the percentage of traffic routed to arm B. It is safe
+// to call while requests are in flight.
+func (r *ABRouter) SetPercentB(percent float64) {
+	r.percentB.Store(int64(min(max(percent, 0), 100) * 100))
+}
+
+// PercentB returns the percentage of traffic routed to arm B.
+func (r *ABRouter) PercentB() float64 {
+	return float64(r.percentB.Load()) / 100
+}
+
+// Arms returns the arms of the router.
+func (r *ABRouter) Arms() (a Arm, b Arm) {
+	return r.arms[0].Arm, r.arms[1].Arm
+}
+
+// Stats returns the counters of each arm, keyed by arm name.
+func (r *ABRouter) Stats() map[string]ArmStats {
+	stats := make(map[string]ArmStats, 2)
+	for _, arm := range r.arms {
+		arm.mu.Lock()
+		stats[arm.Name] = arm.stats
+		arm.mu.Unlock()
+	}
+	return stats
+}
+
+type routingKey struct{}
+
+// ContextWithRoutingKey returns a context that routes requests by a stable
+// hash of key, typically a user or organization ID.
+func ContextWithRoutingKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, routingKey{}, key)
+}
+
+// RoutingKey returns the routing key set with [ContextWithRoutingKey].
+func RoutingKey(ctx context.Context) string {
+	key, _ := ctx.Value(routingKey{}).(string)
+	return key
+}
+
+func (r *ABRouter) pick(ctx context.Context) *armCounters {
+	var bucket int64
+	if key := RoutingKey(ctx); key != "" {
+		h := fnv.New64a()
+		// Salting with the experiment name keeps assignments independent
+		// across experiments
+		h.Write([]byte(r.experiment))
+		h.Write([]byte{0})
+		h.Write([]byte(key))
+		bucket = int64(h.Sum64() % 10000)
+	} else {
+		bucket = rand.Int64N(10000)
+	}
+	if bucket < r.percentB.Load() {
+		return r.arms[1]
+	}
+	return r.arms[0]
+}
+
+// Arm returns the name of the arm a request with ctx would be routed to, which
+// is deterministic when ctx has a routing key.
+func (r *ABRouter) Arm(ctx context.Context) string {
+	return r.pick(ctx).Name
+}
+
+func (r *ABRouter) route(ctx context.Context, body *openai.ChatCompletionNewParams, opts []option.RequestOption) ([]option.RequestOption, *armRequest) {
+	arm := r.pick(ctx)
+	// The content is only needed to validate the schema
+	contentLimit := 1
+	if arm.schema != nil {
+		contentLimit = 0
+	}
+	if arm.Model != "" {
+		body.Model = arm.Model
+	}
+	request := &armRequest{counters: arm, start: time.Now()}
+	return append(opts,
+		option.WithJSONSet("metadata.ab_experiment", r.experiment),
+		option.WithJSONSet("metadata.ab_arm", arm.Name),
+		option.WithMiddleware(RunLogMiddleware(request, WithRunLogContentLimit(contentLimit))),
+	), request
+}
+
+func (r *ABRouter) New(ctx context.Context, body openai.ChatCompletionNewParams, opts ...option.RequestOption) (*openai.ChatCompletion, error) {
+	opts, request := r.route(ctx, &body, opts)
+	completion, err := r.completions.New(ctx, body, opts...)
+	request.finish(err != nil)
+	return completion, err
+}
+
+func (r *ABRouter) NewStreaming(ctx context.Context, body openai.ChatCompletionNewParams, opts ...option.RequestOption) *ssestream.Stream[openai.ChatCompletionChunk] {
+	opts, request := r.route(ctx, &body, opts)
+	stream := r.completions.NewStreaming(ctx, body, opts...)
+	// The attempts are over when the stream fails before its first chunk,
+	// otherwise the request completes when the stream ends
+	if stream.Err() != nil {
+		request.finish(true)
+	}
+	return stream
+}
@@ -0,0 +1,124 @@
+package workflowai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+func TestABRouter(t *testing.T) {
+	var mu sync.Mutex
+	models := map[string]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Model    string            `json:"model"`
+			Metadata map[string]string `json:"metadata"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		mu.Lock()
+		models[body.Metadata["user"]] = body.Model + " " + body.Metadata["ab_experiment"] + " " + body.Metadata["ab_arm"]
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, testCompletion)
+	}))
+	defer server.Close()
+
+	client := openai.NewClient(option.WithBaseURL(server.URL+"/v1/"), option.WithAPIKey("key"))
+	router := NewABRouter(&client.Chat.Completions, "new-prompt",
+		Arm{Name: "control", Model: "my-agent/#1/production"},
+		Arm{Name: "candidate", Model: "my-agent/#2/production"},
+		WithPercentB(30),
+	)
+
+	for i := range 200 {
+		user := fmt.Sprintf("user-%d", i)
+		ctx := ContextWithRoutingKey(context.Background(), user)
+		_, err := router.New(ctx, openai.ChatCompletionNewParams{
+			Model:    "my-agent/gpt-4o",
+			Messages: []openai.ChatCompletionMessageParamUnion{openai.UserMessage("Hello")},
+			Metadata: map[string]string{"user": user},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := "my-agent/#1/production new-prompt control"
+		if router.Arm(ctx) == "candidate" {
+			want = "my-agent/#2/production new-prompt candidate"
+		}
+		if models[user] != want {
+			t.Fatalf("expected %q for %s, got %q", want, user, models[user])
+		}
+	}
+
+	stats := router.Stats()
+	if stats["control"].Requests+stats["candidate"].Requests != 200 {
+		t.Fatalf("unexpected counters %+v", stats)
+	}
+	if n := stats["candidate"].Requests; n < 40 || n > 80 {
+		t.Errorf("expected about 60 candidate requests, got %d", n)
+	}
+	if stats["control"].CostUSD != float64(stats["control"].Requests)*0.5 {
+		t.Errorf("unexpected control cost %f", stats["control"].CostUSD)
+	}
+}
+
+func TestABRouter_StableAssignment(t *testing.T) {
+	router := NewABRouter(nil, "exp", Arm{Name: "a"}, Arm{Name: "b"})
+	ctx := ContextWithRoutingKey(context.Background(), "user-1")
+	first := router.Arm(ctx)
+	for range 10 {
+		if router.Arm(ctx) != first {
+			t.Fatal("assignment is not stable")
+		}
+	}
+
+	router.SetPercentB(0)
+	if router.Arm(ctx) != "a" {
+		t.Error("expected arm a with 0% of traffic to b")
+	}
+	router.SetPercentB(100)
+	if router.Arm(ctx) != "b" {
+		t.Error("expected arm b with 100% of traffic to b")
+	}
+}
+
+func TestABRouter_CountsRequestsOnce(t *testing.T) {
+	var mu sync.Mutex
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		attempts++
+		failed := attempts == 1
+		mu.Unlock()
+		if failed {
+			http.Error(w, `{"error": {"message": "overloaded"}}`, http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, testCompletion)
+	}))
+	defer server.Close()
+	client := NewClient(option.WithBaseURL(server.URL+"/v1"), option.WithAPIKey("test"), option.WithMaxRetries(1))
+	router := NewABRouter(&client.Chat.Completions, "exp", Arm{Name: "a"}, Arm{Name: "b"}, WithPercentB(0))
+
+	_, err := router.New(context.Background(), openai.ChatCompletionNewParams{
+		Model:    "my-agent/gpt-4o-mini-latest",
+		Messages: []openai.ChatCompletionMessageParamUnion{openai.UserMessage("Hello")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attempts != 2 {
+		t.Fatalf("expected the request to be retried once, got %d attempts", attempts)
+	}
+	if stats := router.Stats()["a"]; stats.Requests != 1 || stats.Errors != 0 {
+		t.Errorf("expected the retried request to be counted once, got %+v", stats)
+	}
+}
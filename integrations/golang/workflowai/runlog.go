@@ -0,0 +1,368 @@
+package workflowai
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/openai/openai-go/option"
+)
+
+// RunRecord is a single chat completion exchange as recorded by the run log.
+type RunRecord struct {
+	CreatedAt time.Time
+	// Model is the model that was requested, e.g. "my-agent/gpt-4o"
+	Model string
+	// AgentID and RunID are extracted from the completion ID returned by
+	// WorkflowAI, which has the format "<agent_id>/<run_id>"
+	AgentID   string
+	RunID     string
+	VersionID string
+	CostUSD   float64
+	Latency   time.Duration
+
+	PromptTokens     int64
+	CompletionTokens int64
+
+	Stream     bool
+	StatusCode int
+	// Error is set when the request failed before or after reaching the server
+	Error string
+
+	// Request and Response are truncated to the content limit of the run log
+	Request  string
+	Response string
+}
+
+// RunLogStore persists run records. See the sqlrunlog package for a
+// database/sql implementation.
+type RunLogStore interface {
+	RecordRun(ctx context.Context, record RunRecord) error
+}
+
+type runLogger struct {
+	store        RunLogStore
+	contentLimit int
+	onError      func(error)
+}
+
+type RunLogOption func(*runLogger)
+
+// WithRunLogContentLimit sets the maximum number of bytes of the request and
+// response bodies that are recorded. Defaults to 4096.
+func WithRunLogContentLimit(n int) RunLogOption {
+	return func(l *runLogger) {
+		l.contentLimit = n
+	}
+}
+
+// WithRunLogErrorHandler is called when a record could not be persisted. By
+// default errors are logged with the standard logger. Failing to persist a
+// record never fails the completion.
+func WithRunLogErrorHandler(fn func(error)) RunLogOption {
+	return func(l *runLogger) {
+		l.onError = fn
+	}
+}
+
+// RunLogMiddleware returns a client middleware that records every chat
+// completion, streamed or not, in store:
+//
+//	client := openai.NewClient(option.WithMiddleware(workflowai.RunLogMiddleware(store)))
+//
+// Records are written synchronously once the response body has been fully read.
+func RunLogMiddleware(store RunLogStore, opts ...RunLogOption) option.Middleware {
+	l := &runLogger{
+		store:        store,
+		contentLimit: 4096,
+		onError: func(err error) {
+			log.Printf("workflowai: failed to record run: %v", ScrubError(err))
+		},
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l.middleware
+}
+
+func isChatCompletionRequest(req *http.Request) bool {
+	return req.Method == http.MethodPost && strings.HasSuffix(req.URL.Path, "/chat/completions")
+}
+
+func (l *runLogger) middleware(req *http.Request, next option.MiddlewareNext) (*http.Response, error) {
+	if !isChatCompletionRequest(req) {
+		return next(req)
+	}
+
+	body, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	var payload struct {
+		Model  string `json:"model"`
+		Stream bool   `json:"stream"`
+	}
+	_ = json.Unmarshal(body, &payload)
+
+	record := RunRecord{
+		CreatedAt: time.Now(),
+		Model:     payload.Model,
+		Stream:    payload.Stream,
+		Request:   truncate(RedactSecrets(string(body)), l.contentLimit),
+	}
+	ctx := req.Context()
+
+	res, err := next(req)
+	if err != nil {
+		record.Latency = time.Since(record.CreatedAt)
+		record.Error = RedactSecrets(err.Error())
+		l.record(ctx, record)
+		return res, err
+	}
+	record.StatusCode = res.StatusCode
+
+	if strings.HasPrefix(res.Header.Get("Content-Type"), "text/event-stream") {
+		res.Body = &streamRecorder{
+			ReadCloser: res.Body,
+			onDone: func(summary *completionSummary) {
+				record.Latency = time.Since(record.CreatedAt)
+				summary.apply(&record, l.contentLimit)
+				l.record(ctx, record)
+			},
+		}
+		return res, nil
+	}
+
+	resBody, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	record.Latency = time.Since(record.CreatedAt)
+	if err != nil {
+		record.Error = RedactSecrets(err.Error())
+		l.record(ctx, record)
+		return nil, err
+	}
+	res.Body = io.NopCloser(bytes.NewReader(resBody))
+
+	if res.StatusCode >= 400 {
+		record.Error = truncate(RedactSecrets(string(resBody)), l.contentLimit)
+	} else {
+		var summary completionSummary
+		summary.parseCompletion(resBody)
+		summary.apply(&record, l.contentLimit)
+	}
+	l.record(ctx, record)
+	return res, nil
+}
+
+func (l *runLogger) record(ctx context.Context, record RunRecord) {
+	// The request context may already be canceled when a stream is closed early
+	if err := l.store.RecordRun(context.WithoutCancel(ctx), record); err != nil {
+		l.onError(err)
+	}
+}
+
+// readRequestBody reads the request body and resets it so that it can be sent.
+func readRequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil {
+		return nil, nil
+	}
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+// setRequestBody replaces the body of a request rewritten by a middleware.
+func setRequestBody(req *http.Request, body []byte) {
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.ContentLength = int64(len(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+}
+
+func truncate(s string, limit int) string {
+	if limit <= 0 || len(s) <= limit {
+		return s
+	}
+	// Avoid cutting a multi-byte character in half
+	for limit > 0 && !utf8.RuneStart(s[limit]) {
+		limit--
+	}
+	return s[:limit] + "…"
+}
+
+// completionSummary holds the fields of a completion, or of the chunks of a
+// streamed completion, that are recorded.
+type completionSummary struct {
+	id        string
+	versionID string
+	costUSD   float64
+	usage     *completionUsage
+	content   strings.Builder
+	// truncated is true when the stream was closed before its end
+	truncated bool
+}
+
+type completionUsage struct {
+	PromptTokens     int64 `json:"prompt_tokens"`
+	CompletionTokens int64 `json:"completion_tokens"`
+}
+
+type completionChoice struct {
+	CostUSD *float64 `json:"cost_usd"`
+	Message *struct {
+		Content string `json:"content"`
+	} `json:"message"`
+	Delta *struct {
+		Content string `json:"content"`
+	} `json:"delta"`
+}
+
+type completionPayload struct {
+	ID        string             `json:"id"`
+	VersionID string             `json:"version_id"`
+	Usage     *completionUsage   `json:"usage"`
+	Choices   []completionChoice `json:"choices"`
+}
+
+func (s *completionSummary) parseCompletion(data []byte) {
+	var payload completionPayload
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return
+	}
+	if payload.ID != "" {
+		s.id = payload.ID
+	}
+	if payload.VersionID != "" {
+		s.versionID = payload.VersionID
+	}
+	if payload.Usage != nil {
+		s.usage = payload.Usage
+	}
+	for _, choice := range payload.Choices {
+		if choice.CostUSD != nil {
+			s.costUSD = *choice.CostUSD
+		}
+		if choice.Message != nil {
+			s.content.WriteString(choice.Message.Content)
+		}
+		if choice.Delta != nil {
+			s.content.WriteString(choice.Delta.Content)
+		}
+	}
+}
+
+func (s *completionSummary) apply(record *RunRecord, contentLimit int) {
+	record.AgentID, record.RunID = splitCompletionID(s.id)
+	record.VersionID = s.versionID
+	record.CostUSD = s.costUSD
+	if s.usage != nil {
+		record.PromptTokens = s.usage.PromptTokens
+		record.CompletionTokens = s.usage.CompletionTokens
+	}
+	record.Response = truncate(s.content.String(), contentLimit)
+	if s.truncated && record.Error == "" {
+		record.Error = ErrStreamTruncated.Error()
+	}
+}
+
+// splitCompletionID splits a WorkflowAI completion ID "<agent_id>/<run_id>".
+func splitCompletionID(id string) (agentID string, runID string) {
+	if agentID, runID, ok := strings.Cut(id, "/"); ok {
+		return agentID, runID
+	}
+	return "", id
+}
+
+// ErrStreamTruncated is the error of the streamed completions closed before
+// their end, e.g. by a client that stopped reading them. Their content is
+// partial.
+var ErrStreamTruncated = errors.New("workflowai: stream closed before the end of the completion")
+
+// streamRecorder parses server sent events as they are read by the client
+// and calls onDone once the stream is exhausted or closed. onEvent, when set,
+// is called with the data of each event.
+type streamRecorder struct {
+	io.ReadCloser
+	buf     bytes.Buffer
+	summary completionSummary
+	once    sync.Once
+	onEvent func(data []byte)
+	onDone  func(*completionSummary)
+	// ended is true once the [DONE] event or the end of the body is read
+	ended bool
+}
+
+func (r *streamRecorder) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.buf.Write(p[:n])
+	r.consumeLines()
+	if err == io.EOF {
+		r.ended = true
+		r.done()
+	}
+	return n, err
+}
+
+func (r *streamRecorder) Close() error {
+	err := r.ReadCloser.Close()
+	r.done()
+	return err
+}
+
+func (r *streamRecorder) consumeLines() {
+	for {
+		line, err := r.buf.ReadBytes('\n')
+		if err != nil {
+			// Incomplete line, put it back until more data is read
+			rest := append([]byte{}, line...)
+			r.buf.Reset()
+			r.buf.Write(rest)
+			return
+		}
+		r.consumeLine(bytes.TrimSpace(line))
+	}
+}
+
+func (r *streamRecorder) consumeLine(line []byte) {
+	data, ok := bytes.CutPrefix(line, []byte("data:"))
+	if !ok {
+		return
+	}
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("[DONE]")) {
+		r.ended = true
+		return
+	}
+	if len(data) == 0 {
+		return
+	}
+	r.summary.parseCompletion(data)
+	if r.onEvent != nil {
+		r.onEvent(data)
+	}
+}
+
+func (r *streamRecorder) done() {
+	r.once.Do(func() {
+		scanner := bufio.NewScanner(bytes.NewReader(r.buf.Bytes()))
+		for scanner.Scan() {
+			r.consumeLine(bytes.TrimSpace(scanner.Bytes()))
+		}
+		r.summary.truncated = !r.ended
+		r.onDone(&r.summary)
+	})
+}
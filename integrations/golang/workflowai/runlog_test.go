@@ -0,0 +1,126 @@
+package workflowai
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+type memoryRunLog struct {
+	mu      sync.Mutex
+	records []RunRecord
+}
+
+func (m *memoryRunLog) RecordRun(_ context.Context, record RunRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.records = append(m.records, record)
+	return nil
+}
+
+const testCompletion = `{
+	"id": "my-agent/run-1",
+	"object": "chat.completion",
+	"created": 1,
+	"model": "gpt-4o",
+	"version_id": "version-1",
+	"choices": [{"index": 0, "finish_reason": "stop", "cost_usd": 0.5, "message": {"role": "assistant", "content": "Hello world"}}],
+	"usage": {"prompt_tokens": 10, "completion_tokens": 2, "total_tokens": 12}
+}`
+
+var testChunks = []string{
+	`{"id":"my-agent/run-2","object":"chat.completion.chunk","created":1,"model":"gpt-4o","choices":[{"index":0,"delta":{"content":"Hello"}}]}`,
+	`{"id":"my-agent/run-2","object":"chat.completion.chunk","created":1,"model":"gpt-4o","choices":[{"index":0,"delta":{"content":" world"}}]}`,
+	`{"id":"my-agent/run-2","object":"chat.completion.chunk","created":1,"model":"gpt-4o","choices":[{"index":0,"delta":{},"finish_reason":"stop","cost_usd":0.25}],"usage":{"prompt_tokens":10,"completion_tokens":2,"total_tokens":12}}`,
+}
+
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := readRequestBody(r)
+		if bytes.Contains(body, []byte(`"stream":true`)) {
+			w.Header().Set("Content-Type", "text/event-stream")
+			for _, chunk := range testChunks {
+				fmt.Fprintf(w, "data: %s\n\n", chunk)
+			}
+			fmt.Fprint(w, "data: [DONE]\n\n")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, testCompletion)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestRunLogMiddleware(t *testing.T) {
+	server := newTestServer(t)
+	store := &memoryRunLog{}
+	client := openai.NewClient(
+		option.WithBaseURL(server.URL),
+		option.WithAPIKey("test"),
+		option.WithMiddleware(RunLogMiddleware(store, WithRunLogContentLimit(5))),
+	)
+	params := openai.ChatCompletionNewParams{
+		Model:    "my-agent/gpt-4o",
+		Messages: []openai.ChatCompletionMessageParamUnion{openai.UserMessage("Hi")},
+	}
+
+	ctx := context.Background()
+	if _, err := client.Chat.Completions.New(ctx, params); err != nil {
+		t.Fatal(err)
+	}
+
+	stream := client.Chat.Completions.NewStreaming(ctx, params)
+	for stream.Next() {
+	}
+	if err := stream.Err(); err != nil {
+		t.Fatal(err)
+	}
+	stream.Close()
+
+	if len(store.records) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(store.records))
+	}
+
+	for i, expected := range []RunRecord{
+		{RunID: "run-1", CostUSD: 0.5},
+		{RunID: "run-2", CostUSD: 0.25, Stream: true},
+	} {
+		record := store.records[i]
+		if record.AgentID != "my-agent" || record.RunID != expected.RunID {
+			t.Errorf("record %d: unexpected ids %s/%s", i, record.AgentID, record.RunID)
+		}
+		if record.CostUSD != expected.CostUSD || record.Stream != expected.Stream {
+			t.Errorf("record %d: unexpected record %+v", i, record)
+		}
+		if record.Model != "my-agent/gpt-4o" || record.PromptTokens != 10 || record.CompletionTokens != 2 {
+			t.Errorf("record %d: unexpected record %+v", i, record)
+		}
+		if record.Response != "Hello…" {
+			t.Errorf("record %d: expected truncated response, got %q", i, record.Response)
+		}
+		if record.StatusCode != http.StatusOK {
+			t.Errorf("record %d: unexpected status code %d", i, record.StatusCode)
+		}
+	}
+	if store.records[0].VersionID != "version-1" {
+		t.Errorf("unexpected version id %s", store.records[0].VersionID)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	if got := truncate("héllo", 2); got != "h…" {
+		t.Fatalf("expected multi-byte character to be dropped, got %q", got)
+	}
+	if got := truncate("hello", 0); got != "hello" {
+		t.Fatalf("expected no truncation, got %q", got)
+	}
+}
@@ -0,0 +1,158 @@
+package workflowai
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+// RunService accesses the runs of an agent.
+type RunService struct {
+	client openai.Client
+}
+
+// SearchOperator is an operator of a FieldQuery.
+type SearchOperator string
+
+const (
+	SearchOperatorIs                   SearchOperator = "is"
+	SearchOperatorIsNot                SearchOperator = "is not"
+	SearchOperatorIsEmpty              SearchOperator = "is empty"
+	SearchOperatorIsNotEmpty           SearchOperator = "is not empty"
+	SearchOperatorContains             SearchOperator = "contains"
+	SearchOperatorNotContains          SearchOperator = "does not contain"
+	SearchOperatorGreaterThan          SearchOperator = "greater than"
+	SearchOperatorGreaterThanOrEqualTo SearchOperator = "greater than or equal to"
+	SearchOperatorLessThan             SearchOperator = "less than"
+	SearchOperatorLessThanOrEqualTo    SearchOperator = "less than or equal to"
+	SearchOperatorIsBetween            SearchOperator = "is between"
+	SearchOperatorIsNotBetween         SearchOperator = "is not between"
+	SearchOperatorIsBefore             SearchOperator = "is before"
+	SearchOperatorIsAfter              SearchOperator = "is after"
+)
+
+// FieldQuery filters runs on a single field, e.g. "status", "model", "time",
+// "metadata.user_id" or "input.question".
+type FieldQuery struct {
+	FieldName string         `json:"field_name"`
+	Operator  SearchOperator `json:"operator"`
+	Values    []any          `json:"values"`
+	// Type is the type of the field, e.g. "string" or "date". Optional.
+	Type string `json:"type,omitempty"`
+}
+
+type RunSearchParams struct {
+	FieldQueries []FieldQuery `json:"field_queries,omitempty"`
+	Limit        int          `json:"limit,omitempty"`
+	Offset       int          `json:"offset,omitempty"`
+}
+
+type Page[T any] struct {
+	Items []T  `json:"items"`
+	Count *int `json:"count,omitempty"`
+}
+
+type RunVersion struct {
+	ID         string `json:"id"`
+	Properties struct {
+		Model       string   `json:"model,omitempty"`
+		Provider    string   `json:"provider,omitempty"`
+		Temperature *float64 `json:"temperature,omitempty"`
+	} `json:"properties"`
+}
+
+type RunFeedback struct {
+	Outcome    string `json:"outcome"`
+	Annotation string `json:"annotation,omitempty"`
+}
+
+type RunError struct {
+	Code    string         `json:"code"`
+	Message string         `json:"message"`
+	Details map[string]any `json:"details,omitempty"`
+}
+
+// RunBase contains the fields shared by RunItem and Run.
+type RunBase struct {
+	ID              string        `json:"id"`
+	AgentID         string        `json:"task_id"`
+	SchemaID        int           `json:"task_schema_id"`
+	Version         RunVersion    `json:"version"`
+	Status          string        `json:"status"`
+	DurationSeconds *float64      `json:"duration_seconds,omitempty"`
+	CostUSD         *float64      `json:"cost_usd,omitempty"`
+	CreatedAt       time.Time     `json:"created_at"`
+	UserReview      string        `json:"user_review,omitempty"`
+	AIReview        string        `json:"ai_review,omitempty"`
+	Feedback        []RunFeedback `json:"feedback,omitempty"`
+	FeedbackToken   string        `json:"feedback_token"`
+	URL             string        `json:"url"`
+	Error           *RunError     `json:"error,omitempty"`
+}
+
+// RunItem is a run as returned by the search endpoint, with previews of the
+// input and output.
+type RunItem struct {
+	RunBase
+	InputPreview  string `json:"task_input_preview"`
+	OutputPreview string `json:"task_output_preview"`
+}
+
+// Run is a full run, as returned by [RunService.Get].
+type Run struct {
+	RunBase
+	Input          json.RawMessage `json:"task_input"`
+	Output         json.RawMessage `json:"task_output"`
+	ConversationID string          `json:"conversation_id,omitempty"`
+	Metadata       map[string]any  `json:"metadata,omitempty"`
+}
+
+// Conversation returns the ID of the conversation of the run, set by the
+// platform or by [WithConversationID].
+func (r *Run) Conversation() string {
+	return r.ConversationID
+}
+
+// SearchConversation returns the runs of the page of runs matching params
+// that belong to the conversation, most recent first. The search endpoint
+// cannot filter on the conversation ID, so the runs of the page are fetched
+// to read it: page through a conversation with params.Offset.
+func (s *RunService) SearchConversation(ctx context.Context, agentID string, conversationID string, params RunSearchParams, opts ...option.RequestOption) (*Page[Run], error) {
+	page, err := s.Search(ctx, agentID, params, opts...)
+	if err != nil {
+		return nil, err
+	}
+	runs := &Page[Run]{Items: []Run{}}
+	for _, item := range page.Items {
+		run, err := s.Get(ctx, agentID, item.ID, opts...)
+		if err != nil {
+			return nil, err
+		}
+		if run.ConversationID == conversationID {
+			runs.Items = append(runs.Items, *run)
+		}
+	}
+	return runs, nil
+}
+
+// Search returns a page of the runs of an agent matching params, most recent first.
+func (s *RunService) Search(ctx context.Context, agentID string, params RunSearchParams, opts ...option.RequestOption) (*Page[RunItem], error) {
+	var page Page[RunItem]
+	if err := s.client.Execute(ctx, http.MethodPost, agentPath(agentID, "runs", "search"), params, &page, opts...); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// Get returns a run with its full input and output.
+func (s *RunService) Get(ctx context.Context, agentID string, runID string, opts ...option.RequestOption) (*Run, error) {
+	var run Run
+	if err := s.client.Execute(ctx, http.MethodGet, agentPath(agentID, "runs", runID), nil, &run, opts...); err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
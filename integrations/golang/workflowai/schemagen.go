@@ -0,0 +1,390 @@
+package workflowai
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/openai/openai-go"
+)
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	rawMessageType    = reflect.TypeOf(json.RawMessage{})
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// JSONSchemaFor returns the JSON schema of the encoding of a T by
+// encoding/json, compatible with strict structured outputs: every field is
+// required and objects do not allow additional properties. Pointer fields are
+// nullable, so that optional values are sent as null.
+//
+// The schema of a field is refined by struct tags:
+//
+//	type Invoice struct {
+//		Number   string  `json:"number" description:"The invoice number, as printed"`
+//		Currency string  `json:"currency" enum:"EUR,USD,GBP"`
+//		Total    float64 `json:"total" jsonschema:"minimum=0"`
+//		Date     string  `json:"date" pattern:"^\\d{4}-\\d{2}-\\d{2}$"`
+//	}
+//
+// The jsonschema tag holds comma separated minimum, maximum, minLength,
+// maxLength, minItems, maxItems and format keywords. Recursive types are
+// declared in "$defs", except the root type which is referenced as "#" so
+// that the schema stays an object. Maps, interfaces and json.RawMessage
+// fields are allowed but have no strict schema.
+func JSONSchemaFor[T any]() (json.RawMessage, error) {
+	return JSONSchemaOf(reflect.TypeOf((*T)(nil)).Elem())
+}
+
+// JSONSchemaOf returns the JSON schema of the type t, see [JSONSchemaFor].
+func JSONSchemaOf(t reflect.Type) (json.RawMessage, error) {
+	g := &schemaGenerator{root: t, defs: map[string]any{}, visiting: map[reflect.Type]bool{}, recursive: map[reflect.Type]bool{}}
+	schema, err := g.schema(t)
+	if err != nil {
+		return nil, fmt.Errorf("workflowai: schema of %s: %w", t, err)
+	}
+	if len(g.defs) > 0 {
+		schema["$defs"] = g.defs
+	}
+	return json.Marshal(schema)
+}
+
+// ResponseFormatFor returns a strict JSON schema response format for
+// outputs decoded into a T.
+func ResponseFormatFor[T any](name string) (openai.ChatCompletionNewParamsResponseFormatUnion, error) {
+	raw, err := JSONSchemaFor[T]()
+	if err != nil {
+		return openai.ChatCompletionNewParamsResponseFormatUnion{}, err
+	}
+	var schema map[string]any
+	json.Unmarshal(raw, &schema)
+	return openai.ChatCompletionNewParamsResponseFormatUnion{OfJSONSchema: &openai.ResponseFormatJSONSchemaParam{
+		JSONSchema: openai.ResponseFormatJSONSchemaJSONSchemaParam{Name: name, Schema: schema, Strict: openai.Bool(true)},
+	}}, nil
+}
+
+type schemaGenerator struct {
+	root      reflect.Type
+	defs      map[string]any
+	visiting  map[reflect.Type]bool
+	recursive map[reflect.Type]bool
+}
+
+func (g *schemaGenerator) schema(t reflect.Type) (map[string]any, error) {
+	switch {
+	case t == timeType:
+		return map[string]any{"type": "string", "format": "date-time"}, nil
+	case t == rawMessageType:
+		return map[string]any{}, nil
+	case t.Kind() != reflect.Pointer && (t.Implements(textMarshalerType) || reflect.PointerTo(t).Implements(textMarshalerType)):
+		return map[string]any{"type": "string"}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]any{"type": "integer"}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer", "minimum": 0}, nil
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}, nil
+	case reflect.String:
+		return map[string]any{"type": "string"}, nil
+	case reflect.Interface:
+		return map[string]any{}, nil
+	case reflect.Pointer:
+		elem, err := g.schema(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return nullable(elem), nil
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			// Encoded in base64
+			return map[string]any{"type": "string"}, nil
+		}
+		items, err := g.schema(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		schema := map[string]any{"type": "array", "items": items}
+		if t.Kind() == reflect.Array {
+			schema["minItems"], schema["maxItems"] = t.Len(), t.Len()
+		}
+		return schema, nil
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String && !t.Key().Implements(textMarshalerType) {
+			return nil, fmt.Errorf("unsupported map key %s", t.Key())
+		}
+		values, err := g.schema(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"type": "object", "additionalProperties": values}, nil
+	case reflect.Struct:
+		return g.structSchema(t)
+	}
+	return nil, fmt.Errorf("unsupported type %s", t)
+}
+
+// structSchema returns the schema of a struct, or a reference to its
+// definition when it is recursive.
+func (g *schemaGenerator) structSchema(t reflect.Type) (map[string]any, error) {
+	ref := map[string]any{"$ref": "#/$defs/" + t.Name()}
+	if t == g.root {
+		ref = map[string]any{"$ref": "#"}
+	}
+	if g.visiting[t] {
+		if t.Name() == "" {
+			return nil, fmt.Errorf("recursive anonymous struct")
+		}
+		g.recursive[t] = true
+		return ref, nil
+	}
+	if _, ok := g.defs[t.Name()]; ok && g.recursive[t] {
+		return ref, nil
+	}
+	g.visiting[t] = true
+	defer delete(g.visiting, t)
+
+	properties := map[string]any{}
+	required := []string{}
+	if err := g.fields(t, properties, &required); err != nil {
+		return nil, err
+	}
+	schema := map[string]any{
+		"type":                 "object",
+		"properties":           properties,
+		"required":             required,
+		"additionalProperties": false,
+	}
+	if g.recursive[t] && t != g.root {
+		g.defs[t.Name()] = schema
+		return ref, nil
+	}
+	return schema, nil
+}
+
+// fields adds the properties of the fields of a struct that encoding/json
+// encodes, see [structFields].
+func (g *schemaGenerator) fields(t reflect.Type, properties map[string]any, required *[]string) error {
+	for _, f := range structFields(t) {
+		schema, err := g.schema(f.field.Type)
+		if err != nil {
+			return fmt.Errorf("field %s: %w", f.field.Name, err)
+		}
+		if f.quoted {
+			schema = map[string]any{"type": "string"}
+		}
+		if err := applyTags(schema, f.field); err != nil {
+			return fmt.Errorf("field %s: %w", f.field.Name, err)
+		}
+		*required = append(*required, f.name)
+		properties[f.name] = schema
+	}
+	return nil
+}
+
+// structField is a field of a struct encoded by encoding/json.
+type structField struct {
+	name   string
+	tagged bool
+	quoted bool
+	index  []int
+	field  reflect.StructField
+}
+
+// structFields returns the fields of a struct encoded by encoding/json, in
+// the order of their declaration. The fields of the embedded structs without
+// a JSON name are promoted, following the rules of encoding/json: a field
+// hides the fields of the same name nested deeper, and among the fields of
+// the same name at the same depth the one with a JSON name wins, none of
+// them being encoded when that is ambiguous.
+func structFields(t reflect.Type) []structField {
+	type embedding struct {
+		t     reflect.Type
+		index []int
+	}
+	var fields []structField
+	hidden := map[string]bool{}
+	visited := map[reflect.Type]bool{}
+	for level := []embedding{{t: t}}; len(level) > 0; {
+		var next []embedding
+		byName := map[string][]structField{}
+		var names []string
+		for _, e := range level {
+			for i := 0; i < e.t.NumField(); i++ {
+				field := e.t.Field(i)
+				tag := field.Tag.Get("json")
+				if tag == "-" {
+					continue
+				}
+				name, options, _ := strings.Cut(tag, ",")
+				typ := field.Type
+				if typ.Kind() == reflect.Pointer {
+					typ = typ.Elem()
+				}
+				if field.Anonymous {
+					// The exported fields of unexported embedded structs are encoded
+					if !field.IsExported() && typ.Kind() != reflect.Struct {
+						continue
+					}
+					if name == "" && typ.Kind() == reflect.Struct {
+						next = append(next, embedding{t: typ, index: append(slices.Clone(e.index), i)})
+						continue
+					}
+				} else if !field.IsExported() {
+					continue
+				}
+				f := structField{name: name, tagged: name != "", index: append(slices.Clone(e.index), i), field: field}
+				if f.name == "" {
+					f.name = field.Name
+				}
+				f.quoted = slices.Contains(strings.Split(options, ","), "string")
+				if _, ok := byName[f.name]; !ok {
+					names = append(names, f.name)
+				}
+				byName[f.name] = append(byName[f.name], f)
+			}
+		}
+		for _, name := range names {
+			if hidden[name] {
+				continue
+			}
+			hidden[name] = true
+			if f, ok := dominantField(byName[name]); ok {
+				fields = append(fields, f)
+			}
+		}
+		for _, e := range level {
+			visited[e.t] = true
+		}
+		level = slices.DeleteFunc(next, func(e embedding) bool { return visited[e.t] })
+	}
+	slices.SortFunc(fields, func(a, b structField) int { return slices.Compare(a.index, b.index) })
+	return fields
+}
+
+// dominantField returns the field encoded among fields of the same name at
+// the same depth: the only one, or the only one with a JSON name.
+func dominantField(fields []structField) (structField, bool) {
+	if len(fields) == 1 {
+		return fields[0], true
+	}
+	var tagged []structField
+	for _, f := range fields {
+		if f.tagged {
+			tagged = append(tagged, f)
+		}
+	}
+	if len(tagged) == 1 {
+		return tagged[0], true
+	}
+	return structField{}, false
+}
+
+// applyTags adds the keywords of the description, enum, pattern and
+// jsonschema tags of a field to its schema.
+func applyTags(schema map[string]any, field reflect.StructField) error {
+	// The keywords apply to the value of nullable fields
+	target := schema
+	if variants, ok := schema["anyOf"].([]any); ok {
+		target = variants[0].(map[string]any)
+	}
+	if description := field.Tag.Get("description"); description != "" {
+		schema["description"] = description
+	}
+	if pattern := field.Tag.Get("pattern"); pattern != "" {
+		target["pattern"] = pattern
+	}
+	if enum := field.Tag.Get("enum"); enum != "" {
+		var values []any
+		for _, value := range strings.Split(enum, ",") {
+			v, err := enumValue(target, value)
+			if err != nil {
+				return err
+			}
+			values = append(values, v)
+		}
+		if _, ok := schema["anyOf"]; ok || isNullable(schema) {
+			values = append(values, nil)
+		}
+		target["enum"] = values
+	}
+	for _, keyword := range strings.Split(field.Tag.Get("jsonschema"), ",") {
+		if keyword == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(keyword, "=")
+		if !ok {
+			return fmt.Errorf("invalid jsonschema tag %q", keyword)
+		}
+		switch key {
+		case "minimum", "maximum":
+			n, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return fmt.Errorf("invalid %s %q", key, value)
+			}
+			target[key] = n
+		case "minLength", "maxLength", "minItems", "maxItems":
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("invalid %s %q", key, value)
+			}
+			target[key] = n
+		case "format":
+			target[key] = value
+		default:
+			return fmt.Errorf("unknown jsonschema keyword %q", key)
+		}
+	}
+	return nil
+}
+
+// enumValue converts a value of an enum tag to the type of the schema.
+func enumValue(schema map[string]any, value string) (any, error) {
+	types := schemaTypes(schema["type"])
+	switch {
+	case len(types) == 0 || types[0] == "string":
+		return value, nil
+	case types[0] == "integer":
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid integer enum value %q", value)
+		}
+		return n, nil
+	case types[0] == "number":
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number enum value %q", value)
+		}
+		return n, nil
+	}
+	return nil, fmt.Errorf("enum on a %s field", types[0])
+}
+
+// nullable returns a schema also accepting null: the scalar and array types
+// become a list of types and the other schemas an anyOf.
+func nullable(schema map[string]any) map[string]any {
+	if t, ok := schema["type"].(string); ok && t != "object" {
+		schema["type"] = []any{t, "null"}
+		return schema
+	}
+	return map[string]any{"anyOf": []any{schema, map[string]any{"type": "null"}}}
+}
+
+func isNullable(schema map[string]any) bool {
+	for _, t := range schemaTypes(schema["type"]) {
+		if t == "null" {
+			return true
+		}
+	}
+	return false
+}
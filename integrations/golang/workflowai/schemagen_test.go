@@ -0,0 +1,164 @@
+package workflowai
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+type schemaLineItem struct {
+	Description string  `json:"description"`
+	Quantity    int     `json:"quantity" jsonschema:"minimum=1"`
+	Amount      float64 `json:"amount"`
+}
+
+type schemaAddress struct {
+	City    string `json:"city"`
+	Country string `json:"country" pattern:"^[A-Z]{2}$"`
+}
+
+type schemaInvoice struct {
+	schemaAddress
+	Number   string           `json:"number" description:"The invoice number"`
+	Currency string           `json:"currency" enum:"EUR,USD"`
+	Total    float64          `json:"total" jsonschema:"minimum=0"`
+	Items    []schemaLineItem `json:"items" jsonschema:"minItems=1"`
+	DueDate  *string          `json:"due_date" jsonschema:"format=date"`
+	Status   *string          `json:"status" enum:"paid,due"`
+	Payer    *schemaAddress   `json:"payer"`
+	Issued   time.Time        `json:"issued"`
+	Notes    string           `json:"-"`
+	internal string
+}
+
+type schemaNode struct {
+	Name     string        `json:"name"`
+	Children []*schemaNode `json:"children"`
+}
+
+type schemaNamed struct {
+	Title string `json:"Name"`
+	ID    string
+}
+
+type schemaLabeled struct {
+	Name string
+	ID   string
+	Kind string
+}
+
+type schemaKinded struct {
+	Kind string
+}
+
+type schemaEmbedding struct {
+	schemaNamed
+	*schemaLabeled
+	schemaKinded
+	ID   int    `json:"ID"`
+	Note string `json:"note"`
+}
+
+type schemaTree struct {
+	Value string      `json:"value"`
+	Left  *schemaLeaf `json:"left"`
+}
+
+type schemaLeaf struct {
+	Tree *schemaTree `json:"tree"`
+}
+
+func TestJSONSchemaFor(t *testing.T) {
+	schema, err := JSONSchemaFor[schemaInvoice]()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded struct {
+		Properties           map[string]map[string]any `json:"properties"`
+		Required             []string                  `json:"required"`
+		AdditionalProperties bool                      `json:"additionalProperties"`
+	}
+	json.Unmarshal(schema, &decoded)
+	if got, want := strings.Join(decoded.Required, ","), "city,country,number,currency,total,items,due_date,status,payer,issued"; got != want {
+		t.Errorf("required = %s, want %s", got, want)
+	}
+	if decoded.AdditionalProperties {
+		t.Error("additional properties are allowed")
+	}
+	if decoded.Properties["number"]["description"] != "The invoice number" {
+		t.Errorf("number = %v", decoded.Properties["number"])
+	}
+
+	valid := `{"city": "Paris", "country": "FR", "number": "F-1", "currency": "EUR", "total": 12.5,
+		"items": [{"description": "Pen", "quantity": 2, "amount": 12.5}],
+		"due_date": null, "status": "paid", "payer": {"city": "Lyon", "country": "FR"}, "issued": "2024-01-02T00:00:00Z"}`
+	if err := ValidateJSONSchema(schema, json.RawMessage(valid)); err != nil {
+		t.Errorf("valid invoice: %v", err)
+	}
+	invalid := `{"city": "Paris", "country": "France", "number": "F-1", "currency": "GBP", "total": -1,
+		"items": [], "due_date": "2024-02-01", "status": null, "payer": null, "issued": "2024-01-02T00:00:00Z", "notes": ""}`
+	err = ValidateJSONSchema(schema, json.RawMessage(invalid))
+	for _, want := range []string{"$.country", "$.currency", "$.total", "$.items", "$.notes"} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("expected an error on %s, got %v", want, err)
+		}
+	}
+
+	tree, err := JSONSchemaFor[schemaNode]()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(tree), `"$ref":"#"`) || strings.Contains(string(tree), "$defs") || !strings.HasPrefix(string(tree), "{\"additionalProperties\":false") {
+		t.Errorf("expected the recursive root to be inlined: %s", tree)
+	}
+	if err := ValidateJSONSchema(tree, json.RawMessage(`{"name": "a", "children": [{"name": "b", "children": [null]}]}`)); err != nil {
+		t.Errorf("valid tree: %v", err)
+	}
+	if err := ValidateJSONSchema(tree, json.RawMessage(`{"name": "a", "children": [{"children": []}]}`)); err == nil {
+		t.Error("invalid tree: expected an error")
+	}
+
+	if _, err := JSONSchemaFor[map[int]chan int](); err == nil {
+		t.Error("expected an error for unsupported types")
+	}
+}
+
+func TestJSONSchemaFor_EmbeddedFields(t *testing.T) {
+	schema, err := JSONSchemaFor[schemaEmbedding]()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded struct {
+		Properties map[string]map[string]any `json:"properties"`
+		Required   []string                  `json:"required"`
+	}
+	json.Unmarshal(schema, &decoded)
+	// The tagged Title wins over Name, the ID of the embedded structs are
+	// hidden and Kind is ambiguous
+	encoded, _ := json.Marshal(schemaEmbedding{schemaLabeled: &schemaLabeled{}})
+	var keys map[string]any
+	json.Unmarshal(encoded, &keys)
+	if len(keys) != len(decoded.Required) {
+		t.Errorf("encoding/json encodes %s, schema requires %v", encoded, decoded.Required)
+	}
+	if got, want := strings.Join(decoded.Required, ","), "Name,ID,note"; got != want {
+		t.Errorf("required = %s, want %s", got, want)
+	}
+	if decoded.Properties["ID"]["type"] != "integer" {
+		t.Errorf("ID = %v", decoded.Properties["ID"])
+	}
+}
+
+func TestJSONSchemaFor_MutuallyRecursive(t *testing.T) {
+	schema, err := JSONSchemaFor[schemaTree]()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ValidateJSONSchema(schema, json.RawMessage(`{"value": "a", "left": {"tree": {"value": "b", "left": null}}}`)); err != nil {
+		t.Errorf("valid tree: %v", err)
+	}
+	if err := ValidateJSONSchema(schema, json.RawMessage(`{"value": "a", "left": {"tree": {"left": null}}}`)); err == nil {
+		t.Errorf("invalid tree: expected an error, schema %s", schema)
+	}
+}
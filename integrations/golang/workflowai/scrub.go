@@ -0,0 +1,186 @@
+package workflowai
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"sync/atomic"
+
+	"github.com/openai/openai-go/option"
+)
+
+// VerboseEnv is the environment variable that enables verbose mode when set
+// to "1" or "true", see [SetVerbose].
+const VerboseEnv = "WORKFLOWAI_VERBOSE"
+
+var verbose atomic.Bool
+
+func init() {
+	v := os.Getenv(VerboseEnv)
+	verbose.Store(v == "1" || v == "true")
+}
+
+// SetVerbose controls whether the logs and errors produced by this module
+// include message content. Secrets are redacted in both modes. Verbose mode
+// is off by default.
+func SetVerbose(v bool) {
+	verbose.Store(v)
+}
+
+// Verbose returns true when verbose mode is enabled.
+func Verbose() bool {
+	return verbose.Load()
+}
+
+const redacted = "[REDACTED]"
+
+var secretPatterns = []*regexp.Regexp{
+	// WorkflowAI, OpenAI and Anthropic API keys
+	regexp.MustCompile(`\bwai-[A-Za-z0-9_-]{8,}`),
+	regexp.MustCompile(`\bsk-(?:proj-|ant-)?[A-Za-z0-9_-]{16,}`),
+	// AWS access key IDs
+	regexp.MustCompile(`\bAKIA[0-9A-Z]{16}\b`),
+}
+
+// secretFieldPattern matches the values of headers and fields that hold
+// credentials, e.g. `Authorization: Bearer ...` or `"api_key": "..."`.
+var secretFieldPattern = regexp.MustCompile(`(?i)((?:"|\b)(?:authorization|x-api-key|api[_-]?key|access[_-]?token|client[_-]?secret|password)"?\s*[:=]\s*)("[^"]*"|(?:bearer\s+)?[^\s,;}"]+)`)
+
+// RedactSecrets replaces API keys, bearer tokens and credential fields in s.
+func RedactSecrets(s string) string {
+	s = secretFieldPattern.ReplaceAllString(s, "${1}"+redacted)
+	for _, p := range secretPatterns {
+		s = p.ReplaceAllString(s, redacted)
+	}
+	return s
+}
+
+// longStringPattern matches JSON strings of at least 64 characters, which is
+// where message content ends up in error messages, e.g. when the server
+// echoes the request. The strings of the keys matched by keptKeyPattern are
+// matched with their key, to be kept: they explain the error.
+var (
+	longStringPattern = regexp.MustCompile(`("(?:message|code)"\s*:\s*)?"(?:[^"\\]|\\.){64,}"`)
+	keptKeyPattern    = regexp.MustCompile(`^"(?:message|code)"\s*:`)
+)
+
+// Scrub redacts the secrets in s and, unless verbose mode is enabled, replaces
+// long JSON strings by their length, except the values of the "message" and
+// "code" keys, which explain the errors. It is applied to the logs and errors
+// produced by this module, and can be used for application logs.
+func Scrub(s string) string {
+	s = RedactSecrets(s)
+	if Verbose() {
+		return s
+	}
+	return longStringPattern.ReplaceAllStringFunc(s, func(m string) string {
+		if keptKeyPattern.MatchString(m) {
+			return m
+		}
+		return fmt.Sprintf(`"[%d characters]"`, len(m)-2)
+	})
+}
+
+// ScrubError returns an error whose message is scrubbed with [Scrub]. The
+// original error is still accessible with errors.Is and errors.As.
+func ScrubError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &scrubbedError{err: err}
+}
+
+type scrubbedError struct {
+	err error
+}
+
+func (e *scrubbedError) Error() string {
+	return Scrub(e.err.Error())
+}
+
+func (e *scrubbedError) Unwrap() error {
+	return e.err
+}
+
+// scrubResponses scrubs the bodies of the error responses, from which the
+// errors of the clients are built, e.g. [*openai.Error], so that the errors
+// returned by the API methods are scrubbed at the source. The string values
+// of JSON bodies are scrubbed one by one, so that the body can still be
+// decoded.
+func scrubResponses(req *http.Request, next option.MiddlewareNext) (*http.Response, error) {
+	res, err := next(req)
+	if err != nil || res.StatusCode < 400 || strings.HasPrefix(res.Header.Get("Content-Type"), "text/event-stream") {
+		return res, err
+	}
+	body, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	var payload any
+	if json.Unmarshal(body, &payload) == nil {
+		if scrubbed, err := json.Marshal(scrubValue(payload, false)); err == nil {
+			body = scrubbed
+		}
+	} else {
+		body = []byte(Scrub(string(body)))
+	}
+	res.Body = io.NopCloser(bytes.NewReader(body))
+	res.ContentLength = int64(len(body))
+	res.Header.Del("Content-Length")
+	return res, nil
+}
+
+// scrubValue applies [Scrub] to the strings of a decoded JSON value. The
+// long strings are echoed request content, except the code and the message of
+// the error, which are only redacted. keep is true for those.
+func scrubValue(v any, keep bool) any {
+	switch v := v.(type) {
+	case string:
+		v = RedactSecrets(v)
+		if !keep && !Verbose() && len(v) >= 64 {
+			return fmt.Sprintf("[%d characters]", len(v))
+		}
+		return v
+	case map[string]any:
+		for key, value := range v {
+			// Credential fields are redacted by name, as RedactSecrets does
+			if secretFieldPattern.MatchString(key + ": x") {
+				v[key] = redacted
+				continue
+			}
+			if key == "error" {
+				v[key] = scrubError(value)
+				continue
+			}
+			v[key] = scrubValue(value, key == "code")
+		}
+		return v
+	case []any:
+		for i, value := range v {
+			v[i] = scrubValue(value, false)
+		}
+		return v
+	}
+	return v
+}
+
+// scrubError scrubs the error of a response, keeping its message.
+func scrubError(v any) any {
+	payload, ok := v.(map[string]any)
+	if !ok {
+		return scrubValue(v, false)
+	}
+	message, ok := payload["message"].(string)
+	delete(payload, "message")
+	scrubValue(payload, false)
+	if ok {
+		payload["message"] = scrubValue(message, true)
+	}
+	return payload
+}
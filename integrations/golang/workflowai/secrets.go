@@ -0,0 +1,127 @@
+package workflowai
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SecretLoader loads a set of named secrets, e.g. the WorkflowAI API key
+// and the provider keys stored in a secret manager.
+type SecretLoader func(ctx context.Context) (map[string]string, error)
+
+// Secrets caches the secrets of a loader and renews them periodically, so
+// that rotated secrets are picked up without restarting.
+type Secrets struct {
+	load SecretLoader
+	ttl  time.Duration
+
+	mu       sync.Mutex
+	values   map[string]string
+	loadedAt time.Time
+	// loading is closed when the renewal in progress, if any, completes
+	loading chan struct{}
+}
+
+// NewSecrets loads the secrets once, so that a misconfiguration is reported
+// at startup, and renews them when they are older than ttl. ttl defaults to
+// 5 minutes.
+func NewSecrets(ctx context.Context, load SecretLoader, ttl time.Duration) (*Secrets, error) {
+	if ttl <= 0 {
+		ttl = 5 * time.Minute
+	}
+	s := &Secrets{load: load, ttl: ttl}
+	if err := s.reload(ctx); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Secrets) reload(ctx context.Context) error {
+	values, err := s.load(ctx)
+	if err != nil {
+		return fmt.Errorf("workflowai: loading secrets: %w", err)
+	}
+	s.values, s.loadedAt = values, time.Now()
+	return nil
+}
+
+// Get returns the secret called name. The secrets are loaded again when they
+// are older than the ttl or refresh is true. When renewing fails, the cached
+// secrets are used and the error is logged.
+//
+// The secrets are loaded without holding the lock: while they are renewed,
+// the other calls return the cached secrets, except refreshing calls, which
+// wait for the renewal in progress.
+func (s *Secrets) Get(ctx context.Context, name string, refresh bool) (string, error) {
+	s.mu.Lock()
+	if s.loading == nil && (refresh || time.Since(s.loadedAt) >= s.ttl) {
+		loading := make(chan struct{})
+		s.loading = loading
+		s.mu.Unlock()
+		s.renew(ctx, loading)
+		s.mu.Lock()
+	} else if loading := s.loading; loading != nil && refresh {
+		s.mu.Unlock()
+		select {
+		case <-loading:
+		case <-ctx.Done():
+		}
+		s.mu.Lock()
+	}
+	value, ok := s.values[name]
+	s.mu.Unlock()
+	if !ok {
+		return "", fmt.Errorf("workflowai: secret %q not found", name)
+	}
+	return value, nil
+}
+
+func (s *Secrets) renew(ctx context.Context, loading chan struct{}) {
+	values, err := s.load(ctx)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil {
+		log.Printf("workflowai: using cached secrets: %v", ScrubError(fmt.Errorf("workflowai: loading secrets: %w", err)))
+	} else {
+		s.values = values
+	}
+	// Retried after the ttl on errors too, to not overload an unavailable
+	// manager
+	s.loadedAt = time.Now()
+	s.loading = nil
+	close(loading)
+}
+
+// Key returns a KeyProvider for the secret called name, to use with
+// [APIKeyMiddleware].
+func (s *Secrets) Key(name string) KeyProvider {
+	return KeyProviderFunc(func(ctx context.Context, refresh bool) (string, error) {
+		return s.Get(ctx, name, refresh)
+	})
+}
+
+// EnvSecrets returns a SecretLoader reading the secrets called names from the
+// environment, e.g. in development, where a secret manager is not available.
+// Loading fails when one of them is not set.
+func EnvSecrets(names ...string) SecretLoader {
+	return func(context.Context) (map[string]string, error) {
+		values := make(map[string]string, len(names))
+		var missing []string
+		for _, name := range names {
+			if v := os.Getenv(name); v != "" {
+				values[name] = v
+			} else {
+				missing = append(missing, name)
+			}
+		}
+		if len(missing) > 0 {
+			return nil, fmt.Errorf("%s must be set", strings.Join(missing, ", "))
+		}
+		return values, nil
+	}
+}
@@ -0,0 +1,96 @@
+package workflowai
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSecrets(t *testing.T) {
+	ctx := context.Background()
+	loads := 0
+	var loadErr error
+	secrets, err := NewSecrets(ctx, func(context.Context) (map[string]string, error) {
+		if loadErr != nil {
+			return nil, loadErr
+		}
+		loads++
+		return map[string]string{"WORKFLOWAI_API_KEY": "wai-key"}, nil
+	}, time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(2 * time.Millisecond)
+	if v, err := secrets.Get(ctx, "WORKFLOWAI_API_KEY", false); err != nil || v != "wai-key" || loads != 2 {
+		t.Errorf("expected a renewed key, got %q after %d loads: %v", v, loads, err)
+	}
+
+	// The cached secrets are used while the loader fails
+	loadErr = errors.New("unavailable")
+	if v, err := secrets.Key("WORKFLOWAI_API_KEY").APIKey(ctx, true); err != nil || v != "wai-key" {
+		t.Errorf("expected the cached key, got %q: %v", v, err)
+	}
+	if _, err := secrets.Get(ctx, "OPENAI_API_KEY", false); err == nil {
+		t.Error("expected an error for a missing secret")
+	}
+	if _, err := NewSecrets(ctx, func(context.Context) (map[string]string, error) { return nil, loadErr }, 0); err == nil {
+		t.Error("expected the initial load to fail")
+	}
+}
+
+func TestSecrets_RenewedOutsideTheLock(t *testing.T) {
+	ctx := context.Background()
+	started, release := make(chan struct{}), make(chan struct{})
+	loads := 0
+	secrets, err := NewSecrets(ctx, func(context.Context) (map[string]string, error) {
+		loads++
+		switch loads {
+		case 1:
+			return map[string]string{"WORKFLOWAI_API_KEY": "old-key"}, nil
+		case 2:
+			close(started)
+			<-release
+		}
+		return map[string]string{"WORKFLOWAI_API_KEY": "new-key"}, nil
+	}, time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(2 * time.Millisecond)
+	renewed := make(chan string)
+	go func() {
+		v, _ := secrets.Get(ctx, "WORKFLOWAI_API_KEY", false)
+		renewed <- v
+	}()
+	<-started
+	if v, err := secrets.Get(ctx, "WORKFLOWAI_API_KEY", false); err != nil || v != "old-key" {
+		t.Errorf("expected the cached key during the renewal, got %q: %v", v, err)
+	}
+	refreshed := make(chan string)
+	go func() {
+		v, _ := secrets.Get(ctx, "WORKFLOWAI_API_KEY", true)
+		refreshed <- v
+	}()
+	close(release)
+	if v := <-renewed; v != "new-key" {
+		t.Errorf("expected the renewed key, got %q", v)
+	}
+	if v := <-refreshed; v != "new-key" {
+		t.Errorf("expected the refreshed key, got %q", v)
+	}
+}
+
+func TestEnvSecrets(t *testing.T) {
+	t.Setenv("WORKFLOWAI_API_KEY", "wai-key")
+	t.Setenv("SLACK_BOT_TOKEN", "")
+	values, err := EnvSecrets("WORKFLOWAI_API_KEY")(context.Background())
+	if err != nil || values["WORKFLOWAI_API_KEY"] != "wai-key" {
+		t.Errorf("unexpected secrets %v: %v", values, err)
+	}
+	if _, err := EnvSecrets("WORKFLOWAI_API_KEY", "SLACK_BOT_TOKEN")(context.Background()); err == nil || err.Error() != "SLACK_BOT_TOKEN must be set" {
+		t.Errorf("expected the missing secret to be reported, got %v", err)
+	}
+}
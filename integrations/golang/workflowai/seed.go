@@ -0,0 +1,68 @@
+package workflowai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+// Reproducibility is the result of [CheckReproducible].
+type Reproducibility struct {
+	Runs int
+	// Outputs are the distinct contents of the replies, in the order they
+	// were first seen
+	Outputs []string
+	// Fingerprints are the distinct system fingerprints of the replies. A
+	// change of fingerprint means a change of the backend configuration,
+	// which can change the replies even at temperature 0.
+	Fingerprints []string
+	RunIDs       []string
+}
+
+// Deterministic reports whether all the runs replied the same content.
+func (r *Reproducibility) Deterministic() bool {
+	return len(r.Outputs) == 1
+}
+
+// CheckReproducible runs params n times and compares the replies, to verify
+// that a pipeline replies consistently before relying on it, e.g. in evals or
+// regression tests. Its Temperature is set to 0 when unset. WorkflowAI does
+// not support seeding and rejects the requests with a Seed, so the replies
+// are only as stable as the providers are at temperature 0.
+func CheckReproducible(ctx context.Context, completions ChatCompleter, params openai.ChatCompletionNewParams, n int, opts ...option.RequestOption) (*Reproducibility, error) {
+	if params.Seed.Valid() {
+		return nil, errors.New("workflowai: WorkflowAI does not support seeding, remove the Seed of the params")
+	}
+	if !params.Temperature.Valid() {
+		params.Temperature = openai.Float(0)
+	}
+	r := &Reproducibility{}
+	for range n {
+		completion, err := completions.New(ctx, params, opts...)
+		if err != nil {
+			return r, err
+		}
+		if len(completion.Choices) == 0 {
+			return r, errors.New("workflowai: completion has no choices")
+		}
+		r.Runs++
+		_, runID := splitCompletionID(completion.ID)
+		r.RunIDs = append(r.RunIDs, runID)
+		if content := completion.Choices[0].Message.Content; !slices.Contains(r.Outputs, content) {
+			r.Outputs = append(r.Outputs, content)
+		}
+		if fp := completion.SystemFingerprint; fp != "" && !slices.Contains(r.Fingerprints, fp) {
+			r.Fingerprints = append(r.Fingerprints, fp)
+		}
+	}
+	return r, nil
+}
+
+// String summarizes the check, e.g. "3 runs, 2 distinct outputs, fingerprints [fp_1]".
+func (r *Reproducibility) String() string {
+	return fmt.Sprintf("%d runs, %d distinct outputs, fingerprints %v", r.Runs, len(r.Outputs), r.Fingerprints)
+}
@@ -0,0 +1,60 @@
+package workflowai
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+func TestCheckReproducible(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		if _, ok := body["seed"]; ok || body["temperature"] != 0.0 {
+			t.Errorf("seed %v, temperature %v", body["seed"], body["temperature"])
+		}
+		calls++
+		content, fingerprint := "same", "fp_1"
+		if calls == 3 {
+			content, fingerprint = "different", "fp_2"
+		}
+		writeJSON(w, http.StatusOK, map[string]any{
+			"id":                 "agent/run-1",
+			"system_fingerprint": fingerprint,
+			"choices":            []any{map[string]any{"index": 0, "message": map[string]any{"role": "assistant", "content": content}}},
+		})
+	}))
+	defer server.Close()
+	client := NewClient(option.WithBaseURL(server.URL+"/v1"), option.WithAPIKey("test"), option.WithMaxRetries(0))
+	params := openai.ChatCompletionNewParams{
+		Model:    "agent/gpt-4o-mini-latest",
+		Messages: []openai.ChatCompletionMessageParamUnion{openai.UserMessage("Hello")},
+	}
+
+	seeded := params
+	seeded.Seed = openai.Int(42)
+	if _, err := CheckReproducible(context.Background(), &client.Chat.Completions, seeded, 2); err == nil {
+		t.Error("expected an error with a seed")
+	}
+
+	r, err := CheckReproducible(context.Background(), &client.Chat.Completions, params, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.Deterministic() || r.Runs != 2 || len(r.Fingerprints) != 1 || r.RunIDs[0] != "run-1" {
+		t.Errorf("first check = %v", r)
+	}
+	r, err = CheckReproducible(context.Background(), &client.Chat.Completions, params, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Deterministic() || r.String() != "2 runs, 2 distinct outputs, fingerprints [fp_2 fp_1]" {
+		t.Errorf("second check = %v", r)
+	}
+}
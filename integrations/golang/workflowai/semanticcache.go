@@ -0,0 +1,344 @@
+package workflowai
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openai/openai-go/option"
+)
+
+// SemanticCacheConfig is the caching of the completions of an agent by a
+// [SemanticCache].
+type SemanticCacheConfig struct {
+	// Threshold is the minimum cosine similarity between the embeddings of
+	// two prompts for the response of one to be served for the other, 0.95
+	// by default
+	Threshold float64
+	// TTL is how long a response is served, 1 hour by default
+	TTL time.Duration
+}
+
+// SemanticCacheStats are the counters of a [SemanticCache] since its
+// creation.
+type SemanticCacheStats struct {
+	Hits   int64
+	Misses int64
+	// SavedUSD is the cost of the cached completions that were served
+	SavedUSD float64
+	Entries  int
+}
+
+// SemanticCache serves the cached response of a chat completion when the
+// prompt of a new completion is similar enough to the prompt of a cached
+// one, e.g. the same question phrased differently:
+//
+//	cache := workflowai.NewSemanticCache(embed,
+//		workflowai.WithCachedAgent("faq", workflowai.SemanticCacheConfig{Threshold: 0.93, TTL: 24 * time.Hour}))
+//	client := workflowai.NewClient(option.WithMiddleware(cache.Middleware()))
+//
+// The prompt of a completion is its last user message, with the input of
+// its templated messages. The rest of the request, e.g. the model, the other
+// messages and the tools, must be identical. Only the completions of the
+// agents configured with [WithCachedAgent] are cached, and streamed
+// completions are not. The hits are served with a cost of 0 and the
+// "X-Workflowai-Cache: hit" header.
+//
+// Each completion of a cached agent is embedded. Embedding failures are
+// reported to the error handler and never fail the completion. The entries
+// are kept in memory and compared linearly, up to a maximum number of entries.
+// It is safe for concurrent use.
+type SemanticCache struct {
+	embed      func(ctx context.Context, texts []string) ([][]float64, error)
+	agents     map[string]SemanticCacheConfig
+	maxEntries int
+	onError    func(error)
+
+	mu      sync.Mutex
+	entries *list.List
+	stats   SemanticCacheStats
+}
+
+type semanticCacheEntry struct {
+	agent string
+	// scope is the hash of the request without its prompt
+	scope   string
+	vector  []float64
+	body    []byte
+	costUSD float64
+	expires time.Time
+}
+
+type SemanticCacheOption func(*SemanticCache)
+
+// WithCachedAgent enables the caching of the completions of an agent, e.g.
+// "faq" for the model "faq/gpt-4o-mini-latest".
+func WithCachedAgent(agent string, config SemanticCacheConfig) SemanticCacheOption {
+	return func(c *SemanticCache) {
+		if config.Threshold == 0 {
+			config.Threshold = 0.95
+		}
+		if config.TTL == 0 {
+			config.TTL = time.Hour
+		}
+		c.agents[agent] = config
+	}
+}
+
+// WithSemanticCacheMaxEntries sets the maximum number of cached responses,
+// 1000 by default. The least recently used are evicted first.
+func WithSemanticCacheMaxEntries(n int) SemanticCacheOption {
+	return func(c *SemanticCache) {
+		c.maxEntries = n
+	}
+}
+
+// WithSemanticCacheErrorHandler is called when a prompt could not be
+// embedded. By default errors are logged with the standard logger.
+func WithSemanticCacheErrorHandler(fn func(error)) SemanticCacheOption {
+	return func(c *SemanticCache) {
+		c.onError = fn
+	}
+}
+
+// NewSemanticCache returns a cache embedding the prompts with embed, e.g.
+// with the embeddings endpoint of a client of the OpenAI API, see
+// [OpenAIClientOptions]: WorkflowAI does not serve the embeddings.
+func NewSemanticCache(embed func(ctx context.Context, texts []string) ([][]float64, error), opts ...SemanticCacheOption) *SemanticCache {
+	c := &SemanticCache{
+		embed:      embed,
+		agents:     map[string]SemanticCacheConfig{},
+		maxEntries: 1000,
+		onError:    func(err error) { log.Printf("workflowai: semantic cache: %v", ScrubError(err)) },
+		entries:    list.New(),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Middleware returns the client middleware serving the cached responses.
+func (c *SemanticCache) Middleware() option.Middleware {
+	return c.middleware
+}
+
+// Stats returns the counters of the cache.
+func (c *SemanticCache) Stats() SemanticCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := c.stats
+	stats.Entries = c.entries.Len()
+	return stats
+}
+
+// Invalidate removes the cached responses of an agent, e.g. after a new
+// version is deployed, or of every agent when agent is empty.
+func (c *SemanticCache) Invalidate(agent string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for e := c.entries.Front(); e != nil; {
+		next := e.Next()
+		if agent == "" || e.Value.(*semanticCacheEntry).agent == agent {
+			c.entries.Remove(e)
+		}
+		e = next
+	}
+}
+
+func (c *SemanticCache) middleware(req *http.Request, next option.MiddlewareNext) (*http.Response, error) {
+	if !isChatCompletionRequest(req) {
+		return next(req)
+	}
+	body, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	var request map[string]any
+	if json.Unmarshal(body, &request) != nil || request["stream"] == true {
+		return next(req)
+	}
+	model, _ := request["model"].(string)
+	agent, _, ok := strings.Cut(model, "/")
+	config, cached := c.agents[agent]
+	if !ok || !cached {
+		return next(req)
+	}
+	scope, prompt, ok := semanticCacheKey(request)
+	if !ok {
+		return next(req)
+	}
+	vectors, err := c.embed(req.Context(), []string{prompt})
+	if err == nil && len(vectors) != 1 {
+		err = fmt.Errorf("got %d embeddings for 1 prompt", len(vectors))
+	}
+	if err != nil {
+		c.onError(fmt.Errorf("embedding the prompt: %w", err))
+		return next(req)
+	}
+
+	if entry, similarity := c.lookup(agent, scope, vectors[0], config.Threshold); entry != nil {
+		return &http.Response{
+			Status:     http.StatusText(http.StatusOK),
+			StatusCode: http.StatusOK,
+			Header: http.Header{
+				"Content-Type":                  []string{"application/json"},
+				"X-Workflowai-Cache":            []string{"hit"},
+				"X-Workflowai-Cache-Similarity": []string{strconv.FormatFloat(similarity, 'f', 4, 64)},
+			},
+			Body:          io.NopCloser(bytes.NewReader(entry.body)),
+			ContentLength: int64(len(entry.body)),
+			Request:       req,
+		}, nil
+	}
+
+	res, err := next(req)
+	if err != nil || res.StatusCode != http.StatusOK {
+		return res, err
+	}
+	resBody, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	res.Body = io.NopCloser(bytes.NewReader(resBody))
+	if entry := newSemanticCacheEntry(resBody); entry != nil {
+		entry.agent, entry.scope, entry.vector = agent, scope, vectors[0]
+		entry.expires = time.Now().Add(config.TTL)
+		c.add(entry)
+	}
+	return res, nil
+}
+
+// lookup returns the most similar entry above the threshold, counting the
+// hit or the miss.
+func (c *SemanticCache) lookup(agent string, scope string, vector []float64, threshold float64) (*semanticCacheEntry, float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	var best *list.Element
+	bestSimilarity := threshold
+	for e := c.entries.Front(); e != nil; {
+		next := e.Next()
+		entry := e.Value.(*semanticCacheEntry)
+		if now.After(entry.expires) {
+			c.entries.Remove(e)
+		} else if entry.agent == agent && entry.scope == scope {
+			if similarity := cosineSimilarity(vector, entry.vector); similarity >= bestSimilarity {
+				best, bestSimilarity = e, similarity
+			}
+		}
+		e = next
+	}
+	if best == nil {
+		c.stats.Misses++
+		return nil, 0
+	}
+	c.entries.MoveToFront(best)
+	entry := best.Value.(*semanticCacheEntry)
+	c.stats.Hits++
+	c.stats.SavedUSD += entry.costUSD
+	return entry, bestSimilarity
+}
+
+func (c *SemanticCache) add(entry *semanticCacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries.PushFront(entry)
+	for c.maxEntries > 0 && c.entries.Len() > c.maxEntries {
+		c.entries.Remove(c.entries.Back())
+	}
+}
+
+// semanticCacheKey splits a chat completion request into the hash of the
+// request without its prompt, and its prompt: the content of the last user
+// message and the input of the templated messages.
+func semanticCacheKey(request map[string]any) (scope string, prompt string, ok bool) {
+	messages, _ := request["messages"].([]any)
+	last := -1
+	for i, m := range messages {
+		if message, _ := m.(map[string]any); message["role"] == "user" {
+			last = i
+		}
+	}
+	if last < 0 {
+		return "", "", false
+	}
+	var b strings.Builder
+	switch content := messages[last].(map[string]any)["content"].(type) {
+	case string:
+		b.WriteString(content)
+	case []any:
+		for _, part := range content {
+			if part, _ := part.(map[string]any); part["type"] == "text" {
+				text, _ := part["text"].(string)
+				b.WriteString(text)
+			} else {
+				// The images, audio and files must be identical
+				b.WriteString(canonicalHash(part))
+			}
+		}
+	}
+	if input, ok := request["input"]; ok {
+		data, _ := json.Marshal(input)
+		b.WriteString("\n")
+		b.Write(data)
+	}
+
+	rest := make(map[string]any, len(request))
+	for k, v := range request {
+		rest[k] = v
+	}
+	delete(rest, "input")
+	// Copied so that the request is not modified
+	rest["messages"] = append(append(append([]any{}, messages[:last]...), nil), messages[last+1:]...)
+	return canonicalHash(rest), b.String(), true
+}
+
+// canonicalHash returns the hash of a JSON value, whose map keys are sorted
+// by the encoding.
+func canonicalHash(v any) string {
+	data, _ := json.Marshal(v)
+	h := sha256.Sum256(data)
+	return hex.EncodeToString(h[:])
+}
+
+// newSemanticCacheEntry returns the entry of a completion, with its body
+// served at no cost, or nil when the body is not a completion.
+func newSemanticCacheEntry(body []byte) *semanticCacheEntry {
+	var completion map[string]any
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if decoder.Decode(&completion) != nil {
+		return nil
+	}
+	choices, _ := completion["choices"].([]any)
+	if len(choices) == 0 {
+		return nil
+	}
+	entry := &semanticCacheEntry{}
+	for _, choice := range choices {
+		choice, _ := choice.(map[string]any)
+		if cost, ok := choice["cost_usd"].(json.Number); ok {
+			f, _ := cost.Float64()
+			entry.costUSD += f
+			choice["cost_usd"] = 0
+		}
+	}
+	var err error
+	if entry.body, err = json.Marshal(completion); err != nil {
+		return nil
+	}
+	return entry
+}
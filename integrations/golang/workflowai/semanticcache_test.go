@@ -0,0 +1,132 @@
+package workflowai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+func TestSemanticCache(t *testing.T) {
+	var completions atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		completions.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, testCompletion)
+	}))
+	defer server.Close()
+
+	// The prompts about refunds are similar, the other ones are not
+	var embedErr error
+	embed := func(ctx context.Context, texts []string) ([][]float64, error) {
+		if embedErr != nil {
+			return nil, embedErr
+		}
+		vectors := make([][]float64, len(texts))
+		for i, text := range texts {
+			if strings.Contains(text, "refund") {
+				vectors[i] = []float64{1, 0.1}
+			} else {
+				vectors[i] = []float64{0, 1}
+			}
+		}
+		return vectors, nil
+	}
+	var handled []error
+	cache := NewSemanticCache(embed,
+		WithCachedAgent("faq", SemanticCacheConfig{TTL: 100 * time.Millisecond}),
+		WithSemanticCacheErrorHandler(func(err error) { handled = append(handled, err) }))
+	var hooks Hooks
+	usage := NewUsageAccumulator()
+	usage.Register(&hooks)
+	client := NewClient(option.WithBaseURL(server.URL+"/v1"), option.WithAPIKey("test"), option.WithMaxRetries(0),
+		option.WithMiddleware(cache.Middleware()), option.WithMiddleware(hooks.Middleware()))
+	ctx := context.Background()
+	ask := func(model string, system string, question string) *http.Response {
+		t.Helper()
+		var res *http.Response
+		_, err := client.Chat.Completions.New(ctx, openai.ChatCompletionNewParams{
+			Model:    model,
+			Messages: []openai.ChatCompletionMessageParamUnion{openai.SystemMessage(system), openai.UserMessage(question)},
+		}, option.WithResponseInto(&res))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+
+	ask("faq/gpt-4o", "You answer FAQs", "How do I get a refund?")
+	res := ask("faq/gpt-4o", "You answer FAQs", "Can I have a refund please?")
+	if res.Header.Get("X-Workflowai-Cache") != "hit" || completions.Load() != 1 {
+		t.Errorf("expected a hit, got %d completions", completions.Load())
+	}
+	if stats := cache.Stats(); stats.Hits != 1 || stats.Misses != 1 || stats.SavedUSD != 0.5 || stats.Entries != 1 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+	if total := usage.Total(); total.CostUSD != 0.5 {
+		t.Errorf("expected the hit to be free, got $%v", total.CostUSD)
+	}
+
+	// A different prompt, system message or agent is not served from the cache
+	ask("faq/gpt-4o", "You answer FAQs", "Where is my order?")
+	ask("faq/gpt-4o", "You answer FAQs in French", "Can I have a refund please?")
+	ask("support/gpt-4o", "You answer FAQs", "Can I have a refund please?")
+	ask("support/gpt-4o", "You answer FAQs", "Can I have a refund please?")
+	if completions.Load() != 5 {
+		t.Errorf("expected 5 completions, got %d", completions.Load())
+	}
+
+	// The entries expire
+	time.Sleep(150 * time.Millisecond)
+	if res := ask("faq/gpt-4o", "You answer FAQs", "How do I get a refund?"); res.Header.Get("X-Workflowai-Cache") != "" {
+		t.Error("expected the entry to expire")
+	}
+	cache.Invalidate("faq")
+	if stats := cache.Stats(); stats.Entries != 0 {
+		t.Errorf("expected no entries, got %+v", stats)
+	}
+
+	// Embedding failures do not fail the completions
+	embedErr = errors.New("embeddings unavailable")
+	ask("faq/gpt-4o", "You answer FAQs", "How do I get a refund?")
+	if len(handled) != 1 || completions.Load() != 7 {
+		t.Errorf("unexpected errors %v", handled)
+	}
+}
+
+func TestSemanticCacheKey(t *testing.T) {
+	request := map[string]any{
+		"model": "faq/gpt-4o",
+		"messages": []any{
+			map[string]any{"role": "system", "content": "Answer {{question}}"},
+			map[string]any{"role": "user", "content": []any{map[string]any{"type": "text", "text": "Refund?"}}},
+		},
+		"input": map[string]any{"question": "refund"},
+	}
+	scope, prompt, ok := semanticCacheKey(request)
+	if !ok || prompt != "Refund?\n{\"question\":\"refund\"}" {
+		t.Errorf("unexpected prompt %q", prompt)
+	}
+	if len(request["messages"].([]any)) != 2 || request["input"] == nil {
+		t.Error("the request was modified")
+	}
+	request["input"] = map[string]any{"question": "order"}
+	if other, _, _ := semanticCacheKey(request); other != scope {
+		t.Error("expected the input to be part of the prompt")
+	}
+	request["temperature"] = 0.5
+	if other, _, _ := semanticCacheKey(request); other == scope {
+		t.Error("expected the parameters to be part of the scope")
+	}
+	if _, _, ok := semanticCacheKey(map[string]any{"messages": []any{map[string]any{"role": "system", "content": "Hi"}}}); ok {
+		t.Error("expected no prompt without user message")
+	}
+}
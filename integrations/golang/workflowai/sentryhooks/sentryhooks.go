@@ -0,0 +1,179 @@
+// Package sentryhooks reports the failed completions of a WorkflowAI client
+// to Sentry, with breadcrumbs for the lifecycle of the completions:
+//
+//	var hooks workflowai.Hooks
+//	sentryhooks.Register(&hooks)
+//	client := workflowai.NewClient(option.WithMiddleware(hooks.Middleware()))
+//
+// Events are sent to the hub of the request context, e.g. set by sentryhttp,
+// falling back to the current hub.
+package sentryhooks
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strconv"
+
+	"github.com/getsentry/sentry-go"
+
+	"github.com/workflowai/workflowai/go/examples/workflowai"
+)
+
+const category = "workflowai"
+
+type reporter struct {
+	hub         *sentry.Hub
+	breadcrumbs bool
+	chunks      bool
+}
+
+type Option func(*reporter)
+
+// WithHub sends the events to hub instead of the hub of the request context.
+func WithHub(hub *sentry.Hub) Option {
+	return func(r *reporter) {
+		r.hub = hub
+	}
+}
+
+// WithoutBreadcrumbs only captures the errors.
+func WithoutBreadcrumbs() Option {
+	return func(r *reporter) {
+		r.breadcrumbs = false
+	}
+}
+
+// WithChunkBreadcrumbs adds a breadcrumb for every chunk of the streamed
+// completions, which is verbose and disabled by default.
+func WithChunkBreadcrumbs() Option {
+	return func(r *reporter) {
+		r.chunks = true
+	}
+}
+
+// Register captures the errors of the completions observed by hooks and adds
+// breadcrumbs for their lifecycle.
+//
+// Errors are tagged with the model, the agent and run IDs, the WorkflowAI
+// error code and the retry count. The prompt is never sent: the
+// "workflowai" context has a truncated hash of the messages instead, to
+// group the errors of the same prompt.
+func Register(hooks *workflowai.Hooks, opts ...Option) {
+	r := &reporter{breadcrumbs: true}
+	for _, opt := range opts {
+		opt(r)
+	}
+	hooks.OnError(r.onError)
+	if !r.breadcrumbs {
+		return
+	}
+	hooks.OnRequestStart(func(ctx context.Context, e workflowai.RequestStartEvent) {
+		r.breadcrumb(ctx, "completion started", sentry.LevelInfo, requestData(e.HookRequest))
+	})
+	hooks.OnFirstToken(func(ctx context.Context, e workflowai.FirstTokenEvent) {
+		data := requestData(e.HookRequest)
+		data["ttft_ms"] = e.TimeToFirstToken.Milliseconds()
+		r.breadcrumb(ctx, "first token", sentry.LevelInfo, data)
+	})
+	if r.chunks {
+		hooks.OnChunk(func(ctx context.Context, e workflowai.ChunkEvent) {
+			data := requestData(e.HookRequest)
+			data["index"] = e.Index
+			r.breadcrumb(ctx, "chunk", sentry.LevelDebug, data)
+		})
+	}
+	hooks.OnToolCall(func(ctx context.Context, e workflowai.ToolCallEvent) {
+		data := requestData(e.HookRequest)
+		data["tool"] = e.Name
+		r.breadcrumb(ctx, "tool call", sentry.LevelInfo, data)
+	})
+	hooks.OnComplete(func(ctx context.Context, e workflowai.CompleteEvent) {
+		data := requestData(e.HookRequest)
+		data["agent_id"] = e.AgentID
+		data["run_id"] = e.RunID
+		data["duration_ms"] = e.Duration.Milliseconds()
+		data["cost_usd"] = e.CostUSD
+		r.breadcrumb(ctx, "completion done", sentry.LevelInfo, data)
+	})
+}
+
+func (r *reporter) hubFor(ctx context.Context) *sentry.Hub {
+	if r.hub != nil {
+		return r.hub
+	}
+	if hub := sentry.GetHubFromContext(ctx); hub != nil {
+		return hub
+	}
+	return sentry.CurrentHub()
+}
+
+func (r *reporter) breadcrumb(ctx context.Context, message string, level sentry.Level, data map[string]any) {
+	r.hubFor(ctx).AddBreadcrumb(&sentry.Breadcrumb{
+		Type:     "default",
+		Category: category,
+		Message:  message,
+		Level:    level,
+		Data:     data,
+	}, nil)
+}
+
+func (r *reporter) onError(ctx context.Context, e workflowai.ErrorEvent) {
+	hub := r.hubFor(ctx)
+	if r.breadcrumbs {
+		data := requestData(e.HookRequest)
+		data["status_code"] = e.StatusCode
+		r.breadcrumb(ctx, "completion failed", sentry.LevelError, data)
+	}
+	hub.WithScope(func(scope *sentry.Scope) {
+		tags := map[string]string{
+			"workflowai.model":       e.Model,
+			"workflowai.stream":      strconv.FormatBool(e.Stream),
+			"workflowai.retry_count": strconv.Itoa(e.RetryCount),
+		}
+		if e.Code != "" {
+			tags["workflowai.error_code"] = e.Code
+		}
+		if e.AgentID != "" {
+			tags["workflowai.agent_id"] = e.AgentID
+		}
+		if e.RunID != "" {
+			tags["workflowai.run_id"] = e.RunID
+		}
+		scope.SetTags(tags)
+		scope.SetContext(category, sentry.Context{
+			"status_code": e.StatusCode,
+			"duration_ms": e.Duration.Milliseconds(),
+			"prompt_hash": PromptHash(e.Body),
+		})
+		message := "workflowai: completion failed"
+		if e.Err != nil {
+			message = e.Err.Error()
+		}
+		// The error chain is not sent, as the wrapped errors are not scrubbed
+		hub.CaptureException(errors.New(workflowai.Scrub(message)))
+	})
+}
+
+func requestData(request workflowai.HookRequest) map[string]any {
+	return map[string]any{
+		"model":       request.Model,
+		"stream":      request.Stream,
+		"retry_count": request.RetryCount,
+	}
+}
+
+// PromptHash returns the first 12 hexadecimal characters of the SHA-256 hash
+// of the messages of a JSON encoded chat completion request.
+func PromptHash(body []byte) string {
+	var payload struct {
+		Messages json.RawMessage `json:"messages"`
+	}
+	if err := json.Unmarshal(body, &payload); err == nil && payload.Messages != nil {
+		body = payload.Messages
+	}
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])[:12]
+}
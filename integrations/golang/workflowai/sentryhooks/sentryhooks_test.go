@@ -0,0 +1,116 @@
+package sentryhooks
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+
+	"github.com/workflowai/workflowai/go/examples/workflowai"
+)
+
+type memoryTransport struct {
+	mu     sync.Mutex
+	events []*sentry.Event
+}
+
+func (t *memoryTransport) Configure(sentry.ClientOptions) {}
+func (t *memoryTransport) Flush(time.Duration) bool       { return true }
+func (t *memoryTransport) Close()                         {}
+
+func (t *memoryTransport) SendEvent(event *sentry.Event) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.events = append(t.events, event)
+}
+
+func TestRegister(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Retry-After-Ms", "1")
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"id":"my-agent/run-1","error":{"code":"rate_limit","message":"slow down, key sk-abcdefghijklmnopqrstuvwxyz"}}`))
+	}))
+	defer server.Close()
+
+	transport := &memoryTransport{}
+	sentryClient, err := sentry.NewClient(sentry.ClientOptions{Dsn: "https://key@sentry.example.com/1", Transport: transport})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hub := sentry.NewHub(sentryClient, sentry.NewScope())
+	var hooks workflowai.Hooks
+	Register(&hooks)
+	client := openai.NewClient(
+		option.WithBaseURL(server.URL),
+		option.WithAPIKey("test"),
+		option.WithMaxRetries(1),
+		option.WithMiddleware(hooks.Middleware()),
+	)
+	ctx := sentry.SetHubOnContext(context.Background(), hub)
+	params := openai.ChatCompletionNewParams{Model: "my-agent/gpt-4o", Messages: []openai.ChatCompletionMessageParamUnion{openai.UserMessage("secret prompt")}}
+	if _, err := client.Chat.Completions.New(ctx, params); err == nil {
+		t.Fatal("expected an error")
+	}
+
+	if len(transport.events) != 2 {
+		t.Fatalf("expected an event per attempt, got %d", len(transport.events))
+	}
+	event := transport.events[1]
+	for key, value := range map[string]string{
+		"workflowai.model":       "my-agent/gpt-4o",
+		"workflowai.error_code":  "rate_limit",
+		"workflowai.agent_id":    "my-agent",
+		"workflowai.run_id":      "run-1",
+		"workflowai.retry_count": "1",
+	} {
+		if event.Tags[key] != value {
+			t.Errorf("tag %s = %q, expected %q", key, event.Tags[key], value)
+		}
+	}
+	context := event.Contexts["workflowai"]
+	if context["status_code"] != http.StatusTooManyRequests || len(context["prompt_hash"].(string)) != 12 {
+		t.Errorf("unexpected context %v", context)
+	}
+	if len(event.Exception) != 1 {
+		t.Fatalf("expected a single exception, got %d", len(event.Exception))
+	}
+	if message := event.Exception[0].Value; message != "workflowai: completion failed: rate_limit: slow down, key [REDACTED]" {
+		t.Errorf("unexpected exception %q", message)
+	}
+	var messages []string
+	for _, breadcrumb := range event.Breadcrumbs {
+		messages = append(messages, breadcrumb.Message)
+	}
+	if len(messages) != 4 || messages[2] != "completion started" || messages[3] != "completion failed" {
+		t.Errorf("unexpected breadcrumbs %v", messages)
+	}
+}
+
+func TestPromptHash(t *testing.T) {
+	a := PromptHash([]byte(`{"model":"a","messages":[{"role":"user","content":"hi"}]}`))
+	b := PromptHash([]byte(`{"model":"b","messages":[{"role":"user","content":"hi"}]}`))
+	c := PromptHash([]byte(`{"model":"a","messages":[{"role":"user","content":"hello"}]}`))
+	if a != b || a == c || len(a) != 12 {
+		t.Errorf("unexpected hashes %s %s %s", a, b, c)
+	}
+}
+
+func TestRegister_NilError(t *testing.T) {
+	transport := &memoryTransport{}
+	sentryClient, err := sentry.NewClient(sentry.ClientOptions{Dsn: "https://key@sentry.example.com/1", Transport: transport})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &reporter{hub: sentry.NewHub(sentryClient, sentry.NewScope())}
+	r.onError(context.Background(), workflowai.ErrorEvent{StatusCode: http.StatusOK})
+	if len(transport.events) != 1 || transport.events[0].Exception[0].Value != "workflowai: completion failed" {
+		t.Errorf("expected a generic exception, got %v", transport.events)
+	}
+}
@@ -0,0 +1,199 @@
+package workflowai
+
+import (
+	"context"
+	"log"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+	"github.com/openai/openai-go/packages/ssestream"
+)
+
+// ShadowRecord pairs a production completion with the completion of the
+// same request by the candidate model. Contents are not truncated.
+type ShadowRecord struct {
+	Primary RunRecord
+	Shadow  RunRecord
+}
+
+// Diff compares the outputs of the production and candidate completions.
+// Records of failed shadow requests differ from the production output.
+func (r ShadowRecord) Diff(opts ...DiffOption) *OutputDiff {
+	return DiffOutputs(r.Primary.Response, r.Shadow.Response, opts...)
+}
+
+// ShadowStore persists shadow records for offline comparison.
+type ShadowStore interface {
+	RecordShadow(ctx context.Context, record ShadowRecord) error
+}
+
+// ShadowRouter mirrors a fraction of the completions to a candidate model.
+// The shadow request is sent in the background once the primary completion
+// has been fully received, after the response was returned to the caller,
+// so it never affects the latency or the output seen by users. Shadow
+// requests are dropped when too many are already in flight.
+//
+// Shadow runs are tagged with the "shadow" metadata set to "true". They are
+// sent with the options of the router, see [WithShadowRequestOptions], not
+// with the options of the mirrored request, which can write to the caller's
+// values, e.g. [WithRunInfo], or run its hooks a second time.
+type ShadowRouter struct {
+	completions ChatService
+	model       string
+	store       ShadowStore
+	fraction    float64
+	timeout     time.Duration
+	onError     func(error)
+	opts        []option.RequestOption
+
+	sem chan struct{}
+	wg  sync.WaitGroup
+}
+
+var _ ChatService = (*ShadowRouter)(nil)
+
+type ShadowOption func(*ShadowRouter)
+
+// WithShadowFraction sets the fraction of requests, between 0 and 1, that
+// are mirrored. Defaults to 0.1.
+func WithShadowFraction(f float64) ShadowOption {
+	return func(r *ShadowRouter) {
+		r.fraction = f
+	}
+}
+
+// WithShadowConcurrency sets the maximum number of shadow requests in flight.
+// Defaults to 8.
+func WithShadowConcurrency(n int) ShadowOption {
+	return func(r *ShadowRouter) {
+		r.sem = make(chan struct{}, n)
+	}
+}
+
+// WithShadowTimeout sets the timeout of shadow requests. Defaults to 2 minutes.
+func WithShadowTimeout(d time.Duration) ShadowOption {
+	return func(r *ShadowRouter) {
+		r.timeout = d
+	}
+}
+
+// WithShadowErrorHandler is called when a record could not be persisted. By
+// default errors are logged with the standard logger.
+func WithShadowErrorHandler(fn func(error)) ShadowOption {
+	return func(r *ShadowRouter) {
+		r.onError = fn
+	}
+}
+
+// WithShadowRequestOptions sets the options of the shadow requests, e.g. the
+// API key of a sandbox.
+func WithShadowRequestOptions(opts ...option.RequestOption) ShadowOption {
+	return func(r *ShadowRouter) {
+		r.opts = opts
+	}
+}
+
+// NewShadowRouter creates a router that sends requests to completions and
+// mirrors some of them to model, e.g. "my-agent/#2/production".
+func NewShadowRouter(completions ChatService, model string, store ShadowStore, opts ...ShadowOption) *ShadowRouter {
+	r := &ShadowRouter{
+		completions: completions,
+		model:       model,
+		store:       store,
+		fraction:    0.1,
+		timeout:     2 * time.Minute,
+		onError: func(err error) {
+			log.Printf("workflowai: failed to record shadow run: %v", ScrubError(err))
+		},
+		sem: make(chan struct{}, 8),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Wait blocks until the shadow requests in flight are recorded, e.g. before
+// shutting down.
+func (r *ShadowRouter) Wait() {
+	r.wg.Wait()
+}
+
+// runCapture is a RunLogStore that keeps the last record.
+type runCapture struct {
+	mu     sync.Mutex
+	record RunRecord
+	onDone func(RunRecord)
+}
+
+func (c *runCapture) RecordRun(_ context.Context, record RunRecord) error {
+	c.mu.Lock()
+	c.record = record
+	c.mu.Unlock()
+	if c.onDone != nil {
+		c.onDone(record)
+	}
+	return nil
+}
+
+func (r *ShadowRouter) mirror(body openai.ChatCompletionNewParams, opts []option.RequestOption) []option.RequestOption {
+	if rand.Float64() >= r.fraction {
+		return opts
+	}
+	capture := &runCapture{onDone: func(primary RunRecord) {
+		if primary.Error != "" {
+			return
+		}
+		select {
+		case r.sem <- struct{}{}:
+		default:
+			// Too many shadow requests in flight
+			return
+		}
+		r.wg.Add(1)
+		go func() {
+			defer r.wg.Done()
+			defer func() { <-r.sem }()
+			r.shadow(body, primary)
+		}()
+	}}
+	// Options are copied so that appending to them does not alter the caller's
+	// options
+	opts = append([]option.RequestOption{}, opts...)
+	return append(opts, option.WithMiddleware(RunLogMiddleware(capture, WithRunLogContentLimit(0))))
+}
+
+func (r *ShadowRouter) shadow(body openai.ChatCompletionNewParams, primary RunRecord) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	body.Model = r.model
+	// Shadow requests are never streamed
+	body.StreamOptions = openai.ChatCompletionStreamOptionsParam{}
+	capture := &runCapture{}
+	opts := append(slices.Clip(r.opts),
+		option.WithJSONSet("metadata.shadow", "true"),
+		option.WithMiddleware(RunLogMiddleware(capture, WithRunLogContentLimit(0))),
+	)
+	// Errors are recorded by the capture
+	_, _ = r.completions.New(ctx, body, opts...)
+
+	capture.mu.Lock()
+	shadow := capture.record
+	capture.mu.Unlock()
+	if err := r.store.RecordShadow(ctx, ShadowRecord{Primary: primary, Shadow: shadow}); err != nil {
+		r.onError(err)
+	}
+}
+
+func (r *ShadowRouter) New(ctx context.Context, body openai.ChatCompletionNewParams, opts ...option.RequestOption) (*openai.ChatCompletion, error) {
+	return r.completions.New(ctx, body, r.mirror(body, opts)...)
+}
+
+func (r *ShadowRouter) NewStreaming(ctx context.Context, body openai.ChatCompletionNewParams, opts ...option.RequestOption) *ssestream.Stream[openai.ChatCompletionChunk] {
+	return r.completions.NewStreaming(ctx, body, r.mirror(body, opts)...)
+}
@@ -0,0 +1,98 @@
+package workflowai
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+type memoryShadowStore struct {
+	mu      sync.Mutex
+	records []ShadowRecord
+}
+
+func (m *memoryShadowStore) RecordShadow(_ context.Context, record ShadowRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.records = append(m.records, record)
+	return nil
+}
+
+func TestShadowRouter(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := readRequestBody(r)
+		w.Header().Set("Content-Type", "application/json")
+		if strings.Contains(string(body), `"shadow":"true"`) {
+			fmt.Fprint(w, strings.Replace(testCompletion, "Hello world", "Hello candidate", 1))
+			return
+		}
+		fmt.Fprint(w, testCompletion)
+	}))
+	defer server.Close()
+
+	client := openai.NewClient(option.WithBaseURL(server.URL+"/v1/"), option.WithAPIKey("key"))
+	store := &memoryShadowStore{}
+	router := NewShadowRouter(&client.Chat.Completions, "my-agent/#2/production", store, WithShadowFraction(1))
+
+	// The options of the caller only apply to its request
+	var calls atomic.Int32
+	callerOption := option.WithMiddleware(func(req *http.Request, next option.MiddlewareNext) (*http.Response, error) {
+		calls.Add(1)
+		return next(req)
+	})
+	completion, err := router.New(context.Background(), openai.ChatCompletionNewParams{
+		Model:    "my-agent/#1/production",
+		Messages: []openai.ChatCompletionMessageParamUnion{openai.UserMessage("Hello")},
+	}, callerOption)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if completion.Choices[0].Message.Content != "Hello world" {
+		t.Fatalf("the shadow response leaked to the caller: %q", completion.Choices[0].Message.Content)
+	}
+	router.Wait()
+
+	if len(store.records) != 1 {
+		t.Fatalf("expected 1 shadow record, got %d", len(store.records))
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("expected the caller's options not to be replayed by the shadow request, got %d calls", n)
+	}
+	record := store.records[0]
+	if record.Primary.Model != "my-agent/#1/production" || record.Primary.Response != "Hello world" {
+		t.Errorf("unexpected primary %+v", record.Primary)
+	}
+	if record.Shadow.Model != "my-agent/#2/production" || record.Shadow.Response != "Hello candidate" {
+		t.Errorf("unexpected shadow %+v", record.Shadow)
+	}
+	if diff := record.Diff(); diff.Equal() || diff.Similarity() != 0 {
+		t.Errorf("unexpected diff %v", diff)
+	}
+}
+
+func TestShadowRouter_Fraction(t *testing.T) {
+	server := newTestServer(t)
+	client := openai.NewClient(option.WithBaseURL(server.URL+"/v1/"), option.WithAPIKey("key"))
+	store := &memoryShadowStore{}
+	router := NewShadowRouter(&client.Chat.Completions, "candidate", store, WithShadowFraction(0))
+
+	_, err := router.New(context.Background(), openai.ChatCompletionNewParams{
+		Model:    "my-agent/gpt-4o",
+		Messages: []openai.ChatCompletionMessageParamUnion{openai.UserMessage("Hello")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	router.Wait()
+	if len(store.records) != 0 {
+		t.Fatalf("expected no shadow record, got %d", len(store.records))
+	}
+}
@@ -0,0 +1,144 @@
+// Package sqlrunlog stores workflowai.RunRecord values in a SQLite or Postgres
+// database through database/sql. The package does not import a driver, the
+// caller opens the *sql.DB with the driver of their choice.
+package sqlrunlog
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/workflowai/workflowai/go/examples/workflowai"
+)
+
+type Dialect int
+
+const (
+	SQLite Dialect = iota
+	Postgres
+)
+
+// Store is a workflowai.RunLogStore writing to a single table.
+type Store struct {
+	db      *sql.DB
+	dialect Dialect
+	table   string
+}
+
+var _ workflowai.RunLogStore = (*Store)(nil)
+
+type Option func(*Store)
+
+// WithTable sets the name of the table. Defaults to "workflowai_runs".
+func WithTable(table string) Option {
+	return func(s *Store) {
+		s.table = table
+	}
+}
+
+func New(db *sql.DB, dialect Dialect, opts ...Option) *Store {
+	s := &Store{db: db, dialect: dialect, table: "workflowai_runs"}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+var columns = []string{
+	"created_at",
+	"model",
+	"agent_id",
+	"run_id",
+	"version_id",
+	"cost_usd",
+	"latency_ms",
+	"prompt_tokens",
+	"completion_tokens",
+	"stream",
+	"status_code",
+	"error",
+	"request",
+	"response",
+}
+
+// Migrate creates the table and its indexes if they do not exist.
+func (s *Store) Migrate(ctx context.Context) error {
+	primaryKey := "id INTEGER PRIMARY KEY AUTOINCREMENT"
+	timestamp := "TIMESTAMP"
+	if s.dialect == Postgres {
+		primaryKey = "id BIGSERIAL PRIMARY KEY"
+		timestamp = "TIMESTAMPTZ"
+	}
+
+	statements := []string{
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	%s,
+	created_at %s NOT NULL,
+	model TEXT NOT NULL,
+	agent_id TEXT NOT NULL,
+	run_id TEXT NOT NULL,
+	version_id TEXT NOT NULL,
+	cost_usd DOUBLE PRECISION NOT NULL,
+	latency_ms BIGINT NOT NULL,
+	prompt_tokens BIGINT NOT NULL,
+	completion_tokens BIGINT NOT NULL,
+	stream BOOLEAN NOT NULL,
+	status_code INTEGER NOT NULL,
+	error TEXT NOT NULL,
+	request TEXT NOT NULL,
+	response TEXT NOT NULL
+)`, s.table, primaryKey, timestamp),
+		fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s_created_at_idx ON %s (created_at)", s.table, s.table),
+		fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s_run_id_idx ON %s (run_id)", s.table, s.table),
+	}
+	for _, statement := range statements {
+		if _, err := s.db.ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("sqlrunlog: migrating: %w", err)
+		}
+	}
+	return nil
+}
+
+func (s *Store) placeholders(n int) string {
+	p := make([]string, n)
+	for i := range p {
+		if s.dialect == Postgres {
+			p[i] = fmt.Sprintf("$%d", i+1)
+		} else {
+			p[i] = "?"
+		}
+	}
+	return strings.Join(p, ", ")
+}
+
+func (s *Store) RecordRun(ctx context.Context, r workflowai.RunRecord) error {
+	query := fmt.Sprintf(
+		"INSERT INTO %s (%s) VALUES (%s)",
+		s.table,
+		strings.Join(columns, ", "),
+		s.placeholders(len(columns)),
+	)
+	_, err := s.db.ExecContext(
+		ctx,
+		query,
+		r.CreatedAt.UTC(),
+		r.Model,
+		r.AgentID,
+		r.RunID,
+		r.VersionID,
+		r.CostUSD,
+		r.Latency.Milliseconds(),
+		r.PromptTokens,
+		r.CompletionTokens,
+		r.Stream,
+		r.StatusCode,
+		r.Error,
+		r.Request,
+		r.Response,
+	)
+	if err != nil {
+		return fmt.Errorf("sqlrunlog: inserting run: %w", err)
+	}
+	return nil
+}
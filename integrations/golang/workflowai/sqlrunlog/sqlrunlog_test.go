@@ -0,0 +1,63 @@
+package sqlrunlog
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	_ "modernc.org/sqlite"
+
+	"github.com/workflowai/workflowai/go/examples/workflowai"
+)
+
+func TestStore_SQLite(t *testing.T) {
+	ctx := context.Background()
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	store := New(db, SQLite)
+	if err := store.Migrate(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// Migrating twice is a no-op
+	if err := store.Migrate(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	err = store.RecordRun(ctx, workflowai.RunRecord{
+		CreatedAt:        time.Now(),
+		Model:            "my-agent/gpt-4o",
+		AgentID:          "my-agent",
+		RunID:            "run-1",
+		CostUSD:          0.5,
+		Latency:          1500 * time.Millisecond,
+		PromptTokens:     10,
+		CompletionTokens: 2,
+		StatusCode:       200,
+		Response:         "Hello",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var runID string
+	var latency int64
+	var cost float64
+	if err := db.QueryRowContext(ctx, "SELECT run_id, latency_ms, cost_usd FROM workflowai_runs").Scan(&runID, &latency, &cost); err != nil {
+		t.Fatal(err)
+	}
+	if runID != "run-1" || latency != 1500 || cost != 0.5 {
+		t.Fatalf("unexpected row: %s %d %f", runID, latency, cost)
+	}
+}
+
+func TestStore_PostgresPlaceholders(t *testing.T) {
+	store := New(nil, Postgres)
+	if got := store.placeholders(3); got != "$1, $2, $3" {
+		t.Fatalf("unexpected placeholders %s", got)
+	}
+}
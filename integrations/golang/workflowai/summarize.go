@@ -0,0 +1,220 @@
+package workflowai
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/workflowai/workflowai/go/examples/workflowai/textsplit"
+)
+
+// Metadata keys of the runs of a [Summarizer].
+const (
+	MetadataSummaryLevel = "summary_level"
+	MetadataSummaryChunk = "summary_chunk"
+)
+
+// SummaryInput is the input of the agents of a [Summarizer].
+type SummaryInput struct {
+	Text string `json:"text"`
+}
+
+// SummaryProgress reports the progress of a summary after each run.
+type SummaryProgress struct {
+	// Level is 0 while summarizing the chunks, then the level of the reduce
+	Level int
+	Done  int
+	Total int
+}
+
+// Summary is the result of [Summarizer.Summarize].
+type Summary struct {
+	Text string
+	// Chunks is the number of chunks of the document
+	Chunks int
+	// Levels is the number of reduce levels, 0 when the document fits in a
+	// chunk
+	Levels  int
+	RunIDs  []string
+	CostUSD float64
+}
+
+// Summarizer summarizes documents larger than the context window of a model:
+// the document is split into chunks, the chunks are summarized in parallel,
+// then the summaries are combined in groups fitting the reduce budget, level
+// after level, until one summary is left.
+//
+// The chunk boundaries only depend on the document and the chunk budget, so
+// that summarizing the same document twice sends the same requests.
+type Summarizer struct {
+	// Map summarizes a chunk
+	Map *Agent[SummaryInput, string]
+	// Reduce combines the summaries of consecutive parts of the document
+	Reduce *Agent[SummaryInput, string]
+
+	splitter     *textsplit.Splitter
+	reduceTokens int
+	concurrency  int
+	progress     func(SummaryProgress)
+}
+
+type SummarizerOption func(*Summarizer)
+
+// WithChunkTokens sets the maximum number of tokens of a chunk, 4000 by
+// default.
+func WithChunkTokens(tokens int) SummarizerOption {
+	return func(s *Summarizer) {
+		s.splitter = textsplit.Sentence(tokens)
+	}
+}
+
+// WithSummarySplitter splits the documents into chunks with splitter, e.g.
+// [textsplit.Markdown] or a splitter counting the tokens with the tokenizer
+// of the model, instead of a [textsplit.Sentence] splitter. The reduce
+// budget is counted by the splitter.
+func WithSummarySplitter(splitter *textsplit.Splitter) SummarizerOption {
+	return func(s *Summarizer) {
+		s.splitter = splitter
+	}
+}
+
+// WithReduceTokens sets the maximum number of tokens of the summaries
+// combined by a reduce run, 8000 by default. A reduce run combines at least
+// two summaries.
+func WithReduceTokens(tokens int) SummarizerOption {
+	return func(s *Summarizer) {
+		s.reduceTokens = tokens
+	}
+}
+
+// WithSummaryConcurrency sets the maximum number of concurrent runs, 8 by
+// default.
+func WithSummaryConcurrency(n int) SummarizerOption {
+	return func(s *Summarizer) {
+		s.concurrency = n
+	}
+}
+
+// WithSummaryProgress calls fn after each run. The calls are serialized.
+func WithSummaryProgress(fn func(SummaryProgress)) SummarizerOption {
+	return func(s *Summarizer) {
+		s.progress = fn
+	}
+}
+
+// NewSummarizer returns a summarizer running the agent id with model. The
+// instructions of its Map and Reduce agents can be changed, they render the
+// {{text}} variable.
+func NewSummarizer(completions ChatCompleter, id string, model string, opts ...SummarizerOption) *Summarizer {
+	s := &Summarizer{
+		Map: &Agent[SummaryInput, string]{ID: id, Model: model, completions: completions,
+			Instructions: "Summarize the following part of a longer document. Keep the names, figures and conclusions.\n\n{{text}}"},
+		Reduce: &Agent[SummaryInput, string]{ID: id, Model: model, completions: completions,
+			Instructions: "Combine the following summaries of consecutive parts of a document into a single summary. Keep the names, figures and conclusions.\n\n{{text}}"},
+		splitter:     textsplit.Sentence(4000),
+		reduceTokens: 8000,
+		concurrency:  8,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Summarize summarizes text. The runs are tagged with their level
+// ("summary_level") and the index of their chunk or group
+// ("summary_chunk"). The first error cancels the other runs.
+func (s *Summarizer) Summarize(ctx context.Context, text string) (*Summary, error) {
+	chunks := s.splitter.Split(text)
+	summary := &Summary{Chunks: len(chunks)}
+	if len(chunks) == 0 {
+		return summary, nil
+	}
+	summaries, err := s.level(ctx, summary, 0, s.Map, chunks)
+	if err != nil {
+		return summary, err
+	}
+	for len(summaries) > 1 {
+		summary.Levels++
+		groups := groupTokens(summaries, s.reduceTokens, s.splitter.Tokens)
+		if summaries, err = s.level(ctx, summary, summary.Levels, s.Reduce, groups); err != nil {
+			return summary, err
+		}
+	}
+	summary.Text = summaries[0]
+	return summary, nil
+}
+
+// level runs agent on each text.
+func (s *Summarizer) level(ctx context.Context, summary *Summary, level int, agent *Agent[SummaryInput, string], texts []string) ([]string, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	out := make([]string, len(texts))
+	sem := make(chan struct{}, max(s.concurrency, 1))
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var firstErr error
+	done := 0
+	for i, text := range texts {
+		sem <- struct{}{}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			runCtx := ContextWithRunMetadata(ctx, map[string]string{
+				MetadataSummaryLevel: strconv.Itoa(level),
+				MetadataSummaryChunk: strconv.Itoa(i),
+			})
+			run, err := agent.Run(runCtx, SummaryInput{Text: text})
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+					cancel()
+				}
+				return
+			}
+			out[i] = strings.TrimSpace(run.Output)
+			summary.RunIDs = append(summary.RunIDs, run.RunID())
+			summary.CostUSD += run.CostUSD()
+			done++
+			if s.progress != nil {
+				s.progress(SummaryProgress{Level: level, Done: done, Total: len(texts)})
+			}
+		}()
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// groupTokens joins consecutive summaries into groups of at most maxTokens,
+// and at least two summaries so that each level reduces the number of
+// summaries.
+func groupTokens(summaries []string, maxTokens int, count func(string) int) []string {
+	var groups []string
+	for start := 0; start < len(summaries); {
+		end, tokens := start, 0
+		for end < len(summaries) && (end-start < 2 || tokens+count(summaries[end]) <= maxTokens) {
+			tokens += count(summaries[end])
+			end++
+		}
+		if end == len(summaries)-1 {
+			// Do not leave the last summary alone
+			end++
+		}
+		groups = append(groups, strings.Join(summaries[start:end], "\n\n"))
+		start = end
+	}
+	return groups
+}
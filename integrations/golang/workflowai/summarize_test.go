@@ -0,0 +1,89 @@
+package workflowai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/openai/openai-go/option"
+	"github.com/workflowai/workflowai/go/examples/workflowai/textsplit"
+)
+
+func TestSummarizer(t *testing.T) {
+	var mu sync.Mutex
+	var levels []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Input    SummaryInput      `json:"input"`
+			Metadata map[string]string `json:"metadata"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		mu.Lock()
+		levels = append(levels, body.Metadata[MetadataSummaryLevel])
+		mu.Unlock()
+		// The summary of a text is its first word
+		summary := strings.Fields(body.Input.Text)[0]
+		if strings.Contains(body.Input.Text, "poison") {
+			http.Error(w, `{"error": {"message": "boom"}}`, http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{
+			"id":      "summarizer/run-1",
+			"choices": []any{map[string]any{"index": 0, "cost_usd": 0.001, "message": map[string]any{"role": "assistant", "content": summary}}},
+		})
+	}))
+	defer server.Close()
+	client := NewClient(option.WithBaseURL(server.URL+"/v1/"), option.WithAPIKey("test"), option.WithMaxRetries(0))
+
+	var paragraphs []string
+	for i := range 20 {
+		paragraphs = append(paragraphs, fmt.Sprintf("p%02d %s", i, strings.Repeat("word ", 15)))
+	}
+	document := strings.Join(paragraphs, "\n\n")
+
+	var progress []SummaryProgress
+	summarizer := NewSummarizer(&client.Chat.Completions, "summarizer", "gpt-4o-mini-latest",
+		WithChunkTokens(25), WithReduceTokens(3), WithSummaryConcurrency(3),
+		WithSummaryProgress(func(p SummaryProgress) { progress = append(progress, p) }))
+	summary, err := summarizer.Summarize(context.Background(), document)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Each paragraph is a chunk, reduced by groups of 3 tokens (20, 7, 2, 1)
+	if summary.Text != "p00" || summary.Chunks != 20 || summary.Levels != 3 {
+		t.Errorf("summary = %+v", summary)
+	}
+	if len(summary.RunIDs) != 30 || len(progress) != 30 || len(levels) != 30 {
+		t.Errorf("%d runs, %d progress calls, %d requests", len(summary.RunIDs), len(progress), len(levels))
+	}
+	if last := progress[len(progress)-1]; last != (SummaryProgress{Level: 3, Done: 1, Total: 1}) {
+		t.Errorf("last progress = %+v", last)
+	}
+	if levels[0] != "0" || levels[len(levels)-1] != "3" {
+		t.Errorf("levels = %v", levels)
+	}
+
+	if _, err := summarizer.Summarize(context.Background(), document+"\n\npoison"); err == nil {
+		t.Error("expected an error")
+	}
+
+	single, err := summarizer.Summarize(context.Background(), "short")
+	if err != nil || single.Text != "short" || single.Levels != 0 {
+		t.Errorf("single chunk = %+v, %v", single, err)
+	}
+}
+
+func TestGroupTokens(t *testing.T) {
+	if got := groupTokens([]string{"a", "b", "c"}, 1, textsplit.EstimateTokens); len(got) != 1 {
+		t.Errorf("groups = %q", got)
+	}
+	if got := groupTokens([]string{"a", "b", "c", "d"}, 2, textsplit.EstimateTokens); !reflect.DeepEqual(got, []string{"a\n\nb", "c\n\nd"}) {
+		t.Errorf("groups = %q", got)
+	}
+}
@@ -0,0 +1,274 @@
+// Package textsplit splits documents into chunks of a maximum number of
+// tokens, for retrieval and summarization.
+//
+//	splitter := textsplit.Markdown(300, textsplit.WithOverlap(50))
+//	for _, chunk := range splitter.Split(doc) {
+//		...
+//	}
+//
+// The splitters cut the text at the coarsest boundaries keeping the chunks
+// within the budget: headings then paragraphs for Markdown, top-level
+// declarations then blank lines for code, paragraphs then sentences for
+// prose, and words as a last resort. The chunks only depend on the text and
+// the options, so splitting a document twice gives the same chunks.
+package textsplit
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// EstimateTokens estimates the number of tokens of text, about 4 characters
+// per token for English text. It is the default token counter of the
+// splitters.
+func EstimateTokens(text string) int {
+	return (utf8.RuneCountInString(text) + 3) / 4
+}
+
+// Splitter splits texts into chunks of at most the chunk size, in tokens.
+type Splitter struct {
+	size    int
+	overlap int
+	count   func(string) int
+	// cuts are the boundaries the text is cut at, from the coarsest
+	cuts []func(string) []string
+}
+
+type Option func(*Splitter)
+
+// WithOverlap starts each chunk with up to tokens tokens of the end of the
+// previous chunk, at the same boundaries as the chunks.
+func WithOverlap(tokens int) Option {
+	return func(s *Splitter) {
+		s.overlap = tokens
+	}
+}
+
+// WithTokenCounter counts the tokens with count, e.g. the tokenizer of the
+// model, instead of [EstimateTokens].
+func WithTokenCounter(count func(string) int) Option {
+	return func(s *Splitter) {
+		s.count = count
+	}
+}
+
+func newSplitter(size int, opts []Option, cuts ...func(string) []string) *Splitter {
+	s := &Splitter{size: max(size, 1), count: EstimateTokens, cuts: append(cuts, cutWords)}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Fixed returns a splitter filling chunks of size tokens with words,
+// regardless of the structure of the text.
+func Fixed(size int, opts ...Option) *Splitter {
+	return newSplitter(size, opts)
+}
+
+// Sentence returns a splitter cutting prose at paragraphs, lines then
+// sentences.
+func Sentence(size int, opts ...Option) *Splitter {
+	return newSplitter(size, opts, cutAfter("\n\n"), cutAfter("\n"), cutSentences)
+}
+
+// Markdown returns a splitter cutting Markdown before headings, then at
+// paragraphs, lines and sentences. Headings in code blocks are ignored.
+func Markdown(size int, opts ...Option) *Splitter {
+	return newSplitter(size, opts, cutHeadings, cutAfter("\n\n"), cutAfter("\n"), cutSentences)
+}
+
+// Code returns a splitter cutting source code before top-level declarations,
+// i.e. unindented lines following a blank line, then at blank lines and
+// lines.
+func Code(size int, opts ...Option) *Splitter {
+	return newSplitter(size, opts, cutDeclarations, cutAfter("\n\n"), cutAfter("\n"))
+}
+
+// Tokens returns the number of tokens of text counted by the splitter.
+func (s *Splitter) Tokens(text string) int {
+	return s.count(text)
+}
+
+// Split splits text into chunks, trimmed of their surrounding spaces.
+func (s *Splitter) Split(text string) []string {
+	var chunks []string
+	for _, chunk := range s.split(text, 0) {
+		if chunk = strings.TrimSpace(chunk); chunk != "" {
+			chunks = append(chunks, chunk)
+		}
+	}
+	return chunks
+}
+
+// split cuts text at the boundaries of level, merges the parts into chunks,
+// and splits the parts larger than a chunk at the next level.
+func (s *Splitter) split(text string, level int) []string {
+	if s.count(text) <= s.size {
+		return []string{text}
+	}
+	if level == len(s.cuts) {
+		return s.cutRunes(text)
+	}
+	var chunks, parts []string
+	for _, part := range s.cuts[level](text) {
+		if s.count(part) <= s.size {
+			parts = append(parts, part)
+			continue
+		}
+		chunks = append(chunks, s.merge(parts)...)
+		parts = nil
+		chunks = append(chunks, s.split(part, level+1)...)
+	}
+	return append(chunks, s.merge(parts)...)
+}
+
+// merge joins consecutive parts into chunks of at most the chunk size.
+func (s *Splitter) merge(parts []string) []string {
+	var chunks, current []string
+	// fresh counts the parts of current that are not an overlap
+	fresh := 0
+	for _, part := range parts {
+		if fresh > 0 && s.count(strings.Join(current, "")+part) > s.size {
+			chunks = append(chunks, strings.Join(current, ""))
+			keep := 0
+			for keep < len(current)-1 {
+				overlap := strings.Join(current[len(current)-keep-1:], "")
+				if s.count(overlap) > s.overlap || s.count(overlap+part) > s.size {
+					break
+				}
+				keep++
+			}
+			current, fresh = append([]string(nil), current[len(current)-keep:]...), 0
+		}
+		current = append(current, part)
+		if strings.TrimSpace(part) != "" {
+			fresh++
+		}
+	}
+	if fresh > 0 {
+		chunks = append(chunks, strings.Join(current, ""))
+	}
+	return chunks
+}
+
+// cutRunes cuts a text without boundaries, e.g. a very long word, into
+// pieces of at most the chunk size.
+func (s *Splitter) cutRunes(text string) []string {
+	runes := []rune(text)
+	var pieces []string
+	for len(runes) > 0 {
+		end := min(len(runes), s.size*4)
+		for end > 1 && s.count(string(runes[:end])) > s.size {
+			end /= 2
+		}
+		pieces = append(pieces, string(runes[:end]))
+		runes = runes[end:]
+	}
+	return pieces
+}
+
+// cutAt cuts text at the byte offsets.
+func cutAt(text string, offsets []int) []string {
+	var parts []string
+	start := 0
+	for _, offset := range offsets {
+		if offset > start && offset < len(text) {
+			parts = append(parts, text[start:offset])
+			start = offset
+		}
+	}
+	return append(parts, text[start:])
+}
+
+func cutAfter(sep string) func(string) []string {
+	return func(text string) []string {
+		var offsets []int
+		for i := 0; ; {
+			j := strings.Index(text[i:], sep)
+			if j < 0 {
+				break
+			}
+			i += j + len(sep)
+			offsets = append(offsets, i)
+		}
+		return cutAt(text, offsets)
+	}
+}
+
+var (
+	sentenceEnd = regexp.MustCompile(`[.!?]+["')\]]*\s+`)
+	wordEnd     = regexp.MustCompile(`\s+`)
+)
+
+func cutRegexp(re *regexp.Regexp, text string) []string {
+	var offsets []int
+	for _, match := range re.FindAllStringIndex(text, -1) {
+		offsets = append(offsets, match[1])
+	}
+	return cutAt(text, offsets)
+}
+
+func cutSentences(text string) []string {
+	return cutRegexp(sentenceEnd, text)
+}
+
+func cutWords(text string) []string {
+	return cutRegexp(wordEnd, text)
+}
+
+// lineOffsets returns the offsets of the lines of text.
+func lineOffsets(text string) []int {
+	offsets := []int{0}
+	for i, c := range text {
+		if c == '\n' && i+1 < len(text) {
+			offsets = append(offsets, i+1)
+		}
+	}
+	return offsets
+}
+
+func line(text string, offset int) string {
+	if end := strings.IndexByte(text[offset:], '\n'); end >= 0 {
+		return text[offset : offset+end]
+	}
+	return text[offset:]
+}
+
+var heading = regexp.MustCompile(`^#{1,6}\s`)
+
+func cutHeadings(text string) []string {
+	var offsets []int
+	fenced := false
+	for _, offset := range lineOffsets(text) {
+		l := line(text, offset)
+		if strings.HasPrefix(strings.TrimSpace(l), "```") {
+			fenced = !fenced
+		}
+		if !fenced && heading.MatchString(l) {
+			offsets = append(offsets, offset)
+		}
+	}
+	return cutAt(text, offsets)
+}
+
+func cutDeclarations(text string) []string {
+	var offsets []int
+	blank := false
+	for _, offset := range lineOffsets(text) {
+		l := line(text, offset)
+		if strings.TrimSpace(l) == "" {
+			blank = true
+			continue
+		}
+		first, _ := utf8.DecodeRuneInString(l)
+		// Closing braces end the previous declaration
+		if blank && !unicode.IsSpace(first) && first != '}' && first != ')' {
+			offsets = append(offsets, offset)
+		}
+		blank = false
+	}
+	return cutAt(text, offsets)
+}
@@ -0,0 +1,70 @@
+package textsplit
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSentence(t *testing.T) {
+	text := "First sentence here. Second sentence here.\n\nAnother paragraph that is long enough."
+	chunks := Sentence(8).Split(text)
+	want := []string{"First sentence here.", "Second sentence here.", "Another paragraph that is long", "enough."}
+	if !reflect.DeepEqual(chunks, want) {
+		t.Errorf("chunks = %q", chunks)
+	}
+	if !reflect.DeepEqual(Sentence(8).Split(text), chunks) {
+		t.Error("the chunks are not deterministic")
+	}
+	if got := Sentence(100).Split(text); len(got) != 1 || got[0] != text {
+		t.Errorf("small text = %q", got)
+	}
+}
+
+func TestOverlap(t *testing.T) {
+	words := strings.Fields("one two three four five six seven eight nine ten")
+	count := func(s string) int { return len(strings.Fields(s)) }
+	chunks := Fixed(4, WithOverlap(1), WithTokenCounter(count)).Split(strings.Join(words, " "))
+	want := []string{"one two three four", "four five six seven", "seven eight nine ten"}
+	if !reflect.DeepEqual(chunks, want) {
+		t.Errorf("chunks = %q", chunks)
+	}
+	for _, chunk := range Fixed(4, WithOverlap(10), WithTokenCounter(count)).Split(strings.Join(words, " ")) {
+		if count(chunk) > 4 {
+			t.Errorf("chunk %q is larger than 4 tokens", chunk)
+		}
+	}
+}
+
+func TestMarkdown(t *testing.T) {
+	text := "# Install\n\nRun the installer.\n\n```sh\n# not a heading\nmake install\n```\n\n## Configure\n\nSet the API key.\n\n# Usage\n\nCall the API."
+	count := func(s string) int { return len(strings.Fields(s)) }
+	chunks := Markdown(14, WithTokenCounter(count)).Split(text)
+	want := []string{
+		"# Install\n\nRun the installer.\n\n```sh\n# not a heading\nmake install\n```",
+		"## Configure\n\nSet the API key.\n\n# Usage\n\nCall the API.",
+	}
+	if !reflect.DeepEqual(chunks, want) {
+		t.Errorf("chunks = %q", chunks)
+	}
+}
+
+func TestCode(t *testing.T) {
+	text := "package main\n\n// add adds\nfunc add(a, b int) int {\n\n\treturn a + b\n}\n\nfunc sub(a, b int) int {\n\treturn a - b\n}\n"
+	chunks := Code(16).Split(text)
+	want := []string{
+		"package main",
+		"// add adds\nfunc add(a, b int) int {\n\n\treturn a + b\n}",
+		"func sub(a, b int) int {\n\treturn a - b\n}",
+	}
+	if !reflect.DeepEqual(chunks, want) {
+		t.Errorf("chunks = %q", chunks)
+	}
+}
+
+func TestLongWord(t *testing.T) {
+	chunks := Fixed(2).Split(strings.Repeat("x", 20))
+	if len(chunks) != 3 || chunks[0] != "xxxxxxxx" {
+		t.Errorf("chunks = %q", chunks)
+	}
+}
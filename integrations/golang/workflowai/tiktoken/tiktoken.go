@@ -0,0 +1,91 @@
+// Package tiktoken provides the tokenizers of the OpenAI models, to count
+// tokens exactly.
+//
+//	tokenizer, err := tiktoken.ForModel("my-agent/gpt-4o-mini-latest")
+//	splitter := textsplit.Sentence(500, textsplit.WithTokenCounter(tokenizer.Count))
+//
+// The encodings are downloaded on first use and cached in the directory of
+// the TIKTOKEN_CACHE_DIR environment variable, or the temporary directory.
+package tiktoken
+
+import (
+	"fmt"
+	"strings"
+
+	tiktoken "github.com/pkoukk/tiktoken-go"
+)
+
+// Tokenizer encodes text with the encoding of a model.
+type Tokenizer struct {
+	// Encoding is the name of the encoding, e.g. "o200k_base"
+	Encoding string
+	codec    *tiktoken.Tiktoken
+}
+
+// encodings are the encodings of the model families, by prefix. The longest
+// prefix wins.
+var encodings = map[string]string{
+	"gpt-4o":        "o200k_base",
+	"gpt-4.1":       "o200k_base",
+	"gpt-4.5":       "o200k_base",
+	"gpt-5":         "o200k_base",
+	"o1":            "o200k_base",
+	"o3":            "o200k_base",
+	"o4":            "o200k_base",
+	"gpt-4":         "cl100k_base",
+	"gpt-3.5-turbo": "cl100k_base",
+}
+
+// ForModel returns the tokenizer of a model. The agent of WorkflowAI models is
+// ignored, e.g. "my-agent/gpt-4o-mini-latest" uses the tokenizer of
+// "gpt-4o-mini-latest". Deployments, whose model is only known by the
+// server, and models of other providers, whose tokenizers are not public,
+// return an error.
+func ForModel(model string) (*Tokenizer, error) {
+	encoding, err := encodingFor(model)
+	if err != nil {
+		return nil, err
+	}
+	return ForEncoding(encoding)
+}
+
+func encodingFor(model string) (string, error) {
+	if strings.Contains(model, "#") {
+		return "", fmt.Errorf("tiktoken: the model of deployment %s is unknown, use the model it deploys", model)
+	}
+	id := model[strings.LastIndex(model, "/")+1:]
+	best := ""
+	for prefix := range encodings {
+		if strings.HasPrefix(id, prefix) && len(prefix) > len(best) {
+			best = prefix
+		}
+	}
+	if best == "" {
+		return "", fmt.Errorf("tiktoken: no tokenizer for %s", model)
+	}
+	return encodings[best], nil
+}
+
+// ForEncoding returns the tokenizer of an encoding, e.g. "cl100k_base".
+func ForEncoding(encoding string) (*Tokenizer, error) {
+	codec, err := tiktoken.GetEncoding(encoding)
+	if err != nil {
+		return nil, fmt.Errorf("tiktoken: loading %s: %w", encoding, err)
+	}
+	return &Tokenizer{Encoding: encoding, codec: codec}, nil
+}
+
+// Encode returns the token IDs of text. Special tokens are encoded as text.
+func (t *Tokenizer) Encode(text string) []int64 {
+	ids := t.codec.EncodeOrdinary(text)
+	tokens := make([]int64, len(ids))
+	for i, id := range ids {
+		tokens[i] = int64(id)
+	}
+	return tokens
+}
+
+// Count returns the number of tokens of text.
+func (t *Tokenizer) Count(text string) int {
+	return len(t.codec.EncodeOrdinary(text))
+}
@@ -0,0 +1,22 @@
+package tiktoken
+
+import "testing"
+
+func TestEncodingFor(t *testing.T) {
+	for model, want := range map[string]string{
+		"my-agent/gpt-4o-mini-latest": "o200k_base",
+		"gpt-4.1-nano":                "o200k_base",
+		"o3-mini":                     "o200k_base",
+		"my-agent/gpt-4-turbo":        "cl100k_base",
+		"gpt-3.5-turbo-0125":          "cl100k_base",
+	} {
+		if got, err := encodingFor(model); err != nil || got != want {
+			t.Errorf("%s: %s, %v", model, got, err)
+		}
+	}
+	for _, model := range []string{"my-agent/#1/production", "claude-3-7-sonnet-latest"} {
+		if _, err := encodingFor(model); err == nil {
+			t.Errorf("%s: expected an error", model)
+		}
+	}
+}
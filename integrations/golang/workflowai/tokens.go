@@ -0,0 +1,350 @@
+package workflowai
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"image"
+	"math"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/openai/openai-go"
+
+	"github.com/workflowai/workflowai/go/examples/workflowai/textsplit"
+)
+
+// Kinds of the parts of a [TokenBreakdown].
+const (
+	TokenPartMessage        = "message"
+	TokenPartTool           = "tool"
+	TokenPartResponseFormat = "response_format"
+)
+
+// Token overheads of the chat format, as documented by OpenAI: each message
+// is wrapped in 3 tokens, a name adds 1 token and the reply is primed with 3
+// tokens.
+const (
+	messageTokens      = 3
+	messageNameTokens  = 1
+	replyPrimingTokens = 3
+	// defaultImageTokens are the tokens of a high detail image of unknown
+	// size, as for a 1024x1024 image
+	defaultImageTokens = 765
+	// audioTokensPerSecond are the tokens of a second of input audio, of
+	// about 1 token per 100ms for the OpenAI audio models
+	audioTokensPerSecond = 10
+	// mp3BytesPerSecond is the size of a second of 128 kbps MP3
+	mp3BytesPerSecond = 16000
+	// pdfPageTokens are the tokens of a PDF page, sent as its text and an
+	// image of the page
+	pdfPageTokens = 500 + defaultImageTokens
+	// binaryBytesPerToken estimates the tokens of files of other types
+	binaryBytesPerToken = 4
+)
+
+// TokenPart is the estimated usage of a part of a request.
+type TokenPart struct {
+	// Kind is [TokenPartMessage], [TokenPartTool] or [TokenPartResponseFormat]
+	Kind string
+	// Index is the index of the message or of the tool in the request
+	Index int
+	// Name is the role of a message, e.g. "user", or the name of a tool
+	Name   string
+	Tokens int
+	// ImageTokens are the tokens of the images of a message, included in
+	// Tokens
+	ImageTokens int
+	// AudioTokens are the tokens of the audio of a message, estimated from
+	// its duration, and FileTokens the tokens of its files, both included
+	// in Tokens
+	AudioTokens int
+	FileTokens  int
+}
+
+// TokenBreakdown is the estimated token usage of a request, per message and
+// per tool definition.
+type TokenBreakdown struct {
+	Parts []TokenPart
+	// Total is the sum of the parts and of the overheads of the chat format
+	Total int
+}
+
+// Largest returns the n parts using the most tokens, the largest first, and
+// none when n <= 0.
+func (b *TokenBreakdown) Largest(n int) []TokenPart {
+	parts := slices.Clone(b.Parts)
+	slices.SortStableFunc(parts, func(a, b TokenPart) int { return b.Tokens - a.Tokens })
+	return parts[:min(max(n, 0), len(parts))]
+}
+
+// Share returns the share of the total of a part.
+func (b *TokenBreakdown) Share(p TokenPart) float64 {
+	if b.Total == 0 {
+		return 0
+	}
+	return float64(p.Tokens) / float64(b.Total)
+}
+
+// String returns a table of the parts, e.g. to log it.
+func (b *TokenBreakdown) String() string {
+	var s strings.Builder
+	for _, p := range b.Parts {
+		fmt.Fprintf(&s, "%-15s %3d %-20s %7d %5.1f%%\n", p.Kind, p.Index, p.Name, p.Tokens, 100*b.Share(p))
+	}
+	fmt.Fprintf(&s, "%-40s %7d\n", "total", b.Total)
+	return s.String()
+}
+
+// BreakdownTokens estimates the input tokens of a request per message and per
+// tool definition, to find the parts of a prompt filling the context window.
+// count counts the tokens of a text, e.g. the Count method of a
+// workflowai/tiktoken tokenizer, and defaults to [textsplit.EstimateTokens].
+//
+// The tokens of the tool definitions and of the response format are counted
+// on their JSON, while providers render them in their own formats, so their
+// counts are approximate.
+func BreakdownTokens(params openai.ChatCompletionNewParams, count func(string) int) (*TokenBreakdown, error) {
+	if count == nil {
+		count = textsplit.EstimateTokens
+	}
+	b := &TokenBreakdown{Total: replyPrimingTokens}
+	for i, m := range params.Messages {
+		part, err := messageTokenPart(m, count)
+		if err != nil {
+			return nil, fmt.Errorf("workflowai: counting the tokens of message %d: %w", i, err)
+		}
+		part.Index = i
+		b.Parts = append(b.Parts, part)
+	}
+	for i, tool := range params.Tools {
+		definition, err := json.Marshal(tool.Function)
+		if err != nil {
+			return nil, fmt.Errorf("workflowai: counting the tokens of tool %d: %w", i, err)
+		}
+		b.Parts = append(b.Parts, TokenPart{Kind: TokenPartTool, Index: i, Name: tool.Function.Name, Tokens: count(string(definition))})
+	}
+	if schema := params.ResponseFormat.OfJSONSchema; schema != nil {
+		definition, err := json.Marshal(schema.JSONSchema)
+		if err != nil {
+			return nil, fmt.Errorf("workflowai: counting the tokens of the response format: %w", err)
+		}
+		b.Parts = append(b.Parts, TokenPart{Kind: TokenPartResponseFormat, Name: schema.JSONSchema.Name, Tokens: count(string(definition))})
+	}
+	for _, p := range b.Parts {
+		b.Total += p.Tokens
+	}
+	return b, nil
+}
+
+// countedMessage is the union of the fields of the messages of every role,
+// decoded from their JSON.
+type countedMessage struct {
+	Role      string          `json:"role"`
+	Name      string          `json:"name"`
+	Content   json.RawMessage `json:"content"`
+	ToolCalls []struct {
+		Function struct {
+			Name      string `json:"name"`
+			Arguments string `json:"arguments"`
+		} `json:"function"`
+	} `json:"tool_calls"`
+}
+
+type countedPart struct {
+	Type     string `json:"type"`
+	Text     string `json:"text"`
+	Refusal  string `json:"refusal"`
+	ImageURL struct {
+		URL    string `json:"url"`
+		Detail string `json:"detail"`
+	} `json:"image_url"`
+	InputAudio struct {
+		Data   string `json:"data"`
+		Format string `json:"format"`
+	} `json:"input_audio"`
+	File struct {
+		FileData string `json:"file_data"`
+	} `json:"file"`
+}
+
+// requestTokens estimates the input tokens of the JSON body of a chat
+// completion request like [BreakdownTokens], for the middlewares reading the
+// requests: the images, audio and files are counted from their content, not
+// as the text of their base64.
+func requestTokens(body []byte, count func(string) int) (int, error) {
+	var request struct {
+		Messages []json.RawMessage `json:"messages"`
+		Tools    []struct {
+			Function json.RawMessage `json:"function"`
+		} `json:"tools"`
+		ResponseFormat struct {
+			JSONSchema json.RawMessage `json:"json_schema"`
+		} `json:"response_format"`
+		// The input variables of WorkflowAI, rendered in the messages
+		Input json.RawMessage `json:"input"`
+	}
+	if err := json.Unmarshal(body, &request); err != nil {
+		return 0, err
+	}
+	total := replyPrimingTokens
+	for _, m := range request.Messages {
+		part, err := rawMessageTokenPart(m, count)
+		if err != nil {
+			return 0, err
+		}
+		total += part.Tokens
+	}
+	for _, tool := range request.Tools {
+		total += count(string(tool.Function))
+	}
+	if len(request.ResponseFormat.JSONSchema) > 0 {
+		total += count(string(request.ResponseFormat.JSONSchema))
+	}
+	if len(request.Input) > 0 {
+		total += count(string(request.Input))
+	}
+	return total, nil
+}
+
+func messageTokenPart(m openai.ChatCompletionMessageParamUnion, count func(string) int) (TokenPart, error) {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return TokenPart{}, err
+	}
+	return rawMessageTokenPart(data, count)
+}
+
+// rawMessageTokenPart estimates the tokens of the JSON of a message.
+func rawMessageTokenPart(data []byte, count func(string) int) (TokenPart, error) {
+	var msg countedMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return TokenPart{}, err
+	}
+	part := TokenPart{Kind: TokenPartMessage, Name: msg.Role, Tokens: messageTokens + count(msg.Role)}
+	if msg.Name != "" {
+		part.Tokens += messageNameTokens + count(msg.Name)
+	}
+	for _, call := range msg.ToolCalls {
+		part.Tokens += count(call.Function.Name) + count(call.Function.Arguments)
+	}
+	var text string
+	var parts []countedPart
+	switch {
+	case len(msg.Content) == 0 || string(msg.Content) == "null":
+	case json.Unmarshal(msg.Content, &text) == nil:
+		part.Tokens += count(text)
+	case json.Unmarshal(msg.Content, &parts) == nil:
+		for _, p := range parts {
+			switch p.Type {
+			case "image_url":
+				if documentDataURL(p.ImageURL.URL) {
+					tokens := fileTokens(p.ImageURL.URL, count)
+					part.Tokens += tokens
+					part.FileTokens += tokens
+					break
+				}
+				tokens := imageURLTokens(p.ImageURL.URL, p.ImageURL.Detail)
+				part.Tokens += tokens
+				part.ImageTokens += tokens
+			case "input_audio":
+				tokens := audioTokens(p.InputAudio.Data, p.InputAudio.Format)
+				part.Tokens += tokens
+				part.AudioTokens += tokens
+			case "file":
+				tokens := fileTokens(p.File.FileData, count)
+				part.Tokens += tokens
+				part.FileTokens += tokens
+			default:
+				part.Tokens += count(p.Text) + count(p.Refusal)
+			}
+		}
+	default:
+		return TokenPart{}, fmt.Errorf("unexpected content %s", msg.Content)
+	}
+	return part, nil
+}
+
+// imageURLTokens estimates the tokens of an image from the dimensions of data
+// URLs, and as a 1024x1024 image for remote URLs.
+func imageURLTokens(url, detail string) int {
+	if detail == "low" {
+		return ImageTokens(0, 0, detail)
+	}
+	_, encoded, ok := strings.Cut(url, ";base64,")
+	if !ok || !strings.HasPrefix(url, "data:") {
+		return defaultImageTokens
+	}
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return defaultImageTokens
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return defaultImageTokens
+	}
+	return ImageTokens(cfg.Width, cfg.Height, detail)
+}
+
+// documentDataURL reports whether an image_url data URL holds a document
+// rather than an image, e.g. a PDF sent with [DocumentFilePart]: its MIME type
+// is not an image type, or its data is a PDF.
+func documentDataURL(url string) bool {
+	header, encoded, ok := strings.Cut(url, ";base64,")
+	if !ok || !strings.HasPrefix(header, "data:") {
+		return false
+	}
+	if mimeType := strings.TrimPrefix(header, "data:"); mimeType != "" && !strings.HasPrefix(mimeType, "image/") {
+		return true
+	}
+	// The MIME type can be missing or wrong, so the PDF header is sniffed
+	prefix, err := base64.StdEncoding.DecodeString(encoded[:min(len(encoded), 8)])
+	return err == nil && bytes.HasPrefix(prefix, []byte("%PDF-"))
+}
+
+// audioTokens estimates the tokens of base64 audio from its duration, read
+// from the header of WAV audio and from the size of 128 kbps MP3 otherwise.
+func audioTokens(encoded, format string) int {
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return 0
+	}
+	bytesPerSecond := mp3BytesPerSecond
+	if format == "wav" && len(data) >= 44 && string(data[:4]) == "RIFF" {
+		if rate := int(binary.LittleEndian.Uint32(data[28:32])); rate > 0 {
+			bytesPerSecond = rate
+			data = data[44:]
+		}
+	}
+	return int(math.Ceil(float64(len(data)) / float64(bytesPerSecond) * audioTokensPerSecond))
+}
+
+// fileTokens estimates the tokens of a file sent as a base64 data URL:
+// PDFs per page, text files from their text and other files from their size.
+// Files sent by ID are not counted.
+func fileTokens(dataURL string, count func(string) int) int {
+	_, encoded, ok := strings.Cut(dataURL, ";base64,")
+	if !ok {
+		encoded = dataURL
+	}
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return 0
+	}
+	switch {
+	case bytes.HasPrefix(data, []byte("%PDF-")):
+		pages := pdfPagePattern.FindAll(data, -1)
+		return max(len(pages), 1) * pdfPageTokens
+	case utf8.Valid(data):
+		return count(string(data))
+	}
+	return len(data) / binaryBytesPerToken
+}
+
+// pdfPagePattern matches the page objects of a PDF, not the "/Type /Pages"
+// nodes of the page tree.
+var pdfPagePattern = regexp.MustCompile(`/Type\s*/Page\b`)
@@ -0,0 +1,102 @@
+package workflowai
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"strings"
+	"testing"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/shared"
+)
+
+func TestBreakdownTokens(t *testing.T) {
+	words := func(s string) int { return len(strings.Fields(s)) }
+	params := openai.ChatCompletionNewParams{
+		Model: "gpt-4o-mini-latest",
+		Messages: []openai.ChatCompletionMessageParamUnion{
+			openai.SystemMessage("You are a helpful assistant"),
+			openai.UserMessage([]openai.ChatCompletionContentPartUnionParam{
+				openai.TextContentPart("Describe this"),
+				ImageDataPart(testPNG(t, 100, 50, 255), "image/png", ""),
+				ImageURLPart("https://example.com/cat.png", "low"),
+			}),
+		},
+		Tools: []openai.ChatCompletionToolParam{{
+			Function: shared.FunctionDefinitionParam{Name: "search", Description: openai.String("Search the web")},
+		}},
+	}
+	b, err := BreakdownTokens(params, words)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b.Parts) != 3 {
+		t.Fatalf("unexpected parts %+v", b.Parts)
+	}
+	// 3 tokens per message, the role and the text
+	if system := b.Parts[0]; system.Name != "system" || system.Tokens != 3+1+5 {
+		t.Errorf("unexpected system message %+v", system)
+	}
+	// One 512 pixels tile and a low detail image
+	if user := b.Parts[1]; user.ImageTokens != 255+85 || user.Tokens != 3+1+2+255+85 {
+		t.Errorf("unexpected user message %+v", user)
+	}
+	if tool := b.Parts[2]; tool.Kind != TokenPartTool || tool.Name != "search" || tool.Tokens == 0 {
+		t.Errorf("unexpected tool %+v", tool)
+	}
+	if want := 3 + b.Parts[0].Tokens + b.Parts[1].Tokens + b.Parts[2].Tokens; b.Total != want {
+		t.Errorf("got total %d, want %d", b.Total, want)
+	}
+	if largest := b.Largest(1); len(largest) != 1 || largest[0].Name != "user" {
+		t.Errorf("unexpected largest %+v", largest)
+	}
+	if largest := b.Largest(-1); len(largest) != 0 {
+		t.Errorf("expected no part for a negative n, got %+v", largest)
+	}
+}
+
+func TestBreakdownTokens_AudioAndFiles(t *testing.T) {
+	// 2 seconds of 16 kHz 16-bit mono WAV
+	wav := make([]byte, 44+64000)
+	copy(wav, "RIFF")
+	binary.LittleEndian.PutUint32(wav[28:32], 32000)
+	pdf := "%PDF-1.4\n1 0 obj << /Type /Pages /Count 2 >>\n2 0 obj << /Type /Page >>\n3 0 obj << /Type /Page >>\n"
+	params := openai.ChatCompletionNewParams{
+		Messages: []openai.ChatCompletionMessageParamUnion{
+			openai.UserMessage([]openai.ChatCompletionContentPartUnionParam{
+				openai.InputAudioContentPart(openai.ChatCompletionContentPartInputAudioInputAudioParam{
+					Data: base64.StdEncoding.EncodeToString(wav), Format: "wav",
+				}),
+				openai.FileContentPart(openai.ChatCompletionContentPartFileFileParam{
+					FileData: openai.String("data:application/pdf;base64," + base64.StdEncoding.EncodeToString([]byte(pdf))),
+				}),
+				openai.FileContentPart(openai.ChatCompletionContentPartFileFileParam{
+					FileData: openai.String("data:text/plain;base64," + base64.StdEncoding.EncodeToString([]byte("three short words"))),
+				}),
+			}),
+			// Documents sent as image URLs, as by DocumentFilePart, with a
+			// document MIME type or a mislabeled PDF
+			openai.UserMessage([]openai.ChatCompletionContentPartUnionParam{
+				ImageDataPart([]byte(pdf), "application/pdf", ""),
+				ImageDataPart([]byte(pdf), "image/png", ""),
+			}),
+		},
+	}
+	b, err := BreakdownTokens(params, func(s string) int { return len(strings.Fields(s)) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := b.Parts[0]
+	if user.AudioTokens != 20 {
+		t.Errorf("expected 10 tokens per second of audio, got %d", user.AudioTokens)
+	}
+	if user.FileTokens != 2*pdfPageTokens+3 {
+		t.Errorf("expected the PDF counted per page and the text file from its text, got %d", user.FileTokens)
+	}
+	if user.Tokens != 3+1+user.AudioTokens+user.FileTokens {
+		t.Errorf("expected the base64 data not to be counted as text, got %+v", user)
+	}
+	if documents := b.Parts[1]; documents.ImageTokens != 0 || documents.FileTokens != 4*pdfPageTokens {
+		t.Errorf("expected the documents counted as files, got %+v", documents)
+	}
+}
@@ -0,0 +1,156 @@
+package workflowai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/openai/openai-go"
+)
+
+// Metadata keys linking the runs of an agent called as a tool, see
+// [Agent.AsTool], to the run of the agent calling it.
+const (
+	MetadataParentRunID  = "parent_run_id"
+	MetadataParentAgent  = "parent_agent"
+	MetadataToolCallID   = "tool_call_id"
+	defaultMaxToolRounds = 10
+)
+
+// Tool is a function that the model of an [Agent] can call.
+type Tool struct {
+	Name        string
+	Description string
+	// Parameters is the JSON schema of the arguments
+	Parameters json.RawMessage
+	// Call runs the tool with the arguments of a call and returns the result
+	// sent to the model.
+	Call func(ctx context.Context, arguments string) (string, error)
+}
+
+// NewTool returns a tool decoding its arguments into an A, whose schema is
+// generated by [JSONSchemaFor], and sending the JSON encoding of the result
+// of fn to the model.
+func NewTool[A any](name string, description string, fn func(ctx context.Context, args A) (any, error)) (Tool, error) {
+	schema, err := JSONSchemaFor[A]()
+	if err != nil {
+		return Tool{}, err
+	}
+	return Tool{
+		Name:        name,
+		Description: description,
+		Parameters:  schema,
+		Call: func(ctx context.Context, arguments string) (string, error) {
+			var args A
+			if err := json.Unmarshal([]byte(arguments), &args); err != nil {
+				return "", fmt.Errorf("invalid arguments: %w", err)
+			}
+			result, err := fn(ctx, args)
+			if err != nil {
+				return "", err
+			}
+			if s, ok := result.(string); ok {
+				return s, nil
+			}
+			data, err := json.Marshal(result)
+			return string(data), err
+		},
+	}, nil
+}
+
+// AsTool returns a tool running the agent, for supervisor agents delegating
+// to worker agents. The arguments of the tool are the input of the agent, and
+// its result is the output. The runs of the agent are tagged with the run
+// that called the tool in their metadata ("parent_run_id", "parent_agent"
+// and "tool_call_id").
+func (a *Agent[I, O]) AsTool(name string, description string) (Tool, error) {
+	schema, err := a.InputSchema()
+	if err != nil {
+		return Tool{}, err
+	}
+	return Tool{
+		Name:        name,
+		Description: description,
+		Parameters:  schema,
+		Call: func(ctx context.Context, arguments string) (string, error) {
+			var input I
+			if err := json.Unmarshal([]byte(arguments), &input); err != nil {
+				return "", fmt.Errorf("invalid arguments: %w", err)
+			}
+			run, err := a.Run(ctx, input)
+			if err != nil {
+				return "", err
+			}
+			if a.schema == nil {
+				return run.Completion.Choices[0].Message.Content, nil
+			}
+			data, err := json.Marshal(run.Output)
+			return string(data), err
+		},
+	}, nil
+}
+
+func (t Tool) param() openai.ChatCompletionToolParam {
+	var parameters map[string]any
+	json.Unmarshal(t.Parameters, &parameters)
+	return openai.ChatCompletionToolParam{Function: openai.FunctionDefinitionParam{
+		Name:        t.Name,
+		Description: openai.String(t.Description),
+		Parameters:  parameters,
+	}}
+}
+
+// callTools runs the tool calls of a completion concurrently and returns the
+// tool messages of their results. The errors of the tools are sent to the
+// model, for it to recover.
+func callTools(ctx context.Context, agentID string, tools []Tool, completion *openai.ChatCompletion) ([]openai.ChatCompletionMessageParamUnion, error) {
+	calls := completion.Choices[0].Message.ToolCalls
+	_, runID := splitCompletionID(completion.ID)
+	results := make([]string, len(calls))
+	done := make(chan struct{}, len(calls))
+	for i, call := range calls {
+		go func() {
+			defer func() { done <- struct{}{} }()
+			tool, ok := findTool(tools, call.Function.Name)
+			if !ok {
+				results[i] = toolError(fmt.Errorf("unknown tool %q", call.Function.Name))
+				return
+			}
+			callCtx := ContextWithRunMetadata(ctx, map[string]string{
+				MetadataParentRunID: runID,
+				MetadataParentAgent: agentID,
+				MetadataToolCallID:  call.ID,
+			})
+			result, err := tool.Call(callCtx, call.Function.Arguments)
+			if err != nil {
+				result = toolError(err)
+			}
+			results[i] = result
+		}()
+	}
+	for range calls {
+		<-done
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	messages := []openai.ChatCompletionMessageParamUnion{completion.Choices[0].Message.ToParam()}
+	for i, call := range calls {
+		messages = append(messages, openai.ToolMessage(results[i], call.ID))
+	}
+	return messages, nil
+}
+
+func findTool(tools []Tool, name string) (Tool, bool) {
+	for _, tool := range tools {
+		if tool.Name == name {
+			return tool, true
+		}
+	}
+	return Tool{}, false
+}
+
+func toolError(err error) string {
+	data, _ := json.Marshal(map[string]string{"error": ScrubError(err).Error()})
+	return string(data)
+}
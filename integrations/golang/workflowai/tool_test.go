@@ -0,0 +1,171 @@
+package workflowai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/openai/openai-go/option"
+)
+
+type workerInput struct {
+	Question string `json:"question"`
+}
+
+type workerOutput struct {
+	Answer string `json:"answer"`
+}
+
+func TestAgentAsTool(t *testing.T) {
+	var mu sync.Mutex
+	var requests []map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		mu.Lock()
+		requests = append(requests, body)
+		n := len(requests)
+		mu.Unlock()
+		message := map[string]any{"role": "assistant", "content": `{"answer": "42"}`}
+		messages, _ := body["messages"].([]any)
+		switch last, _ := messages[len(messages)-1].(map[string]any); {
+		case body["model"] == "supervisor/gpt-4o" && last["role"] != "tool":
+			message = map[string]any{"role": "assistant", "content": nil, "tool_calls": []any{
+				map[string]any{"id": "call-1", "type": "function", "function": map[string]any{"name": "ask_expert", "arguments": `{"question": "meaning of life"}`}},
+				map[string]any{"id": "call-2", "type": "function", "function": map[string]any{"name": "lookup", "arguments": `{"question": "x"}`}},
+			}}
+		case body["model"] == "supervisor/gpt-4o":
+			message["content"] = "The answer is 42"
+		}
+		agent, _, _ := strings.Cut(body["model"].(string), "/")
+		writeJSON(w, http.StatusOK, map[string]any{
+			"id":      agent + "/run-" + strconv.Itoa(n),
+			"choices": []any{map[string]any{"index": 0, "cost_usd": 0.01, "message": message}},
+		})
+	}))
+	defer server.Close()
+	client := NewClient(option.WithBaseURL(server.URL+"/v1"), option.WithAPIKey("test"), option.WithMaxRetries(0))
+
+	worker, err := NewAgent[workerInput, workerOutput](&client.Chat.Completions, "expert", "gpt-4o-mini", "Answer {{question}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expert, err := worker.AsTool("ask_expert", "Asks the expert")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lookup, err := NewTool("lookup", "Looks up a question", func(ctx context.Context, args workerInput) (any, error) {
+		return nil, errors.New("lookup is down")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	supervisor, err := NewAgent[workerInput, string](&client.Chat.Completions, "supervisor", "gpt-4o", "Delegate {{question}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	supervisor.Tools = []Tool{expert, lookup}
+
+	run, err := supervisor.Run(context.Background(), workerInput{Question: "meaning of life"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if run.Output != "The answer is 42" || len(run.Completions) != 2 || run.CostUSD() != 0.02 {
+		t.Errorf("run = %q, %d completions, cost %v", run.Output, len(run.Completions), run.CostUSD())
+	}
+	if len(requests) != 3 {
+		t.Fatalf("expected 3 requests, got %d", len(requests))
+	}
+	if tools, _ := requests[0]["tools"].([]any); len(tools) != 2 {
+		t.Errorf("tools = %v", requests[0]["tools"])
+	}
+
+	var child, final map[string]any
+	for _, r := range requests[1:] {
+		if r["model"] == "expert/gpt-4o-mini" {
+			child = r
+		} else {
+			final = r
+		}
+	}
+	metadata, _ := child["metadata"].(map[string]any)
+	if metadata[MetadataParentRunID] != "run-1" || metadata[MetadataParentAgent] != "supervisor" || metadata[MetadataToolCallID] != "call-1" {
+		t.Errorf("child metadata = %v", metadata)
+	}
+	if input, _ := child["input"].(map[string]any); input["question"] != "meaning of life" {
+		t.Errorf("child input = %v", child["input"])
+	}
+	messages, _ := final["messages"].([]any)
+	if len(messages) != 4 {
+		t.Fatalf("expected the system message, the tool calls and 2 results, got %v", messages)
+	}
+	if result, _ := messages[2].(map[string]any); result["content"] != `{"answer":"42"}` {
+		t.Errorf("expert result = %v", result)
+	}
+	if result, _ := messages[3].(map[string]any); !strings.Contains(result["content"].(string), "lookup is down") {
+		t.Errorf("lookup result = %v", result)
+	}
+}
+
+func TestAgent_MaxToolRounds(t *testing.T) {
+	var requests []map[string]any
+	ignoreToolChoice := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		requests = append(requests, body)
+		message := map[string]any{"role": "assistant", "content": nil, "tool_calls": []any{
+			map[string]any{"id": "call-" + strconv.Itoa(len(requests)), "type": "function", "function": map[string]any{"name": "lookup", "arguments": `{"question": "x"}`}},
+		}}
+		if body["tool_choice"] == "none" && !ignoreToolChoice {
+			message = map[string]any{"role": "assistant", "content": "done"}
+		}
+		writeJSON(w, http.StatusOK, map[string]any{
+			"id":      "agent/run-" + strconv.Itoa(len(requests)),
+			"choices": []any{map[string]any{"index": 0, "message": message}},
+		})
+	}))
+	defer server.Close()
+	client := NewClient(option.WithBaseURL(server.URL+"/v1"), option.WithAPIKey("test"), option.WithMaxRetries(0))
+
+	calls := 0
+	lookup, err := NewTool("lookup", "Looks up a question", func(ctx context.Context, args workerInput) (any, error) {
+		calls++
+		return "nothing", nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	agent, err := NewAgent[workerInput, string](&client.Chat.Completions, "agent", "gpt-4o", "{{question}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	agent.Tools = []Tool{lookup}
+	agent.MaxToolRounds = 2
+
+	run, err := agent.Run(context.Background(), workerInput{Question: "x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if run.Output != "done" || calls != 2 || len(requests) != 3 {
+		t.Errorf("run = %q after %d tool calls and %d requests", run.Output, calls, len(requests))
+	}
+	if _, ok := requests[1]["tool_choice"]; ok || requests[2]["tool_choice"] != "none" {
+		t.Errorf("tool choices = %v, %v", requests[1]["tool_choice"], requests[2]["tool_choice"])
+	}
+
+	ignoreToolChoice = true
+	requests, calls = nil, 0
+	if _, err := agent.Run(context.Background(), workerInput{Question: "x"}); err == nil || !strings.Contains(err.Error(), "did not answer after 2 rounds") {
+		t.Errorf("expected the rounds to be exhausted, got %v", err)
+	}
+	if calls != 2 || len(requests) != 3 {
+		t.Errorf("expected 2 rounds of tool calls, got %d tool calls and %d requests", calls, len(requests))
+	}
+}
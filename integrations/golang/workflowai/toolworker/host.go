@@ -0,0 +1,328 @@
+package toolworker
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/workflowai/workflowai/go/examples/workflowai"
+)
+
+// ErrWorkerExited is returned for the calls in progress when their worker
+// exits, e.g. after a crash.
+var ErrWorkerExited = errors.New("toolworker: the worker exited")
+
+// Limits are the limits of the calls of a tool.
+type Limits struct {
+	// Timeout bounds a call. The context of the tool is canceled at the
+	// timeout, and the worker is killed if the call does not return within
+	// a second after it. 0 only keeps the deadline of the context.
+	Timeout time.Duration
+	// MaxMemoryBytes is the soft memory limit of the Go runtime of the worker
+	// (GOMEMLIMIT), 0 for none
+	MaxMemoryBytes int64
+	// MaxResultBytes bounds the size of a result, 0 for no limit
+	MaxResultBytes int
+}
+
+// Host starts the workers and sends them the calls of the tools.
+type Host struct {
+	command       func() *exec.Cmd
+	defaultLimits Limits
+	limits        map[string]Limits
+	stderr        io.Writer
+
+	mu      sync.Mutex
+	workers map[string]*worker
+	closed  bool
+}
+
+type HostOption func(*Host)
+
+// WithDefaultLimits sets the limits of the tools without limits of their own,
+// a 30 seconds timeout by default.
+func WithDefaultLimits(limits Limits) HostOption {
+	return func(h *Host) {
+		h.defaultLimits = limits
+	}
+}
+
+// WithLimits sets the limits of a tool.
+func WithLimits(tool string, limits Limits) HostOption {
+	return func(h *Host) {
+		h.limits[tool] = limits
+	}
+}
+
+// WithStderr sets the destination of the standard error of the workers,
+// where the output of the tools goes, the standard error of the host by
+// default.
+func WithStderr(w io.Writer) HostOption {
+	return func(h *Host) {
+		h.stderr = w
+	}
+}
+
+// Self returns the commands starting the executable of the current process
+// as a worker, with the same arguments.
+func Self() func() *exec.Cmd {
+	return func() *exec.Cmd {
+		path, err := os.Executable()
+		if err != nil {
+			path = os.Args[0]
+		}
+		return exec.Command(path, os.Args[1:]...)
+	}
+}
+
+// NewHost returns a host starting the workers with command. The command must
+// call [Serve] when [IsWorker].
+func NewHost(command func() *exec.Cmd, opts ...HostOption) *Host {
+	h := &Host{
+		command:       command,
+		defaultLimits: Limits{Timeout: 30 * time.Second},
+		limits:        map[string]Limits{},
+		stderr:        os.Stderr,
+		workers:       map[string]*worker{},
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+// Tools starts a worker to list its tools, and returns the tools calling
+// them in their workers.
+func (h *Host) Tools(ctx context.Context) ([]workflowai.Tool, error) {
+	w, err := h.start(h.defaultLimits)
+	if err != nil {
+		return nil, err
+	}
+	defer w.close()
+	res, err := w.send(ctx, request{Method: "list"})
+	if err != nil {
+		return nil, err
+	}
+	tools := make([]workflowai.Tool, len(res.Tools))
+	for i, def := range res.Tools {
+		name := def.Name
+		tools[i] = workflowai.Tool{
+			Name:        name,
+			Description: def.Description,
+			Parameters:  def.Parameters,
+			Call: func(ctx context.Context, arguments string) (string, error) {
+				return h.Call(ctx, name, arguments)
+			},
+		}
+	}
+	return tools, nil
+}
+
+func (h *Host) limitsOf(tool string) Limits {
+	if limits, ok := h.limits[tool]; ok {
+		return limits
+	}
+	return h.defaultLimits
+}
+
+// Call calls a tool in its worker, starting the worker if it is not running.
+func (h *Host) Call(ctx context.Context, tool string, arguments string) (string, error) {
+	limits := h.limitsOf(tool)
+	w, err := h.worker(tool, limits)
+	if err != nil {
+		return "", err
+	}
+	req := request{Method: "call", Tool: tool, Arguments: arguments}
+	parent := ctx
+	if limits.Timeout > 0 {
+		req.TimeoutMS = limits.Timeout.Milliseconds()
+		// Grace period for the tool to return after its context is canceled
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, limits.Timeout+time.Second)
+		defer cancel()
+	}
+	res, err := w.send(ctx, req)
+	if err != nil {
+		if ctx.Err() != nil && parent.Err() == nil {
+			// The tool is stuck past its timeout: the other calls of the
+			// worker fail too
+			w.close()
+			return "", fmt.Errorf("toolworker: %s: the call exceeded its timeout of %s", tool, limits.Timeout)
+		}
+		return "", fmt.Errorf("toolworker: %s: %w", tool, err)
+	}
+	if res.Error != "" {
+		return "", errors.New(res.Error)
+	}
+	if limits.MaxResultBytes > 0 && len(res.Result) > limits.MaxResultBytes {
+		return "", fmt.Errorf("toolworker: %s: the result of %d bytes exceeds the limit of %d bytes", tool, len(res.Result), limits.MaxResultBytes)
+	}
+	return res.Result, nil
+}
+
+// worker returns the running worker of a tool, or starts it.
+func (h *Host) worker(tool string, limits Limits) (*worker, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return nil, errors.New("toolworker: the host is closed")
+	}
+	if w, ok := h.workers[tool]; ok && !w.exited() {
+		return w, nil
+	}
+	w, err := h.start(limits)
+	if err != nil {
+		return nil, err
+	}
+	h.workers[tool] = w
+	return w, nil
+}
+
+// Close stops the workers.
+func (h *Host) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	for _, w := range h.workers {
+		w.close()
+	}
+	return nil
+}
+
+type worker struct {
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+	done  chan struct{}
+	// err is the exit error, set before done is closed
+	err error
+
+	mu      sync.Mutex
+	nextID  int64
+	pending map[int64]chan response
+}
+
+func (h *Host) start(limits Limits) (*worker, error) {
+	cmd := h.command()
+	cmd.Env = append(cmd.Environ(), WorkerEnv+"=1")
+	if limits.MaxMemoryBytes > 0 {
+		cmd.Env = append(cmd.Env, "GOMEMLIMIT="+strconv.FormatInt(limits.MaxMemoryBytes, 10))
+	}
+	cmd.Stderr = h.stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("toolworker: %w", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("toolworker: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("toolworker: starting the worker: %w", err)
+	}
+	w := &worker{cmd: cmd, stdin: stdin, done: make(chan struct{}), pending: map[int64]chan response{}}
+	go w.read(stdout)
+	return w, nil
+}
+
+func (w *worker) read(stdout io.Reader) {
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64<<10), maxLine)
+	for scanner.Scan() {
+		var res response
+		if err := json.Unmarshal(scanner.Bytes(), &res); err != nil {
+			continue
+		}
+		w.mu.Lock()
+		ch, ok := w.pending[res.ID]
+		delete(w.pending, res.ID)
+		w.mu.Unlock()
+		if ok {
+			ch <- res
+		}
+	}
+	readErr := scanner.Err()
+	if readErr != nil {
+		// The worker blocks on its output once it is no longer read, e.g.
+		// after a message over maxLine
+		w.cmd.Process.Kill()
+	}
+	err := w.cmd.Wait()
+	switch {
+	case readErr != nil:
+		w.err = fmt.Errorf("%w: reading its output: %v", ErrWorkerExited, readErr)
+	case err != nil:
+		w.err = fmt.Errorf("%w: %v", ErrWorkerExited, err)
+	default:
+		w.err = ErrWorkerExited
+	}
+	close(w.done)
+}
+
+func (w *worker) send(ctx context.Context, req request) (response, error) {
+	ch := make(chan response, 1)
+	w.mu.Lock()
+	w.nextID++
+	req.ID = w.nextID
+	w.pending[req.ID] = ch
+	data, _ := json.Marshal(req)
+	_, err := w.stdin.Write(append(data, '\n'))
+	w.mu.Unlock()
+	if err != nil {
+		w.forget(req.ID)
+		return response{}, fmt.Errorf("%w: %v", ErrWorkerExited, err)
+	}
+	select {
+	case res := <-ch:
+		return res, nil
+	case <-w.done:
+		w.forget(req.ID)
+		return response{}, w.err
+	case <-ctx.Done():
+		w.forget(req.ID)
+		if req.Method == "call" {
+			// The tool stops working for a caller that is gone
+			w.cancel(req.ID)
+		}
+		return response{}, ctx.Err()
+	}
+}
+
+// cancel cancels the context of a call in the worker.
+func (w *worker) cancel(id int64) {
+	data, _ := json.Marshal(request{ID: id, Method: "cancel"})
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.stdin.Write(append(data, '\n'))
+}
+
+func (w *worker) forget(id int64) {
+	w.mu.Lock()
+	delete(w.pending, id)
+	w.mu.Unlock()
+}
+
+func (w *worker) exited() bool {
+	select {
+	case <-w.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// close kills the worker. The worker exits on its own when its input is
+// closed, but a stuck tool would keep it running.
+func (w *worker) close() {
+	w.stdin.Close()
+	if w.cmd.Process != nil {
+		w.cmd.Process.Kill()
+	}
+}
@@ -0,0 +1,143 @@
+package toolworker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/workflowai/workflowai/go/examples/workflowai"
+)
+
+func testTool(name string, fn func(ctx context.Context, arguments string) (string, error)) workflowai.Tool {
+	return workflowai.Tool{Name: name, Description: "the " + name + " tool", Parameters: []byte(`{"type":"object"}`), Call: fn}
+}
+
+var testTools = []workflowai.Tool{
+	testTool("echo", func(_ context.Context, arguments string) (string, error) {
+		// Printed to the standard error, not mixed with the messages
+		fmt.Println("echoing", arguments)
+		return fmt.Sprintf("%s from %d", arguments, os.Getpid()), nil
+	}),
+	testTool("fail", func(context.Context, string) (string, error) {
+		return "", errors.New("invalid input")
+	}),
+	testTool("panic", func(context.Context, string) (string, error) {
+		panic("boom")
+	}),
+	testTool("crash", func(context.Context, string) (string, error) {
+		os.Exit(3)
+		return "", nil
+	}),
+	testTool("hang", func(context.Context, string) (string, error) {
+		select {}
+	}),
+	testTool("large", func(context.Context, string) (string, error) {
+		return strings.Repeat("a", 100), nil
+	}),
+	testTool("huge", func(context.Context, string) (string, error) {
+		return strings.Repeat("a", maxLine), nil
+	}),
+	testTool("wait", func(ctx context.Context, path string) (string, error) {
+		<-ctx.Done()
+		return "", os.WriteFile(path, []byte(ctx.Err().Error()), 0o600)
+	}),
+}
+
+// TestMain serves the test tools when the test binary is started as a
+// worker.
+func TestMain(m *testing.M) {
+	if IsWorker() {
+		if err := Serve(testTools...); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+func TestHost(t *testing.T) {
+	command := func() *exec.Cmd { return exec.Command(os.Args[0], "-test.run=^$") }
+	host := NewHost(command,
+		WithStderr(io.Discard),
+		WithLimits("hang", Limits{Timeout: 100 * time.Millisecond}),
+		WithLimits("large", Limits{MaxResultBytes: 10}),
+	)
+	defer host.Close()
+	ctx := context.Background()
+
+	tools, err := host.Tools(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tools) != len(testTools) || tools[0].Name != "echo" || tools[0].Description != "the echo tool" {
+		t.Fatalf("unexpected tools %+v", tools)
+	}
+	call := func(name string) (string, error) {
+		return host.Call(ctx, name, `{"n":1}`)
+	}
+
+	result, err := call("echo")
+	if err != nil || !strings.HasPrefix(result, `{"n":1} from `) || strings.HasSuffix(result, fmt.Sprint(os.Getpid())) {
+		t.Fatalf("expected the echo of another process, got %q %v", result, err)
+	}
+	if _, err := call("fail"); err == nil || err.Error() != "invalid input" {
+		t.Errorf("expected the error of the tool, got %v", err)
+	}
+	if _, err := call("panic"); err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Errorf("expected the panic of the tool, got %v", err)
+	}
+	if _, err := call("crash"); !errors.Is(err, ErrWorkerExited) {
+		t.Errorf("expected the worker to exit, got %v", err)
+	}
+	// The crashed worker is restarted
+	if _, err := call("crash"); !errors.Is(err, ErrWorkerExited) {
+		t.Errorf("expected the restarted worker to exit, got %v", err)
+	}
+	start := time.Now()
+	if _, err := call("hang"); err == nil || !strings.Contains(err.Error(), "timeout") {
+		t.Errorf("expected a timeout, got %v", err)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("the timeout took %s", d)
+	}
+	if _, err := call("large"); err == nil || !strings.Contains(err.Error(), "exceeds the limit") {
+		t.Errorf("expected a result size error, got %v", err)
+	}
+	// A result over the size of the messages kills the worker
+	if _, err := call("huge"); !errors.Is(err, ErrWorkerExited) {
+		t.Errorf("expected the worker to be killed, got %v", err)
+	}
+
+	// The cancellation of the caller cancels the tool
+	canceled := filepath.Join(t.TempDir(), "canceled")
+	callCtx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	if _, err := host.Call(callCtx, "wait", canceled); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the deadline of the caller, got %v", err)
+	}
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		if data, err := os.ReadFile(canceled); err == nil {
+			if string(data) != context.Canceled.Error() {
+				t.Errorf("unexpected context error %s", data)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected the context of the tool to be canceled")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// The other tools are unaffected
+	if again, err := call("echo"); err != nil || again != result {
+		t.Errorf("expected the same worker, got %q %v", again, err)
+	}
+}
@@ -0,0 +1,174 @@
+// Package toolworker runs the tools of workflowai agents in worker processes,
+// so that untrusted or crash-prone tool code cannot take the serving process
+// down. Each tool runs in its own worker, which is killed when a call
+// exceeds its timeout and restarted on the next call.
+//
+// The workers are usually the binary of the service itself, started with
+// [Self]: main serves the tools when it runs as a worker.
+//
+//	func main() {
+//		if toolworker.IsWorker() {
+//			if err := toolworker.Serve(scrapeTool, pdfTool); err != nil {
+//				log.Fatal(err)
+//			}
+//			return
+//		}
+//		host := toolworker.NewHost(toolworker.Self(), toolworker.WithLimits("scrape", toolworker.Limits{Timeout: 10 * time.Second}))
+//		defer host.Close()
+//		tools, err := host.Tools(ctx)
+//		agent.Tools = tools
+//		...
+//	}
+//
+// The host and the workers exchange JSON lines over the standard input and
+// output of the workers. The output of the tools is redirected to the
+// standard error.
+package toolworker
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/workflowai/workflowai/go/examples/workflowai"
+)
+
+// WorkerEnv is the environment variable set for the workers.
+const WorkerEnv = "WORKFLOWAI_TOOL_WORKER"
+
+// maxLine bounds the size of a message between the host and a worker.
+const maxLine = 64 << 20
+
+type request struct {
+	ID int64 `json:"id"`
+	// Method is "list", "call" or "cancel", which cancels the context of the
+	// call of the ID and has no response
+	Method    string `json:"method"`
+	Tool      string `json:"tool,omitempty"`
+	Arguments string `json:"arguments,omitempty"`
+	// TimeoutMS cancels the context of the call, before the host kills the
+	// worker
+	TimeoutMS int64 `json:"timeout_ms,omitempty"`
+}
+
+type toolDefinition struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Parameters  json.RawMessage `json:"parameters"`
+}
+
+type response struct {
+	ID     int64            `json:"id"`
+	Tools  []toolDefinition `json:"tools,omitempty"`
+	Result string           `json:"result,omitempty"`
+	Error  string           `json:"error,omitempty"`
+}
+
+// IsWorker reports whether the process was started as a worker by a [Host].
+func IsWorker() bool {
+	return os.Getenv(WorkerEnv) == "1"
+}
+
+// Serve serves tools to the host over the standard input and output, until
+// the host closes the input. The calls run concurrently and a panic of a
+// tool is returned as the error of its call.
+func Serve(tools ...workflowai.Tool) error {
+	out := os.Stdout
+	// Tools printing to the standard output must not corrupt the messages
+	os.Stdout = os.Stderr
+	return serve(os.Stdin, out, tools)
+}
+
+func serve(in io.Reader, out io.Writer, tools []workflowai.Tool) error {
+	byName := make(map[string]workflowai.Tool, len(tools))
+	for _, t := range tools {
+		byName[t.Name] = t
+	}
+	var mu sync.Mutex
+	enc := json.NewEncoder(out)
+	send := func(res response) {
+		mu.Lock()
+		defer mu.Unlock()
+		enc.Encode(res)
+	}
+	// cancels are the cancel functions of the calls in progress, by ID
+	var cancelsMu sync.Mutex
+	cancels := map[int64]context.CancelFunc{}
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 64<<10), maxLine)
+	for scanner.Scan() {
+		var req request
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			return fmt.Errorf("toolworker: invalid request: %w", err)
+		}
+		switch req.Method {
+		case "list":
+			res := response{ID: req.ID}
+			for _, t := range tools {
+				res.Tools = append(res.Tools, toolDefinition{Name: t.Name, Description: t.Description, Parameters: t.Parameters})
+			}
+			send(res)
+		case "call":
+			tool, ok := byName[req.Tool]
+			if !ok {
+				send(response{ID: req.ID, Error: fmt.Sprintf("unknown tool %q", req.Tool)})
+				continue
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			cancelsMu.Lock()
+			cancels[req.ID] = cancel
+			cancelsMu.Unlock()
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer func() {
+					cancelsMu.Lock()
+					delete(cancels, req.ID)
+					cancelsMu.Unlock()
+					cancel()
+				}()
+				send(call(ctx, tool, req))
+			}()
+		case "cancel":
+			cancelsMu.Lock()
+			if cancel, ok := cancels[req.ID]; ok {
+				cancel()
+			}
+			cancelsMu.Unlock()
+		default:
+			send(response{ID: req.ID, Error: fmt.Sprintf("unknown method %q", req.Method)})
+		}
+	}
+	if err := scanner.Err(); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("toolworker: reading requests: %w", err)
+	}
+	return nil
+}
+
+func call(ctx context.Context, tool workflowai.Tool, req request) (res response) {
+	res.ID = req.ID
+	defer func() {
+		if r := recover(); r != nil {
+			res = response{ID: req.ID, Error: fmt.Sprintf("the tool panicked: %v", r)}
+		}
+	}()
+	if req.TimeoutMS > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(req.TimeoutMS)*time.Millisecond)
+		defer cancel()
+	}
+	result, err := tool.Call(ctx, req.Arguments)
+	if err != nil {
+		return response{ID: req.ID, Error: err.Error()}
+	}
+	res.Result = result
+	return res
+}
@@ -0,0 +1,387 @@
+package workflowai
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+
+	"github.com/workflowai/workflowai/go/examples/workflowai/textsplit"
+)
+
+// truncationField is the field of the messages carrying their truncation
+// policy, removed by the trimming middleware before the requests are sent.
+const truncationField = "workflowai_truncation"
+
+// TruncationMode is how a message is cut when a request exceeds its token
+// budget.
+type TruncationMode string
+
+const (
+	// TruncateNever keeps the message whole, e.g. the user question.
+	TruncateNever TruncationMode = "never"
+	// TruncateDrop removes the whole message.
+	TruncateDrop TruncationMode = "drop"
+	// TruncateEnd keeps the start of the text, e.g. for retrieved context
+	// ranked by relevance.
+	TruncateEnd TruncationMode = "end"
+	// TruncateStart keeps the end of the text, e.g. for logs.
+	TruncateStart TruncationMode = "start"
+	// TruncateMiddle keeps the start and the end of the text.
+	TruncateMiddle TruncationMode = "middle"
+)
+
+// TruncationPolicy is how [ContextTrimMiddleware] may cut a message to fit
+// the token budget of a request.
+type TruncationPolicy struct {
+	Mode TruncationMode `json:"mode"`
+	// Priority orders the messages that are cut, the lowest first, then the
+	// oldest first
+	Priority int `json:"priority,omitempty"`
+	// MinTokens is the least number of tokens a truncated text keeps
+	MinTokens int `json:"min_tokens,omitempty"`
+}
+
+// WithTruncation attaches a truncation policy to a message, enforced by
+// [ContextTrimMiddleware], which removes it from the requests:
+//
+//	params.Messages = []openai.ChatCompletionMessageParamUnion{
+//		openai.SystemMessage(instructions),
+//		workflowai.WithTruncation(openai.UserMessage(retrieved), workflowai.TruncationPolicy{Mode: workflowai.TruncateEnd, MinTokens: 500}),
+//		workflowai.WithTruncation(openai.UserMessage(question), workflowai.TruncationPolicy{Mode: workflowai.TruncateNever}),
+//	}
+func WithTruncation(message openai.ChatCompletionMessageParamUnion, policy TruncationPolicy) openai.ChatCompletionMessageParamUnion {
+	set := func(extra map[string]any) map[string]any {
+		extra = maps.Clone(extra)
+		if extra == nil {
+			extra = map[string]any{}
+		}
+		extra[truncationField] = policy
+		return extra
+	}
+	switch {
+	case message.OfSystem != nil:
+		message.OfSystem.SetExtraFields(set(message.OfSystem.ExtraFields()))
+	case message.OfDeveloper != nil:
+		message.OfDeveloper.SetExtraFields(set(message.OfDeveloper.ExtraFields()))
+	case message.OfUser != nil:
+		message.OfUser.SetExtraFields(set(message.OfUser.ExtraFields()))
+	case message.OfAssistant != nil:
+		message.OfAssistant.SetExtraFields(set(message.OfAssistant.ExtraFields()))
+	case message.OfTool != nil:
+		message.OfTool.SetExtraFields(set(message.OfTool.ExtraFields()))
+	}
+	return message
+}
+
+// ContextOverflowError is returned by [ContextTrimMiddleware] when a request
+// exceeds its token budget once every message that may be cut was cut.
+type ContextOverflowError struct {
+	Tokens    int
+	MaxTokens int
+}
+
+func (e *ContextOverflowError) Error() string {
+	return fmt.Sprintf("workflowai: the request has %d tokens once trimmed, more than the budget of %d", e.Tokens, e.MaxTokens)
+}
+
+type contextTrimmer struct {
+	maxTokens int
+	count     func(string) int
+	// history is the policy of the messages without one, except the system
+	// and developer messages and the last user message, which are never cut
+	history TruncationPolicy
+}
+
+type TrimOption func(*contextTrimmer)
+
+// WithTrimTokenCounter sets the token counter, [textsplit.EstimateTokens] by
+// default.
+func WithTrimTokenCounter(count func(string) int) TrimOption {
+	return func(t *contextTrimmer) {
+		t.count = count
+	}
+}
+
+// WithHistoryTruncation sets the policy of the messages without one, except
+// the system and developer messages and the last user message, which are
+// never cut. Defaults to dropping the oldest messages first.
+func WithHistoryTruncation(policy TruncationPolicy) TrimOption {
+	return func(t *contextTrimmer) {
+		t.history = policy
+	}
+}
+
+// ContextTrimMiddleware returns a client middleware cutting the messages of
+// the chat completion requests whose estimated tokens exceed maxTokens,
+// following their truncation policy, see [WithTruncation]. Messages are cut
+// by priority, then the oldest first, until the request fits. Requests that
+// cannot fit fail with a [*ContextOverflowError] before being sent. The
+// tokens are estimated like [BreakdownTokens].
+//
+// The tool results of an assistant message are dropped with it, and an
+// assistant message with the tool calls of a dropped result.
+func ContextTrimMiddleware(maxTokens int, opts ...TrimOption) option.Middleware {
+	t := &contextTrimmer{
+		maxTokens: maxTokens,
+		count:     textsplit.EstimateTokens,
+		history:   TruncationPolicy{Mode: TruncateDrop},
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t.middleware
+}
+
+// trimmedMessage is a message of a request that may be cut.
+type trimmedMessage struct {
+	index   int
+	message map[string]any
+	policy  TruncationPolicy
+}
+
+func (t *contextTrimmer) middleware(req *http.Request, next option.MiddlewareNext) (*http.Response, error) {
+	if !isChatCompletionRequest(req) {
+		return next(req)
+	}
+	body, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	marked := bytes.Contains(body, []byte(truncationField))
+	tokens, err := requestTokens(body, t.count)
+	if err != nil {
+		// Let the server report invalid requests
+		return next(req)
+	}
+	if !marked && tokens <= t.maxTokens {
+		return next(req)
+	}
+	payload, err := decodeJSONObject(body)
+	if err != nil {
+		return next(req)
+	}
+	messages, _ := payload["messages"].([]any)
+	candidates := t.candidates(messages)
+	if marked {
+		encoded, err := encodeJSON(payload)
+		if err != nil {
+			return nil, err
+		}
+		if tokens, err = requestTokens(encoded, t.count); err != nil {
+			return next(req)
+		}
+	}
+
+	dropped := map[int]bool{}
+	excess := tokens - t.maxTokens
+	for _, c := range candidates {
+		if excess <= 0 {
+			break
+		}
+		if dropped[c.index] {
+			continue
+		}
+		if c.policy.Mode == TruncateDrop {
+			for _, i := range toolGroup(messages, c.index) {
+				if !dropped[i] {
+					dropped[i] = true
+					excess -= t.messageTokens(messages[i])
+				}
+			}
+			continue
+		}
+		excess -= t.truncate(c, excess)
+	}
+	if excess > 0 {
+		return abort(&ContextOverflowError{Tokens: t.maxTokens + excess, MaxTokens: t.maxTokens})
+	}
+	if len(dropped) > 0 {
+		kept := make([]any, 0, len(messages)-len(dropped))
+		for i, m := range messages {
+			if !dropped[i] {
+				kept = append(kept, m)
+			}
+		}
+		payload["messages"] = kept
+	}
+	encoded, err := encodeJSON(payload)
+	if err != nil {
+		return nil, err
+	}
+	setRequestBody(req, encoded)
+	return next(req)
+}
+
+// candidates removes the truncation policies of the messages and returns the
+// messages that may be cut, in the order they are cut.
+func (t *contextTrimmer) candidates(messages []any) []trimmedMessage {
+	last := -1
+	for i, m := range messages {
+		if message, _ := m.(map[string]any); message["role"] == "user" {
+			last = i
+		}
+	}
+	var candidates []trimmedMessage
+	for i, m := range messages {
+		message, ok := m.(map[string]any)
+		if !ok {
+			continue
+		}
+		policy := TruncationPolicy{Mode: TruncateNever}
+		if role := message["role"]; role != "system" && role != "developer" && i != last {
+			policy = t.history
+		}
+		if raw, ok := message[truncationField]; ok {
+			delete(message, truncationField)
+			if data, err := json.Marshal(raw); err == nil {
+				_ = json.Unmarshal(data, &policy)
+			}
+		}
+		if policy.Mode != TruncateNever && policy.Mode != "" {
+			candidates = append(candidates, trimmedMessage{index: i, message: message, policy: policy})
+		}
+	}
+	slices.SortStableFunc(candidates, func(a, b trimmedMessage) int {
+		return cmp.Compare(a.policy.Priority, b.policy.Priority)
+	})
+	return candidates
+}
+
+func (t *contextTrimmer) messageTokens(m any) int {
+	data, err := encodeJSON(m)
+	if err != nil {
+		return 0
+	}
+	part, err := rawMessageTokenPart(data, t.count)
+	if err != nil {
+		return 0
+	}
+	return part.Tokens
+}
+
+// truncate cuts the texts of a message by up to excess tokens, the longest
+// first, and returns the number of tokens removed.
+func (t *contextTrimmer) truncate(c trimmedMessage, excess int) int {
+	type text struct {
+		value string
+		set   func(string)
+	}
+	var texts []text
+	switch content := c.message["content"].(type) {
+	case string:
+		texts = append(texts, text{content, func(s string) { c.message["content"] = s }})
+	case []any:
+		for _, p := range content {
+			part, _ := p.(map[string]any)
+			if value, ok := part["text"].(string); ok {
+				texts = append(texts, text{value, func(s string) { part["text"] = s }})
+			}
+		}
+	}
+	slices.SortStableFunc(texts, func(a, b text) int { return len(b.value) - len(a.value) })
+	removed := 0
+	for _, x := range texts {
+		if removed >= excess {
+			break
+		}
+		tokens := t.count(x.value)
+		target := max(tokens-(excess-removed), c.policy.MinTokens)
+		if target >= tokens {
+			continue
+		}
+		cut := cutTokens(x.value, target, c.policy.Mode, t.count)
+		x.set(cut)
+		removed += tokens - t.count(cut)
+	}
+	return removed
+}
+
+// truncationMarker replaces the cut part of the truncated texts.
+const truncationMarker = "[...]"
+
+// cutTokens cuts text to about maxTokens tokens at word boundaries, keeping
+// its start, its end or both depending on mode.
+func cutTokens(text string, maxTokens int, mode TruncationMode, count func(string) int) string {
+	runes := []rune(text)
+	// prefix returns the longest prefix of runes of at most n tokens
+	prefix := func(runes []rune, n int) []rune {
+		lo, hi := 0, len(runes)
+		for lo < hi {
+			mid := (lo + hi + 1) / 2
+			if count(string(runes[:mid])) <= n {
+				lo = mid
+			} else {
+				hi = mid - 1
+			}
+		}
+		if lo < len(runes) {
+			if space := lastSpace(runes[:lo]); space > 0 {
+				lo = space
+			}
+		}
+		return runes[:lo]
+	}
+	reversed := func(runes []rune) []rune {
+		out := slices.Clone(runes)
+		slices.Reverse(out)
+		return out
+	}
+	budget := max(maxTokens-count(truncationMarker), 0)
+	switch mode {
+	case TruncateStart:
+		end := reversed(prefix(reversed(runes), budget))
+		return truncationMarker + " " + strings.TrimLeftFunc(string(end), unicode.IsSpace)
+	case TruncateMiddle:
+		start := prefix(runes, budget/2)
+		end := reversed(prefix(reversed(runes[len(start):]), budget-budget/2))
+		return strings.TrimRightFunc(string(start), unicode.IsSpace) + " " + truncationMarker + " " + strings.TrimLeftFunc(string(end), unicode.IsSpace)
+	}
+	return strings.TrimRightFunc(string(prefix(runes, budget)), unicode.IsSpace) + " " + truncationMarker
+}
+
+func lastSpace(runes []rune) int {
+	for i := len(runes) - 1; i > 0; i-- {
+		if unicode.IsSpace(runes[i]) {
+			return i
+		}
+	}
+	return 0
+}
+
+// toolGroup returns the messages dropped with the message at i: an assistant
+// message with its tool results, or the assistant message of a tool result
+// with its other results.
+func toolGroup(messages []any, i int) []int {
+	start := i
+	if message, _ := messages[i].(map[string]any); message["role"] == "tool" {
+		for start > 0 {
+			start--
+			if m, _ := messages[start].(map[string]any); m["role"] != "tool" {
+				break
+			}
+		}
+		if m, _ := messages[start].(map[string]any); m["role"] != "assistant" {
+			return []int{i}
+		}
+	}
+	group := []int{start}
+	if m, _ := messages[start].(map[string]any); m["role"] != "assistant" || m["tool_calls"] == nil {
+		return group
+	}
+	for j := start + 1; j < len(messages); j++ {
+		if m, _ := messages[j].(map[string]any); m["role"] != "tool" {
+			break
+		}
+		group = append(group, j)
+	}
+	return group
+}
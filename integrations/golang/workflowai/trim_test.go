@@ -0,0 +1,218 @@
+package workflowai
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+
+	"github.com/workflowai/workflowai/go/examples/workflowai/textsplit"
+)
+
+func newTrimClient(t *testing.T, bodies *[]map[string]any, maxTokens int, opts ...TrimOption) Client {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		*bodies = append(*bodies, body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(testCompletion))
+	}))
+	t.Cleanup(server.Close)
+	return NewClient(option.WithBaseURL(server.URL+"/v1"), option.WithAPIKey("test"), option.WithMaxRetries(0),
+		option.WithMiddleware(ContextTrimMiddleware(maxTokens, opts...)))
+}
+
+func messageContents(body map[string]any) []string {
+	var contents []string
+	for _, m := range body["messages"].([]any) {
+		content, _ := m.(map[string]any)["content"].(string)
+		contents = append(contents, content)
+	}
+	return contents
+}
+
+func TestContextTrimMiddlewareTruncation(t *testing.T) {
+	var bodies []map[string]any
+	client := newTrimClient(t, &bodies, 300)
+	retrieved := strings.Repeat("The refund policy allows refunds within 30 days. ", 100)
+	question := strings.Repeat("Can I get a refund for my order? ", 20)
+	params := openai.ChatCompletionNewParams{
+		Model: "support/gpt-4o",
+		Messages: []openai.ChatCompletionMessageParamUnion{
+			openai.SystemMessage("Answer from the context."),
+			WithTruncation(openai.UserMessage(retrieved), TruncationPolicy{Mode: TruncateEnd}),
+			WithTruncation(openai.UserMessage(question), TruncationPolicy{Mode: TruncateNever}),
+		},
+	}
+
+	if _, err := client.Chat.Completions.New(context.Background(), params); err != nil {
+		t.Fatal(err)
+	}
+	body := bodies[0]
+	if strings.Contains(mustJSON(t, body), truncationField) {
+		t.Error("expected the policies to be removed")
+	}
+	contents := messageContents(body)
+	if len(contents) != 3 || contents[0] != "Answer from the context." || contents[2] != question {
+		t.Fatalf("expected the other messages to be kept, got %q", contents)
+	}
+	if !strings.HasPrefix(contents[1], "The refund policy") || !strings.HasSuffix(contents[1], " [...]") || len(contents[1]) >= len(retrieved) {
+		t.Errorf("expected the retrieved context to be truncated, got %q", contents[1])
+	}
+	if tokens, _ := requestTokens([]byte(mustJSON(t, body)), textsplit.EstimateTokens); tokens > 300 {
+		t.Errorf("expected at most 300 tokens, got %d", tokens)
+	}
+}
+
+func TestContextTrimMiddlewareImages(t *testing.T) {
+	var bodies []map[string]any
+	client := newTrimClient(t, &bodies, 100000)
+	// A 1 MB image, padded after the end of the PNG
+	data := append(testPNG(t, 512, 512, 255), make([]byte, 1<<20)...)
+	params := openai.ChatCompletionNewParams{
+		Model: "support/gpt-4o",
+		Messages: []openai.ChatCompletionMessageParamUnion{openai.UserMessage([]openai.ChatCompletionContentPartUnionParam{
+			openai.TextContentPart("What is in this image?"),
+			openai.ImageContentPart(openai.ChatCompletionContentPartImageImageURLParam{URL: "data:image/png;base64," + base64.StdEncoding.EncodeToString(data)}),
+		})},
+	}
+	if _, err := client.Chat.Completions.New(context.Background(), params); err != nil {
+		t.Fatalf("expected the image to be counted from its dimensions, got %v", err)
+	}
+	if len(bodies) != 1 || len(bodies[0]["messages"].([]any)) != 1 {
+		t.Errorf("expected the request to be sent as it is, got %d requests", len(bodies))
+	}
+}
+
+func TestContextTrimMiddlewareHistory(t *testing.T) {
+	var bodies []map[string]any
+	client := newTrimClient(t, &bodies, 200)
+	long := strings.Repeat("word ", 150)
+	params := openai.ChatCompletionNewParams{
+		Model: "support/gpt-4o",
+		Messages: []openai.ChatCompletionMessageParamUnion{
+			openai.SystemMessage("Be helpful."),
+			openai.UserMessage("first " + long),
+			openai.AssistantMessage("second"),
+			openai.UserMessage("third"),
+		},
+	}
+	if _, err := client.Chat.Completions.New(context.Background(), params); err != nil {
+		t.Fatal(err)
+	}
+	if contents := messageContents(bodies[0]); strings.Join(contents, "|") != "Be helpful.|second|third" {
+		t.Errorf("expected the oldest message to be dropped, got %q", contents)
+	}
+
+	// Within the budget, the request is sent as it is
+	params.Messages[1] = openai.UserMessage("first")
+	if _, err := client.Chat.Completions.New(context.Background(), params); err != nil {
+		t.Fatal(err)
+	}
+	if contents := messageContents(bodies[1]); len(contents) != 4 {
+		t.Errorf("expected the messages to be kept, got %q", contents)
+	}
+}
+
+func TestContextTrimMiddlewarePriorities(t *testing.T) {
+	var bodies []map[string]any
+	client := newTrimClient(t, &bodies, 250, WithHistoryTruncation(TruncationPolicy{Mode: TruncateNever}))
+	block := strings.Repeat("lorem ipsum ", 60)
+	params := openai.ChatCompletionNewParams{
+		Model: "support/gpt-4o",
+		Messages: []openai.ChatCompletionMessageParamUnion{
+			WithTruncation(openai.UserMessage("important "+block), TruncationPolicy{Mode: TruncateDrop, Priority: 1}),
+			WithTruncation(openai.UserMessage("optional "+block), TruncationPolicy{Mode: TruncateDrop}),
+			openai.UserMessage("question"),
+		},
+	}
+	if _, err := client.Chat.Completions.New(context.Background(), params); err != nil {
+		t.Fatal(err)
+	}
+	contents := messageContents(bodies[0])
+	if len(contents) != 2 || !strings.HasPrefix(contents[0], "important") {
+		t.Errorf("expected the lowest priority to be dropped first, got %d messages", len(contents))
+	}
+}
+
+func TestContextTrimMiddlewareToolResults(t *testing.T) {
+	var bodies []map[string]any
+	client := newTrimClient(t, &bodies, 150)
+	params := openai.ChatCompletionNewParams{
+		Model: "support/gpt-4o",
+		Messages: []openai.ChatCompletionMessageParamUnion{
+			openai.UserMessage("What is the weather?"),
+			{OfAssistant: &openai.ChatCompletionAssistantMessageParam{ToolCalls: []openai.ChatCompletionMessageToolCallParam{
+				{ID: "call_1", Function: openai.ChatCompletionMessageToolCallFunctionParam{Name: "weather", Arguments: "{}"}},
+			}}},
+			openai.ToolMessage(strings.Repeat("sunny ", 200), "call_1"),
+			openai.AssistantMessage("It is sunny."),
+			openai.UserMessage("Thanks"),
+		},
+	}
+	if _, err := client.Chat.Completions.New(context.Background(), params); err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range bodies[0]["messages"].([]any) {
+		if role := m.(map[string]any)["role"]; role == "tool" {
+			t.Fatal("expected the tool result to be dropped")
+		}
+		if m.(map[string]any)["tool_calls"] != nil {
+			t.Fatal("expected the tool calls to be dropped with their results")
+		}
+	}
+}
+
+func TestContextTrimMiddlewareOverflow(t *testing.T) {
+	var bodies []map[string]any
+	client := newTrimClient(t, &bodies, 50)
+	params := openai.ChatCompletionNewParams{
+		Model: "support/gpt-4o",
+		Messages: []openai.ChatCompletionMessageParamUnion{
+			WithTruncation(openai.UserMessage(strings.Repeat("context ", 100)), TruncationPolicy{Mode: TruncateEnd, MinTokens: 80}),
+			openai.UserMessage("question"),
+		},
+	}
+	_, err := client.Chat.Completions.New(context.Background(), params)
+	var overflow *ContextOverflowError
+	if !errors.As(err, &overflow) || overflow.MaxTokens != 50 || overflow.Tokens <= 50 {
+		t.Fatalf("expected an overflow error, got %v", err)
+	}
+	if len(bodies) != 0 {
+		t.Error("expected the request not to be sent")
+	}
+}
+
+func TestCutTokens(t *testing.T) {
+	text := "one two three four five six seven eight nine ten eleven twelve"
+	count := func(s string) int { return len(strings.Fields(s)) }
+	for _, tt := range []struct {
+		mode TruncationMode
+		want string
+	}{
+		{TruncateEnd, "one two three [...]"},
+		{TruncateStart, "[...] ten eleven twelve"},
+		{TruncateMiddle, "one [...] eleven twelve"},
+	} {
+		if got := cutTokens(text, 4, tt.mode, count); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.mode, got, tt.want)
+		}
+	}
+}
+
+func mustJSON(t *testing.T, v any) string {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
@@ -0,0 +1,141 @@
+package workflowai
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Usage is the usage accumulated by the completions of a label.
+type Usage struct {
+	Requests         int64
+	PromptTokens     int64
+	CompletionTokens int64
+	CostUSD          float64
+}
+
+func (u *Usage) add(e CompleteEvent) {
+	u.Requests++
+	u.PromptTokens += e.PromptTokens
+	u.CompletionTokens += e.CompletionTokens
+	u.CostUSD += e.CostUSD
+}
+
+// UsageAccumulator aggregates the tokens and cost of the completions per
+// label, e.g. to expose the spend of the current hour. Completions are
+// counted under the labels of their context (see [ContextWithUsageLabels])
+// and under "agent:<agent_id>":
+//
+//	usage := workflowai.NewUsageAccumulator()
+//	usage.Register(&hooks)
+//	ctx = workflowai.ContextWithUsageLabels(ctx, "user:"+userID, "feature:search")
+//
+// It is safe for concurrent use.
+type UsageAccumulator struct {
+	mu     sync.Mutex
+	since  time.Time
+	total  Usage
+	labels map[string]*Usage
+}
+
+func NewUsageAccumulator() *UsageAccumulator {
+	return &UsageAccumulator{since: time.Now(), labels: map[string]*Usage{}}
+}
+
+// Register counts the completions observed by hooks.
+func (a *UsageAccumulator) Register(hooks *Hooks) {
+	hooks.OnComplete(func(ctx context.Context, e CompleteEvent) {
+		labels := UsageLabels(ctx)
+		if e.AgentID != "" {
+			labels = append(labels, "agent:"+e.AgentID)
+		}
+		a.Add(e, labels...)
+	})
+}
+
+// Add counts a completion under labels.
+func (a *UsageAccumulator) Add(e CompleteEvent, labels ...string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.total.add(e)
+	seen := make(map[string]bool, len(labels))
+	for _, label := range labels {
+		if seen[label] {
+			continue
+		}
+		seen[label] = true
+		usage, ok := a.labels[label]
+		if !ok {
+			usage = &Usage{}
+			a.labels[label] = usage
+		}
+		usage.add(e)
+	}
+}
+
+// Get returns the usage of a label.
+func (a *UsageAccumulator) Get(label string) Usage {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if usage, ok := a.labels[label]; ok {
+		return *usage
+	}
+	return Usage{}
+}
+
+// Total returns the usage of all the completions. Completions with several
+// labels are counted once.
+func (a *UsageAccumulator) Total() Usage {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.total
+}
+
+// UsageSnapshot is the usage accumulated since a point in time.
+type UsageSnapshot struct {
+	Since  time.Time
+	Total  Usage
+	Labels map[string]Usage
+}
+
+// Snapshot returns the usage of every label.
+func (a *UsageAccumulator) Snapshot() UsageSnapshot {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.snapshot()
+}
+
+// Reset clears the usage and returns it, e.g. at the start of every hour.
+func (a *UsageAccumulator) Reset() UsageSnapshot {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	snapshot := a.snapshot()
+	a.since = time.Now()
+	a.total = Usage{}
+	a.labels = map[string]*Usage{}
+	return snapshot
+}
+
+func (a *UsageAccumulator) snapshot() UsageSnapshot {
+	labels := make(map[string]Usage, len(a.labels))
+	for label, usage := range a.labels {
+		labels[label] = *usage
+	}
+	return UsageSnapshot{Since: a.since, Total: a.total, Labels: labels}
+}
+
+type usageLabelsKey struct{}
+
+// ContextWithUsageLabels returns a context whose completions are counted
+// under labels, in addition to the labels already set on ctx.
+func ContextWithUsageLabels(ctx context.Context, labels ...string) context.Context {
+	labels = append(UsageLabels(ctx), labels...)
+	return context.WithValue(ctx, usageLabelsKey{}, labels)
+}
+
+// UsageLabels returns the labels set with [ContextWithUsageLabels].
+func UsageLabels(ctx context.Context) []string {
+	labels, _ := ctx.Value(usageLabelsKey{}).([]string)
+	// Copied so that appending does not share the array across contexts
+	return append([]string(nil), labels...)
+}
@@ -0,0 +1,58 @@
+package workflowai
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+func TestUsageAccumulator(t *testing.T) {
+	server := newTestServer(t)
+	var hooks Hooks
+	usage := NewUsageAccumulator()
+	usage.Register(&hooks)
+	client := openai.NewClient(option.WithBaseURL(server.URL), option.WithAPIKey("test"), option.WithMiddleware(hooks.Middleware()))
+	params := openai.ChatCompletionNewParams{Model: "my-agent/gpt-4o", Messages: []openai.ChatCompletionMessageParamUnion{openai.UserMessage("Hi")}}
+
+	ctx := ContextWithUsageLabels(context.Background(), "user:1")
+	var wg sync.WaitGroup
+	for i := range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx := ctx
+			if i%2 == 0 {
+				ctx = ContextWithUsageLabels(ctx, "feature:search", "user:1")
+			}
+			if _, err := client.Chat.Completions.New(ctx, params); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := usage.Get("agent:my-agent"); got != (Usage{Requests: 4, PromptTokens: 40, CompletionTokens: 8, CostUSD: 2}) {
+		t.Errorf("unexpected agent usage %+v", got)
+	}
+	// Duplicate labels are counted once
+	if got := usage.Get("user:1"); got.Requests != 4 {
+		t.Errorf("unexpected user usage %+v", got)
+	}
+	if got := usage.Get("feature:search"); got.Requests != 2 || got.CostUSD != 1 {
+		t.Errorf("unexpected feature usage %+v", got)
+	}
+	if got := usage.Total(); got.Requests != 4 {
+		t.Errorf("unexpected total %+v", got)
+	}
+
+	snapshot := usage.Reset()
+	if len(snapshot.Labels) != 3 || snapshot.Total.Requests != 4 || snapshot.Since.IsZero() {
+		t.Errorf("unexpected snapshot %+v", snapshot)
+	}
+	if got := usage.Snapshot(); got.Total.Requests != 0 || len(got.Labels) != 0 || got.Since.Before(snapshot.Since) {
+		t.Errorf("usage was not reset: %+v", got)
+	}
+}
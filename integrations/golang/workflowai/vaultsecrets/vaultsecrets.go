@@ -0,0 +1,126 @@
+// Package vaultsecrets loads the WorkflowAI API key and the provider keys
+// from a HashiCorp Vault KV version 2 secret.
+//
+//	secrets, err := vaultsecrets.New(ctx, "workflowai/production")
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	client := workflowai.NewClient(option.WithMiddleware(
+//		workflowai.APIKeyMiddleware(secrets.Key("WORKFLOWAI_API_KEY")),
+//	))
+package vaultsecrets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/workflowai/workflowai/go/examples/workflowai"
+)
+
+type config struct {
+	address    string
+	token      string
+	mount      string
+	ttl        time.Duration
+	httpClient *http.Client
+}
+
+type Option func(*config)
+
+// WithAddress sets the address of the Vault server. Defaults to VAULT_ADDR.
+func WithAddress(address string) Option {
+	return func(c *config) {
+		c.address = address
+	}
+}
+
+// WithToken sets the Vault token. Defaults to VAULT_TOKEN.
+func WithToken(token string) Option {
+	return func(c *config) {
+		c.token = token
+	}
+}
+
+// WithMount sets the mount path of the KV engine. Defaults to "secret".
+func WithMount(mount string) Option {
+	return func(c *config) {
+		c.mount = mount
+	}
+}
+
+// WithTTL sets how long the secret is cached before it is read again.
+// Defaults to 5 minutes.
+func WithTTL(ttl time.Duration) Option {
+	return func(c *config) {
+		c.ttl = ttl
+	}
+}
+
+// WithHTTPClient sets the client used to reach Vault, e.g. to configure TLS.
+func WithHTTPClient(client *http.Client) Option {
+	return func(c *config) {
+		c.httpClient = client
+	}
+}
+
+// New reads the secret at path, e.g. "workflowai/production", and returns
+// its string fields as cached secrets.
+func New(ctx context.Context, path string, opts ...Option) (*workflowai.Secrets, error) {
+	cfg := &config{
+		address:    os.Getenv("VAULT_ADDR"),
+		token:      os.Getenv("VAULT_TOKEN"),
+		mount:      "secret",
+		httpClient: http.DefaultClient,
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	if cfg.address == "" || cfg.token == "" {
+		return nil, errors.New("vaultsecrets: a Vault address and token are required")
+	}
+	return workflowai.NewSecrets(ctx, loader(cfg, path), cfg.ttl)
+}
+
+func loader(cfg *config, path string) workflowai.SecretLoader {
+	endpoint := strings.TrimRight(cfg.address, "/") + "/v1/" + url.PathEscape(cfg.mount) + "/data/" + strings.TrimLeft(path, "/")
+	return func(ctx context.Context) (map[string]string, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("X-Vault-Token", cfg.token)
+		res, err := cfg.httpClient.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("vaultsecrets: reading %s: %w", path, err)
+		}
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			body, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+			return nil, fmt.Errorf("vaultsecrets: reading %s: %s: %s", path, res.Status, strings.TrimSpace(string(body)))
+		}
+
+		var secret struct {
+			Data struct {
+				Data map[string]any `json:"data"`
+			} `json:"data"`
+		}
+		if err := json.NewDecoder(res.Body).Decode(&secret); err != nil {
+			return nil, fmt.Errorf("vaultsecrets: decoding %s: %w", path, err)
+		}
+		values := make(map[string]string, len(secret.Data.Data))
+		for k, v := range secret.Data.Data {
+			if s, ok := v.(string); ok {
+				values[k] = s
+			}
+		}
+		return values, nil
+	}
+}
@@ -0,0 +1,61 @@
+package vaultsecrets
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	key := "wai-key-1"
+	reads := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if r.URL.Path != "/v1/kv/data/workflowai/production" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		reads++
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"data": map[string]any{
+				"data":     map[string]any{"WORKFLOWAI_API_KEY": key, "OPENAI_API_KEY": "sk-1", "version": 3},
+				"metadata": map[string]any{"version": 1},
+			},
+		})
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	secrets, err := New(ctx, "workflowai/production", WithAddress(server.URL), WithToken("token"), WithMount("kv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, err := secrets.Get(ctx, "OPENAI_API_KEY", false); err != nil || v != "sk-1" {
+		t.Errorf("unexpected provider key %q: %v", v, err)
+	}
+	if _, err := secrets.Get(ctx, "version", false); err == nil {
+		t.Error("expected non string fields to be ignored")
+	}
+
+	key = "wai-key-2"
+	provider := secrets.Key("WORKFLOWAI_API_KEY")
+	if v, _ := provider.APIKey(ctx, false); v != "wai-key-1" {
+		t.Errorf("expected the cached key, got %q", v)
+	}
+	if v, _ := provider.APIKey(ctx, true); v != "wai-key-2" || reads != 2 {
+		t.Errorf("expected the refreshed key, got %q after %d reads", v, reads)
+	}
+
+	if _, err := New(ctx, "workflowai/production", WithAddress(server.URL), WithToken("other")); err == nil {
+		t.Error("expected an error for a rejected token")
+	}
+	t.Setenv("VAULT_ADDR", "")
+	if _, err := New(ctx, "workflowai/production", WithToken("token")); err == nil {
+		t.Error("expected an error without address")
+	}
+}
@@ -0,0 +1,170 @@
+package workflowai
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+// VersionService accesses the versions of an agent and their deployments.
+type VersionService struct {
+	client openai.Client
+}
+
+// Environments a version can be deployed to.
+const (
+	EnvironmentDev        = "dev"
+	EnvironmentStaging    = "staging"
+	EnvironmentProduction = "production"
+)
+
+type UserIdentifier struct {
+	UserID    string `json:"user_id,omitempty"`
+	UserEmail string `json:"user_email,omitempty"`
+}
+
+// VersionDeployment is the deployment of a version to an environment.
+type VersionDeployment struct {
+	Environment string          `json:"environment"`
+	DeployedAt  time.Time       `json:"deployed_at"`
+	DeployedBy  *UserIdentifier `json:"deployed_by,omitempty"`
+}
+
+// Version is a version of an agent, as returned by [VersionService.Get].
+type Version struct {
+	ID       string `json:"id"`
+	SchemaID int    `json:"schema_id"`
+	// Semver is the major and minor of saved versions, e.g. [2, 1] for "2.1"
+	Semver    []int     `json:"semver,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	Model     string    `json:"model"`
+	// Properties are the model, temperature, instructions, messages... of
+	// the version
+	Properties   map[string]any      `json:"properties"`
+	InputSchema  json.RawMessage     `json:"input_schema"`
+	OutputSchema json.RawMessage     `json:"output_schema"`
+	Deployments  []VersionDeployment `json:"deployments,omitempty"`
+	Notes        string              `json:"notes,omitempty"`
+	CreatedBy    *UserIdentifier     `json:"created_by,omitempty"`
+}
+
+// Name returns the semver of the version, or its ID when it is not saved.
+func (v *Version) Name() string {
+	if len(v.Semver) == 2 {
+		return strconv.Itoa(v.Semver[0]) + "." + strconv.Itoa(v.Semver[1])
+	}
+	return v.ID
+}
+
+// MinorVersion is a version as listed in its [MajorVersion].
+type MinorVersion struct {
+	ID          string              `json:"id"`
+	Minor       int                 `json:"minor"`
+	Model       string              `json:"model"`
+	Deployments []VersionDeployment `json:"deployments,omitempty"`
+	Notes       string              `json:"notes,omitempty"`
+}
+
+// MajorVersion groups the versions sharing the same instructions and
+// temperature.
+type MajorVersion struct {
+	Major     int            `json:"major"`
+	SchemaID  int            `json:"schema_id"`
+	Minors    []MinorVersion `json:"minors"`
+	CreatedAt time.Time      `json:"created_at"`
+}
+
+// Deployment is the result of [VersionService.Deploy].
+type Deployment struct {
+	SchemaID    int       `json:"task_schema_id"`
+	VersionID   string    `json:"version_id"`
+	Environment string    `json:"environment"`
+	DeployedAt  time.Time `json:"deployed_at"`
+}
+
+// Get returns a version by ID or semver, e.g. "2.1", with its input and
+// output schemas.
+func (s *VersionService) Get(ctx context.Context, agentID string, versionID string, opts ...option.RequestOption) (*Version, error) {
+	var version Version
+	if err := s.client.Execute(ctx, http.MethodGet, agentPath(agentID, "versions", versionID), nil, &version, opts...); err != nil {
+		return nil, err
+	}
+	return &version, nil
+}
+
+// List returns the saved versions of an agent grouped by major version. A
+// schemaID of 0 returns the versions of every schema.
+func (s *VersionService) List(ctx context.Context, agentID string, schemaID int, opts ...option.RequestOption) ([]MajorVersion, error) {
+	path := agentPath(agentID, "versions")
+	if schemaID != 0 {
+		path += "?" + url.Values{"schema_id": {strconv.Itoa(schemaID)}}.Encode()
+	}
+	var page Page[MajorVersion]
+	if err := s.client.Execute(ctx, http.MethodGet, path, nil, &page, opts...); err != nil {
+		return nil, err
+	}
+	return page.Items, nil
+}
+
+// ListDeployed returns the versions of an agent deployed to at least one
+// environment, with their deployments, for every schema.
+func (s *VersionService) ListDeployed(ctx context.Context, agentID string, opts ...option.RequestOption) ([]Version, error) {
+	var page Page[Version]
+	// The deployed versions are served by the tenant route, outside of /v1,
+	// where versions/deployed would be read as the version "deployed"
+	if err := s.client.Execute(ctx, http.MethodGet, "../"+agentPath(agentID, "versions", "deployed"), nil, &page, opts...); err != nil {
+		return nil, err
+	}
+	return page.Items, nil
+}
+
+// Deploy deploys a version to an environment, replacing the version deployed
+// to the environment for the schema of the version.
+func (s *VersionService) Deploy(ctx context.Context, agentID string, versionID string, environment string, opts ...option.RequestOption) (*Deployment, error) {
+	var deployment Deployment
+	body := map[string]string{"environment": environment}
+	if err := s.client.Execute(ctx, http.MethodPost, agentPath(agentID, "versions", versionID, "deploy"), body, &deployment, opts...); err != nil {
+		return nil, err
+	}
+	return &deployment, nil
+}
+
+// UpdateNotes replaces the notes of a version.
+func (s *VersionService) UpdateNotes(ctx context.Context, agentID string, versionID string, notes string, opts ...option.RequestOption) error {
+	body := map[string]string{"notes": notes}
+	// Decoding the response, even if unused, closes its body
+	var res json.RawMessage
+	return s.client.Execute(ctx, http.MethodPatch, agentPath(agentID, "versions", versionID, "notes"), body, &res, opts...)
+}
+
+// DeployedVersion returns the version deployed to environment among majors,
+// preferring the deployment of schemaID, and falling back to the most
+// recent deployment of another schema. It returns nil when no version is
+// deployed to the environment.
+func DeployedVersion(majors []MajorVersion, environment string, schemaID int) (*MinorVersion, *MajorVersion) {
+	var (
+		minor    *MinorVersion
+		major    *MajorVersion
+		deployed time.Time
+	)
+	for i := range majors {
+		for j := range majors[i].Minors {
+			for _, d := range majors[i].Minors[j].Deployments {
+				if d.Environment != environment {
+					continue
+				}
+				sameSchema := majors[i].SchemaID == schemaID
+				if major == nil || (sameSchema && major.SchemaID != schemaID) || (sameSchema == (major.SchemaID == schemaID) && d.DeployedAt.After(deployed)) {
+					minor, major, deployed = &majors[i].Minors[j], &majors[i], d.DeployedAt
+				}
+			}
+		}
+	}
+	return minor, major
+}
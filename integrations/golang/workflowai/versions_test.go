@@ -0,0 +1,40 @@
+package workflowai
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDeployedVersion(t *testing.T) {
+	deployed := func(env string, day int) []VersionDeployment {
+		return []VersionDeployment{{Environment: env, DeployedAt: time.Date(2025, 1, day, 0, 0, 0, 0, time.UTC)}}
+	}
+	majors := []MajorVersion{
+		{Major: 1, SchemaID: 1, Minors: []MinorVersion{{ID: "v1", Deployments: deployed("production", 1)}}},
+		{Major: 2, SchemaID: 2, Minors: []MinorVersion{
+			{ID: "v2-1", Deployments: deployed("production", 2)},
+			{ID: "v2-2", Deployments: deployed("staging", 3)},
+		}},
+	}
+	for _, tt := range []struct {
+		env      string
+		schemaID int
+		want     string
+	}{
+		{"production", 1, "v1"},
+		{"production", 2, "v2-1"},
+		// The most recent deployment of another schema
+		{"production", 3, "v2-1"},
+		{"staging", 1, "v2-2"},
+		{"dev", 1, ""},
+	} {
+		minor, _ := DeployedVersion(majors, tt.env, tt.schemaID)
+		var got string
+		if minor != nil {
+			got = minor.ID
+		}
+		if got != tt.want {
+			t.Errorf("DeployedVersion(%s, %d) = %q, want %q", tt.env, tt.schemaID, got, tt.want)
+		}
+	}
+}
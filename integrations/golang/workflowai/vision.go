@@ -0,0 +1,51 @@
+package workflowai
+
+import (
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/openai/openai-go"
+)
+
+// ImageURLPart is a content part of an image served at url. detail is
+// "auto", "low" or "high", empty for the default.
+func ImageURLPart(url string, detail string) openai.ChatCompletionContentPartUnionParam {
+	return openai.ImageContentPart(openai.ChatCompletionContentPartImageImageURLParam{URL: url, Detail: detail})
+}
+
+// ImageDataPart is a content part of an image sent inline, as a base64 data
+// URL. The mime type is detected from data when empty.
+func ImageDataPart(data []byte, mimeType string, detail string) openai.ChatCompletionContentPartUnionParam {
+	if mimeType == "" {
+		mimeType = http.DetectContentType(data)
+	}
+	url := "data:" + mimeType + ";base64," + base64.StdEncoding.EncodeToString(data)
+	return ImageURLPart(url, detail)
+}
+
+// ImageFilePart reads a local image and returns it as an inline content
+// part. The mime type is derived from the extension of the file, then from its
+// content.
+func ImageFilePart(path string, detail string) (openai.ChatCompletionContentPartUnionParam, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return openai.ChatCompletionContentPartUnionParam{}, fmt.Errorf("workflowai: reading image: %w", err)
+	}
+	return ImageDataPart(data, mime.TypeByExtension(filepath.Ext(path)), detail), nil
+}
+
+// DocumentFilePart reads a local document, e.g. a PDF, and returns it as an
+// inline content part. WorkflowAI does not accept "file" content parts:
+// documents are sent as image URL parts, the content type of the data URL
+// telling the provider how to read them.
+func DocumentFilePart(path string) (openai.ChatCompletionContentPartUnionParam, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return openai.ChatCompletionContentPartUnionParam{}, fmt.Errorf("workflowai: reading document: %w", err)
+	}
+	return ImageDataPart(data, mime.TypeByExtension(filepath.Ext(path)), ""), nil
+}
@@ -0,0 +1,48 @@
+package workflowai
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestImageParts(t *testing.T) {
+	part := ImageURLPart("https://example.com/cat.jpg", "low")
+	if url := part.OfImageURL.ImageURL; url.URL != "https://example.com/cat.jpg" || url.Detail != "low" {
+		t.Errorf("unexpected part %+v", url)
+	}
+
+	png := []byte("\x89PNG\r\n\x1a\n")
+	if url := ImageDataPart(png, "", "").OfImageURL.ImageURL.URL; url != "data:image/png;base64,iVBORw0KGgo=" {
+		t.Errorf("unexpected data URL %q", url)
+	}
+
+	path := filepath.Join(t.TempDir(), "cat.webp")
+	os.WriteFile(path, []byte("webp"), 0o644)
+	part, err := ImageFilePart(path, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if url := part.OfImageURL.ImageURL.URL; !strings.HasPrefix(url, "data:image/webp;base64,") {
+		t.Errorf("unexpected data URL %q", url)
+	}
+	if _, err := ImageFilePart(filepath.Join(t.TempDir(), "missing.png"), ""); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
+
+func TestDocumentFilePart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report")
+	os.WriteFile(path, []byte("%PDF-1.7\n"), 0o644)
+	part, err := DocumentFilePart(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if url := part.OfImageURL.ImageURL.URL; !strings.HasPrefix(url, "data:application/pdf;base64,") {
+		t.Errorf("unexpected data URL %q", url)
+	}
+	if _, err := DocumentFilePart(filepath.Join(t.TempDir(), "missing.pdf")); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
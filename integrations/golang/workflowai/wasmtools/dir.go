@@ -0,0 +1,196 @@
+package wasmtools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/workflowai/workflowai/go/examples/workflowai"
+)
+
+// Dir serves the tools of the .wasm plugins of a directory, and reloads them
+// when their files change.
+type Dir struct {
+	path string
+	opts []Option
+
+	// reload serializes the reloads
+	reload  sync.Mutex
+	mu      sync.RWMutex
+	plugins map[string]*dirPlugin
+	// tools maps the names of the tools to their plugin files
+	tools map[string]string
+}
+
+type dirPlugin struct {
+	plugin  *Plugin
+	modTime time.Time
+	size    int64
+}
+
+// OpenDir loads the plugins of a directory. A plugin failing to load is an
+// error.
+func OpenDir(ctx context.Context, path string, opts ...Option) (*Dir, error) {
+	d := &Dir{path: path, opts: opts, plugins: map[string]*dirPlugin{}, tools: map[string]string{}}
+	if err := d.Reload(ctx); err != nil {
+		d.Close(ctx)
+		return nil, err
+	}
+	return d, nil
+}
+
+// Reload loads the new and changed plugins of the directory, and removes the
+// deleted ones. A plugin failing to load keeps its previous version, and the
+// errors are returned once the other plugins are reloaded. The calls in
+// progress end on the version they started on.
+func (d *Dir) Reload(ctx context.Context) error {
+	d.reload.Lock()
+	defer d.reload.Unlock()
+	files, err := filepath.Glob(filepath.Join(d.path, "*.wasm"))
+	if err != nil {
+		return fmt.Errorf("wasmtools: %w", err)
+	}
+	d.mu.RLock()
+	current := make(map[string]*dirPlugin, len(d.plugins))
+	for file, p := range d.plugins {
+		current[file] = p
+	}
+	d.mu.RUnlock()
+
+	var errs []error
+	next := map[string]*dirPlugin{}
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("wasmtools: %w", err))
+			continue
+		}
+		if p, ok := current[file]; ok && p.modTime.Equal(info.ModTime()) && p.size == info.Size() {
+			next[file] = p
+			continue
+		}
+		plugin, err := LoadFile(ctx, file, d.opts...)
+		if err != nil {
+			errs = append(errs, err)
+			if p, ok := current[file]; ok {
+				next[file] = p
+			}
+			continue
+		}
+		next[file] = &dirPlugin{plugin: plugin, modTime: info.ModTime(), size: info.Size()}
+	}
+
+	tools := map[string]string{}
+	for _, file := range sortedKeys(next) {
+		for _, def := range next[file].plugin.Definitions() {
+			if other, ok := tools[def.Name]; ok {
+				errs = append(errs, fmt.Errorf("wasmtools: the tool %s of %s is already defined by %s", def.Name, file, other))
+				continue
+			}
+			tools[def.Name] = file
+		}
+	}
+
+	d.mu.Lock()
+	d.plugins, d.tools = next, tools
+	d.mu.Unlock()
+	// The replaced plugins are closed once their calls end
+	for file, p := range current {
+		if next[file] != p {
+			go p.plugin.Close(context.Background())
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Watch reloads the plugins every interval until ctx is done, reporting the
+// errors of the reloads to onError when it is not nil.
+func (d *Dir) Watch(ctx context.Context, interval time.Duration, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := d.Reload(ctx); err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}
+}
+
+// Tools returns the tools of the plugins, sorted by name. A call goes to the
+// current version of the plugin of its tool, so that the tools keep working
+// across the reloads; tools added by a reload need a new call to Tools.
+func (d *Dir) Tools() []workflowai.Tool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	var tools []workflowai.Tool
+	for _, file := range sortedKeys(d.plugins) {
+		for _, def := range d.plugins[file].plugin.Definitions() {
+			if d.tools[def.Name] != file {
+				continue
+			}
+			name := def.Name
+			tools = append(tools, workflowai.Tool{
+				Name:        name,
+				Description: def.Description,
+				Parameters:  def.Parameters,
+				Call: func(ctx context.Context, arguments string) (string, error) {
+					return d.Call(ctx, name, arguments)
+				},
+			})
+		}
+	}
+	sort.Slice(tools, func(i, j int) bool { return tools[i].Name < tools[j].Name })
+	return tools
+}
+
+// Call calls a tool in the current version of its plugin.
+func (d *Dir) Call(ctx context.Context, tool string, arguments string) (string, error) {
+	for {
+		d.mu.RLock()
+		file, ok := d.tools[tool]
+		var plugin *Plugin
+		if ok {
+			plugin = d.plugins[file].plugin
+		}
+		d.mu.RUnlock()
+		if !ok {
+			return "", fmt.Errorf("wasmtools: unknown tool %q", tool)
+		}
+		result, err := plugin.Call(ctx, tool, arguments)
+		// The plugin was replaced by a reload in between
+		if errors.Is(err, errClosed) {
+			continue
+		}
+		return result, err
+	}
+}
+
+// Close releases the plugins.
+func (d *Dir) Close(ctx context.Context) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var errs []error
+	for _, p := range d.plugins {
+		errs = append(errs, p.plugin.Close(ctx))
+	}
+	d.plugins, d.tools = map[string]*dirPlugin{}, map[string]string{}
+	return errors.Join(errs...)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,359 @@
+// Package wasmtools loads the tools of workflowai agents from WASM modules,
+// run with wazero. A plugin has no access to the file system, the network or
+// the environment of the service: it only sees its arguments, and can only
+// answer through the host interface below. Each call runs in a fresh instance
+// of the module, bounded in memory and time, so that a call cannot leak state
+// into the next one.
+//
+// # Host interface
+//
+// A plugin is a WASI reactor module (its "_initialize" function is called
+// when it is instantiated, "_start" is not) exporting:
+//
+//	memory
+//	alloc(size i32) i32
+//		returns the address of size free bytes, where the host writes the
+//		name of the tool and its arguments
+//	describe()
+//		sets as its result the JSON array of the tools of the plugin, e.g.
+//		[{"name": "slugify", "description": "...", "parameters": {...}}]
+//	call(name_ptr i32, name_len i32, args_ptr i32, args_len i32)
+//		calls a tool with its JSON arguments, and sets its result or its
+//		error
+//
+// and importing from the "workflowai" module the functions it needs among:
+//
+//	result(ptr i32, len i32)	sets the result of the call
+//	error(ptr i32, len i32)		sets the error of the call, sent to the model
+//	log(ptr i32, len i32)		writes a line to the log of the host
+//
+// The standard output and error of the plugin also go to the log.
+//
+//	plugins, err := wasmtools.OpenDir(ctx, "plugins", wasmtools.WithTimeout(5*time.Second))
+//	defer plugins.Close(ctx)
+//	go plugins.Watch(ctx, 10*time.Second, func(err error) { log.Print(err) })
+//	agent.Tools = plugins.Tools()
+package wasmtools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+
+	"github.com/workflowai/workflowai/go/examples/workflowai"
+)
+
+const (
+	// DefaultTimeout bounds the calls of the tools.
+	DefaultTimeout = 30 * time.Second
+	// DefaultMemoryLimit bounds the memory of an instance of a plugin.
+	DefaultMemoryLimit = 64 << 20
+	// HostModule is the name of the module of the host functions.
+	HostModule = "workflowai"
+)
+
+const pageSize = 64 << 10
+
+var errClosed = errors.New("wasmtools: the plugin is closed")
+
+type config struct {
+	timeout     time.Duration
+	memoryLimit int
+	log         io.Writer
+}
+
+type Option func(*config)
+
+// WithTimeout bounds each call, [DefaultTimeout] by default. A call past its
+// timeout is interrupted.
+func WithTimeout(d time.Duration) Option {
+	return func(c *config) {
+		c.timeout = d
+	}
+}
+
+// WithMemoryLimit bounds the memory of each instance of a plugin, rounded
+// up to the 64 KiB WASM pages, [DefaultMemoryLimit] by default.
+func WithMemoryLimit(bytes int) Option {
+	return func(c *config) {
+		c.memoryLimit = bytes
+	}
+}
+
+// WithLog sets the destination of the logs and of the output of the
+// plugins, the standard error by default.
+func WithLog(w io.Writer) Option {
+	return func(c *config) {
+		c.log = w
+	}
+}
+
+func newConfig(opts []Option) *config {
+	c := &config{timeout: DefaultTimeout, memoryLimit: DefaultMemoryLimit, log: os.Stderr}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Definition is the definition of a tool of a plugin.
+type Definition struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Parameters  json.RawMessage `json:"parameters"`
+}
+
+// Plugin is a compiled WASM module serving tools.
+type Plugin struct {
+	cfg         *config
+	runtime     wazero.Runtime
+	compiled    wazero.CompiledModule
+	definitions []Definition
+
+	// mu is held by the calls, so that Close waits for them
+	mu     sync.RWMutex
+	closed bool
+}
+
+// callState collects what the plugin sets through the host functions during
+// a call.
+type callState struct {
+	result, err string
+	set         bool
+}
+
+type stateKey struct{}
+
+// Load compiles a plugin and reads the definitions of its tools.
+func Load(ctx context.Context, wasm []byte, opts ...Option) (*Plugin, error) {
+	cfg := newConfig(opts)
+	pages := uint32((cfg.memoryLimit + pageSize - 1) / pageSize)
+	runtime := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().
+		WithCloseOnContextDone(true).
+		WithMemoryLimitPages(pages))
+	p := &Plugin{cfg: cfg, runtime: runtime}
+	if err := p.init(ctx, wasm); err != nil {
+		runtime.Close(ctx)
+		return nil, err
+	}
+	return p, nil
+}
+
+// LoadFile loads the plugin of a .wasm file.
+func LoadFile(ctx context.Context, path string, opts ...Option) (*Plugin, error) {
+	wasm, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("wasmtools: %w", err)
+	}
+	p, err := Load(ctx, wasm, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("%w (%s)", err, path)
+	}
+	return p, nil
+}
+
+func (p *Plugin) init(ctx context.Context, wasm []byte) error {
+	if _, err := wasi_snapshot_preview1.Instantiate(ctx, p.runtime); err != nil {
+		return fmt.Errorf("wasmtools: %w", err)
+	}
+	_, err := p.runtime.NewHostModuleBuilder(HostModule).
+		NewFunctionBuilder().WithFunc(func(ctx context.Context, m api.Module, ptr, n uint32) {
+		setState(ctx, m, ptr, n, false)
+	}).Export("result").
+		NewFunctionBuilder().WithFunc(func(ctx context.Context, m api.Module, ptr, n uint32) {
+		setState(ctx, m, ptr, n, true)
+	}).Export("error").
+		NewFunctionBuilder().WithFunc(func(_ context.Context, m api.Module, ptr, n uint32) {
+		if data, ok := m.Memory().Read(ptr, n); ok {
+			fmt.Fprintf(p.cfg.log, "%s\n", data)
+		}
+	}).Export("log").
+		Instantiate(ctx)
+	if err != nil {
+		return fmt.Errorf("wasmtools: %w", err)
+	}
+	p.compiled, err = p.runtime.CompileModule(ctx, wasm)
+	if err != nil {
+		return fmt.Errorf("wasmtools: invalid module: %w", err)
+	}
+	if err := checkExports(p.compiled); err != nil {
+		return err
+	}
+	described, err := p.invoke(ctx, "describe")
+	if err != nil {
+		return fmt.Errorf("wasmtools: describe: %w", err)
+	}
+	if err := json.Unmarshal([]byte(described), &p.definitions); err != nil {
+		return fmt.Errorf("wasmtools: invalid tool definitions: %w", err)
+	}
+	for i, def := range p.definitions {
+		if def.Name == "" {
+			return fmt.Errorf("wasmtools: the tool %d has no name", i)
+		}
+		if len(def.Parameters) == 0 {
+			p.definitions[i].Parameters = json.RawMessage(`{"type":"object"}`)
+		}
+	}
+	return nil
+}
+
+func checkExports(compiled wazero.CompiledModule) error {
+	if _, ok := compiled.ExportedMemories()["memory"]; !ok {
+		return errors.New("wasmtools: the module does not export its memory")
+	}
+	want := map[string][]api.ValueType{
+		"alloc":    {api.ValueTypeI32},
+		"describe": nil,
+		"call":     {api.ValueTypeI32, api.ValueTypeI32, api.ValueTypeI32, api.ValueTypeI32},
+	}
+	functions := compiled.ExportedFunctions()
+	for name, params := range want {
+		fn, ok := functions[name]
+		if !ok {
+			return fmt.Errorf("wasmtools: the module does not export %s", name)
+		}
+		if !equalTypes(fn.ParamTypes(), params) {
+			return fmt.Errorf("wasmtools: %s has the parameters %v", name, fn.ParamTypes())
+		}
+	}
+	if results := functions["alloc"].ResultTypes(); !equalTypes(results, []api.ValueType{api.ValueTypeI32}) {
+		return fmt.Errorf("wasmtools: alloc has the results %v", results)
+	}
+	return nil
+}
+
+func equalTypes(a, b []api.ValueType) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func setState(ctx context.Context, m api.Module, ptr, n uint32, isErr bool) {
+	st, ok := ctx.Value(stateKey{}).(*callState)
+	if !ok {
+		return
+	}
+	data, ok := m.Memory().Read(ptr, n)
+	if !ok {
+		return
+	}
+	st.set = true
+	if isErr {
+		st.err = string(data)
+	} else {
+		st.result = string(data)
+	}
+}
+
+// Definitions returns the definitions of the tools of the plugin.
+func (p *Plugin) Definitions() []Definition {
+	return p.definitions
+}
+
+// Tools returns the tools of the plugin.
+func (p *Plugin) Tools() []workflowai.Tool {
+	tools := make([]workflowai.Tool, len(p.definitions))
+	for i, def := range p.definitions {
+		name := def.Name
+		tools[i] = workflowai.Tool{
+			Name:        name,
+			Description: def.Description,
+			Parameters:  def.Parameters,
+			Call: func(ctx context.Context, arguments string) (string, error) {
+				return p.Call(ctx, name, arguments)
+			},
+		}
+	}
+	return tools
+}
+
+// Call calls a tool of the plugin in a new instance of the module.
+func (p *Plugin) Call(ctx context.Context, tool string, arguments string) (string, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		return "", errClosed
+	}
+	return p.invoke(ctx, "call", tool, arguments)
+}
+
+// invoke instantiates the module and calls one of its exports with strings
+// written into its memory.
+func (p *Plugin) invoke(ctx context.Context, export string, args ...string) (string, error) {
+	parent := ctx
+	if p.cfg.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.cfg.timeout)
+		defer cancel()
+	}
+	st := &callState{}
+	ctx = context.WithValue(ctx, stateKey{}, st)
+	mod, err := p.runtime.InstantiateModule(ctx, p.compiled, wazero.NewModuleConfig().
+		WithName("").
+		WithStartFunctions("_initialize").
+		WithStdout(p.cfg.log).
+		WithStderr(p.cfg.log))
+	if err != nil {
+		return "", fmt.Errorf("wasmtools: instantiating the module: %w", err)
+	}
+	defer mod.Close(context.Background())
+	var params []uint64
+	for _, arg := range args {
+		ptr, err := write(ctx, mod, arg)
+		if err != nil {
+			return "", err
+		}
+		params = append(params, uint64(ptr), uint64(len(arg)))
+	}
+	if _, err := mod.ExportedFunction(export).Call(ctx, params...); err != nil {
+		if ctx.Err() != nil && parent.Err() == nil {
+			return "", fmt.Errorf("wasmtools: the call exceeded its timeout of %s", p.cfg.timeout)
+		}
+		return "", fmt.Errorf("wasmtools: the plugin failed: %w", err)
+	}
+	if st.err != "" {
+		return "", errors.New(st.err)
+	}
+	if !st.set {
+		return "", errors.New("wasmtools: the plugin returned no result")
+	}
+	return st.result, nil
+}
+
+func write(ctx context.Context, mod api.Module, s string) (uint32, error) {
+	res, err := mod.ExportedFunction("alloc").Call(ctx, uint64(len(s)))
+	if err != nil {
+		return 0, fmt.Errorf("wasmtools: alloc: %w", err)
+	}
+	ptr := uint32(res[0])
+	if !mod.Memory().WriteString(ptr, s) {
+		return 0, fmt.Errorf("wasmtools: alloc returned %d, out of memory", ptr)
+	}
+	return ptr, nil
+}
+
+// Close waits for the calls in progress and releases the plugin.
+func (p *Plugin) Close(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return nil
+	}
+	p.closed = true
+	return p.runtime.Close(ctx)
+}
@@ -0,0 +1,194 @@
+package wasmtools
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testModule assembles a plugin serving the tools of toolsJSON:
+//
+//	call echoes its arguments with result, reports an error when they are
+//	empty, and loops forever for the tools named with 5 letters ("sleep").
+func testModule(toolsJSON string) []byte {
+	const errMessage = "empty arguments"
+	vec := func(items ...[]byte) []byte {
+		out := uleb(uint64(len(items)))
+		for _, item := range items {
+			out = append(out, item...)
+		}
+		return out
+	}
+	name := func(s string) []byte { return append(uleb(uint64(len(s))), s...) }
+	section := func(id byte, items ...[]byte) []byte {
+		content := vec(items...)
+		return append(append([]byte{id}, uleb(uint64(len(content)))...), content...)
+	}
+	cat := func(parts ...[]byte) []byte {
+		var out []byte
+		for _, p := range parts {
+			out = append(out, p...)
+		}
+		return out
+	}
+	i32const := func(v int64) []byte { return append([]byte{0x41}, sleb(v)...) }
+	code := func(body ...[]byte) []byte {
+		fn := cat(vec(), cat(body...), []byte{0x0b})
+		return append(uleb(uint64(len(fn))), fn...)
+	}
+	const i32, end = 0x7f, 0x0b
+	return cat(
+		[]byte("\x00asm\x01\x00\x00\x00"),
+		section(1, // types
+			[]byte{0x60, 2, i32, i32, 0},
+			[]byte{0x60, 1, i32, 1, i32},
+			[]byte{0x60, 0, 0},
+			[]byte{0x60, 4, i32, i32, i32, i32, 0},
+		),
+		section(2, // imports
+			cat(name(HostModule), name("result"), []byte{0x00, 0}),
+			cat(name(HostModule), name("error"), []byte{0x00, 0}),
+		),
+		section(3, []byte{1}, []byte{2}, []byte{3}),                     // alloc, describe, call
+		section(5, []byte{0x00, 1}),                                     // memory of 1 page
+		section(6, cat([]byte{i32, 0x01}, i32const(4096), []byte{end})), // heap pointer
+		section(7, // exports
+			cat(name("memory"), []byte{0x02, 0}),
+			cat(name("alloc"), []byte{0x00, 2}),
+			cat(name("describe"), []byte{0x00, 3}),
+			cat(name("call"), []byte{0x00, 4}),
+		),
+		section(10, // code
+			// alloc: returns the heap pointer and moves it by size
+			code([]byte{0x23, 0, 0x23, 0, 0x20, 0, 0x6a, 0x24, 0}),
+			// describe
+			code(i32const(1024), i32const(int64(len(toolsJSON))), []byte{0x10, 0}),
+			// call
+			code(
+				[]byte{0x20, 1}, i32const(5), []byte{0x46, 0x04, 0x40, 0x03, 0x40, 0x0c, 0, end, end},
+				[]byte{0x20, 3, 0x45, 0x04, 0x40}, i32const(2048), i32const(int64(len(errMessage))), []byte{0x10, 1, 0x0f, end},
+				[]byte{0x20, 2, 0x20, 3, 0x10, 0},
+			),
+		),
+		section(11, // data
+			cat([]byte{0x00}, i32const(1024), []byte{end}, name(toolsJSON)),
+			cat([]byte{0x00}, i32const(2048), []byte{end}, name(errMessage)),
+		),
+	)
+}
+
+func uleb(v uint64) []byte {
+	var out []byte
+	for {
+		b := byte(v & 0x7f)
+		v >>= 7
+		if v == 0 {
+			return append(out, b)
+		}
+		out = append(out, b|0x80)
+	}
+}
+
+func sleb(v int64) []byte {
+	var out []byte
+	for {
+		b := byte(v & 0x7f)
+		v >>= 7
+		if (v == 0 && b&0x40 == 0) || (v == -1 && b&0x40 != 0) {
+			return append(out, b)
+		}
+		out = append(out, b|0x80)
+	}
+}
+
+const testTools = `[{"name": "echo", "description": "Echoes its arguments"}, {"name": "sleep", "description": "Never returns"}]`
+
+func TestPlugin(t *testing.T) {
+	ctx := context.Background()
+	plugin, err := Load(ctx, testModule(testTools), WithTimeout(200*time.Millisecond), WithLog(io.Discard))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer plugin.Close(ctx)
+
+	tools := plugin.Tools()
+	if len(tools) != 2 || tools[0].Name != "echo" || tools[0].Description != "Echoes its arguments" || string(tools[0].Parameters) != `{"type":"object"}` {
+		t.Fatalf("unexpected tools %+v", tools)
+	}
+	result, err := tools[0].Call(ctx, `{"text": "hello"}`)
+	if err != nil || result != `{"text": "hello"}` {
+		t.Errorf("got %q %v", result, err)
+	}
+	if _, err := tools[0].Call(ctx, ""); err == nil || err.Error() != "empty arguments" {
+		t.Errorf("expected the error of the plugin, got %v", err)
+	}
+	if _, err := tools[1].Call(ctx, "{}"); err == nil || !strings.Contains(err.Error(), "timeout") {
+		t.Errorf("expected a timeout, got %v", err)
+	}
+	// The instance of the interrupted call is discarded
+	if result, err := tools[0].Call(ctx, "{}"); err != nil || result != "{}" {
+		t.Errorf("got %q %v", result, err)
+	}
+
+	if _, err := Load(ctx, []byte("\x00asm\x01\x00\x00\x00")); err == nil || !strings.Contains(err.Error(), "does not export") {
+		t.Errorf("expected a missing export error, got %v", err)
+	}
+	if _, err := Load(ctx, testModule(`{}`)); err == nil || !strings.Contains(err.Error(), "invalid tool definitions") {
+		t.Errorf("expected an invalid definitions error, got %v", err)
+	}
+}
+
+func TestDir(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "text.wasm")
+	if err := os.WriteFile(path, testModule(testTools), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	plugins, err := OpenDir(ctx, dir, WithLog(io.Discard))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer plugins.Close(ctx)
+	tools := plugins.Tools()
+	if len(tools) != 2 {
+		t.Fatalf("unexpected tools %+v", tools)
+	}
+
+	updated := `[{"name": "echo", "description": "Echoes its arguments back"}]`
+	if err := os.WriteFile(path, testModule(updated), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// A second plugin defining the same tool is rejected
+	if err := os.WriteFile(filepath.Join(dir, "z.wasm"), testModule(updated), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := plugins.Reload(ctx); err == nil || !strings.Contains(err.Error(), "already defined") {
+		t.Errorf("expected a duplicate tool error, got %v", err)
+	}
+	if reloaded := plugins.Tools(); len(reloaded) != 1 || reloaded[0].Description != "Echoes its arguments back" {
+		t.Errorf("unexpected reloaded tools %+v", reloaded)
+	}
+	// The tools returned before the reload call the new version
+	if result, err := tools[0].Call(ctx, "{}"); err != nil || result != "{}" {
+		t.Errorf("got %q %v", result, err)
+	}
+	if _, err := tools[1].Call(ctx, "{}"); err == nil || !strings.Contains(err.Error(), "unknown tool") {
+		t.Errorf("expected the removed tool to fail, got %v", err)
+	}
+
+	// An invalid update keeps the previous version
+	if err := os.WriteFile(path, []byte("invalid"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := plugins.Reload(ctx); err == nil {
+		t.Error("expected a load error")
+	}
+	if result, err := tools[0].Call(ctx, "{}"); err != nil || result != "{}" {
+		t.Errorf("got %q %v", result, err)
+	}
+}
@@ -0,0 +1,78 @@
+package workflowaieval
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/workflowai/workflowai/go/examples/workflowai"
+)
+
+// AssertJSONMatchesSchema fails the test if output is not valid JSON matching
+// schema. Both can be a string, a []byte, a json.RawMessage or any value that
+// can be marshalled to JSON.
+func AssertJSONMatchesSchema(t testing.TB, schema any, output any) bool {
+	t.Helper()
+	s, err := toJSON(schema)
+	if err != nil {
+		t.Fatalf("encoding schema: %v", err)
+	}
+	o, err := toJSON(output)
+	if err != nil {
+		t.Errorf("encoding output: %v", err)
+		return false
+	}
+	if err := workflowai.ValidateJSONSchema(s, o); err != nil {
+		t.Errorf("output does not match the schema: %v\noutput: %s", err, o)
+		return false
+	}
+	return true
+}
+
+// AssertContainsAllOf fails the test if output does not contain every one of
+// the substrings. The comparison is case insensitive.
+func AssertContainsAllOf(t testing.TB, output string, substrings ...string) bool {
+	t.Helper()
+	lower := strings.ToLower(output)
+	var missing []string
+	for _, s := range substrings {
+		if !strings.Contains(lower, strings.ToLower(s)) {
+			missing = append(missing, s)
+		}
+	}
+	if len(missing) > 0 {
+		t.Errorf("output is missing %q\noutput: %s", missing, output)
+		return false
+	}
+	return true
+}
+
+// AssertJudgedBy fails the test if model judges that output does not satisfy
+// rubric. The reasoning of the judge is logged in both cases.
+func AssertJudgedBy(t testing.TB, completions workflowai.ChatCompleter, model string, rubric string, output string) bool {
+	t.Helper()
+	verdict, err := Judge(context.Background(), completions, model, rubric, output, "")
+	if err != nil {
+		t.Fatalf("judging output: %v", err)
+	}
+	if !verdict.Pass {
+		t.Errorf("judge %s rejected the output (score %.2f): %s\noutput: %s", model, verdict.Score, verdict.Reasoning, output)
+		return false
+	}
+	t.Logf("judge %s accepted the output (score %.2f): %s", model, verdict.Score, verdict.Reasoning)
+	return true
+}
+
+func toJSON(v any) (json.RawMessage, error) {
+	switch v := v.(type) {
+	case json.RawMessage:
+		return v, nil
+	case []byte:
+		return v, nil
+	case string:
+		return json.RawMessage(v), nil
+	default:
+		return json.Marshal(v)
+	}
+}
@@ -0,0 +1,76 @@
+package workflowaieval
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+// recordingT records failures instead of failing the test.
+type recordingT struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recordingT) Helper() {}
+
+func (r *recordingT) Errorf(format string, args ...any) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func (r *recordingT) Logf(string, ...any) {}
+
+type judgeCompleter struct {
+	verdict string
+	params  openai.ChatCompletionNewParams
+}
+
+func (j *judgeCompleter) New(_ context.Context, body openai.ChatCompletionNewParams, _ ...option.RequestOption) (*openai.ChatCompletion, error) {
+	j.params = body
+	return &openai.ChatCompletion{
+		Choices: []openai.ChatCompletionChoice{{Message: openai.ChatCompletionMessage{Content: j.verdict}}},
+	}, nil
+}
+
+func TestAssertJSONMatchesSchema(t *testing.T) {
+	schema := map[string]any{
+		"type":       "object",
+		"properties": map[string]any{"answer": map[string]any{"type": "string"}},
+		"required":   []string{"answer"},
+	}
+	AssertJSONMatchesSchema(t, schema, `{"answer": "42"}`)
+
+	r := &recordingT{TB: t}
+	if AssertJSONMatchesSchema(r, schema, map[string]any{"answer": 42}) {
+		t.Fatal("expected the assertion to fail")
+	}
+	if len(r.errors) != 1 {
+		t.Fatalf("expected a single error, got %v", r.errors)
+	}
+}
+
+func TestAssertContainsAllOf(t *testing.T) {
+	AssertContainsAllOf(t, "The Eiffel Tower is in Paris", "eiffel", "paris")
+
+	r := &recordingT{TB: t}
+	if AssertContainsAllOf(r, "The Eiffel Tower is in Paris", "London", "paris") {
+		t.Fatal("expected the assertion to fail")
+	}
+}
+
+func TestAssertJudgedBy(t *testing.T) {
+	judge := &judgeCompleter{verdict: `{"pass": true, "score": 0.9, "reasoning": "polite"}`}
+	AssertJudgedBy(t, judge, "gpt-4o-mini", "The answer is polite", "Thank you!")
+	if judge.params.Model != "gpt-4o-mini" || judge.params.ResponseFormat.OfJSONSchema == nil {
+		t.Fatalf("unexpected judge params %+v", judge.params)
+	}
+
+	judge.verdict = `{"pass": false, "score": 0.1, "reasoning": "rude"}`
+	r := &recordingT{TB: t}
+	if AssertJudgedBy(r, judge, "gpt-4o-mini", "The answer is polite", "Go away") {
+		t.Fatal("expected the assertion to fail")
+	}
+}
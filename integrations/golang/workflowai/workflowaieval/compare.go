@@ -0,0 +1,151 @@
+package workflowaieval
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openai/openai-go"
+
+	"github.com/workflowai/workflowai/go/examples/workflowai"
+)
+
+// Candidate is a model or agent version compared by [Compare].
+type Candidate struct {
+	Name   string
+	Runner Runner
+}
+
+// ModelCandidates returns a candidate per model, each running params with
+// [CompletionRunner] after replacing the model. Models can be plain models
+// ("my-agent/gpt-4o") or deployments ("my-agent/#1/production").
+func ModelCandidates(completions workflowai.ChatCompleter, params openai.ChatCompletionNewParams, models ...string) []Candidate {
+	candidates := make([]Candidate, len(models))
+	for i, model := range models {
+		p := params
+		p.Model = model
+		candidates[i] = Candidate{Name: model, Runner: CompletionRunner(completions, p)}
+	}
+	return candidates
+}
+
+// Comparison describes the evaluation of several candidates over the same
+// dataset. The dataset, matcher, concurrency and timeout apply to every
+// candidate, and candidates are evaluated concurrently.
+type Comparison struct {
+	Dataset    *workflowai.Dataset
+	Candidates []Candidate
+	// Matcher scores the outputs, typically a [JudgeMatcher]. Defaults to Exact.
+	Matcher     Matcher
+	Concurrency int
+	Timeout     time.Duration
+}
+
+// CandidateReport is the evaluation of a single candidate.
+type CandidateReport struct {
+	Name string
+	*Report
+}
+
+// ComparisonReport holds the reports of the candidates, in the order of the
+// comparison.
+type ComparisonReport struct {
+	Candidates []CandidateReport
+}
+
+// Compare evaluates every candidate of the comparison over its dataset.
+func Compare(ctx context.Context, comparison Comparison) (*ComparisonReport, error) {
+	if len(comparison.Candidates) == 0 {
+		return nil, errors.New("workflowaieval: at least one candidate is required")
+	}
+	report := &ComparisonReport{Candidates: make([]CandidateReport, len(comparison.Candidates))}
+	errs := make([]error, len(comparison.Candidates))
+	var wg sync.WaitGroup
+	for i, candidate := range comparison.Candidates {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r, err := Evaluate(ctx, Suite{
+				Dataset:     comparison.Dataset,
+				Runner:      candidate.Runner,
+				Matcher:     comparison.Matcher,
+				Concurrency: comparison.Concurrency,
+				Timeout:     comparison.Timeout,
+			})
+			if err != nil {
+				errs[i] = fmt.Errorf("%s: %w", candidate.Name, err)
+				return
+			}
+			report.Candidates[i] = CandidateReport{Name: candidate.Name, Report: r}
+		}()
+	}
+	wg.Wait()
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// CostPerItem returns the average cost of the cases of the report.
+func (r *Report) CostPerItem() float64 {
+	if len(r.Results) == 0 {
+		return 0
+	}
+	total := 0.0
+	for _, result := range r.Results {
+		total += result.CostUSD
+	}
+	return total / float64(len(r.Results))
+}
+
+// LatencyPercentile returns the p-th percentile (0 < p <= 100) of the
+// duration of the cases that did not fail.
+func (r *Report) LatencyPercentile(p float64) time.Duration {
+	durations := make([]time.Duration, 0, len(r.Results))
+	for _, result := range r.Results {
+		if result.Error == "" {
+			durations = append(durations, result.Duration)
+		}
+	}
+	if len(durations) == 0 {
+		return 0
+	}
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	i := int(float64(len(durations))*p/100+0.5) - 1
+	return durations[min(max(i, 0), len(durations)-1)]
+}
+
+// WriteMarkdown writes a comparison table of the candidates, sorted by
+// decreasing average score.
+func (r *ComparisonReport) WriteMarkdown(w io.Writer) error {
+	candidates := make([]CandidateReport, len(r.Candidates))
+	copy(candidates, r.Candidates)
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].AverageScore() > candidates[j].AverageScore()
+	})
+
+	var b strings.Builder
+	b.WriteString("| Candidate | Score | Pass rate | Errors | p50 latency | p90 latency | Cost per item |\n")
+	b.WriteString("|---|---|---|---|---|---|---|\n")
+	for _, c := range candidates {
+		errs := 0
+		for _, result := range c.Results {
+			if result.Error != "" {
+				errs++
+			}
+		}
+		fmt.Fprintf(&b, "| %s | %.3f | %.1f%% | %d | %s | %s | $%.6f |\n",
+			c.Name, c.AverageScore(), c.PassRate()*100, errs,
+			c.LatencyPercentile(50).Round(time.Millisecond),
+			c.LatencyPercentile(90).Round(time.Millisecond),
+			c.CostPerItem(),
+		)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
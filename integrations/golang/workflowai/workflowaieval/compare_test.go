@@ -0,0 +1,48 @@
+package workflowaieval
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/workflowai/workflowai/go/examples/workflowai"
+)
+
+func TestCompare(t *testing.T) {
+	expensive := func(ctx context.Context, c workflowai.DatasetCase) (json.RawMessage, error) {
+		RecordCost(ctx, 0.5)
+		return doubleRunner(ctx, c)
+	}
+	wrong := func(ctx context.Context, c workflowai.DatasetCase) (json.RawMessage, error) {
+		RecordCost(ctx, 0.1)
+		return json.RawMessage(`{"double": 0}`), nil
+	}
+
+	report, err := Compare(context.Background(), Comparison{
+		Dataset: testDataset(t),
+		Candidates: []Candidate{
+			{Name: "wrong", Runner: wrong},
+			{Name: "expensive", Runner: expensive},
+		},
+		Matcher: JSONSubset(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Candidates[1].CostPerItem() != 0.5 {
+		t.Errorf("unexpected cost per item %f", report.Candidates[1].CostPerItem())
+	}
+
+	var b strings.Builder
+	if err := report.WriteMarkdown(&b); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(b.String(), "\n")
+	if !strings.HasPrefix(lines[2], "| expensive | 0.500 | 50.0% | 1 |") {
+		t.Errorf("expected the best candidate first:\n%s", b.String())
+	}
+	if !strings.HasPrefix(lines[3], "| wrong | 0.250 | 25.0% | 0 |") || !strings.HasSuffix(lines[3], "| $0.100000 |") {
+		t.Errorf("unexpected row for the wrong candidate:\n%s", b.String())
+	}
+}
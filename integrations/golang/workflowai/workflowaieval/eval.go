@@ -0,0 +1,218 @@
+// Package workflowaieval runs agents over golden datasets from Go tests and
+// reports the differences with the expected outputs.
+//
+//	func TestAgent(t *testing.T) {
+//		ds, err := workflowai.LoadDataset("testdata/cases.jsonl")
+//		if err != nil {
+//			t.Fatal(err)
+//		}
+//		workflowaieval.Run(t, workflowaieval.Suite{
+//			Dataset: ds,
+//			Runner:  workflowaieval.CompletionRunner(&client.Chat.Completions, params),
+//			Matcher: workflowaieval.JSONSubset(),
+//		})
+//	}
+package workflowaieval
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+
+	"github.com/workflowai/workflowai/go/examples/workflowai"
+)
+
+// Runner computes the output of the agent for a dataset case.
+type Runner func(ctx context.Context, c workflowai.DatasetCase) (json.RawMessage, error)
+
+// CompletionRunner returns a Runner that creates a chat completion for each
+// case. The case input is sent as the WorkflowAI "input" field, which is used
+// to render the templated messages of params, and the case is linked to the
+// run through metadata. The message content is parsed as JSON, and used as a
+// JSON string when it is not valid JSON.
+func CompletionRunner(completions workflowai.ChatCompleter, params openai.ChatCompletionNewParams) Runner {
+	return func(ctx context.Context, c workflowai.DatasetCase) (json.RawMessage, error) {
+		opts := []option.RequestOption{option.WithJSONSet("input", c.Input)}
+		if ds := datasetFromContext(ctx); ds != nil {
+			opts = append(opts, option.WithJSONSet("metadata", ds.Metadata(c)))
+		}
+		completion, err := completions.New(ctx, params, opts...)
+		if err != nil {
+			return nil, err
+		}
+		RecordCost(ctx, workflowai.CompletionCost(completion))
+		if len(completion.Choices) == 0 {
+			return nil, errors.New("completion has no choices")
+		}
+		content := completion.Choices[0].Message.Content
+		if json.Valid([]byte(content)) {
+			return json.RawMessage(content), nil
+		}
+		b, _ := json.Marshal(content)
+		return b, nil
+	}
+}
+
+type datasetKey struct{}
+
+func datasetFromContext(ctx context.Context) *workflowai.Dataset {
+	ds, _ := ctx.Value(datasetKey{}).(*workflowai.Dataset)
+	return ds
+}
+
+type costKey struct{}
+
+type caseCost struct {
+	mu  sync.Mutex
+	usd float64
+}
+
+// RecordCost adds usd to the cost of the case being evaluated. Runners that
+// do not use CompletionRunner call it to report the cost of their runs.
+func RecordCost(ctx context.Context, usd float64) {
+	if cost, ok := ctx.Value(costKey{}).(*caseCost); ok {
+		cost.mu.Lock()
+		cost.usd += usd
+		cost.mu.Unlock()
+	}
+}
+
+// Suite describes an evaluation of an agent over a dataset.
+type Suite struct {
+	Dataset *workflowai.Dataset
+	Runner  Runner
+	// Matcher compares outputs to expectations. Defaults to Exact. Cases
+	// without an expected output pass as long as the runner succeeds.
+	Matcher Matcher
+	// Concurrency is the number of cases evaluated in parallel. Defaults to 4.
+	Concurrency int
+	// Timeout applies to each case. Defaults to no timeout.
+	Timeout time.Duration
+}
+
+// Evaluate runs every case of the suite and returns the report. It returns an
+// error only when the suite is misconfigured, failures of individual cases are
+// recorded in the report.
+func Evaluate(ctx context.Context, suite Suite) (*Report, error) {
+	if suite.Dataset == nil || suite.Runner == nil {
+		return nil, errors.New("workflowaieval: a dataset and a runner are required")
+	}
+	matcher := suite.Matcher
+	if matcher == nil {
+		matcher = Exact()
+	}
+	concurrency := suite.Concurrency
+	if concurrency <= 0 {
+		concurrency = 4
+	}
+	ctx = context.WithValue(ctx, datasetKey{}, suite.Dataset)
+
+	report := &Report{
+		DatasetID:   suite.Dataset.ID,
+		DatasetName: suite.Dataset.Name,
+		Results:     make([]CaseResult, len(suite.Dataset.Cases)),
+	}
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, c := range suite.Dataset.Cases {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			report.Results[i] = evaluateCase(ctx, suite, matcher, c)
+		}()
+	}
+	wg.Wait()
+	return report, nil
+}
+
+func evaluateCase(ctx context.Context, suite Suite, matcher Matcher, c workflowai.DatasetCase) CaseResult {
+	if suite.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, suite.Timeout)
+		defer cancel()
+	}
+
+	result := CaseResult{CaseID: c.ID, Input: c.Input, Expected: c.ExpectedOutput}
+	cost := &caseCost{}
+	start := time.Now()
+	output, err := suite.Runner(context.WithValue(ctx, costKey{}, cost), c)
+	result.Duration = time.Since(start)
+	cost.mu.Lock()
+	result.CostUSD = cost.usd
+	cost.mu.Unlock()
+	if err != nil {
+		result.Error = workflowai.RedactSecrets(err.Error())
+		return result
+	}
+	result.Output = output
+
+	if len(c.ExpectedOutput) == 0 {
+		result.Match = MatchResult{Pass: true, Score: 1}
+		return result
+	}
+	match, err := matcher.Match(ctx, c.ExpectedOutput, output)
+	if err != nil {
+		result.Error = workflowai.RedactSecrets(err.Error())
+		return result
+	}
+	result.Match = match
+	if !match.Pass {
+		result.Diff = Diff(c.ExpectedOutput, output)
+	}
+	return result
+}
+
+// ReportDirEnv is the environment variable that, when set, makes [Run] write
+// a markdown report for each suite to the given directory.
+const ReportDirEnv = "WORKFLOWAI_EVAL_REPORT_DIR"
+
+// Run evaluates the suite and reports each case as a subtest of t. Failing
+// cases log the diff between the expected and actual outputs.
+func Run(t *testing.T, suite Suite) *Report {
+	t.Helper()
+	report, err := Evaluate(context.Background(), suite)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, result := range report.Results {
+		t.Run(result.CaseID, func(t *testing.T) {
+			if result.Error != "" {
+				t.Fatalf("case failed: %s", result.Error)
+			}
+			if !result.Match.Pass {
+				t.Errorf("output does not match the expectation: %s\n%s", result.Match.Reason, result.Diff)
+			}
+		})
+	}
+
+	if dir := os.Getenv(ReportDirEnv); dir != "" {
+		if err := writeReport(dir, t.Name(), report); err != nil {
+			t.Errorf("writing the eval report: %v", err)
+		}
+	}
+	return report
+}
+
+func writeReport(dir string, testName string, report *Report) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	f, err := os.Create(filepath.Join(dir, strings.ReplaceAll(testName, "/", "_")+".md"))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return report.WriteMarkdown(f)
+}
@@ -0,0 +1,137 @@
+package workflowaieval
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/workflowai/workflowai/go/examples/workflowai"
+)
+
+func testDataset(t *testing.T) *workflowai.Dataset {
+	t.Helper()
+	ds, err := workflowai.ReadJSONLDataset(strings.NewReader(`{"id": "ok", "input": {"n": 1}, "expected_output": {"double": 2}}
+{"id": "wrong", "input": {"n": 2}, "expected_output": {"double": 5}}
+{"id": "error", "input": {"n": -1}, "expected_output": {"double": -2}}
+{"id": "no-expectation", "input": {"n": 3}}`), "doubles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ds
+}
+
+func doubleRunner(_ context.Context, c workflowai.DatasetCase) (json.RawMessage, error) {
+	var input struct{ N int }
+	if err := json.Unmarshal(c.Input, &input); err != nil {
+		return nil, err
+	}
+	if input.N < 0 {
+		return nil, errors.New("negative input")
+	}
+	return json.Marshal(map[string]any{"double": input.N * 2, "extra": true})
+}
+
+func TestEvaluate(t *testing.T) {
+	report, err := Evaluate(context.Background(), Suite{
+		Dataset: testDataset(t),
+		Runner:  doubleRunner,
+		Matcher: JSONSubset(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	passed := map[string]bool{}
+	for _, r := range report.Results {
+		passed[r.CaseID] = r.Passed()
+	}
+	if !passed["ok"] || passed["wrong"] || passed["error"] || !passed["no-expectation"] {
+		t.Fatalf("unexpected results %v", passed)
+	}
+	if report.PassRate() != 0.5 {
+		t.Fatalf("unexpected pass rate %f", report.PassRate())
+	}
+
+	wrong := report.Results[1]
+	if !strings.Contains(wrong.Diff, `-   "double": 5`) || !strings.Contains(wrong.Diff, `+   "double": 4,`) {
+		t.Fatalf("unexpected diff:\n%s", wrong.Diff)
+	}
+
+	var buf bytes.Buffer
+	if err := report.WriteMarkdown(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "## wrong") || !strings.Contains(buf.String(), "Error: negative input") {
+		t.Fatalf("unexpected report:\n%s", buf.String())
+	}
+}
+
+func TestRun(t *testing.T) {
+	ds, err := workflowai.ReadJSONLDataset(strings.NewReader(`{"input": {"n": 1}, "expected_output": {"double": 2.0}}`), "doubles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := Run(t, Suite{Dataset: ds, Runner: doubleRunner, Matcher: JSONSubset()})
+	if report.PassRate() != 1 {
+		t.Fatalf("unexpected pass rate %f", report.PassRate())
+	}
+}
+
+func TestMatchers(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name     string
+		matcher  Matcher
+		expected string
+		actual   string
+		pass     bool
+	}{
+		{"exact ignores key order", Exact(), `{"a": 1, "b": [1, 2]}`, `{"b":[1,2],"a":1.0}`, true},
+		{"exact rejects extra fields", Exact(), `{"a": 1}`, `{"a": 1, "b": 2}`, false},
+		{"exact rejects missing null field", Exact(), `{"a": null}`, `{"b": null}`, false},
+		{"subset accepts extra fields", JSONSubset(), `{"a": {"b": 1}}`, `{"a": {"b": 1, "c": 2}, "d": 3}`, true},
+		{"subset compares arrays by index", JSONSubset(), `{"a": [{"b": 1}]}`, `{"a": [{"b": 1, "c": 2}]}`, true},
+		{"subset rejects different values", JSONSubset(), `{"a": {"b": 1}}`, `{"a": {"b": 2}}`, false},
+		{"subset rejects invalid json", JSONSubset(), `{"a": 1}`, `not json`, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			res, err := test.matcher.Match(ctx, json.RawMessage(test.expected), json.RawMessage(test.actual))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.Pass != test.pass {
+				t.Fatalf("expected pass=%v, got %+v", test.pass, res)
+			}
+		})
+	}
+}
+
+func TestSemanticSimilarity(t *testing.T) {
+	embed := func(_ context.Context, texts []string) ([][]float64, error) {
+		out := make([][]float64, len(texts))
+		for i, text := range texts {
+			if strings.Contains(text, "cat") {
+				out[i] = []float64{1, 0.1}
+			} else {
+				out[i] = []float64{0.1, 1}
+			}
+		}
+		return out, nil
+	}
+	matcher := SemanticSimilarity(embed, 0.9)
+	res, err := matcher.Match(context.Background(), json.RawMessage(`"a cat"`), json.RawMessage(`"the cat"`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Pass {
+		t.Fatalf("expected similar outputs to pass, got %+v", res)
+	}
+	res, _ = matcher.Match(context.Background(), json.RawMessage(`"a cat"`), json.RawMessage(`"a dog"`))
+	if res.Pass {
+		t.Fatalf("expected different outputs to fail, got %+v", res)
+	}
+}
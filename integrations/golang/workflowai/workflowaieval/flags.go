@@ -0,0 +1,90 @@
+package workflowaieval
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/openai/openai-go"
+
+	"github.com/workflowai/workflowai/go/examples/workflowai"
+)
+
+// BenchmarkFlags are the command line flags of a model comparison, shared by
+// the workflowai-benchmark and "workflowai eval" commands.
+type BenchmarkFlags struct {
+	Dataset      string
+	Models       string
+	Prompt       string
+	Instructions string
+	Judge        string
+	Rubric       string
+	Match        string
+	Concurrency  int
+	Timeout      time.Duration
+}
+
+// Register defines the flags in fs.
+func (f *BenchmarkFlags) Register(fs *flag.FlagSet) {
+	fs.StringVar(&f.Dataset, "dataset", "", "JSONL or CSV dataset (required)")
+	fs.StringVar(&f.Models, "models", "", `comma separated models or deployments to compare, e.g. "gpt-4o,#1/production" (required)`)
+	fs.StringVar(&f.Prompt, "prompt", "", "templated user message rendered with the input of each case")
+	fs.StringVar(&f.Instructions, "instructions", "", "templated system message rendered with the input of each case")
+	fs.StringVar(&f.Judge, "judge", "", "model used to score the outputs against -rubric")
+	fs.StringVar(&f.Rubric, "rubric", "The output correctly and completely answers the input.", "rubric used by the judge")
+	fs.StringVar(&f.Match, "match", "exact", "matcher used without a judge: exact or subset")
+	fs.IntVar(&f.Concurrency, "concurrency", 4, "number of cases evaluated in parallel for each model")
+	fs.DurationVar(&f.Timeout, "timeout", 2*time.Minute, "timeout of each case")
+}
+
+// Comparison loads the dataset and returns the comparison of the models, each
+// prefixed with prefix when it is not empty, e.g. an agent ID.
+func (f *BenchmarkFlags) Comparison(completions workflowai.ChatCompleter, prefix string) (Comparison, error) {
+	if f.Dataset == "" || f.Models == "" {
+		return Comparison{}, errors.New("-dataset and -models are required")
+	}
+	matcher, err := f.matcher(completions)
+	if err != nil {
+		return Comparison{}, err
+	}
+	ds, err := workflowai.LoadDataset(f.Dataset)
+	if err != nil {
+		return Comparison{}, err
+	}
+	var params openai.ChatCompletionNewParams
+	if f.Instructions != "" {
+		params.Messages = append(params.Messages, openai.SystemMessage(f.Instructions))
+	}
+	if f.Prompt != "" {
+		params.Messages = append(params.Messages, openai.UserMessage(f.Prompt))
+	}
+	var models []string
+	for _, model := range strings.Split(f.Models, ",") {
+		model = strings.TrimSpace(model)
+		if prefix != "" {
+			model = prefix + "/" + model
+		}
+		models = append(models, model)
+	}
+	return Comparison{
+		Dataset:     ds,
+		Candidates:  ModelCandidates(completions, params, models...),
+		Matcher:     matcher,
+		Concurrency: f.Concurrency,
+		Timeout:     f.Timeout,
+	}, nil
+}
+
+func (f *BenchmarkFlags) matcher(completions workflowai.ChatCompleter) (Matcher, error) {
+	switch {
+	case f.Judge != "":
+		return JudgeMatcher(completions, f.Judge, f.Rubric), nil
+	case f.Match == "exact":
+		return Exact(), nil
+	case f.Match == "subset":
+		return JSONSubset(), nil
+	}
+	return nil, fmt.Errorf("unknown matcher %q, expected exact or subset", f.Match)
+}
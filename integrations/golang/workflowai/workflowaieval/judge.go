@@ -0,0 +1,91 @@
+package workflowaieval
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/openai/openai-go"
+
+	"github.com/workflowai/workflowai/go/examples/workflowai"
+)
+
+// Verdict is the evaluation of an output by a judge model.
+type Verdict struct {
+	Pass bool `json:"pass"`
+	// Score is between 0 and 1
+	Score     float64 `json:"score"`
+	Reasoning string  `json:"reasoning"`
+}
+
+const judgeInstructions = `You are evaluating the output of an AI agent against a rubric.
+Read the rubric and the output carefully, then decide whether the output satisfies the rubric.
+Give a score between 0 and 1 reflecting how well the output satisfies the rubric and explain your reasoning briefly.`
+
+var judgeSchema = map[string]any{
+	"type": "object",
+	"properties": map[string]any{
+		"reasoning": map[string]any{"type": "string"},
+		"pass":      map[string]any{"type": "boolean"},
+		"score":     map[string]any{"type": "number"},
+	},
+	"required":             []string{"reasoning", "pass", "score"},
+	"additionalProperties": false,
+}
+
+// Judge asks model to evaluate output against rubric. When extraContext is
+// not empty, it is provided to the judge, for example the input of the agent
+// or a reference answer.
+func Judge(ctx context.Context, completions workflowai.ChatCompleter, model string, rubric string, output string, extraContext string) (Verdict, error) {
+	prompt := fmt.Sprintf("<rubric>\n%s\n</rubric>\n\n<output>\n%s\n</output>", rubric, output)
+	if extraContext != "" {
+		prompt = fmt.Sprintf("<context>\n%s\n</context>\n\n%s", extraContext, prompt)
+	}
+
+	completion, err := completions.New(ctx, openai.ChatCompletionNewParams{
+		Model: model,
+		Messages: []openai.ChatCompletionMessageParamUnion{
+			openai.SystemMessage(judgeInstructions),
+			openai.UserMessage(prompt),
+		},
+		Temperature: openai.Float(0),
+		ResponseFormat: openai.ChatCompletionNewParamsResponseFormatUnion{
+			OfJSONSchema: &openai.ResponseFormatJSONSchemaParam{
+				JSONSchema: openai.ResponseFormatJSONSchemaJSONSchemaParam{
+					Name:   "verdict",
+					Schema: judgeSchema,
+					Strict: openai.Bool(true),
+				},
+			},
+		},
+	})
+	if err != nil {
+		return Verdict{}, fmt.Errorf("workflowaieval: judging output: %w", err)
+	}
+	if len(completion.Choices) == 0 {
+		return Verdict{}, errors.New("workflowaieval: judge completion has no choices")
+	}
+
+	var verdict Verdict
+	if err := json.Unmarshal([]byte(completion.Choices[0].Message.Content), &verdict); err != nil {
+		return Verdict{}, fmt.Errorf("workflowaieval: decoding verdict: %w", err)
+	}
+	return verdict, nil
+}
+
+// JudgeMatcher returns a Matcher that asks model whether the output satisfies
+// rubric, using the expected output as a reference answer.
+func JudgeMatcher(completions workflowai.ChatCompleter, model string, rubric string) Matcher {
+	return MatcherFunc(func(ctx context.Context, expected json.RawMessage, actual json.RawMessage) (MatchResult, error) {
+		reference := ""
+		if len(expected) > 0 {
+			reference = "Reference answer:\n" + string(expected)
+		}
+		verdict, err := Judge(ctx, completions, model, rubric, string(actual), reference)
+		if err != nil {
+			return MatchResult{}, err
+		}
+		return MatchResult{Pass: verdict.Pass, Score: verdict.Score, Reason: verdict.Reasoning}, nil
+	})
+}
@@ -0,0 +1,232 @@
+package workflowaieval
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+
+	"github.com/openai/openai-go"
+)
+
+// MatchResult is the outcome of comparing an output with its expectation.
+type MatchResult struct {
+	Pass bool
+	// Score is between 0 and 1
+	Score  float64
+	Reason string
+}
+
+// Matcher compares the output of an agent with the expected output of a case.
+type Matcher interface {
+	Match(ctx context.Context, expected json.RawMessage, actual json.RawMessage) (MatchResult, error)
+}
+
+// MatcherFunc adapts a function to the Matcher interface.
+type MatcherFunc func(ctx context.Context, expected json.RawMessage, actual json.RawMessage) (MatchResult, error)
+
+func (f MatcherFunc) Match(ctx context.Context, expected json.RawMessage, actual json.RawMessage) (MatchResult, error) {
+	return f(ctx, expected, actual)
+}
+
+func decode(raw json.RawMessage) (any, error) {
+	var v any
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	if err := decoder.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func passOrFail(pass bool, reason string) MatchResult {
+	if pass {
+		return MatchResult{Pass: true, Score: 1}
+	}
+	return MatchResult{Pass: false, Score: 0, Reason: reason}
+}
+
+// Exact passes when the output is the same JSON value as the expectation,
+// ignoring formatting and key order.
+func Exact() Matcher {
+	return MatcherFunc(func(_ context.Context, expected json.RawMessage, actual json.RawMessage) (MatchResult, error) {
+		e, err := decode(expected)
+		if err != nil {
+			return MatchResult{}, fmt.Errorf("decoding expected output: %w", err)
+		}
+		a, err := decode(actual)
+		if err != nil {
+			return passOrFail(false, "output is not valid JSON"), nil
+		}
+		return passOrFail(equal(e, a), "output differs from the expected output"), nil
+	})
+}
+
+// JSONSubset passes when every field of the expectation is present with the
+// same value in the output. Additional fields in the output are ignored.
+// Arrays must have the same length and each element is compared as a subset.
+func JSONSubset() Matcher {
+	return MatcherFunc(func(_ context.Context, expected json.RawMessage, actual json.RawMessage) (MatchResult, error) {
+		e, err := decode(expected)
+		if err != nil {
+			return MatchResult{}, fmt.Errorf("decoding expected output: %w", err)
+		}
+		a, err := decode(actual)
+		if err != nil {
+			return passOrFail(false, "output is not valid JSON"), nil
+		}
+		if path, ok := isSubset(e, a, "$"); !ok {
+			return passOrFail(false, fmt.Sprintf("output does not match the expected value at %s", path)), nil
+		}
+		return passOrFail(true, ""), nil
+	})
+}
+
+func isSubset(expected any, actual any, path string) (string, bool) {
+	switch e := expected.(type) {
+	case map[string]any:
+		a, ok := actual.(map[string]any)
+		if !ok {
+			return path, false
+		}
+		for k, ev := range e {
+			av, ok := a[k]
+			if !ok {
+				return path + "." + k, false
+			}
+			if p, ok := isSubset(ev, av, path+"."+k); !ok {
+				return p, false
+			}
+		}
+		return "", true
+	case []any:
+		a, ok := actual.([]any)
+		if !ok || len(a) != len(e) {
+			return path, false
+		}
+		for i := range e {
+			if p, ok := isSubset(e[i], a[i], fmt.Sprintf("%s[%d]", path, i)); !ok {
+				return p, false
+			}
+		}
+		return "", true
+	default:
+		return path, equal(expected, actual)
+	}
+}
+
+// equal compares decoded JSON values, comparing numbers by value so that 1
+// and 1.0 are equal.
+func equal(a any, b any) bool {
+	if an, ok := a.(json.Number); ok {
+		bn, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		af, aerr := an.Float64()
+		bf, berr := bn.Float64()
+		if aerr != nil || berr != nil {
+			return an == bn
+		}
+		return af == bf
+	}
+	switch av := a.(type) {
+	case map[string]any:
+		bv, ok := b.(map[string]any)
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for k, v := range av {
+			w, ok := bv[k]
+			if !ok || !equal(v, w) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		bv, ok := b.([]any)
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for i := range av {
+			if !equal(av[i], bv[i]) {
+				return false
+			}
+		}
+		return true
+	default:
+		return reflect.DeepEqual(a, b)
+	}
+}
+
+// Embedder returns one embedding per text.
+type Embedder func(ctx context.Context, texts []string) ([][]float64, error)
+
+// OpenAIEmbedder returns an Embedder backed by an OpenAI compatible
+// embeddings endpoint.
+func OpenAIEmbedder(embeddings *openai.EmbeddingService, model string) Embedder {
+	return func(ctx context.Context, texts []string) ([][]float64, error) {
+		res, err := embeddings.New(ctx, openai.EmbeddingNewParams{
+			Model: model,
+			Input: openai.EmbeddingNewParamsInputUnion{OfArrayOfStrings: texts},
+		})
+		if err != nil {
+			return nil, err
+		}
+		out := make([][]float64, len(texts))
+		for _, d := range res.Data {
+			if int(d.Index) < len(out) {
+				out[d.Index] = d.Embedding
+			}
+		}
+		return out, nil
+	}
+}
+
+// SemanticSimilarity passes when the cosine similarity between the embeddings
+// of the expected and actual outputs is at least threshold. JSON strings are
+// embedded as their text value, other values as their JSON encoding.
+func SemanticSimilarity(embed Embedder, threshold float64) Matcher {
+	return MatcherFunc(func(ctx context.Context, expected json.RawMessage, actual json.RawMessage) (MatchResult, error) {
+		vectors, err := embed(ctx, []string{embeddableText(expected), embeddableText(actual)})
+		if err != nil {
+			return MatchResult{}, fmt.Errorf("embedding outputs: %w", err)
+		}
+		if len(vectors) != 2 {
+			return MatchResult{}, errors.New("embedder did not return 2 embeddings")
+		}
+		similarity := cosineSimilarity(vectors[0], vectors[1])
+		return MatchResult{
+			Pass:   similarity >= threshold,
+			Score:  math.Max(0, similarity),
+			Reason: fmt.Sprintf("similarity %.3f, threshold %.3f", similarity, threshold),
+		}, nil
+	})
+}
+
+func embeddableText(raw json.RawMessage) string {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+	return string(raw)
+}
+
+func cosineSimilarity(a []float64, b []float64) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += a[i] * b[i]
+		na += a[i] * a[i]
+		nb += b[i] * b[i]
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
@@ -0,0 +1,148 @@
+package workflowaieval
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// CaseResult is the evaluation of a single dataset case.
+type CaseResult struct {
+	CaseID   string          `json:"case_id"`
+	Input    json.RawMessage `json:"input"`
+	Expected json.RawMessage `json:"expected_output,omitempty"`
+	Output   json.RawMessage `json:"output,omitempty"`
+	Match    MatchResult     `json:"match"`
+	// Error is set when the runner or the matcher failed
+	Error    string        `json:"error,omitempty"`
+	Diff     string        `json:"diff,omitempty"`
+	Duration time.Duration `json:"duration"`
+	// CostUSD is the cost of the runs of the case, see [RecordCost]
+	CostUSD float64 `json:"cost_usd,omitempty"`
+}
+
+// Passed returns true if the case ran and matched its expectation.
+func (r CaseResult) Passed() bool {
+	return r.Error == "" && r.Match.Pass
+}
+
+type Report struct {
+	DatasetID   string       `json:"dataset_id"`
+	DatasetName string       `json:"dataset_name"`
+	Results     []CaseResult `json:"results"`
+}
+
+// PassRate returns the fraction of cases that passed.
+func (r *Report) PassRate() float64 {
+	if len(r.Results) == 0 {
+		return 0
+	}
+	passed := 0
+	for _, result := range r.Results {
+		if result.Passed() {
+			passed++
+		}
+	}
+	return float64(passed) / float64(len(r.Results))
+}
+
+// AverageScore returns the average match score, counting errors as 0.
+func (r *Report) AverageScore() float64 {
+	if len(r.Results) == 0 {
+		return 0
+	}
+	total := 0.0
+	for _, result := range r.Results {
+		if result.Error == "" {
+			total += result.Match.Score
+		}
+	}
+	return total / float64(len(r.Results))
+}
+
+// WriteMarkdown writes a human readable report with the diff of every
+// failing case, suitable for attaching to a pull request.
+func (r *Report) WriteMarkdown(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Evaluation of %s (%s)\n\n", r.DatasetName, r.DatasetID)
+	fmt.Fprintf(&b, "Pass rate: %.1f%% (%d cases), average score: %.3f\n\n", r.PassRate()*100, len(r.Results), r.AverageScore())
+	b.WriteString("| Case | Result | Score | Duration |\n|---|---|---|---|\n")
+	for _, result := range r.Results {
+		status := "pass"
+		switch {
+		case result.Error != "":
+			status = "error"
+		case !result.Match.Pass:
+			status = "fail"
+		}
+		fmt.Fprintf(&b, "| %s | %s | %.3f | %s |\n", result.CaseID, status, result.Match.Score, result.Duration.Round(time.Millisecond))
+	}
+
+	for _, result := range r.Results {
+		if result.Passed() {
+			continue
+		}
+		fmt.Fprintf(&b, "\n## %s\n\n", result.CaseID)
+		if result.Error != "" {
+			fmt.Fprintf(&b, "Error: %s\n", result.Error)
+			continue
+		}
+		if result.Match.Reason != "" {
+			fmt.Fprintf(&b, "%s\n\n", result.Match.Reason)
+		}
+		fmt.Fprintf(&b, "```diff\n%s```\n", result.Diff)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func indent(raw json.RawMessage) []string {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, raw, "", "  "); err != nil {
+		buf.Reset()
+		buf.Write(raw)
+	}
+	return strings.Split(buf.String(), "\n")
+}
+
+// Diff returns a line diff of the indented JSON values, prefixing removed
+// lines with "-" and added lines with "+".
+func Diff(expected json.RawMessage, actual json.RawMessage) string {
+	a, b := indent(expected), indent(actual)
+
+	// Longest common subsequence table
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var out strings.Builder
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			out.WriteString("  " + a[i] + "\n")
+			i++
+			j++
+		case j < len(b) && (i == len(a) || lcs[i][j+1] >= lcs[i+1][j]):
+			out.WriteString("+ " + b[j] + "\n")
+			j++
+		default:
+			out.WriteString("- " + a[i] + "\n")
+			i++
+		}
+	}
+	return out.String()
+}
@@ -0,0 +1,243 @@
+package workflowaitest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+	"github.com/openai/openai-go/packages/ssestream"
+
+	"github.com/workflowai/workflowai/go/examples/workflowai"
+)
+
+// ErrNoRule is returned by [FakeModel] for the requests that no rule matches.
+var ErrNoRule = errors.New("workflowaitest: no rule matches the request")
+
+// Match selects the requests answered by a [Rule].
+type Match func(Request) bool
+
+// ModelIs matches the requests to model, e.g. "my-agent/gpt-4o".
+func ModelIs(model string) Match {
+	return func(r Request) bool { return r.Model() == model }
+}
+
+// MessageContains matches the requests with a message containing substr.
+func MessageContains(substr string) Match {
+	return func(r Request) bool {
+		return slices.ContainsFunc(r.Messages(), func(m Message) bool { return strings.Contains(m.Content, substr) })
+	}
+}
+
+// LastMessageContains matches the requests whose last message contains substr,
+// e.g. to answer the second turn of a conversation differently.
+func LastMessageContains(substr string) Match {
+	return func(r Request) bool {
+		messages := r.Messages()
+		return len(messages) > 0 && strings.Contains(messages[len(messages)-1].Content, substr)
+	}
+}
+
+// HasTool matches the requests where the tool is available.
+func HasTool(name string) Match {
+	return func(r Request) bool { return slices.Contains(r.Tools(), name) }
+}
+
+// MetadataIs matches the requests with the metadata key set to value.
+func MetadataIs(key string, value string) Match {
+	return func(r Request) bool {
+		v, ok := r.Metadata()[key]
+		return ok && v == value
+	}
+}
+
+// All matches the requests matched by every match.
+func All(matches ...Match) Match {
+	return func(r Request) bool {
+		for _, match := range matches {
+			if !match(r) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Rule answers the requests it matches. The reply and the arguments of the
+// tool calls are text/template templates executed with the [Request], e.g.
+// `{"greeting": "Hello {{.Input.name}}"}`.
+type Rule struct {
+	// Match selects the requests, every request matches when nil
+	Match     Match
+	Reply     string
+	ToolCalls []ToolCall
+	// Err is returned instead of a completion
+	Err     error
+	CostUSD float64
+}
+
+// ToolCall is a tool call replied by a [Rule].
+type ToolCall struct {
+	Name      string
+	Arguments string
+}
+
+// FakeModel is a [workflowai.ChatService] answering from rules, without HTTP,
+// to test the code using completions deterministically. The first rule
+// matching a request answers it.
+type FakeModel struct {
+	rules []Rule
+
+	mu       sync.Mutex
+	requests []Request
+}
+
+var _ workflowai.ChatService = (*FakeModel)(nil)
+
+func NewFakeModel(rules ...Rule) *FakeModel {
+	return &FakeModel{rules: rules}
+}
+
+// Requests returns the requests received so far.
+func (m *FakeModel) Requests() []Request {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Request(nil), m.requests...)
+}
+
+// New answers a request with the first matching rule. The options are
+// ignored.
+func (m *FakeModel) New(ctx context.Context, body openai.ChatCompletionNewParams, _ ...option.RequestOption) (*openai.ChatCompletion, error) {
+	id, message, rule, err := m.answer(ctx, body)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(completionBody(id, message, finishReason(rule), rule.CostUSD))
+	if err != nil {
+		return nil, err
+	}
+	var completion openai.ChatCompletion
+	if err := json.Unmarshal(data, &completion); err != nil {
+		return nil, err
+	}
+	return &completion, nil
+}
+
+// NewStreaming streams the answer of New as a single chunk.
+func (m *FakeModel) NewStreaming(ctx context.Context, body openai.ChatCompletionNewParams, _ ...option.RequestOption) *ssestream.Stream[openai.ChatCompletionChunk] {
+	id, message, rule, err := m.answer(ctx, body)
+	if err != nil {
+		return ssestream.NewStream[openai.ChatCompletionChunk](nil, err)
+	}
+	if calls, ok := message["tool_calls"].([]any); ok {
+		for i, call := range calls {
+			call.(map[string]any)["index"] = i
+		}
+	}
+	stream := Stream{ID: id}
+	decoder := &eventDecoder{}
+	for _, choice := range []map[string]any{
+		{"index": 0, "delta": message},
+		{"index": 0, "delta": map[string]any{}, "finish_reason": finishReason(rule), "cost_usd": rule.CostUSD},
+	} {
+		decoder.events = append(decoder.events, ssestream.Event{Data: []byte(stream.chunk(choice))})
+	}
+	return ssestream.NewStream[openai.ChatCompletionChunk](decoder, nil)
+}
+
+// answer records the request and returns the run ID and the message of the
+// matching rule.
+func (m *FakeModel) answer(ctx context.Context, body openai.ChatCompletionNewParams) (string, map[string]any, Rule, error) {
+	if err := ctx.Err(); err != nil {
+		return "", nil, Rule{}, err
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return "", nil, Rule{}, err
+	}
+	request := Request{Header: map[string][]string{}, Body: data}
+	if err := json.Unmarshal(data, &request.Params); err != nil {
+		return "", nil, Rule{}, err
+	}
+
+	m.mu.Lock()
+	m.requests = append(m.requests, request)
+	id := runID(len(m.requests))
+	m.mu.Unlock()
+
+	i := slices.IndexFunc(m.rules, func(rule Rule) bool { return rule.Match == nil || rule.Match(request) })
+	if i < 0 {
+		return "", nil, Rule{}, fmt.Errorf("%w to %s", ErrNoRule, request.Model())
+	}
+	rule := m.rules[i]
+	if rule.Err != nil {
+		return "", nil, rule, rule.Err
+	}
+
+	message := map[string]any{"role": "assistant"}
+	if message["content"], err = render(rule.Reply, request); err != nil {
+		return "", nil, rule, err
+	}
+	var calls []any
+	for j, call := range rule.ToolCalls {
+		arguments, err := render(call.Arguments, request)
+		if err != nil {
+			return "", nil, rule, err
+		}
+		calls = append(calls, map[string]any{
+			"id":       fmt.Sprintf("call_%d", j+1),
+			"type":     "function",
+			"function": map[string]any{"name": call.Name, "arguments": arguments},
+		})
+	}
+	if len(calls) > 0 {
+		message["tool_calls"] = calls
+	}
+	return id, message, rule, nil
+}
+
+func render(text string, request Request) (string, error) {
+	if !strings.Contains(text, "{{") {
+		return text, nil
+	}
+	tmpl, err := template.New("reply").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("workflowaitest: parsing template: %w", err)
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, request); err != nil {
+		return "", fmt.Errorf("workflowaitest: executing template: %w", err)
+	}
+	return b.String(), nil
+}
+
+func finishReason(rule Rule) string {
+	if len(rule.ToolCalls) > 0 {
+		return "tool_calls"
+	}
+	return "stop"
+}
+
+// eventDecoder is an [ssestream.Decoder] of in-memory events.
+type eventDecoder struct {
+	events []ssestream.Event
+	i      int
+}
+
+func (d *eventDecoder) Next() bool {
+	if d.i >= len(d.events) {
+		return false
+	}
+	d.i++
+	return true
+}
+
+func (d *eventDecoder) Event() ssestream.Event { return d.events[d.i-1] }
+func (d *eventDecoder) Close() error           { return nil }
+func (d *eventDecoder) Err() error             { return nil }
@@ -0,0 +1,147 @@
+package workflowaitest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/shared"
+
+	"github.com/workflowai/workflowai/go/examples/workflowai"
+)
+
+func TestFakeModel(t *testing.T) {
+	errProvider := errors.New("provider failed")
+	fake := NewFakeModel(
+		Rule{Match: MetadataIs("tenant", "broken"), Err: errProvider},
+		Rule{Match: HasTool("lookup_order"), ToolCalls: []ToolCall{{Name: "lookup_order", Arguments: `{"id":"{{.Input.order_id}}"}`}}},
+		Rule{Match: All(ModelIs("support/gpt-4o"), LastMessageContains("refund")), Reply: `{"intent":"refund"}`, CostUSD: 0.002},
+		Rule{Match: MessageContains("hello"), Reply: "Hello {{.Input.name}}"},
+	)
+
+	tests := []struct {
+		name        string
+		params      openai.ChatCompletionNewParams
+		input       map[string]any
+		wantContent string
+		wantTool    string
+		wantArgs    string
+		wantCost    float64
+		wantErr     error
+	}{
+		{
+			name:        "template",
+			params:      openai.ChatCompletionNewParams{Model: "greeter/gpt-4o", Messages: []openai.ChatCompletionMessageParamUnion{openai.UserMessage("hello")}},
+			input:       map[string]any{"name": "Ada"},
+			wantContent: "Hello Ada",
+		},
+		{
+			name: "last message",
+			params: openai.ChatCompletionNewParams{Model: "support/gpt-4o", Messages: []openai.ChatCompletionMessageParamUnion{
+				openai.UserMessage("hello"),
+				openai.AssistantMessage("How can I help?"),
+				openai.UserMessage("I want a refund"),
+			}},
+			wantContent: `{"intent":"refund"}`,
+			wantCost:    0.002,
+		},
+		{
+			name: "tool",
+			params: openai.ChatCompletionNewParams{
+				Model:    "support/gpt-4o",
+				Messages: []openai.ChatCompletionMessageParamUnion{openai.UserMessage("where is my order?")},
+				Tools:    []openai.ChatCompletionToolParam{{Function: shared.FunctionDefinitionParam{Name: "lookup_order"}}},
+			},
+			input:    map[string]any{"order_id": "A12"},
+			wantTool: "lookup_order",
+			wantArgs: `{"id":"A12"}`,
+		},
+		{
+			name:    "error",
+			params:  openai.ChatCompletionNewParams{Model: "support/gpt-4o", Metadata: shared.Metadata{"tenant": "broken"}},
+			wantErr: errProvider,
+		},
+		{
+			name:    "no rule",
+			params:  openai.ChatCompletionNewParams{Model: "support/gpt-4o", Messages: []openai.ChatCompletionMessageParamUnion{openai.UserMessage("bye")}},
+			wantErr: ErrNoRule,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.input != nil {
+				tt.params.SetExtraFields(map[string]any{"input": tt.input})
+			}
+			completion, err := fake.New(context.Background(), tt.params)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			message := completion.Choices[0].Message
+			if message.Content != tt.wantContent {
+				t.Errorf("content = %q, want %q", message.Content, tt.wantContent)
+			}
+			if tt.wantTool != "" {
+				if len(message.ToolCalls) != 1 || message.ToolCalls[0].Function.Name != tt.wantTool || message.ToolCalls[0].Function.Arguments != tt.wantArgs {
+					t.Errorf("tool calls = %+v", message.ToolCalls)
+				}
+				if completion.Choices[0].FinishReason != "tool_calls" {
+					t.Errorf("finish reason = %q", completion.Choices[0].FinishReason)
+				}
+			}
+			if got := workflowai.CompletionCost(completion); got != tt.wantCost {
+				t.Errorf("cost = %v, want %v", got, tt.wantCost)
+			}
+		})
+	}
+	if got := len(fake.Requests()); got != len(tests) {
+		t.Errorf("got %d requests, want %d", got, len(tests))
+	}
+}
+
+func TestFakeModel_NewStreaming(t *testing.T) {
+	fake := NewFakeModel(
+		Rule{Match: HasTool("search"), ToolCalls: []ToolCall{{Name: "search", Arguments: `{"q":"go"}`}}},
+		Rule{Reply: "Hello"},
+	)
+
+	stream := fake.NewStreaming(context.Background(), openai.ChatCompletionNewParams{Model: "test/gpt-4o"})
+	var acc openai.ChatCompletionAccumulator
+	for stream.Next() {
+		acc.AddChunk(stream.Current())
+	}
+	if err := stream.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if got := acc.Choices[0].Message.Content; got != "Hello" {
+		t.Errorf("content = %q", got)
+	}
+
+	stream = fake.NewStreaming(context.Background(), openai.ChatCompletionNewParams{
+		Model: "test/gpt-4o",
+		Tools: []openai.ChatCompletionToolParam{{Function: shared.FunctionDefinitionParam{Name: "search"}}},
+	})
+	acc = openai.ChatCompletionAccumulator{}
+	for stream.Next() {
+		acc.AddChunk(stream.Current())
+	}
+	if err := stream.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if calls := acc.Choices[0].Message.ToolCalls; len(calls) != 1 || calls[0].Function.Name != "search" || calls[0].Function.Arguments != `{"q":"go"}` {
+		t.Errorf("tool calls = %+v", calls)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	stream = fake.NewStreaming(ctx, openai.ChatCompletionNewParams{Model: "test/gpt-4o"})
+	if stream.Next() || !errors.Is(stream.Err(), context.Canceled) {
+		t.Errorf("err = %v, want context.Canceled", stream.Err())
+	}
+}
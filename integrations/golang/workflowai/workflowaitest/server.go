@@ -0,0 +1,264 @@
+// Package workflowaitest provides a fake WorkflowAI server to test code using
+// the client offline.
+//
+//	server := workflowaitest.NewServer(t)
+//	server.Enqueue(
+//		workflowaitest.Completion{Content: `{"sentiment":"positive"}`},
+//		workflowaitest.Stream{Events: []workflowaitest.Event{
+//			workflowaitest.Chunk("Hello"),
+//			workflowaitest.Delay(100 * time.Millisecond),
+//			workflowaitest.ErrorChunk("provider_error", "the provider failed"),
+//		}},
+//	)
+//	client := server.Client()
+//
+// The responses are served in order to the chat completion requests, which
+// are recorded for assertions. [FakeModel] answers from rules instead, without
+// HTTP.
+package workflowaitest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/openai/openai-go/option"
+
+	"github.com/workflowai/workflowai/go/examples/workflowai"
+)
+
+// Response is a scripted response of the server: a [Completion], a
+// [Stream] or an [Error].
+type Response interface {
+	serve(w http.ResponseWriter, r *http.Request, req Request, n int)
+}
+
+// Request is a chat completion request received by the server.
+type Request struct {
+	Header http.Header
+	Body   json.RawMessage
+	// Params is the decoded body
+	Params map[string]any
+}
+
+// Model returns the model of the request, e.g. "my-agent/gpt-4o".
+func (r Request) Model() string {
+	model, _ := r.Params["model"].(string)
+	return model
+}
+
+// Stream returns true for streaming requests.
+func (r Request) Stream() bool {
+	stream, _ := r.Params["stream"].(bool)
+	return stream
+}
+
+// Input returns the WorkflowAI input of the request.
+func (r Request) Input() map[string]any {
+	input, _ := r.Params["input"].(map[string]any)
+	return input
+}
+
+// Metadata returns the metadata of the request.
+func (r Request) Metadata() map[string]any {
+	metadata, _ := r.Params["metadata"].(map[string]any)
+	return metadata
+}
+
+// Message is a message of a [Request].
+type Message struct {
+	Role string
+	// Content is the text of the message, its text parts concatenated
+	Content string
+}
+
+// Messages returns the messages of the request.
+func (r Request) Messages() []Message {
+	raw, _ := r.Params["messages"].([]any)
+	messages := make([]Message, 0, len(raw))
+	for _, m := range raw {
+		m, _ := m.(map[string]any)
+		message := Message{}
+		message.Role, _ = m["role"].(string)
+		switch content := m["content"].(type) {
+		case string:
+			message.Content = content
+		case []any:
+			for _, part := range content {
+				part, _ := part.(map[string]any)
+				text, _ := part["text"].(string)
+				message.Content += text
+			}
+		}
+		messages = append(messages, message)
+	}
+	return messages
+}
+
+// Tools returns the names of the tools available to the request.
+func (r Request) Tools() []string {
+	raw, _ := r.Params["tools"].([]any)
+	var names []string
+	for _, tool := range raw {
+		tool, _ := tool.(map[string]any)
+		function, _ := tool["function"].(map[string]any)
+		if name, ok := function["name"].(string); ok {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// Server is a fake WorkflowAI server serving scripted chat completions.
+type Server struct {
+	*httptest.Server
+	t testing.TB
+
+	mu        sync.Mutex
+	responses []Response
+	requests  []Request
+}
+
+// NewServer starts a server, closed at the end of the test. The requests that
+// do not have a scripted response fail the test.
+func NewServer(t testing.TB) *Server {
+	t.Helper()
+	s := &Server{t: t}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/chat/completions", s.handleCompletion)
+	s.Server = httptest.NewServer(mux)
+	t.Cleanup(s.Close)
+	return s
+}
+
+// Client returns a client of the server, without retries.
+func (s *Server) Client(opts ...option.RequestOption) workflowai.Client {
+	return workflowai.NewClient(append([]option.RequestOption{
+		option.WithBaseURL(s.URL + "/v1"),
+		option.WithAPIKey("test"),
+		option.WithMaxRetries(0),
+	}, opts...)...)
+}
+
+// Enqueue adds responses served in order to the next requests.
+func (s *Server) Enqueue(responses ...Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.responses = append(s.responses, responses...)
+}
+
+// Requests returns the requests received so far.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+func (s *Server) handleCompletion(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	request := Request{Header: r.Header.Clone(), Body: body}
+	if err := json.Unmarshal(body, &request.Params); err != nil {
+		s.t.Errorf("workflowaitest: invalid request body: %v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	s.requests = append(s.requests, request)
+	n := len(s.requests)
+	var response Response
+	if len(s.responses) > 0 {
+		response, s.responses = s.responses[0], s.responses[1:]
+	}
+	s.mu.Unlock()
+
+	if response == nil {
+		s.t.Errorf("workflowaitest: no response scripted for request %d to %s", n, request.Model())
+		Error{Status: http.StatusInternalServerError, Code: "no_response", Message: "no scripted response"}.serve(w, r, request, n)
+		return
+	}
+	response.serve(w, r, request, n)
+}
+
+// Error is an error response, in the WorkflowAI error format.
+type Error struct {
+	// Status is the HTTP status, 400 when zero
+	Status  int
+	Code    string
+	Message string
+	// Header is added to the response, e.g. Retry-After
+	Header http.Header
+}
+
+func (e Error) serve(w http.ResponseWriter, _ *http.Request, _ Request, n int) {
+	for key, values := range e.Header {
+		w.Header()[key] = values
+	}
+	status := e.Status
+	if status == 0 {
+		status = http.StatusBadRequest
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(errorBody(runID(n), e.Code, e.Message))
+}
+
+func errorBody(id string, code string, message string) map[string]any {
+	return map[string]any{"id": id, "error": map[string]any{"code": code, "message": message}}
+}
+
+// runID returns the ID of the run of the n-th request.
+func runID(n int) string {
+	return fmt.Sprintf("test-agent/run-%d", n)
+}
+
+// Completion is a successful completion, streamed as a single chunk to
+// streaming requests.
+type Completion struct {
+	// ID is the run ID, "test-agent/run-<n>" for the n-th request when empty
+	ID      string
+	Content string
+	CostUSD float64
+	// FinishReason is "stop" when empty
+	FinishReason string
+}
+
+func (c Completion) serve(w http.ResponseWriter, r *http.Request, req Request, n int) {
+	if c.ID == "" {
+		c.ID = runID(n)
+	}
+	if c.FinishReason == "" {
+		c.FinishReason = "stop"
+	}
+	if req.Stream() {
+		Stream{ID: c.ID, CostUSD: c.CostUSD, FinishReason: c.FinishReason, Events: []Event{Chunk(c.Content)}}.serve(w, r, req, n)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(completionBody(c.ID, map[string]any{"role": "assistant", "content": c.Content}, c.FinishReason, c.CostUSD))
+}
+
+// completionBody returns a chat completion with a single choice.
+func completionBody(id string, message map[string]any, finishReason string, costUSD float64) map[string]any {
+	return map[string]any{
+		"id":      id,
+		"object":  "chat.completion",
+		"created": 0,
+		"model":   "test",
+		"choices": []any{map[string]any{
+			"index":         0,
+			"finish_reason": finishReason,
+			"cost_usd":      costUSD,
+			"message":       message,
+		}},
+		"usage": map[string]any{"prompt_tokens": 0, "completion_tokens": 0, "total_tokens": 0},
+	}
+}
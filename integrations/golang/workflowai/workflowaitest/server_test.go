@@ -0,0 +1,174 @@
+package workflowaitest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openai/openai-go"
+)
+
+func newParams(input map[string]any) openai.ChatCompletionNewParams {
+	params := openai.ChatCompletionNewParams{Model: "test-agent/gpt-4o-mini"}
+	params.SetExtraFields(map[string]any{"input": input})
+	return params
+}
+
+// streamContent reads a stream until it ends, returning the content read.
+func streamContent(ctx context.Context, server *Server, params openai.ChatCompletionNewParams) (string, error) {
+	client := server.Client()
+	stream := client.Chat.Completions.NewStreaming(ctx, params)
+	defer stream.Close()
+	var content strings.Builder
+	for stream.Next() {
+		for _, choice := range stream.Current().Choices {
+			content.WriteString(choice.Delta.Content)
+		}
+	}
+	return content.String(), stream.Err()
+}
+
+func TestServer_Completion(t *testing.T) {
+	server := NewServer(t)
+	server.Enqueue(Completion{Content: `{"ok":true}`, CostUSD: 0.01})
+
+	client := server.Client()
+	completion, err := client.Chat.Completions.New(context.Background(), newParams(map[string]any{"text": "hello"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := completion.Choices[0].Message.Content; got != `{"ok":true}` {
+		t.Errorf("content = %q", got)
+	}
+	if completion.ID != "test-agent/run-1" {
+		t.Errorf("id = %q", completion.ID)
+	}
+	requests := server.Requests()
+	if len(requests) != 1 {
+		t.Fatalf("got %d requests", len(requests))
+	}
+	if got := requests[0].Model(); got != "test-agent/gpt-4o-mini" {
+		t.Errorf("model = %q", got)
+	}
+	if got := requests[0].Input()["text"]; got != "hello" {
+		t.Errorf("input text = %v", got)
+	}
+	if requests[0].Stream() {
+		t.Error("request is streaming")
+	}
+}
+
+func TestServer_Error(t *testing.T) {
+	server := NewServer(t)
+	server.Enqueue(Error{Status: http.StatusTooManyRequests, Code: "rate_limit", Message: "slow down"})
+
+	client := server.Client()
+	_, err := client.Chat.Completions.New(context.Background(), newParams(nil))
+	var apiErr *openai.Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestServer_Stream(t *testing.T) {
+	tests := []struct {
+		name        string
+		response    Response
+		wantContent string
+		wantErr     string
+	}{
+		{
+			name:        "chunks",
+			response:    Stream{Events: []Event{Chunk(`{"ok"`), Delay(10 * time.Millisecond), Chunk(`:true}`)}},
+			wantContent: `{"ok":true}`,
+		},
+		{
+			name:        "completion",
+			response:    Completion{Content: "hello"},
+			wantContent: "hello",
+		},
+		{
+			name:        "mid-stream error",
+			response:    Stream{Events: []Event{Chunk("hel"), ErrorChunk("provider_error", "the provider failed")}},
+			wantContent: "hel",
+			wantErr:     "the provider failed",
+		},
+		{
+			name:        "malformed chunk",
+			response:    Stream{Events: []Event{Chunk("hel"), Raw("{not json")}},
+			wantContent: "hel",
+			wantErr:     "invalid character",
+		},
+		{
+			name:        "disconnect",
+			response:    Stream{Events: []Event{Chunk("hel"), Disconnect()}},
+			wantContent: "hel",
+			wantErr:     "EOF",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := NewServer(t)
+			server.Enqueue(tt.response)
+
+			content, err := streamContent(context.Background(), server, newParams(nil))
+			if content != tt.wantContent {
+				t.Errorf("content = %q, want %q", content, tt.wantContent)
+			}
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("err = %v, want %q", err, tt.wantErr)
+			}
+			if requests := server.Requests(); len(requests) != 1 || !requests[0].Stream() {
+				t.Errorf("requests = %+v", requests)
+			}
+		})
+	}
+}
+
+func TestServer_Reconnect(t *testing.T) {
+	server := NewServer(t)
+	server.Enqueue(
+		Stream{Events: []Event{Chunk("hel"), Disconnect()}},
+		Stream{Events: []Event{Chunk("hello")}},
+	)
+
+	var content string
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		if content, err = streamContent(context.Background(), server, newParams(nil)); err == nil {
+			break
+		}
+	}
+	if err != nil || content != "hello" {
+		t.Errorf("content = %q, err = %v", content, err)
+	}
+	if got := len(server.Requests()); got != 2 {
+		t.Errorf("got %d requests, want 2", got)
+	}
+}
+
+func TestServer_Cancel(t *testing.T) {
+	server := NewServer(t)
+	server.Enqueue(Stream{Events: []Event{Chunk("hel"), Hang()}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	client := server.Client()
+	stream := client.Chat.Completions.NewStreaming(ctx, newParams(nil))
+	defer stream.Close()
+	if !stream.Next() || stream.Current().Choices[0].Delta.Content != "hel" {
+		t.Fatalf("first chunk not received: %v", stream.Err())
+	}
+	cancel()
+	if stream.Next() {
+		t.Error("stream continued after cancellation")
+	}
+	if err := stream.Err(); !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+}
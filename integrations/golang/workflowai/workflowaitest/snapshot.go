@@ -0,0 +1,196 @@
+package workflowaitest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/workflowai/workflowai/go/examples/workflowai/workflowaieval"
+)
+
+// UpdateSnapshotsEnv is the environment variable making [AssertSnapshot]
+// write the snapshots instead of comparing them, when set to "1".
+const UpdateSnapshotsEnv = "WORKFLOWAI_UPDATE_SNAPSHOTS"
+
+// SnapshotDir is the directory of the snapshots, relative to the package of
+// the test. It must be in a testdata directory for the snapshots to be
+// written.
+var SnapshotDir = filepath.Join("testdata", "snapshots")
+
+// AssertSnapshot fails the test if the request payload differs from its
+// snapshot in SnapshotDir, named after the test, showing the diff. The payload
+// is a [Request], e.g. from [FakeModel.Requests] or [Server.Requests], or the
+// parameters of the request.
+//
+// The snapshot is a text file readable in code review: the parameters, then
+// the messages with the input variables replaced as WorkflowAI does, then the
+// tools, the response format and the input. Run the tests with
+// WORKFLOWAI_UPDATE_SNAPSHOTS=1 to create or update the snapshots: only the
+// snapshots whose content changed are written.
+func AssertSnapshot(t testing.TB, payload any) bool {
+	t.Helper()
+	got, err := RenderSnapshot(payload)
+	if err != nil {
+		t.Fatalf("workflowaitest: rendering the snapshot: %v", err)
+	}
+	// Subtest names can hold ".." elements
+	name := filepath.FromSlash(t.Name()) + ".txt"
+	if !filepath.IsLocal(name) {
+		t.Errorf("workflowaitest: the snapshot of %s would be outside %s", t.Name(), SnapshotDir)
+		return false
+	}
+	path := filepath.Join(SnapshotDir, name)
+	want, err := os.ReadFile(path)
+	if os.Getenv(UpdateSnapshotsEnv) == "1" {
+		if err == nil && string(want) == got {
+			return true
+		}
+		if !inTestdata(SnapshotDir) {
+			t.Errorf("workflowaitest: not writing the snapshot %s outside of a testdata directory", path)
+			return false
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return true
+	}
+	if errors.Is(err, os.ErrNotExist) {
+		t.Errorf("workflowaitest: no snapshot %s, run with %s=1 to create it", path, UpdateSnapshotsEnv)
+		return false
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(want) != got {
+		// Diff falls back to a line diff of the text for invalid JSON
+		t.Errorf("workflowaitest: the request differs from the snapshot %s, run with %s=1 to update it:\n%s",
+			path, UpdateSnapshotsEnv, workflowaieval.Diff(want, []byte(got)))
+		return false
+	}
+	return true
+}
+
+// inTestdata returns true if dir is a relative path in a testdata directory,
+// which the go tool ignores.
+func inTestdata(dir string) bool {
+	if !filepath.IsLocal(dir) {
+		return false
+	}
+	return slices.Contains(strings.Split(filepath.ToSlash(filepath.Clean(dir)), "/"), "testdata")
+}
+
+// snapshotSections are rendered after the messages, in this order. The other
+// object and array parameters follow, sorted.
+var snapshotSections = []string{"tools", "response_format", "input"}
+
+// RenderSnapshot renders a request payload as written by [AssertSnapshot].
+func RenderSnapshot(payload any) (string, error) {
+	var params map[string]any
+	if r, ok := payload.(Request); ok {
+		params = r.Params
+	} else {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return "", err
+		}
+		if err := json.Unmarshal(data, &params); err != nil {
+			return "", err
+		}
+	}
+	request := Request{Params: params}
+	input := request.Input()
+
+	var (
+		b        strings.Builder
+		scalars  []string
+		sections []string
+	)
+	for key, value := range params {
+		switch value.(type) {
+		case map[string]any, []any:
+			if key != "messages" && !slices.Contains(snapshotSections, key) {
+				sections = append(sections, key)
+			}
+		default:
+			scalars = append(scalars, key)
+		}
+	}
+	sort.Strings(scalars)
+	sort.Strings(sections)
+	for _, key := range scalars {
+		fmt.Fprintf(&b, "%s: %s\n", key, marshal(params[key], ""))
+	}
+
+	raw, _ := params["messages"].([]any)
+	for i, message := range request.Messages() {
+		m, _ := raw[i].(map[string]any)
+		header := message.Role
+		if id, ok := m["tool_call_id"].(string); ok {
+			header += " " + id
+		}
+		fmt.Fprintf(&b, "\n## %s\n", header)
+		if message.Content != "" {
+			fmt.Fprintf(&b, "%s\n", renderVariables(message.Content, input))
+		}
+		if calls, ok := m["tool_calls"]; ok {
+			b.WriteString(indentJSON(calls))
+		}
+	}
+	for _, key := range append(snapshotSections, sections...) {
+		if value, ok := params[key]; ok {
+			fmt.Fprintf(&b, "\n## %s\n%s", key, indentJSON(value))
+		}
+	}
+	return b.String(), nil
+}
+
+var variablePattern = regexp.MustCompile(`\{\{\s*([\w.]+)\s*\}\}`)
+
+// renderVariables replaces the {{variables}} of a message with their value in
+// the input, keeping the variables missing from the input.
+func renderVariables(content string, input map[string]any) string {
+	if input == nil {
+		return content
+	}
+	return variablePattern.ReplaceAllStringFunc(content, func(v string) string {
+		var value any = input
+		for _, key := range strings.Split(variablePattern.FindStringSubmatch(v)[1], ".") {
+			object, ok := value.(map[string]any)
+			if !ok {
+				return v
+			}
+			if value, ok = object[key]; !ok {
+				return v
+			}
+		}
+		if s, ok := value.(string); ok {
+			return s
+		}
+		return marshal(value, "")
+	})
+}
+
+func indentJSON(v any) string {
+	return marshal(v, "  ") + "\n"
+}
+
+// marshal encodes v without escaping HTML characters, frequent in prompts.
+func marshal(v any, indent string) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", indent)
+	enc.Encode(v)
+	return strings.TrimSuffix(buf.String(), "\n")
+}
@@ -0,0 +1,127 @@
+package workflowaitest
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/shared"
+)
+
+// recorder records the failures of a test instead of failing it.
+type recorder struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recorder) Errorf(format string, args ...any) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func snapshotParams(instructions string) openai.ChatCompletionNewParams {
+	params := openai.ChatCompletionNewParams{
+		Model:       "support/gpt-4o",
+		Temperature: openai.Float(0),
+		Messages: []openai.ChatCompletionMessageParamUnion{
+			openai.SystemMessage(instructions),
+			openai.UserMessage("Where is order {{order.id}}? <urgent>"),
+		},
+		Tools: []openai.ChatCompletionToolParam{{Function: shared.FunctionDefinitionParam{
+			Name:        "lookup_order",
+			Description: openai.String("Returns the status of an order"),
+		}}},
+		Metadata: shared.Metadata{"tenant": "acme"},
+	}
+	params.SetExtraFields(map[string]any{"input": map[string]any{"order": map[string]any{"id": "A12"}, "name": "Ada"}})
+	return params
+}
+
+func TestAssertSnapshot(t *testing.T) {
+	fake := NewFakeModel(Rule{Reply: "ok"})
+	if _, err := fake.New(context.Background(), snapshotParams("You help {{name}} with their orders.")); err != nil {
+		t.Fatal(err)
+	}
+	AssertSnapshot(t, fake.Requests()[0])
+}
+
+func TestAssertSnapshot_Changed(t *testing.T) {
+	// Not to write the snapshots when the others are updated
+	t.Setenv(UpdateSnapshotsEnv, "")
+	// The snapshot of TestAssertSnapshot is compared to a changed prompt
+	rec := &recorder{TB: renamed{t, "TestAssertSnapshot"}}
+	if AssertSnapshot(rec, snapshotParams("You help {{name}} with their orders and refunds.")) {
+		t.Fatal("snapshot matched")
+	}
+	if len(rec.errors) != 1 {
+		t.Fatalf("errors = %q", rec.errors)
+	}
+	for _, want := range []string{
+		"- You help Ada with their orders.",
+		"+ You help Ada with their orders and refunds.",
+		"  Where is order A12? <urgent>",
+	} {
+		if !strings.Contains(rec.errors[0], want) {
+			t.Errorf("missing %q in:\n%s", want, rec.errors[0])
+		}
+	}
+}
+
+func TestAssertSnapshot_Missing(t *testing.T) {
+	// Not to write the snapshots when the others are updated
+	t.Setenv(UpdateSnapshotsEnv, "")
+	rec := &recorder{TB: t}
+	if AssertSnapshot(rec, snapshotParams("")) {
+		t.Fatal("missing snapshot matched")
+	}
+	if len(rec.errors) != 1 || !strings.Contains(rec.errors[0], UpdateSnapshotsEnv+"=1") {
+		t.Errorf("errors = %q", rec.errors)
+	}
+}
+
+type renamed struct {
+	testing.TB
+	name string
+}
+
+func (r renamed) Name() string { return r.name }
+
+func TestAssertSnapshot_Update(t *testing.T) {
+	t.Setenv(UpdateSnapshotsEnv, "1")
+	want, err := os.ReadFile(filepath.Join(SnapshotDir, "TestAssertSnapshot.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func(dir string) { SnapshotDir = dir }(SnapshotDir)
+	SnapshotDir = filepath.Join(t.TempDir(), "testdata", "snapshots")
+	path := filepath.Join(SnapshotDir, "TestAssertSnapshot.txt")
+	os.MkdirAll(SnapshotDir, 0o755)
+	os.WriteFile(path, want, 0o644)
+	old := time.Now().Add(-time.Hour).Truncate(time.Second)
+	os.Chtimes(path, old, old)
+
+	// An unchanged snapshot is not rewritten
+	rec := &recorder{TB: renamed{t, "TestAssertSnapshot"}}
+	if !AssertSnapshot(rec, snapshotParams("You help {{name}} with their orders.")) || len(rec.errors) > 0 {
+		t.Fatalf("errors = %q", rec.errors)
+	}
+	if info, err := os.Stat(path); err != nil || !info.ModTime().Equal(old) {
+		t.Errorf("expected the unchanged snapshot not to be written, got %v", err)
+	}
+
+	// Changed snapshots are only written in a relative testdata directory
+	if AssertSnapshot(rec, snapshotParams("You help {{name}} with their refunds.")) || len(rec.errors) != 1 || !strings.Contains(rec.errors[0], "outside of a testdata directory") {
+		t.Errorf("errors = %q", rec.errors)
+	}
+	rec = &recorder{TB: renamed{t, "TestAssertSnapshot/../../../escaped"}}
+	if AssertSnapshot(rec, snapshotParams("")) || len(rec.errors) != 1 || !strings.Contains(rec.errors[0], "would be outside") {
+		t.Errorf("errors = %q", rec.errors)
+	}
+	if got, _ := os.ReadFile(path); string(got) != string(want) {
+		t.Error("the snapshot was overwritten")
+	}
+}
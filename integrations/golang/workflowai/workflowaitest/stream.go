@@ -0,0 +1,128 @@
+package workflowaitest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Stream is a scripted server-sent events response. The events are sent in
+// order, followed by a final chunk with the finish reason and the cost and by
+// "[DONE]", unless an event ends the stream first.
+type Stream struct {
+	// ID is the run ID, "test-agent/run-<n>" for the n-th request when empty
+	ID      string
+	Events  []Event
+	CostUSD float64
+	// FinishReason is "stop" when empty
+	FinishReason string
+}
+
+// Event is a step of a [Stream].
+type Event struct {
+	delay   time.Duration
+	content *string
+	data    string
+	err     *Error
+	end     streamEnd
+}
+
+type streamEnd int
+
+const (
+	endNone streamEnd = iota
+	// endDisconnect closes the connection
+	endDisconnect
+	// endHang waits for the client to cancel the request
+	endHang
+)
+
+// Chunk sends a chunk with the content delta.
+func Chunk(content string) Event {
+	return Event{content: &content}
+}
+
+// Delay pauses the stream, or returns early when the client cancels the
+// request.
+func Delay(d time.Duration) Event {
+	return Event{delay: d}
+}
+
+// ErrorChunk sends an error in the stream, as WorkflowAI does when the run
+// fails after the response started, and ends the stream.
+func ErrorChunk(code string, message string) Event {
+	return Event{err: &Error{Code: code, Message: message}}
+}
+
+// Raw sends data as is, e.g. a malformed chunk.
+func Raw(data string) Event {
+	return Event{data: data}
+}
+
+// Disconnect closes the connection without ending the stream, as a network
+// failure would.
+func Disconnect() Event {
+	return Event{end: endDisconnect}
+}
+
+// Hang keeps the stream open until the client cancels the request.
+func Hang() Event {
+	return Event{end: endHang}
+}
+
+func (s Stream) serve(w http.ResponseWriter, r *http.Request, _ Request, n int) {
+	if s.ID == "" {
+		s.ID = runID(n)
+	}
+	if s.FinishReason == "" {
+		s.FinishReason = "stop"
+	}
+	flusher, _ := w.(http.Flusher)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	send := func(data string) {
+		fmt.Fprintf(w, "data: %s\n\n", data)
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+
+	for _, event := range s.Events {
+		switch {
+		case event.delay > 0:
+			select {
+			case <-time.After(event.delay):
+			case <-r.Context().Done():
+				return
+			}
+		case event.content != nil:
+			send(s.chunk(map[string]any{"index": 0, "delta": map[string]any{"role": "assistant", "content": *event.content}}))
+		case event.data != "":
+			send(event.data)
+		case event.err != nil:
+			data, _ := json.Marshal(errorBody(s.ID, event.err.Code, event.err.Message))
+			send(string(data))
+			return
+		case event.end == endDisconnect:
+			panic(http.ErrAbortHandler)
+		case event.end == endHang:
+			<-r.Context().Done()
+			return
+		}
+	}
+	send(s.chunk(map[string]any{"index": 0, "delta": map[string]any{}, "finish_reason": s.FinishReason, "cost_usd": s.CostUSD}))
+	send("[DONE]")
+}
+
+func (s Stream) chunk(choice map[string]any) string {
+	data, _ := json.Marshal(map[string]any{
+		"id":      s.ID,
+		"object":  "chat.completion.chunk",
+		"created": 0,
+		"model":   "test",
+		"choices": []any{choice},
+	})
+	return string(data)
+}